@@ -477,7 +477,9 @@ type TaskResult struct {
 	// data 结果数据
 	Data *structpb.Struct `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
 	// duration_ms 执行耗时（毫秒）
-	DurationMs    int64 `protobuf:"varint,4,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	DurationMs int64 `protobuf:"varint,4,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	// artifacts 任务产出的文件
+	Artifacts     []*Artifact `protobuf:"bytes,5,rep,name=artifacts,proto3" json:"artifacts,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -540,6 +542,95 @@ func (x *TaskResult) GetDurationMs() int64 {
 	return 0
 }
 
+func (x *TaskResult) GetArtifacts() []*Artifact {
+	if x != nil {
+		return x.Artifacts
+	}
+	return nil
+}
+
+// Artifact 任务产出的文件，uri 与 key 二选一
+type Artifact struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// name 文件名，同一任务内唯一
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// content_type MIME 类型
+	ContentType string `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	// size 字节数，未知时为 0
+	Size int64 `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	// uri 文件地址，scheme 须在 TaskFlow 允许的列表中
+	Uri string `protobuf:"bytes,4,opt,name=uri,proto3" json:"uri,omitempty"`
+	// key TaskFlow 对象存储中的 key
+	Key           string `protobuf:"bytes,5,opt,name=key,proto3" json:"key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Artifact) Reset() {
+	*x = Artifact{}
+	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Artifact) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Artifact) ProtoMessage() {}
+
+func (x *Artifact) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Artifact.ProtoReflect.Descriptor instead.
+func (*Artifact) Descriptor() ([]byte, []int) {
+	return file_api_proto_grpc_task_v1_task_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Artifact) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Artifact) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Artifact) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Artifact) GetUri() string {
+	if x != nil {
+		return x.Uri
+	}
+	return ""
+}
+
+func (x *Artifact) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
 // ErrorDetail 错误详情
 type ErrorDetail struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -557,7 +648,7 @@ type ErrorDetail struct {
 
 func (x *ErrorDetail) Reset() {
 	*x = ErrorDetail{}
-	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[5]
+	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -569,7 +660,7 @@ func (x *ErrorDetail) String() string {
 func (*ErrorDetail) ProtoMessage() {}
 
 func (x *ErrorDetail) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[5]
+	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -582,7 +673,7 @@ func (x *ErrorDetail) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ErrorDetail.ProtoReflect.Descriptor instead.
 func (*ErrorDetail) Descriptor() ([]byte, []int) {
-	return file_api_proto_grpc_task_v1_task_proto_rawDescGZIP(), []int{5}
+	return file_api_proto_grpc_task_v1_task_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *ErrorDetail) GetCode() string {
@@ -626,7 +717,7 @@ type CancelTaskRequest struct {
 
 func (x *CancelTaskRequest) Reset() {
 	*x = CancelTaskRequest{}
-	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[6]
+	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -638,7 +729,7 @@ func (x *CancelTaskRequest) String() string {
 func (*CancelTaskRequest) ProtoMessage() {}
 
 func (x *CancelTaskRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[6]
+	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -651,7 +742,7 @@ func (x *CancelTaskRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CancelTaskRequest.ProtoReflect.Descriptor instead.
 func (*CancelTaskRequest) Descriptor() ([]byte, []int) {
-	return file_api_proto_grpc_task_v1_task_proto_rawDescGZIP(), []int{6}
+	return file_api_proto_grpc_task_v1_task_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *CancelTaskRequest) GetTaskId() string {
@@ -681,7 +772,7 @@ type CancelTaskResponse struct {
 
 func (x *CancelTaskResponse) Reset() {
 	*x = CancelTaskResponse{}
-	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[7]
+	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -693,7 +784,7 @@ func (x *CancelTaskResponse) String() string {
 func (*CancelTaskResponse) ProtoMessage() {}
 
 func (x *CancelTaskResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[7]
+	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -706,7 +797,7 @@ func (x *CancelTaskResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CancelTaskResponse.ProtoReflect.Descriptor instead.
 func (*CancelTaskResponse) Descriptor() ([]byte, []int) {
-	return file_api_proto_grpc_task_v1_task_proto_rawDescGZIP(), []int{7}
+	return file_api_proto_grpc_task_v1_task_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *CancelTaskResponse) GetSuccess() bool {
@@ -734,7 +825,7 @@ type HealthCheckRequest struct {
 
 func (x *HealthCheckRequest) Reset() {
 	*x = HealthCheckRequest{}
-	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[8]
+	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -746,7 +837,7 @@ func (x *HealthCheckRequest) String() string {
 func (*HealthCheckRequest) ProtoMessage() {}
 
 func (x *HealthCheckRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[8]
+	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -759,7 +850,7 @@ func (x *HealthCheckRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HealthCheckRequest.ProtoReflect.Descriptor instead.
 func (*HealthCheckRequest) Descriptor() ([]byte, []int) {
-	return file_api_proto_grpc_task_v1_task_proto_rawDescGZIP(), []int{8}
+	return file_api_proto_grpc_task_v1_task_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *HealthCheckRequest) GetServiceName() string {
@@ -784,7 +875,7 @@ type HealthCheckResponse struct {
 
 func (x *HealthCheckResponse) Reset() {
 	*x = HealthCheckResponse{}
-	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[9]
+	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -796,7 +887,7 @@ func (x *HealthCheckResponse) String() string {
 func (*HealthCheckResponse) ProtoMessage() {}
 
 func (x *HealthCheckResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[9]
+	mi := &file_api_proto_grpc_task_v1_task_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -809,7 +900,7 @@ func (x *HealthCheckResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HealthCheckResponse.ProtoReflect.Descriptor instead.
 func (*HealthCheckResponse) Descriptor() ([]byte, []int) {
-	return file_api_proto_grpc_task_v1_task_proto_rawDescGZIP(), []int{9}
+	return file_api_proto_grpc_task_v1_task_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *HealthCheckResponse) GetStatus() HealthStatus {
@@ -869,14 +960,21 @@ const file_api_proto_grpc_task_v1_task_proto_rawDesc = "" +
 	"\bmetadata\x18\x06 \x03(\v2$.grpc_task.v1.Progress.MetadataEntryR\bmetadata\x1a;\n" +
 	"\rMetadataEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xa5\x01\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xdb\x01\n" +
 	"\n" +
 	"TaskResult\x12\x17\n" +
 	"\atask_id\x18\x01 \x01(\tR\x06taskId\x120\n" +
 	"\x06status\x18\x02 \x01(\x0e2\x18.grpc_task.v1.TaskStatusR\x06status\x12+\n" +
 	"\x04data\x18\x03 \x01(\v2\x17.google.protobuf.StructR\x04data\x12\x1f\n" +
 	"\vduration_ms\x18\x04 \x01(\x03R\n" +
-	"durationMs\"\x89\x01\n" +
+	"durationMs\x124\n" +
+	"\tartifacts\x18\x05 \x03(\v2\x16.grpc_task.v1.ArtifactR\tartifacts\"y\n" +
+	"\bArtifact\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12!\n" +
+	"\fcontent_type\x18\x02 \x01(\tR\vcontentType\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x10\n" +
+	"\x03uri\x18\x04 \x01(\tR\x03uri\x12\x10\n" +
+	"\x03key\x18\x05 \x01(\tR\x03key\"\x89\x01\n" +
 	"\vErrorDetail\x12\x12\n" +
 	"\x04code\x18\x01 \x01(\tR\x04code\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1c\n" +
@@ -927,7 +1025,7 @@ func file_api_proto_grpc_task_v1_task_proto_rawDescGZIP() []byte {
 }
 
 var file_api_proto_grpc_task_v1_task_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_api_proto_grpc_task_v1_task_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_api_proto_grpc_task_v1_task_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
 var file_api_proto_grpc_task_v1_task_proto_goTypes = []any{
 	(TaskStatus)(0),             // 0: grpc_task.v1.TaskStatus
 	(HealthStatus)(0),           // 1: grpc_task.v1.HealthStatus
@@ -936,39 +1034,41 @@ var file_api_proto_grpc_task_v1_task_proto_goTypes = []any{
 	(*ExecuteTaskResponse)(nil), // 4: grpc_task.v1.ExecuteTaskResponse
 	(*Progress)(nil),            // 5: grpc_task.v1.Progress
 	(*TaskResult)(nil),          // 6: grpc_task.v1.TaskResult
-	(*ErrorDetail)(nil),         // 7: grpc_task.v1.ErrorDetail
-	(*CancelTaskRequest)(nil),   // 8: grpc_task.v1.CancelTaskRequest
-	(*CancelTaskResponse)(nil),  // 9: grpc_task.v1.CancelTaskResponse
-	(*HealthCheckRequest)(nil),  // 10: grpc_task.v1.HealthCheckRequest
-	(*HealthCheckResponse)(nil), // 11: grpc_task.v1.HealthCheckResponse
-	nil,                         // 12: grpc_task.v1.ExecuteTaskRequest.MetadataEntry
-	nil,                         // 13: grpc_task.v1.Progress.MetadataEntry
-	nil,                         // 14: grpc_task.v1.HealthCheckResponse.DetailsEntry
-	(*structpb.Struct)(nil),     // 15: google.protobuf.Struct
+	(*Artifact)(nil),            // 7: grpc_task.v1.Artifact
+	(*ErrorDetail)(nil),         // 8: grpc_task.v1.ErrorDetail
+	(*CancelTaskRequest)(nil),   // 9: grpc_task.v1.CancelTaskRequest
+	(*CancelTaskResponse)(nil),  // 10: grpc_task.v1.CancelTaskResponse
+	(*HealthCheckRequest)(nil),  // 11: grpc_task.v1.HealthCheckRequest
+	(*HealthCheckResponse)(nil), // 12: grpc_task.v1.HealthCheckResponse
+	nil,                         // 13: grpc_task.v1.ExecuteTaskRequest.MetadataEntry
+	nil,                         // 14: grpc_task.v1.Progress.MetadataEntry
+	nil,                         // 15: grpc_task.v1.HealthCheckResponse.DetailsEntry
+	(*structpb.Struct)(nil),     // 16: google.protobuf.Struct
 }
 var file_api_proto_grpc_task_v1_task_proto_depIdxs = []int32{
-	15, // 0: grpc_task.v1.ExecuteTaskRequest.payload:type_name -> google.protobuf.Struct
-	12, // 1: grpc_task.v1.ExecuteTaskRequest.metadata:type_name -> grpc_task.v1.ExecuteTaskRequest.MetadataEntry
+	16, // 0: grpc_task.v1.ExecuteTaskRequest.payload:type_name -> google.protobuf.Struct
+	13, // 1: grpc_task.v1.ExecuteTaskRequest.metadata:type_name -> grpc_task.v1.ExecuteTaskRequest.MetadataEntry
 	3,  // 2: grpc_task.v1.ExecuteTaskRequest.options:type_name -> grpc_task.v1.ExecutionOptions
 	5,  // 3: grpc_task.v1.ExecuteTaskResponse.progress:type_name -> grpc_task.v1.Progress
 	6,  // 4: grpc_task.v1.ExecuteTaskResponse.result:type_name -> grpc_task.v1.TaskResult
-	7,  // 5: grpc_task.v1.ExecuteTaskResponse.error:type_name -> grpc_task.v1.ErrorDetail
-	13, // 6: grpc_task.v1.Progress.metadata:type_name -> grpc_task.v1.Progress.MetadataEntry
+	8,  // 5: grpc_task.v1.ExecuteTaskResponse.error:type_name -> grpc_task.v1.ErrorDetail
+	14, // 6: grpc_task.v1.Progress.metadata:type_name -> grpc_task.v1.Progress.MetadataEntry
 	0,  // 7: grpc_task.v1.TaskResult.status:type_name -> grpc_task.v1.TaskStatus
-	15, // 8: grpc_task.v1.TaskResult.data:type_name -> google.protobuf.Struct
-	1,  // 9: grpc_task.v1.HealthCheckResponse.status:type_name -> grpc_task.v1.HealthStatus
-	14, // 10: grpc_task.v1.HealthCheckResponse.details:type_name -> grpc_task.v1.HealthCheckResponse.DetailsEntry
-	2,  // 11: grpc_task.v1.TaskExecutorService.ExecuteTask:input_type -> grpc_task.v1.ExecuteTaskRequest
-	8,  // 12: grpc_task.v1.TaskExecutorService.CancelTask:input_type -> grpc_task.v1.CancelTaskRequest
-	10, // 13: grpc_task.v1.TaskExecutorService.HealthCheck:input_type -> grpc_task.v1.HealthCheckRequest
-	4,  // 14: grpc_task.v1.TaskExecutorService.ExecuteTask:output_type -> grpc_task.v1.ExecuteTaskResponse
-	9,  // 15: grpc_task.v1.TaskExecutorService.CancelTask:output_type -> grpc_task.v1.CancelTaskResponse
-	11, // 16: grpc_task.v1.TaskExecutorService.HealthCheck:output_type -> grpc_task.v1.HealthCheckResponse
-	14, // [14:17] is the sub-list for method output_type
-	11, // [11:14] is the sub-list for method input_type
-	11, // [11:11] is the sub-list for extension type_name
-	11, // [11:11] is the sub-list for extension extendee
-	0,  // [0:11] is the sub-list for field type_name
+	16, // 8: grpc_task.v1.TaskResult.data:type_name -> google.protobuf.Struct
+	7,  // 9: grpc_task.v1.TaskResult.artifacts:type_name -> grpc_task.v1.Artifact
+	1,  // 10: grpc_task.v1.HealthCheckResponse.status:type_name -> grpc_task.v1.HealthStatus
+	15, // 11: grpc_task.v1.HealthCheckResponse.details:type_name -> grpc_task.v1.HealthCheckResponse.DetailsEntry
+	2,  // 12: grpc_task.v1.TaskExecutorService.ExecuteTask:input_type -> grpc_task.v1.ExecuteTaskRequest
+	9,  // 13: grpc_task.v1.TaskExecutorService.CancelTask:input_type -> grpc_task.v1.CancelTaskRequest
+	11, // 14: grpc_task.v1.TaskExecutorService.HealthCheck:input_type -> grpc_task.v1.HealthCheckRequest
+	4,  // 15: grpc_task.v1.TaskExecutorService.ExecuteTask:output_type -> grpc_task.v1.ExecuteTaskResponse
+	10, // 16: grpc_task.v1.TaskExecutorService.CancelTask:output_type -> grpc_task.v1.CancelTaskResponse
+	12, // 17: grpc_task.v1.TaskExecutorService.HealthCheck:output_type -> grpc_task.v1.HealthCheckResponse
+	15, // [15:18] is the sub-list for method output_type
+	12, // [12:15] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
 }
 
 func init() { file_api_proto_grpc_task_v1_task_proto_init() }
@@ -987,7 +1087,7 @@ func file_api_proto_grpc_task_v1_task_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_proto_grpc_task_v1_task_proto_rawDesc), len(file_api_proto_grpc_task_v1_task_proto_rawDesc)),
 			NumEnums:      2,
-			NumMessages:   13,
+			NumMessages:   14,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -17,12 +17,24 @@ import (
 
 	taskapp "github.com/Aixtrade/TaskFlow/internal/application/task"
 	"github.com/Aixtrade/TaskFlow/internal/config"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/blobstore"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/discovery"
 	"github.com/Aixtrade/TaskFlow/internal/infrastructure/observability/logging"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/observability/metrics"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/progresstoken"
 	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/routing"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/schema"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/tracking"
 	httpserver "github.com/Aixtrade/TaskFlow/internal/interfaces/http"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
 	"github.com/Aixtrade/TaskFlow/pkg/progress"
+	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
 )
 
+// subscriptionPoolTimeout 订阅连接池耗尽时等待空闲连接的时长，超时后订阅以容量不足结束
+const subscriptionPoolTimeout = time.Second
+
 func main() {
 	configPath := flag.String("config", "", "path to config file")
 	flag.Parse()
@@ -32,12 +44,24 @@ func main() {
 		log.Fatalf("failed to load config: %v", err)
 	}
 
-	logger, err := logging.NewLogger(&cfg.Logging)
+	logger, logLevel, err := logging.NewLevelLogger(&cfg.Logging)
 	if err != nil {
 		log.Fatalf("failed to create logger: %v", err)
 	}
 	defer logger.Sync()
 
+	accessLogger, err := logging.NewAccessLogger(&cfg.Logging, logger)
+	if err != nil {
+		log.Fatalf("failed to create access logger: %v", err)
+	}
+	defer accessLogger.Sync()
+
+	auditLogger, err := logging.NewAuditLogger(&cfg.Logging, logger)
+	if err != nil {
+		log.Fatalf("failed to create audit logger: %v", err)
+	}
+	defer auditLogger.Sync()
+
 	logger.Info("starting taskflow api",
 		zap.String("env", cfg.App.Env),
 		zap.String("host", cfg.Server.HTTP.Host),
@@ -51,6 +75,16 @@ func main() {
 	})
 	defer redisClient.Close()
 
+	// SSE 订阅使用独立的连接池：每个订阅长期占用一个阻塞连接，不能挤占普通请求的连接
+	subscriptionRedis := redis.NewClient(&redis.Options{
+		Addr:        cfg.Redis.Addr,
+		Password:    cfg.Redis.Password,
+		DB:          cfg.Redis.DB,
+		PoolSize:    cfg.Progress.SubscriptionPoolSize,
+		PoolTimeout: subscriptionPoolTimeout,
+	})
+	defer subscriptionRedis.Close()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -58,24 +92,178 @@ func main() {
 		logger.Fatal("failed to connect to redis", zap.Error(err))
 	}
 
-	asynqClient, err := asynqqueue.NewClient(&cfg.Redis)
+	var apiMetrics *metrics.Metrics
+	clientOpts := []asynqqueue.ClientOption{
+		asynqqueue.WithOperationTimeout(cfg.OperationTimeouts.Inspector),
+		asynqqueue.WithSlowEnqueueWarning(logger, cfg.Logging.SlowEnqueueThreshold),
+	}
+	if cfg.Metrics.Enabled {
+		apiMetrics = metrics.New(metrics.WithLabelGuard(metrics.NewLabelGuard(cfg.Metrics.MaxLabelValues, logger)))
+		clientOpts = append(clientOpts, asynqqueue.WithEnqueueObserver(apiMetrics))
+	}
+	asynqClient, err := asynqqueue.NewClient(&cfg.Redis, clientOpts...)
 	if err != nil {
 		logger.Fatal("failed to create asynq client", zap.Error(err))
 	}
 	defer asynqClient.Close()
 
-	taskService := taskapp.NewService(asynqClient, logger)
+	// 与 worker 写入的配置指纹比较，DB 等配置不一致时两侧都"健康"但任务和进度互相不可见
+	fingerprintRedis := discovery.NewFingerprintClient(&cfg.Redis)
+	defer fingerprintRedis.Close()
+	configCheck := discovery.NewFingerprintCheck(discovery.NewFingerprintStore(fingerprintRedis), discovery.NewFingerprint(cfg))
+	switch fp := configCheck.Check(ctx); fp.Status {
+	case discovery.FingerprintMismatch:
+		logger.Error("API CONFIG DOES NOT MATCH WORKER CONFIG: tasks or progress written by one side are invisible to the other",
+			zap.Strings("mismatches", fp.Mismatches),
+			zap.String("worker_instance", fp.Worker.InstanceID),
+		)
+	case discovery.FingerprintUnknown:
+		logger.Warn("cannot verify worker config fingerprint", zap.String("reason", fp.Error))
+	}
 
-	router := httpserver.NewRouter(httpserver.RouterConfig{
-		Config:      cfg,
-		Logger:      logger,
-		TaskService: taskService,
-		RedisClient: redisClient,
-		Progress: progress.StreamOptions{
+	var directory *discovery.Directory
+	var serviceOpts []taskapp.Option
+	if cfg.Discovery.Enabled {
+		directory = discovery.NewDirectory(redisClient, logger)
+		serviceOpts = append(serviceOpts, taskapp.WithCapabilityCheck(directory, cfg.Discovery.TypeCheck))
+	}
+
+	if cfg.Consistency.Enabled {
+		tracker := tracking.NewCreationTracker(redisClient, cfg.Consistency.MarkerTTL)
+		serviceOpts = append(serviceOpts, taskapp.WithReadYourWrites(
+			tracker,
+			cfg.Consistency.RetryAttempts,
+			cfg.Consistency.RetryInterval,
+		))
+	}
+
+	if cfg.BlobStore.Enabled() {
+		blobs, err := blobstore.New(&cfg.BlobStore)
+		if err != nil {
+			logger.Fatal("failed to create blob store", zap.Error(err))
+		}
+		serviceOpts = append(serviceOpts, taskapp.WithBlobStore(blobs), taskapp.WithArtifactPresignTTL(cfg.Results.Artifacts.PresignTTL))
+	}
+
+	serviceOpts = append(serviceOpts, taskapp.WithLimits(limitsFromConfig(cfg)), taskapp.WithQueues(cfg.KnownQueues()))
+	idGenerator, err := taskapp.NewIDGenerator(cfg.TaskIDs.Scheme)
+	if err != nil {
+		logger.Fatal("invalid task id scheme", zap.Error(err))
+	}
+	serviceOpts = append(serviceOpts, taskapp.WithIDGenerator(idGenerator, cfg.TaskIDs.Prefix))
+	serviceOpts = append(serviceOpts, taskapp.WithAuditLogger(auditLogger))
+	serviceOpts = append(serviceOpts, taskapp.WithRequeueWait(cfg.Server.HTTP.RequeueWait))
+	serviceOpts = append(serviceOpts, taskapp.WithPayloadPreview(taskapp.NewRedactor(cfg.Redaction.Fields), cfg.Redaction.PreviewBytes))
+
+	// 热更新时即使当前没有路由也创建路由表，以便之后添加路由
+	var routes *routing.Table
+	if len(cfg.Routing.Routes) > 0 || cfg.App.HotReload {
+		routes = routing.NewTable(&cfg.Routing)
+		serviceOpts = append(serviceOpts, taskapp.WithLabelRouting(routes))
+	}
+
+	var (
+		metricsHandler http.Handler
+		redisObserver  progress.RedisObserver
+
+		deprecationMetrics taskapp.DeprecationRecorder
+	)
+	if apiMetrics != nil {
+		serviceOpts = append(serviceOpts, taskapp.WithEnqueueMetrics(apiMetrics))
+		deprecationMetrics = apiMetrics
+		if cfg.Metrics.QueueStats.Enabled {
+			apiMetrics.RegisterQueues(asynqClient, cfg.Metrics.QueueStats.CacheTTL, clock.Real())
+		}
+		metricsHandler = apiMetrics.Handler()
+		redisObserver = apiMetrics
+	}
+
+	// 关闭进度功能时不创建发布器和订阅器，路由也不注册进度相关端点
+	var (
+		publisher  *progress.Publisher
+		subscriber *progress.Subscriber
+	)
+	if cfg.Progress.IsEnabled() {
+		publisher = progress.NewPublisher(redisClient, logger, progress.StreamOptions{
+			MaxLen:      cfg.Progress.MaxLen,
+			TTL:         cfg.Progress.TTL,
+			ReadTimeout: cfg.Progress.ReadTimeout,
+
+			CompletedTTL:           cfg.Progress.CompletedTTL,
+			TypeOverrides:          cfg.Progress.TypeOverrides(),
+			Unwatched:              progress.UnwatchedMode(cfg.Progress.Unwatched),
+			UnwatchedInterval:      cfg.Progress.UnwatchedInterval,
+			SlowOperationThreshold: cfg.Progress.SlowOperationThreshold,
+			RedisObserver:          redisObserver,
+		})
+		serviceOpts = append(serviceOpts, taskapp.WithCompletionPublisher(publisher), taskapp.WithProgressCleanup(publisher))
+		if cfg.Progress.PublishOnCreate {
+			serviceOpts = append(serviceOpts, taskapp.WithCreationEvents(publisher))
+		}
+	}
+	var tokenRevocations *progresstoken.Revocations
+	if cfg.Progress.ReportTokens.Enabled() {
+		tokenRevocations = progresstoken.NewRevocations(redisClient, cfg.Progress.ReportTokens.MaxTTL)
+		serviceOpts = append(serviceOpts, taskapp.WithProgressTokenRevocation(tokenRevocations))
+	}
+
+	var schemas *schema.Registry
+	if cfg.Schemas.Enabled {
+		schemas = schema.NewRegistry(redisClient, logger)
+		serviceOpts = append(serviceOpts, taskapp.WithPayloadValidator(schemas))
+	}
+
+	if len(cfg.Deprecations) > 0 {
+		serviceOpts = append(serviceOpts, taskapp.WithDeprecations(deprecationsFromConfig(cfg.Deprecations), deprecationMetrics))
+	}
+
+	if cfg.Progress.IsEnabled() {
+		subscriber = progress.NewSubscriber(redisClient, logger, progress.StreamOptions{
 			MaxLen:      cfg.Progress.MaxLen,
 			TTL:         cfg.Progress.TTL,
 			ReadTimeout: cfg.Progress.ReadTimeout,
-		},
+
+			MaxReadTimeout:   cfg.Progress.MaxReadTimeout,
+			TaskChecker:      asynqClient,
+			WatchdogInterval: cfg.Progress.WatchdogInterval,
+			NotFoundGrace:    cfg.Progress.NotFoundGrace,
+
+			SlowOperationThreshold: cfg.Progress.SlowOperationThreshold,
+			RedisObserver:          redisObserver,
+
+			SubscriptionClient: subscriptionRedis,
+			MaxSubscriptions:   cfg.Progress.SubscriptionPoolSize,
+		})
+		if apiMetrics != nil {
+			apiMetrics.RegisterProgressSubscriber(subscriber)
+		}
+		serviceOpts = append(serviceOpts, taskapp.WithWorkerLookup(subscriber))
+	}
+	taskService := taskapp.NewService(asynqClient, logger, serviceOpts...)
+
+	// 进度自检：定期读取 worker 发布的 canary 进度流，确认两侧配置一致
+	var canary *progress.Canary
+	if cfg.Progress.Canary.Enabled {
+		canary = progress.NewReadCanary(subscriber, cfg.Progress.Canary.TaskID, cfg.Progress.Canary.StaleAfter, logger)
+		if apiMetrics != nil {
+			apiMetrics.RegisterProgressCanary(canary)
+		}
+	}
+
+	router := httpserver.NewRouter(httpserver.RouterConfig{
+		Config:       cfg,
+		Logger:       logger,
+		AccessLogger: accessLogger,
+		TaskService:  taskService,
+		RedisClient:  redisClient,
+		Subscriber:   subscriber,
+		Canary:       canary,
+		Directory:    directory,
+		Schemas:      schemas,
+		Metrics:      metricsHandler,
+		ConfigCheck:  configCheck,
+		Publisher:    publisher,
+		Revocations:  tokenRevocations,
 	})
 
 	engine := router.Setup()
@@ -89,6 +277,41 @@ func main() {
 		IdleTimeout:  120 * time.Second, // 增加空闲超时
 	}
 
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	if schemas != nil {
+		go schemas.Watch(watchCtx, cfg.Schemas.RefreshInterval)
+	}
+	if canary != nil {
+		go canary.Run(watchCtx, cfg.Progress.Canary.Interval)
+	}
+	if apiMetrics != nil && cfg.QueueCleanup.Enabled {
+		// 过期队列由 worker 删除，API 按同样的间隔删除这些队列残留的入队指标；未启用清理时不删除
+		go apiMetrics.RunForgetMissingQueues(watchCtx, asynqClient, cfg.QueueCleanup.Interval, clock.Real(), logger)
+	}
+
+	if cfg.App.HotReload {
+		err := config.Watch(*configPath, func(updated *config.Config) {
+			logLevel.SetLevel(logging.ParseLevel(updated.Logging.Level))
+			routes.Update(&updated.Routing)
+			taskService.SetLimits(limitsFromConfig(updated))
+			taskService.SetQueues(updated.KnownQueues())
+			logger.Info("config reloaded",
+				zap.String("log_level", logLevel.String()),
+				zap.Int("routes", len(updated.Routing.Routes)),
+			)
+			// queues 只有 worker 使用的权重，API 的队列列表随 routing 更新
+			if changed := config.RestartRequired(cfg, updated, "routing", "limits", "queues"); len(changed) > 0 {
+				logger.Warn("config changes require a restart to take effect", zap.Strings("sections", changed))
+			}
+		}, func(err error) {
+			logger.Error("invalid config change ignored", zap.Error(err))
+		})
+		if err != nil {
+			logger.Fatal("failed to watch config", zap.Error(err))
+		}
+	}
+
 	go func() {
 		logger.Info("starting http server", zap.String("addr", addr))
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -111,3 +334,46 @@ func main() {
 
 	logger.Info("server stopped")
 }
+
+// deprecationsFromConfig 将配置转换为弃用项，迁移名已在配置校验时确认存在
+func deprecationsFromConfig(cfgs []config.DeprecationConfig) []taskapp.Deprecation {
+	deprecations := make([]taskapp.Deprecation, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		d := taskapp.Deprecation{
+			Name:    cfg.Name,
+			Type:    tasktype.Type(cfg.Type),
+			Rewrite: cfg.Rewrite,
+			Message: cfg.Message,
+			Cutoff:  cfg.Cutoff,
+		}
+		if cfg.Migration != "" {
+			if m, ok := tasktype.LookupMigration(cfg.Migration); ok {
+				d.Migration = &m
+			}
+		}
+		deprecations = append(deprecations, d)
+	}
+	return deprecations
+}
+
+// limitsFromConfig 将配置转换为创建任务的参数限制
+func limitsFromConfig(cfg *config.Config) taskapp.Limits {
+	l := &cfg.Limits
+	limits := taskapp.Limits{
+		DefaultMaxRetries: l.DefaultMaxRetries,
+		DefaultTimeout:    l.DefaultTimeout,
+		MaxRetriesCap:     l.MaxRetriesCap,
+		MaxRetriesMode:    l.MaxRetriesMode,
+		TimeoutCap:        l.TimeoutCap,
+		TimeoutMode:       l.TimeoutMode,
+		ProcessAtHorizon:  l.ProcessAtHorizon,
+		ProcessAtMode:     l.ProcessAtMode,
+
+		DemoMaxCount:         l.DemoMaxCount,
+		DemoMaxMessageLength: l.DemoMaxMessageLength,
+	}
+	if cfg.GRPCServices.Enabled {
+		limits.GRPCMinTimeout = cfg.GRPCServices.MinTaskTimeout()
+	}
+	return limits
+}
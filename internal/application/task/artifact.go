@@ -0,0 +1,120 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/blobstore"
+	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+	"github.com/Aixtrade/TaskFlow/pkg/payload"
+)
+
+// defaultPresignTTL 预签名下载地址的默认有效期
+const defaultPresignTTL = 15 * time.Minute
+
+// WithArtifactPresignTTL 设置下载任务产出文件时预签名地址的有效期，仅在对象存储实现 blobstore.Presigner 时生效
+func WithArtifactPresignTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.presignTTL = ttl
+	}
+}
+
+// GetTaskArtifactQuery 下载任务产出的某个文件
+type GetTaskArtifactQuery struct {
+	TaskID string
+	Queue  string
+	Name   string
+}
+
+// TaskArtifact 文件的下载方式：RedirectURL 不为空时重定向，否则由 Body 提供内容，调用方负责关闭 Body
+type TaskArtifact struct {
+	Artifact    payload.Artifact
+	RedirectURL string
+	Body        io.ReadCloser
+}
+
+// GetTaskArtifact 在任务结果的文件列表中查找 Name。文件在对象存储中时优先返回预签名地址，
+// 存储不支持预签名时直接读取；外部文件只有 http(s) 地址可以重定向，其余返回 ErrArtifactUnavailable
+func (s *Service) GetTaskArtifact(ctx context.Context, query *GetTaskArtifactQuery) (*TaskArtifact, error) {
+	result, err := s.GetTaskResult(ctx, &GetTaskQuery{TaskID: query.TaskID, Queue: query.Queue})
+	if err != nil {
+		if errors.Is(err, apperrors.ErrResultNotFound) {
+			return nil, errors.Join(apperrors.ErrArtifactNotFound, err)
+		}
+		return nil, err
+	}
+	artifacts, err := decodeArtifacts(result.Body)
+	result.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var artifact *payload.Artifact
+	for i := range artifacts {
+		if artifacts[i].Name == query.Name {
+			artifact = &artifacts[i]
+			break
+		}
+	}
+	if artifact == nil {
+		return nil, fmt.Errorf("%w: %q", apperrors.ErrArtifactNotFound, query.Name)
+	}
+
+	if artifact.Key == "" {
+		u, err := url.Parse(artifact.URI)
+		if err != nil || (!strings.EqualFold(u.Scheme, "http") && !strings.EqualFold(u.Scheme, "https")) {
+			return nil, fmt.Errorf("%w: %q is not an http(s) uri", apperrors.ErrArtifactUnavailable, query.Name)
+		}
+		return &TaskArtifact{Artifact: *artifact, RedirectURL: artifact.URI}, nil
+	}
+
+	// 保存时已校验，这里再次检查，避免结果被改写后读出其他对象
+	if !payload.ValidArtifactKey(query.TaskID, artifact.Key) {
+		return nil, fmt.Errorf("%w: %q is outside the task's artifact prefix", apperrors.ErrArtifactUnavailable, query.Name)
+	}
+	if s.blobs == nil {
+		return nil, fmt.Errorf("%w: %w", apperrors.ErrArtifactUnavailable, apperrors.ErrBlobStoreDisabled)
+	}
+	if presigner, ok := s.blobs.(blobstore.Presigner); ok {
+		redirect, err := presigner.PresignGet(ctx, artifact.Key, s.presignTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to presign artifact: %w", err)
+		}
+		return &TaskArtifact{Artifact: *artifact, RedirectURL: redirect}, nil
+	}
+
+	body, obj, err := s.blobs.Open(ctx, artifact.Key)
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %q is missing from the blob store", apperrors.ErrArtifactNotFound, query.Name)
+		}
+		return nil, fmt.Errorf("failed to open artifact: %w", err)
+	}
+	if artifact.ContentType == "" {
+		artifact.ContentType = obj.ContentType
+	}
+	artifact.Size = obj.Size
+	return &TaskArtifact{Artifact: *artifact, Body: body}, nil
+}
+
+// decodeArtifacts 从任务结果中读取文件列表，结果不是 payload.ArtifactResult 时视为没有文件
+func decodeArtifacts(r io.Reader) ([]payload.Artifact, error) {
+	var result struct {
+		Artifacts []payload.Artifact `json:"artifacts"`
+	}
+	if err := json.NewDecoder(r).Decode(&result); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read result: %w", err)
+	}
+	return result.Artifacts, nil
+}
@@ -0,0 +1,57 @@
+package task
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// 审计日志中的操作名
+const (
+	AuditCancelTask  = "task.cancel"
+	AuditDeleteTask  = "task.delete"
+	AuditArchiveTask = "task.archive"
+	AuditRunTask     = "task.run"
+	AuditRequeueTask = "task.requeue"
+	AuditFlushGroup  = "group.flush"
+)
+
+// AuditActor 发起写操作的调用方，由接口层放入 context
+type AuditActor struct {
+	APIKey     string // API key 名称，未启用 API key 时为空
+	RequestID  string
+	RemoteAddr string
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor 将调用方信息放入 context，写操作的审计日志会带上这些字段
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// WithAuditLogger 审计日志写入的 logger，未设置时使用应用 logger 的 audit 子 logger
+func WithAuditLogger(logger *zap.Logger) Option {
+	return func(s *Service) {
+		s.auditLogger = logger
+	}
+}
+
+// audit 记录一次实际执行的写操作，err 不为 nil 时记为失败。预览（dry run）不记录
+func (s *Service) audit(ctx context.Context, action string, err error, fields ...zap.Field) {
+	actor, _ := ctx.Value(auditActorKey{}).(AuditActor)
+
+	outcome := "succeeded"
+	if err != nil {
+		outcome = "failed"
+		fields = append(fields, zap.Error(err))
+	}
+	fields = append(fields,
+		zap.String("action", action),
+		zap.String("outcome", outcome),
+		zap.String("api_key", actor.APIKey),
+		zap.String("request_id", actor.RequestID),
+		zap.String("remote_addr", actor.RemoteAddr),
+	)
+	s.auditLogger.Info("audit", fields...)
+}
@@ -2,9 +2,16 @@ package task
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
 	"time"
 
+	"github.com/hibiken/asynq"
+
 	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+	"github.com/Aixtrade/TaskFlow/pkg/payload"
 	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
 )
 
@@ -17,6 +24,11 @@ type CreateTaskCommand struct {
 	ProcessAt  time.Time         `json:"process_at,omitempty"`
 	Unique     time.Duration     `json:"unique,omitempty"`
 	Metadata   map[string]string `json:"metadata,omitempty"`
+	Requires   []string          `json:"requires,omitempty"`
+	// TaskID 调用方指定的任务 ID，为空时自动生成；必须符合配置的 ID 格式
+	TaskID string `json:"task_id,omitempty"`
+	// IDPrefix 租户的任务 ID 前缀，为空时使用服务配置的前缀
+	IDPrefix string `json:"id_prefix,omitempty"`
 }
 
 func (c *CreateTaskCommand) Validate() error {
@@ -26,18 +38,154 @@ func (c *CreateTaskCommand) Validate() error {
 	if len(c.Payload) == 0 {
 		return apperrors.ErrInvalidPayload
 	}
+	return c.normalizeQueue()
+}
+
+// normalizeQueue 规范化指定的队列，未指定时使用任务类型的默认队列
+func (c *CreateTaskCommand) normalizeQueue() error {
+	if c.Queue == "" {
+		return nil
+	}
+	queue, err := NormalizeQueue(c.Queue)
+	if err != nil {
+		return err
+	}
+	c.Queue = queue
 	return nil
 }
 
+// queue 返回任务将进入的队列（标签路由之前）
+func (c *CreateTaskCommand) queue() string {
+	if c.Queue != "" {
+		return c.Queue
+	}
+	return c.Type.Queue()
+}
+
+// ValidatePayload 对有内置校验的任务类型检查 payload 字段，l 为 nil 时使用默认上限
+func (c *CreateTaskCommand) ValidatePayload(l *Limits) error {
+	var err error
+	switch c.Type {
+	case tasktype.Demo:
+		var limits payload.DemoLimits
+		if l != nil {
+			limits = payload.DemoLimits{MaxCount: l.DemoMaxCount, MaxMessageLength: l.DemoMaxMessageLength}
+		}
+		err = payload.ValidateDemoPayload(c.Payload, limits)
+	}
+
+	var fieldErr *payload.ValidationError
+	if errors.As(err, &fieldErr) {
+		return apperrors.NewPayloadFieldError(c.Type.String(), fieldErr.Field, fieldErr.Message)
+	}
+	return err
+}
+
+const (
+	LimitReject = "reject"
+	LimitClamp  = "clamp"
+)
+
+// Limits 创建任务参数的默认值与上限，上限为 0 表示不限制；
+// 超出上限时按对应 Mode 拒绝（reject）或截断到上限（clamp）
+type Limits struct {
+	DefaultMaxRetries int
+	DefaultTimeout    time.Duration
+
+	MaxRetriesCap    int
+	MaxRetriesMode   string
+	TimeoutCap       time.Duration
+	TimeoutMode      string
+	ProcessAtHorizon time.Duration
+	ProcessAtMode    string
+
+	// GRPCMinTimeout grpc_task 的最短 timeout，即 grpc_services.budget_margin 与 min_budget 之和。
+	// 更短的任务在 worker 上剩余时间总是不够，永远不会调用下游，0 表示不检查
+	GRPCMinTimeout time.Duration
+
+	// DemoMaxCount demo 任务 count 上限，0 表示使用默认值
+	DemoMaxCount int
+	// DemoMaxMessageLength demo 任务 message 最大字符数，0 表示使用默认值
+	DemoMaxMessageLength int
+}
+
+// ApplyLimits 填充默认值并检查上限，返回截断时的提示
+func (c *CreateTaskCommand) ApplyLimits(l *Limits, now time.Time) ([]string, error) {
+	if c.MaxRetries == 0 {
+		c.MaxRetries = l.DefaultMaxRetries
+	}
+	if c.Timeout == 0 {
+		c.Timeout = l.DefaultTimeout
+	}
+
+	var warnings []string
+	if l.MaxRetriesCap > 0 && c.MaxRetries > l.MaxRetriesCap {
+		if l.MaxRetriesMode != LimitClamp {
+			return nil, apperrors.NewLimitError("max_retries", "limits.max_retries_cap",
+				strconv.Itoa(l.MaxRetriesCap), strconv.Itoa(c.MaxRetries))
+		}
+		warnings = append(warnings, fmt.Sprintf("max_retries clamped from %d to %d", c.MaxRetries, l.MaxRetriesCap))
+		c.MaxRetries = l.MaxRetriesCap
+	}
+	if l.TimeoutCap > 0 && c.Timeout > l.TimeoutCap {
+		if l.TimeoutMode != LimitClamp {
+			return nil, apperrors.NewLimitError("timeout", "limits.timeout_cap",
+				l.TimeoutCap.String(), c.Timeout.String())
+		}
+		warnings = append(warnings, fmt.Sprintf("timeout clamped from %s to %s", c.Timeout, l.TimeoutCap))
+		c.Timeout = l.TimeoutCap
+	}
+	if c.Type == tasktype.GRPCTask && l.GRPCMinTimeout > 0 && c.Timeout > 0 && c.Timeout < l.GRPCMinTimeout {
+		return nil, apperrors.NewMinimumError("timeout", "grpc_services.budget_margin + min_budget",
+			l.GRPCMinTimeout.String(), c.Timeout.String())
+	}
+	if l.ProcessAtHorizon > 0 && c.ProcessAt.After(now.Add(l.ProcessAtHorizon)) {
+		latest := now.Add(l.ProcessAtHorizon)
+		if l.ProcessAtMode != LimitClamp {
+			return nil, apperrors.NewLimitError("process_at", "limits.process_at_horizon",
+				l.ProcessAtHorizon.String(), c.ProcessAt.Format(time.RFC3339))
+		}
+		warnings = append(warnings, fmt.Sprintf("process_at clamped from %s to %s",
+			c.ProcessAt.Format(time.RFC3339), latest.Format(time.RFC3339)))
+		c.ProcessAt = latest
+	}
+	return warnings, nil
+}
+
+type UploadTaskCommand struct {
+	CreateTaskCommand
+	FileField   string    `json:"file_field"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	File        io.Reader `json:"-"`
+}
+
+func (c *UploadTaskCommand) Validate() error {
+	if !c.Type.IsValid() {
+		return apperrors.ErrInvalidTaskType
+	}
+	if c.File == nil || c.Filename == "" {
+		return apperrors.ErrInvalidPayload
+	}
+	if c.FileField == "" {
+		c.FileField = "file"
+	}
+	return c.normalizeQueue()
+}
+
 type CancelTaskCommand struct {
 	TaskID string `json:"task_id"`
+	Queue  string `json:"queue"`
 }
 
 func (c *CancelTaskCommand) Validate() error {
 	if c.TaskID == "" {
 		return apperrors.ErrInvalidTaskID
 	}
-	return nil
+	if c.Queue == "" {
+		c.Queue = "default"
+	}
+	return normalizeQueue(&c.Queue)
 }
 
 type DeleteTaskCommand struct {
@@ -49,8 +197,65 @@ func (c *DeleteTaskCommand) Validate() error {
 	if c.TaskID == "" {
 		return apperrors.ErrInvalidTaskID
 	}
-	if c.Queue == "" {
-		return apperrors.ErrInvalidQueue
+	return normalizeQueue(&c.Queue)
+}
+
+// ArchiveTaskCommand 将未开始执行的任务移入 archived
+type ArchiveTaskCommand struct {
+	TaskID string `json:"task_id"`
+	Queue  string `json:"queue"`
+}
+
+func (c *ArchiveTaskCommand) Validate() error {
+	if c.TaskID == "" {
+		return apperrors.ErrInvalidTaskID
+	}
+	return normalizeQueue(&c.Queue)
+}
+
+// RunTaskCommand 让 scheduled 或 retry 状态的任务立即执行
+type RunTaskCommand struct {
+	TaskID string `json:"task_id"`
+	Queue  string `json:"queue"`
+}
+
+func (c *RunTaskCommand) Validate() error {
+	if c.TaskID == "" {
+		return apperrors.ErrInvalidTaskID
+	}
+	return normalizeQueue(&c.Queue)
+}
+
+// RequeueTaskCommand 取消正在执行的任务，并以新 ID 重新入队其副本
+type RequeueTaskCommand struct {
+	TaskID string `json:"task_id"`
+	Queue  string `json:"queue"`
+	// IDPrefix 新任务 ID 的前缀，为空时使用全局前缀
+	IDPrefix string `json:"-"`
+	// Authorize 非空时在取消之前检查调用方是否可以提交该任务，返回错误则不做任何修改
+	Authorize func(info *asynq.TaskInfo) error `json:"-"`
+}
+
+func (c *RequeueTaskCommand) Validate() error {
+	if c.TaskID == "" {
+		return apperrors.ErrInvalidTaskID
+	}
+	return normalizeQueue(&c.Queue)
+}
+
+type FlushGroupCommand struct {
+	Queue string `json:"queue"`
+	Group string `json:"group"`
+	// DryRun 只返回将被聚合的任务，不修改分组
+	DryRun bool `json:"dry_run"`
+}
+
+func (c *FlushGroupCommand) Validate() error {
+	if err := normalizeQueue(&c.Queue); err != nil {
+		return err
+	}
+	if c.Group == "" {
+		return apperrors.ErrGroupNotFound
 	}
 	return nil
 }
@@ -0,0 +1,118 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
+)
+
+// 弃用指标中的处理结果
+const (
+	DeprecationAccepted = "accepted" // 原样接受
+	DeprecationMigrated = "migrated" // 改写为新格式后接受
+	DeprecationRejected = "rejected" // 已过截止时间，拒绝
+)
+
+// Deprecation 一项弃用：任务类型整体弃用，或设置 Migration 时只弃用其匹配的旧 payload 格式
+type Deprecation struct {
+	Name      string
+	Type      tasktype.Type
+	Migration *tasktype.Migration
+	// Rewrite 为 true 时入队前用 Migration 将 payload 改写为新格式
+	Rewrite bool
+	Message string
+	// Cutoff 到达该时间后拒绝请求，零值表示一直接受
+	Cutoff time.Time
+}
+
+// matches 判断创建请求是否命中该弃用项
+func (d *Deprecation) matches(cmd *CreateTaskCommand) bool {
+	if d.Type != cmd.Type {
+		return false
+	}
+	if d.Migration != nil && d.Migration.Matches != nil {
+		return d.Migration.Matches(cmd.Payload)
+	}
+	return true
+}
+
+// DeprecationNotice 创建结果中命中的弃用项
+type DeprecationNotice struct {
+	Name     string    `json:"name"`
+	Message  string    `json:"message"`
+	Cutoff   time.Time `json:"cutoff,omitempty"`
+	Migrated bool      `json:"migrated,omitempty"`
+}
+
+// Warning 返回供 Warning 头和日志使用的说明
+func (n DeprecationNotice) Warning() string {
+	msg := fmt.Sprintf("deprecated (%s)", n.Name)
+	if n.Message != "" {
+		msg += ": " + n.Message
+	}
+	if !n.Cutoff.IsZero() {
+		msg += "; requests will be rejected from " + n.Cutoff.UTC().Format(time.RFC3339)
+	}
+	return msg
+}
+
+// DeprecationRecorder 记录命中弃用项的请求
+type DeprecationRecorder interface {
+	ObserveDeprecated(name, outcome string)
+}
+
+// WithDeprecations 对命中弃用项的创建请求告警、按需改写 payload，过截止时间后拒绝。recorder 可以为 nil
+func WithDeprecations(deprecations []Deprecation, recorder DeprecationRecorder) Option {
+	return func(s *Service) {
+		s.deprecations = deprecations
+		s.deprecated = recorder
+	}
+}
+
+// applyDeprecations 检查创建请求命中的弃用项，需要时原地改写 cmd.Payload
+func (s *Service) applyDeprecations(cmd *CreateTaskCommand, now time.Time) ([]DeprecationNotice, error) {
+	var notices []DeprecationNotice
+	for i := range s.deprecations {
+		d := &s.deprecations[i]
+		if !d.matches(cmd) {
+			continue
+		}
+
+		if !d.Cutoff.IsZero() && !now.Before(d.Cutoff) {
+			s.observeDeprecated(d.Name, DeprecationRejected)
+			return nil, &apperrors.DeprecatedError{
+				Name:     d.Name,
+				TaskType: d.Type.String(),
+				Cutoff:   d.Cutoff,
+				Message:  d.Message,
+			}
+		}
+
+		notice := DeprecationNotice{Name: d.Name, Message: d.Message, Cutoff: d.Cutoff}
+		if d.Rewrite && d.Migration != nil && d.Migration.Migrate != nil {
+			migrated, err := d.Migration.Migrate(cmd.Payload)
+			if err != nil {
+				return nil, errors.Join(apperrors.ErrInvalidPayload, fmt.Errorf("migrate payload (%s): %w", d.Name, err))
+			}
+			cmd.Payload = migrated
+			notice.Migrated = true
+		}
+
+		outcome := DeprecationAccepted
+		if notice.Migrated {
+			outcome = DeprecationMigrated
+		}
+		s.observeDeprecated(d.Name, outcome)
+		notices = append(notices, notice)
+	}
+	return notices, nil
+}
+
+func (s *Service) observeDeprecated(name, outcome string) {
+	if s.deprecated != nil {
+		s.deprecated.ObserveDeprecated(name, outcome)
+	}
+}
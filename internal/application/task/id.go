@@ -0,0 +1,188 @@
+package task
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+)
+
+// 任务 ID 的生成方式
+const (
+	IDSchemeUUID4 = "uuid4"
+	IDSchemeULID  = "ulid"
+)
+
+// IDGenerator 生成任务 ID 并校验调用方指定的 ID，实现必须可并发使用
+type IDGenerator interface {
+	// NewID 生成带 prefix 的任务 ID
+	NewID(prefix string) string
+	// Validate 检查 id 是否为带 prefix 的合法 ID
+	Validate(id, prefix string) error
+}
+
+// NewIDGenerator 按 scheme 返回内置的生成器，scheme 为空时使用 uuid4
+func NewIDGenerator(scheme string) (IDGenerator, error) {
+	switch scheme {
+	case "", IDSchemeUUID4:
+		return UUIDGenerator{}, nil
+	case IDSchemeULID:
+		return NewULIDGenerator(nil), nil
+	default:
+		return nil, fmt.Errorf("unknown task id scheme %q", scheme)
+	}
+}
+
+// WithIDGenerator 替换默认的 uuid4 任务 ID，prefix 为未指定租户前缀时使用的前缀
+func WithIDGenerator(gen IDGenerator, prefix string) Option {
+	return func(s *Service) {
+		s.ids = gen
+		s.idPrefix = prefix
+	}
+}
+
+// taskID 返回 cmd 指定的 ID（校验通过时）或新生成的 ID
+func (s *Service) taskID(cmd *CreateTaskCommand) (string, error) {
+	prefix := s.idPrefix
+	if cmd.IDPrefix != "" {
+		prefix = cmd.IDPrefix
+	}
+	if cmd.TaskID == "" {
+		return s.ids.NewID(prefix), nil
+	}
+	if err := s.ids.Validate(cmd.TaskID, prefix); err != nil {
+		return "", fmt.Errorf("%w: %v", apperrors.ErrInvalidTaskID, err)
+	}
+	return cmd.TaskID, nil
+}
+
+func trimIDPrefix(id, prefix string) (string, error) {
+	rest, ok := strings.CutPrefix(id, prefix)
+	if !ok {
+		return "", fmt.Errorf("task id must start with %q", prefix)
+	}
+	return rest, nil
+}
+
+// UUIDGenerator 生成随机的 UUIDv4
+type UUIDGenerator struct{}
+
+func (UUIDGenerator) NewID(prefix string) string {
+	return prefix + uuid.NewString()
+}
+
+// Validate 要求前缀之后为标准格式（36 个字符）的 UUIDv4
+func (UUIDGenerator) Validate(id, prefix string) error {
+	rest, err := trimIDPrefix(id, prefix)
+	if err != nil {
+		return err
+	}
+	u, err := uuid.Parse(rest)
+	if err != nil || len(rest) != 36 || u.Version() != 4 {
+		return fmt.Errorf("task id must be a UUIDv4")
+	}
+	return nil
+}
+
+// crockford ULID 使用的 Crockford base32 字母表
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator 生成按时间排序的 ULID：48 位毫秒时间戳 + 80 位随机数，编码为 26 个字符。
+// 同一毫秒内（或时钟回拨时）沿用上一个时间戳并将随机部分加一，保证同一进程内严格递增
+type ULIDGenerator struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	lastMs  uint64
+	lastHi  uint16
+	lastLo  uint64
+	started bool
+}
+
+// NewULIDGenerator now 为 nil 时使用 time.Now
+func NewULIDGenerator(now func() time.Time) *ULIDGenerator {
+	if now == nil {
+		now = time.Now
+	}
+	return &ULIDGenerator{now: now}
+}
+
+func (g *ULIDGenerator) NewID(prefix string) string {
+	ms := uint64(g.now().UnixMilli())
+
+	g.mu.Lock()
+	if g.started && ms <= g.lastMs {
+		ms = g.lastMs
+		g.lastLo++
+		if g.lastLo == 0 {
+			g.lastHi++
+			if g.lastHi == 0 {
+				// 同一毫秒内的 2^80 个 ID 已用尽，借用下一毫秒
+				ms++
+			}
+		}
+	} else {
+		var b [10]byte
+		_, _ = rand.Read(b[:])
+		g.lastHi = binary.BigEndian.Uint16(b[:2])
+		g.lastLo = binary.BigEndian.Uint64(b[2:])
+	}
+	g.lastMs = ms
+	g.started = true
+	hi, lo := g.lastHi, g.lastLo
+	g.mu.Unlock()
+
+	return prefix + encodeULID(ms, hi, lo)
+}
+
+// Validate 要求前缀之后为 26 个字符的 ULID，字母须为大写
+func (g *ULIDGenerator) Validate(id, prefix string) error {
+	rest, err := trimIDPrefix(id, prefix)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 26 {
+		return fmt.Errorf("task id must be a 26-character ULID")
+	}
+	// 首字符最大为 7，否则时间戳超过 48 位
+	if rest[0] > '7' {
+		return fmt.Errorf("task id must be a 26-character ULID")
+	}
+	for i := 0; i < len(rest); i++ {
+		if strings.IndexByte(crockford, rest[i]) < 0 {
+			return fmt.Errorf("task id must be a 26-character ULID")
+		}
+	}
+	return nil
+}
+
+// encodeULID 将 128 位值（ms 的低 48 位 + hi + lo）按 5 位一组编码，首字符只使用 3 位
+func encodeULID(ms uint64, hi uint16, lo uint64) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[0:8], ms<<16|uint64(hi))
+	binary.BigEndian.PutUint64(b[8:16], lo)
+
+	var out [26]byte
+	// 从最低位开始每次取 5 位
+	var acc uint32
+	var bits uint
+	pos := 25
+	for i := 15; i >= 0; i-- {
+		acc |= uint32(b[i]) << bits
+		bits += 8
+		for bits >= 5 {
+			out[pos] = crockford[acc&31]
+			pos--
+			acc >>= 5
+			bits -= 5
+		}
+	}
+	out[0] = crockford[acc&31]
+	return string(out[:])
+}
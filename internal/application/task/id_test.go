@@ -0,0 +1,165 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
+)
+
+func TestULIDGeneratorIsMonotonic(t *testing.T) {
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	// 时钟停在同一毫秒、前进后又回拨，ID 都应严格递增
+	times := []time.Time{base, base, base, base.Add(time.Millisecond), base.Add(-time.Second), base.Add(2 * time.Millisecond)}
+	i := 0
+	gen := NewULIDGenerator(func() time.Time {
+		now := times[i%len(times)]
+		i++
+		return now
+	})
+
+	prev := ""
+	for range times {
+		id := gen.NewID("")
+		if err := gen.Validate(id, ""); err != nil {
+			t.Fatalf("generated invalid ULID %q: %v", id, err)
+		}
+		if id <= prev {
+			t.Fatalf("ULID %q is not greater than previous %q", id, prev)
+		}
+		prev = id
+	}
+}
+
+func TestULIDGeneratorEncodesTimestamp(t *testing.T) {
+	gen := NewULIDGenerator(func() time.Time { return time.UnixMilli(0) })
+	if id := gen.NewID(""); !strings.HasPrefix(id, "0000000000") {
+		t.Fatalf("expected zero timestamp prefix, got %q", id)
+	}
+
+	// 48 位时间戳的最大值编码为 7ZZZZZZZZZ
+	gen = NewULIDGenerator(func() time.Time { return time.UnixMilli(1<<48 - 1) })
+	if id := gen.NewID(""); !strings.HasPrefix(id, "7ZZZZZZZZZ") {
+		t.Fatalf("expected max timestamp prefix, got %q", id)
+	}
+}
+
+func TestULIDGeneratorConcurrentUnique(t *testing.T) {
+	gen := NewULIDGenerator(nil)
+	const workers, perWorker = 8, 2000
+
+	results := make([][]string, workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids := make([]string, perWorker)
+			for i := range ids {
+				ids[i] = gen.NewID("tf_")
+			}
+			results[w] = ids
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool, workers*perWorker)
+	for _, ids := range results {
+		for i, id := range ids {
+			if seen[id] {
+				t.Fatalf("duplicate ULID %q", id)
+			}
+			seen[id] = true
+			// 同一 goroutine 内生成的 ID 保持递增
+			if i > 0 && id <= ids[i-1] {
+				t.Fatalf("ULID %q is not greater than %q", id, ids[i-1])
+			}
+		}
+	}
+}
+
+func TestIDGeneratorValidate(t *testing.T) {
+	ulid := NewULIDGenerator(nil)
+	uuid4 := UUIDGenerator{}
+
+	tests := []struct {
+		name   string
+		gen    IDGenerator
+		id     string
+		prefix string
+		ok     bool
+	}{
+		{"ulid", ulid, "01JGQ3Z5M8W7V2K4N6P8R0S2T4", "", true},
+		{"ulid with prefix", ulid, "acme_01JGQ3Z5M8W7V2K4N6P8R0S2T4", "acme_", true},
+		{"ulid missing prefix", ulid, "01JGQ3Z5M8W7V2K4N6P8R0S2T4", "acme_", false},
+		{"ulid lowercase", ulid, "01jgq3z5m8w7v2k4n6p8r0s2t4", "", false},
+		{"ulid excluded letter", ulid, "01JGQ3Z5M8W7V2K4N6P8R0S2TU", "", false},
+		{"ulid timestamp overflow", ulid, "81JGQ3Z5M8W7V2K4N6P8R0S2T4", "", false},
+		{"ulid too short", ulid, "01JGQ3Z5M8", "", false},
+		{"uuid4", uuid4, "6f1c2a7e-3b7d-4c1e-9a55-0d2f4b8e9c10", "", true},
+		{"uuid4 with prefix", uuid4, "tf-6f1c2a7e-3b7d-4c1e-9a55-0d2f4b8e9c10", "tf-", true},
+		{"uuid v1", uuid4, "6f1c2a7e-3b7d-1c1e-9a55-0d2f4b8e9c10", "", false},
+		{"uuid without dashes", uuid4, "6f1c2a7e3b7d4c1e9a550d2f4b8e9c10", "", false},
+		{"not a uuid", uuid4, "report-42", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.gen.Validate(tt.id, tt.prefix)
+			if (err == nil) != tt.ok {
+				t.Fatalf("Validate(%q, %q) = %v, want ok=%v", tt.id, tt.prefix, err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestServiceCreateTaskUsesIDGenerator(t *testing.T) {
+	fake := &fakeClient{enqueueInfo: &asynq.TaskInfo{ID: "x", Queue: "default", State: asynq.TaskStatePending}}
+	service := NewService(fake, zap.NewNop(), WithIDGenerator(NewULIDGenerator(nil), "tf_"))
+
+	create := func(taskID, prefix string) error {
+		_, err := service.CreateTask(context.Background(), &CreateTaskCommand{
+			Type:     tasktype.Demo,
+			Payload:  []byte(`{"message":"hi","count":1}`),
+			TaskID:   taskID,
+			IDPrefix: prefix,
+		})
+		return err
+	}
+
+	if err := create("", ""); err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if id := fake.enqueueOpts.TaskID; !strings.HasPrefix(id, "tf_") || len(id) != len("tf_")+26 {
+		t.Fatalf("expected prefixed ULID, got %q", id)
+	}
+
+	if err := create("", "acme_"); err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if id := fake.enqueueOpts.TaskID; !strings.HasPrefix(id, "acme_") {
+		t.Fatalf("expected tenant prefix to replace the default, got %q", id)
+	}
+
+	if err := create("tf_01JGQ3Z5M8W7V2K4N6P8R0S2T4", ""); err != nil {
+		t.Fatalf("CreateTask with valid id: %v", err)
+	}
+	if id := fake.enqueueOpts.TaskID; id != "tf_01JGQ3Z5M8W7V2K4N6P8R0S2T4" {
+		t.Fatalf("expected client-supplied id, got %q", id)
+	}
+
+	enqueued := fake.enqueued
+	if err := create("6f1c2a7e-3b7d-4c1e-9a55-0d2f4b8e9c10", ""); !errors.Is(err, apperrors.ErrInvalidTaskID) {
+		t.Fatalf("expected ErrInvalidTaskID, got %v", err)
+	}
+	if fake.enqueued != enqueued {
+		t.Fatal("task with invalid id should not be enqueued")
+	}
+}
@@ -0,0 +1,79 @@
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/hibiken/asynq"
+)
+
+// 已结束任务按执行结果过滤的取值
+const (
+	ResultStatusSuccess = "success"
+	ResultStatusFailure = "failure"
+)
+
+// resultStatus 已结束任务的执行结果。completed 只记录成功的执行；archived 中带错误的是重试耗尽或不再重试的失败，
+// 没有错误的是开始前被取消的任务，两者都不算。等待重试时被取消的任务保留上一次的错误，记为失败
+func resultStatus(info *asynq.TaskInfo) string {
+	switch info.State {
+	case asynq.TaskStateCompleted:
+		return ResultStatusSuccess
+	case asynq.TaskStateArchived:
+		if info.LastErr != "" {
+			return ResultStatusFailure
+		}
+	}
+	return ""
+}
+
+// matchPayload 判断 payload 是否满足所有字段条件。字符串字段比较解码后的值，
+// 其他类型比较 JSON 原文（如 42、true）；payload 不是 JSON 对象或缺少字段时不匹配
+func matchPayload(payload []byte, match map[string]string) bool {
+	if len(match) == 0 {
+		return true
+	}
+
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &root); err != nil {
+		return false
+	}
+	for path, want := range match {
+		got, ok := payloadField(root, path)
+		if !ok || got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// payloadField 按 . 分隔的路径读取字段，返回其字符串形式
+func payloadField(root map[string]json.RawMessage, path string) (string, bool) {
+	keys := strings.Split(path, ".")
+	fields := root
+	for i, key := range keys {
+		raw, ok := fields[key]
+		if !ok {
+			return "", false
+		}
+		if i < len(keys)-1 {
+			fields = nil
+			if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+				return "", false
+			}
+			continue
+		}
+
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return s, true
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, raw); err != nil {
+			return "", false
+		}
+		return compact.String(), true
+	}
+	return "", false
+}
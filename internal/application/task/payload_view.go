@@ -0,0 +1,182 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+)
+
+// 查询任务时附带 payload 的方式
+const (
+	PayloadNone    = ""
+	PayloadPreview = "preview"
+	PayloadFull    = "full"
+)
+
+// AuditReadPayload 读取完整 payload 的审计操作名
+const AuditReadPayload = "task.payload.read"
+
+// RedactedValue 替换敏感字段值的占位符
+const RedactedValue = "[REDACTED]"
+
+// DefaultPreviewBytes payload 预览默认的最大字节数
+const DefaultPreviewBytes = 256
+
+// DefaultRedactFields 未配置时视为敏感信息的字段名
+var DefaultRedactFields = []string{"password", "secret", "token", "api_key", "apikey", "authorization", "credentials", "private_key"}
+
+// Redactor 按字段名脱敏 JSON，字段名不区分大小写，任意嵌套层级的同名字段都会被替换
+type Redactor struct {
+	fields map[string]bool
+}
+
+// NewRedactor 创建脱敏器，fields 为空时使用 DefaultRedactFields
+func NewRedactor(fields []string) *Redactor {
+	if len(fields) == 0 {
+		fields = DefaultRedactFields
+	}
+	r := &Redactor{fields: make(map[string]bool, len(fields))}
+	for _, f := range fields {
+		r.fields[strings.ToLower(f)] = true
+	}
+	return r
+}
+
+// JSON 返回脱敏后的 JSON 及是否替换了字段；data 不是合法 JSON 时返回 false 的 ok
+func (r *Redactor) JSON(data []byte) (redacted []byte, changed, ok bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var root any
+	if err := dec.Decode(&root); err != nil || dec.More() {
+		return nil, false, false
+	}
+
+	changed = r.walk(root)
+	if !changed {
+		return data, false, true
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(root); err != nil {
+		return nil, false, false
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), true, true
+}
+
+func (r *Redactor) walk(v any) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if r.fields[strings.ToLower(k)] {
+				v[k] = RedactedValue
+				changed = true
+				continue
+			}
+			if r.walk(child) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if r.walk(child) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// PayloadView 查询结果附带的 payload
+type PayloadView struct {
+	// Size payload 原始字节数
+	Size int `json:"size"`
+	// Preview 脱敏后的前若干字节，截断处可能不是完整的 JSON
+	Preview string `json:"preview,omitempty"`
+	// Truncated 预览是否被截断
+	Truncated bool `json:"truncated,omitempty"`
+	// Redacted 是否有字段被脱敏；payload 不是 JSON 时无法脱敏，不返回预览并记为 true
+	Redacted bool `json:"redacted,omitempty"`
+	// Full 完整的原始 payload，仅 include_payload=full；不是 JSON 时为字符串
+	Full json.RawMessage `json:"full,omitempty"`
+}
+
+// WithPayloadPreview 设置 payload 预览的脱敏规则和最大字节数，maxBytes <= 0 时使用 DefaultPreviewBytes
+func WithPayloadPreview(redactor *Redactor, maxBytes int) Option {
+	return func(s *Service) {
+		s.redactor = redactor
+		s.previewBytes = maxBytes
+	}
+}
+
+func validPayloadMode(mode string) error {
+	switch mode {
+	case PayloadNone, PayloadPreview, PayloadFull:
+		return nil
+	}
+	return apperrors.NewValidationError("include_payload", "must be preview or full")
+}
+
+// payloadView 按 mode 构造 payload 视图，mode 为空时返回 nil
+func (s *Service) payloadView(mode string, payload []byte) *PayloadView {
+	switch mode {
+	case PayloadPreview:
+		return s.previewPayload(payload)
+	case PayloadFull:
+		view := &PayloadView{Size: len(payload), Full: json.RawMessage(payload)}
+		if !json.Valid(payload) {
+			view.Full, _ = json.Marshal(string(payload))
+		}
+		return view
+	}
+	return nil
+}
+
+func (s *Service) previewPayload(payload []byte) *PayloadView {
+	view := &PayloadView{Size: len(payload)}
+	if len(payload) == 0 {
+		return view
+	}
+
+	redactor := s.redactor
+	if redactor == nil {
+		redactor = NewRedactor(nil)
+	}
+	redacted, changed, ok := redactor.JSON(payload)
+	if !ok {
+		view.Redacted = true
+		return view
+	}
+	view.Redacted = changed
+
+	limit := s.previewBytes
+	if limit <= 0 {
+		limit = DefaultPreviewBytes
+	}
+	if len(redacted) > limit {
+		// 不在多字节字符中间截断
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(redacted[cut]) {
+			cut--
+		}
+		redacted = redacted[:cut]
+		view.Truncated = true
+	}
+	view.Preview = string(redacted)
+	return view
+}
+
+// auditPayloadRead 记录一次完整 payload 的读取
+func (s *Service) auditPayloadRead(ctx context.Context, queue string, taskIDs []string) {
+	s.audit(ctx, AuditReadPayload, nil,
+		zap.String("queue", queue),
+		zap.Strings("task_ids", taskIDs),
+	)
+}
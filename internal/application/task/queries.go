@@ -1,24 +1,86 @@
 package task
 
-import apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+import (
+	"fmt"
+	"strings"
+
+	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+)
 
 type GetTaskQuery struct {
 	TaskID string `json:"task_id"`
 	Queue  string `json:"queue"`
+	// Payload 附带 payload 的方式：空、PayloadPreview 或 PayloadFull
+	Payload string `json:"payload,omitempty"`
 }
 
 func (q *GetTaskQuery) Validate() error {
 	if q.TaskID == "" {
 		return apperrors.ErrInvalidTaskID
 	}
-	if q.Queue == "" {
-		return apperrors.ErrInvalidQueue
+	if err := normalizeQueue(&q.Queue); err != nil {
+		return err
 	}
-	return nil
+	return validPayloadMode(q.Payload)
 }
 
+// maxStatsQueues 一次查询统计的队列数上限
+const maxStatsQueues = 50
+
 type GetQueueStatsQuery struct {
 	Queue string `json:"queue,omitempty"`
+	// Queues 只返回这些队列的统计，按给定顺序，与 Queue 互斥
+	Queues []string `json:"queues,omitempty"`
+	// SkipUnknown 为 true 时跳过不存在的队列，否则返回 ErrQueueNotFound
+	SkipUnknown bool `json:"skip_unknown,omitempty"`
+}
+
+func (q *GetQueueStatsQuery) Validate() error {
+	if len(q.Queues) == 0 {
+		if q.Queue == "" {
+			return nil
+		}
+		return normalizeQueue(&q.Queue)
+	}
+	if q.Queue != "" {
+		return apperrors.NewValidationError("queues", "queue and queues cannot be used together")
+	}
+	if len(q.Queues) > maxStatsQueues {
+		return apperrors.NewValidationError("queues", fmt.Sprintf("at most %d queues can be requested at once", maxStatsQueues))
+	}
+
+	seen := make(map[string]bool, len(q.Queues))
+	queues := q.Queues[:0]
+	for _, name := range q.Queues {
+		if strings.TrimSpace(name) == "" {
+			return apperrors.NewValidationError("queues", "queue names cannot be empty")
+		}
+		if err := normalizeQueue(&name); err != nil {
+			return err
+		}
+		if !seen[name] {
+			seen[name] = true
+			queues = append(queues, name)
+		}
+	}
+	q.Queues = queues
+	return nil
+}
+
+type ListGroupsQuery struct {
+	Queue string `json:"queue"`
+}
+
+func (q *ListGroupsQuery) Validate() error {
+	return normalizeQueue(&q.Queue)
+}
+
+type OldestTasksQuery struct {
+	Queue string `json:"queue"`
+}
+
+func (q *OldestTasksQuery) Validate() error {
+	return normalizeQueue(&q.Queue)
 }
 
 type ListTasksQuery struct {
@@ -26,11 +88,19 @@ type ListTasksQuery struct {
 	Status string `json:"status"`
 	Page   int    `json:"page"`
 	Size   int    `json:"size"`
+	// Match 只返回 payload 字段等于给定值的任务，键为字段路径（嵌套字段用 . 连接）。
+	// 过滤在取出一页之后进行，因此结果可能少于 Size
+	Match map[string]string `json:"match,omitempty"`
+	// Payload 附带 payload 的方式：空、PayloadPreview 或 PayloadFull
+	Payload string `json:"payload,omitempty"`
+	// ResultStatus 只返回执行成功（ResultStatusSuccess）或失败（ResultStatusFailure）的任务，
+	// 仅适用于 completed 和 archived，与 Match 一样在取出一页之后过滤
+	ResultStatus string `json:"result_status,omitempty"`
 }
 
 func (q *ListTasksQuery) Validate() error {
-	if q.Queue == "" {
-		return apperrors.ErrInvalidQueue
+	if err := normalizeQueue(&q.Queue); err != nil {
+		return err
 	}
 	if q.Status == "" {
 		q.Status = "active"
@@ -46,5 +116,19 @@ func (q *ListTasksQuery) Validate() error {
 	if q.Size <= 0 {
 		q.Size = 20
 	}
-	return nil
+	for key := range q.Match {
+		if key == "" || strings.HasPrefix(key, ".") || strings.HasSuffix(key, ".") || strings.Contains(key, "..") {
+			return apperrors.NewValidationError("match", fmt.Sprintf("invalid field path %q", key))
+		}
+	}
+	switch q.ResultStatus {
+	case "":
+	case ResultStatusSuccess, ResultStatusFailure:
+		if q.Status != "completed" && q.Status != "archived" {
+			return apperrors.NewValidationError("result_status", "only applies to completed and archived tasks")
+		}
+	default:
+		return apperrors.NewValidationError("result_status", fmt.Sprintf("must be %s or %s", ResultStatusSuccess, ResultStatusFailure))
+	}
+	return validPayloadMode(q.Payload)
 }
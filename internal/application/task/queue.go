@@ -0,0 +1,96 @@
+package task
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+)
+
+// queueNamePattern 规范化后的队列名：小写字母、数字和 . _ -，标签队列形如 default.gpu
+var queueNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// NormalizeQueue 去除首尾空白并转为小写，结果不符合队列名格式时返回 QueueError。
+// asynq 会为任意名称创建队列，"Default " 之类的名称会进入没有 worker 消费的新队列
+func NormalizeQueue(name string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	if !queueNamePattern.MatchString(normalized) {
+		return "", apperrors.NewQueueError(name,
+			"queue names may only contain lowercase letters, digits, '.', '_' and '-', and must start with a letter or digit", nil)
+	}
+	return normalized, nil
+}
+
+// normalizeQueue 规范化命令和查询中必填的队列，为空时返回 ErrInvalidQueue
+func normalizeQueue(queue *string) error {
+	if *queue == "" {
+		return apperrors.ErrInvalidQueue
+	}
+	normalized, err := NormalizeQueue(*queue)
+	if err != nil {
+		return err
+	}
+	*queue = normalized
+	return nil
+}
+
+// QueueSet 可以入队和查询的队列，即 worker 可能消费的队列
+type QueueSet struct {
+	names []string
+}
+
+// NewQueueSet 创建队列集合，名称按 NormalizeQueue 规范化
+func NewQueueSet(names []string) *QueueSet {
+	set := &QueueSet{}
+	for _, name := range names {
+		if normalized, err := NormalizeQueue(name); err == nil && !slices.Contains(set.names, normalized) {
+			set.names = append(set.names, normalized)
+		}
+	}
+	slices.Sort(set.names)
+	return set
+}
+
+// Names 返回排序后的队列名
+func (q *QueueSet) Names() []string {
+	return slices.Clone(q.names)
+}
+
+// Check 检查规范化后的队列名是否在集合中
+func (q *QueueSet) Check(queue string) error {
+	if _, found := slices.BinarySearch(q.names, queue); found {
+		return nil
+	}
+	return apperrors.NewQueueError(queue, "no worker consumes this queue", q.Names())
+}
+
+// WithQueues 只允许使用 names 中的队列，未设置时只检查队列名格式
+func WithQueues(names []string) Option {
+	return func(s *Service) {
+		s.queues.Store(NewQueueSet(names))
+	}
+}
+
+// SetQueues 替换允许使用的队列，用于配置热更新
+func (s *Service) SetQueues(names []string) {
+	s.queues.Store(NewQueueSet(names))
+}
+
+// KnownQueues 返回允许使用的队列，未设置队列集合时返回 nil
+func (s *Service) KnownQueues() []string {
+	set := s.queues.Load()
+	if set == nil {
+		return nil
+	}
+	return set.Names()
+}
+
+// checkQueue 检查队列是否在允许使用的队列中，queue 应已规范化
+func (s *Service) checkQueue(queue string) error {
+	set := s.queues.Load()
+	if set == nil {
+		return nil
+	}
+	return set.Check(queue)
+}
@@ -0,0 +1,246 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
+)
+
+const (
+	// DefaultRequeueWait 重新入队时等待被取消的任务停止的默认时长
+	DefaultRequeueWait = 10 * time.Second
+	// requeuePollInterval 等待任务停止时查询状态的间隔
+	requeuePollInterval = 100 * time.Millisecond
+)
+
+// WithRequeueWait 设置重新入队时等待被取消的任务停止的最长时间，不大于 0 时使用默认值
+func WithRequeueWait(d time.Duration) Option {
+	return func(s *Service) {
+		s.requeueWait = d
+	}
+}
+
+// RequeueTaskResult 重新入队的结果
+type RequeueTaskResult struct {
+	TaskID    string `json:"task_id"`
+	Queue     string `json:"queue"`
+	NewTaskID string `json:"new_task_id"`
+	// StoppedState 原任务停止后的状态（retry 或 archived），原任务最终都归档，不会再次执行
+	StoppedState string `json:"stopped_state"`
+}
+
+// RequeueTask 取消正在执行的任务，等待它停止后以新 ID 入队一个 payload 和选项相同的副本。
+// 副本在取消之前按新建任务的规则检查（队列、限制、payload），超出 clamp 模式上限的选项按上限截断；
+// 入队失败时恢复已归档的原任务。任务在停止前已成功完成时不入队副本，返回 ErrTaskCompleted
+func (s *Service) RequeueTask(ctx context.Context, cmd *RequeueTaskCommand) (*RequeueTaskResult, error) {
+	if err := cmd.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQueue(cmd.Queue); err != nil {
+		return nil, err
+	}
+
+	result, err := s.requeueTask(ctx, cmd)
+	fields := []zap.Field{zap.String("task_id", cmd.TaskID), zap.String("queue", cmd.Queue)}
+	if result != nil {
+		fields = append(fields,
+			zap.String("new_task_id", result.NewTaskID),
+			zap.String("stopped_state", result.StoppedState),
+		)
+	}
+	s.audit(ctx, AuditRequeueTask, err, fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("task requeued",
+		zap.String("task_id", result.TaskID),
+		zap.String("new_task_id", result.NewTaskID),
+		zap.String("queue", result.Queue),
+		zap.String("stopped_state", result.StoppedState),
+	)
+	return result, nil
+}
+
+func (s *Service) requeueTask(ctx context.Context, cmd *RequeueTaskCommand) (*RequeueTaskResult, error) {
+	info, err := s.client.GetTaskInfo(cmd.Queue, cmd.TaskID)
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+			return nil, errors.Join(apperrors.ErrTaskNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to get task info: %w", err)
+	}
+	switch info.State {
+	case asynq.TaskStateActive:
+	case asynq.TaskStateCompleted:
+		return nil, apperrors.ErrTaskCompleted
+	default:
+		return nil, fmt.Errorf("%w: task is %s", apperrors.ErrTaskNotActive, info.State)
+	}
+	// 副本与新建任务一样受调用方权限限制，在取消之前检查
+	if cmd.Authorize != nil {
+		if err := cmd.Authorize(info); err != nil {
+			return nil, err
+		}
+	}
+	copyInfo, err := s.prepareCopy(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.client.CancelTask(info.ID); err != nil {
+		s.logger.Error("failed to cancel task",
+			zap.String("task_id", info.ID),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to cancel task: %w", err)
+	}
+	if s.tokens != nil {
+		if err := s.tokens.Revoke(ctx, info.ID); err != nil {
+			s.logger.Warn("failed to revoke progress token",
+				zap.String("task_id", info.ID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	stopped, err := s.waitStopped(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+	archived := false
+	switch stopped.State {
+	case asynq.TaskStateCompleted:
+		return nil, fmt.Errorf("%w: task finished before it was cancelled, not requeued", apperrors.ErrTaskCompleted)
+	case asynq.TaskStateArchived:
+	default:
+		// 取消后任务按失败处理进入 retry，归档以免原任务和副本都再次执行
+		if err := s.client.ArchiveTask(info.Queue, info.ID); err != nil {
+			s.logger.Error("failed to archive cancelled task",
+				zap.String("task_id", info.ID),
+				zap.String("state", stopped.State.String()),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("failed to archive cancelled task: %w", err)
+		}
+		archived = true
+	}
+
+	prefix := s.idPrefix
+	if cmd.IDPrefix != "" {
+		prefix = cmd.IDPrefix
+	}
+	copied, err := s.client.EnqueueCopy(ctx, copyInfo, s.ids.NewID(prefix))
+	if err != nil {
+		s.logger.Error("failed to enqueue copy of cancelled task",
+			zap.String("task_id", info.ID),
+			zap.Error(err),
+		)
+		// 副本没有入队时恢复刚归档的原任务，任务不会因此丢失
+		if archived {
+			if runErr := s.client.RunTask(info.Queue, info.ID); runErr != nil {
+				s.logger.Error("failed to restore cancelled task after enqueueing its copy failed",
+					zap.String("task_id", info.ID),
+					zap.Error(runErr),
+				)
+				return nil, fmt.Errorf("failed to enqueue copy, and the cancelled task stays archived: %w", errors.Join(err, runErr))
+			}
+		}
+		return nil, fmt.Errorf("failed to enqueue copy: %w", err)
+	}
+
+	if s.tracker != nil {
+		if err := s.tracker.MarkCreated(ctx, copied.ID, copied.Queue); err != nil {
+			s.logger.Warn("failed to mark task created",
+				zap.String("task_id", copied.ID),
+				zap.Error(err),
+			)
+		}
+	}
+	if s.enqueues != nil {
+		s.enqueues.ObserveEnqueue(copied.Type, copied.Queue, len(copied.Payload))
+	}
+	s.publishCreated(ctx, copied)
+
+	return &RequeueTaskResult{
+		TaskID:       info.ID,
+		Queue:        info.Queue,
+		NewTaskID:    copied.ID,
+		StoppedState: stopped.State.String(),
+	}, nil
+}
+
+// prepareCopy 按新建任务的规则检查副本：队列是否可用、当前的限制和 payload 校验，
+// 返回按限制截断重试次数和超时后的任务信息。在取消原任务之前调用，副本不能入队时原任务不受影响
+func (s *Service) prepareCopy(ctx context.Context, info *asynq.TaskInfo) (*asynq.TaskInfo, error) {
+	if err := s.checkQueue(info.Queue); err != nil {
+		return nil, err
+	}
+	cmd := &CreateTaskCommand{
+		Type:       tasktype.Type(info.Type),
+		Payload:    info.Payload,
+		MaxRetries: info.MaxRetry,
+		Timeout:    info.Timeout,
+	}
+	limits := s.limits.Load()
+	if limits != nil {
+		// 副本沿用原任务的取值，只检查上限，不套用默认值
+		capped := *limits
+		capped.DefaultMaxRetries, capped.DefaultTimeout = 0, 0
+		if _, err := cmd.ApplyLimits(&capped, s.clock.Now()); err != nil {
+			return nil, err
+		}
+	}
+	if err := cmd.ValidatePayload(limits); err != nil {
+		return nil, err
+	}
+	if s.validator != nil {
+		if err := s.validator.ValidatePayload(ctx, info.Type, info.Payload); err != nil {
+			return nil, err
+		}
+	}
+
+	copyInfo := *info
+	copyInfo.MaxRetry = cmd.MaxRetries
+	copyInfo.Timeout = cmd.Timeout
+	return &copyInfo, nil
+}
+
+// waitStopped 轮询任务状态直到它不再是 active。没有保留时间的任务成功后会被删除，
+// 查询不到时按已完成处理
+func (s *Service) waitStopped(ctx context.Context, info *asynq.TaskInfo) (*asynq.TaskInfo, error) {
+	wait := s.requeueWait
+	if wait <= 0 {
+		wait = DefaultRequeueWait
+	}
+	deadline := s.clock.Now().Add(wait)
+
+	for {
+		current, err := s.client.GetTaskInfo(info.Queue, info.ID)
+		if errors.Is(err, asynq.ErrTaskNotFound) {
+			return nil, fmt.Errorf("%w: task finished before it was cancelled, not requeued", apperrors.ErrTaskCompleted)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get task info: %w", err)
+		}
+		if current.State != asynq.TaskStateActive {
+			return current, nil
+		}
+		if !s.clock.Now().Before(deadline) {
+			return nil, fmt.Errorf("%w: task did not stop within %s after cancellation was requested", apperrors.ErrTaskActive, wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-s.clock.After(requeuePollInterval):
+		}
+	}
+}
@@ -1,22 +1,75 @@
 package task
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"go.uber.org/zap"
 
 	"github.com/Aixtrade/TaskFlow/internal/domain/task"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/blobstore"
 	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
 	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+	"github.com/Aixtrade/TaskFlow/pkg/payload"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
 )
 
 type Service struct {
 	client TaskClient
 	logger *zap.Logger
+
+	capabilities CapabilityChecker
+	typeCheck    string
+
+	blobs      blobstore.Store
+	presignTTL time.Duration
+
+	validator PayloadValidator
+
+	router QueueRouter
+
+	completions CompletionPublisher
+	creations   ProgressPublisher
+	progress    ProgressDeleter
+	latest      ProgressReader
+
+	tracker       CreationTracker
+	retryAttempts int
+	retryInterval time.Duration
+
+	limits atomic.Pointer[Limits]
+	queues atomic.Pointer[QueueSet]
+
+	enqueues EnqueueRecorder
+
+	deprecations []Deprecation
+	deprecated   DeprecationRecorder
+
+	auditLogger *zap.Logger
+
+	redactor     *Redactor
+	previewBytes int
+
+	ids      IDGenerator
+	idPrefix string
+
+	tokens TokenRevoker
+
+	requeueWait time.Duration
+
+	clock clock.Clock
 }
 
 type TaskClient interface {
@@ -25,38 +78,312 @@ type TaskClient interface {
 	ListTasks(queue, state string, page, size int) ([]*asynq.TaskInfo, error)
 	CancelTask(taskID string) error
 	DeleteTask(queue, taskID string) error
+	ArchiveTask(queue, taskID string) error
+	RunTask(queue, taskID string) error
+	EnqueueCopy(ctx context.Context, info *asynq.TaskInfo, taskID string) (*asynq.TaskInfo, error)
 	GetQueueInfo(queue string) (*asynq.QueueInfo, error)
+	GetQueues() ([]string, error)
 	GetAllQueueStats() ([]asynqqueue.QueueStats, error)
+	ListGroups(queue string) ([]asynqqueue.GroupStats, error)
+	FlushGroup(queue, group string, dryRun bool) ([]string, error)
+	OldestTasks(queue string) (*asynqqueue.OldestTasks, error)
+	UniqueTTL(info *asynq.TaskInfo) (time.Duration, error)
+}
+
+// CapabilityChecker 判断是否有存活 worker 声明了指定任务类型
+type CapabilityChecker interface {
+	SupportsType(ctx context.Context, taskType string) (bool, error)
+}
+
+const (
+	TypeCheckOff    = "off"
+	TypeCheckWarn   = "warn"
+	TypeCheckReject = "reject"
+)
+
+type Option func(*Service)
+
+// WithCapabilityCheck 在创建任务时检查 worker 能力，按 mode 告警或拒绝
+func WithCapabilityCheck(checker CapabilityChecker, mode string) Option {
+	return func(s *Service) {
+		s.capabilities = checker
+		s.typeCheck = mode
+	}
+}
+
+// CreationTracker 记录刚创建的任务，弥补 inspector 的可见性延迟
+type CreationTracker interface {
+	MarkCreated(ctx context.Context, taskID, queue string) error
+	RecentlyCreated(ctx context.Context, taskID string) (queue string, ok bool, err error)
+}
+
+// WithReadYourWrites 对刚创建的任务在 GetTask 中做有限次重试
+func WithReadYourWrites(tracker CreationTracker, attempts int, interval time.Duration) Option {
+	return func(s *Service) {
+		s.tracker = tracker
+		s.retryAttempts = attempts
+		s.retryInterval = interval
+	}
+}
+
+// QueueRouter 根据任务要求的执行环境标签选择队列
+type QueueRouter interface {
+	Route(queue string, requires []string) (string, error)
+}
+
+// WithLabelRouting 启用基于标签的队列路由
+func WithLabelRouting(router QueueRouter) Option {
+	return func(s *Service) {
+		s.router = router
+	}
+}
+
+// CompletionPublisher 发布任务终态事件
+type CompletionPublisher interface {
+	PublishCompletion(ctx context.Context, taskID, status, message string) error
+}
+
+// WithCompletionPublisher 在 API 侧直接结束任务（如取消未开始的任务）时发布终态事件
+func WithCompletionPublisher(p CompletionPublisher) Option {
+	return func(s *Service) {
+		s.completions = p
+	}
+}
+
+// ProgressDeleter 删除任务的进度流
+type ProgressDeleter interface {
+	Delete(ctx context.Context, taskID string) error
+}
+
+// WithProgressCleanup 删除任务时一并删除其进度流
+func WithProgressCleanup(d ProgressDeleter) Option {
+	return func(s *Service) {
+		s.progress = d
+	}
+}
+
+// ProgressReader 读取任务最新的进度事件
+type ProgressReader interface {
+	LatestWorker(ctx context.Context, taskID string) (*progress.Worker, error)
+}
+
+// WithWorkerLookup 查询任务时从最近一条 worker 发布的进度事件读取处理它的 worker 实例
+func WithWorkerLookup(r ProgressReader) Option {
+	return func(s *Service) {
+		s.latest = r
+	}
 }
 
-func NewService(client TaskClient, logger *zap.Logger) *Service {
-	return &Service{
-		client: client,
-		logger: logger,
+// ProgressPublisher 发布任务进度
+type ProgressPublisher interface {
+	Publish(ctx context.Context, prog *progress.Progress) error
+}
+
+// WithCreationEvents 创建任务后立即发布一条 0% 的进度，让刚订阅的客户端确认任务已入队
+func WithCreationEvents(p ProgressPublisher) Option {
+	return func(s *Service) {
+		s.creations = p
+	}
+}
+
+// PayloadValidator 在入队前校验任务 payload
+type PayloadValidator interface {
+	ValidatePayload(ctx context.Context, taskType string, payload []byte) error
+}
+
+// WithPayloadValidator 启用入队前的 payload 校验
+func WithPayloadValidator(v PayloadValidator) Option {
+	return func(s *Service) {
+		s.validator = v
+	}
+}
+
+// WithLimits 为创建任务的参数设置默认值与上限
+func WithLimits(limits Limits) Option {
+	return func(s *Service) {
+		s.limits.Store(&limits)
+	}
+}
+
+// SetLimits 替换创建任务的参数限制，用于配置热更新
+func (s *Service) SetLimits(limits Limits) {
+	s.limits.Store(&limits)
+}
+
+// WithBlobStore 启用基于对象存储的文件任务
+func WithBlobStore(store blobstore.Store) Option {
+	return func(s *Service) {
+		s.blobs = store
 	}
 }
 
+// EnqueueRecorder 记录成功入队的任务及其 payload 大小
+type EnqueueRecorder interface {
+	ObserveEnqueue(taskType, queue string, payloadBytes int)
+}
+
+// WithEnqueueMetrics 记录入队任务数和 payload 大小
+func WithEnqueueMetrics(r EnqueueRecorder) Option {
+	return func(s *Service) {
+		s.enqueues = r
+	}
+}
+
+// TokenRevoker 吊销任务的进度上报令牌
+type TokenRevoker interface {
+	Revoke(ctx context.Context, taskID string) error
+}
+
+// WithProgressTokenRevocation 取消任务时吊销其进度上报令牌
+func WithProgressTokenRevocation(r TokenRevoker) Option {
+	return func(s *Service) {
+		s.tokens = r
+	}
+}
+
+// WithClock 替换等待任务状态变化时使用的时钟，用于测试
+func WithClock(clk clock.Clock) Option {
+	return func(s *Service) {
+		s.clock = clk
+	}
+}
+
+func NewService(client TaskClient, logger *zap.Logger, opts ...Option) *Service {
+	s := &Service{
+		client:    client,
+		logger:    logger,
+		typeCheck: TypeCheckOff,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.auditLogger == nil {
+		s.auditLogger = logger.Named("audit")
+	}
+	if s.ids == nil {
+		s.ids = UUIDGenerator{}
+	}
+	if s.presignTTL <= 0 {
+		s.presignTTL = defaultPresignTTL
+	}
+	s.clock = clock.OrReal(s.clock)
+	return s
+}
+
 type CreateTaskResult struct {
-	TaskID string `json:"task_id"`
-	Queue  string `json:"queue"`
-	Status string `json:"status"`
+	TaskID   string   `json:"task_id"`
+	Queue    string   `json:"queue"`
+	Status   string   `json:"status"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Deprecations 请求命中的弃用项
+	Deprecations []DeprecationNotice `json:"deprecations,omitempty"`
+
+	// UniqueTTL 重复任务被拒绝的时长，未设置 unique 时为 0
+	UniqueTTL time.Duration `json:"unique_ttl,omitempty"`
+	// UniqueExpiresAt 唯一锁的过期时间，任务成功完成时会提前释放
+	UniqueExpiresAt time.Time `json:"unique_expires_at,omitempty"`
+
+	// Effective 合并默认值和上限之后实际入队的选项
+	Effective EffectiveOptions `json:"effective"`
+}
+
+// EffectiveOptions 任务入队时实际生效的选项，取自 asynq 返回的任务信息
+type EffectiveOptions struct {
+	Queue      string        `json:"queue"`
+	MaxRetries int           `json:"max_retries"`
+	Timeout    time.Duration `json:"timeout"`
+	// Retention 完成后任务记录的保留时长，为 0 时完成即删除
+	Retention time.Duration `json:"retention"`
+	UniqueTTL time.Duration `json:"unique_ttl,omitempty"`
+	// ProcessAt 定时任务的执行时间，立即执行的任务为零值
+	ProcessAt time.Time `json:"process_at,omitempty"`
+}
+
+func effectiveOptions(info *asynq.TaskInfo, unique time.Duration) EffectiveOptions {
+	eff := EffectiveOptions{
+		Queue:      info.Queue,
+		MaxRetries: info.MaxRetry,
+		Timeout:    info.Timeout,
+		Retention:  info.Retention,
+		UniqueTTL:  unique,
+	}
+	if info.State == asynq.TaskStateScheduled {
+		eff.ProcessAt = info.NextProcessAt
+	}
+	return eff
+}
+
+// uniqueExpiry 与 asynq 一致：定时任务的唯一锁从计划执行时间起算
+func uniqueExpiry(now, processAt time.Time, unique time.Duration) time.Time {
+	if processAt.After(now) {
+		return processAt.Add(unique)
+	}
+	return now.Add(unique)
 }
 
 func (s *Service) CreateTask(ctx context.Context, cmd *CreateTaskCommand) (*CreateTaskResult, error) {
 	if err := cmd.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.checkQueue(cmd.queue()); err != nil {
+		return nil, err
+	}
+
+	deprecations, err := s.applyDeprecations(cmd, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	limits := s.limits.Load()
+	var warnings []string
+	if limits != nil {
+		clamped, err := cmd.ApplyLimits(limits, s.clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		warnings = clamped
+	}
+
+	if err := cmd.ValidatePayload(limits); err != nil {
+		return nil, err
+	}
+
+	if s.validator != nil {
+		if err := s.validator.ValidatePayload(ctx, cmd.Type.String(), cmd.Payload); err != nil {
+			return nil, err
+		}
+	}
+
+	capWarnings, err := s.checkCapability(ctx, cmd.Type.String())
+	if err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, capWarnings...)
 
 	t, err := task.NewTask(cmd.Type, cmd.Payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build task: %w", err)
 	}
 
-	t.ID = uuid.New().String()
+	t.ID, err = s.taskID(cmd)
+	if err != nil {
+		return nil, err
+	}
 
 	if cmd.Queue != "" {
 		t.Queue = cmd.Queue
 	}
+	if len(cmd.Requires) > 0 {
+		if s.router == nil {
+			return nil, apperrors.ErrUnroutableLabels
+		}
+		queue, err := s.router.Route(t.Queue, cmd.Requires)
+		if err != nil {
+			return nil, errors.Join(apperrors.ErrUnroutableLabels, err)
+		}
+		// 标签要求体现在队列名的后缀中，不另外保存
+		t.Queue = queue
+	}
 	if cmd.MaxRetries > 0 {
 		t.MaxRetries = cmd.MaxRetries
 	}
@@ -69,6 +396,11 @@ func (s *Service) CreateTask(ctx context.Context, cmd *CreateTaskCommand) (*Crea
 	for k, v := range cmd.Metadata {
 		t.SetMetadata(k, v)
 	}
+	// 唯一锁的有效期不写入任务元数据（不随任务保存），查询时由 UniqueTTL 从锁本身读取
+	var uniqueExpiresAt time.Time
+	if cmd.Unique > 0 {
+		uniqueExpiresAt = uniqueExpiry(s.clock.Now(), cmd.ProcessAt, cmd.Unique)
+	}
 
 	opts := asynqqueue.EnqueueOptions{
 		Queue:      t.Queue,
@@ -91,6 +423,21 @@ func (s *Service) CreateTask(ctx context.Context, cmd *CreateTaskCommand) (*Crea
 		return nil, fmt.Errorf("failed to enqueue task: %w", err)
 	}
 
+	if s.tracker != nil {
+		if err := s.tracker.MarkCreated(ctx, info.ID, info.Queue); err != nil {
+			s.logger.Warn("failed to mark task created",
+				zap.String("task_id", info.ID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	if s.enqueues != nil {
+		s.enqueues.ObserveEnqueue(t.Type.String(), info.Queue, len(t.Payload))
+	}
+
+	s.publishCreated(ctx, info)
+
 	s.logger.Info("task created",
 		zap.String("task_id", info.ID),
 		zap.String("type", t.Type.String()),
@@ -98,12 +445,164 @@ func (s *Service) CreateTask(ctx context.Context, cmd *CreateTaskCommand) (*Crea
 	)
 
 	return &CreateTaskResult{
-		TaskID: info.ID,
-		Queue:  info.Queue,
-		Status: info.State.String(),
+		TaskID:   info.ID,
+		Queue:    info.Queue,
+		Status:   info.State.String(),
+		Warnings: warnings,
+
+		Deprecations: deprecations,
+
+		UniqueTTL:       cmd.Unique,
+		UniqueExpiresAt: uniqueExpiresAt,
+
+		Effective: effectiveOptions(info, cmd.Unique),
 	}, nil
 }
 
+// BatchItemResult 批量创建中单个任务的结果，Err 非空时 Result 为 nil
+type BatchItemResult struct {
+	Result *CreateTaskResult
+	Err    error
+}
+
+// CreateTaskBatch 依次创建任务，单个任务失败不影响其余任务，结果与 cmds 一一对应。
+// 同一批次中的 task_id 创建成功后，之后重复出现的任务直接返回 ErrTaskAlreadyExists；
+// 先出现的任务创建失败时，后面同 ID 的任务照常创建
+func (s *Service) CreateTaskBatch(ctx context.Context, cmds []*CreateTaskCommand) []BatchItemResult {
+	results := make([]BatchItemResult, len(cmds))
+	seen := make(map[string]struct{}, len(cmds))
+	for i, cmd := range cmds {
+		if _, ok := seen[cmd.TaskID]; ok && cmd.TaskID != "" {
+			results[i].Err = fmt.Errorf("%w: task_id %q appears more than once in the batch", apperrors.ErrTaskAlreadyExists, cmd.TaskID)
+			continue
+		}
+		results[i].Result, results[i].Err = s.CreateTask(ctx, cmd)
+		if results[i].Err == nil && cmd.TaskID != "" {
+			seen[cmd.TaskID] = struct{}{}
+		}
+	}
+	return results
+}
+
+// publishCreated 发布创建事件，失败时只记录日志
+func (s *Service) publishCreated(ctx context.Context, info *asynq.TaskInfo) {
+	if s.creations == nil {
+		return
+	}
+
+	message := "task queued"
+	if info.State == asynq.TaskStateScheduled && !info.NextProcessAt.IsZero() {
+		message = "task scheduled for " + info.NextProcessAt.Format(time.RFC3339)
+	}
+	prog := progress.NewProgress(info.ID, 0, info.State.String(), message)
+	prog.Metadata = map[string]string{"queue": info.Queue, progress.TaskTypeMetadataKey: info.Type}
+
+	if err := s.creations.Publish(ctx, prog); err != nil {
+		s.logger.Warn("failed to publish creation event",
+			zap.String("task_id", info.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+func (s *Service) checkCapability(ctx context.Context, taskType string) ([]string, error) {
+	if s.capabilities == nil || s.typeCheck == TypeCheckOff {
+		return nil, nil
+	}
+
+	ok, err := s.capabilities.SupportsType(ctx, taskType)
+	if err != nil {
+		// 能力目录不可用时不阻塞任务创建
+		s.logger.Warn("failed to check worker capabilities",
+			zap.String("type", taskType),
+			zap.Error(err),
+		)
+		return nil, nil
+	}
+	if ok {
+		return nil, nil
+	}
+
+	if s.typeCheck == TypeCheckReject {
+		return nil, fmt.Errorf("%w: %s", apperrors.ErrNoCapableWorker, taskType)
+	}
+
+	s.logger.Warn("no live worker advertises task type", zap.String("type", taskType))
+	return []string{fmt.Sprintf("no live worker currently handles task type %q", taskType)}, nil
+}
+
+func (s *Service) CreateTaskFromUpload(ctx context.Context, cmd *UploadTaskCommand) (*CreateTaskResult, error) {
+	if s.blobs == nil {
+		return nil, apperrors.ErrBlobStoreDisabled
+	}
+	if err := cmd.Validate(); err != nil {
+		return nil, err
+	}
+	// 在保存文件之前检查队列，避免留下没有任务引用的文件
+	if err := s.checkQueue(cmd.queue()); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if len(cmd.Payload) > 0 {
+		if err := json.Unmarshal(cmd.Payload, &fields); err != nil {
+			return nil, errors.Join(apperrors.ErrInvalidPayload, err)
+		}
+		if fields == nil {
+			fields = make(map[string]json.RawMessage)
+		}
+	}
+
+	key := path.Join("uploads", uuid.New().String(), sanitizeFilename(cmd.Filename))
+	obj, err := s.blobs.Put(ctx, key, cmd.File, cmd.ContentType)
+	if err != nil {
+		s.logger.Error("failed to store upload",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+
+	ref, err := json.Marshal(payload.BlobRef{
+		Key:         obj.Key,
+		Filename:    cmd.Filename,
+		ContentType: obj.ContentType,
+		Size:        obj.Size,
+		URI:         obj.URI,
+	})
+	if err != nil {
+		return nil, err
+	}
+	fields[cmd.FileField] = ref
+
+	cmd.Payload, err = json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.CreateTask(ctx, &cmd.CreateTaskCommand)
+	if err != nil {
+		// 任务未创建成功，清理已上传的文件
+		if delErr := s.blobs.Delete(context.WithoutCancel(ctx), key); delErr != nil {
+			s.logger.Warn("failed to clean up upload",
+				zap.String("key", key),
+				zap.Error(delErr),
+			)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func sanitizeFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		return "upload"
+	}
+	return name
+}
+
 type TaskInfo struct {
 	ID            string `json:"id"`
 	Queue         string `json:"queue"`
@@ -113,6 +612,16 @@ type TaskInfo struct {
 	Retried       int    `json:"retried"`
 	LastErr       string `json:"last_err,omitempty"`
 	NextProcessAt string `json:"next_process_at,omitempty"`
+
+	// UniqueTTL 唯一锁的剩余有效期，任务未持有锁时为 0
+	UniqueTTL       time.Duration `json:"unique_ttl,omitempty"`
+	UniqueExpiresAt string        `json:"unique_expires_at,omitempty"`
+
+	// Worker 最近一次处理该任务的 worker 实例，任务尚未开始或进度流已过期时为空
+	Worker *progress.Worker `json:"worker,omitempty"`
+
+	// Payload 仅在查询指定了 Payload 时返回
+	Payload *PayloadView `json:"payload,omitempty"`
 }
 
 type TaskListItem struct {
@@ -120,14 +629,24 @@ type TaskListItem struct {
 	Queue string `json:"queue"`
 	Type  string `json:"type"`
 	State string `json:"state"`
+	// LastErr 最近一次执行的错误，按 ResultStatusFailure 过滤时即失败原因
+	LastErr string `json:"last_err,omitempty"`
+
+	Payload *PayloadView `json:"payload,omitempty"`
 }
 
 func (s *Service) GetTask(ctx context.Context, query *GetTaskQuery) (*TaskInfo, error) {
 	if err := query.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.checkQueue(query.Queue); err != nil {
+		return nil, err
+	}
 
 	info, err := s.client.GetTaskInfo(query.Queue, query.TaskID)
+	if errors.Is(err, asynq.ErrTaskNotFound) {
+		info, err = s.retryRecentlyCreated(ctx, query, err)
+	}
 	if err != nil {
 		if errors.Is(err, asynq.ErrTaskNotFound) {
 			return nil, errors.Join(apperrors.ErrTaskNotFound, err)
@@ -149,95 +668,763 @@ func (s *Service) GetTask(ctx context.Context, query *GetTaskQuery) (*TaskInfo,
 		result.NextProcessAt = info.NextProcessAt.Format("2006-01-02T15:04:05Z07:00")
 	}
 
+	ttl, err := s.client.UniqueTTL(info)
+	if err != nil {
+		// 唯一锁信息只是辅助信息，读取失败不影响查询
+		s.logger.Warn("failed to read unique lock ttl",
+			zap.String("task_id", info.ID),
+			zap.Error(err),
+		)
+	} else if ttl > 0 {
+		result.UniqueTTL = ttl
+		result.UniqueExpiresAt = s.clock.Now().Add(ttl).Format(time.RFC3339)
+	}
+
+	result.Worker = s.lastWorker(ctx, info.ID)
+	result.Payload = s.payloadView(query.Payload, info.Payload)
+	if query.Payload == PayloadFull {
+		s.auditPayloadRead(ctx, info.Queue, []string{info.ID})
+	}
 	return result, nil
 }
 
-func (s *Service) CancelTask(ctx context.Context, cmd *CancelTaskCommand) error {
+// lastWorker 返回最近一条 worker 发布的进度事件中记录的 worker 实例，读取失败时视为未知
+func (s *Service) lastWorker(ctx context.Context, taskID string) *progress.Worker {
+	if s.latest == nil {
+		return nil
+	}
+	w, err := s.latest.LatestWorker(ctx, taskID)
+	if err != nil {
+		s.logger.Warn("failed to read task worker from progress",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+		return nil
+	}
+	return w
+}
+
+// TaskResult 任务结果内容，调用方负责关闭 Body
+type TaskResult struct {
+	ContentType string
+	Size        int64
+	Body        io.ReadCloser
+}
+
+func (s *Service) GetTaskResult(ctx context.Context, query *GetTaskQuery) (*TaskResult, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQueue(query.Queue); err != nil {
+		return nil, err
+	}
+
+	// 大结果由 worker 写入对象存储，任务本身可能已经过了保留期
+	if s.blobs != nil {
+		body, obj, err := s.blobs.Open(ctx, blobstore.ResultKey(query.TaskID))
+		if err == nil {
+			return &TaskResult{
+				ContentType: obj.ContentType,
+				Size:        obj.Size,
+				Body:        body,
+			}, nil
+		}
+		if !errors.Is(err, blobstore.ErrNotFound) {
+			return nil, fmt.Errorf("failed to open result: %w", err)
+		}
+	}
+
+	info, err := s.client.GetTaskInfo(query.Queue, query.TaskID)
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) {
+			return nil, errors.Join(apperrors.ErrTaskNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+	if len(info.Result) == 0 {
+		return nil, apperrors.ErrResultNotFound
+	}
+
+	return &TaskResult{
+		ContentType: "application/json",
+		Size:        int64(len(info.Result)),
+		Body:        io.NopCloser(bytes.NewReader(info.Result)),
+	}, nil
+}
+
+// retryRecentlyCreated 对刚创建但尚不可见的任务做有限次重试，
+// 并在调用方猜错队列时改用创建时记录的队列
+func (s *Service) retryRecentlyCreated(ctx context.Context, query *GetTaskQuery, lastErr error) (*asynq.TaskInfo, error) {
+	if s.tracker == nil {
+		return nil, lastErr
+	}
+
+	queue, ok, err := s.tracker.RecentlyCreated(ctx, query.TaskID)
+	if err != nil {
+		s.logger.Warn("failed to check recent task marker",
+			zap.String("task_id", query.TaskID),
+			zap.Error(err),
+		)
+		return nil, lastErr
+	}
+	if !ok {
+		return nil, lastErr
+	}
+
+	if queue != "" && queue != query.Queue {
+		query.Queue = queue
+		info, err := s.client.GetTaskInfo(query.Queue, query.TaskID)
+		if !errors.Is(err, asynq.ErrTaskNotFound) {
+			return info, err
+		}
+	}
+
+	for i := 0; i < s.retryAttempts; i++ {
+		select {
+		case <-ctx.Done():
+			return nil, lastErr
+		case <-time.After(s.retryInterval):
+		}
+
+		info, err := s.client.GetTaskInfo(query.Queue, query.TaskID)
+		if !errors.Is(err, asynq.ErrTaskNotFound) {
+			return info, err
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+const (
+	// CancelActionArchived 任务尚未开始，已移入 archived 不再执行，任务记录保留
+	CancelActionArchived = "archived"
+	// CancelActionRequested 任务正在执行，已通知 worker 取消
+	CancelActionRequested = "cancel_requested"
+)
+
+type CancelTaskResult struct {
+	TaskID        string `json:"task_id"`
+	Queue         string `json:"queue"`
+	PreviousState string `json:"previous_state"`
+	Action        string `json:"action"`
+}
+
+// CancelTask 根据任务当前状态取消任务，取消后任务记录和进度流都保留（删除见 DeleteTask）：
+// 未开始（pending/scheduled/retry/aggregating）的任务移入 archived 并发布 cancelled 事件，
+// 正在执行的任务通过 CancelProcessing 通知 worker。指定队列中找不到任务时查找其他允许使用的队列，
+// 都找不到时返回 ErrTaskNotFound
+func (s *Service) CancelTask(ctx context.Context, cmd *CancelTaskCommand) (*CancelTaskResult, error) {
 	if err := cmd.Validate(); err != nil {
-		return err
+		return nil, err
+	}
+	if err := s.checkQueue(cmd.Queue); err != nil {
+		return nil, err
+	}
+
+	result, err := s.cancelTask(ctx, cmd)
+	if err == nil && s.tokens != nil {
+		if revokeErr := s.tokens.Revoke(ctx, result.TaskID); revokeErr != nil {
+			s.logger.Warn("failed to revoke progress token",
+				zap.String("task_id", result.TaskID),
+				zap.Error(revokeErr),
+			)
+		}
+	}
+	fields := []zap.Field{zap.String("task_id", cmd.TaskID), zap.String("queue", cmd.Queue)}
+	if result != nil {
+		fields = append(fields,
+			zap.String("previous_state", result.PreviousState),
+			zap.String("cancel_action", result.Action),
+		)
+	}
+	s.audit(ctx, AuditCancelTask, err, fields...)
+	return result, err
+}
+
+func (s *Service) cancelTask(ctx context.Context, cmd *CancelTaskCommand) (*CancelTaskResult, error) {
+	info, err := s.findTask(cmd.Queue, cmd.TaskID)
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) {
+			return nil, errors.Join(apperrors.ErrTaskNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	switch info.State {
+	case asynq.TaskStateActive:
+		return s.requestCancel(info.ID, info.Queue, info.State.String())
+	case asynq.TaskStateCompleted, asynq.TaskStateArchived:
+		return nil, fmt.Errorf("%w: task is %s", apperrors.ErrTaskNotCancelable, info.State)
+	}
+
+	if err := s.client.ArchiveTask(info.Queue, info.ID); err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) {
+			return nil, errors.Join(apperrors.ErrTaskNotFound, err)
+		}
+		// 归档前任务可能已被 worker 取走，重新读取状态，只有正在执行时才改为取消执行
+		current, getErr := s.client.GetTaskInfo(info.Queue, info.ID)
+		switch {
+		case errors.Is(getErr, asynq.ErrTaskNotFound):
+			return nil, errors.Join(apperrors.ErrTaskNotFound, getErr)
+		case getErr != nil:
+			return nil, fmt.Errorf("failed to archive task: %w", errors.Join(err, getErr))
+		case current.State == asynq.TaskStateActive:
+			s.logger.Info("task started before it could be archived, requesting cancellation",
+				zap.String("task_id", info.ID),
+				zap.Error(err),
+			)
+			return s.requestCancel(info.ID, info.Queue, current.State.String())
+		case current.State == asynq.TaskStateCompleted || current.State == asynq.TaskStateArchived:
+			return nil, fmt.Errorf("%w: task is %s", apperrors.ErrTaskNotCancelable, current.State)
+		}
+		return nil, fmt.Errorf("failed to archive task: %w", err)
+	}
+
+	if s.completions != nil {
+		if err := s.completions.PublishCompletion(ctx, info.ID, "cancelled", "task cancelled before it started"); err != nil {
+			s.logger.Warn("failed to publish cancellation",
+				zap.String("task_id", info.ID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	s.logger.Info("task cancelled",
+		zap.String("task_id", info.ID),
+		zap.String("queue", info.Queue),
+		zap.String("previous_state", info.State.String()),
+	)
+
+	return &CancelTaskResult{
+		TaskID:        info.ID,
+		Queue:         info.Queue,
+		PreviousState: info.State.String(),
+		Action:        CancelActionArchived,
+	}, nil
+}
+
+// findTask 在 queue 中查找任务，找不到时依次查找其他允许使用的队列，都找不到时返回 asynq.ErrTaskNotFound
+func (s *Service) findTask(queue, taskID string) (*asynq.TaskInfo, error) {
+	info, err := s.client.GetTaskInfo(queue, taskID)
+	if !errors.Is(err, asynq.ErrTaskNotFound) {
+		return info, err
+	}
+	set := s.queues.Load()
+	if set == nil {
+		return nil, err
+	}
+	for _, other := range set.Names() {
+		if other == queue {
+			continue
+		}
+		info, otherErr := s.client.GetTaskInfo(other, taskID)
+		if otherErr == nil {
+			return info, nil
+		}
+		if !errors.Is(otherErr, asynq.ErrTaskNotFound) {
+			return nil, otherErr
+		}
 	}
+	return nil, err
+}
 
-	err := s.client.CancelTask(cmd.TaskID)
+func (s *Service) requestCancel(taskID, queue, state string) (*CancelTaskResult, error) {
+	err := s.client.CancelTask(taskID)
 	if err != nil {
 		if errors.Is(err, asynq.ErrTaskNotFound) {
-			return errors.Join(apperrors.ErrTaskNotFound, err)
+			return nil, errors.Join(apperrors.ErrTaskNotFound, err)
 		}
 		s.logger.Error("failed to cancel task",
-			zap.String("task_id", cmd.TaskID),
+			zap.String("task_id", taskID),
 			zap.Error(err),
 		)
-		return fmt.Errorf("failed to cancel task: %w", err)
+		return nil, fmt.Errorf("failed to cancel task: %w", err)
 	}
 
-	s.logger.Info("task cancelled", zap.String("task_id", cmd.TaskID))
-	return nil
+	s.logger.Info("task cancellation requested", zap.String("task_id", taskID))
+	return &CancelTaskResult{
+		TaskID:        taskID,
+		Queue:         queue,
+		PreviousState: state,
+		Action:        CancelActionRequested,
+	}, nil
+}
+
+// DeleteTaskResult 删除任务的结果
+type DeleteTaskResult struct {
+	TaskID string `json:"task_id"`
+	Queue  string `json:"queue"`
+	// ProgressDeleted 进度流是否已删除；未配置清理或删除失败时为 false，进度流按 TTL 过期
+	ProgressDeleted bool `json:"progress_deleted"`
 }
 
-func (s *Service) DeleteTask(ctx context.Context, cmd *DeleteTaskCommand) error {
+// DeleteTask 从队列删除任务并删除其进度流，不发布终态事件，正在订阅的客户端以 TASK_GONE 错误结束。
+// 正在执行的任务不能删除，需先取消
+func (s *Service) DeleteTask(ctx context.Context, cmd *DeleteTaskCommand) (*DeleteTaskResult, error) {
 	if err := cmd.Validate(); err != nil {
-		return err
+		return nil, err
+	}
+	if err := s.checkQueue(cmd.Queue); err != nil {
+		return nil, err
+	}
+
+	err := s.deleteTask(cmd)
+	result := &DeleteTaskResult{TaskID: cmd.TaskID, Queue: cmd.Queue}
+	if err == nil && s.progress != nil {
+		if perr := s.progress.Delete(ctx, cmd.TaskID); perr != nil {
+			s.logger.Warn("failed to delete progress stream of deleted task",
+				zap.String("task_id", cmd.TaskID),
+				zap.Error(perr),
+			)
+		} else {
+			result.ProgressDeleted = true
+		}
+	}
+	s.audit(ctx, AuditDeleteTask, err,
+		zap.String("task_id", cmd.TaskID),
+		zap.String("queue", cmd.Queue),
+		zap.Bool("progress_deleted", result.ProgressDeleted),
+	)
+	if err != nil {
+		return nil, err
 	}
 
+	s.logger.Info("task deleted",
+		zap.String("task_id", cmd.TaskID),
+		zap.String("queue", cmd.Queue),
+		zap.Bool("progress_deleted", result.ProgressDeleted),
+	)
+	return result, nil
+}
+
+func (s *Service) deleteTask(cmd *DeleteTaskCommand) error {
 	err := s.client.DeleteTask(cmd.Queue, cmd.TaskID)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, asynq.ErrTaskNotFound) {
+		return errors.Join(apperrors.ErrTaskNotFound, err)
+	}
+	// asynq 不区分删除失败的原因，正在执行的任务单独报告
+	if info, infoErr := s.client.GetTaskInfo(cmd.Queue, cmd.TaskID); infoErr == nil && info.State == asynq.TaskStateActive {
+		return fmt.Errorf("%w: cancel it before deleting", apperrors.ErrTaskActive)
+	}
+	s.logger.Error("failed to delete task",
+		zap.String("task_id", cmd.TaskID),
+		zap.String("queue", cmd.Queue),
+		zap.Error(err),
+	)
+	return fmt.Errorf("failed to delete task: %w", err)
+}
+
+// ArchiveTaskResult 归档任务的结果
+type ArchiveTaskResult struct {
+	TaskID string `json:"task_id"`
+	Queue  string `json:"queue"`
+}
+
+// ArchiveTask 将 pending、scheduled、retry 或 aggregating 状态的任务移入 archived，任务记录保留以便之后检查。
+// 归档后发布 archived 终态事件，正在订阅的客户端随之结束；正在执行的任务不能归档，需先取消
+func (s *Service) ArchiveTask(ctx context.Context, cmd *ArchiveTaskCommand) (*ArchiveTaskResult, error) {
+	if err := cmd.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQueue(cmd.Queue); err != nil {
+		return nil, err
+	}
+
+	err := s.archiveTask(cmd)
+	s.audit(ctx, AuditArchiveTask, err,
+		zap.String("task_id", cmd.TaskID),
+		zap.String("queue", cmd.Queue),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.completions != nil {
+		if err := s.completions.PublishCompletion(ctx, cmd.TaskID, "archived", "task archived before it started"); err != nil {
+			s.logger.Warn("failed to publish archive event",
+				zap.String("task_id", cmd.TaskID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	s.logger.Info("task archived",
+		zap.String("task_id", cmd.TaskID),
+		zap.String("queue", cmd.Queue),
+	)
+	return &ArchiveTaskResult{TaskID: cmd.TaskID, Queue: cmd.Queue}, nil
+}
+
+func (s *Service) archiveTask(cmd *ArchiveTaskCommand) error {
+	err := s.client.ArchiveTask(cmd.Queue, cmd.TaskID)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+		return errors.Join(apperrors.ErrTaskNotFound, err)
+	}
+	// asynq 不区分归档失败的原因，正在执行和已归档的任务单独报告
+	if info, infoErr := s.client.GetTaskInfo(cmd.Queue, cmd.TaskID); infoErr == nil {
+		switch info.State {
+		case asynq.TaskStateActive:
+			return fmt.Errorf("%w: cancel it instead", apperrors.ErrTaskActive)
+		case asynq.TaskStateArchived, asynq.TaskStateCompleted:
+			return fmt.Errorf("%w: task is already %s", apperrors.ErrInvalidTaskState, info.State)
+		}
+	}
+	s.logger.Error("failed to archive task",
+		zap.String("task_id", cmd.TaskID),
+		zap.String("queue", cmd.Queue),
+		zap.Error(err),
+	)
+	return fmt.Errorf("failed to archive task: %w", err)
+}
+
+// RunTaskResult 立即执行任务的结果
+type RunTaskResult struct {
+	TaskID string `json:"task_id"`
+	Queue  string `json:"queue"`
+	// PreviousState 移入 pending 之前的状态：scheduled 或 retry
+	PreviousState string `json:"previous_state"`
+}
+
+// RunTask 将 scheduled 或 retry 状态的任务移入 pending，由 worker 立即执行，重试次数不变
+func (s *Service) RunTask(ctx context.Context, cmd *RunTaskCommand) (*RunTaskResult, error) {
+	if err := cmd.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQueue(cmd.Queue); err != nil {
+		return nil, err
+	}
+
+	result, err := s.runTask(cmd)
+	fields := []zap.Field{zap.String("task_id", cmd.TaskID), zap.String("queue", cmd.Queue)}
+	if result != nil {
+		fields = append(fields, zap.String("previous_state", result.PreviousState))
+	}
+	s.audit(ctx, AuditRunTask, err, fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("task moved to pending to run now",
+		zap.String("task_id", cmd.TaskID),
+		zap.String("queue", cmd.Queue),
+		zap.String("previous_state", result.PreviousState),
+	)
+	return result, nil
+}
+
+func (s *Service) runTask(cmd *RunTaskCommand) (*RunTaskResult, error) {
+	info, err := s.client.GetTaskInfo(cmd.Queue, cmd.TaskID)
 	if err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+			return nil, errors.Join(apperrors.ErrTaskNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to get task info: %w", err)
+	}
+	switch info.State {
+	case asynq.TaskStateScheduled, asynq.TaskStateRetry:
+	case asynq.TaskStateActive:
+		return nil, apperrors.ErrTaskActive
+	default:
+		return nil, fmt.Errorf("%w: task is %s", apperrors.ErrTaskNotRunnable, info.State)
+	}
+
+	if err := s.client.RunTask(cmd.Queue, cmd.TaskID); err != nil {
 		if errors.Is(err, asynq.ErrTaskNotFound) {
-			return errors.Join(apperrors.ErrTaskNotFound, err)
+			return nil, errors.Join(apperrors.ErrTaskNotFound, err)
 		}
-		s.logger.Error("failed to delete task",
+		// 查询之后任务可能已开始执行，asynq 不区分失败的原因，重新查询状态
+		if info, infoErr := s.client.GetTaskInfo(cmd.Queue, cmd.TaskID); infoErr == nil && info.State == asynq.TaskStateActive {
+			return nil, apperrors.ErrTaskActive
+		}
+		s.logger.Error("failed to run task",
 			zap.String("task_id", cmd.TaskID),
 			zap.String("queue", cmd.Queue),
 			zap.Error(err),
 		)
-		return fmt.Errorf("failed to delete task: %w", err)
+		return nil, fmt.Errorf("failed to run task: %w", err)
 	}
 
-	s.logger.Info("task deleted",
-		zap.String("task_id", cmd.TaskID),
-		zap.String("queue", cmd.Queue),
-	)
-	return nil
+	return &RunTaskResult{
+		TaskID:        cmd.TaskID,
+		Queue:         cmd.Queue,
+		PreviousState: info.State.String(),
+	}, nil
 }
 
 func (s *Service) GetQueueStats(ctx context.Context, query *GetQueueStatsQuery) ([]asynqqueue.QueueStats, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	for _, queue := range query.Queues {
+		if err := s.checkQueue(queue); err != nil {
+			return nil, err
+		}
+	}
+	if len(query.Queues) > 0 {
+		return s.queueStatsFor(query.Queues, query.SkipUnknown)
+	}
 	if query.Queue != "" {
+		if err := s.checkQueue(query.Queue); err != nil {
+			return nil, err
+		}
 		info, err := s.client.GetQueueInfo(query.Queue)
 		if err != nil {
 			return nil, err
 		}
-		return []asynqqueue.QueueStats{{
-			Queue:     query.Queue,
-			Pending:   info.Pending,
-			Active:    info.Active,
-			Scheduled: info.Scheduled,
-			Retry:     info.Retry,
-			Archived:  info.Archived,
-			Completed: info.Completed,
-		}}, nil
+		return []asynqqueue.QueueStats{queueStats(query.Queue, info)}, nil
 	}
 
 	return s.client.GetAllQueueStats()
 }
 
-func (s *Service) ListTasks(ctx context.Context, query *ListTasksQuery) ([]TaskListItem, error) {
+// queueStatsFor 按给定顺序读取指定队列的统计。先取队列列表判断哪些队列不存在，
+// skipUnknown 为 false 时任一队列不存在即返回 ErrQueueNotFound
+func (s *Service) queueStatsFor(queues []string, skipUnknown bool) ([]asynqqueue.QueueStats, error) {
+	known, err := s.client.GetQueues()
+	if err != nil {
+		return nil, err
+	}
+
+	var unknown []string
+	stats := make([]asynqqueue.QueueStats, 0, len(queues))
+	for _, queue := range queues {
+		if !slices.Contains(known, queue) {
+			unknown = append(unknown, queue)
+			continue
+		}
+		info, err := s.client.GetQueueInfo(queue)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, queueStats(queue, info))
+	}
+
+	if len(unknown) > 0 && !skipUnknown {
+		return nil, fmt.Errorf("%w: %s", apperrors.ErrQueueNotFound, strings.Join(unknown, ", "))
+	}
+	return stats, nil
+}
+
+// requireQueue 确认队列存在，不存在时返回 ErrQueueNotFound。
+// asynq 的分组查询对不存在的队列返回空结果，与没有分组的队列无法区分；
+// 其他 inspector 查询返回的错误也不能与 asynq.ErrQueueNotFound 比较
+func (s *Service) requireQueue(queue string) error {
+	known, err := s.client.GetQueues()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(known, queue) {
+		return fmt.Errorf("%w: %s", apperrors.ErrQueueNotFound, queue)
+	}
+	return nil
+}
+
+func queueStats(queue string, info *asynq.QueueInfo) asynqqueue.QueueStats {
+	return asynqqueue.QueueStats{
+		Queue:     queue,
+		Pending:   info.Pending,
+		Active:    info.Active,
+		Scheduled: info.Scheduled,
+		Retry:     info.Retry,
+		Archived:  info.Archived,
+		Completed: info.Completed,
+
+		Aggregating: info.Aggregating,
+		Groups:      info.Groups,
+	}
+}
+
+func (s *Service) ListGroups(ctx context.Context, query *ListGroupsQuery) ([]asynqqueue.GroupStats, error) {
+	_ = ctx
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQueue(query.Queue); err != nil {
+		return nil, err
+	}
+	if err := s.requireQueue(query.Queue); err != nil {
+		return nil, err
+	}
+	return s.client.ListGroups(query.Queue)
+}
+
+// OldestTasks 返回队列中最早的 pending 和 scheduled 任务，空队列对应字段为 nil
+func (s *Service) OldestTasks(ctx context.Context, query *OldestTasksQuery) (*asynqqueue.OldestTasks, error) {
 	_ = ctx
 	if err := query.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.checkQueue(query.Queue); err != nil {
+		return nil, err
+	}
+	if err := s.requireQueue(query.Queue); err != nil {
+		return nil, err
+	}
+	return s.client.OldestTasks(query.Queue)
+}
+
+// flushSampleSize 刷新分组结果中返回的任务 ID 数量上限
+const flushSampleSize = 20
+
+// FlushGroupResult 刷新分组的结果，DryRun 时为预览，分组未被修改
+type FlushGroupResult struct {
+	Queue  string
+	Group  string
+	DryRun bool
+	// Affected 将被（或已被）移入 pending 的任务数
+	Affected int
+	// TaskIDs 受影响任务 ID 的样本，最多 flushSampleSize 个
+	TaskIDs []string
+}
+
+// FlushGroup 将分组中的任务移入 pending，不再等待聚合，由 worker 逐个处理。
+// DryRun 时走同一套成员查询，只返回受影响的任务而不修改分组，也不记录审计日志
+func (s *Service) FlushGroup(ctx context.Context, cmd *FlushGroupCommand) (*FlushGroupResult, error) {
+	if err := cmd.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQueue(cmd.Queue); err != nil {
+		return nil, err
+	}
+	if err := s.requireQueue(cmd.Queue); err != nil {
+		return nil, err
+	}
+
+	members, err := s.client.FlushGroup(cmd.Queue, cmd.Group, cmd.DryRun)
+	if !cmd.DryRun {
+		s.audit(ctx, AuditFlushGroup, err,
+			zap.String("queue", cmd.Queue),
+			zap.String("group", cmd.Group),
+			zap.Int("affected", len(members)),
+			zap.Strings("task_ids", members),
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !cmd.DryRun {
+		s.logger.Info("group flushed",
+			zap.String("queue", cmd.Queue),
+			zap.String("group", cmd.Group),
+			zap.Int("size", len(members)),
+		)
+	}
+	return &FlushGroupResult{
+		Queue:    cmd.Queue,
+		Group:    cmd.Group,
+		DryRun:   cmd.DryRun,
+		Affected: len(members),
+		TaskIDs:  members[:min(len(members), flushSampleSize)],
+	}, nil
+}
+
+// TaskListPage 一页任务列表，Scanned 为过滤前从 asynq 取出的任务数
+type TaskListPage struct {
+	Items   []TaskListItem
+	Scanned int
+
+	Page int
+	Size int
+	// Total 该状态下的任务总数（过滤前）。TotalExact 为 false 时队列计数不可用，
+	// Total 是由已翻过的页推算的下限
+	Total      int
+	TotalExact bool
+	HasMore    bool
+	// Order 列表的排序依据，见 asynqqueue.ListOrder
+	Order string
+}
+
+func (s *Service) ListTasks(ctx context.Context, query *ListTasksQuery) (*TaskListPage, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQueue(query.Queue); err != nil {
+		return nil, err
+	}
 
 	infos, err := s.client.ListTasks(query.Queue, query.Status, query.Page, query.Size)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list tasks: %w", err)
 	}
 
-	result := make([]TaskListItem, len(infos))
-	for i, info := range infos {
-		result[i] = TaskListItem{
-			ID:    info.ID,
-			Queue: info.Queue,
-			Type:  info.Type,
-			State: info.State.String(),
+	// 逐个解析本页任务的 payload，成本与页大小成正比
+	result := make([]TaskListItem, 0, len(infos))
+	for _, info := range infos {
+		if !matchPayload(info.Payload, query.Match) {
+			continue
 		}
+		if query.ResultStatus != "" && resultStatus(info) != query.ResultStatus {
+			continue
+		}
+		result = append(result, TaskListItem{
+			ID:      info.ID,
+			Queue:   info.Queue,
+			Type:    info.Type,
+			State:   info.State.String(),
+			LastErr: info.LastErr,
+			Payload: s.payloadView(query.Payload, info.Payload),
+		})
 	}
 
-	return result, nil
+	if query.Payload == PayloadFull && len(result) > 0 {
+		ids := make([]string, len(result))
+		for i, item := range result {
+			ids[i] = item.ID
+		}
+		s.auditPayloadRead(ctx, query.Queue, ids)
+	}
+
+	page := &TaskListPage{
+		Items:   result,
+		Scanned: len(infos),
+		Page:    query.Page,
+		Size:    query.Size,
+		Order:   asynqqueue.ListOrder(query.Status),
+	}
+	if total, ok := s.countTasks(query.Queue, query.Status); ok {
+		page.Total = total
+		page.TotalExact = true
+		page.HasMore = (query.Page+1)*query.Size < total
+	} else {
+		page.Total = query.Page*query.Size + len(infos)
+		page.HasMore = len(infos) == query.Size
+	}
+	return page, nil
+}
+
+// countTasks 从队列信息中读取 state 下的任务数，读取失败时返回 false
+func (s *Service) countTasks(queue, state string) (int, bool) {
+	info, err := s.client.GetQueueInfo(queue)
+	if err != nil || info == nil {
+		s.logger.Warn("failed to count tasks, pagination total is estimated",
+			zap.String("queue", queue),
+			zap.String("state", state),
+			zap.Error(err),
+		)
+		return 0, false
+	}
+	switch state {
+	case "pending":
+		return info.Pending, true
+	case "active":
+		return info.Active, true
+	case "scheduled":
+		return info.Scheduled, true
+	case "retry":
+		return info.Retry, true
+	case "archived":
+		return info.Archived, true
+	case "completed":
+		return info.Completed, true
+	default:
+		return 0, false
+	}
 }
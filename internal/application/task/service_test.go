@@ -2,37 +2,83 @@ package task
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/hibiken/asynq"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
 	"github.com/Aixtrade/TaskFlow/internal/domain/task"
 	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
 	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
 	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
 )
 
 type fakeClient struct {
 	enqueueInfo *asynq.TaskInfo
 	enqueueErr  error
+	enqueued    int
+	enqueueOpts asynqqueue.EnqueueOptions
 
 	getInfo    *asynq.TaskInfo
 	getInfoErr error
+	// getInfoMisses 前 N 次 GetTaskInfo 返回 ErrTaskNotFound
+	getInfoMisses int
+	getInfoCalls  int
+	getInfoQueues []string
+	// getInfoSeq 非空时 GetTaskInfo 依次返回其中的任务，最后一个重复返回，nil 表示任务不存在
+	getInfoSeq []*asynq.TaskInfo
 
-	cancelErr error
-	deleteErr error
+	cancelErr  error
+	cancelled  int
+	deleteErr  error
+	deleted    int
+	archiveErr error
+	archived   int
+	ran        int
+	copiedIDs  []string
+	copyErr    error
+	copied     *asynq.TaskInfo
 
 	queueInfo    *asynq.QueueInfo
 	queueInfoErr error
+	queues       []string
 
-	allStats    []asynqqueue.QueueStats
-	allStatsErr error
+	allStats      []asynqqueue.QueueStats
+	allStatsErr   error
+	allStatsCalls int
+
+	listed []*asynq.TaskInfo
+	// listArgs 最近一次 ListTasks 的参数
+	listArgs struct {
+		queue, state string
+		page, size   int
+	}
+
+	groups   []asynqqueue.GroupStats
+	flushErr error
+	flushed  []string
+
+	enqueuedTask *task.Task
+	uniqueTTL    time.Duration
 }
 
 func (f *fakeClient) Enqueue(ctx context.Context, t *task.Task, opts ...asynqqueue.EnqueueOptions) (*asynq.TaskInfo, error) {
+	f.enqueued++
+	f.enqueuedTask = t
+	if len(opts) > 0 {
+		f.enqueueOpts = opts[0]
+	}
 	if f.enqueueErr != nil {
 		return nil, f.enqueueErr
 	}
@@ -40,24 +86,62 @@ func (f *fakeClient) Enqueue(ctx context.Context, t *task.Task, opts ...asynqque
 }
 
 func (f *fakeClient) GetTaskInfo(queue, taskID string) (*asynq.TaskInfo, error) {
+	f.getInfoCalls++
+	f.getInfoQueues = append(f.getInfoQueues, queue)
+	if f.getInfoCalls <= f.getInfoMisses {
+		return nil, asynq.ErrTaskNotFound
+	}
 	if f.getInfoErr != nil {
 		return nil, f.getInfoErr
 	}
+	if len(f.getInfoSeq) > 0 {
+		info := f.getInfoSeq[0]
+		if len(f.getInfoSeq) > 1 {
+			f.getInfoSeq = f.getInfoSeq[1:]
+		}
+		if info == nil {
+			return nil, asynq.ErrTaskNotFound
+		}
+		return info, nil
+	}
 	return f.getInfo, nil
 }
 
 func (f *fakeClient) ListTasks(queue, state string, page, size int) ([]*asynq.TaskInfo, error) {
-	return nil, nil
+	f.listArgs.queue, f.listArgs.state = queue, state
+	f.listArgs.page, f.listArgs.size = page, size
+	return f.listed, nil
 }
 
 func (f *fakeClient) CancelTask(taskID string) error {
+	f.cancelled++
 	return f.cancelErr
 }
 
 func (f *fakeClient) DeleteTask(queue, taskID string) error {
+	f.deleted++
 	return f.deleteErr
 }
 
+func (f *fakeClient) ArchiveTask(queue, taskID string) error {
+	f.archived++
+	return f.archiveErr
+}
+
+func (f *fakeClient) RunTask(queue, taskID string) error {
+	f.ran++
+	return nil
+}
+
+func (f *fakeClient) EnqueueCopy(ctx context.Context, info *asynq.TaskInfo, taskID string) (*asynq.TaskInfo, error) {
+	f.copiedIDs = append(f.copiedIDs, taskID)
+	f.copied = info
+	if f.copyErr != nil {
+		return nil, f.copyErr
+	}
+	return &asynq.TaskInfo{ID: taskID, Queue: info.Queue, Type: info.Type, Payload: info.Payload, State: asynq.TaskStatePending}, nil
+}
+
 func (f *fakeClient) GetQueueInfo(queue string) (*asynq.QueueInfo, error) {
 	if f.queueInfoErr != nil {
 		return nil, f.queueInfoErr
@@ -65,13 +149,40 @@ func (f *fakeClient) GetQueueInfo(queue string) (*asynq.QueueInfo, error) {
 	return f.queueInfo, nil
 }
 
+func (f *fakeClient) GetQueues() ([]string, error) {
+	return f.queues, nil
+}
+
 func (f *fakeClient) GetAllQueueStats() ([]asynqqueue.QueueStats, error) {
+	f.allStatsCalls++
 	if f.allStatsErr != nil {
 		return nil, f.allStatsErr
 	}
 	return f.allStats, nil
 }
 
+func (f *fakeClient) ListGroups(queue string) ([]asynqqueue.GroupStats, error) {
+	return f.groups, nil
+}
+
+func (f *fakeClient) OldestTasks(queue string) (*asynqqueue.OldestTasks, error) {
+	return &asynqqueue.OldestTasks{Queue: queue}, nil
+}
+
+func (f *fakeClient) FlushGroup(queue, group string, dryRun bool) ([]string, error) {
+	if f.flushErr != nil {
+		return nil, f.flushErr
+	}
+	if !dryRun {
+		f.flushed = append(f.flushed, queue+"/"+group)
+	}
+	return []string{"t1", "t2", "t3"}, nil
+}
+
+func (f *fakeClient) UniqueTTL(info *asynq.TaskInfo) (time.Duration, error) {
+	return f.uniqueTTL, nil
+}
+
 func TestServiceCreateTaskAlreadyExists(t *testing.T) {
 	fake := &fakeClient{enqueueErr: asynq.ErrTaskIDConflict}
 	service := NewService(fake, zap.NewNop())
@@ -90,6 +201,57 @@ func TestServiceCreateTaskAlreadyExists(t *testing.T) {
 	}
 }
 
+func TestServiceCreateTaskBatchCollectsErrors(t *testing.T) {
+	fake := &fakeClient{enqueueErr: errors.New("redis down")}
+	service := NewService(fake, zap.NewNop())
+
+	const id = "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+	cmds := []*CreateTaskCommand{
+		{Type: tasktype.Demo, Payload: []byte(`{"message":"hi","count":1}`), TaskID: id},
+		{Type: tasktype.Demo, Payload: []byte(`{"message":"hi","count":1}`), TaskID: id},
+		{Type: tasktype.Demo, Payload: []byte(`{"message":"hi","count":1}`)},
+	}
+
+	results := service.CreateTaskBatch(context.Background(), cmds)
+	if len(results) != len(cmds) {
+		t.Fatalf("expected %d results, got %d", len(cmds), len(results))
+	}
+	for i, r := range results {
+		if r.Err == nil || r.Result != nil {
+			t.Fatalf("result %d: expected an error, got %+v", i, r)
+		}
+	}
+	// 入队失败不中断批次；先出现的任务没有创建成功，同 ID 的任务照常入队
+	if errors.Is(results[1].Err, apperrors.ErrTaskAlreadyExists) {
+		t.Fatalf("expected the task id to be retried after the first attempt failed, got %v", results[1].Err)
+	}
+	if fake.enqueued != 3 {
+		t.Fatalf("expected 3 enqueue attempts, got %d", fake.enqueued)
+	}
+}
+
+func TestServiceCreateTaskBatchRejectsCreatedDuplicates(t *testing.T) {
+	fake := &fakeClient{enqueueInfo: &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending}}
+	service := NewService(fake, zap.NewNop())
+
+	const id = "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+	cmds := []*CreateTaskCommand{
+		{Type: tasktype.Demo, Payload: []byte(`{"message":"hi","count":1}`), TaskID: id},
+		{Type: tasktype.Demo, Payload: []byte(`{"message":"hi","count":1}`), TaskID: id},
+	}
+
+	results := service.CreateTaskBatch(context.Background(), cmds)
+	if results[0].Err != nil {
+		t.Fatalf("unexpected error: %v", results[0].Err)
+	}
+	if !errors.Is(results[1].Err, apperrors.ErrTaskAlreadyExists) {
+		t.Fatalf("expected duplicate task id to be rejected, got %v", results[1].Err)
+	}
+	if fake.enqueued != 1 {
+		t.Fatalf("expected 1 enqueue attempt, got %d", fake.enqueued)
+	}
+}
+
 func TestServiceGetTaskNotFound(t *testing.T) {
 	fake := &fakeClient{getInfoErr: asynq.ErrTaskNotFound}
 	service := NewService(fake, zap.NewNop())
@@ -104,24 +266,436 @@ func TestServiceGetTaskNotFound(t *testing.T) {
 	}
 }
 
+func TestServiceNormalizesAndChecksQueues(t *testing.T) {
+	fake := &fakeClient{enqueueInfo: &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending}}
+	service := NewService(fake, zap.NewNop(), WithQueues([]string{"critical", "default", "default.gpu"}))
+	demo := []byte(`{"message":"hi","count":1}`)
+
+	if _, err := service.CreateTask(context.Background(), &CreateTaskCommand{Type: tasktype.Demo, Payload: demo, Queue: " Default "}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if fake.enqueueOpts.Queue != "default" {
+		t.Fatalf("expected queue to be normalized to default, got %q", fake.enqueueOpts.Queue)
+	}
+
+	tests := []struct {
+		queue string
+		valid bool
+	}{
+		{queue: "critical/eu"},
+		{queue: "eu", valid: true},
+	}
+	for _, tt := range tests {
+		_, err := service.CreateTask(context.Background(), &CreateTaskCommand{Type: tasktype.Demo, Payload: demo, Queue: tt.queue})
+		var queueErr *apperrors.QueueError
+		if !errors.As(err, &queueErr) || !errors.Is(err, apperrors.ErrInvalidQueue) {
+			t.Fatalf("%s: expected QueueError, got %v", tt.queue, err)
+		}
+		if tt.valid && !slices.Equal(queueErr.Valid, []string{"critical", "default", "default.gpu"}) {
+			t.Fatalf("%s: expected valid queues in the error, got %v", tt.queue, queueErr.Valid)
+		}
+	}
+	if fake.enqueued != 1 {
+		t.Fatalf("expected rejected tasks not to be enqueued, got %d enqueues", fake.enqueued)
+	}
+
+	if _, err := service.GetTask(context.Background(), &GetTaskQuery{TaskID: "id", Queue: "eu"}); !errors.Is(err, apperrors.ErrInvalidQueue) {
+		t.Fatalf("expected ErrInvalidQueue from GetTask, got %v", err)
+	}
+	if _, err := service.DeleteTask(context.Background(), &DeleteTaskCommand{TaskID: "id", Queue: "Critical/EU"}); !errors.Is(err, apperrors.ErrInvalidQueue) {
+		t.Fatalf("expected ErrInvalidQueue from DeleteTask, got %v", err)
+	}
+
+	// 所有按队列操作的命令和查询都规范化并检查队列
+	ctx := context.Background()
+	for name, call := range map[string]func(queue string) error{
+		"CancelTask": func(queue string) error {
+			_, err := service.CancelTask(ctx, &CancelTaskCommand{TaskID: "id", Queue: queue})
+			return err
+		},
+		"ListTasks": func(queue string) error {
+			_, err := service.ListTasks(ctx, &ListTasksQuery{Queue: queue})
+			return err
+		},
+		"ListGroups": func(queue string) error {
+			_, err := service.ListGroups(ctx, &ListGroupsQuery{Queue: queue})
+			return err
+		},
+		"FlushGroup": func(queue string) error {
+			_, err := service.FlushGroup(ctx, &FlushGroupCommand{Queue: queue, Group: "g", DryRun: true})
+			return err
+		},
+		"OldestTasks": func(queue string) error {
+			_, err := service.OldestTasks(ctx, &OldestTasksQuery{Queue: queue})
+			return err
+		},
+		"GetQueueStats": func(queue string) error {
+			_, err := service.GetQueueStats(ctx, &GetQueueStatsQuery{Queue: queue})
+			return err
+		},
+		"GetQueueStats queues": func(queue string) error {
+			_, err := service.GetQueueStats(ctx, &GetQueueStatsQuery{Queues: []string{"default", queue}})
+			return err
+		},
+	} {
+		for _, queue := range []string{"eu", "Critical/EU"} {
+			if err := call(queue); !errors.Is(err, apperrors.ErrInvalidQueue) {
+				t.Fatalf("%s(%q): expected ErrInvalidQueue, got %v", name, queue, err)
+			}
+		}
+	}
+}
+
 func TestServiceCancelTaskNotFound(t *testing.T) {
-	fake := &fakeClient{cancelErr: asynq.ErrTaskNotFound}
+	fake := &fakeClient{getInfoErr: asynq.ErrTaskNotFound, cancelErr: asynq.ErrTaskNotFound}
 	service := NewService(fake, zap.NewNop())
 
-	err := service.CancelTask(context.Background(), &CancelTaskCommand{TaskID: "id"})
+	_, err := service.CancelTask(context.Background(), &CancelTaskCommand{TaskID: "id"})
 	if err == nil {
 		t.Fatal("expected error")
 	}
 	if !errors.Is(err, apperrors.ErrTaskNotFound) {
 		t.Fatalf("expected ErrTaskNotFound, got %v", err)
 	}
+	if fake.cancelled != 0 {
+		t.Fatalf("expected no cancel request for a missing task, got %d", fake.cancelled)
+	}
+}
+
+func TestServiceCancelTaskSearchesOtherQueues(t *testing.T) {
+	fake := &fakeClient{
+		getInfoMisses: 1,
+		getInfo:       &asynq.TaskInfo{ID: "id", Queue: "low", State: asynq.TaskStatePending},
+	}
+	service := NewService(fake, zap.NewNop(), WithQueues([]string{"default", "low"}))
+
+	result, err := service.CancelTask(context.Background(), &CancelTaskCommand{TaskID: "id", Queue: "default"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(fake.getInfoQueues, []string{"default", "low"}) {
+		t.Fatalf("expected lookup in the other known queue, got %v", fake.getInfoQueues)
+	}
+	if result.Queue != "low" || result.Action != CancelActionArchived {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// 所有队列都找不到时返回 ErrTaskNotFound
+	fake = &fakeClient{getInfoErr: asynq.ErrTaskNotFound}
+	service = NewService(fake, zap.NewNop(), WithQueues([]string{"default", "low"}))
+	if _, err := service.CancelTask(context.Background(), &CancelTaskCommand{TaskID: "id", Queue: "default"}); !errors.Is(err, apperrors.ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+	if fake.getInfoCalls != 2 || fake.cancelled != 0 {
+		t.Fatalf("expected both queues searched and no cancel request, got %d lookups, %d cancels", fake.getInfoCalls, fake.cancelled)
+	}
+}
+
+type fakeCompletions struct {
+	statuses map[string]string
+}
+
+func (f *fakeCompletions) PublishCompletion(ctx context.Context, taskID, status, message string) error {
+	f.statuses[taskID] = status
+	return nil
+}
+
+func TestServiceCancelTaskArchivesTasksNotYetStarted(t *testing.T) {
+	for _, state := range []asynq.TaskState{asynq.TaskStatePending, asynq.TaskStateScheduled, asynq.TaskStateRetry} {
+		fake := &fakeClient{getInfo: &asynq.TaskInfo{ID: "id", Queue: "low", State: state}}
+		completions := &fakeCompletions{statuses: map[string]string{}}
+		service := NewService(fake, zap.NewNop(), WithCompletionPublisher(completions))
+
+		result, err := service.CancelTask(context.Background(), &CancelTaskCommand{TaskID: "id", Queue: "low"})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", state, err)
+		}
+		if result.Action != CancelActionArchived || result.PreviousState != state.String() {
+			t.Fatalf("%s: unexpected result %+v", state, result)
+		}
+		// 取消保留任务记录
+		if fake.archived != 1 || fake.deleted != 0 || fake.cancelled != 0 {
+			t.Fatalf("%s: expected archive only, got archived=%d deleted=%d cancelled=%d", state, fake.archived, fake.deleted, fake.cancelled)
+		}
+		if completions.statuses["id"] != "cancelled" {
+			t.Fatalf("%s: expected cancelled event, got %v", state, completions.statuses)
+		}
+	}
+}
+
+func TestServiceArchiveTaskPublishesArchivedEvent(t *testing.T) {
+	fake := &fakeClient{}
+	completions := &fakeCompletions{statuses: map[string]string{}}
+	service := NewService(fake, zap.NewNop(), WithCompletionPublisher(completions))
+
+	if _, err := service.ArchiveTask(context.Background(), &ArchiveTaskCommand{TaskID: "id", Queue: "default"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fake.archived != 1 {
+		t.Fatalf("expected one archive, got %d", fake.archived)
+	}
+	if completions.statuses["id"] != "archived" {
+		t.Fatalf("expected archived event, got %v", completions.statuses)
+	}
+
+	// 归档失败时不发布
+	fake = &fakeClient{archiveErr: asynq.ErrTaskNotFound}
+	completions = &fakeCompletions{statuses: map[string]string{}}
+	service = NewService(fake, zap.NewNop(), WithCompletionPublisher(completions))
+	if _, err := service.ArchiveTask(context.Background(), &ArchiveTaskCommand{TaskID: "id", Queue: "default"}); !errors.Is(err, apperrors.ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+	if len(completions.statuses) != 0 {
+		t.Fatalf("expected no event for a failed archive, got %v", completions.statuses)
+	}
+}
+
+type fakeRevoker struct {
+	revoked []string
+}
+
+func (f *fakeRevoker) Revoke(ctx context.Context, taskID string) error {
+	f.revoked = append(f.revoked, taskID)
+	return nil
+}
+
+func TestServiceCancelTaskRevokesProgressToken(t *testing.T) {
+	fake := &fakeClient{getInfo: &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStateActive}}
+	revoker := &fakeRevoker{}
+	service := NewService(fake, zap.NewNop(), WithProgressTokenRevocation(revoker))
+
+	if _, err := service.CancelTask(context.Background(), &CancelTaskCommand{TaskID: "id", Queue: "default"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(revoker.revoked, []string{"id"}) {
+		t.Fatalf("expected token of cancelled task to be revoked, got %v", revoker.revoked)
+	}
+
+	// 取消失败时令牌保持有效
+	fake.getInfo = &asynq.TaskInfo{ID: "done", Queue: "default", State: asynq.TaskStateCompleted}
+	if _, err := service.CancelTask(context.Background(), &CancelTaskCommand{TaskID: "done", Queue: "default"}); err == nil {
+		t.Fatal("expected error for completed task")
+	}
+	if len(revoker.revoked) != 1 {
+		t.Fatalf("expected no revocation on failed cancel, got %v", revoker.revoked)
+	}
+}
+
+func TestServiceCancelTaskCancelsActiveTask(t *testing.T) {
+	fake := &fakeClient{getInfo: &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStateActive}}
+	completions := &fakeCompletions{statuses: map[string]string{}}
+	service := NewService(fake, zap.NewNop(), WithCompletionPublisher(completions))
+
+	result, err := service.CancelTask(context.Background(), &CancelTaskCommand{TaskID: "id"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Action != CancelActionRequested || fake.cancelled != 1 || fake.archived != 0 {
+		t.Fatalf("unexpected result %+v (archived=%d cancelled=%d)", result, fake.archived, fake.cancelled)
+	}
+	// 执行中的任务由 worker 发布终态
+	if len(completions.statuses) != 0 {
+		t.Fatalf("expected no completion event, got %v", completions.statuses)
+	}
+}
+
+func TestServiceCancelTaskFallsBackWhenTaskStartsDuringArchive(t *testing.T) {
+	fake := &fakeClient{
+		getInfoSeq: []*asynq.TaskInfo{
+			{ID: "id", Queue: "default", State: asynq.TaskStatePending},
+			{ID: "id", Queue: "default", State: asynq.TaskStateActive},
+		},
+		archiveErr: errors.New("cannot archive task in active state"),
+	}
+	service := NewService(fake, zap.NewNop())
+
+	result, err := service.CancelTask(context.Background(), &CancelTaskCommand{TaskID: "id"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Action != CancelActionRequested || fake.cancelled != 1 {
+		t.Fatalf("expected cancel request, got %+v", result)
+	}
+}
+
+func TestServiceCancelTaskReportsArchiveFailure(t *testing.T) {
+	// 归档失败但任务仍未开始时不发送取消请求，返回归档错误
+	fake := &fakeClient{
+		getInfo:    &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending},
+		archiveErr: errors.New("redis: connection reset"),
+	}
+	service := NewService(fake, zap.NewNop())
+
+	if _, err := service.CancelTask(context.Background(), &CancelTaskCommand{TaskID: "id"}); err == nil || errors.Is(err, apperrors.ErrTaskNotFound) {
+		t.Fatalf("expected archive error, got %v", err)
+	}
+	if fake.cancelled != 0 {
+		t.Fatalf("expected no cancel request for a pending task, got %d", fake.cancelled)
+	}
+
+	// 归档期间任务已完成
+	fake.getInfoSeq = []*asynq.TaskInfo{
+		{ID: "id", Queue: "default", State: asynq.TaskStatePending},
+		{ID: "id", Queue: "default", State: asynq.TaskStateCompleted},
+	}
+	if _, err := service.CancelTask(context.Background(), &CancelTaskCommand{TaskID: "id"}); !errors.Is(err, apperrors.ErrTaskNotCancelable) {
+		t.Fatalf("expected ErrTaskNotCancelable, got %v", err)
+	}
+}
+
+func TestServiceCancelTaskRejectsFinishedTask(t *testing.T) {
+	fake := &fakeClient{getInfo: &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStateCompleted}}
+	service := NewService(fake, zap.NewNop())
+
+	_, err := service.CancelTask(context.Background(), &CancelTaskCommand{TaskID: "id"})
+	if !errors.Is(err, apperrors.ErrTaskNotCancelable) {
+		t.Fatalf("expected ErrTaskNotCancelable, got %v", err)
+	}
+}
+
+func TestServiceRequeueTask(t *testing.T) {
+	state := func(s asynq.TaskState) *asynq.TaskInfo {
+		return &asynq.TaskInfo{ID: "id", Queue: "default", Type: "demo", Payload: []byte(`{"message":"hi","count":1}`), State: s}
+	}
+	tests := []struct {
+		name     string
+		seq      []*asynq.TaskInfo
+		wantErr  error
+		archived int
+		copied   int
+	}{
+		{name: "stops into retry", seq: []*asynq.TaskInfo{state(asynq.TaskStateActive), state(asynq.TaskStateActive), state(asynq.TaskStateRetry)}, archived: 1, copied: 1},
+		{name: "stops into archived", seq: []*asynq.TaskInfo{state(asynq.TaskStateActive), state(asynq.TaskStateArchived)}, copied: 1},
+		{name: "completes during the wait", seq: []*asynq.TaskInfo{state(asynq.TaskStateActive), state(asynq.TaskStateCompleted)}, wantErr: apperrors.ErrTaskCompleted},
+		{name: "removed after completing", seq: []*asynq.TaskInfo{state(asynq.TaskStateActive), nil}, wantErr: apperrors.ErrTaskCompleted},
+		{name: "does not stop", seq: []*asynq.TaskInfo{state(asynq.TaskStateActive)}, wantErr: apperrors.ErrTaskActive},
+		{name: "not running", seq: []*asynq.TaskInfo{state(asynq.TaskStatePending)}, wantErr: apperrors.ErrTaskNotActive},
+		{name: "already completed", seq: []*asynq.TaskInfo{state(asynq.TaskStateCompleted)}, wantErr: apperrors.ErrTaskCompleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeClient{getInfoSeq: tt.seq}
+			creations := &fakeProgressPublisher{}
+			clk := clock.NewFake(time.Unix(0, 0))
+			service := NewService(fake, zap.NewNop(), WithClock(clk),
+				WithRequeueWait(300*time.Millisecond), WithIDGenerator(UUIDGenerator{}, "job-"), WithCreationEvents(creations))
+
+			var (
+				result *RequeueTaskResult
+				err    error
+			)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				result, err = service.RequeueTask(context.Background(), &RequeueTaskCommand{TaskID: "id", Queue: " Default", IDPrefix: "acme-"})
+			}()
+			advanceUntilDone(clk, requeuePollInterval, done)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fake.archived != tt.archived || len(fake.copiedIDs) != tt.copied {
+				t.Fatalf("archived=%d copied=%v, want %d and %d", fake.archived, fake.copiedIDs, tt.archived, tt.copied)
+			}
+			if tt.copied == 0 {
+				return
+			}
+			if result.TaskID != "id" || result.NewTaskID != fake.copiedIDs[0] || !strings.HasPrefix(result.NewTaskID, "acme-") {
+				t.Fatalf("unexpected result %+v", result)
+			}
+			if fake.cancelled != 1 || len(creations.published) != 1 || creations.published[0].TaskID != result.NewTaskID {
+				t.Fatalf("expected one cancel and a creation event for the copy, got cancelled=%d events=%v", fake.cancelled, creations.published)
+			}
+		})
+	}
+}
+
+func TestServiceRequeueTaskRestoresTaskWhenCopyFails(t *testing.T) {
+	state := func(s asynq.TaskState) *asynq.TaskInfo {
+		return &asynq.TaskInfo{ID: "id", Queue: "default", Type: "demo", Payload: []byte(`{"message":"hi","count":1}`), State: s}
+	}
+	fake := &fakeClient{
+		getInfoSeq: []*asynq.TaskInfo{state(asynq.TaskStateActive), state(asynq.TaskStateRetry)},
+		copyErr:    errors.New("redis: connection reset"),
+	}
+	clk := clock.NewFake(time.Unix(0, 0))
+	service := NewService(fake, zap.NewNop(), WithClock(clk))
+
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err = service.RequeueTask(context.Background(), &RequeueTaskCommand{TaskID: "id", Queue: "default"})
+	}()
+	advanceUntilDone(clk, requeuePollInterval, done)
+	if err == nil {
+		t.Fatal("expected the enqueue error")
+	}
+	// 归档的原任务重新进入 pending
+	if fake.archived != 1 || fake.ran != 1 {
+		t.Fatalf("expected the archived task to be restored, got archived=%d ran=%d", fake.archived, fake.ran)
+	}
+}
+
+func TestServiceRequeueTaskChecksCopyAgainstLimits(t *testing.T) {
+	info := &asynq.TaskInfo{ID: "id", Queue: "default", Type: "demo", Payload: []byte(`{"message":"hi","count":1}`),
+		State: asynq.TaskStateActive, MaxRetry: 50, Timeout: 2 * time.Hour}
+
+	// reject 模式下超出上限时不取消原任务
+	fake := &fakeClient{getInfo: info}
+	service := NewService(fake, zap.NewNop(), WithLimits(Limits{MaxRetriesCap: 10, MaxRetriesMode: LimitReject}))
+	_, err := service.RequeueTask(context.Background(), &RequeueTaskCommand{TaskID: "id", Queue: "default"})
+	var limitErr *apperrors.LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "limits.max_retries_cap" {
+		t.Fatalf("expected max_retries_cap limit error, got %v", err)
+	}
+	if fake.cancelled != 0 || len(fake.copiedIDs) != 0 {
+		t.Fatalf("expected the task to be left running, got cancelled=%d copies=%v", fake.cancelled, fake.copiedIDs)
+	}
+
+	// clamp 模式下副本按上限入队
+	stopped := *info
+	stopped.State = asynq.TaskStateArchived
+	fake = &fakeClient{getInfoSeq: []*asynq.TaskInfo{info, &stopped}}
+	service = NewService(fake, zap.NewNop(), WithLimits(Limits{
+		MaxRetriesCap:  10,
+		MaxRetriesMode: LimitClamp,
+		TimeoutCap:     time.Hour,
+		TimeoutMode:    LimitClamp,
+	}))
+	if _, err := service.RequeueTask(context.Background(), &RequeueTaskCommand{TaskID: "id", Queue: "default"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fake.copied == nil || fake.copied.MaxRetry != 10 || fake.copied.Timeout != time.Hour {
+		t.Fatalf("expected the copy to be clamped, got %+v", fake.copied)
+	}
+}
+
+// advanceUntilDone 在 done 关闭前，每当被测代码等待定时器时推进 step
+func advanceUntilDone(clk *clock.Fake, step time.Duration, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if clk.Waiters() > 0 {
+			clk.Advance(step)
+			continue
+		}
+		runtime.Gosched()
+	}
 }
 
 func TestServiceDeleteTaskNotFound(t *testing.T) {
 	fake := &fakeClient{deleteErr: asynq.ErrTaskNotFound}
 	service := NewService(fake, zap.NewNop())
 
-	err := service.DeleteTask(context.Background(), &DeleteTaskCommand{TaskID: "id", Queue: "default"})
+	_, err := service.DeleteTask(context.Background(), &DeleteTaskCommand{TaskID: "id", Queue: "default"})
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -130,6 +704,54 @@ func TestServiceDeleteTaskNotFound(t *testing.T) {
 	}
 }
 
+type fakeProgressDeleter struct {
+	deleted []string
+	err     error
+}
+
+func (f *fakeProgressDeleter) Delete(ctx context.Context, taskID string) error {
+	f.deleted = append(f.deleted, taskID)
+	return f.err
+}
+
+func TestServiceDeleteTaskRemovesProgress(t *testing.T) {
+	fake := &fakeClient{}
+	progress := &fakeProgressDeleter{}
+	service := NewService(fake, zap.NewNop(), WithProgressCleanup(progress))
+
+	result, err := service.DeleteTask(context.Background(), &DeleteTaskCommand{TaskID: "id", Queue: "default"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.ProgressDeleted || len(progress.deleted) != 1 || progress.deleted[0] != "id" {
+		t.Fatalf("expected progress stream deleted, got %+v (%v)", result, progress.deleted)
+	}
+
+	// 进度流删除失败不影响任务删除
+	progress.err = errors.New("redis down")
+	result, err = service.DeleteTask(context.Background(), &DeleteTaskCommand{TaskID: "id", Queue: "default"})
+	if err != nil || result.ProgressDeleted {
+		t.Fatalf("expected task deleted without progress cleanup, got %+v, %v", result, err)
+	}
+}
+
+func TestServiceDeleteTaskRejectsActiveTask(t *testing.T) {
+	fake := &fakeClient{
+		deleteErr: errors.New("cannot delete task in active state"),
+		getInfo:   &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStateActive},
+	}
+	progress := &fakeProgressDeleter{}
+	service := NewService(fake, zap.NewNop(), WithProgressCleanup(progress))
+
+	_, err := service.DeleteTask(context.Background(), &DeleteTaskCommand{TaskID: "id", Queue: "default"})
+	if !errors.Is(err, apperrors.ErrTaskActive) {
+		t.Fatalf("expected ErrTaskActive, got %v", err)
+	}
+	if len(progress.deleted) != 0 {
+		t.Fatalf("expected progress stream kept, got %v", progress.deleted)
+	}
+}
+
 func TestServiceGetQueueStatsSingleQueue(t *testing.T) {
 	fake := &fakeClient{
 		queueInfo: &asynq.QueueInfo{
@@ -191,3 +813,881 @@ func TestServiceCreateTaskUsesProcessAt(t *testing.T) {
 		t.Fatalf("expected task id 'id', got %s", result.TaskID)
 	}
 }
+
+func TestServiceCreateTaskReportsUniqueWindow(t *testing.T) {
+	info := &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStateScheduled}
+	fake := &fakeClient{enqueueInfo: info}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := NewService(fake, zap.NewNop(), WithClock(clock.NewFake(now)))
+
+	cmd := &CreateTaskCommand{
+		Type:    tasktype.Demo,
+		Payload: []byte(`{"message":"hi","count":1}`),
+		Unique:  10 * time.Minute,
+	}
+	result, err := service.CreateTask(context.Background(), cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.UniqueTTL != 10*time.Minute || !result.UniqueExpiresAt.Equal(now.Add(10*time.Minute)) {
+		t.Fatalf("unexpected unique window: %v until %v", result.UniqueTTL, result.UniqueExpiresAt)
+	}
+
+	// 定时任务的唯一锁从计划执行时间起算
+	when := now.Add(time.Hour)
+	cmd.ProcessAt = when
+	result, err = service.CreateTask(context.Background(), cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.UniqueExpiresAt.Equal(when.Add(10 * time.Minute)) {
+		t.Fatalf("unexpected unique expiry %v", result.UniqueExpiresAt)
+	}
+}
+
+func TestServiceGetTaskReportsRemainingUniqueTTL(t *testing.T) {
+	info := &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending}
+	fake := &fakeClient{getInfo: info, uniqueTTL: 90 * time.Second}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := NewService(fake, zap.NewNop(), WithClock(clock.NewFake(now)))
+
+	result, err := service.GetTask(context.Background(), &GetTaskQuery{TaskID: "id", Queue: "default"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.UniqueTTL != 90*time.Second || result.UniqueExpiresAt != "2026-01-01T12:01:30Z" {
+		t.Fatalf("expected remaining unique ttl, got %v until %q", result.UniqueTTL, result.UniqueExpiresAt)
+	}
+}
+
+func TestServiceCreateTaskRejectsRetriesAboveCap(t *testing.T) {
+	fake := &fakeClient{}
+	service := NewService(fake, zap.NewNop(), WithLimits(Limits{MaxRetriesCap: 25, MaxRetriesMode: LimitReject}))
+
+	_, err := service.CreateTask(context.Background(), &CreateTaskCommand{
+		Type:       tasktype.Demo,
+		Payload:    []byte(`{"message":"hi","count":1}`),
+		MaxRetries: 1000,
+	})
+	var limitErr *apperrors.LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "limits.max_retries_cap" || limitErr.Cap != "25" {
+		t.Fatalf("expected max_retries_cap limit error, got %v", err)
+	}
+	if fake.enqueued != 0 {
+		t.Fatalf("expected nothing to be enqueued")
+	}
+}
+
+func TestServiceCreateTaskRejectsGRPCTimeoutBelowBudget(t *testing.T) {
+	fake := &fakeClient{enqueueInfo: &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending}}
+	service := NewService(fake, zap.NewNop(), WithLimits(Limits{GRPCMinTimeout: 1500 * time.Millisecond}))
+
+	_, err := service.CreateTask(context.Background(), &CreateTaskCommand{
+		Type:    tasktype.GRPCTask,
+		Payload: []byte(`{"service":"llm","data":{}}`),
+		Timeout: time.Second,
+	})
+	var limitErr *apperrors.LimitError
+	if !errors.As(err, &limitErr) || !limitErr.Below || limitErr.Cap != "1.5s" {
+		t.Fatalf("expected a minimum timeout error, got %v", err)
+	}
+	if fake.enqueued != 0 {
+		t.Fatalf("expected nothing to be enqueued")
+	}
+
+	// 其他任务类型不受影响
+	if _, err := service.CreateTask(context.Background(), &CreateTaskCommand{
+		Type:    tasktype.Demo,
+		Payload: []byte(`{"message":"hi","count":1}`),
+		Timeout: time.Second,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestServiceCreateTaskClampsAndDefaults(t *testing.T) {
+	info := &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStateScheduled}
+	fake := &fakeClient{enqueueInfo: info}
+	now := time.Unix(1_700_000_000, 0)
+	service := NewService(fake, zap.NewNop(), WithClock(clock.NewFake(now)), WithLimits(Limits{
+		DefaultMaxRetries: 5,
+		TimeoutCap:        time.Hour,
+		TimeoutMode:       LimitClamp,
+		ProcessAtHorizon:  24 * time.Hour,
+		ProcessAtMode:     LimitClamp,
+	}))
+
+	result, err := service.CreateTask(context.Background(), &CreateTaskCommand{
+		Type:      tasktype.Demo,
+		Payload:   []byte(`{"message":"hi","count":1}`),
+		Timeout:   72 * time.Hour,
+		ProcessAt: now.Add(30 * 24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fake.enqueueOpts.MaxRetries != 5 || fake.enqueueOpts.Timeout != time.Hour {
+		t.Fatalf("expected defaulted retries and clamped timeout, got %+v", fake.enqueueOpts)
+	}
+	if !fake.enqueueOpts.ProcessAt.Equal(now.Add(24 * time.Hour)) {
+		t.Fatalf("expected process_at clamped to horizon, got %v", fake.enqueueOpts.ProcessAt)
+	}
+	if len(result.Warnings) != 2 {
+		t.Fatalf("expected clamp warnings, got %v", result.Warnings)
+	}
+}
+
+type fakeProgressPublisher struct {
+	published []*progress.Progress
+}
+
+func (f *fakeProgressPublisher) Publish(ctx context.Context, prog *progress.Progress) error {
+	f.published = append(f.published, prog)
+	return nil
+}
+
+func TestServiceCreateTaskPublishesCreationEvent(t *testing.T) {
+	when := time.Now().Add(time.Hour)
+	info := &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStateScheduled, NextProcessAt: when}
+	fake := &fakeClient{enqueueInfo: info}
+	publisher := &fakeProgressPublisher{}
+	service := NewService(fake, zap.NewNop(), WithCreationEvents(publisher))
+
+	_, err := service.CreateTask(context.Background(), &CreateTaskCommand{
+		Type:      tasktype.Demo,
+		Payload:   []byte(`{"message":"hi","count":1}`),
+		ProcessAt: when,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(publisher.published) != 1 {
+		t.Fatalf("expected one creation event, got %d", len(publisher.published))
+	}
+	prog := publisher.published[0]
+	if prog.TaskID != "id" || prog.Percentage != 0 || prog.Stage != "scheduled" {
+		t.Fatalf("unexpected creation event: %+v", prog)
+	}
+}
+
+type fakeEnqueueRecorder struct {
+	observed []string
+}
+
+func (f *fakeEnqueueRecorder) ObserveEnqueue(taskType, queue string, payloadBytes int) {
+	f.observed = append(f.observed, fmt.Sprintf("%s/%s/%d", taskType, queue, payloadBytes))
+}
+
+func TestServiceCreateTaskRecordsEnqueueMetrics(t *testing.T) {
+	info := &asynq.TaskInfo{ID: "id", Queue: "high", State: asynq.TaskStatePending}
+	fake := &fakeClient{enqueueInfo: info}
+	recorder := &fakeEnqueueRecorder{}
+	service := NewService(fake, zap.NewNop(), WithEnqueueMetrics(recorder))
+
+	payload := []byte(`{"message":"hi","count":1}`)
+	if _, err := service.CreateTask(context.Background(), &CreateTaskCommand{Type: tasktype.Demo, Payload: payload}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fake.enqueueErr = errors.New("redis down")
+	if _, err := service.CreateTask(context.Background(), &CreateTaskCommand{Type: tasktype.Demo, Payload: payload}); err == nil {
+		t.Fatal("expected enqueue error")
+	}
+
+	want := fmt.Sprintf("demo/high/%d", len(payload))
+	if len(recorder.observed) != 1 || recorder.observed[0] != want {
+		t.Fatalf("expected only the successful enqueue %q to be recorded, got %v", want, recorder.observed)
+	}
+}
+
+type fakeDeprecationRecorder struct {
+	observed []string
+}
+
+func (f *fakeDeprecationRecorder) ObserveDeprecated(name, outcome string) {
+	f.observed = append(f.observed, name+"/"+outcome)
+}
+
+// legacyDemo 旧版 demo payload 使用 msg 字段
+var legacyDemo = tasktype.Migration{
+	Type: tasktype.Demo,
+	Matches: func(payload []byte) bool {
+		var fields map[string]json.RawMessage
+		return json.Unmarshal(payload, &fields) == nil && fields["msg"] != nil
+	},
+	Migrate: func(payload []byte) ([]byte, error) {
+		var old struct {
+			Msg string `json:"msg"`
+		}
+		if err := json.Unmarshal(payload, &old); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]any{"message": old.Msg, "count": 1})
+	},
+}
+
+func TestServiceCreateTaskMigratesDeprecatedPayload(t *testing.T) {
+	info := &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending}
+	fake := &fakeClient{enqueueInfo: info}
+	recorder := &fakeDeprecationRecorder{}
+	cutoff := time.Now().Add(24 * time.Hour)
+	service := NewService(fake, zap.NewNop(), WithDeprecations([]Deprecation{{
+		Name:      "demo-msg",
+		Type:      tasktype.Demo,
+		Migration: &legacyDemo,
+		Rewrite:   true,
+		Message:   "use message instead of msg",
+		Cutoff:    cutoff,
+	}}, recorder))
+
+	result, err := service.CreateTask(context.Background(), &CreateTaskCommand{Type: tasktype.Demo, Payload: []byte(`{"msg":"hi"}`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(fake.enqueuedTask.Payload); got != `{"count":1,"message":"hi"}` {
+		t.Fatalf("expected migrated payload, got %s", got)
+	}
+	if len(result.Deprecations) != 1 || !result.Deprecations[0].Migrated || !result.Deprecations[0].Cutoff.Equal(cutoff) {
+		t.Fatalf("unexpected deprecation notices: %+v", result.Deprecations)
+	}
+
+	// 新格式不命中
+	result, err = service.CreateTask(context.Background(), &CreateTaskCommand{Type: tasktype.Demo, Payload: []byte(`{"message":"hi","count":1}`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Deprecations) != 0 {
+		t.Fatalf("expected current payload not to be deprecated, got %+v", result.Deprecations)
+	}
+	if len(recorder.observed) != 1 || recorder.observed[0] != "demo-msg/migrated" {
+		t.Fatalf("unexpected deprecation metrics: %v", recorder.observed)
+	}
+}
+
+func TestServiceCreateTaskRejectsDeprecatedAfterCutoff(t *testing.T) {
+	fake := &fakeClient{enqueueInfo: &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending}}
+	recorder := &fakeDeprecationRecorder{}
+	cutoff := time.Unix(1_700_000_000, 0)
+	clk := clock.NewFake(cutoff.Add(-time.Minute))
+	service := NewService(fake, zap.NewNop(), WithClock(clk), WithDeprecations([]Deprecation{{
+		Name:    "demo-retired",
+		Type:    tasktype.Demo,
+		Message: "use grpc_task",
+		Cutoff:  cutoff,
+	}}, recorder))
+	cmd := &CreateTaskCommand{Type: tasktype.Demo, Payload: []byte(`{"message":"hi","count":1}`)}
+
+	if _, err := service.CreateTask(context.Background(), cmd); err != nil {
+		t.Fatalf("expected request before cutoff to be accepted, got %v", err)
+	}
+
+	clk.Advance(time.Minute)
+	_, err := service.CreateTask(context.Background(), cmd)
+	var deprecatedErr *apperrors.DeprecatedError
+	if !errors.As(err, &deprecatedErr) || !errors.Is(err, apperrors.ErrDeprecated) || deprecatedErr.Name != "demo-retired" {
+		t.Fatalf("expected deprecation error, got %v", err)
+	}
+	if fake.enqueued != 1 {
+		t.Fatalf("expected only the first request to be enqueued, got %d", fake.enqueued)
+	}
+	if len(recorder.observed) != 2 || recorder.observed[0] != "demo-retired/accepted" || recorder.observed[1] != "demo-retired/rejected" {
+		t.Fatalf("unexpected deprecation metrics: %v", recorder.observed)
+	}
+}
+
+type fakeCapabilities struct {
+	supported bool
+	err       error
+}
+
+func (f *fakeCapabilities) SupportsType(ctx context.Context, taskType string) (bool, error) {
+	return f.supported, f.err
+}
+
+func TestServiceCreateTaskRejectsUnsupportedType(t *testing.T) {
+	fake := &fakeClient{enqueueInfo: &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending}}
+	service := NewService(fake, zap.NewNop(), WithCapabilityCheck(&fakeCapabilities{}, TypeCheckReject))
+
+	cmd := &CreateTaskCommand{
+		Type:    tasktype.Demo,
+		Payload: []byte(`{"message":"hi","count":1}`),
+	}
+
+	_, err := service.CreateTask(context.Background(), cmd)
+	if !errors.Is(err, apperrors.ErrNoCapableWorker) {
+		t.Fatalf("expected ErrNoCapableWorker, got %v", err)
+	}
+}
+
+func TestServiceCreateTaskWarnsUnsupportedType(t *testing.T) {
+	fake := &fakeClient{enqueueInfo: &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending}}
+	service := NewService(fake, zap.NewNop(), WithCapabilityCheck(&fakeCapabilities{}, TypeCheckWarn))
+
+	cmd := &CreateTaskCommand{
+		Type:    tasktype.Demo,
+		Payload: []byte(`{"message":"hi","count":1}`),
+	}
+
+	result, err := service.CreateTask(context.Background(), cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Warnings) != 1 {
+		t.Fatalf("expected 1 warning, got %v", result.Warnings)
+	}
+}
+
+func TestServiceCreateTaskIgnoresCapabilityLookupError(t *testing.T) {
+	fake := &fakeClient{enqueueInfo: &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending}}
+	checker := &fakeCapabilities{err: errors.New("redis down")}
+	service := NewService(fake, zap.NewNop(), WithCapabilityCheck(checker, TypeCheckReject))
+
+	cmd := &CreateTaskCommand{
+		Type:    tasktype.Demo,
+		Payload: []byte(`{"message":"hi","count":1}`),
+	}
+
+	if _, err := service.CreateTask(context.Background(), cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+type fakeRouter struct {
+	suffix string
+}
+
+func (f *fakeRouter) Route(queue string, requires []string) (string, error) {
+	if f.suffix == "" {
+		return "", errors.New("no route")
+	}
+	return queue + "." + f.suffix, nil
+}
+
+func TestServiceCreateTaskRoutesByLabels(t *testing.T) {
+	fake := &fakeClient{enqueueInfo: &asynq.TaskInfo{ID: "id", Queue: "default.gpu", State: asynq.TaskStatePending}}
+	service := NewService(fake, zap.NewNop(), WithLabelRouting(&fakeRouter{suffix: "gpu"}))
+
+	cmd := &CreateTaskCommand{
+		Type:     tasktype.Demo,
+		Payload:  []byte(`{"message":"hi","count":1}`),
+		Requires: []string{"gpu"},
+	}
+
+	if _, err := service.CreateTask(context.Background(), cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fake.enqueueOpts.Queue != "default.gpu" {
+		t.Fatalf("expected queue default.gpu, got %s", fake.enqueueOpts.Queue)
+	}
+}
+
+func TestServiceCreateTaskRejectsUnroutableLabels(t *testing.T) {
+	fake := &fakeClient{enqueueInfo: &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending}}
+
+	for _, service := range []*Service{
+		NewService(fake, zap.NewNop()),
+		NewService(fake, zap.NewNop(), WithLabelRouting(&fakeRouter{})),
+	} {
+		cmd := &CreateTaskCommand{
+			Type:     tasktype.Demo,
+			Payload:  []byte(`{"message":"hi","count":1}`),
+			Requires: []string{"tpu"},
+		}
+		_, err := service.CreateTask(context.Background(), cmd)
+		if !errors.Is(err, apperrors.ErrUnroutableLabels) {
+			t.Fatalf("expected ErrUnroutableLabels, got %v", err)
+		}
+	}
+	if fake.enqueued != 0 {
+		t.Fatalf("expected task not to be enqueued")
+	}
+}
+
+type fakeValidator struct {
+	err   error
+	calls int
+}
+
+func (f *fakeValidator) ValidatePayload(ctx context.Context, taskType string, payload []byte) error {
+	f.calls++
+	return f.err
+}
+
+func TestServiceCreateTaskRejectedBySchema(t *testing.T) {
+	fake := &fakeClient{enqueueInfo: &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending}}
+	validator := &fakeValidator{err: apperrors.NewPayloadSchemaError("demo", 3, "missing property 'message'")}
+	service := NewService(fake, zap.NewNop(), WithPayloadValidator(validator))
+
+	cmd := &CreateTaskCommand{
+		Type:    tasktype.Demo,
+		Payload: []byte(`{"message":"hi","count":1}`),
+	}
+
+	_, err := service.CreateTask(context.Background(), cmd)
+	if !errors.Is(err, apperrors.ErrInvalidPayload) {
+		t.Fatalf("expected ErrInvalidPayload, got %v", err)
+	}
+	var schemaErr *apperrors.PayloadSchemaError
+	if !errors.As(err, &schemaErr) || schemaErr.SchemaVersion != 3 {
+		t.Fatalf("expected schema error citing version 3, got %v", err)
+	}
+	if fake.enqueued != 0 {
+		t.Fatalf("expected task not to be enqueued")
+	}
+}
+
+func TestServiceCreateTaskValidatesDemoPayload(t *testing.T) {
+	limits := Limits{DemoMaxCount: 10, DemoMaxMessageLength: 5}
+	tests := []struct {
+		name    string
+		payload string
+		field   string
+	}{
+		{name: "count omitted", payload: `{"message":"hi"}`},
+		{name: "count at max", payload: `{"message":"hi","count":10,"fail_at_step":10}`},
+		{name: "zero count", payload: `{"message":"hi","count":0}`, field: "count"},
+		{name: "negative count", payload: `{"message":"hi","count":-3}`, field: "count"},
+		{name: "count over max", payload: `{"message":"hi","count":11}`, field: "count"},
+		{name: "message too long", payload: `{"message":"hello!","count":1}`, field: "message"},
+		{name: "fail_at_step after last step", payload: `{"message":"hi","count":2,"fail_at_step":3}`, field: "fail_at_step"},
+		{name: "malformed", payload: `{"count":"many"}`, field: "payload"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeClient{enqueueInfo: &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending}}
+			service := NewService(fake, zap.NewNop(), WithLimits(limits))
+
+			_, err := service.CreateTask(context.Background(), &CreateTaskCommand{
+				Type:    tasktype.Demo,
+				Payload: []byte(tt.payload),
+			})
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("expected payload to be accepted, got %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, apperrors.ErrInvalidPayload) {
+				t.Fatalf("expected ErrInvalidPayload, got %v", err)
+			}
+			var fieldErr *apperrors.PayloadFieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Field != tt.field || fieldErr.TaskType != "demo" {
+				t.Fatalf("expected demo field error on %q, got %v", tt.field, err)
+			}
+			if fake.enqueued != 0 {
+				t.Fatalf("expected task not to be enqueued")
+			}
+		})
+	}
+}
+
+type fakeTracker struct {
+	created map[string]string
+}
+
+func (f *fakeTracker) MarkCreated(ctx context.Context, taskID, queue string) error {
+	f.created[taskID] = queue
+	return nil
+}
+
+func (f *fakeTracker) RecentlyCreated(ctx context.Context, taskID string) (string, bool, error) {
+	queue, ok := f.created[taskID]
+	return queue, ok, nil
+}
+
+func TestServiceCreateThenGetRetriesUntilVisible(t *testing.T) {
+	fake := &fakeClient{
+		enqueueInfo:   &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending},
+		getInfo:       &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending},
+		getInfoMisses: 2,
+	}
+	tracker := &fakeTracker{created: map[string]string{}}
+	service := NewService(fake, zap.NewNop(), WithReadYourWrites(tracker, 3, time.Millisecond))
+
+	cmd := &CreateTaskCommand{Type: tasktype.Demo, Payload: []byte(`{"message":"hi"}`)}
+	if _, err := service.CreateTask(context.Background(), cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := service.GetTask(context.Background(), &GetTaskQuery{TaskID: "id", Queue: "default"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.ID != "id" {
+		t.Fatalf("expected task id 'id', got %s", info.ID)
+	}
+	if fake.getInfoCalls != 3 {
+		t.Fatalf("expected 3 lookups, got %d", fake.getInfoCalls)
+	}
+}
+
+func TestServiceCreateThenGetUsesCreatedQueue(t *testing.T) {
+	fake := &fakeClient{
+		enqueueInfo:   &asynq.TaskInfo{ID: "id", Queue: "high", State: asynq.TaskStatePending},
+		getInfo:       &asynq.TaskInfo{ID: "id", Queue: "high", State: asynq.TaskStatePending},
+		getInfoMisses: 1,
+	}
+	tracker := &fakeTracker{created: map[string]string{}}
+	service := NewService(fake, zap.NewNop(), WithReadYourWrites(tracker, 3, time.Millisecond))
+
+	cmd := &CreateTaskCommand{Type: tasktype.Demo, Payload: []byte(`{"message":"hi"}`), Queue: "high"}
+	if _, err := service.CreateTask(context.Background(), cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := service.GetTask(context.Background(), &GetTaskQuery{TaskID: "id", Queue: "default"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Queue != "high" {
+		t.Fatalf("expected queue high, got %s", info.Queue)
+	}
+	if got := fake.getInfoQueues; len(got) != 2 || got[1] != "high" {
+		t.Fatalf("expected retry against created queue, got %v", got)
+	}
+}
+
+func TestServiceGetTaskNotRecentlyCreatedDoesNotRetry(t *testing.T) {
+	fake := &fakeClient{getInfoErr: asynq.ErrTaskNotFound}
+	tracker := &fakeTracker{created: map[string]string{}}
+	service := NewService(fake, zap.NewNop(), WithReadYourWrites(tracker, 3, time.Millisecond))
+
+	_, err := service.GetTask(context.Background(), &GetTaskQuery{TaskID: "id", Queue: "default"})
+	if !errors.Is(err, apperrors.ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+	if fake.getInfoCalls != 1 {
+		t.Fatalf("expected a single lookup, got %d", fake.getInfoCalls)
+	}
+}
+
+func TestServiceCreateThenGetGivesUpAfterRetries(t *testing.T) {
+	fake := &fakeClient{
+		enqueueInfo:   &asynq.TaskInfo{ID: "id", Queue: "default", State: asynq.TaskStatePending},
+		getInfoMisses: 10,
+	}
+	tracker := &fakeTracker{created: map[string]string{}}
+	service := NewService(fake, zap.NewNop(), WithReadYourWrites(tracker, 3, time.Millisecond))
+
+	cmd := &CreateTaskCommand{Type: tasktype.Demo, Payload: []byte(`{"message":"hi"}`)}
+	if _, err := service.CreateTask(context.Background(), cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err := service.GetTask(context.Background(), &GetTaskQuery{TaskID: "id", Queue: "default"})
+	if !errors.Is(err, apperrors.ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+	if fake.getInfoCalls != 4 {
+		t.Fatalf("expected 4 lookups, got %d", fake.getInfoCalls)
+	}
+}
+
+func TestServiceFlushGroup(t *testing.T) {
+	fake := &fakeClient{queues: []string{"default"}}
+	core, audits := observer.New(zap.InfoLevel)
+	service := NewService(fake, zap.NewNop(), WithAuditLogger(zap.New(core)))
+	ctx := WithAuditActor(context.Background(), AuditActor{APIKey: "ops", RequestID: "req-1"})
+
+	preview, err := service.FlushGroup(ctx, &FlushGroupCommand{Queue: "default", Group: "user:42", DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !preview.DryRun || preview.Affected != 3 || len(preview.TaskIDs) != 3 {
+		t.Fatalf("unexpected dry run result: %+v", preview)
+	}
+	if len(fake.flushed) != 0 || audits.Len() != 0 {
+		t.Fatalf("expected dry run to change and audit nothing, flushed=%v audits=%d", fake.flushed, audits.Len())
+	}
+
+	result, err := service.FlushGroup(ctx, &FlushGroupCommand{Queue: "default", Group: "user:42"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Affected != preview.Affected || len(fake.flushed) != 1 || fake.flushed[0] != "default/user:42" {
+		t.Fatalf("unexpected flush result: %+v flushed=%v", result, fake.flushed)
+	}
+	entries := audits.FilterField(zap.String("action", AuditFlushGroup)).All()
+	if len(entries) != 1 {
+		t.Fatalf("expected one audit entry, got %d", audits.Len())
+	}
+	fields := entries[0].ContextMap()
+	if fields["api_key"] != "ops" || fields["request_id"] != "req-1" || fields["outcome"] != "succeeded" || fields["affected"] != int64(3) {
+		t.Fatalf("unexpected audit fields: %v", fields)
+	}
+
+	if _, err := service.FlushGroup(context.Background(), &FlushGroupCommand{Queue: "default"}); !errors.Is(err, apperrors.ErrGroupNotFound) {
+		t.Fatalf("expected ErrGroupNotFound for empty group, got %v", err)
+	}
+}
+
+func TestServiceGroupsOfMissingQueue(t *testing.T) {
+	fake := &fakeClient{queues: []string{"default"}}
+	service := NewService(fake, zap.NewNop())
+	ctx := context.Background()
+
+	if _, err := service.ListGroups(ctx, &ListGroupsQuery{Queue: "low"}); !errors.Is(err, apperrors.ErrQueueNotFound) {
+		t.Fatalf("expected ErrQueueNotFound from ListGroups, got %v", err)
+	}
+	if _, err := service.FlushGroup(ctx, &FlushGroupCommand{Queue: "low", Group: "g"}); !errors.Is(err, apperrors.ErrQueueNotFound) {
+		t.Fatalf("expected ErrQueueNotFound from FlushGroup, got %v", err)
+	}
+	if len(fake.flushed) != 0 {
+		t.Fatalf("expected nothing flushed, got %v", fake.flushed)
+	}
+}
+
+func TestServiceListTasksFiltersByPayloadFields(t *testing.T) {
+	fake := &fakeClient{listed: []*asynq.TaskInfo{
+		{ID: "a", Queue: "default", Type: "grpc_task", State: asynq.TaskStateRetry, Payload: []byte(`{"service":"llm","data":{"tenant":"acme","tier":2}}`)},
+		{ID: "b", Queue: "default", Type: "grpc_task", State: asynq.TaskStateRetry, Payload: []byte(`{"service":"llm","data":{"tenant":"other","tier":2}}`)},
+		{ID: "c", Queue: "default", Type: "demo", State: asynq.TaskStateRetry, Payload: []byte(`{"message":"hi"}`)},
+		{ID: "d", Queue: "default", Type: "demo", State: asynq.TaskStateRetry, Payload: []byte(`not json`)},
+	}}
+	service := NewService(fake, zap.NewNop())
+
+	tests := []struct {
+		name  string
+		match map[string]string
+		want  []string
+	}{
+		{name: "no filter", want: []string{"a", "b", "c", "d"}},
+		{name: "nested string", match: map[string]string{"data.tenant": "acme"}, want: []string{"a"}},
+		{name: "number", match: map[string]string{"data.tier": "2"}, want: []string{"a", "b"}},
+		{name: "all conditions", match: map[string]string{"service": "llm", "data.tenant": "other"}, want: []string{"b"}},
+		{name: "missing field", match: map[string]string{"data.region": "eu"}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := service.ListTasks(context.Background(), &ListTasksQuery{Queue: "default", Status: "retry", Match: tt.match})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if page.Scanned != 4 {
+				t.Fatalf("expected 4 scanned tasks, got %d", page.Scanned)
+			}
+			var got []string
+			for _, item := range page.Items {
+				got = append(got, item.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	_, err := service.ListTasks(context.Background(), &ListTasksQuery{Queue: "default", Match: map[string]string{"data..tenant": "x"}})
+	if !apperrors.IsValidationError(err) {
+		t.Fatalf("expected validation error for malformed path, got %v", err)
+	}
+}
+
+func TestServiceListTasksFiltersByResultStatus(t *testing.T) {
+	// fake 不区分状态，同一页里混合了 archived 和 completed 任务
+	fake := &fakeClient{listed: []*asynq.TaskInfo{
+		{ID: "exhausted", Queue: "default", Type: "demo", State: asynq.TaskStateArchived, LastErr: "backend unavailable"},
+		{ID: "cancelled", Queue: "default", Type: "demo", State: asynq.TaskStateArchived},
+		{ID: "done", Queue: "default", Type: "demo", State: asynq.TaskStateCompleted, LastErr: "first attempt timed out"},
+	}}
+	service := NewService(fake, zap.NewNop())
+
+	tests := []struct {
+		status       string
+		resultStatus string
+		want         []string
+	}{
+		{status: "archived", want: []string{"exhausted", "cancelled", "done"}},
+		{status: "archived", resultStatus: ResultStatusFailure, want: []string{"exhausted"}},
+		{status: "completed", resultStatus: ResultStatusSuccess, want: []string{"done"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status+"/"+tt.resultStatus, func(t *testing.T) {
+			page, err := service.ListTasks(context.Background(), &ListTasksQuery{Queue: "default", Status: tt.status, ResultStatus: tt.resultStatus})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, item := range page.Items {
+				got = append(got, item.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	page, _ := service.ListTasks(context.Background(), &ListTasksQuery{Queue: "default", Status: "archived", ResultStatus: ResultStatusFailure})
+	if page.Items[0].LastErr != "backend unavailable" {
+		t.Fatalf("expected the failure reason in the listing, got %q", page.Items[0].LastErr)
+	}
+
+	for _, query := range []*ListTasksQuery{
+		{Queue: "default", Status: "retry", ResultStatus: ResultStatusFailure},
+		{Queue: "default", Status: "completed", ResultStatus: "failed"},
+	} {
+		var verr *apperrors.ValidationError
+		if _, err := service.ListTasks(context.Background(), query); !errors.As(err, &verr) || verr.Field != "result_status" {
+			t.Fatalf("expected result_status validation error for %+v, got %v", query, err)
+		}
+	}
+}
+
+func TestServiceListTasksStates(t *testing.T) {
+	fake := &fakeClient{queueInfo: &asynq.QueueInfo{Queue: "default"}}
+	service := NewService(fake, zap.NewNop())
+
+	for _, state := range []string{"pending", "active", "scheduled", "retry", "archived", "completed"} {
+		t.Run(state, func(t *testing.T) {
+			if _, err := service.ListTasks(context.Background(), &ListTasksQuery{Queue: "default", Status: state}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fake.listArgs.state != state {
+				t.Fatalf("listed state %q, want %q", fake.listArgs.state, state)
+			}
+		})
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		page, err := service.ListTasks(context.Background(), &ListTasksQuery{Queue: "default", Page: -1})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if fake.listArgs.state != "active" || fake.listArgs.page != 0 || fake.listArgs.size != 20 {
+			t.Fatalf("unexpected list arguments: %+v", fake.listArgs)
+		}
+		if page.Page != 0 || page.Size != 20 {
+			t.Fatalf("unexpected page metadata: %+v", page)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := service.ListTasks(context.Background(), &ListTasksQuery{Queue: "default", Status: "running"})
+		if !errors.Is(err, apperrors.ErrInvalidTaskState) {
+			t.Fatalf("expected ErrInvalidTaskState, got %v", err)
+		}
+		_, err = service.ListTasks(context.Background(), &ListTasksQuery{Status: "pending"})
+		if !errors.Is(err, apperrors.ErrInvalidQueue) {
+			t.Fatalf("expected ErrInvalidQueue, got %v", err)
+		}
+	})
+}
+
+func TestServiceListTasksPagination(t *testing.T) {
+	listed := make([]*asynq.TaskInfo, 2)
+	for i := range listed {
+		listed[i] = &asynq.TaskInfo{ID: fmt.Sprint(i), Queue: "default", Type: "demo", State: asynq.TaskStateScheduled}
+	}
+	fake := &fakeClient{listed: listed, queueInfo: &asynq.QueueInfo{Queue: "default", Pending: 1, Scheduled: 5}}
+	service := NewService(fake, zap.NewNop())
+
+	page, err := service.ListTasks(context.Background(), &ListTasksQuery{Queue: "default", Status: "scheduled", Page: 1, Size: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !page.TotalExact || page.Total != 5 || !page.HasMore || page.Order != "process_at" || page.Page != 1 || page.Size != 2 {
+		t.Fatalf("unexpected page metadata: %+v", page)
+	}
+
+	page, err = service.ListTasks(context.Background(), &ListTasksQuery{Queue: "default", Status: "scheduled", Page: 2, Size: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if page.HasMore {
+		t.Fatal("expected the third page of five tasks to be the last")
+	}
+
+	// 队列计数不可用时按已翻过的页估计总数，满页视为可能还有下一页
+	fake.queueInfoErr = errors.New("redis down")
+	page, err = service.ListTasks(context.Background(), &ListTasksQuery{Queue: "default", Status: "scheduled", Page: 3, Size: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if page.TotalExact || page.Total != 8 || !page.HasMore {
+		t.Fatalf("expected an estimated total, got %+v", page)
+	}
+}
+
+func TestServiceListTasksPayloadPreview(t *testing.T) {
+	fake := &fakeClient{listed: []*asynq.TaskInfo{
+		{ID: "a", Queue: "default", Type: "grpc_task", State: asynq.TaskStateRetry, Payload: []byte(`{"service":"llm","data":{"Password":"hunter2","prompt":"héllo wörld"}}`)},
+		{ID: "b", Queue: "default", Type: "demo", State: asynq.TaskStateRetry, Payload: []byte(`not json`)},
+	}}
+	core, audits := observer.New(zap.InfoLevel)
+	service := NewService(fake, zap.NewNop(), WithAuditLogger(zap.New(core)), WithPayloadPreview(NewRedactor([]string{"password"}), 40))
+
+	page, err := service.ListTasks(context.Background(), &ListTasksQuery{Queue: "default", Status: "retry", Payload: PayloadPreview})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first := page.Items[0].Payload
+	if first == nil || first.Size != len(fake.listed[0].Payload) || !first.Truncated || !first.Redacted {
+		t.Fatalf("unexpected preview: %+v", first)
+	}
+	if strings.Contains(first.Preview, "hunter2") || len(first.Preview) > 40 || !utf8.ValidString(first.Preview) {
+		t.Fatalf("preview leaked or was cut badly: %q", first.Preview)
+	}
+	if second := page.Items[1].Payload; second.Preview != "" || !second.Redacted {
+		t.Fatalf("non-JSON payload should be withheld, got %+v", second)
+	}
+	if audits.Len() != 0 {
+		t.Fatalf("previews should not be audited, got %d entries", audits.Len())
+	}
+
+	page, err = service.ListTasks(context.Background(), &ListTasksQuery{Queue: "default", Status: "retry", Payload: PayloadFull})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(page.Items[0].Payload.Full) != string(fake.listed[0].Payload) || string(page.Items[1].Payload.Full) != `"not json"` {
+		t.Fatalf("unexpected full payloads: %s %s", page.Items[0].Payload.Full, page.Items[1].Payload.Full)
+	}
+	if entries := audits.FilterField(zap.String("action", AuditReadPayload)).All(); len(entries) != 1 {
+		t.Fatalf("expected one payload read audit entry, got %d", len(entries))
+	}
+
+	_, err = service.ListTasks(context.Background(), &ListTasksQuery{Queue: "default", Payload: "all"})
+	if !apperrors.IsValidationError(err) {
+		t.Fatalf("expected validation error for unknown payload mode, got %v", err)
+	}
+}
+
+func TestServiceGetQueueStatsForSubset(t *testing.T) {
+	fake := &fakeClient{
+		queues:    []string{"default", "critical", "low"},
+		queueInfo: &asynq.QueueInfo{Pending: 4},
+	}
+	service := NewService(fake, zap.NewNop())
+
+	stats, err := service.GetQueueStats(context.Background(), &GetQueueStatsQuery{Queues: []string{"low", " critical", "low"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stats) != 2 || stats[0].Queue != "low" || stats[1].Queue != "critical" || stats[0].Pending != 4 {
+		t.Fatalf("expected low and critical in request order, got %+v", stats)
+	}
+	if fake.allStatsCalls != 0 {
+		t.Fatalf("expected a subset query not to read every queue")
+	}
+
+	_, err = service.GetQueueStats(context.Background(), &GetQueueStatsQuery{Queues: []string{"low", "ghost"}})
+	if !errors.Is(err, apperrors.ErrQueueNotFound) || !strings.Contains(err.Error(), "ghost") {
+		t.Fatalf("expected ErrQueueNotFound naming ghost, got %v", err)
+	}
+
+	stats, err = service.GetQueueStats(context.Background(), &GetQueueStatsQuery{Queues: []string{"low", "ghost"}, SkipUnknown: true})
+	if err != nil || len(stats) != 1 || stats[0].Queue != "low" {
+		t.Fatalf("expected unknown queue to be skipped, got %+v, %v", stats, err)
+	}
+
+	for _, query := range []*GetQueueStatsQuery{
+		{Queues: []string{"low", ""}},
+		{Queue: "low", Queues: []string{"critical"}},
+		{Queues: make([]string, maxStatsQueues+1)},
+	} {
+		var validation *apperrors.ValidationError
+		if _, err := service.GetQueueStats(context.Background(), query); !errors.As(err, &validation) {
+			t.Fatalf("expected validation error for %+v, got %v", query, err)
+		}
+	}
+}
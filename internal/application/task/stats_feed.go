@@ -0,0 +1,180 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+// ErrTooManyStatsSubscribers 队列统计推送的订阅者已达上限
+var ErrTooManyStatsSubscribers = errors.New("too many queue stats subscribers")
+
+// QueueStatsSnapshot 一次推送的队列统计，Seq 从 1 开始递增
+type QueueStatsSnapshot struct {
+	Seq   uint64
+	Stats []asynqqueue.QueueStats
+}
+
+// QueueStatsFeed 定期读取所有队列的统计并广播给订阅者。
+// 所有订阅者共用同一次读取，没有订阅者时停止读取
+type QueueStatsFeed struct {
+	service  *Service
+	interval time.Duration
+	minDelta int
+	maxSubs  int
+	clock    clock.Clock
+
+	mu     sync.Mutex
+	subs   map[chan QueueStatsSnapshot]struct{}
+	last   *QueueStatsSnapshot
+	seq    uint64
+	cancel context.CancelFunc
+}
+
+// NewQueueStatsFeed 创建队列统计推送。每 interval 读取一次统计；
+// minDelta 大于 0 时，只有某个队列的计数变化达到 minDelta 才推送；
+// maxSubscribers 大于 0 时限制同时订阅的数量
+func NewQueueStatsFeed(service *Service, interval time.Duration, minDelta, maxSubscribers int, clk clock.Clock) *QueueStatsFeed {
+	return &QueueStatsFeed{
+		service:  service,
+		interval: interval,
+		minDelta: minDelta,
+		maxSubs:  maxSubscribers,
+		clock:    clock.OrReal(clk),
+		subs:     make(map[chan QueueStatsSnapshot]struct{}),
+	}
+}
+
+// Subscribe 订阅统计快照，ctx 结束时取消订阅并关闭 channel。
+// 已有快照时立即收到最近一次快照；消费过慢时只保留最新的快照。
+// 订阅者已达上限时返回 ErrTooManyStatsSubscribers
+func (f *QueueStatsFeed) Subscribe(ctx context.Context) (<-chan QueueStatsSnapshot, error) {
+	ch := make(chan QueueStatsSnapshot, 1)
+
+	f.mu.Lock()
+	if f.maxSubs > 0 && len(f.subs) >= f.maxSubs {
+		f.mu.Unlock()
+		return nil, ErrTooManyStatsSubscribers
+	}
+	if f.last != nil {
+		ch <- *f.last
+	}
+	f.subs[ch] = struct{}{}
+	if f.cancel == nil {
+		pollCtx, cancel := context.WithCancel(context.Background())
+		f.cancel = cancel
+		go f.run(pollCtx)
+	}
+	f.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		f.unsubscribe(ch)
+	}()
+	return ch, nil
+}
+
+// Subscribers 当前订阅者数量
+func (f *QueueStatsFeed) Subscribers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subs)
+}
+
+func (f *QueueStatsFeed) unsubscribe(ch chan QueueStatsSnapshot) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.subs, ch)
+	close(ch)
+	if len(f.subs) == 0 && f.cancel != nil {
+		f.cancel()
+		f.cancel = nil
+		// 停止读取后缓存的快照会过期，下一个订阅者等待新的读取
+		f.last = nil
+	}
+}
+
+func (f *QueueStatsFeed) run(ctx context.Context) {
+	ticker := f.clock.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		f.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+func (f *QueueStatsFeed) poll(ctx context.Context) {
+	stats, err := f.service.GetQueueStats(ctx, &GetQueueStatsQuery{})
+	if err != nil {
+		f.service.logger.Warn("failed to read queue stats for stream", zap.Error(err))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// 读取期间所有订阅者已离开
+	if ctx.Err() != nil {
+		return
+	}
+	if f.last != nil && !statsChanged(f.last.Stats, stats, f.minDelta) {
+		return
+	}
+
+	f.seq++
+	snapshot := QueueStatsSnapshot{Seq: f.seq, Stats: stats}
+	f.last = &snapshot
+	for ch := range f.subs {
+		// 丢弃订阅者尚未读取的旧快照
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- snapshot
+	}
+}
+
+// statsChanged 队列集合变化或任一计数变化达到 minDelta 时返回 true，minDelta 不大于 0 时总是返回 true
+func statsChanged(prev, next []asynqqueue.QueueStats, minDelta int) bool {
+	if minDelta <= 0 || len(prev) != len(next) {
+		return true
+	}
+
+	byQueue := make(map[string]asynqqueue.QueueStats, len(prev))
+	for _, s := range prev {
+		byQueue[s.Queue] = s
+	}
+	for _, n := range next {
+		p, ok := byQueue[n.Queue]
+		if !ok {
+			return true
+		}
+		for _, d := range []int{
+			n.Pending - p.Pending,
+			n.Active - p.Active,
+			n.Scheduled - p.Scheduled,
+			n.Retry - p.Retry,
+			n.Archived - p.Archived,
+			n.Completed - p.Completed,
+			n.Aggregating - p.Aggregating,
+			n.Groups - p.Groups,
+		} {
+			if d >= minDelta || -d >= minDelta {
+				return true
+			}
+		}
+	}
+	return false
+}
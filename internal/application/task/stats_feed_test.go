@@ -0,0 +1,93 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+func TestQueueStatsFeedSharesReadsBetweenSubscribers(t *testing.T) {
+	fake := &fakeClient{allStats: []asynqqueue.QueueStats{{Queue: "default", Pending: 3}}}
+	clk := clock.NewFake(time.Unix(0, 0))
+	feed := NewQueueStatsFeed(NewService(fake, zap.NewNop()), time.Second, 0, 0, clk)
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	ctxB, cancelB := context.WithCancel(context.Background())
+	a, _ := feed.Subscribe(ctxA)
+	if snap := <-a; snap.Seq != 1 || snap.Stats[0].Pending != 3 {
+		t.Fatalf("unexpected first snapshot: %+v", snap)
+	}
+	// 后加入的订阅者立即收到最近一次快照
+	b, _ := feed.Subscribe(ctxB)
+	if snap := <-b; snap.Seq != 1 {
+		t.Fatalf("expected the cached snapshot, got %+v", snap)
+	}
+
+	clk.Advance(time.Second)
+	if snap := <-a; snap.Seq != 2 {
+		t.Fatalf("expected second snapshot, got %+v", snap)
+	}
+	if snap := <-b; snap.Seq != 2 {
+		t.Fatalf("expected second snapshot, got %+v", snap)
+	}
+	if fake.allStatsCalls != 2 {
+		t.Fatalf("expected one read per interval for all subscribers, got %d", fake.allStatsCalls)
+	}
+
+	cancelA()
+	cancelB()
+	for range a {
+	}
+	for range b {
+	}
+	if n := feed.Subscribers(); n != 0 {
+		t.Fatalf("expected no subscribers left, got %d", n)
+	}
+}
+
+func TestStatsChangedHonorsMinDelta(t *testing.T) {
+	prev := []asynqqueue.QueueStats{{Queue: "default", Pending: 10, Active: 2}}
+
+	if !statsChanged(prev, prev, 0) {
+		t.Fatal("expected every read to be pushed without min_delta")
+	}
+	if statsChanged(prev, []asynqqueue.QueueStats{{Queue: "default", Pending: 14, Active: 2}}, 5) {
+		t.Fatal("expected a change below min_delta to be suppressed")
+	}
+	if !statsChanged(prev, []asynqqueue.QueueStats{{Queue: "default", Pending: 5, Active: 2}}, 5) {
+		t.Fatal("expected a decrease of min_delta to be pushed")
+	}
+	if !statsChanged(prev, []asynqqueue.QueueStats{{Queue: "high"}}, 5) {
+		t.Fatal("expected a different queue set to be pushed")
+	}
+}
+
+func TestQueueStatsFeedLimitsSubscribers(t *testing.T) {
+	fake := &fakeClient{allStats: []asynqqueue.QueueStats{{Queue: "default"}}}
+	feed := NewQueueStatsFeed(NewService(fake, zap.NewNop()), time.Second, 0, 1, clock.NewFake(time.Unix(0, 0)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := feed.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := feed.Subscribe(context.Background()); !errors.Is(err, ErrTooManyStatsSubscribers) {
+		t.Fatalf("expected ErrTooManyStatsSubscribers, got %v", err)
+	}
+
+	// 订阅者离开后名额释放
+	cancel()
+	for range ch {
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := feed.Subscribe(ctx); err != nil {
+		t.Fatalf("expected a free slot after unsubscribe, got %v", err)
+	}
+}
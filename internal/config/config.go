@@ -2,10 +2,17 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/Aixtrade/TaskFlow/pkg/payload"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
 )
 
 type Config struct {
@@ -13,14 +20,35 @@ type Config struct {
 	Server       ServerConfig       `mapstructure:"server"`
 	Redis        RedisConfig        `mapstructure:"redis"`
 	Queues       QueuesConfig       `mapstructure:"queues"`
+	Aging        AgingConfig        `mapstructure:"aging"`
+	QueueCleanup QueueCleanupConfig `mapstructure:"queue_cleanup"`
 	Logging      LoggingConfig      `mapstructure:"logging"`
 	Progress     ProgressConfig     `mapstructure:"progress"`
 	GRPCServices GRPCServicesConfig `mapstructure:"grpc_services"`
+	Discovery    DiscoveryConfig    `mapstructure:"discovery"`
+	BlobStore    BlobStoreConfig    `mapstructure:"blob_store"`
+	Consistency  ConsistencyConfig  `mapstructure:"consistency"`
+	Schemas      SchemasConfig      `mapstructure:"schemas"`
+	Admin        AdminConfig        `mapstructure:"admin"`
+	Maintenance  MaintenanceConfig  `mapstructure:"maintenance"`
+	Redaction    RedactionConfig    `mapstructure:"redaction"`
+	Results      ResultsConfig      `mapstructure:"results"`
+	Routing      RoutingConfig      `mapstructure:"routing"`
+	Metrics      MetricsConfig      `mapstructure:"metrics"`
+	Limits       LimitsConfig       `mapstructure:"limits"`
+	Auth         AuthConfig         `mapstructure:"auth"`
+	TaskIDs      TaskIDsConfig      `mapstructure:"task_ids"`
+
+	Deprecations []DeprecationConfig `mapstructure:"deprecations"`
+
+	OperationTimeouts OperationTimeoutsConfig `mapstructure:"operation_timeouts"`
 }
 
 type AppConfig struct {
 	Name string `mapstructure:"name"`
 	Env  string `mapstructure:"env"`
+	// HotReload 监听配置文件变化并在线应用可热更新的配置
+	HotReload bool `mapstructure:"hot_reload"`
 }
 
 type ServerConfig struct {
@@ -29,13 +57,113 @@ type ServerConfig struct {
 }
 
 type HTTPConfig struct {
-	Host string `mapstructure:"host"`
-	Port int    `mapstructure:"port"`
+	Host           string `mapstructure:"host"`
+	Port           int    `mapstructure:"port"`
+	MaxBodyBytes   int64  `mapstructure:"max_body_bytes"`
+	MaxUploadBytes int64  `mapstructure:"max_upload_bytes"`
+	// MaxBatchSize POST /api/v1/tasks/batch 一次最多包含的任务数
+	MaxBatchSize int `mapstructure:"max_batch_size"`
+	// RequeueWait POST /api/v1/tasks/:id/requeue 等待被取消的任务停止的最长时间
+	RequeueWait time.Duration `mapstructure:"requeue_wait"`
+	// QueueStatsStream 队列统计 SSE 推送
+	QueueStatsStream QueueStatsStreamConfig `mapstructure:"queue_stats_stream"`
+	// SSEMaxLifetime 进度 SSE 连接的最长持续时间，到期后发送 timeout 事件并断开，客户端需重新连接
+	SSEMaxLifetime time.Duration `mapstructure:"sse_max_lifetime"`
+	// MultiProgressStream 多任务进度 SSE 每个连接的推送限制
+	MultiProgressStream MultiProgressStreamConfig `mapstructure:"multi_progress_stream"`
+	// SSEReconnect 进度 SSE 建议客户端使用的重连间隔
+	SSEReconnect SSEReconnectConfig `mapstructure:"sse_reconnect"`
+	// Response JSON 响应的默认格式，客户端可通过 Accept 的 profile 参数按请求覆盖
+	Response ResponseFormatConfig `mapstructure:"response"`
+	// PublicURL 客户端访问 API 的地址（如 https://taskflow.example.com），用于创建响应中的链接；
+	// 为空时由请求的 Host 推断，来自 TrustedProxies 的请求使用 X-Forwarded-Proto/X-Forwarded-Host
+	PublicURL string `mapstructure:"public_url"`
+	// TrustedProxies 可信反向代理的 IP 或 CIDR，只有来自这些地址的请求才采用其 X-Forwarded-* 头部
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	// WebSocketOrigins 允许建立进度 WebSocket 连接的浏览器页面来源（如 https://app.example.com），"*" 允许任意来源；
+	// 未携带 Origin 的非浏览器客户端和与 API 同源的页面总是允许
+	WebSocketOrigins []string `mapstructure:"websocket_origins"`
+}
+
+// TrustedProxyPrefixes 解析 TrustedProxies，单个 IP 视为只含该地址的网段
+func (c HTTPConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(c.TrustedProxies))
+	for i, proxy := range c.TrustedProxies {
+		if addr, err := netip.ParseAddr(proxy); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("server.http.trusted_proxies[%d] must be an IP address or CIDR: %q", i, proxy)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// SSEReconnectConfig 进度 SSE 连接开始时发送的 retry: 字段。订阅容量有上限
+// （progress.subscription_pool_size）时，间隔随容量占用从 Retry 增加到 MaxRetry
+type SSEReconnectConfig struct {
+	// Retry 空闲时的重连间隔，负数表示不发送 retry: 字段
+	Retry time.Duration `mapstructure:"retry"`
+	// MaxRetry 订阅容量用尽时的重连间隔
+	MaxRetry time.Duration `mapstructure:"max_retry"`
+}
+
+// ResponseFormatConfig JSON 响应格式配置
+type ResponseFormatConfig struct {
+	// Casing 字段命名风格：snake（默认）或 camel
+	Casing string `mapstructure:"casing"`
+	// Envelope 为 true 时响应包装为 {"data": ..., "error": ...}
+	Envelope bool `mapstructure:"envelope"`
+}
+
+// QueueStatsStreamConfig 队列统计 SSE 推送配置，所有连接共用同一次读取
+type QueueStatsStreamConfig struct {
+	// Interval 读取队列统计的间隔
+	Interval time.Duration `mapstructure:"interval"`
+	// MinDelta 大于 0 时，只有某个计数变化达到该值才推送
+	MinDelta int `mapstructure:"min_delta"`
+	// Heartbeat 连接上超过该时间没有推送时发送一条 SSE 注释，避免代理断开空闲连接；默认 15s，负数表示不发送
+	Heartbeat time.Duration `mapstructure:"heartbeat"`
+	// MaxSubscribers 同时连接数上限，超出时返回 503；0 表示不限制
+	MaxSubscribers int `mapstructure:"max_subscribers"`
+}
+
+// MultiProgressStreamConfig 多任务进度 SSE 的推送限制
+type MultiProgressStreamConfig struct {
+	// MaxEventsPerSecond 每个连接每秒最多推送的进度事件数，默认 20，负数表示不限制；完成和错误事件不受限制
+	MaxEventsPerSecond float64 `mapstructure:"max_events_per_second"`
+	// StatsInterval 推送 stats 事件的间隔，默认 10s
+	StatsInterval time.Duration `mapstructure:"stats_interval"`
 }
 
 type WorkerConfig struct {
 	Concurrency int                `mapstructure:"concurrency"`
 	Health      WorkerHealthConfig `mapstructure:"health"`
+	// Labels 该 worker 提供的执行环境标签，决定消费哪些标签队列
+	Labels []string `mapstructure:"labels"`
+	// WarmupTimeout 开始消费前等待 Redis 和 gRPC 服务就绪的最长时间
+	WarmupTimeout time.Duration `mapstructure:"warmup_timeout"`
+	// RetryJitter 任务重试延迟的随机浮动比例（0~1），同时失败的任务错开重试；默认 0.2，负数表示不浮动
+	RetryJitter float64 `mapstructure:"retry_jitter"`
+	// WeightsPollInterval 检查 Redis 中队列权重覆盖（PUT /api/v1/queues/weights）的间隔
+	WeightsPollInterval time.Duration `mapstructure:"weights_poll_interval"`
+	// DebugCapture 按任务类型临时开启的调试采集（POST /admin/debug/capture）
+	DebugCapture DebugCaptureConfig `mapstructure:"debug_capture"`
+}
+
+// DebugCaptureConfig 调试采集的限制
+type DebugCaptureConfig struct {
+	// MaxDuration 单次采集的最长时间，默认 1h
+	MaxDuration time.Duration `mapstructure:"max_duration"`
+	// Retention 采集结束后保留采集数据的时间，默认 24h
+	Retention time.Duration `mapstructure:"retention"`
+	// MaxEntries 每次采集最多保留的任务记录数，超出时丢弃最早的记录，默认 1000
+	MaxEntries int `mapstructure:"max_entries"`
+	// MaxFieldBytes 采集的 payload 和结果各自最多保留的字节数，默认 64KiB，负数表示不截断
+	MaxFieldBytes int `mapstructure:"max_field_bytes"`
 }
 
 type RedisConfig struct {
@@ -51,21 +179,416 @@ type QueuesConfig struct {
 	Low      int `mapstructure:"low"`
 }
 
+// AgingConfig 任务老化：worker 定期将在队列中等待过久的 pending 任务移到权重更高的队列，
+// 避免低优先级任务在持续的高优先级负载下饿死
+type AgingConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Interval 检查间隔
+	Interval time.Duration `mapstructure:"interval"`
+	// BatchSize 每条规则每次检查最多移动的任务数
+	BatchSize int `mapstructure:"batch_size"`
+	// Rules 按顺序执行的提升规则
+	Rules []AgingRuleConfig `mapstructure:"rules"`
+}
+
+// AgingRuleConfig 在 From 队列中等待超过 After 的任务移到 To 队列
+type AgingRuleConfig struct {
+	From  string        `mapstructure:"from"`
+	To    string        `mapstructure:"to"`
+	After time.Duration `mapstructure:"after"`
+}
+
+// QueueCleanupConfig 清理过期队列：worker 定期删除不在配置中（queues 及其路由队列）
+// 且持续为空超过 Retention 的队列，并删除这些队列的指标
+type QueueCleanupConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Interval 检查间隔，启用时 API 也按此间隔删除已不存在的队列的入队指标
+	Interval time.Duration `mapstructure:"interval"`
+	// Retention 队列持续为空多久后删除，从 worker 第一次发现它为空开始计算
+	Retention time.Duration `mapstructure:"retention"`
+	// DryRun 只记录将被删除的队列，不删除；每个队列在每个 Retention 内只记录一次
+	DryRun bool `mapstructure:"dry_run"`
+}
+
 type LoggingConfig struct {
-	Level  string `mapstructure:"level"`
+	Level  string          `mapstructure:"level"`
+	Format string          `mapstructure:"format"`
+	Output string          `mapstructure:"output"`
+	Access AccessLogConfig `mapstructure:"access"`
+	Audit  AuditLogConfig  `mapstructure:"audit"`
+	Asynq  AsynqLogConfig  `mapstructure:"asynq"`
+	// SlowEnqueueThreshold API 入队超过该耗时时记录警告（含任务类型、队列和 payload 大小），0 表示不记录
+	SlowEnqueueThreshold time.Duration `mapstructure:"slow_enqueue_threshold"`
+}
+
+// AsynqLogConfig worker 中 asynq 内部日志的级别与重复日志抑制，与应用日志级别相互独立
+type AsynqLogConfig struct {
+	// Level 最低级别: debug, info, warn, error。asynq 的 info 日志多为启动、停止等例行信息
+	Level string `mapstructure:"level"`
+	// SuppressWindow 相同的 warn/error 日志（如 Redis 故障期间的连接错误）在该时间内只输出一次，
+	// 窗口结束后输出一条 "suppressed N similar messages" 汇总
+	SuppressWindow time.Duration `mapstructure:"suppress_window"`
+}
+
+// AccessLogConfig HTTP 访问日志配置，未启用时与应用日志共用同一 logger
+type AccessLogConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Level   string `mapstructure:"level"`
+	Format  string `mapstructure:"format"`
+	Output  string `mapstructure:"output"`
+}
+
+// AuditLogConfig 取消、删除、刷新分组等写操作的审计日志，总是记录；未设置 output 时写入应用日志
+type AuditLogConfig struct {
 	Format string `mapstructure:"format"`
+	Output string `mapstructure:"output"`
 }
 
 type ProgressConfig struct {
+	// Enabled 为 false 时关闭进度功能：worker 不发布进度和完成事件，API 不提供进度接口。未设置时启用
+	Enabled     *bool         `mapstructure:"enabled"`
 	MaxLen      int64         `mapstructure:"max_len"`
 	TTL         time.Duration `mapstructure:"ttl"`
 	ReadTimeout time.Duration `mapstructure:"read_timeout"`
+	// 完成事件发布后进度流的过期时间，不小于 ttl 时不缩短
+	CompletedTTL time.Duration `mapstructure:"completed_ttl"`
+	// Canary 进度链路自检
+	Canary ProgressCanaryConfig `mapstructure:"canary"`
+	// 完成事件写入 Redis 失败后在内存中重试的时间窗口，默认 1m，负数表示不重试
+	CompletionRetryWindow time.Duration `mapstructure:"completion_retry_window"`
+	// 完成事件写入失败时阻塞处理器返回并同步重试，直到送达或超出 completion_retry_window
+	ConfirmCompletion bool `mapstructure:"confirm_completion"`
+	// 空闲订阅的阻塞读取超时上限，不大于 read_timeout 时不做自适应
+	MaxReadTimeout time.Duration `mapstructure:"max_read_timeout"`
+	// 空闲订阅检查任务是否已被删除的间隔
+	WatchdogInterval time.Duration `mapstructure:"watchdog_interval"`
+	// 任务和进度流都不存在持续超过该时长时结束订阅
+	NotFoundGrace time.Duration `mapstructure:"not_found_grace"`
+	// 创建任务后立即发布 pending/scheduled 进度事件
+	PublishOnCreate bool `mapstructure:"publish_on_create"`
+	// 任务结束后若处理器没有发布完成事件，worker 补发通用完成事件
+	PublishOnFinish bool `mapstructure:"publish_on_finish"`
+	// 任务开始执行时发布 0% 的 started 进度。开启后无论 PublishOnFinish 是否开启，都会在最后一次执行结束后补发完成事件
+	PublishOnStart bool `mapstructure:"publish_on_start"`
+	// 进度百分比回退时的处理：空表示不检查，drop 丢弃，clamp 提升为已发布的最大值
+	Monotonic string `mapstructure:"monotonic"`
+	// 任务没有订阅方时中间进度的处理：空表示照常发布，drop 不发布，sample 每个任务每 unwatched_interval 最多发布一条
+	Unwatched string `mapstructure:"unwatched"`
+	// Unwatched 为 sample 时每个任务发布中间进度的最小间隔
+	UnwatchedInterval time.Duration `mapstructure:"unwatched_interval"`
+	// Redis 流操作（XADD/XREAD/XRANGE）超过该耗时时记录警告，0 表示不记录
+	SlowOperationThreshold time.Duration `mapstructure:"slow_operation_threshold"`
+	// API 进程订阅专用 Redis 连接池大小，即可同时进行的任务订阅数
+	SubscriptionPoolSize int `mapstructure:"subscription_pool_size"`
+	// 按任务类型覆盖 max_len、ttl 和 completed_ttl，未设置的项沿用上面的全局值
+	Types map[string]ProgressTypeConfig `mapstructure:"types"`
+	// ReportTokens 下游执行方通过 HTTP 上报进度所用的令牌
+	ReportTokens ProgressReportTokensConfig `mapstructure:"report_tokens"`
+}
+
+// IsEnabled 是否启用进度功能，未配置 enabled 时启用
+func (c *ProgressConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// ProgressReportTokensConfig worker 为 grpc_task 签发任务级令牌，下游凭令牌调用进度上报接口。
+// API 和 worker 须配置相同的 secret
+type ProgressReportTokensConfig struct {
+	// Secret 签名密钥，为空时不签发令牌，上报接口返回 404
+	Secret string `mapstructure:"secret"`
+	// MaxTTL 令牌的最长有效期，任务截止时间更晚时按该值截断
+	MaxTTL time.Duration `mapstructure:"max_ttl"`
+}
+
+// Enabled 是否启用进度上报令牌
+func (c ProgressReportTokensConfig) Enabled() bool {
+	return c.Secret != ""
+}
+
+// ProgressTypeConfig 某个任务类型的进度流保留策略，0 表示沿用全局值
+type ProgressTypeConfig struct {
+	MaxLen       int64         `mapstructure:"max_len"`
+	TTL          time.Duration `mapstructure:"ttl"`
+	CompletedTTL time.Duration `mapstructure:"completed_ttl"`
+}
+
+// TypeOverrides 转换为 progress.StreamOptions.TypeOverrides
+func (c *ProgressConfig) TypeOverrides() map[string]progress.StreamOverride {
+	if len(c.Types) == 0 {
+		return nil
+	}
+	overrides := make(map[string]progress.StreamOverride, len(c.Types))
+	for taskType, t := range c.Types {
+		overrides[taskType] = progress.StreamOverride{
+			MaxLen:       t.MaxLen,
+			TTL:          t.TTL,
+			CompletedTTL: t.CompletedTTL,
+		}
+	}
+	return overrides
+}
+
+// ProgressCanaryConfig 进度链路自检配置：worker 定期向 canary 进度流发布事件，API 定期读取，
+// 两个进程需使用相同的 task_id
+type ProgressCanaryConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// TaskID canary 进度流使用的任务 ID，进度流 key 为 progress:<task_id>
+	TaskID string `mapstructure:"task_id"`
+	// Interval 发布和读取的间隔
+	Interval time.Duration `mapstructure:"interval"`
+	// StaleAfter API 读到的最新 canary 事件早于该时长时判定为 stale
+	StaleAfter time.Duration `mapstructure:"stale_after"`
 }
 
 type WorkerHealthConfig struct {
 	Enabled bool   `mapstructure:"enabled"`
 	Host    string `mapstructure:"host"`
 	Port    int    `mapstructure:"port"`
+	// 读写与空闲连接超时
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
+}
+
+// ConsistencyConfig 创建后立即查询的读己之写配置
+type ConsistencyConfig struct {
+	// Enabled 是否记录刚创建的任务并在查询时重试
+	Enabled bool `mapstructure:"enabled"`
+	// MarkerTTL 创建标记保留时间
+	MarkerTTL time.Duration `mapstructure:"marker_ttl"`
+	// RetryAttempts 查询不到刚创建任务时的重试次数
+	RetryAttempts int `mapstructure:"retry_attempts"`
+	// RetryInterval 重试间隔
+	RetryInterval time.Duration `mapstructure:"retry_interval"`
+}
+
+// RoutingConfig 基于执行环境标签的队列路由配置
+type RoutingConfig struct {
+	// Routes 标签组合到专用队列的路由表，任务进入 "<队列>.<suffix>"
+	Routes []LabelRouteConfig `mapstructure:"routes"`
+}
+
+// LabelRouteConfig 单条标签路由
+type LabelRouteConfig struct {
+	// Requires 任务要求的标签组合（与顺序无关）
+	Requires []string `mapstructure:"requires"`
+	// Suffix 队列后缀，例如 gpu 表示 default 路由到 default.gpu
+	Suffix string `mapstructure:"suffix"`
+}
+
+// labelKey 去重、排序后以 "+" 连接的标签组合，与 routing 包的路由键一致
+func (r LabelRouteConfig) labelKey() string {
+	labels := make([]string, 0, len(r.Requires))
+	for _, l := range r.Requires {
+		if l = strings.ToLower(strings.TrimSpace(l)); l != "" && !slices.Contains(labels, l) {
+			labels = append(labels, l)
+		}
+	}
+	slices.Sort(labels)
+	return strings.Join(labels, "+")
+}
+
+// AuthConfig API 访问控制
+type AuthConfig struct {
+	// APIKeys 配置后 /api/v1 下的请求必须携带有效的 X-API-Key
+	APIKeys []APIKeyConfig `mapstructure:"api_keys"`
+}
+
+// APIKeyConfig 单个 API key 及其可创建任务的范围，列表为空表示不限制
+type APIKeyConfig struct {
+	// Name 用于日志和 /api/v1/me 的名称
+	Name string `mapstructure:"name"`
+	Key  string `mapstructure:"key"`
+	// DefaultQueue 请求未指定队列时使用
+	DefaultQueue string `mapstructure:"default_queue"`
+	// AllowedTypes 允许创建的任务类型
+	AllowedTypes []string `mapstructure:"allowed_types"`
+	// AllowedQueues 允许使用的队列
+	AllowedQueues []string `mapstructure:"allowed_queues"`
+	// AllowedServices grpc_task 允许调用的服务
+	AllowedServices []string `mapstructure:"allowed_services"`
+	// IDPrefix 该 key 创建的任务 ID 前缀，覆盖 task_ids.prefix
+	IDPrefix string `mapstructure:"id_prefix"`
+}
+
+// TaskIDsConfig 任务 ID 的生成方式
+type TaskIDsConfig struct {
+	// Scheme uuid4 或 ulid（按创建时间排序）
+	Scheme string `mapstructure:"scheme"`
+	// Prefix 所有任务 ID 的前缀，例如 "tf_"
+	Prefix string `mapstructure:"prefix"`
+}
+
+// OperationTimeoutsConfig 取消、清理和健康检查等短操作的超时
+type OperationTimeoutsConfig struct {
+	// Cancel gRPC 服务 CancelTask 调用的超时
+	Cancel time.Duration `mapstructure:"cancel"`
+	// Inspector 取消、删除任务及查询队列等基于 asynq inspector 或直接读写 Redis 的操作的超时
+	Inspector time.Duration `mapstructure:"inspector"`
+	// HealthCheck Redis 健康检查和 gRPC 服务 HealthCheck 的超时
+	HealthCheck time.Duration `mapstructure:"health_check"`
+}
+
+// LimitsConfig 创建任务参数的默认值与上限，上限为 0 表示不限制
+type LimitsConfig struct {
+	// DefaultMaxRetries 请求未指定 max_retries 时使用，0 表示沿用任务默认值
+	DefaultMaxRetries int `mapstructure:"default_max_retries"`
+	// DefaultTimeout 请求未指定 timeout 时使用，0 表示沿用任务默认值
+	DefaultTimeout time.Duration `mapstructure:"default_timeout"`
+	// MaxRetriesCap max_retries 上限
+	MaxRetriesCap int `mapstructure:"max_retries_cap"`
+	// MaxRetriesMode 超出上限时的处理: reject, clamp
+	MaxRetriesMode string `mapstructure:"max_retries_mode"`
+	// TimeoutCap timeout 上限
+	TimeoutCap time.Duration `mapstructure:"timeout_cap"`
+	// TimeoutMode 超出上限时的处理: reject, clamp
+	TimeoutMode string `mapstructure:"timeout_mode"`
+	// ProcessAtHorizon process_at 最多可以晚于当前时间多久
+	ProcessAtHorizon time.Duration `mapstructure:"process_at_horizon"`
+	// ProcessAtMode 超出上限时的处理: reject, clamp
+	ProcessAtMode string `mapstructure:"process_at_mode"`
+	// DemoMaxCount demo 任务 count 上限
+	DemoMaxCount int `mapstructure:"demo_max_count"`
+	// DemoMaxMessageLength demo 任务 message 最大字符数
+	DemoMaxMessageLength int `mapstructure:"demo_max_message_length"`
+}
+
+// DeprecationConfig 任务类型或 payload 格式的弃用。命中的创建请求照常入队，
+// 但响应带 Warning 头和 deprecations 字段；到达 cutoff 后拒绝
+type DeprecationConfig struct {
+	// Name 弃用项名称，出现在告警、错误详情和指标标签中
+	Name string `mapstructure:"name"`
+	// Type 弃用的任务类型
+	Type string `mapstructure:"type"`
+	// Migration 代码中注册的 payload 迁移名（tasktype.RegisterMigration）。
+	// 设置后只有迁移判定为旧格式的 payload 命中该项，为空时整个任务类型弃用
+	Migration string `mapstructure:"migration"`
+	// Rewrite 入队前用 Migration 将旧格式 payload 改写为新格式
+	Rewrite bool `mapstructure:"rewrite"`
+	// Message 返回给调用方的说明，如替代的任务类型或格式
+	Message string `mapstructure:"message"`
+	// Cutoff 开始拒绝请求的时间，未设置时一直接受
+	Cutoff time.Time `mapstructure:"cutoff"`
+}
+
+// MetricsConfig worker Prometheus 指标配置
+type MetricsConfig struct {
+	// Enabled 是否在 worker 健康检查端口和 API 端口暴露 /metrics
+	Enabled bool `mapstructure:"enabled"`
+	// Token API 端口 /metrics 的抓取令牌（Authorization: Bearer <token>），未配置时拒绝所有抓取。
+	// worker 健康检查端口不对外暴露，不校验令牌
+	Token string `mapstructure:"token"`
+	// PanicClasses panic 分类规则，按顺序匹配 panic 信息，均不匹配时按 panic 值类型归类
+	PanicClasses []PanicClassConfig `mapstructure:"panic_classes"`
+	// TopGroups 每个队列导出待聚合任务数最多的分组个数
+	TopGroups int `mapstructure:"top_groups"`
+	// MaxLabelValues 任务类型等用户可控标签在每个指标中的最大取值数，超出后归入 "other"；默认 100，负数表示不限制
+	MaxLabelValues int `mapstructure:"max_label_values"`
+	// QueueStats 导出队列统计指标（API 和 worker）
+	QueueStats QueueStatsConfig `mapstructure:"queue_stats"`
+}
+
+// QueueStatsConfig 队列统计指标配置
+type QueueStatsConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// CacheTTL 队列统计的缓存时间，期间的抓取复用同一份数据
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+}
+
+// PanicClassConfig 单条 panic 分类规则
+type PanicClassConfig struct {
+	// Class 指标中的 class 标签值
+	Class string `mapstructure:"class"`
+	// Match panic 信息包含该子串时命中
+	Match string `mapstructure:"match"`
+}
+
+// ResultsConfig 任务结果存储配置
+type ResultsConfig struct {
+	// BlobThreshold 超过该字节数的结果写入对象存储，完成事件只携带引用；0 表示不保存结果
+	BlobThreshold int64 `mapstructure:"blob_threshold"`
+	// Artifacts 任务产出的文件列表
+	Artifacts ArtifactsConfig `mapstructure:"artifacts"`
+}
+
+// ArtifactsConfig 任务产出文件的校验规则与下载方式
+type ArtifactsConfig struct {
+	// MaxCount 单个任务最多的文件数
+	MaxCount int `mapstructure:"max_count"`
+	// AllowedSchemes 文件 uri 允许的 scheme
+	AllowedSchemes []string `mapstructure:"allowed_schemes"`
+	// PresignTTL 对象存储支持预签名时，下载接口重定向地址的有效期
+	PresignTTL time.Duration `mapstructure:"presign_ttl"`
+}
+
+// Policy 转换为 worker 使用的校验规则
+func (c ArtifactsConfig) Policy() payload.ArtifactPolicy {
+	return payload.ArtifactPolicy{
+		MaxCount:       c.MaxCount,
+		AllowedSchemes: c.AllowedSchemes,
+	}
+}
+
+// SchemasConfig 任务 payload 的 JSON Schema 注册表配置
+type SchemasConfig struct {
+	// Enabled 是否在入队前按注册表校验 payload
+	Enabled bool `mapstructure:"enabled"`
+	// RefreshInterval 检查 schema 变更的轮询间隔
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+}
+
+// AdminConfig 管理接口配置
+type AdminConfig struct {
+	// Token 管理接口令牌，留空则禁用管理接口
+	Token string `mapstructure:"token"`
+}
+
+// MaintenanceConfig 只读维护模式：开启后 API 拒绝创建、取消、删除等写请求，查询和进度订阅照常可用。
+// 运行中可通过管理接口切换，切换只作用于收到请求的 API 实例
+type MaintenanceConfig struct {
+	// Enabled 启动时是否处于维护模式
+	Enabled bool `mapstructure:"enabled"`
+	// Message 写请求被拒绝时返回的错误信息，为空时使用默认信息
+	Message string `mapstructure:"message"`
+}
+
+// RedactionConfig 查询接口返回 payload 预览时的脱敏规则
+type RedactionConfig struct {
+	// Fields 需要脱敏的 JSON 字段名，不区分大小写，为空时使用内置列表
+	Fields []string `mapstructure:"fields"`
+	// PreviewBytes 预览的最大字节数
+	PreviewBytes int `mapstructure:"preview_bytes"`
+}
+
+// BlobStoreConfig 对象存储配置
+type BlobStoreConfig struct {
+	// Driver 存储驱动，留空表示不启用: local
+	Driver string `mapstructure:"driver"`
+	// Local 本地文件系统存储配置
+	Local LocalBlobStoreConfig `mapstructure:"local"`
+}
+
+// LocalBlobStoreConfig 本地文件系统存储配置
+type LocalBlobStoreConfig struct {
+	// Root 存储根目录
+	Root string `mapstructure:"root"`
+}
+
+// Enabled 是否配置了对象存储
+func (c *BlobStoreConfig) Enabled() bool {
+	return c.Driver != ""
+}
+
+// DiscoveryConfig worker 能力注册配置
+type DiscoveryConfig struct {
+	// Enabled 是否启用 worker 自注册
+	Enabled bool `mapstructure:"enabled"`
+	// HeartbeatInterval 心跳间隔
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
+	// TTL 能力声明过期时间
+	TTL time.Duration `mapstructure:"ttl"`
+	// TypeCheck 创建任务时的类型检查模式: off, warn, reject
+	TypeCheck string `mapstructure:"type_check"`
 }
 
 // GRPCServicesConfig gRPC 服务配置
@@ -76,6 +599,15 @@ type GRPCServicesConfig struct {
 	Services map[string]GRPCServiceConfig `mapstructure:"services"`
 	// Defaults 默认配置
 	Defaults GRPCServiceConfig `mapstructure:"defaults"`
+	// BudgetMargin 下游超时与任务截止时间之间保留的余量
+	BudgetMargin time.Duration `mapstructure:"budget_margin"`
+	// MinBudget 扣除余量后任务剩余时间低于该值时不调用下游，任务稍后重试
+	MinBudget time.Duration `mapstructure:"min_budget"`
+}
+
+// MinTaskTimeout grpc_task 的最短 timeout。更短的任务每次尝试时剩余时间都低于 min_budget，永远不会调用下游
+func (c *GRPCServicesConfig) MinTaskTimeout() time.Duration {
+	return c.BudgetMargin + c.MinBudget
 }
 
 // GRPCServiceConfig 单个 gRPC 服务配置
@@ -86,13 +618,51 @@ type GRPCServiceConfig struct {
 	Timeout time.Duration `mapstructure:"timeout"`
 	// HealthCheckInterval 健康检查间隔
 	HealthCheckInterval time.Duration `mapstructure:"health_check_interval"`
-	// MaxRetries 最大重试次数
+	// MaxRetries 一元调用（HealthCheck、CancelTask）的最大重试次数，ExecuteTask 不在传输层重试
 	MaxRetries int `mapstructure:"max_retries"`
 	// RetryDelay 重试延迟
 	RetryDelay time.Duration `mapstructure:"retry_delay"`
+	// MaxAttempts 任务在该服务上的总执行次数上限，低于任务的 max_retry+1 时提前停止重试；0 表示不限制
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// Methods 允许调用的方法，为空时不限制
+	Methods []string `mapstructure:"methods"`
+	// DefaultMethod payload 未指定方法时使用的方法
+	DefaultMethod string `mapstructure:"default_method"`
+	// Prewarm 启动时建立连接并等待就绪，后端不可达时 worker 启动失败
+	Prewarm bool `mapstructure:"prewarm"`
+	// PrewarmTimeout 预热等待超时，默认 10s
+	PrewarmTimeout time.Duration `mapstructure:"prewarm_timeout"`
+	// MaxConcurrentStreams 同时执行的任务流上限，0 表示不限制
+	MaxConcurrentStreams int `mapstructure:"max_concurrent_streams"`
+	// StreamWaitTimeout 流已满时等待空闲名额的时间，超时后任务稍后重试
+	StreamWaitTimeout time.Duration `mapstructure:"stream_wait_timeout"`
+	// ResultMode 后端返回多条结果时的处理：single（默认）只保留最后一条，accumulate 合并为 {"chunks": [...]}
+	ResultMode string `mapstructure:"result_mode"`
+	// ResponseMetadata 后端响应头和 trailer 中随完成事件发布的键（如 x-model-version），为空时全部丢弃
+	ResponseMetadata []string `mapstructure:"response_metadata"`
+	// CancelTimeout CancelTask 调用的超时，为 0 时使用 operation_timeouts.cancel
+	CancelTimeout time.Duration `mapstructure:"cancel_timeout"`
+	// HealthCheckTimeout 单次健康检查请求的超时，为 0 时使用 operation_timeouts.health_check
+	HealthCheckTimeout time.Duration `mapstructure:"health_check_timeout"`
+	// HealthCheckRetries 健康检查请求失败后的重试次数，未设置时沿用 max_retries
+	HealthCheckRetries *int `mapstructure:"health_check_retries"`
+	// HealthCheckProtocol 健康检查协议：taskflow（默认）调用 TaskExecutorService.HealthCheck，grpc 调用标准 grpc.health.v1
+	HealthCheckProtocol string `mapstructure:"health_check_protocol"`
+	// HealthCheckService 标准健康检查查询的服务名，一个服务端承载多个服务时指定；为空时检查整个服务端
+	HealthCheckService string `mapstructure:"health_check_service"`
 }
 
 func Load(configPath string) (*Config, error) {
+	v := newViper(configPath)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	return decode(v)
+}
+
+func newViper(configPath string) *viper.Viper {
 	v := viper.New()
 
 	v.SetConfigType("yaml")
@@ -109,10 +679,11 @@ func Load(configPath string) (*Config, error) {
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 
-	if err := v.ReadInConfig(); err != nil {
-		return nil, err
-	}
+	return v
+}
 
+// decode 解析已读取的配置，补全默认值并校验
+func decode(v *viper.Viper) (*Config, error) {
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, err
@@ -127,40 +698,590 @@ func Load(configPath string) (*Config, error) {
 }
 
 func (c *Config) applyDefaults() {
+	if c.Logging.Asynq.Level == "" {
+		c.Logging.Asynq.Level = "warn"
+	}
+	if c.Logging.Asynq.SuppressWindow == 0 {
+		c.Logging.Asynq.SuppressWindow = time.Minute
+	}
+	if c.Aging.Interval == 0 {
+		c.Aging.Interval = 30 * time.Second
+	}
+	if c.Aging.BatchSize == 0 {
+		c.Aging.BatchSize = 100
+	}
+	if c.QueueCleanup.Interval == 0 {
+		c.QueueCleanup.Interval = 10 * time.Minute
+	}
+	if c.QueueCleanup.Retention == 0 {
+		c.QueueCleanup.Retention = 24 * time.Hour
+	}
+	if c.Server.HTTP.MaxBodyBytes == 0 {
+		c.Server.HTTP.MaxBodyBytes = 4 << 20
+	}
+	if c.Server.HTTP.MaxUploadBytes == 0 {
+		c.Server.HTTP.MaxUploadBytes = 32 << 20
+	}
+	if c.Server.HTTP.MaxBatchSize == 0 {
+		c.Server.HTTP.MaxBatchSize = 100
+	}
+	if c.Server.HTTP.RequeueWait == 0 {
+		c.Server.HTTP.RequeueWait = 10 * time.Second
+	}
+	if c.Server.HTTP.QueueStatsStream.Interval == 0 {
+		c.Server.HTTP.QueueStatsStream.Interval = time.Second
+	}
+	if c.Server.HTTP.QueueStatsStream.Heartbeat == 0 {
+		c.Server.HTTP.QueueStatsStream.Heartbeat = 15 * time.Second
+	}
+	if c.Server.HTTP.SSEMaxLifetime == 0 {
+		c.Server.HTTP.SSEMaxLifetime = time.Hour
+	}
+	if c.Server.HTTP.SSEReconnect.Retry == 0 {
+		c.Server.HTTP.SSEReconnect.Retry = 3 * time.Second
+	}
+	if c.Server.HTTP.SSEReconnect.MaxRetry == 0 {
+		c.Server.HTTP.SSEReconnect.MaxRetry = 30 * time.Second
+	}
+	if c.Server.HTTP.MultiProgressStream.MaxEventsPerSecond == 0 {
+		c.Server.HTTP.MultiProgressStream.MaxEventsPerSecond = 20
+	}
+	if c.Server.HTTP.MultiProgressStream.StatsInterval == 0 {
+		c.Server.HTTP.MultiProgressStream.StatsInterval = 10 * time.Second
+	}
+	if c.Server.HTTP.Response.Casing == "" {
+		c.Server.HTTP.Response.Casing = "snake"
+	}
+	if c.Server.Worker.Health.ReadTimeout == 0 {
+		c.Server.Worker.Health.ReadTimeout = 10 * time.Second
+	}
+	if c.Server.Worker.Health.WriteTimeout == 0 {
+		c.Server.Worker.Health.WriteTimeout = 10 * time.Second
+	}
+	if c.Server.Worker.WarmupTimeout == 0 {
+		c.Server.Worker.WarmupTimeout = 30 * time.Second
+	}
+	if c.Server.Worker.RetryJitter == 0 {
+		c.Server.Worker.RetryJitter = 0.2
+	}
+	if c.Server.Worker.WeightsPollInterval == 0 {
+		c.Server.Worker.WeightsPollInterval = 10 * time.Second
+	}
+	if c.Server.Worker.DebugCapture.MaxDuration == 0 {
+		c.Server.Worker.DebugCapture.MaxDuration = time.Hour
+	}
+	if c.Server.Worker.DebugCapture.Retention == 0 {
+		c.Server.Worker.DebugCapture.Retention = 24 * time.Hour
+	}
+	if c.Server.Worker.DebugCapture.MaxEntries == 0 {
+		c.Server.Worker.DebugCapture.MaxEntries = 1000
+	}
+	if c.Server.Worker.DebugCapture.MaxFieldBytes == 0 {
+		c.Server.Worker.DebugCapture.MaxFieldBytes = 64 << 10
+	}
+	if c.Server.Worker.Health.IdleTimeout == 0 {
+		c.Server.Worker.Health.IdleTimeout = 60 * time.Second
+	}
 	if c.Progress.MaxLen == 0 {
 		c.Progress.MaxLen = 1000
 	}
 	if c.Progress.TTL == 0 {
 		c.Progress.TTL = time.Hour
 	}
+	if c.Progress.CompletedTTL == 0 {
+		c.Progress.CompletedTTL = 5 * time.Minute
+	}
+	if c.Progress.Canary.TaskID == "" {
+		c.Progress.Canary.TaskID = "_progress_canary"
+	}
+	if c.Progress.Canary.Interval == 0 {
+		c.Progress.Canary.Interval = 30 * time.Second
+	}
+	if c.Progress.Canary.StaleAfter == 0 {
+		c.Progress.Canary.StaleAfter = 2 * time.Minute
+	}
 	if c.Progress.ReadTimeout == 0 {
 		c.Progress.ReadTimeout = 30 * time.Second
 	}
+	if c.Metrics.TopGroups == 0 {
+		c.Metrics.TopGroups = 10
+	}
+	if c.Metrics.MaxLabelValues == 0 {
+		c.Metrics.MaxLabelValues = 100
+	}
+	if c.Metrics.QueueStats.CacheTTL == 0 {
+		c.Metrics.QueueStats.CacheTTL = 10 * time.Second
+	}
+	if c.Progress.CompletionRetryWindow == 0 {
+		c.Progress.CompletionRetryWindow = time.Minute
+	}
+	if c.Progress.MaxReadTimeout == 0 {
+		c.Progress.MaxReadTimeout = 5 * time.Minute
+	}
+	if c.Progress.UnwatchedInterval == 0 {
+		c.Progress.UnwatchedInterval = 10 * time.Second
+	}
+	if c.Progress.WatchdogInterval == 0 {
+		c.Progress.WatchdogInterval = time.Minute
+	}
+	if c.Progress.NotFoundGrace == 0 {
+		c.Progress.NotFoundGrace = 30 * time.Second
+	}
+	if c.Progress.ReportTokens.MaxTTL == 0 {
+		c.Progress.ReportTokens.MaxTTL = 24 * time.Hour
+	}
+	if c.Progress.SubscriptionPoolSize == 0 {
+		c.Progress.SubscriptionPoolSize = 200
+	}
+	if c.Consistency.MarkerTTL == 0 {
+		c.Consistency.MarkerTTL = 10 * time.Second
+	}
+	if c.Consistency.RetryAttempts == 0 {
+		c.Consistency.RetryAttempts = 3
+	}
+	if c.Consistency.RetryInterval == 0 {
+		c.Consistency.RetryInterval = 50 * time.Millisecond
+	}
+	if c.Schemas.RefreshInterval == 0 {
+		c.Schemas.RefreshInterval = 5 * time.Second
+	}
+	if c.Discovery.HeartbeatInterval == 0 {
+		c.Discovery.HeartbeatInterval = 10 * time.Second
+	}
+	if c.Discovery.TTL == 0 {
+		c.Discovery.TTL = 30 * time.Second
+	}
+	if c.Discovery.TypeCheck == "" {
+		c.Discovery.TypeCheck = "off"
+	}
+	if c.TaskIDs.Scheme == "" {
+		c.TaskIDs.Scheme = "uuid4"
+	}
+	if c.Results.Artifacts.MaxCount == 0 {
+		c.Results.Artifacts.MaxCount = payload.DefaultArtifactPolicy().MaxCount
+	}
+	if c.Results.Artifacts.AllowedSchemes == nil {
+		c.Results.Artifacts.AllowedSchemes = payload.DefaultArtifactPolicy().AllowedSchemes
+	}
+	if c.Results.Artifacts.PresignTTL == 0 {
+		c.Results.Artifacts.PresignTTL = 15 * time.Minute
+	}
+	if c.Limits.MaxRetriesMode == "" {
+		c.Limits.MaxRetriesMode = "reject"
+	}
+	if c.Limits.TimeoutMode == "" {
+		c.Limits.TimeoutMode = "reject"
+	}
+	if c.Limits.ProcessAtMode == "" {
+		c.Limits.ProcessAtMode = "reject"
+	}
+	if c.Limits.DemoMaxCount == 0 {
+		c.Limits.DemoMaxCount = payload.DefaultDemoMaxCount
+	}
+	if c.Limits.DemoMaxMessageLength == 0 {
+		c.Limits.DemoMaxMessageLength = payload.DefaultDemoMaxMessageLength
+	}
+	if c.GRPCServices.BudgetMargin == 0 {
+		c.GRPCServices.BudgetMargin = 500 * time.Millisecond
+	}
+	if c.GRPCServices.MinBudget == 0 {
+		c.GRPCServices.MinBudget = time.Second
+	}
+	if c.OperationTimeouts.Cancel == 0 {
+		c.OperationTimeouts.Cancel = 10 * time.Second
+	}
+	if c.OperationTimeouts.Inspector == 0 {
+		c.OperationTimeouts.Inspector = 5 * time.Second
+	}
+	if c.OperationTimeouts.HealthCheck == 0 {
+		c.OperationTimeouts.HealthCheck = 5 * time.Second
+	}
+	if c.Redaction.PreviewBytes == 0 {
+		c.Redaction.PreviewBytes = 256
+	}
 }
 
 func (c *Config) Validate() error {
 	if c.Server.HTTP.Port <= 0 {
 		return fmt.Errorf("server.http.port must be greater than 0")
 	}
+	if c.Server.HTTP.MaxBodyBytes < 0 {
+		return fmt.Errorf("server.http.max_body_bytes must be greater than or equal to 0")
+	}
+	if c.Server.HTTP.MaxUploadBytes < 0 {
+		return fmt.Errorf("server.http.max_upload_bytes must be greater than or equal to 0")
+	}
+	if c.Server.HTTP.MaxBatchSize < 0 {
+		return fmt.Errorf("server.http.max_batch_size must be greater than or equal to 0")
+	}
+	if c.Server.HTTP.RequeueWait < 0 {
+		return fmt.Errorf("server.http.requeue_wait must be greater than or equal to 0")
+	}
+	if c.Server.HTTP.QueueStatsStream.Interval < 0 || c.Server.HTTP.QueueStatsStream.MinDelta < 0 {
+		return fmt.Errorf("server.http.queue_stats_stream.interval and min_delta must be greater than or equal to 0")
+	}
+	if c.Server.HTTP.QueueStatsStream.MaxSubscribers < 0 {
+		return fmt.Errorf("server.http.queue_stats_stream.max_subscribers must be greater than or equal to 0")
+	}
+	if _, err := c.Server.HTTP.TrustedProxyPrefixes(); err != nil {
+		return err
+	}
+	if c.Server.HTTP.SSEMaxLifetime <= 0 {
+		return fmt.Errorf("server.http.sse_max_lifetime must be greater than 0")
+	}
+	if r := c.Server.HTTP.SSEReconnect; r.Retry > 0 && r.MaxRetry < r.Retry {
+		return fmt.Errorf("server.http.sse_reconnect.max_retry must be greater than or equal to retry")
+	}
+	if c.Server.HTTP.MultiProgressStream.StatsInterval < 0 {
+		return fmt.Errorf("server.http.multi_progress_stream.stats_interval must be greater than or equal to 0")
+	}
+	if casing := c.Server.HTTP.Response.Casing; casing != "snake" && casing != "camel" {
+		return fmt.Errorf("server.http.response.casing must be snake or camel")
+	}
+	if c.Server.HTTP.PublicURL != "" {
+		u, err := url.Parse(c.Server.HTTP.PublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("server.http.public_url must be an absolute http or https URL")
+		}
+	}
+	if !slices.Contains([]string{"debug", "info", "warn", "error"}, c.Logging.Asynq.Level) {
+		return fmt.Errorf("logging.asynq.level must be debug, info, warn or error")
+	}
+	if c.Logging.Asynq.SuppressWindow < 0 {
+		return fmt.Errorf("logging.asynq.suppress_window must be greater than or equal to 0")
+	}
 	if c.Server.Worker.Concurrency <= 0 {
 		return fmt.Errorf("server.worker.concurrency must be greater than 0")
 	}
+	if c.Server.Worker.WarmupTimeout < 0 {
+		return fmt.Errorf("server.worker.warmup_timeout must be greater than or equal to 0")
+	}
+	if c.Server.Worker.RetryJitter > 1 {
+		return fmt.Errorf("server.worker.retry_jitter must be less than or equal to 1")
+	}
+	if c.Server.Worker.WeightsPollInterval < 0 {
+		return fmt.Errorf("server.worker.weights_poll_interval must be greater than or equal to 0")
+	}
+	if dc := c.Server.Worker.DebugCapture; dc.MaxDuration < 0 || dc.Retention < 0 || dc.MaxEntries < 0 {
+		return fmt.Errorf("server.worker.debug_capture values must be greater than or equal to 0")
+	}
 	if c.Queues.Critical <= 0 || c.Queues.High <= 0 || c.Queues.Default <= 0 || c.Queues.Low <= 0 {
 		return fmt.Errorf("queues weights must be greater than 0")
 	}
+	if err := c.Aging.validate(c.Queues.ToMap()); err != nil {
+		return err
+	}
+	if c.QueueCleanup.Enabled && (c.QueueCleanup.Interval <= 0 || c.QueueCleanup.Retention <= 0) {
+		return fmt.Errorf("queue_cleanup.interval and queue_cleanup.retention must be greater than 0")
+	}
 	if c.Progress.MaxLen < 0 {
 		return fmt.Errorf("progress.max_len must be greater than or equal to 0")
 	}
 	if c.Progress.TTL < 0 {
 		return fmt.Errorf("progress.ttl must be greater than or equal to 0")
 	}
+	if c.Progress.CompletedTTL <= 0 {
+		return fmt.Errorf("progress.completed_ttl must be greater than 0")
+	}
+	for taskType, t := range c.Progress.Types {
+		if t.MaxLen < 0 || t.TTL < 0 || t.CompletedTTL < 0 {
+			return fmt.Errorf("progress.types.%s values must be greater than or equal to 0", taskType)
+		}
+	}
+	if !c.Progress.IsEnabled() && (c.Progress.Canary.Enabled || c.Progress.ReportTokens.Enabled()) {
+		return fmt.Errorf("progress.canary and progress.report_tokens require progress.enabled")
+	}
+	if c.Progress.Canary.Enabled {
+		if c.Progress.Canary.Interval <= 0 {
+			return fmt.Errorf("progress.canary.interval must be greater than 0")
+		}
+		if c.Progress.Canary.StaleAfter <= c.Progress.Canary.Interval {
+			return fmt.Errorf("progress.canary.stale_after must be greater than progress.canary.interval")
+		}
+	}
 	if c.Progress.ReadTimeout < 0 {
 		return fmt.Errorf("progress.read_timeout must be greater than or equal to 0")
 	}
+	if c.Progress.MaxReadTimeout < 0 || c.Progress.WatchdogInterval < 0 {
+		return fmt.Errorf("progress.max_read_timeout and progress.watchdog_interval must be greater than or equal to 0")
+	}
+	if c.Progress.NotFoundGrace < 0 {
+		return fmt.Errorf("progress.not_found_grace must be greater than or equal to 0")
+	}
+	if c.Progress.ReportTokens.MaxTTL < 0 {
+		return fmt.Errorf("progress.report_tokens.max_ttl must be greater than or equal to 0")
+	}
+	if c.Progress.SubscriptionPoolSize <= 0 {
+		return fmt.Errorf("progress.subscription_pool_size must be greater than 0")
+	}
+	if c.Logging.SlowEnqueueThreshold < 0 {
+		return fmt.Errorf("logging.slow_enqueue_threshold must be greater than or equal to 0")
+	}
+	if c.Progress.SlowOperationThreshold < 0 {
+		return fmt.Errorf("progress.slow_operation_threshold must be greater than or equal to 0")
+	}
+	if !slices.Contains([]string{"", "drop", "clamp"}, c.Progress.Monotonic) {
+		return fmt.Errorf("progress.monotonic must be empty, drop or clamp")
+	}
+	if !slices.Contains([]string{"", "drop", "sample"}, c.Progress.Unwatched) {
+		return fmt.Errorf("progress.unwatched must be empty, drop or sample")
+	}
+	if c.Progress.UnwatchedInterval < 0 {
+		return fmt.Errorf("progress.unwatched_interval must be greater than or equal to 0")
+	}
+	for name, svc := range c.GRPCServices.Services {
+		if !slices.Contains([]string{"", "single", "accumulate"}, svc.ResultMode) {
+			return fmt.Errorf("grpc_services.services.%s.result_mode must be empty, single or accumulate", name)
+		}
+		if svc.MaxConcurrentStreams < 0 || svc.StreamWaitTimeout < 0 {
+			return fmt.Errorf("grpc_services.services.%s.max_concurrent_streams and stream_wait_timeout must be greater than or equal to 0", name)
+		}
+		if svc.MaxAttempts < 0 {
+			return fmt.Errorf("grpc_services.services.%s.max_attempts must be greater than or equal to 0", name)
+		}
+		if svc.DefaultMethod != "" && len(svc.Methods) > 0 && !slices.Contains(svc.Methods, svc.DefaultMethod) {
+			return fmt.Errorf("grpc_services.services.%s.default_method must be one of methods", name)
+		}
+		if !slices.Contains([]string{"", "taskflow", "grpc"}, svc.HealthCheckProtocol) {
+			return fmt.Errorf("grpc_services.services.%s.health_check_protocol must be empty, taskflow or grpc", name)
+		}
+		if svc.HealthCheckService != "" && svc.HealthCheckProtocol != "grpc" {
+			return fmt.Errorf("grpc_services.services.%s.health_check_service requires health_check_protocol grpc", name)
+		}
+		if svc.HealthCheckTimeout < 0 || (svc.HealthCheckRetries != nil && *svc.HealthCheckRetries < 0) {
+			return fmt.Errorf("grpc_services.services.%s.health_check_timeout and health_check_retries must be greater than or equal to 0", name)
+		}
+		if svc.CancelTimeout < 0 {
+			return fmt.Errorf("grpc_services.services.%s.cancel_timeout must be greater than or equal to 0", name)
+		}
+	}
+	if c.Metrics.TopGroups < 0 {
+		return fmt.Errorf("metrics.top_groups must be greater than or equal to 0")
+	}
+	if c.Metrics.QueueStats.CacheTTL < 0 {
+		return fmt.Errorf("metrics.queue_stats.cache_ttl must be greater than or equal to 0")
+	}
+	for i, rule := range c.Metrics.PanicClasses {
+		if rule.Class == "" || rule.Match == "" {
+			return fmt.Errorf("metrics.panic_classes[%d] requires class and match", i)
+		}
+	}
+	if c.Consistency.RetryAttempts < 0 || c.Consistency.RetryInterval < 0 {
+		return fmt.Errorf("consistency.retry_attempts and consistency.retry_interval must be greater than or equal to 0")
+	}
+	switch c.BlobStore.Driver {
+	case "":
+	case "local":
+		if c.BlobStore.Local.Root == "" {
+			return fmt.Errorf("blob_store.local.root is required when driver is local")
+		}
+	default:
+		return fmt.Errorf("blob_store.driver must be empty or local")
+	}
+	if c.Results.BlobThreshold < 0 {
+		return fmt.Errorf("results.blob_threshold must be greater than or equal to 0")
+	}
+	if c.Redaction.PreviewBytes < 0 {
+		return fmt.Errorf("redaction.preview_bytes must be greater than or equal to 0")
+	}
+	if c.Results.BlobThreshold > 0 && !c.BlobStore.Enabled() {
+		return fmt.Errorf("results.blob_threshold requires blob_store.driver")
+	}
+	if c.Results.Artifacts.MaxCount < 0 {
+		return fmt.Errorf("results.artifacts.max_count must be greater than or equal to 0")
+	}
+	for i, scheme := range c.Results.Artifacts.AllowedSchemes {
+		if scheme == "" || scheme != strings.ToLower(scheme) {
+			return fmt.Errorf("results.artifacts.allowed_schemes[%d] must be a non-empty lowercase scheme", i)
+		}
+	}
+	if c.Results.Artifacts.PresignTTL < 0 {
+		return fmt.Errorf("results.artifacts.presign_ttl must be greater than or equal to 0")
+	}
+	routeKeys := make(map[string]int, len(c.Routing.Routes))
+	for i, route := range c.Routing.Routes {
+		if len(route.Requires) == 0 || route.Suffix == "" {
+			return fmt.Errorf("routing.routes[%d] requires non-empty requires and suffix", i)
+		}
+		// 标签组合与顺序和大小写无关，相同组合的路由会互相覆盖
+		key := route.labelKey()
+		if j, ok := routeKeys[key]; ok {
+			return fmt.Errorf("routing.routes[%d] has the same requires as routing.routes[%d]: %s", i, j, key)
+		}
+		routeKeys[key] = i
+	}
+	if c.Discovery.TTL <= c.Discovery.HeartbeatInterval {
+		return fmt.Errorf("discovery.ttl must be greater than discovery.heartbeat_interval")
+	}
+	switch c.Discovery.TypeCheck {
+	case "off", "warn", "reject":
+	default:
+		return fmt.Errorf("discovery.type_check must be one of off, warn, reject")
+	}
+	if err := c.Limits.validate(); err != nil {
+		return err
+	}
+	if c.GRPCServices.Defaults.MaxAttempts < 0 {
+		return fmt.Errorf("grpc_services.defaults.max_attempts must be greater than or equal to 0")
+	}
+	if c.GRPCServices.BudgetMargin < 0 || c.GRPCServices.MinBudget < 0 {
+		return fmt.Errorf("grpc_services.budget_margin and grpc_services.min_budget must be greater than or equal to 0")
+	}
+	if c.GRPCServices.Enabled {
+		minTimeout := c.GRPCServices.MinTaskTimeout()
+		if c.Limits.DefaultTimeout > 0 && c.Limits.DefaultTimeout < minTimeout {
+			return fmt.Errorf("limits.default_timeout must be at least grpc_services.budget_margin + min_budget (%s), or grpc_task tasks never run", minTimeout)
+		}
+		if c.Limits.TimeoutCap > 0 && c.Limits.TimeoutCap < minTimeout {
+			return fmt.Errorf("limits.timeout_cap must be at least grpc_services.budget_margin + min_budget (%s), or grpc_task tasks never run", minTimeout)
+		}
+	}
+	if c.OperationTimeouts.Cancel < 0 || c.OperationTimeouts.Inspector < 0 || c.OperationTimeouts.HealthCheck < 0 {
+		return fmt.Errorf("operation_timeouts.cancel, operation_timeouts.inspector and operation_timeouts.health_check must be greater than or equal to 0")
+	}
+	if c.TaskIDs.Scheme != "uuid4" && c.TaskIDs.Scheme != "ulid" {
+		return fmt.Errorf("task_ids.scheme must be uuid4 or ulid")
+	}
+	if !validIDPrefix(c.TaskIDs.Prefix) {
+		return fmt.Errorf("task_ids.prefix must be at most %d characters of letters, digits, '_', '-', '.' or ':'", maxIDPrefixLength)
+	}
+	seenKeys := make(map[string]bool, len(c.Auth.APIKeys))
+	for i, key := range c.Auth.APIKeys {
+		if key.Name == "" || key.Key == "" {
+			return fmt.Errorf("auth.api_keys[%d] requires name and key", i)
+		}
+		if seenKeys[key.Key] {
+			return fmt.Errorf("auth.api_keys[%d] reuses the key of another entry", i)
+		}
+		seenKeys[key.Key] = true
+		if !validIDPrefix(key.IDPrefix) {
+			return fmt.Errorf("auth.api_keys[%d].id_prefix must be at most %d characters of letters, digits, '_', '-', '.' or ':'", i, maxIDPrefixLength)
+		}
+		if key.DefaultQueue != "" && len(key.AllowedQueues) > 0 && !slices.Contains(key.AllowedQueues, key.DefaultQueue) {
+			return fmt.Errorf("auth.api_keys[%d].default_queue must be one of allowed_queues", i)
+		}
+		for _, t := range key.AllowedTypes {
+			if !tasktype.Type(t).IsValid() {
+				return fmt.Errorf("auth.api_keys[%d].allowed_types contains unknown task type %q", i, t)
+			}
+		}
+		// allowed_services 只约束 grpc_task，不允许 grpc_task 时配置它没有意义，多半是配置错误
+		if len(key.AllowedServices) > 0 && len(key.AllowedTypes) > 0 && !slices.Contains(key.AllowedTypes, tasktype.GRPCTask.String()) {
+			return fmt.Errorf("auth.api_keys[%d].allowed_services requires allowed_types to include %s", i, tasktype.GRPCTask)
+		}
+	}
+	seenDeprecations := make(map[string]bool, len(c.Deprecations))
+	for i, d := range c.Deprecations {
+		if d.Name == "" {
+			return fmt.Errorf("deprecations[%d].name is required", i)
+		}
+		if seenDeprecations[d.Name] {
+			return fmt.Errorf("deprecations[%d].name %q is duplicated", i, d.Name)
+		}
+		seenDeprecations[d.Name] = true
+		if !tasktype.Type(d.Type).IsValid() {
+			return fmt.Errorf("deprecations[%d].type %q is not a known task type", i, d.Type)
+		}
+		if d.Migration == "" {
+			if d.Rewrite {
+				return fmt.Errorf("deprecations[%d].rewrite requires migration", i)
+			}
+			continue
+		}
+		m, ok := tasktype.LookupMigration(d.Migration)
+		if !ok {
+			return fmt.Errorf("deprecations[%d].migration %q is not registered", i, d.Migration)
+		}
+		if string(m.Type) != d.Type {
+			return fmt.Errorf("deprecations[%d].migration %q applies to %s, not %s", i, d.Migration, m.Type, d.Type)
+		}
+		if d.Rewrite && m.Migrate == nil {
+			return fmt.Errorf("deprecations[%d].migration %q cannot rewrite payloads", i, d.Migration)
+		}
+	}
 	if c.Server.Worker.Health.Enabled {
 		if c.Server.Worker.Health.Port <= 0 {
 			return fmt.Errorf("server.worker.health.port must be greater than 0")
 		}
+		h := c.Server.Worker.Health
+		if h.ReadTimeout < 0 || h.WriteTimeout < 0 || h.IdleTimeout < 0 {
+			return fmt.Errorf("server.worker.health timeouts must be greater than or equal to 0")
+		}
+	}
+	return nil
+}
+
+func (a *AgingConfig) validate(weights map[string]int) error {
+	if !a.Enabled {
+		return nil
+	}
+	if a.Interval <= 0 || a.BatchSize <= 0 {
+		return fmt.Errorf("aging.interval and aging.batch_size must be greater than 0")
+	}
+	if len(a.Rules) == 0 {
+		return fmt.Errorf("aging.rules must not be empty when aging is enabled")
+	}
+	for i, rule := range a.Rules {
+		if rule.From == "" || rule.To == "" || rule.From == rule.To {
+			return fmt.Errorf("aging.rules[%d] requires different from and to queues", i)
+		}
+		if rule.After <= 0 {
+			return fmt.Errorf("aging.rules[%d].after must be greater than 0", i)
+		}
+		// 标签队列没有独立权重，只检查基础队列，避免任务在两个队列间来回移动
+		from, fromOK := weights[rule.From]
+		to, toOK := weights[rule.To]
+		if fromOK && toOK && to <= from {
+			return fmt.Errorf("aging.rules[%d].to queue %s must have a higher weight than %s", i, rule.To, rule.From)
+		}
+	}
+	return nil
+}
+
+// maxIDPrefixLength 任务 ID 前缀的最大长度
+const maxIDPrefixLength = 32
+
+// validIDPrefix 前缀只允许可安全出现在 URL 路径和 Redis key 中的字符
+func validIDPrefix(prefix string) bool {
+	if len(prefix) > maxIDPrefixLength {
+		return false
+	}
+	for _, r := range prefix {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-.:", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *LimitsConfig) validate() error {
+	if l.DefaultMaxRetries < 0 || l.MaxRetriesCap < 0 {
+		return fmt.Errorf("limits.default_max_retries and limits.max_retries_cap must be greater than or equal to 0")
+	}
+	if l.DefaultTimeout < 0 || l.TimeoutCap < 0 || l.ProcessAtHorizon < 0 {
+		return fmt.Errorf("limits.default_timeout, limits.timeout_cap and limits.process_at_horizon must be greater than or equal to 0")
+	}
+	if l.DemoMaxCount < 0 || l.DemoMaxCount > payload.DemoCountCeiling {
+		return fmt.Errorf("limits.demo_max_count must be between 1 and %d", payload.DemoCountCeiling)
+	}
+	if l.DemoMaxMessageLength < 0 {
+		return fmt.Errorf("limits.demo_max_message_length must be greater than 0")
+	}
+	if l.MaxRetriesCap > 0 && l.DefaultMaxRetries > l.MaxRetriesCap {
+		return fmt.Errorf("limits.default_max_retries must not exceed limits.max_retries_cap")
+	}
+	if l.TimeoutCap > 0 && l.DefaultTimeout > l.TimeoutCap {
+		return fmt.Errorf("limits.default_timeout must not exceed limits.timeout_cap")
+	}
+	modes := []struct{ name, mode string }{
+		{"limits.max_retries_mode", l.MaxRetriesMode},
+		{"limits.timeout_mode", l.TimeoutMode},
+		{"limits.process_at_mode", l.ProcessAtMode},
+	}
+	for _, m := range modes {
+		if m.mode != "reject" && m.mode != "clamp" {
+			return fmt.Errorf("%s must be one of reject, clamp", m.name)
+		}
 	}
 	return nil
 }
@@ -173,6 +1294,20 @@ func (c *Config) IsProduction() bool {
 	return c.App.Env == "production"
 }
 
+// KnownQueues 返回 worker 可能消费的全部队列：基础队列以及按 routing.routes 派生的标签队列
+func (c *Config) KnownQueues() []string {
+	base := c.Queues.ToMap()
+	queues := make([]string, 0, len(base)*(len(c.Routing.Routes)+1))
+	for q := range base {
+		queues = append(queues, q)
+		for _, route := range c.Routing.Routes {
+			queues = append(queues, q+"."+route.Suffix)
+		}
+	}
+	slices.Sort(queues)
+	return slices.Compact(queues)
+}
+
 func (c *QueuesConfig) ToMap() map[string]int {
 	return map[string]int{
 		"critical": c.Critical,
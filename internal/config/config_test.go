@@ -0,0 +1,125 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadExample 读取示例配置，作为各项校验测试的基础
+func loadExample(t *testing.T) *Config {
+	t.Helper()
+	cfg, err := Load("../../configs/config.yaml.example")
+	if err != nil {
+		t.Fatalf("load example config: %v", err)
+	}
+	return cfg
+}
+
+func TestValidateRejectsDuplicateRoutes(t *testing.T) {
+	cfg := loadExample(t)
+	cfg.Routing.Routes = []LabelRouteConfig{
+		{Requires: []string{"gpu", "cuda12"}, Suffix: "gpu"},
+		{Requires: []string{"CUDA12", "gpu "}, Suffix: "gpu-new"},
+	}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "routing.routes[1] has the same requires as routing.routes[0]") {
+		t.Fatalf("expected duplicate route error, got %v", err)
+	}
+
+	cfg.Routing.Routes[1].Requires = []string{"gpu"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected distinct routes to be valid, got %v", err)
+	}
+}
+
+func TestTrustedProxyPrefixes(t *testing.T) {
+	cfg := loadExample(t)
+	cfg.Server.HTTP.TrustedProxies = []string{"10.0.0.1", "192.168.0.0/16"}
+	prefixes, err := cfg.Server.HTTP.TrustedProxyPrefixes()
+	if err != nil {
+		t.Fatalf("TrustedProxyPrefixes: %v", err)
+	}
+	if len(prefixes) != 2 || prefixes[0].String() != "10.0.0.1/32" || prefixes[1].String() != "192.168.0.0/16" {
+		t.Fatalf("unexpected prefixes %v", prefixes)
+	}
+
+	cfg.Server.HTTP.TrustedProxies = []string{"proxy.internal"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "server.http.trusted_proxies[0]") {
+		t.Fatalf("expected invalid proxy to be rejected, got %v", err)
+	}
+}
+
+func TestCompletionRetryWindowCanBeDisabled(t *testing.T) {
+	cfg := loadExample(t)
+	cfg.Progress.CompletionRetryWindow = -1
+	cfg.applyDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected a negative window to be valid, got %v", err)
+	}
+	if cfg.Progress.CompletionRetryWindow >= 0 {
+		t.Fatalf("expected a negative window to stay disabled, got %v", cfg.Progress.CompletionRetryWindow)
+	}
+}
+
+func TestRetryJitterCanBeDisabled(t *testing.T) {
+	cfg := loadExample(t)
+	cfg.Server.Worker.RetryJitter = -1
+	cfg.applyDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected a negative jitter to be valid, got %v", err)
+	}
+	if cfg.Server.Worker.RetryJitter >= 0 {
+		t.Fatalf("expected a negative jitter to stay disabled, got %v", cfg.Server.Worker.RetryJitter)
+	}
+
+	cfg.Server.Worker.RetryJitter = 1.5
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "server.worker.retry_jitter") {
+		t.Fatalf("expected a jitter above 1 to be rejected, got %v", err)
+	}
+}
+
+func TestMaxLabelValuesCanBeUnlimited(t *testing.T) {
+	cfg := loadExample(t)
+	cfg.Metrics.MaxLabelValues = -1
+	cfg.applyDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected a negative limit to be valid, got %v", err)
+	}
+	if cfg.Metrics.MaxLabelValues >= 0 {
+		t.Fatalf("expected a negative limit to stay unlimited, got %d", cfg.Metrics.MaxLabelValues)
+	}
+}
+
+func TestDebugCaptureMaxFieldBytesCanBeUnlimited(t *testing.T) {
+	cfg := loadExample(t)
+	cfg.Server.Worker.DebugCapture.MaxFieldBytes = -1
+	cfg.applyDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected a negative limit to be valid, got %v", err)
+	}
+	if cfg.Server.Worker.DebugCapture.MaxFieldBytes >= 0 {
+		t.Fatalf("expected a negative limit to stay unlimited, got %d", cfg.Server.Worker.DebugCapture.MaxFieldBytes)
+	}
+}
+
+func TestValidateRejectsTimeoutsBelowGRPCBudget(t *testing.T) {
+	cfg := loadExample(t)
+	cfg.Limits.TimeoutCap = time.Second
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "limits.timeout_cap") {
+		t.Fatalf("expected a timeout cap below the grpc budget to be rejected, got %v", err)
+	}
+
+	cfg.Limits.TimeoutCap = cfg.GRPCServices.MinTaskTimeout()
+	cfg.Limits.DefaultTimeout = cfg.Limits.TimeoutCap
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected a timeout cap equal to the grpc budget to be valid, got %v", err)
+	}
+
+	cfg.GRPCServices.Enabled = false
+	cfg.Limits.TimeoutCap = time.Second
+	cfg.Limits.DefaultTimeout = time.Second
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected no check with grpc services disabled, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch 监听配置文件变化，每次变更后重新加载并校验，成功时调用 onChange，
+// 文件无法解析或校验失败时调用 onError 并保留当前配置。只有调用方在 onChange 中应用的配置会生效
+func Watch(configPath string, onChange func(*Config), onError func(error)) error {
+	v := newViper(configPath)
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+
+	v.OnConfigChange(func(fsnotify.Event) {
+		// viper 读取失败时只记录日志并保留之前的内容，这里再读一次以便把错误交给 onError
+		if err := v.ReadInConfig(); err != nil {
+			onError(err)
+			return
+		}
+		cfg, err := decode(v)
+		if err != nil {
+			onError(err)
+			return
+		}
+		onChange(cfg)
+	})
+	v.WatchConfig()
+	return nil
+}
+
+// RestartRequired 返回发生变化但未被热更新的顶层配置项，这些变化需要重启才能生效。
+// logging.level 和 logging.asynq.level 总是热更新；reloaded 为调用方已在线应用或不使用的其他顶层配置项
+func RestartRequired(old, updated *Config, reloaded ...string) []string {
+	a, b := *old, *updated
+	a.Logging.Level, b.Logging.Level = "", ""
+	a.Logging.Asynq.Level, b.Logging.Asynq.Level = "", ""
+
+	var changed []string
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	for i := 0; i < va.NumField(); i++ {
+		name := va.Type().Field(i).Tag.Get("mapstructure")
+		if slices.Contains(reloaded, name) {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
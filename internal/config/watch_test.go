@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRestartRequiredSkipsReloadedSections(t *testing.T) {
+	cfg, updated := loadExample(t), loadExample(t)
+	updated.Logging.Level = "debug"
+	updated.Logging.Asynq.Level = "debug"
+	updated.Routing.Routes = append(updated.Routing.Routes, LabelRouteConfig{Requires: []string{"gpu"}, Suffix: "gpu"})
+	updated.Redis.Addr = "redis:6380"
+	updated.Queues.Default++
+
+	if changed := RestartRequired(cfg, updated, "routing"); !slices.Equal(changed, []string{"redis", "queues"}) {
+		t.Fatalf("expected redis and queues to require a restart, got %v", changed)
+	}
+	if changed := RestartRequired(cfg, updated, "routing", "redis", "queues"); len(changed) != 0 {
+		t.Fatalf("expected log levels and reloaded sections to be ignored, got %v", changed)
+	}
+}
+
+// writeConfig 先写临时文件再改名，避免监听方读到写了一半的文件
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("replace config: %v", err)
+	}
+}
+
+func TestWatchReloadsValidChangesOnly(t *testing.T) {
+	example, err := os.ReadFile("../../configs/config.yaml.example")
+	if err != nil {
+		t.Fatalf("read example config: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, string(example))
+
+	changes := make(chan *Config, 4)
+	errs := make(chan error, 4)
+	if err := Watch(path, func(cfg *Config) { changes <- cfg }, func(err error) { errs <- err }); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	updated := strings.Replace(string(example), "level: info", "level: debug", 1)
+	writeConfig(t, path, updated)
+	select {
+	case cfg := <-changes:
+		if cfg.Logging.Level != "debug" {
+			t.Fatalf("expected the reloaded log level, got %q", cfg.Logging.Level)
+		}
+	case err := <-errs:
+		t.Fatalf("unexpected reload error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the config change")
+	}
+
+	// 校验失败的修改交给 onError，不调用 onChange
+	invalid := strings.Replace(updated, "default_timeout: 30m", "default_timeout: 100h", 1)
+	writeConfig(t, path, invalid)
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "default_timeout") {
+			t.Fatalf("expected a validation error, got %v", err)
+		}
+	case cfg := <-changes:
+		t.Fatalf("expected the invalid change to be rejected, got %+v", cfg.Limits)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the validation error")
+	}
+
+	// 无法解析的文件同样交给 onError，不会以之前读到的配置调用 onChange
+	writeConfig(t, path, "logging: [")
+	select {
+	case err := <-errs:
+		if strings.Contains(err.Error(), "default_timeout") {
+			t.Fatalf("expected a parse error, got %v", err)
+		}
+	case cfg := <-changes:
+		t.Fatalf("expected the unreadable file to be rejected, got log level %q", cfg.Logging.Level)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the parse error")
+	}
+}
@@ -0,0 +1,145 @@
+// Package aging 将在队列中等待过久的 pending 任务移到权重更高的队列，
+// 避免低优先级任务在持续的高优先级负载下饿死
+package aging
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/config"
+	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+// Queues 查找和移动 pending 任务
+type Queues interface {
+	// StalePendingTask 返回等待超过 olderThan 的队首 pending 任务，没有时返回 nil
+	StalePendingTask(queue string, olderThan time.Duration) (*asynq.TaskInfo, error)
+	MoveTask(ctx context.Context, info *asynq.TaskInfo, queue string) error
+}
+
+// PromotionRecorder 记录被提升的任务
+type PromotionRecorder interface {
+	ObservePromotion(from, to string)
+}
+
+// Ager 按规则提升等待过久的任务。
+// 移动任务时先从原队列删除，多个 worker 同时运行时同一个任务只会被其中一个移走
+type Ager struct {
+	queues    Queues
+	rules     []config.AgingRuleConfig
+	batchSize int
+
+	recorder PromotionRecorder
+	clock    clock.Clock
+	logger   *zap.Logger
+}
+
+// Option Ager 可选项
+type Option func(*Ager)
+
+// WithRecorder 记录提升次数
+func WithRecorder(r PromotionRecorder) Option {
+	return func(a *Ager) {
+		a.recorder = r
+	}
+}
+
+// WithClock 替换检查间隔使用的时钟，用于测试
+func WithClock(c clock.Clock) Option {
+	return func(a *Ager) {
+		a.clock = c
+	}
+}
+
+// New 根据配置创建 Ager
+func New(queues Queues, cfg *config.AgingConfig, logger *zap.Logger, opts ...Option) *Ager {
+	a := &Ager{
+		queues:    queues,
+		rules:     cfg.Rules,
+		batchSize: cfg.BatchSize,
+		logger:    logger,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.clock = clock.OrReal(a.clock)
+	return a
+}
+
+// Run 立即检查一次，之后每隔 interval 检查，直到 ctx 结束
+func (a *Ager) Run(ctx context.Context, interval time.Duration) {
+	a.Promote(ctx)
+
+	ticker := a.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			a.Promote(ctx)
+		}
+	}
+}
+
+// Promote 按顺序执行每条规则，返回本次移动的任务数
+func (a *Ager) Promote(ctx context.Context) int {
+	promoted := 0
+	for _, rule := range a.rules {
+		promoted += a.promoteRule(ctx, rule)
+	}
+	return promoted
+}
+
+// promoteRule 从队首开始逐个移动等待过久的任务，最多 batchSize 个
+func (a *Ager) promoteRule(ctx context.Context, rule config.AgingRuleConfig) int {
+	promoted := 0
+	for range a.batchSize {
+		if ctx.Err() != nil {
+			break
+		}
+
+		info, err := a.queues.StalePendingTask(rule.From, rule.After)
+		if err != nil {
+			a.logger.Warn("failed to find stale pending task",
+				zap.String("queue", rule.From),
+				zap.Error(err),
+			)
+			break
+		}
+		if info == nil {
+			break
+		}
+
+		if err := a.queues.MoveTask(ctx, info, rule.To); err != nil {
+			// 任务已开始执行或被其他 worker 移走是正常竞争，不需要告警，继续检查新的队首任务
+			if errors.Is(err, asynqqueue.ErrTaskNotPending) {
+				continue
+			}
+			a.logger.Error("failed to promote task",
+				zap.String("task_id", info.ID),
+				zap.String("from", rule.From),
+				zap.String("to", rule.To),
+				zap.Error(err),
+			)
+			// 队首任务移动失败时后面的任务也无法检查，等下一轮
+			break
+		}
+		promoted++
+		if a.recorder != nil {
+			a.recorder.ObservePromotion(rule.From, rule.To)
+		}
+		a.logger.Info("task promoted",
+			zap.String("task_id", info.ID),
+			zap.String("type", info.Type),
+			zap.String("from", rule.From),
+			zap.String("to", rule.To),
+		)
+	}
+	return promoted
+}
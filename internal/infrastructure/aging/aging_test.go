@@ -0,0 +1,106 @@
+package aging
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/config"
+	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+)
+
+// fakeQueues 按队列保存等待过久的任务，移动成功或任务已不在 pending 时从队首移除
+type fakeQueues struct {
+	stale   map[string][]*asynq.TaskInfo
+	moveErr map[string]error
+	moved   []string
+}
+
+func (f *fakeQueues) StalePendingTask(queue string, olderThan time.Duration) (*asynq.TaskInfo, error) {
+	if len(f.stale[queue]) == 0 {
+		return nil, nil
+	}
+	return f.stale[queue][0], nil
+}
+
+func (f *fakeQueues) MoveTask(ctx context.Context, info *asynq.TaskInfo, queue string) error {
+	err := f.moveErr[info.ID]
+	if err == nil || errors.Is(err, asynqqueue.ErrTaskNotPending) {
+		f.stale[info.Queue] = f.stale[info.Queue][1:]
+	}
+	if err != nil {
+		return err
+	}
+	f.moved = append(f.moved, info.ID+"->"+queue)
+	return nil
+}
+
+type fakeRecorder struct {
+	promotions []string
+}
+
+func (f *fakeRecorder) ObservePromotion(from, to string) {
+	f.promotions = append(f.promotions, from+"->"+to)
+}
+
+func TestPromote(t *testing.T) {
+	queues := &fakeQueues{
+		stale: map[string][]*asynq.TaskInfo{
+			"low":     {{ID: "a", Queue: "low"}, {ID: "b", Queue: "low"}, {ID: "d", Queue: "low"}},
+			"default": {{ID: "c", Queue: "default"}},
+		},
+		moveErr: map[string]error{"b": asynqqueue.ErrTaskNotPending},
+	}
+	recorder := &fakeRecorder{}
+	ager := New(queues, &config.AgingConfig{
+		BatchSize: 50,
+		Rules: []config.AgingRuleConfig{
+			{From: "low", To: "default", After: 10 * time.Minute},
+			{From: "default", To: "high", After: 10 * time.Minute},
+		},
+	}, zap.NewNop(), WithRecorder(recorder))
+
+	if n := ager.Promote(context.Background()); n != 3 {
+		t.Fatalf("expected 3 promotions, got %d", n)
+	}
+	if want := []string{"a->default", "d->default", "c->high"}; !slices.Equal(queues.moved, want) {
+		t.Fatalf("expected moves %v, got %v", want, queues.moved)
+	}
+	if len(recorder.promotions) != 3 || recorder.promotions[0] != "low->default" {
+		t.Fatalf("unexpected promotion metrics: %v", recorder.promotions)
+	}
+
+	// 队首任务移动失败时本轮不再检查该队列
+	queues.stale["low"] = []*asynq.TaskInfo{{ID: "e", Queue: "low"}, {ID: "f", Queue: "low"}}
+	queues.moveErr["e"] = errors.New("redis down")
+	if n := ager.Promote(context.Background()); n != 0 {
+		t.Fatalf("expected no promotions after a failed move, got %d", n)
+	}
+	if len(queues.stale["low"]) != 2 {
+		t.Fatalf("expected the queue to be left as is, got %v", queues.stale["low"])
+	}
+}
+
+func TestPromoteStopsAtBatchSize(t *testing.T) {
+	queues := &fakeQueues{
+		stale: map[string][]*asynq.TaskInfo{
+			"low": {{ID: "a", Queue: "low"}, {ID: "b", Queue: "low"}, {ID: "c", Queue: "low"}},
+		},
+	}
+	ager := New(queues, &config.AgingConfig{
+		BatchSize: 2,
+		Rules:     []config.AgingRuleConfig{{From: "low", To: "default", After: time.Minute}},
+	}, zap.NewNop())
+
+	if n := ager.Promote(context.Background()); n != 2 {
+		t.Fatalf("expected the batch size to limit promotions, got %d", n)
+	}
+	if len(queues.stale["low"]) != 1 || queues.stale["low"][0].ID != "c" {
+		t.Fatalf("expected the newest task to wait for the next check, got %v", queues.stale["low"])
+	}
+}
@@ -0,0 +1,54 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Aixtrade/TaskFlow/internal/config"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("blob not found")
+
+// Object 描述一个已存储的对象
+type Object struct {
+	Key         string `json:"key"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size"`
+	URI         string `json:"uri"`
+}
+
+// Store 对象存储抽象，用于保存任务输入文件与大结果
+type Store interface {
+	// Put 写入对象，返回对象描述
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (*Object, error)
+	// Open 读取对象，调用方负责关闭返回的 reader
+	Open(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner 由支持预签名的对象存储（S3 兼容存储等）实现，
+// 下载任务产出的文件时重定向到预签名地址，不经 API 转发内容
+type Presigner interface {
+	// PresignGet 返回在 ttl 内可直接下载 key 的地址
+	PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// ResultKey 任务结果在对象存储中的 key
+func ResultKey(taskID string) string {
+	return "results/" + taskID + ".json"
+}
+
+// New 根据配置创建对象存储
+func New(cfg *config.BlobStoreConfig) (Store, error) {
+	switch cfg.Driver {
+	case "local":
+		return NewLocalStore(cfg.Local.Root)
+	default:
+		return nil, fmt.Errorf("unsupported blob store driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,130 @@
+package blobstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const metaSuffix = ".meta.json"
+
+// LocalStore 基于本地文件系统的对象存储
+// 适用于单机部署或挂载共享卷（NFS 等）的场景
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore 创建本地对象存储
+func NewLocalStore(root string) (*LocalStore, error) {
+	if root == "" {
+		return nil, fmt.Errorf("root is required")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob root: %w", err)
+	}
+	return &LocalStore{root: root}, nil
+}
+
+// Put 写入对象
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (*Object, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob dir: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免读到写了一半的对象
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	obj := &Object{
+		Key:         key,
+		ContentType: contentType,
+		Size:        size,
+		URI:         "file://" + path,
+	}
+
+	meta, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path+metaSuffix, meta, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write blob metadata: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to commit blob: %w", err)
+	}
+
+	return obj, nil
+}
+
+// Open 读取对象
+func (s *LocalStore) Open(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+
+	obj := &Object{Key: key, URI: "file://" + path}
+	if meta, err := os.ReadFile(path + metaSuffix); err == nil {
+		_ = json.Unmarshal(meta, obj)
+	}
+	if stat, err := f.Stat(); err == nil {
+		obj.Size = stat.Size()
+	}
+
+	return f, obj, nil
+}
+
+// Delete 删除对象
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	for _, p := range []string{path, path + metaSuffix} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// path 将 key 映射为 root 下的文件路径，拒绝越界访问
+func (s *LocalStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.HasSuffix(clean, metaSuffix) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.root, clean), nil
+}
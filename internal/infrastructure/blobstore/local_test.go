@@ -0,0 +1,61 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	obj, err := store.Put(ctx, "uploads/a/report.csv", strings.NewReader("a,b\n1,2\n"), "text/csv")
+	if err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	if obj.Size != 8 {
+		t.Fatalf("expected size 8, got %d", obj.Size)
+	}
+
+	r, got, err := store.Open(ctx, "uploads/a/report.csv")
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "a,b\n1,2\n" {
+		t.Fatalf("unexpected content %q", data)
+	}
+	if got.ContentType != "text/csv" {
+		t.Fatalf("expected content type text/csv, got %q", got.ContentType)
+	}
+
+	if err := store.Delete(ctx, "uploads/a/report.csv"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, _, err := store.Open(ctx, "uploads/a/report.csv"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocalStore(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	obj, err := store.Put(context.Background(), "../../etc/passwd", strings.NewReader("x"), "")
+	if err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	if !strings.HasPrefix(obj.URI, "file://"+root) {
+		t.Fatalf("expected object under root, got %s", obj.URI)
+	}
+}
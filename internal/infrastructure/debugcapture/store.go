@@ -0,0 +1,197 @@
+package debugcapture
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// activeKey 采集的有序集合，分数为结束时间（Unix 毫秒），worker 定期读取其中未结束的采集
+	activeKey = "taskflow:debug:captures"
+	keyPrefix = "taskflow:debug:capture:"
+)
+
+// ErrNotFound 采集不存在或已过保留期
+var ErrNotFound = errors.New("debug capture not found")
+
+// Options 采集时额外执行的动作
+type Options struct {
+	// VerboseLogging 匹配任务的日志使用 debug 级别输出
+	VerboseLogging bool `json:"verbose_logging"`
+	// CapturePayloads 记录任务的 payload 和结果
+	CapturePayloads bool `json:"capture_payloads"`
+	// GRPCSizes 记录 grpc_task 请求和结果的字节数
+	GRPCSizes bool `json:"grpc_sizes"`
+}
+
+// Capture 一次按任务类型开启的调试采集
+type Capture struct {
+	ID        string    `json:"id"`
+	TaskType  string    `json:"task_type"`
+	Options   Options   `json:"options"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// DeleteAt 采集数据自动删除的时间
+	DeleteAt time.Time `json:"delete_at"`
+}
+
+// Active 采集在 now 时是否仍在进行
+func (c Capture) Active(now time.Time) bool {
+	return now.Before(c.ExpiresAt)
+}
+
+// Entry 采集期间一次任务执行的记录
+type Entry struct {
+	TaskID     string    `json:"task_id"`
+	Queue      string    `json:"queue,omitempty"`
+	Worker     string    `json:"worker,omitempty"`
+	Retry      int       `json:"retry"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+
+	PayloadSize      int    `json:"payload_size"`
+	Payload          string `json:"payload,omitempty"`
+	PayloadTruncated bool   `json:"payload_truncated,omitempty"`
+	Result           string `json:"result,omitempty"`
+	ResultTruncated  bool   `json:"result_truncated,omitempty"`
+
+	GRPCRequestBytes *int `json:"grpc_request_bytes,omitempty"`
+	GRPCResultBytes  *int `json:"grpc_result_bytes,omitempty"`
+}
+
+// Store 在 Redis 中保存采集及其记录，过了 DeleteAt 后自动删除
+type Store struct {
+	redis      *redis.Client
+	maxEntries int
+}
+
+// NewStore maxEntries 为每次采集保留的记录上限，0 表示不限制
+func NewStore(redisClient *redis.Client, maxEntries int) *Store {
+	return &Store{redis: redisClient, maxEntries: maxEntries}
+}
+
+func captureKey(id string) string {
+	return keyPrefix + id
+}
+
+func entriesKey(id string) string {
+	return keyPrefix + id + ":entries"
+}
+
+// NewID 生成采集 ID
+func NewID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Create 保存采集，DeleteAt 之后采集和记录一起过期
+func (s *Store) Create(ctx context.Context, c Capture) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	pipe := s.redis.TxPipeline()
+	pipe.Set(ctx, captureKey(c.ID), data, 0)
+	pipe.ExpireAt(ctx, captureKey(c.ID), c.DeleteAt)
+	pipe.ZAdd(ctx, activeKey, redis.Z{Score: float64(c.ExpiresAt.UnixMilli()), Member: c.ID})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save debug capture: %w", err)
+	}
+	return nil
+}
+
+// Active 返回 now 时仍在进行的采集，并清理已结束的索引
+func (s *Store) Active(ctx context.Context, now time.Time) ([]Capture, error) {
+	nowMs := strconv.FormatInt(now.UnixMilli(), 10)
+	if err := s.redis.ZRemRangeByScore(ctx, activeKey, "-inf", nowMs).Err(); err != nil {
+		return nil, fmt.Errorf("failed to prune debug captures: %w", err)
+	}
+	ids, err := s.redis.ZRangeByScore(ctx, activeKey, &redis.ZRangeBy{Min: "(" + nowMs, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list debug captures: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = captureKey(id)
+	}
+	values, err := s.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read debug captures: %w", err)
+	}
+	captures := make([]Capture, 0, len(values))
+	for _, v := range values {
+		raw, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var c Capture
+		if err := json.Unmarshal([]byte(raw), &c); err != nil {
+			continue
+		}
+		captures = append(captures, c)
+	}
+	return captures, nil
+}
+
+// Get 返回采集及其记录，记录按写入顺序排列
+func (s *Store) Get(ctx context.Context, id string) (Capture, []Entry, error) {
+	pipe := s.redis.Pipeline()
+	captureCmd := pipe.Get(ctx, captureKey(id))
+	entriesCmd := pipe.LRange(ctx, entriesKey(id), 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return Capture{}, nil, fmt.Errorf("failed to read debug capture: %w", err)
+	}
+
+	raw, err := captureCmd.Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Capture{}, nil, ErrNotFound
+	}
+	var c Capture
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return Capture{}, nil, fmt.Errorf("invalid debug capture %s: %w", id, err)
+	}
+
+	entries := make([]Entry, 0, len(entriesCmd.Val()))
+	for _, item := range entriesCmd.Val() {
+		var e Entry
+		if err := json.Unmarshal([]byte(item), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return c, entries, nil
+}
+
+// Append 追加一条记录，超过上限时丢弃最早的记录
+func (s *Store) Append(ctx context.Context, c Capture, e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	key := entriesKey(c.ID)
+	pipe := s.redis.TxPipeline()
+	pipe.RPush(ctx, key, data)
+	if s.maxEntries > 0 {
+		pipe.LTrim(ctx, key, int64(-s.maxEntries), -1)
+	}
+	pipe.ExpireAt(ctx, key, c.DeleteAt)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to append debug capture entry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package debugcapture
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestStore(t *testing.T, maxEntries int) (*Store, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return NewStore(client, maxEntries), mr
+}
+
+func TestStoreKeepsLatestEntriesUntilDeleteAt(t *testing.T) {
+	store, mr := newTestStore(t, 2)
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0).UTC()
+	mr.SetTime(now)
+
+	c := Capture{ID: "c1", TaskType: "demo", CreatedAt: now, ExpiresAt: now.Add(time.Minute), DeleteAt: now.Add(time.Hour)}
+	if err := store.Create(ctx, c); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	for _, id := range []string{"t1", "t2", "t3"} {
+		if err := store.Append(ctx, c, Entry{TaskID: id, Status: "completed"}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	got, entries, err := store.Get(ctx, "c1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.TaskType != "demo" || !got.ExpiresAt.Equal(c.ExpiresAt) {
+		t.Fatalf("unexpected capture %+v", got)
+	}
+	if len(entries) != 2 || entries[0].TaskID != "t2" || entries[1].TaskID != "t3" {
+		t.Fatalf("expected the two latest entries in order, got %+v", entries)
+	}
+
+	if _, _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown capture, got %v", err)
+	}
+
+	// 过了 DeleteAt 后采集和记录一起过期
+	mr.FastForward(time.Hour)
+	if _, _, err := store.Get(ctx, "c1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after DeleteAt, got %v", err)
+	}
+	if mr.Exists(entriesKey("c1")) {
+		t.Fatal("expected entries to expire with the capture")
+	}
+}
+
+func TestStoreActivePrunesEndedCaptures(t *testing.T) {
+	store, mr := newTestStore(t, 0)
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0).UTC()
+	mr.SetTime(now)
+
+	for _, c := range []Capture{
+		{ID: "short", TaskType: "demo", ExpiresAt: now.Add(time.Minute), DeleteAt: now.Add(time.Hour)},
+		{ID: "long", TaskType: "demo", ExpiresAt: now.Add(10 * time.Minute), DeleteAt: now.Add(time.Hour)},
+	} {
+		if err := store.Create(ctx, c); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	active, err := store.Active(ctx, now)
+	if err != nil {
+		t.Fatalf("Active: %v", err)
+	}
+	if len(active) != 2 {
+		t.Fatalf("expected both captures to be active, got %+v", active)
+	}
+
+	active, err = store.Active(ctx, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Active: %v", err)
+	}
+	if len(active) != 1 || active[0].ID != "long" {
+		t.Fatalf("expected only the long capture, got %+v", active)
+	}
+	if members, _ := mr.ZMembers(activeKey); len(members) != 1 {
+		t.Fatalf("expected the ended capture to be pruned from the index, got %v", members)
+	}
+	// 已结束的采集仍可按 ID 读取，直到 DeleteAt
+	if _, _, err := store.Get(ctx, "short"); err != nil {
+		t.Fatalf("expected the ended capture to stay readable, got %v", err)
+	}
+}
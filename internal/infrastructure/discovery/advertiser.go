@@ -0,0 +1,159 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+// Advertiser 负责在 Redis 中注册 worker 能力并定期续约
+type Advertiser struct {
+	redis   *redis.Client
+	logger  *zap.Logger
+	options Options
+	clock   clock.Clock
+
+	mu     sync.Mutex
+	info   WorkerInfo
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewAdvertiser 创建能力声明器
+func NewAdvertiser(redisClient *redis.Client, logger *zap.Logger, info WorkerInfo, opts ...Options) *Advertiser {
+	opt := DefaultOptions()
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	clk := clock.OrReal(opt.Clock)
+
+	if info.Hostname == "" {
+		info.Hostname, _ = os.Hostname()
+	}
+	if info.StartedAt.IsZero() {
+		info.StartedAt = clk.Now().UTC()
+	}
+
+	return &Advertiser{
+		redis:   redisClient,
+		logger:  logger,
+		options: opt,
+		clock:   clk,
+		info:    info,
+	}
+}
+
+// Info 返回当前声明的 worker 信息
+func (a *Advertiser) Info() WorkerInfo {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.info
+}
+
+// UpdateQueues 更新消费的队列权重及其覆盖版本，并立即刷新声明
+func (a *Advertiser) UpdateQueues(ctx context.Context, queues map[string]int, weightsVersion int64) error {
+	a.mu.Lock()
+	a.info.Queues = queues
+	a.info.WeightsVersion = weightsVersion
+	a.mu.Unlock()
+	return a.register(ctx)
+}
+
+// Start 同步完成首次注册，并在后台启动心跳
+// 首次注册失败时仍会启动心跳，由后续心跳完成注册
+func (a *Advertiser) Start(ctx context.Context) error {
+	err := a.register(ctx)
+
+	loopCtx, cancel := context.WithCancel(context.Background())
+	a.mu.Lock()
+	a.cancel = cancel
+	a.done = make(chan struct{})
+	a.mu.Unlock()
+
+	go a.heartbeatLoop(loopCtx)
+
+	if err != nil {
+		return err
+	}
+
+	a.logger.Info("worker registered",
+		zap.String("instance_id", a.info.InstanceID),
+		zap.Strings("types", a.info.Types),
+	)
+
+	return nil
+}
+
+// Stop 停止心跳并注销 worker
+func (a *Advertiser) Stop(ctx context.Context) error {
+	a.mu.Lock()
+	cancel, done := a.cancel, a.done
+	a.cancel = nil
+	a.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+
+	pipe := a.redis.TxPipeline()
+	pipe.Del(ctx, WorkerKey(a.info.InstanceID))
+	pipe.SRem(ctx, workersSetKey, a.info.InstanceID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to deregister worker: %w", err)
+	}
+
+	a.logger.Info("worker deregistered", zap.String("instance_id", a.info.InstanceID))
+	return nil
+}
+
+// heartbeatLoop 定期刷新能力声明
+func (a *Advertiser) heartbeatLoop(ctx context.Context) {
+	defer close(a.done)
+
+	ticker := a.clock.NewTicker(a.options.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if err := a.register(ctx); err != nil && ctx.Err() == nil {
+				a.logger.Warn("worker heartbeat failed",
+					zap.String("instance_id", a.info.InstanceID),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+}
+
+// register 写入能力声明并设置 TTL
+func (a *Advertiser) register(ctx context.Context) error {
+	a.mu.Lock()
+	a.info.HeartbeatAt = a.clock.Now().UTC()
+	info := a.info
+	a.mu.Unlock()
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to marshal worker info: %w", err)
+	}
+
+	pipe := a.redis.TxPipeline()
+	pipe.Set(ctx, WorkerKey(info.InstanceID), data, a.options.TTL)
+	pipe.SAdd(ctx, workersSetKey, info.InstanceID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to register worker: %w", err)
+	}
+
+	return nil
+}
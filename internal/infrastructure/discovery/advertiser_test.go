@@ -0,0 +1,111 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+func newTestAdvertiser(t *testing.T, fake *clock.Fake) (*miniredis.Miniredis, *Advertiser, *Directory) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	adv := NewAdvertiser(client, zap.NewNop(), WorkerInfo{InstanceID: "w1", Types: []string{"demo"}}, Options{
+		HeartbeatInterval: 10 * time.Second,
+		TTL:               30 * time.Second,
+		Clock:             fake,
+	})
+	return mr, adv, NewDirectory(client, zap.NewNop())
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAdvertiserHeartbeatKeepsWorkerRegistered(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0).UTC()
+	fake := clock.NewFake(start)
+	mr, adv, dir := newTestAdvertiser(t, fake)
+	ctx := context.Background()
+
+	if err := adv.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	workers, err := dir.Workers(ctx)
+	if err != nil {
+		t.Fatalf("Workers: %v", err)
+	}
+	if len(workers) != 1 || workers[0].InstanceID != "w1" || !workers[0].HeartbeatAt.Equal(start) {
+		t.Fatalf("expected w1 to be registered at start, got %+v", workers)
+	}
+	if ok, _ := dir.SupportsType(ctx, "demo"); !ok {
+		t.Fatal("expected the registered type to be supported")
+	}
+
+	// 每次心跳把声明的 TTL 续满，心跳持续时声明不会过期
+	fake.BlockUntil(1)
+	for i := 1; i <= 3; i++ {
+		mr.FastForward(10 * time.Second)
+		fake.Advance(10 * time.Second)
+		beat := start.Add(time.Duration(i) * 10 * time.Second)
+		waitFor(t, func() bool {
+			workers, err := dir.Workers(ctx)
+			return err == nil && len(workers) == 1 && workers[0].HeartbeatAt.Equal(beat)
+		})
+	}
+	if ttl := mr.TTL(WorkerKey("w1")); ttl != 30*time.Second {
+		t.Fatalf("expected the heartbeat to renew the TTL, got %s", ttl)
+	}
+
+	if err := adv.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if workers, _ := dir.Workers(ctx); len(workers) != 0 {
+		t.Fatalf("expected no workers after Stop, got %+v", workers)
+	}
+	if mr.Exists(workersSetKey) {
+		t.Fatal("expected Stop to remove the worker from the set")
+	}
+}
+
+func TestDirectoryPrunesExpiredWorkers(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1_700_000_000, 0))
+	mr, adv, dir := newTestAdvertiser(t, fake)
+	ctx := context.Background()
+
+	if err := adv.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { _ = adv.Stop(ctx) })
+
+	// 心跳停止（进程崩溃）后声明在 TTL 到期时过期，读取时从集合中清理
+	mr.FastForward(30 * time.Second)
+	workers, err := dir.Workers(ctx)
+	if err != nil {
+		t.Fatalf("Workers: %v", err)
+	}
+	if len(workers) != 0 {
+		t.Fatalf("expected the expired worker to be gone, got %+v", workers)
+	}
+	if mr.Exists(workersSetKey) {
+		t.Fatal("expected the expired worker to be pruned from the set")
+	}
+	if ok, _ := dir.SupportsType(ctx, "demo"); ok {
+		t.Fatal("expected no support from an expired worker")
+	}
+}
@@ -0,0 +1,125 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Directory 读取存活 worker 的能力声明
+type Directory struct {
+	redis  *redis.Client
+	logger *zap.Logger
+}
+
+// NewDirectory 创建能力目录
+func NewDirectory(redisClient *redis.Client, logger *zap.Logger) *Directory {
+	return &Directory{
+		redis:  redisClient,
+		logger: logger,
+	}
+}
+
+// Capabilities 汇总所有存活 worker 的能力
+type Capabilities struct {
+	Workers []WorkerInfo   `json:"workers"`
+	Types   map[string]int `json:"types"`  // 任务类型 -> 支持该类型的 worker 数
+	Queues  map[string]int `json:"queues"` // 队列 -> 消费该队列的 worker 数
+	Labels  map[string]int `json:"labels"` // 标签组合 -> 可服务的 worker 数
+}
+
+// Workers 返回所有存活的 worker，并清理已过期的成员
+func (d *Directory) Workers(ctx context.Context) ([]WorkerInfo, error) {
+	ids, err := d.redis.SMembers(ctx, workersSetKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []WorkerInfo{}, nil
+	}
+	sort.Strings(ids)
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = WorkerKey(id)
+	}
+
+	values, err := d.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	workers := make([]WorkerInfo, 0, len(values))
+	var stale []interface{}
+	for i, v := range values {
+		raw, ok := v.(string)
+		if !ok {
+			// 心跳 key 已过期（进程崩溃或未正常注销）
+			stale = append(stale, ids[i])
+			continue
+		}
+
+		var info WorkerInfo
+		if err := json.Unmarshal([]byte(raw), &info); err != nil {
+			d.logger.Warn("invalid worker info",
+				zap.String("instance_id", ids[i]),
+				zap.Error(err),
+			)
+			continue
+		}
+		workers = append(workers, info)
+	}
+
+	if len(stale) > 0 {
+		if err := d.redis.SRem(ctx, workersSetKey, stale...).Err(); err != nil {
+			d.logger.Debug("failed to prune stale workers", zap.Error(err))
+		}
+	}
+
+	return workers, nil
+}
+
+// Capabilities 返回能力汇总
+func (d *Directory) Capabilities(ctx context.Context) (*Capabilities, error) {
+	workers, err := d.Workers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	caps := &Capabilities{
+		Workers: workers,
+		Types:   make(map[string]int),
+		Queues:  make(map[string]int),
+		Labels:  make(map[string]int),
+	}
+	for _, w := range workers {
+		for _, t := range w.Types {
+			caps.Types[t]++
+		}
+		for q := range w.Queues {
+			caps.Queues[q]++
+		}
+		for _, l := range w.Labels {
+			caps.Labels[l]++
+		}
+	}
+
+	return caps, nil
+}
+
+// SupportsType 判断是否至少有一个存活 worker 声明了该任务类型
+func (d *Directory) SupportsType(ctx context.Context, taskType string) (bool, error) {
+	workers, err := d.Workers(ctx)
+	if err != nil {
+		return false, err
+	}
+	for i := range workers {
+		if workers[i].SupportsType(taskType) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,71 @@
+package discovery
+
+import (
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+const (
+	// workersSetKey 记录所有已注册 worker 实例 ID 的集合
+	workersSetKey = "taskflow:workers"
+	// workerKeyPrefix 单个 worker 能力声明的 key 前缀
+	workerKeyPrefix = "taskflow:workers:"
+)
+
+// WorkerInfo 描述单个 worker 实例的能力声明
+type WorkerInfo struct {
+	InstanceID  string         `json:"instance_id"`
+	Hostname    string         `json:"hostname"`
+	Version     string         `json:"version"`
+	Types       []string       `json:"types"`
+	Queues      map[string]int `json:"queues"`
+	Labels      []string       `json:"labels,omitempty"` // 可服务的标签组合，例如 gpu、gpu+highmem
+	Concurrency int            `json:"concurrency"`
+	// WeightsVersion Queues 对应的权重覆盖版本（见 WeightStore），使用配置中的权重时为 0
+	WeightsVersion int64     `json:"weights_version,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+	HeartbeatAt    time.Time `json:"heartbeat_at"`
+}
+
+// SupportsType 判断该 worker 是否声明了指定任务类型
+func (w *WorkerInfo) SupportsType(taskType string) bool {
+	for _, t := range w.Types {
+		if t == taskType {
+			return true
+		}
+	}
+	return false
+}
+
+// Options 注册与心跳配置
+type Options struct {
+	HeartbeatInterval time.Duration // 心跳间隔
+	TTL               time.Duration // 声明过期时间，进程崩溃后自动失效
+	Clock             clock.Clock   // 心跳和时间戳使用的时钟，为空时使用真实时钟
+}
+
+// DefaultOptions 返回默认配置
+func DefaultOptions() Options {
+	return Options{
+		HeartbeatInterval: 10 * time.Second,
+		TTL:               30 * time.Second,
+	}
+}
+
+// WorkerKey 生成 worker 能力声明的 Redis key
+func WorkerKey(instanceID string) string {
+	return workerKeyPrefix + instanceID
+}
+
+// NewInstanceID 生成 worker 实例 ID（主机名 + 随机后缀）
+func NewInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "worker"
+	}
+	return host + "-" + uuid.New().String()[:8]
+}
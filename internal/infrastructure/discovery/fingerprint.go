@@ -0,0 +1,160 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Aixtrade/TaskFlow/internal/config"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+const (
+	// fingerprintKey worker 写入的配置指纹，固定保存在 FingerprintDB
+	fingerprintKey = "taskflow:config_fingerprint"
+	// FingerprintDB 指纹所在的 DB。API 和 worker 配置的 DB 不一致时，只有固定的位置才能读到对方的指纹
+	FingerprintDB = 0
+)
+
+// 指纹比较的结果
+const (
+	FingerprintOK       = "ok"
+	FingerprintMismatch = "mismatch"
+	// FingerprintUnknown 没有 worker 写入过指纹，或读取失败
+	FingerprintUnknown = "unknown"
+)
+
+// Fingerprint 决定 API 和 worker 能否看到同一批任务和进度的配置，两侧不一致时任务或进度会"消失"
+type Fingerprint struct {
+	// RedisDB 进度流等数据所在的 DB
+	RedisDB int `json:"redis_db"`
+	// AsynqDB 任务队列所在的 DB
+	AsynqDB        int    `json:"asynq_db"`
+	ProgressPrefix string `json:"progress_prefix"`
+	ProgressMaxLen int64  `json:"progress_max_len"`
+	ProgressTTL    string `json:"progress_ttl"`
+	CompletedTTL   string `json:"completed_ttl"`
+	// ProgressDisabled 关闭了进度功能（progress.enabled: false），一侧关闭时另一侧的进度接口或事件不会有对端
+	ProgressDisabled bool `json:"progress_disabled,omitempty"`
+
+	// 以下字段说明指纹来源，不参与比较
+	InstanceID string    `json:"instance_id,omitempty"`
+	WrittenAt  time.Time `json:"written_at,omitempty"`
+}
+
+// NewFingerprint 由配置生成指纹
+func NewFingerprint(cfg *config.Config) Fingerprint {
+	return Fingerprint{
+		RedisDB:        cfg.Redis.DB,
+		AsynqDB:        cfg.Redis.DB,
+		ProgressPrefix: progress.StreamKey(""),
+		ProgressMaxLen: cfg.Progress.MaxLen,
+		ProgressTTL:    cfg.Progress.TTL.String(),
+		CompletedTTL:   cfg.Progress.CompletedTTL.String(),
+
+		ProgressDisabled: !cfg.Progress.IsEnabled(),
+	}
+}
+
+// Diff 返回 API 的指纹 f 与 worker 的指纹 other 不一致的字段，格式为 "字段: api=值 worker=值"
+func (f Fingerprint) Diff(other Fingerprint) []string {
+	var diffs []string
+	add := func(field string, mine, theirs any) {
+		if mine != theirs {
+			diffs = append(diffs, fmt.Sprintf("%s: api=%v worker=%v", field, mine, theirs))
+		}
+	}
+	add("redis_db", f.RedisDB, other.RedisDB)
+	add("asynq_db", f.AsynqDB, other.AsynqDB)
+	add("progress_prefix", f.ProgressPrefix, other.ProgressPrefix)
+	add("progress_max_len", f.ProgressMaxLen, other.ProgressMaxLen)
+	add("progress_ttl", f.ProgressTTL, other.ProgressTTL)
+	add("completed_ttl", f.CompletedTTL, other.CompletedTTL)
+	add("progress_disabled", f.ProgressDisabled, other.ProgressDisabled)
+	return diffs
+}
+
+// NewFingerprintClient 连接 cfg 所指 Redis 的 FingerprintDB，调用方负责关闭
+func NewFingerprintClient(cfg *config.RedisConfig) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       FingerprintDB,
+	})
+}
+
+// FingerprintStore 读写配置指纹，redisClient 必须连接 FingerprintDB
+type FingerprintStore struct {
+	redis *redis.Client
+}
+
+func NewFingerprintStore(redisClient *redis.Client) *FingerprintStore {
+	return &FingerprintStore{redis: redisClient}
+}
+
+// Write 保存 worker 的指纹，多个 worker 时保留最后启动的一个
+func (s *FingerprintStore) Write(ctx context.Context, f Fingerprint) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := s.redis.Set(ctx, fingerprintKey, data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to write config fingerprint: %w", err)
+	}
+	return nil
+}
+
+// Read 读取 worker 的指纹，没有时返回 false
+func (s *FingerprintStore) Read(ctx context.Context) (Fingerprint, bool, error) {
+	data, err := s.redis.Get(ctx, fingerprintKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Fingerprint{}, false, nil
+	}
+	if err != nil {
+		return Fingerprint{}, false, fmt.Errorf("failed to read config fingerprint: %w", err)
+	}
+	var f Fingerprint
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Fingerprint{}, false, fmt.Errorf("invalid config fingerprint: %w", err)
+	}
+	return f, true, nil
+}
+
+// FingerprintStatus API 与 worker 指纹的比较结果
+type FingerprintStatus struct {
+	Status     string       `json:"status"`
+	Mismatches []string     `json:"mismatches,omitempty"`
+	Worker     *Fingerprint `json:"worker,omitempty"`
+	Error      string       `json:"error,omitempty"`
+}
+
+// FingerprintCheck 将 API 的配置与 worker 写入的指纹比较
+type FingerprintCheck struct {
+	store    *FingerprintStore
+	expected Fingerprint
+}
+
+func NewFingerprintCheck(store *FingerprintStore, expected Fingerprint) *FingerprintCheck {
+	return &FingerprintCheck{store: store, expected: expected}
+}
+
+// Check 读取 worker 的指纹并比较
+func (c *FingerprintCheck) Check(ctx context.Context) FingerprintStatus {
+	worker, ok, err := c.store.Read(ctx)
+	if err != nil {
+		return FingerprintStatus{Status: FingerprintUnknown, Error: err.Error()}
+	}
+	if !ok {
+		return FingerprintStatus{Status: FingerprintUnknown, Error: "no worker has written a config fingerprint"}
+	}
+	status := FingerprintStatus{Status: FingerprintOK, Worker: &worker}
+	if diffs := c.expected.Diff(worker); len(diffs) > 0 {
+		status.Status = FingerprintMismatch
+		status.Mismatches = diffs
+	}
+	return status
+}
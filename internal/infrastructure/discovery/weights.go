@@ -0,0 +1,114 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// queueWeightsKey 运行中覆盖的队列权重（队列 -> 权重），不存在时使用各 worker 的配置
+	queueWeightsKey = "taskflow:queue_weights"
+	// queueWeightsVersionKey 每次修改覆盖时递增，worker 在心跳中报告已应用的版本
+	queueWeightsVersionKey = "taskflow:queue_weights:version"
+	// weightsRestartLockKey 同一时间只允许一个 worker 为应用新权重重启消费
+	weightsRestartLockKey = "taskflow:queue_weights:restart_lock"
+)
+
+// QueueWeights 队列权重覆盖
+type QueueWeights struct {
+	// Version 从未设置过时为 0
+	Version int64 `json:"version"`
+	// Weights 为空表示没有覆盖，使用配置中的权重
+	Weights map[string]int `json:"weights"`
+}
+
+// WeightStore 在 Redis 中读写队列权重覆盖，并协调 worker 依次重启
+type WeightStore struct {
+	redis *redis.Client
+}
+
+// NewWeightStore 创建权重覆盖存储
+func NewWeightStore(redisClient *redis.Client) *WeightStore {
+	return &WeightStore{redis: redisClient}
+}
+
+// Get 读取当前的权重覆盖
+func (s *WeightStore) Get(ctx context.Context) (QueueWeights, error) {
+	pipe := s.redis.Pipeline()
+	weightsCmd := pipe.HGetAll(ctx, queueWeightsKey)
+	versionCmd := pipe.Get(ctx, queueWeightsVersionKey)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return QueueWeights{}, fmt.Errorf("failed to read queue weights: %w", err)
+	}
+
+	var result QueueWeights
+	if v, err := versionCmd.Int64(); err == nil {
+		result.Version = v
+	}
+	raw := weightsCmd.Val()
+	if len(raw) == 0 {
+		return result, nil
+	}
+	result.Weights = make(map[string]int, len(raw))
+	for queue, value := range raw {
+		weight, err := strconv.Atoi(value)
+		if err != nil {
+			return QueueWeights{}, fmt.Errorf("invalid weight %q for queue %s", value, queue)
+		}
+		result.Weights[queue] = weight
+	}
+	return result, nil
+}
+
+// Set 替换权重覆盖并递增版本，weights 为空时清除覆盖（worker 恢复配置中的权重）
+func (s *WeightStore) Set(ctx context.Context, weights map[string]int) (QueueWeights, error) {
+	pipe := s.redis.TxPipeline()
+	pipe.Del(ctx, queueWeightsKey)
+	if len(weights) > 0 {
+		values := make(map[string]interface{}, len(weights))
+		for queue, weight := range weights {
+			values[queue] = weight
+		}
+		pipe.HSet(ctx, queueWeightsKey, values)
+	}
+	versionCmd := pipe.Incr(ctx, queueWeightsVersionKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return QueueWeights{}, fmt.Errorf("failed to write queue weights: %w", err)
+	}
+
+	result := QueueWeights{Version: versionCmd.Val()}
+	if len(weights) > 0 {
+		result.Weights = weights
+	}
+	return result, nil
+}
+
+// AcquireRestart 尝试取得重启许可，ttl 后自动释放，避免持有者崩溃后其他 worker 一直无法重启
+func (s *WeightStore) AcquireRestart(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	ok, err := s.redis.SetNX(ctx, weightsRestartLockKey, owner, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire restart lock: %w", err)
+	}
+	return ok, nil
+}
+
+// releaseScript 只释放自己持有的许可
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// ReleaseRestart 释放 owner 持有的重启许可
+func (s *WeightStore) ReleaseRestart(ctx context.Context, owner string) error {
+	if err := releaseScript.Run(ctx, s.redis, []string{weightsRestartLockKey}, owner).Err(); err != nil {
+		return fmt.Errorf("failed to release restart lock: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package discovery
+
+import (
+	"context"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestWeightStore(t *testing.T) (*miniredis.Miniredis, *WeightStore) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, NewWeightStore(client)
+}
+
+func TestWeightStoreSetAndGet(t *testing.T) {
+	_, store := newTestWeightStore(t)
+	ctx := context.Background()
+
+	got, err := store.Get(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Version != 0 || got.Weights != nil {
+		t.Fatalf("expected no override before the first set, got %+v", got)
+	}
+
+	want := map[string]int{"critical": 10, "default": 2}
+	set, err := store.Set(ctx, want)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err = store.Get(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if set.Version != 1 || got.Version != 1 || !maps.Equal(got.Weights, want) {
+		t.Fatalf("expected version 1 with %v, got set %+v and get %+v", want, set, got)
+	}
+
+	// 替换而不是合并，空覆盖清除权重但版本仍递增
+	if _, err := store.Set(ctx, map[string]int{"low": 3}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ = store.Get(ctx); !maps.Equal(got.Weights, map[string]int{"low": 3}) {
+		t.Fatalf("expected the override to be replaced, got %v", got.Weights)
+	}
+	if _, err := store.Set(ctx, map[string]int{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ = store.Get(ctx); got.Version != 3 || got.Weights != nil {
+		t.Fatalf("expected a cleared override at version 3, got %+v", got)
+	}
+}
+
+func TestWeightStoreGetRejectsInvalidWeight(t *testing.T) {
+	mr, store := newTestWeightStore(t)
+	mr.HSet(queueWeightsKey, "default", "heavy")
+
+	if _, err := store.Get(context.Background()); err == nil {
+		t.Fatal("expected an error for a non-numeric weight")
+	}
+}
+
+func TestWeightStoreRestartLock(t *testing.T) {
+	mr, store := newTestWeightStore(t)
+	ctx := context.Background()
+
+	if ok, err := store.AcquireRestart(ctx, "w1", time.Minute); err != nil || !ok {
+		t.Fatalf("expected w1 to acquire the lock, got %v, %v", ok, err)
+	}
+	if ok, _ := store.AcquireRestart(ctx, "w2", time.Minute); ok {
+		t.Fatal("expected w2 to wait while w1 holds the lock")
+	}
+
+	// 只有持有者能释放
+	if err := store.ReleaseRestart(ctx, "w2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !mr.Exists(weightsRestartLockKey) {
+		t.Fatal("expected the lock to survive a release by another worker")
+	}
+	if err := store.ReleaseRestart(ctx, "w1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok, _ := store.AcquireRestart(ctx, "w2", time.Minute); !ok {
+		t.Fatal("expected w2 to acquire the released lock")
+	}
+
+	// 持有者崩溃时许可过期
+	mr.FastForward(time.Minute)
+	if ok, _ := store.AcquireRestart(ctx, "w3", time.Minute); !ok {
+		t.Fatal("expected the lock to expire")
+	}
+}
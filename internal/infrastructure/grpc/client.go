@@ -4,16 +4,21 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	pb "github.com/Aixtrade/TaskFlow/api/proto/grpc_task/v1"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
@@ -22,8 +27,58 @@ type ClientConfig struct {
 	Address             string        `mapstructure:"address"`
 	Timeout             time.Duration `mapstructure:"timeout"`
 	HealthCheckInterval time.Duration `mapstructure:"health_check_interval"`
-	MaxRetries          int           `mapstructure:"max_retries"`
-	RetryDelay          time.Duration `mapstructure:"retry_delay"`
+	// MaxRetries 一元调用（健康检查、CancelTask）的重试次数，ExecuteTask 流不在传输层重试
+	MaxRetries int           `mapstructure:"max_retries"`
+	RetryDelay time.Duration `mapstructure:"retry_delay"`
+	// MaxAttempts 任务在该服务上的总执行次数（首次执行加 asynq 重试），用尽后不再重试；0 表示不限制
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// Methods 允许调用的方法，为空时不限制
+	Methods []string `mapstructure:"methods"`
+	// DefaultMethod payload 未指定方法时使用的方法
+	DefaultMethod string `mapstructure:"default_method"`
+	// Prewarm 创建时立即建立连接并等待就绪，连接失败则创建失败
+	Prewarm bool `mapstructure:"prewarm"`
+	// PrewarmTimeout 预热等待连接就绪的超时时间
+	PrewarmTimeout time.Duration `mapstructure:"prewarm_timeout"`
+	// MaxConcurrentStreams 同时进行的 ExecuteTask 流上限，应不大于服务端 HTTP/2 的并发流上限；0 表示不限制
+	MaxConcurrentStreams int `mapstructure:"max_concurrent_streams"`
+	// StreamWaitTimeout 流已满时等待空闲名额的时间，超时返回 ErrStreamsSaturated；0 表示立即返回
+	StreamWaitTimeout time.Duration `mapstructure:"stream_wait_timeout"`
+	// ResultMode 后端返回多条 Result 消息时的处理方式，见 ResultModeSingle 和 ResultModeAccumulate
+	ResultMode string `mapstructure:"result_mode"`
+	// CancelTimeout CancelTask 调用的超时时间
+	CancelTimeout time.Duration `mapstructure:"cancel_timeout"`
+	// HealthCheckTimeout 单次健康检查请求的超时时间，重试时每次请求单独计算
+	HealthCheckTimeout time.Duration `mapstructure:"health_check_timeout"`
+	// HealthCheckRetries 健康检查请求失败后的重试次数，为空时沿用 MaxRetries
+	HealthCheckRetries *int `mapstructure:"health_check_retries"`
+	// HealthCheckProtocol 健康检查协议，见 HealthProtocolTaskFlow 和 HealthProtocolGRPC，为空时为 taskflow
+	HealthCheckProtocol string `mapstructure:"health_check_protocol"`
+	// HealthCheckService 标准健康检查查询的服务名，为空时检查整个服务端；仅 grpc 协议使用
+	HealthCheckService string `mapstructure:"health_check_service"`
+	// ResponseMetadata ExecuteTask 响应头和 trailer 中需要保留的键，不区分大小写；为空时全部丢弃
+	ResponseMetadata []string `mapstructure:"response_metadata"`
+}
+
+const (
+	// ResultModeSingle 只保留最后一条 Result 消息（默认）
+	ResultModeSingle = "single"
+	// ResultModeAccumulate 按接收顺序合并所有 Result 消息，见 MergeResults
+	ResultModeAccumulate = "accumulate"
+)
+
+// ResolveMethod 补全默认方法并校验方法是否在允许列表中
+func (c ClientConfig) ResolveMethod(method string) (string, error) {
+	if method == "" {
+		method = c.DefaultMethod
+	}
+	if len(c.Methods) == 0 || slices.Contains(c.Methods, method) {
+		return method, nil
+	}
+	if method == "" {
+		return "", fmt.Errorf("method is required, allowed: %s", strings.Join(c.Methods, ", "))
+	}
+	return "", fmt.Errorf("unknown method %q, allowed: %s", method, strings.Join(c.Methods, ", "))
 }
 
 // DefaultClientConfig 返回默认配置
@@ -33,23 +88,53 @@ func DefaultClientConfig() ClientConfig {
 		HealthCheckInterval: 30 * time.Second,
 		MaxRetries:          3,
 		RetryDelay:          time.Second,
+		PrewarmTimeout:      10 * time.Second,
+		CancelTimeout:       10 * time.Second,
+		HealthCheckTimeout:  5 * time.Second,
 	}
 }
 
 // StreamingGRPCClient 封装与 gRPC 服务的流式通信
 type StreamingGRPCClient struct {
-	config  ClientConfig
-	conn    *grpc.ClientConn
-	client  pb.TaskExecutorServiceClient
-	logger  *zap.Logger
-	healthy atomic.Bool
+	config ClientConfig
+	conn   *grpc.ClientConn
+	client pb.TaskExecutorServiceClient
+	// healthClient 标准健康检查客户端，HealthCheckProtocol 为 grpc 时使用
+	healthClient healthpb.HealthClient
+	logger       *zap.Logger
+	healthy      atomic.Bool
+	clock        clock.Clock
+
+	// dialOptions 追加的拨号选项
+	dialOptions []grpc.DialOption
 
 	mu         sync.RWMutex
 	cancelFunc context.CancelFunc
+
+	// streams 流名额，nil 表示不限制
+	streams       chan struct{}
+	activeStreams atomic.Int64
+}
+
+// ClientOption 客户端可选项
+type ClientOption func(*StreamingGRPCClient)
+
+// WithClock 替换健康检查和重试使用的时间源
+func WithClock(c clock.Clock) ClientOption {
+	return func(client *StreamingGRPCClient) {
+		client.clock = c
+	}
+}
+
+// WithDialOptions 追加 gRPC 拨号选项
+func WithDialOptions(opts ...grpc.DialOption) ClientOption {
+	return func(client *StreamingGRPCClient) {
+		client.dialOptions = append(client.dialOptions, opts...)
+	}
 }
 
 // NewStreamingGRPCClient 创建新的 gRPC 服务客户端
-func NewStreamingGRPCClient(config ClientConfig, logger *zap.Logger) (*StreamingGRPCClient, error) {
+func NewStreamingGRPCClient(config ClientConfig, logger *zap.Logger, opts ...ClientOption) (*StreamingGRPCClient, error) {
 	if config.Address == "" {
 		return nil, fmt.Errorf("address is required")
 	}
@@ -67,15 +152,37 @@ func NewStreamingGRPCClient(config ClientConfig, logger *zap.Logger) (*Streaming
 	if config.RetryDelay == 0 {
 		config.RetryDelay = DefaultClientConfig().RetryDelay
 	}
+	if config.PrewarmTimeout == 0 {
+		config.PrewarmTimeout = DefaultClientConfig().PrewarmTimeout
+	}
+	if config.CancelTimeout == 0 {
+		config.CancelTimeout = DefaultClientConfig().CancelTimeout
+	}
+	if config.HealthCheckTimeout == 0 {
+		config.HealthCheckTimeout = DefaultClientConfig().HealthCheckTimeout
+	}
 
 	c := &StreamingGRPCClient{
 		config: config,
 		logger: logger,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.clock = clock.OrReal(c.clock)
+	if config.MaxConcurrentStreams > 0 {
+		c.streams = make(chan struct{}, config.MaxConcurrentStreams)
+	}
 
 	if err := c.connect(); err != nil {
 		return nil, err
 	}
+	if config.Prewarm {
+		if err := c.prewarm(); err != nil {
+			c.conn.Close()
+			return nil, err
+		}
+	}
 
 	// 启动健康检查
 	ctx, cancel := context.WithCancel(context.Background())
@@ -96,7 +203,7 @@ func (c *StreamingGRPCClient) connect() error {
 		}),
 		grpc.WithChainUnaryInterceptor(
 			LoggingUnaryInterceptor(c.logger),
-			RetryUnaryInterceptor(c.config.MaxRetries, c.config.RetryDelay, c.logger),
+			RetryUnaryInterceptor(c.config.MaxRetries, c.config.RetryDelay, c.logger, c.clock),
 			MetadataUnaryInterceptor("taskflow-worker"),
 		),
 		grpc.WithChainStreamInterceptor(
@@ -104,6 +211,7 @@ func (c *StreamingGRPCClient) connect() error {
 			MetadataStreamInterceptor("taskflow-worker"),
 		),
 	}
+	opts = append(opts, c.dialOptions...)
 
 	conn, err := grpc.NewClient(c.config.Address, opts...)
 	if err != nil {
@@ -112,6 +220,7 @@ func (c *StreamingGRPCClient) connect() error {
 
 	c.conn = conn
 	c.client = pb.NewTaskExecutorServiceClient(conn)
+	c.healthClient = healthpb.NewHealthClient(conn)
 	c.healthy.Store(true)
 
 	c.logger.Info("connected to grpc service",
@@ -121,16 +230,39 @@ func (c *StreamingGRPCClient) connect() error {
 	return nil
 }
 
+// prewarm 主动建立连接并等待 Ready，避免首个任务承担建连开销
+// grpc.NewClient 默认是惰性连接，不预热时首个请求才会拨号
+func (c *StreamingGRPCClient) prewarm() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.PrewarmTimeout)
+	defer cancel()
+
+	start := time.Now()
+	c.conn.Connect()
+	for {
+		state := c.conn.GetState()
+		if state == connectivity.Ready {
+			c.logger.Info("grpc connection prewarmed",
+				zap.String("address", c.config.Address),
+				zap.Duration("elapsed", time.Since(start)),
+			)
+			return nil
+		}
+		if !c.conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("grpc service %s not ready after %s (state %s)", c.config.Address, c.config.PrewarmTimeout, state)
+		}
+	}
+}
+
 // healthCheckLoop 定期执行健康检查
 func (c *StreamingGRPCClient) healthCheckLoop(ctx context.Context) {
-	ticker := time.NewTicker(c.config.HealthCheckInterval)
+	ticker := c.clock.NewTicker(c.config.HealthCheckInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			c.checkHealth(ctx)
 		}
 	}
@@ -138,31 +270,45 @@ func (c *StreamingGRPCClient) healthCheckLoop(ctx context.Context) {
 
 // checkHealth 执行单次健康检查
 func (c *StreamingGRPCClient) checkHealth(ctx context.Context) {
-	checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
-	resp, err := c.client.HealthCheck(checkCtx, &pb.HealthCheckRequest{})
+	status, err := c.health(ctx)
 	if err != nil {
 		c.logger.Warn("health check failed",
 			zap.String("address", c.config.Address),
+			zap.String("health_service", c.config.HealthCheckService),
 			zap.Error(err),
 		)
 		c.healthy.Store(false)
 		return
 	}
 
-	healthy := resp.Status == pb.HealthStatus_HEALTH_STATUS_HEALTHY
-	c.healthy.Store(healthy)
+	c.healthy.Store(status.Healthy)
 
-	if !healthy {
+	if !status.Healthy {
 		c.logger.Warn("service unhealthy",
 			zap.String("address", c.config.Address),
-			zap.String("status", resp.Status.String()),
-			zap.String("message", resp.Message),
+			zap.String("health_service", c.config.HealthCheckService),
+			zap.String("status", status.Status),
+			zap.String("message", status.Message),
 		)
 	}
 }
 
+// WarmUp 按 interval 反复执行健康检查，直到服务健康或 ctx 结束
+// 用于 worker 开始消费前等待启动较慢的服务
+func (c *StreamingGRPCClient) WarmUp(ctx context.Context, interval time.Duration) error {
+	for {
+		c.checkHealth(ctx)
+		if c.IsHealthy() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("grpc service %s not healthy: %w", c.config.Address, ctx.Err())
+		case <-c.clock.After(interval):
+		}
+	}
+}
+
 // IsHealthy 返回服务健康状态
 func (c *StreamingGRPCClient) IsHealthy() bool {
 	// 同时检查连接状态
@@ -175,12 +321,31 @@ func (c *StreamingGRPCClient) IsHealthy() bool {
 // ProgressCallback 进度回调函数类型
 type ProgressCallback func(*pb.Progress)
 
-// ExecuteTask 执行任务并返回结果
+// ExecuteTask 执行任务并返回结果和 ResponseMetadata 允许的响应元数据，出错时元数据见 ExecuteTaskResults。
+// ResultMode 为 accumulate 时返回 MergeResults 合并后的结果，否则返回最后一条 Result 消息
 func (c *StreamingGRPCClient) ExecuteTask(
 	ctx context.Context,
 	req *pb.ExecuteTaskRequest,
 	onProgress ProgressCallback,
-) (*pb.TaskResult, error) {
+) (*pb.TaskResult, map[string]string, error) {
+	results, md, err := c.ExecuteTaskResults(ctx, req, onProgress)
+	if err != nil {
+		return nil, md, err
+	}
+	if c.config.ResultMode == ResultModeAccumulate {
+		return MergeResults(results), md, nil
+	}
+	return results[len(results)-1], md, nil
+}
+
+// ExecuteTaskResults 执行任务并按接收顺序返回流中的所有 Result 消息（至少包含一条），
+// 以及响应头和 trailer 中 ResponseMetadata 允许的键值，见 SelectMetadata。
+// 流中断或后端返回 error 时同样返回已收到的元数据，后者的流尚未结束，只包含响应头
+func (c *StreamingGRPCClient) ExecuteTaskResults(
+	ctx context.Context,
+	req *pb.ExecuteTaskRequest,
+	onProgress ProgressCallback,
+) ([]*pb.TaskResult, map[string]string, error) {
 	// 设置超时
 	timeout := c.config.Timeout
 	if req.Options != nil && req.Options.TimeoutMs > 0 {
@@ -190,21 +355,28 @@ func (c *StreamingGRPCClient) ExecuteTask(
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	// 流名额已满时快速失败，避免在 HTTP/2 传输层排队占用 worker
+	if err := c.acquireStream(ctx); err != nil {
+		return nil, nil, err
+	}
+	defer c.releaseStream()
+
 	// 发起流式调用
 	stream, err := c.client.ExecuteTask(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to start task execution: %w", err)
+		return nil, nil, fmt.Errorf("failed to start task execution: %w", err)
 	}
 
 	// 处理流式响应
-	var result *pb.TaskResult
+	var results []*pb.TaskResult
 	for {
 		resp, err := stream.Recv()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("stream error: %w", err)
+			// 流已出错结束，trailer 可读
+			return nil, c.responseMetadata(stream, true), fmt.Errorf("stream error: %w", err)
 		}
 
 		switch r := resp.Response.(type) {
@@ -213,9 +385,10 @@ func (c *StreamingGRPCClient) ExecuteTask(
 				onProgress(r.Progress)
 			}
 		case *pb.ExecuteTaskResponse_Result:
-			result = r.Result
+			results = append(results, r.Result)
 		case *pb.ExecuteTaskResponse_Error:
-			return nil, &GRPCError{
+			// 流尚未结束，读取 trailer 会阻塞到后端关闭流，只取响应头
+			return nil, c.responseMetadata(stream, false), &GRPCError{
 				Code:      r.Error.Code,
 				Message:   r.Error.Message,
 				Retryable: r.Error.Retryable,
@@ -223,16 +396,130 @@ func (c *StreamingGRPCClient) ExecuteTask(
 		}
 	}
 
-	if result == nil {
-		return nil, fmt.Errorf("no result received from stream")
+	if len(results) == 0 {
+		return nil, nil, fmt.Errorf("no result received from stream")
+	}
+
+	return results, c.responseMetadata(stream, true), nil
+}
+
+// responseMetadata 返回响应头中 ResponseMetadata 允许的键值，withTrailer 为 true 时同时读取 trailer，
+// 此时流必须已经结束。已收到消息或流已结束时 Header 不会阻塞
+func (c *StreamingGRPCClient) responseMetadata(stream pb.TaskExecutorService_ExecuteTaskClient, withTrailer bool) map[string]string {
+	if len(c.config.ResponseMetadata) == 0 {
+		return nil
+	}
+	header, _ := stream.Header()
+	var trailer metadata.MD
+	if withTrailer {
+		trailer = stream.Trailer()
+	}
+	return SelectMetadata(c.config.ResponseMetadata, header, trailer)
+}
+
+// SelectMetadata 从响应头和 trailer 中挑出 allowed 中的键，键统一为小写。
+// 同一个键同时出现时 trailer 优先，多个值以逗号连接；没有匹配的键时返回 nil
+func SelectMetadata(allowed []string, header, trailer metadata.MD) map[string]string {
+	var selected map[string]string
+	for _, key := range allowed {
+		key = strings.ToLower(key)
+		values := trailer.Get(key)
+		if len(values) == 0 {
+			values = header.Get(key)
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if selected == nil {
+			selected = make(map[string]string, len(allowed))
+		}
+		selected[key] = strings.Join(values, ",")
+	}
+	return selected
+}
+
+// MergeResults 将分块返回的多条结果合并为一条：
+//   - Data 为 {"chunks": [...]}，按接收顺序包含每条结果的 data，没有 data 的结果为 null
+//   - Status 为第一条失败或取消的结果的状态，都成功时为最后一条的状态
+//   - DurationMs 取最大值，兼容报告累计耗时和分块耗时的后端
+//   - Artifacts 按接收顺序拼接所有结果的文件列表
+func MergeResults(results []*pb.TaskResult) *pb.TaskResult {
+	merged := &pb.TaskResult{
+		TaskId: results[len(results)-1].GetTaskId(),
+		Status: results[len(results)-1].GetStatus(),
+	}
+
+	chunks := make([]*structpb.Value, 0, len(results))
+	failed := false
+	for _, r := range results {
+		if r.GetData() != nil {
+			chunks = append(chunks, structpb.NewStructValue(r.GetData()))
+		} else {
+			chunks = append(chunks, structpb.NewNullValue())
+		}
+		if !failed && (r.GetStatus() == pb.TaskStatus_TASK_STATUS_FAILED || r.GetStatus() == pb.TaskStatus_TASK_STATUS_CANCELLED) {
+			merged.Status = r.GetStatus()
+			failed = true
+		}
+		merged.DurationMs = max(merged.DurationMs, r.GetDurationMs())
+		merged.Artifacts = append(merged.Artifacts, r.GetArtifacts()...)
+	}
+	merged.Data = &structpb.Struct{Fields: map[string]*structpb.Value{
+		"chunks": structpb.NewListValue(&structpb.ListValue{Values: chunks}),
+	}}
+	return merged
+}
+
+// acquireStream 占用一个流名额，名额已满时最多等待 StreamWaitTimeout
+func (c *StreamingGRPCClient) acquireStream(ctx context.Context) error {
+	if c.streams != nil {
+		select {
+		case c.streams <- struct{}{}:
+		default:
+			if err := c.waitStream(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	c.activeStreams.Add(1)
+	return nil
+}
+
+func (c *StreamingGRPCClient) waitStream(ctx context.Context) error {
+	saturated := fmt.Errorf("%w: %s has %d active streams", ErrStreamsSaturated, c.config.Address, c.config.MaxConcurrentStreams)
+	if c.config.StreamWaitTimeout <= 0 {
+		return saturated
+	}
+	select {
+	case c.streams <- struct{}{}:
+		return nil
+	case <-c.clock.After(c.config.StreamWaitTimeout):
+		return saturated
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *StreamingGRPCClient) releaseStream() {
+	c.activeStreams.Add(-1)
+	if c.streams != nil {
+		<-c.streams
 	}
+}
+
+// ActiveStreams 返回正在进行的 ExecuteTask 流数量
+func (c *StreamingGRPCClient) ActiveStreams() int {
+	return int(c.activeStreams.Load())
+}
 
-	return result, nil
+// MaxStreams 返回流数量上限，0 表示不限制
+func (c *StreamingGRPCClient) MaxStreams() int {
+	return c.config.MaxConcurrentStreams
 }
 
 // CancelTask 取消任务
 func (c *StreamingGRPCClient) CancelTask(ctx context.Context, taskID, reason string) error {
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, c.config.CancelTimeout)
 	defer cancel()
 
 	resp, err := c.client.CancelTask(ctx, &pb.CancelTaskRequest{
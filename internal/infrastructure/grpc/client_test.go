@@ -0,0 +1,586 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	pb "github.com/Aixtrade/TaskFlow/api/proto/grpc_task/v1"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+type fakeExecutor struct {
+	pb.UnimplementedTaskExecutorServiceServer
+	status atomic.Int32
+	calls  atomic.Int32
+}
+
+func (f *fakeExecutor) HealthCheck(ctx context.Context, req *pb.HealthCheckRequest) (*pb.HealthCheckResponse, error) {
+	f.calls.Add(1)
+	return &pb.HealthCheckResponse{Status: pb.HealthStatus(f.status.Load())}, nil
+}
+
+func startExecutor(t *testing.T, executor *fakeExecutor) grpc.DialOption {
+	t.Helper()
+	return serveExecutor(t, executor)
+}
+
+// serveExecutor 在内存连接上启动任意 TaskExecutorService 实现，返回连接它的拨号选项
+func serveExecutor(t *testing.T, executor pb.TaskExecutorServiceServer) grpc.DialOption {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterTaskExecutorServiceServer(srv, executor)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	})
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHealthCheckLoopFollowsFakeClock(t *testing.T) {
+	executor := &fakeExecutor{}
+	executor.status.Store(int32(pb.HealthStatus_HEALTH_STATUS_UNHEALTHY))
+	fake := clock.NewFake(time.Unix(0, 0))
+
+	client, err := NewStreamingGRPCClient(ClientConfig{
+		Address:             "passthrough:///bufnet",
+		HealthCheckInterval: time.Hour,
+	}, zap.NewNop(), WithClock(fake), WithDialOptions(startExecutor(t, executor)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	fake.BlockUntil(1)
+	if !client.IsHealthy() {
+		t.Fatal("expected client to start healthy")
+	}
+
+	fake.Advance(time.Hour)
+	waitFor(t, func() bool { return !client.IsHealthy() })
+
+	executor.status.Store(int32(pb.HealthStatus_HEALTH_STATUS_HEALTHY))
+	fake.Advance(time.Hour)
+	waitFor(t, func() bool { return client.IsHealthy() })
+
+	if got := executor.calls.Load(); got != 2 {
+		t.Fatalf("expected 2 health checks, got %d", got)
+	}
+}
+
+func TestRetryUnaryInterceptorWaitsOnClock(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	interceptor := RetryUnaryInterceptor(2, time.Minute, zap.NewNop(), fake)
+
+	var attempts atomic.Int32
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		if attempts.Add(1) < 3 {
+			return status.Error(codes.Unavailable, "try again")
+		}
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- interceptor(context.Background(), "/test", nil, nil, nil, invoker)
+	}()
+
+	for i := 0; i < 2; i++ {
+		fake.BlockUntil(1)
+		fake.Advance(time.Minute)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestClientConfigResolveMethod(t *testing.T) {
+	cfg := ClientConfig{Methods: []string{"chat", "summarize"}, DefaultMethod: "chat"}
+
+	if got, err := cfg.ResolveMethod(""); err != nil || got != "chat" {
+		t.Fatalf("expected default method, got %q, %v", got, err)
+	}
+	if got, err := cfg.ResolveMethod("summarize"); err != nil || got != "summarize" {
+		t.Fatalf("expected allowed method, got %q, %v", got, err)
+	}
+	if _, err := cfg.ResolveMethod("chta"); err == nil {
+		t.Fatalf("expected unknown method to be rejected")
+	}
+	if got, err := (ClientConfig{}).ResolveMethod("anything"); err != nil || got != "anything" {
+		t.Fatalf("expected unrestricted service to accept any method, got %q, %v", got, err)
+	}
+	if _, err := (ClientConfig{Methods: []string{"chat"}}).ResolveMethod(""); err == nil {
+		t.Fatalf("expected missing method to be rejected without a default")
+	}
+}
+
+func TestPrewarmWaitsForReadyConnection(t *testing.T) {
+	executor := &fakeExecutor{}
+	dialer := startExecutor(t, executor)
+
+	client, err := NewStreamingGRPCClient(ClientConfig{Address: "passthrough:///bufnet", Prewarm: true}, zap.NewNop(), WithDialOptions(dialer))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	if state := client.conn.GetState(); state != connectivity.Ready {
+		t.Fatalf("expected ready connection after prewarm, got %s", state)
+	}
+}
+
+func TestPrewarmFailsForUnreachableService(t *testing.T) {
+	unreachable := grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	})
+
+	_, err := NewStreamingGRPCClient(ClientConfig{
+		Address:        "passthrough:///unreachable",
+		Prewarm:        true,
+		PrewarmTimeout: 100 * time.Millisecond,
+	}, zap.NewNop(), WithDialOptions(unreachable))
+	if err == nil {
+		t.Fatalf("expected prewarm to fail for unreachable service")
+	}
+}
+
+func TestProbeReportsHealthAndConnectionErrors(t *testing.T) {
+	executor := &fakeExecutor{}
+	executor.status.Store(int32(pb.HealthStatus_HEALTH_STATUS_HEALTHY))
+	dialer := startExecutor(t, executor)
+
+	result := Probe(context.Background(), ClientConfig{Address: "passthrough:///bufnet"}, zap.NewNop(), WithDialOptions(dialer))
+	if !result.Connected || !result.Healthy || result.Status != "HEALTH_STATUS_HEALTHY" || result.Error != "" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if executor.calls.Load() != 1 {
+		t.Fatalf("expected a single health check, got %d", executor.calls.Load())
+	}
+
+	unreachable := grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	})
+	result = Probe(context.Background(), ClientConfig{
+		Address:        "passthrough:///unreachable",
+		PrewarmTimeout: 100 * time.Millisecond,
+	}, zap.NewNop(), WithDialOptions(unreachable))
+	if result.Connected || result.Healthy || result.Error == "" {
+		t.Fatalf("expected a connection error, got %+v", result)
+	}
+}
+
+// stallingExecutor 前 stalls 次健康检查一直阻塞到请求超时
+type stallingExecutor struct {
+	fakeExecutor
+	stalls int32
+}
+
+func (s *stallingExecutor) HealthCheck(ctx context.Context, req *pb.HealthCheckRequest) (*pb.HealthCheckResponse, error) {
+	if s.calls.Add(1) <= s.stalls {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &pb.HealthCheckResponse{Status: pb.HealthStatus_HEALTH_STATUS_HEALTHY}, nil
+}
+
+func TestHealthCheckRetriesWithPerRequestTimeout(t *testing.T) {
+	retries := func(n int) *int { return &n }
+	tests := []struct {
+		name    string
+		retries *int
+		healthy bool
+		calls   int32
+	}{
+		{name: "retries until healthy", retries: retries(2), healthy: true, calls: 3},
+		{name: "no retries", retries: retries(0), healthy: false, calls: 1},
+		{name: "falls back to max_retries", retries: nil, healthy: false, calls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &stallingExecutor{stalls: 2}
+			result := Probe(context.Background(), ClientConfig{
+				Address:            "passthrough:///bufnet",
+				MaxRetries:         1,
+				RetryDelay:         time.Millisecond,
+				HealthCheckTimeout: 20 * time.Millisecond,
+				HealthCheckRetries: tt.retries,
+			}, zap.NewNop(), WithDialOptions(serveExecutor(t, executor)))
+
+			if result.Healthy != tt.healthy {
+				t.Fatalf("expected healthy=%v, got %+v", tt.healthy, result)
+			}
+			if got := executor.calls.Load(); got != tt.calls {
+				t.Fatalf("expected %d health check requests, got %d", tt.calls, got)
+			}
+		})
+	}
+}
+
+func TestStandardHealthCheckTargetsService(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterTaskExecutorServiceServer(srv, &fakeExecutor{})
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("llm.Chat", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("llm.Embed", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	dialer := grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	})
+
+	tests := []struct {
+		service string
+		healthy bool
+		status  string
+		err     string
+	}{
+		{service: "", healthy: true, status: "SERVING"},
+		{service: "llm.Chat", healthy: true, status: "SERVING"},
+		{service: "llm.Embed", healthy: false, status: "NOT_SERVING"},
+		{service: "llm.Unknown", healthy: false, err: "NotFound"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			cfg := ClientConfig{
+				Address:             "passthrough:///bufnet",
+				HealthCheckProtocol: HealthProtocolGRPC,
+				HealthCheckService:  tt.service,
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("unexpected validation error: %v", err)
+			}
+			result := Probe(context.Background(), cfg, zap.NewNop(), WithDialOptions(dialer))
+			if result.Healthy != tt.healthy || result.Status != tt.status || !strings.Contains(result.Error, tt.err) {
+				t.Fatalf("unexpected result %+v", result)
+			}
+		})
+	}
+
+	if err := (ClientConfig{Address: "x", HealthCheckService: "llm.Chat"}).Validate(); err == nil {
+		t.Fatal("expected health_check_service without the grpc protocol to be rejected")
+	}
+}
+
+func TestManagerWarmUpWaitsForSlowService(t *testing.T) {
+	slow := &fakeExecutor{}
+	slow.status.Store(int32(pb.HealthStatus_HEALTH_STATUS_UNHEALTHY))
+	down := &fakeExecutor{}
+	down.status.Store(int32(pb.HealthStatus_HEALTH_STATUS_UNHEALTHY))
+
+	manager := &ClientManager{clients: make(map[string]*StreamingGRPCClient), logger: zap.NewNop()}
+	for name, executor := range map[string]*fakeExecutor{"slow": slow, "down": down} {
+		client, err := NewStreamingGRPCClient(ClientConfig{
+			Address:             "passthrough:///bufnet",
+			HealthCheckInterval: time.Hour,
+		}, zap.NewNop(), WithDialOptions(startExecutor(t, executor)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer client.Close()
+		manager.clients[name] = client
+	}
+
+	// slow 在几次检查后才就绪，down 始终不健康
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		slow.status.Store(int32(pb.HealthStatus_HEALTH_STATUS_HEALTHY))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	failed := manager.WarmUp(ctx, 10*time.Millisecond)
+
+	if len(failed) != 1 || failed[0] != "down" {
+		t.Fatalf("expected only down to fail warm-up, got %v", failed)
+	}
+	if !manager.clients["slow"].IsHealthy() || slow.calls.Load() < 2 {
+		t.Fatalf("expected slow service to be retried until healthy (calls=%d)", slow.calls.Load())
+	}
+}
+
+type blockingExecutor struct {
+	fakeExecutor
+	release chan struct{}
+}
+
+func (b *blockingExecutor) ExecuteTask(req *pb.ExecuteTaskRequest, stream pb.TaskExecutorService_ExecuteTaskServer) error {
+	<-b.release
+	return stream.Send(&pb.ExecuteTaskResponse{Response: &pb.ExecuteTaskResponse_Result{
+		Result: &pb.TaskResult{TaskId: req.TaskId, Status: pb.TaskStatus_TASK_STATUS_COMPLETED},
+	}})
+}
+
+func TestExecuteTaskFailsFastWhenStreamsSaturated(t *testing.T) {
+	executor := &blockingExecutor{release: make(chan struct{})}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterTaskExecutorServiceServer(srv, executor)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	dialer := grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	})
+
+	client, err := NewStreamingGRPCClient(ClientConfig{Address: "passthrough:///bufnet", MaxConcurrentStreams: 1}, zap.NewNop(), WithDialOptions(dialer))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := client.ExecuteTask(context.Background(), &pb.ExecuteTaskRequest{TaskId: "task-1"}, nil)
+		done <- err
+	}()
+	waitFor(t, func() bool { return client.ActiveStreams() == 1 })
+
+	if _, _, err := client.ExecuteTask(context.Background(), &pb.ExecuteTaskRequest{TaskId: "task-2"}, nil); !errors.Is(err, ErrStreamsSaturated) {
+		t.Fatalf("expected ErrStreamsSaturated, got %v", err)
+	}
+
+	close(executor.release)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := client.ActiveStreams(); got != 0 {
+		t.Fatalf("expected stream slot to be released, got %d active", got)
+	}
+}
+
+// chunkedExecutor 每个数据块发送一条 Result 消息
+type chunkedExecutor struct {
+	fakeExecutor
+}
+
+func (c *chunkedExecutor) ExecuteTask(req *pb.ExecuteTaskRequest, stream pb.TaskExecutorService_ExecuteTaskServer) error {
+	for i, part := range []string{"a", "b", "c"} {
+		data, _ := structpb.NewStruct(map[string]any{"part": part})
+		if err := stream.Send(&pb.ExecuteTaskResponse{Response: &pb.ExecuteTaskResponse_Result{
+			Result: &pb.TaskResult{TaskId: req.TaskId, Status: pb.TaskStatus_TASK_STATUS_COMPLETED, Data: data, DurationMs: int64(10 * (i + 1))},
+		}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExecuteTaskResultModes(t *testing.T) {
+	dialer := serveExecutor(t, &chunkedExecutor{})
+
+	single, err := NewStreamingGRPCClient(ClientConfig{Address: "passthrough:///bufnet"}, zap.NewNop(), WithDialOptions(dialer))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer single.Close()
+
+	result, _, err := single.ExecuteTask(context.Background(), &pb.ExecuteTaskRequest{TaskId: "task-1"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := result.Data.AsMap()["part"]; got != "c" {
+		t.Fatalf("expected the last result by default, got %v", got)
+	}
+
+	accumulate, err := NewStreamingGRPCClient(ClientConfig{Address: "passthrough:///bufnet", ResultMode: ResultModeAccumulate}, zap.NewNop(), WithDialOptions(dialer))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer accumulate.Close()
+
+	result, _, err = accumulate.ExecuteTask(context.Background(), &pb.ExecuteTaskRequest{TaskId: "task-2"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	chunks, _ := result.Data.AsMap()["chunks"].([]any)
+	if len(chunks) != 3 || chunks[0].(map[string]any)["part"] != "a" || chunks[2].(map[string]any)["part"] != "c" {
+		t.Fatalf("expected all chunks in order, got %v", result.Data.AsMap())
+	}
+	if result.Status != pb.TaskStatus_TASK_STATUS_COMPLETED || result.DurationMs != 30 {
+		t.Fatalf("unexpected merged status or duration: %v, %d", result.Status, result.DurationMs)
+	}
+}
+
+func TestMergeResultsKeepsFirstFailure(t *testing.T) {
+	merged := MergeResults([]*pb.TaskResult{
+		{Status: pb.TaskStatus_TASK_STATUS_COMPLETED, Artifacts: []*pb.Artifact{{Name: "part-1.csv"}}},
+		{Status: pb.TaskStatus_TASK_STATUS_FAILED},
+		{Status: pb.TaskStatus_TASK_STATUS_COMPLETED, Artifacts: []*pb.Artifact{{Name: "part-3.csv"}}},
+	})
+	if merged.Status != pb.TaskStatus_TASK_STATUS_FAILED {
+		t.Fatalf("expected a failed chunk to fail the merged result, got %v", merged.Status)
+	}
+	if len(merged.Artifacts) != 2 || merged.Artifacts[0].GetName() != "part-1.csv" || merged.Artifacts[1].GetName() != "part-3.csv" {
+		t.Fatalf("expected the artifacts of every chunk in order, got %v", merged.Artifacts)
+	}
+	if chunks := merged.Data.Fields["chunks"].GetListValue().GetValues(); len(chunks) != 3 || chunks[0].GetNullValue() != structpb.NullValue_NULL_VALUE {
+		t.Fatalf("expected null placeholders for chunks without data, got %v", chunks)
+	}
+}
+
+// metadataExecutor 在响应头和 trailer 中返回元数据
+type metadataExecutor struct {
+	fakeExecutor
+}
+
+func (m *metadataExecutor) ExecuteTask(req *pb.ExecuteTaskRequest, stream pb.TaskExecutorService_ExecuteTaskServer) error {
+	if err := stream.SendHeader(metadata.Pairs("x-model-version", "v1", "x-region", "eu")); err != nil {
+		return err
+	}
+	stream.SetTrailer(metadata.Pairs("x-cost-units", "42", "x-model-version", "v2"))
+	return stream.Send(&pb.ExecuteTaskResponse{Response: &pb.ExecuteTaskResponse_Result{
+		Result: &pb.TaskResult{TaskId: req.TaskId, Status: pb.TaskStatus_TASK_STATUS_COMPLETED},
+	}})
+}
+
+func TestExecuteTaskReturnsAllowedMetadata(t *testing.T) {
+	dialer := serveExecutor(t, &metadataExecutor{})
+
+	client, err := NewStreamingGRPCClient(ClientConfig{
+		Address:          "passthrough:///bufnet",
+		ResponseMetadata: []string{"X-Model-Version", "x-cost-units", "x-missing"},
+	}, zap.NewNop(), WithDialOptions(dialer))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	_, md, err := client.ExecuteTask(context.Background(), &pb.ExecuteTaskRequest{TaskId: "task-1"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{"x-model-version": "v2", "x-cost-units": "42"}
+	if len(md) != len(want) {
+		t.Fatalf("expected %v, got %v", want, md)
+	}
+	for k, v := range want {
+		if md[k] != v {
+			t.Fatalf("expected %s=%s, got %v", k, v, md)
+		}
+	}
+
+	none, err := NewStreamingGRPCClient(ClientConfig{Address: "passthrough:///bufnet"}, zap.NewNop(), WithDialOptions(dialer))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer none.Close()
+	if _, md, err := none.ExecuteTask(context.Background(), &pb.ExecuteTaskRequest{TaskId: "task-2"}, nil); err != nil || md != nil {
+		t.Fatalf("expected metadata to be dropped without an allow-list, got %v, %v", md, err)
+	}
+}
+
+// failingMetadataExecutor 返回元数据后以 error 消息（backendError 为 true）或 gRPC 状态结束
+type failingMetadataExecutor struct {
+	fakeExecutor
+	backendError bool
+}
+
+func (f *failingMetadataExecutor) ExecuteTask(req *pb.ExecuteTaskRequest, stream pb.TaskExecutorService_ExecuteTaskServer) error {
+	if err := stream.SendHeader(metadata.Pairs("x-model-version", "v1")); err != nil {
+		return err
+	}
+	stream.SetTrailer(metadata.Pairs("x-cost-units", "7"))
+	if f.backendError {
+		return stream.Send(&pb.ExecuteTaskResponse{Response: &pb.ExecuteTaskResponse_Error{
+			Error: &pb.ErrorDetail{Code: "MODEL_ERROR", Message: "model failed"},
+		}})
+	}
+	return status.Error(codes.Internal, "backend crashed")
+}
+
+func TestExecuteTaskReturnsMetadataOnError(t *testing.T) {
+	cfg := ClientConfig{Address: "passthrough:///bufnet", ResponseMetadata: []string{"x-model-version", "x-cost-units"}}
+
+	// 流中断时流已结束，响应头和 trailer 都可读
+	client, err := NewStreamingGRPCClient(cfg, zap.NewNop(), WithDialOptions(serveExecutor(t, &failingMetadataExecutor{})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+	_, md, err := client.ExecuteTask(context.Background(), &pb.ExecuteTaskRequest{TaskId: "task-1"}, nil)
+	if status.Code(errors.Unwrap(err)) != codes.Internal {
+		t.Fatalf("expected the stream error, got %v", err)
+	}
+	if len(md) != 2 || md["x-model-version"] != "v1" || md["x-cost-units"] != "7" {
+		t.Fatalf("expected header and trailer metadata, got %v", md)
+	}
+
+	// 后端返回 error 消息时流尚未结束，只有响应头
+	client, err = NewStreamingGRPCClient(cfg, zap.NewNop(), WithDialOptions(serveExecutor(t, &failingMetadataExecutor{backendError: true})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+	_, md, err = client.ExecuteTask(context.Background(), &pb.ExecuteTaskRequest{TaskId: "task-2"}, nil)
+	var grpcErr *GRPCError
+	if !errors.As(err, &grpcErr) || grpcErr.Code != "MODEL_ERROR" {
+		t.Fatalf("expected the backend error, got %v", err)
+	}
+	if len(md) != 1 || md["x-model-version"] != "v1" {
+		t.Fatalf("expected header metadata only, got %v", md)
+	}
+}
+
+// slowCancelExecutor 的 CancelTask 一直阻塞到调用方放弃
+type slowCancelExecutor struct {
+	fakeExecutor
+}
+
+func (s *slowCancelExecutor) CancelTask(ctx context.Context, req *pb.CancelTaskRequest) (*pb.CancelTaskResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCancelTaskUsesConfiguredTimeout(t *testing.T) {
+	dialer := serveExecutor(t, &slowCancelExecutor{})
+	client, err := NewStreamingGRPCClient(ClientConfig{Address: "passthrough:///bufnet", CancelTimeout: 50 * time.Millisecond}, zap.NewNop(), WithDialOptions(dialer))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	start := time.Now()
+	err = client.CancelTask(context.Background(), "task-1", "test")
+	if !errors.Is(err, context.DeadlineExceeded) && status.Code(errors.Unwrap(err)) != codes.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected cancel to give up after the configured timeout, took %v", elapsed)
+	}
+}
@@ -1,10 +1,15 @@
 package grpc
 
 import (
+	"errors"
+
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// ErrStreamsSaturated 服务的并发流已达上限，任务应稍后重试
+var ErrStreamsSaturated = errors.New("grpc service stream limit reached")
+
 // GRPCError 表示 gRPC 调用错误
 type GRPCError struct {
 	Code      string
@@ -0,0 +1,77 @@
+package grpc
+
+import (
+	"context"
+
+	pb "github.com/Aixtrade/TaskFlow/api/proto/grpc_task/v1"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// HealthProtocolTaskFlow 调用 TaskExecutorService.HealthCheck（默认）
+	HealthProtocolTaskFlow = "taskflow"
+	// HealthProtocolGRPC 调用标准的 grpc.health.v1.Health/Check，可按 HealthCheckService 检查服务端上的某个服务
+	HealthProtocolGRPC = "grpc"
+)
+
+// healthStatus 一次健康检查的结果
+type healthStatus struct {
+	Healthy bool
+	Status  string
+	Message string
+	Details map[string]string
+}
+
+// health 按 HealthCheckProtocol 执行健康检查。每次请求单独计算 HealthCheckTimeout，
+// 可重试的错误最多重试 HealthCheckRetries 次（未设置时为 MaxRetries），服务返回不健康不重试
+func (c *StreamingGRPCClient) health(ctx context.Context) (healthStatus, error) {
+	retries := c.config.MaxRetries
+	if c.config.HealthCheckRetries != nil {
+		retries = *c.config.HealthCheckRetries
+	}
+	// 重试由这里控制，拦截器不再重试
+	ctx = withRetries(ctx, 0)
+
+	for attempt := 0; ; attempt++ {
+		status, err := c.healthOnce(ctx)
+		if err == nil {
+			return status, nil
+		}
+		if grpcErr, ok := ConvertError(err); attempt >= retries || !ok || !grpcErr.Retryable {
+			return healthStatus{}, err
+		}
+		select {
+		case <-ctx.Done():
+			return healthStatus{}, err
+		case <-c.clock.After(c.config.RetryDelay):
+		}
+	}
+}
+
+// healthOnce 发送一次健康检查请求
+func (c *StreamingGRPCClient) healthOnce(ctx context.Context) (healthStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.HealthCheckTimeout)
+	defer cancel()
+
+	if c.config.HealthCheckProtocol == HealthProtocolGRPC {
+		resp, err := c.healthClient.Check(ctx, &healthpb.HealthCheckRequest{Service: c.config.HealthCheckService})
+		if err != nil {
+			return healthStatus{}, err
+		}
+		return healthStatus{
+			Healthy: resp.Status == healthpb.HealthCheckResponse_SERVING,
+			Status:  resp.Status.String(),
+		}, nil
+	}
+
+	resp, err := c.client.HealthCheck(ctx, &pb.HealthCheckRequest{})
+	if err != nil {
+		return healthStatus{}, err
+	}
+	return healthStatus{
+		Healthy: resp.Status == pb.HealthStatus_HEALTH_STATUS_HEALTHY,
+		Status:  resp.Status.String(),
+		Message: resp.Message,
+		Details: resp.Details,
+	}, nil
+}
@@ -7,6 +7,8 @@ import (
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
 )
 
 // LoggingUnaryInterceptor 创建一元 RPC 日志拦截器
@@ -144,8 +146,18 @@ func MetadataStreamInterceptor(serviceName string) grpc.StreamClientInterceptor
 	}
 }
 
-// RetryUnaryInterceptor 创建带重试的一元 RPC 拦截器
-func RetryUnaryInterceptor(maxRetries int, retryDelay time.Duration, logger *zap.Logger) grpc.UnaryClientInterceptor {
+// retriesKey 覆盖 RetryUnaryInterceptor 重试次数的 context key
+type retriesKey struct{}
+
+// withRetries 让 RetryUnaryInterceptor 对本次调用最多重试 n 次
+func withRetries(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, retriesKey{}, n)
+}
+
+// RetryUnaryInterceptor 创建带重试的一元 RPC 拦截器，clk 为空时使用系统时钟。
+// context 中通过 withRetries 设置的次数优先于 maxRetries
+func RetryUnaryInterceptor(maxRetries int, retryDelay time.Duration, logger *zap.Logger, clk clock.Clock) grpc.UnaryClientInterceptor {
+	clk = clock.OrReal(clk)
 	return func(
 		ctx context.Context,
 		method string,
@@ -154,6 +166,10 @@ func RetryUnaryInterceptor(maxRetries int, retryDelay time.Duration, logger *zap
 		invoker grpc.UnaryInvoker,
 		opts ...grpc.CallOption,
 	) error {
+		maxRetries := maxRetries
+		if n, ok := ctx.Value(retriesKey{}).(int); ok {
+			maxRetries = n
+		}
 		var lastErr error
 		for i := 0; i <= maxRetries; i++ {
 			if i > 0 {
@@ -165,7 +181,7 @@ func RetryUnaryInterceptor(maxRetries int, retryDelay time.Duration, logger *zap
 				select {
 				case <-ctx.Done():
 					return ctx.Err()
-				case <-time.After(retryDelay):
+				case <-clk.After(retryDelay):
 				}
 			}
 
@@ -1,8 +1,11 @@
 package grpc
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -81,6 +84,37 @@ func (m *ClientManager) Services() []string {
 	return services
 }
 
+// WarmUp 并发预检所有服务，返回 ctx 结束前仍未健康的服务
+func (m *ClientManager) WarmUp(ctx context.Context, interval time.Duration) []string {
+	m.mu.RLock()
+	clients := make(map[string]*StreamingGRPCClient, len(m.clients))
+	for name, client := range m.clients {
+		clients[name] = client
+	}
+	m.mu.RUnlock()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []string
+	)
+	for name, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.WarmUp(ctx, interval); err != nil {
+				mu.Lock()
+				failed = append(failed, name)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(failed)
+	return failed
+}
+
 // HealthyServices 返回健康的服务列表
 func (m *ClientManager) HealthyServices() []string {
 	m.mu.RLock()
@@ -114,6 +148,9 @@ type ServiceHealth struct {
 	Name    string
 	Address string
 	Healthy bool
+	// ActiveStreams 正在进行的流数量，MaxStreams 为 0 表示不限制
+	ActiveStreams int
+	MaxStreams    int
 }
 
 // GetHealthStatus 获取所有服务的健康状态
@@ -127,6 +164,9 @@ func (m *ClientManager) GetHealthStatus() []ServiceHealth {
 			Name:    name,
 			Address: client.Address(),
 			Healthy: client.IsHealthy(),
+
+			ActiveStreams: client.ActiveStreams(),
+			MaxStreams:    client.MaxStreams(),
 		})
 	}
 	return status
@@ -0,0 +1,83 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ProbeResult 一次连通性检查的结果
+type ProbeResult struct {
+	Address string `json:"address"`
+	// Connected 连接在 PrewarmTimeout 内就绪
+	Connected bool `json:"connected"`
+	// Healthy 健康检查返回 HEALTHY（标准协议为 SERVING）
+	Healthy bool `json:"healthy"`
+	// Status 健康检查返回的状态，未能调用时为空
+	Status  string            `json:"status,omitempty"`
+	Message string            `json:"message,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+	// Error 连接或调用失败的原因
+	Error   string `json:"error,omitempty"`
+	Elapsed string `json:"elapsed"`
+}
+
+// Validate 校验与服务注册相同的配置约束，供注册前的检查使用
+func (c ClientConfig) Validate() error {
+	if c.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+	if !slices.Contains([]string{"", ResultModeSingle, ResultModeAccumulate}, c.ResultMode) {
+		return fmt.Errorf("result_mode must be empty, single or accumulate")
+	}
+	if c.MaxConcurrentStreams < 0 || c.StreamWaitTimeout < 0 {
+		return fmt.Errorf("max_concurrent_streams and stream_wait_timeout must be greater than or equal to 0")
+	}
+	if c.DefaultMethod != "" && len(c.Methods) > 0 && !slices.Contains(c.Methods, c.DefaultMethod) {
+		return fmt.Errorf("default_method must be one of methods")
+	}
+	if !slices.Contains([]string{"", HealthProtocolTaskFlow, HealthProtocolGRPC}, c.HealthCheckProtocol) {
+		return fmt.Errorf("health_check_protocol must be empty, taskflow or grpc")
+	}
+	if c.HealthCheckService != "" && c.HealthCheckProtocol != HealthProtocolGRPC {
+		return fmt.Errorf("health_check_service requires health_check_protocol grpc")
+	}
+	if c.HealthCheckTimeout < 0 || (c.HealthCheckRetries != nil && *c.HealthCheckRetries < 0) {
+		return fmt.Errorf("health_check_timeout and health_check_retries must be greater than or equal to 0")
+	}
+	return nil
+}
+
+// Probe 用 config 创建临时客户端，等待连接就绪后执行一次健康检查，完成后关闭客户端。
+// 连接和调用错误记录在结果中而不是返回，便于在注册服务前一次性看到问题
+func Probe(ctx context.Context, config ClientConfig, logger *zap.Logger, opts ...ClientOption) (result ProbeResult) {
+	start := time.Now()
+	result.Address = config.Address
+	defer func() {
+		result.Elapsed = time.Since(start).String()
+	}()
+
+	// 预热使连接失败在创建时暴露，而不是等到首次调用
+	config.Prewarm = true
+	client, err := NewStreamingGRPCClient(config, logger, opts...)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer client.Close()
+	result.Connected = true
+
+	status, err := client.health(ctx)
+	if err != nil {
+		result.Error = fmt.Sprintf("health check failed: %v", err)
+		return result
+	}
+	result.Status = status.Status
+	result.Message = status.Message
+	result.Details = status.Details
+	result.Healthy = status.Healthy
+	return result
+}
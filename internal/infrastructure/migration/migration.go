@@ -0,0 +1,449 @@
+// Package migration 将 TaskFlow 的队列任务和进度流从一个 Redis 实例迁移到另一个
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// 可迁移的任务状态；active 任务正在执行，暂停源队列后等待其结束即可
+const (
+	StatePending   = "pending"
+	StateScheduled = "scheduled"
+	StateRetry     = "retry"
+	StateArchived  = "archived"
+)
+
+// DefaultStates 默认迁移的任务状态
+var DefaultStates = []string{StatePending, StateScheduled, StateRetry, StateArchived}
+
+const (
+	defaultPageSize      = 500
+	defaultStreamPattern = "progress:*"
+
+	// archivedHold 归档任务先以延迟任务写入目标库，再立即归档，避免被目标 worker 提前消费
+	archivedHold = 24 * time.Hour
+)
+
+// Endpoint Redis 连接端点
+type Endpoint struct {
+	Addr     string
+	Password string
+	DB       int
+}
+
+func (e Endpoint) asynqOpt() asynq.RedisClientOpt {
+	return asynq.RedisClientOpt{Addr: e.Addr, Password: e.Password, DB: e.DB}
+}
+
+func (e Endpoint) redisOptions() *redis.Options {
+	return &redis.Options{Addr: e.Addr, Password: e.Password, DB: e.DB}
+}
+
+// Options 迁移选项
+type Options struct {
+	// Queues 需要迁移的队列，为空时迁移源库中的全部队列
+	Queues []string
+	// States 需要迁移的任务状态，为空时使用 DefaultStates
+	States []string
+	// DryRun 只统计不写入，也不暂停源队列
+	DryRun bool
+	// PauseSource 迁移前暂停源队列
+	PauseSource bool
+	// SkipStreams 不迁移进度流
+	SkipStreams bool
+	// StreamPattern 进度流 key 模式，默认 progress:*
+	StreamPattern string
+	// PageSize 分页大小
+	PageSize int
+}
+
+// Migrator 队列与进度流迁移器
+// 任务按原 ID 重新入队，目标库已存在同 ID 任务时跳过，因此中断后可直接重跑续传
+type Migrator struct {
+	srcInspector *asynq.Inspector
+	dstInspector *asynq.Inspector
+	dstClient    *asynq.Client
+	srcRedis     *redis.Client
+	dstRedis     *redis.Client
+	logger       *zap.Logger
+}
+
+// New 创建迁移器
+func New(src, dst Endpoint, logger *zap.Logger) *Migrator {
+	return &Migrator{
+		srcInspector: asynq.NewInspector(src.asynqOpt()),
+		dstInspector: asynq.NewInspector(dst.asynqOpt()),
+		dstClient:    asynq.NewClient(dst.asynqOpt()),
+		srcRedis:     redis.NewClient(src.redisOptions()),
+		dstRedis:     redis.NewClient(dst.redisOptions()),
+		logger:       logger,
+	}
+}
+
+// Close 关闭所有连接
+func (m *Migrator) Close() error {
+	return errors.Join(
+		m.srcInspector.Close(),
+		m.dstInspector.Close(),
+		m.dstClient.Close(),
+		m.srcRedis.Close(),
+		m.dstRedis.Close(),
+	)
+}
+
+// Report 迁移报告
+type Report struct {
+	DryRun  bool
+	Queues  []*QueueReport
+	Streams StreamReport
+}
+
+// QueueReport 单个队列的迁移结果
+type QueueReport struct {
+	Queue  string
+	Paused bool
+	// Active 迁移时仍在执行的任务数，这些任务不会被复制
+	Active int
+	States map[string]*StateReport
+}
+
+// StateReport 单个状态的迁移结果
+type StateReport struct {
+	Source   int // 源库任务数
+	Copied   int // 本次写入的任务数
+	Existing int // 目标库已存在（之前已迁移）的任务数
+	Failed   int
+	Dest     int // 校验时目标库的任务数
+}
+
+// Verified 目标库任务数不少于源库且没有失败
+func (r *StateReport) Verified() bool {
+	return r.Failed == 0 && r.Dest >= r.Source
+}
+
+// StreamReport 进度流迁移结果
+type StreamReport struct {
+	Streams  int
+	Copied   int64 // 本次写入的条目数
+	Existing int64 // 目标库已有的条目数
+	Failed   int
+	Mismatch []string // 校验长度不一致的流
+}
+
+// Verified 判断整个迁移是否通过校验
+func (r *Report) Verified() bool {
+	if r.DryRun {
+		return true
+	}
+	for _, q := range r.Queues {
+		for _, s := range q.States {
+			if !s.Verified() {
+				return false
+			}
+		}
+	}
+	return r.Streams.Failed == 0 && len(r.Streams.Mismatch) == 0
+}
+
+// Run 执行迁移
+func (m *Migrator) Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultPageSize
+	}
+	if len(opts.States) == 0 {
+		opts.States = DefaultStates
+	}
+	for _, state := range opts.States {
+		switch state {
+		case StatePending, StateScheduled, StateRetry, StateArchived:
+		default:
+			return nil, fmt.Errorf("unsupported task state %q", state)
+		}
+	}
+	if opts.StreamPattern == "" {
+		opts.StreamPattern = defaultStreamPattern
+	}
+
+	queues := opts.Queues
+	if len(queues) == 0 {
+		var err error
+		if queues, err = m.srcInspector.Queues(); err != nil {
+			return nil, fmt.Errorf("list source queues: %w", err)
+		}
+	}
+
+	report := &Report{DryRun: opts.DryRun}
+	for _, queue := range queues {
+		qr, err := m.migrateQueue(ctx, queue, opts)
+		if err != nil {
+			return report, fmt.Errorf("migrate queue %s: %w", queue, err)
+		}
+		report.Queues = append(report.Queues, qr)
+	}
+
+	if !opts.SkipStreams {
+		if err := m.migrateStreams(ctx, opts, &report.Streams); err != nil {
+			return report, fmt.Errorf("migrate progress streams: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+func (m *Migrator) migrateQueue(ctx context.Context, queue string, opts Options) (*QueueReport, error) {
+	qr := &QueueReport{Queue: queue, States: make(map[string]*StateReport, len(opts.States))}
+
+	info, err := m.srcInspector.GetQueueInfo(queue)
+	if err != nil {
+		return nil, fmt.Errorf("get source queue info: %w", err)
+	}
+	qr.Active = info.Active
+
+	if opts.PauseSource && !opts.DryRun {
+		if !info.Paused {
+			if err := m.srcInspector.PauseQueue(queue); err != nil {
+				return nil, fmt.Errorf("pause source queue: %w", err)
+			}
+		}
+		qr.Paused = true
+	}
+
+	for _, state := range opts.States {
+		sr := &StateReport{Source: stateCount(info, state)}
+		qr.States[state] = sr
+
+		if opts.DryRun {
+			continue
+		}
+		if err := m.copyTasks(ctx, queue, state, opts.PageSize, sr); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.DryRun {
+		return qr, nil
+	}
+
+	dstQueues, err := m.dstInspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("list destination queues: %w", err)
+	}
+	if !slices.Contains(dstQueues, queue) {
+		// 源队列没有可迁移的任务时目标库不会创建该队列
+		return qr, nil
+	}
+	dstInfo, err := m.dstInspector.GetQueueInfo(queue)
+	if err != nil {
+		return nil, fmt.Errorf("get destination queue info: %w", err)
+	}
+	for state, sr := range qr.States {
+		sr.Dest = stateCount(dstInfo, state)
+	}
+	return qr, nil
+}
+
+func (m *Migrator) copyTasks(ctx context.Context, queue, state string, pageSize int, sr *StateReport) error {
+	for page := 1; ; page++ {
+		tasks, err := m.listTasks(queue, state, asynq.Page(page), asynq.PageSize(pageSize))
+		if err != nil {
+			return fmt.Errorf("list %s tasks: %w", state, err)
+		}
+
+		for _, info := range tasks {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			existing, err := m.copyTask(ctx, info, state)
+			switch {
+			case err != nil:
+				sr.Failed++
+				m.logger.Error("failed to copy task",
+					zap.String("queue", queue),
+					zap.String("task_id", info.ID),
+					zap.String("state", state),
+					zap.Error(err),
+				)
+			case existing:
+				sr.Existing++
+			default:
+				sr.Copied++
+			}
+		}
+
+		if len(tasks) < pageSize {
+			return nil
+		}
+	}
+}
+
+func (m *Migrator) listTasks(queue, state string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+	switch state {
+	case StatePending:
+		return m.srcInspector.ListPendingTasks(queue, opts...)
+	case StateScheduled:
+		return m.srcInspector.ListScheduledTasks(queue, opts...)
+	case StateRetry:
+		return m.srcInspector.ListRetryTasks(queue, opts...)
+	default:
+		return m.srcInspector.ListArchivedTasks(queue, opts...)
+	}
+}
+
+// copyTask 按原 ID 和选项写入目标库，返回目标库中是否已存在该任务
+// 重试次数（Retried）与最后错误无法通过客户端恢复，迁移后从 0 开始计数
+func (m *Migrator) copyTask(ctx context.Context, info *asynq.TaskInfo, state string) (bool, error) {
+	opts := []asynq.Option{
+		asynq.TaskID(info.ID),
+		asynq.Queue(info.Queue),
+		asynq.MaxRetry(info.MaxRetry),
+	}
+	if info.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(info.Timeout))
+	}
+	if !info.Deadline.IsZero() {
+		opts = append(opts, asynq.Deadline(info.Deadline))
+	}
+	if info.Retention > 0 {
+		opts = append(opts, asynq.Retention(info.Retention))
+	}
+
+	switch state {
+	case StateScheduled, StateRetry:
+		opts = append(opts, asynq.ProcessAt(info.NextProcessAt))
+	case StateArchived:
+		opts = append(opts, asynq.ProcessIn(archivedHold))
+	}
+
+	_, err := m.dstClient.EnqueueContext(ctx, asynq.NewTask(info.Type, info.Payload), opts...)
+	existing := errors.Is(err, asynq.ErrTaskIDConflict)
+	if err != nil && !existing {
+		return false, err
+	}
+
+	if state == StateArchived {
+		// 上次中断可能停在入队与归档之间，已存在时也要确认归档
+		if existing {
+			dst, err := m.dstInspector.GetTaskInfo(info.Queue, info.ID)
+			if err != nil {
+				return true, err
+			}
+			if dst.State == asynq.TaskStateArchived {
+				return true, nil
+			}
+		}
+		if err := m.dstInspector.ArchiveTask(info.Queue, info.ID); err != nil {
+			return existing, fmt.Errorf("archive task: %w", err)
+		}
+	}
+
+	return existing, nil
+}
+
+func stateCount(info *asynq.QueueInfo, state string) int {
+	switch state {
+	case StatePending:
+		return info.Pending
+	case StateScheduled:
+		return info.Scheduled
+	case StateRetry:
+		return info.Retry
+	case StateArchived:
+		return info.Archived
+	default:
+		return 0
+	}
+}
+
+// migrateStreams 按原条目 ID 复制进度流；目标流已有条目时从其最后一个 ID 之后续传
+func (m *Migrator) migrateStreams(ctx context.Context, opts Options, sr *StreamReport) error {
+	iter := m.srcRedis.ScanType(ctx, 0, opts.StreamPattern, int64(opts.PageSize), "stream").Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		sr.Streams++
+
+		if opts.DryRun {
+			n, err := m.srcRedis.XLen(ctx, key).Result()
+			if err != nil {
+				return err
+			}
+			sr.Copied += n
+			continue
+		}
+
+		if err := m.copyStream(ctx, key, int64(opts.PageSize), sr); err != nil {
+			sr.Failed++
+			m.logger.Error("failed to copy progress stream", zap.String("key", key), zap.Error(err))
+		}
+	}
+	return iter.Err()
+}
+
+func (m *Migrator) copyStream(ctx context.Context, key string, batch int64, sr *StreamReport) error {
+	start := "-"
+	last, err := m.dstRedis.XRevRangeN(ctx, key, "+", "-", 1).Result()
+	if err != nil {
+		return err
+	}
+	if len(last) > 0 {
+		start = "(" + last[0].ID
+		existing, err := m.dstRedis.XLen(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		sr.Existing += existing
+	}
+
+	for {
+		entries, err := m.srcRedis.XRangeN(ctx, key, start, "+", batch).Result()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := m.dstRedis.XAdd(ctx, &redis.XAddArgs{
+				Stream: key,
+				ID:     entry.ID,
+				Values: entry.Values,
+			}).Err(); err != nil {
+				return err
+			}
+			sr.Copied++
+		}
+		if int64(len(entries)) < batch {
+			break
+		}
+		start = "(" + entries[len(entries)-1].ID
+	}
+
+	// 保留剩余过期时间
+	ttl, err := m.srcRedis.PTTL(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		if err := m.dstRedis.PExpire(ctx, key, ttl).Err(); err != nil {
+			return err
+		}
+	}
+
+	srcLen, err := m.srcRedis.XLen(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	dstLen, err := m.dstRedis.XLen(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if dstLen != srcLen {
+		sr.Mismatch = append(sr.Mismatch, key)
+	}
+	return nil
+}
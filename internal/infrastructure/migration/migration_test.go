@@ -0,0 +1,123 @@
+package migration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func newEndpoint(t *testing.T) (Endpoint, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	return Endpoint{Addr: mr.Addr()}, mr
+}
+
+func TestMigrateTasksAndStreams(t *testing.T) {
+	src, _ := newEndpoint(t)
+	dst, _ := newEndpoint(t)
+	ctx := context.Background()
+
+	client := asynq.NewClient(src.asynqOpt())
+	defer client.Close()
+	if _, err := client.Enqueue(asynq.NewTask("demo", []byte(`{"n":1}`)), asynq.TaskID("pending-1"), asynq.MaxRetry(5)); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if _, err := client.Enqueue(asynq.NewTask("demo", nil), asynq.TaskID("scheduled-1"), asynq.ProcessIn(time.Hour)); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if _, err := client.Enqueue(asynq.NewTask("demo", nil), asynq.TaskID("archived-1")); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	srcInspector := asynq.NewInspector(src.asynqOpt())
+	defer srcInspector.Close()
+	if err := srcInspector.ArchiveTask("default", "archived-1"); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	srcRedis := redis.NewClient(src.redisOptions())
+	defer srcRedis.Close()
+	for i := 0; i < 3; i++ {
+		if err := srcRedis.XAdd(ctx, &redis.XAddArgs{Stream: "progress:pending-1", Values: map[string]any{"n": i}}).Err(); err != nil {
+			t.Fatalf("xadd: %v", err)
+		}
+	}
+
+	m := New(src, dst, zap.NewNop())
+	defer m.Close()
+
+	report, err := m.Run(ctx, Options{PauseSource: true, PageSize: 2})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !report.Verified() {
+		t.Fatalf("expected verified report, got %+v", report.Queues[0].States)
+	}
+	states := report.Queues[0].States
+	if states[StatePending].Copied != 1 || states[StateScheduled].Copied != 1 || states[StateArchived].Copied != 1 {
+		t.Fatalf("unexpected copy counts: %+v %+v %+v", states[StatePending], states[StateScheduled], states[StateArchived])
+	}
+	if !report.Queues[0].Paused {
+		t.Fatalf("expected source queue to be paused")
+	}
+	if report.Streams.Streams != 1 || report.Streams.Copied != 3 {
+		t.Fatalf("unexpected stream report: %+v", report.Streams)
+	}
+
+	dstInspector := asynq.NewInspector(dst.asynqOpt())
+	defer dstInspector.Close()
+	info, err := dstInspector.GetTaskInfo("default", "pending-1")
+	if err != nil {
+		t.Fatalf("get migrated task: %v", err)
+	}
+	if info.MaxRetry != 5 || string(info.Payload) != `{"n":1}` {
+		t.Fatalf("options not preserved: %+v", info)
+	}
+	if info, _ := dstInspector.GetTaskInfo("default", "archived-1"); info == nil || info.State != asynq.TaskStateArchived {
+		t.Fatalf("expected archived task in destination, got %+v", info)
+	}
+
+	// 再次运行时应跳过已迁移的数据
+	if err := srcRedis.XAdd(ctx, &redis.XAddArgs{Stream: "progress:pending-1", Values: map[string]any{"n": 3}}).Err(); err != nil {
+		t.Fatalf("xadd: %v", err)
+	}
+	report, err = m.Run(ctx, Options{PauseSource: true})
+	if err != nil {
+		t.Fatalf("rerun: %v", err)
+	}
+	if got := report.Queues[0].States[StatePending]; got.Copied != 0 || got.Existing != 1 {
+		t.Fatalf("expected pending task to be skipped on resume, got %+v", got)
+	}
+	if report.Streams.Copied != 1 || report.Streams.Existing != 3 || !report.Verified() {
+		t.Fatalf("expected only the new stream entry to be copied, got %+v", report.Streams)
+	}
+}
+
+func TestDryRunDoesNotWrite(t *testing.T) {
+	src, _ := newEndpoint(t)
+	dst, dstServer := newEndpoint(t)
+
+	client := asynq.NewClient(src.asynqOpt())
+	defer client.Close()
+	if _, err := client.Enqueue(asynq.NewTask("demo", nil), asynq.Queue("critical")); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	m := New(src, dst, zap.NewNop())
+	defer m.Close()
+
+	report, err := m.Run(context.Background(), Options{DryRun: true, PauseSource: true, Queues: []string{"critical"}})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if report.Queues[0].States[StatePending].Source != 1 || report.Queues[0].Paused {
+		t.Fatalf("unexpected dry-run report: %+v", report.Queues[0])
+	}
+	if keys := dstServer.Keys(); len(keys) != 0 {
+		t.Fatalf("expected destination to stay empty, got %v", keys)
+	}
+}
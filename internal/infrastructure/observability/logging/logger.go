@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"fmt"
 	"os"
 
 	"go.uber.org/zap"
@@ -10,26 +11,90 @@ import (
 )
 
 func NewLogger(cfg *config.LoggingConfig) (*zap.Logger, error) {
+	logger, _, err := NewLevelLogger(cfg)
+	return logger, err
+}
+
+// NewLevelLogger 创建 logger，并返回可在运行时调整的日志级别
+func NewLevelLogger(cfg *config.LoggingConfig) (*zap.Logger, zap.AtomicLevel, error) {
+	level := zap.NewAtomicLevelAt(ParseLevel(cfg.Level))
+	logger, err := newLogger(level, cfg.Format, cfg.Output)
+	return logger, level, err
+}
+
+// NewDebugLogger 创建与应用 logger 输出相同、固定 debug 级别的 logger，用于调试采集中的任务
+func NewDebugLogger(cfg *config.LoggingConfig) (*zap.Logger, error) {
+	return newLogger(zapcore.DebugLevel, cfg.Format, cfg.Output)
+}
+
+// ParseLevel 解析日志级别，无法识别时使用 info
+func ParseLevel(text string) zapcore.Level {
 	var level zapcore.Level
-	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
-		level = zapcore.InfoLevel
+	if err := level.UnmarshalText([]byte(text)); err != nil {
+		return zapcore.InfoLevel
+	}
+	return level
+}
+
+// NewAccessLogger 创建 HTTP 访问日志 logger
+// 未启用独立配置时直接复用应用 logger
+func NewAccessLogger(cfg *config.LoggingConfig, base *zap.Logger) (*zap.Logger, error) {
+	if !cfg.Access.Enabled {
+		return base, nil
+	}
+
+	level := cfg.Access.Level
+	if level == "" {
+		level = cfg.Level
+	}
+	format := cfg.Access.Format
+	if format == "" {
+		format = cfg.Format
+	}
+
+	return newLogger(ParseLevel(level), format, cfg.Access.Output)
+}
+
+// NewAuditLogger 创建写操作审计日志 logger，固定 info 级别，不受日志级别热更新影响
+// 未配置 output 时使用应用 logger 的 audit 子 logger
+func NewAuditLogger(cfg *config.LoggingConfig, base *zap.Logger) (*zap.Logger, error) {
+	if cfg.Audit.Output == "" {
+		return base.Named("audit"), nil
 	}
 
+	format := cfg.Audit.Format
+	if format == "" {
+		format = cfg.Format
+	}
+	logger, err := newLogger(zapcore.InfoLevel, format, cfg.Audit.Output)
+	if err != nil {
+		return nil, err
+	}
+	return logger.Named("audit"), nil
+}
+
+func newLogger(level zapcore.LevelEnabler, format, output string) (*zap.Logger, error) {
+
 	var encoder zapcore.Encoder
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.TimeKey = "timestamp"
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 
-	if cfg.Format == "console" {
+	if format == "console" {
 		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 		encoder = zapcore.NewConsoleEncoder(encoderConfig)
 	} else {
 		encoder = zapcore.NewJSONEncoder(encoderConfig)
 	}
 
+	sink, err := openSink(output)
+	if err != nil {
+		return nil, err
+	}
+
 	core := zapcore.NewCore(
 		encoder,
-		zapcore.AddSync(os.Stdout),
+		sink,
 		level,
 	)
 
@@ -41,6 +106,22 @@ func NewLogger(cfg *config.LoggingConfig) (*zap.Logger, error) {
 	return logger, nil
 }
 
+// openSink 解析日志输出目标：stdout（默认）、stderr 或文件路径
+func openSink(output string) (zapcore.WriteSyncer, error) {
+	switch output {
+	case "", "stdout":
+		return zapcore.AddSync(os.Stdout), nil
+	case "stderr":
+		return zapcore.AddSync(os.Stderr), nil
+	default:
+		f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log output %s: %w", output, err)
+		}
+		return zapcore.AddSync(f), nil
+	}
+}
+
 func NewDevelopmentLogger() (*zap.Logger, error) {
 	cfg := zap.NewDevelopmentConfig()
 	cfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
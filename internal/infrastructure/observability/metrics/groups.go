@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+)
+
+// GroupLister 列出队列中等待聚合的分组（按大小降序）
+type GroupLister interface {
+	ListGroups(queue string) ([]asynqqueue.GroupStats, error)
+}
+
+// groupCollector 抓取时读取每个队列中最大的 topN 个分组
+// 分组名基数不可控，只导出最大的几个，避免指标爆炸
+type groupCollector struct {
+	lister GroupLister
+	queues []string
+	topN   int
+	desc   *prometheus.Desc
+}
+
+// RegisterGroups 注册分组待聚合任务数指标
+func (m *Metrics) RegisterGroups(lister GroupLister, queues []string, topN int) {
+	m.registry.MustRegister(&groupCollector{
+		lister: lister,
+		queues: queues,
+		topN:   topN,
+		desc: prometheus.NewDesc(
+			namespace+"_group_pending_tasks",
+			"Tasks waiting for aggregation in the largest groups of each queue.",
+			[]string{"queue", "group"}, nil,
+		),
+	})
+}
+
+func (c *groupCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *groupCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, queue := range c.queues {
+		groups, err := c.lister.ListGroups(queue)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.desc, err)
+			continue
+		}
+		if len(groups) > c.topN {
+			groups = groups[:c.topN]
+		}
+		for _, g := range groups {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(g.Size), queue, g.Group)
+		}
+	}
+}
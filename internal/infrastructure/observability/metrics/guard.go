@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// OtherLabelValue 超出基数上限后新标签值归入的桶
+const OtherLabelValue = "other"
+
+// LabelGuard 限制每个指标标签的不同取值数量，防止用户可控的取值（任务类型、方法、租户等）
+// 造成指标基数失控。超出上限后出现的新取值统一记为 OtherLabelValue，已记录的取值不受影响
+type LabelGuard struct {
+	limit  int
+	logger *zap.Logger
+
+	mu     sync.Mutex
+	seen   map[string]map[string]struct{} // 指标标签 -> 已记录的取值
+	warned map[string]bool
+}
+
+// NewLabelGuard 创建标签基数保护，limit 不大于 0 时不限制
+func NewLabelGuard(limit int, logger *zap.Logger) *LabelGuard {
+	return &LabelGuard{
+		limit:  limit,
+		logger: logger,
+		seen:   make(map[string]map[string]struct{}),
+		warned: make(map[string]bool),
+	}
+}
+
+// Value 返回 label 在指标中应使用的取值，label 建议使用 "<指标名>/<标签名>" 区分不同指标
+// 每个 label 第一次超出上限时记录一条警告
+func (g *LabelGuard) Value(label, value string) string {
+	if g == nil || g.limit <= 0 {
+		return value
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	values, ok := g.seen[label]
+	if !ok {
+		values = make(map[string]struct{})
+		g.seen[label] = values
+	}
+	if _, ok := values[value]; ok {
+		return value
+	}
+	if len(values) < g.limit {
+		values[value] = struct{}{}
+		return value
+	}
+
+	if !g.warned[label] {
+		g.warned[label] = true
+		g.logger.Warn("metric label cardinality limit reached, collapsing new values",
+			zap.String("label", label),
+			zap.Int("limit", g.limit),
+			zap.String("first_collapsed", value),
+		)
+	}
+	return OtherLabelValue
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLabelGuardCollapsesValuesBeyondLimit(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	guard := NewLabelGuard(2, zap.New(core))
+
+	got := []string{
+		guard.Value("m/type", "a"),
+		guard.Value("m/type", "b"),
+		guard.Value("m/type", "c"),
+		guard.Value("m/type", "a"),
+		guard.Value("m/type", "d"),
+		guard.Value("other/type", "c"),
+	}
+	want := []string{"a", "b", OtherLabelValue, "a", OtherLabelValue, "c"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("value %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+	if n := logs.Len(); n != 1 {
+		t.Fatalf("expected one warning per label, got %d", n)
+	}
+}
+
+func TestObserveTaskCollapsesTaskTypes(t *testing.T) {
+	m := New(WithLabelGuard(NewLabelGuard(1, zap.NewNop())))
+
+	m.ObserveTask("demo", "success", time.Second)
+	m.ObserveTask("tenant-123:export", "success", time.Second)
+	m.ObserveTask("tenant-456:export", "success", time.Second)
+
+	if got := testutil.ToFloat64(m.tasksTotal.WithLabelValues("demo", "success")); got != 1 {
+		t.Fatalf("expected 1 demo task, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.tasksTotal.WithLabelValues(OtherLabelValue, "success")); got != 2 {
+		t.Fatalf("expected 2 tasks in the other bucket, got %v", got)
+	}
+}
+
+func TestObserveTaskAttachesInstanceExemplar(t *testing.T) {
+	m := New(WithWorkerInstance("worker-1-abcd1234", "v1.2.3"))
+	m.ObserveTask("demo", "success", time.Second)
+
+	families, err := m.registry.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	var exemplar, info bool
+	for _, mf := range families {
+		switch mf.GetName() {
+		case "taskflow_tasks_processed_total":
+			for _, l := range mf.GetMetric()[0].GetCounter().GetExemplar().GetLabel() {
+				if l.GetName() == "instance_id" && l.GetValue() == "worker-1-abcd1234" {
+					exemplar = true
+				}
+			}
+			if n := len(mf.GetMetric()[0].GetLabel()); n != 2 {
+				t.Fatalf("expected only type and status labels, got %d", n)
+			}
+		case "taskflow_worker_info":
+			info = true
+		}
+	}
+	if !exemplar || !info {
+		t.Fatalf("expected an instance exemplar (%v) and worker info (%v)", exemplar, info)
+	}
+}
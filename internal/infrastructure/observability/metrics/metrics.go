@@ -0,0 +1,347 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "taskflow"
+
+// Metrics worker 侧的 Prometheus 指标
+type Metrics struct {
+	registry *prometheus.Registry
+
+	tasksTotal   *prometheus.CounterVec
+	taskDuration *prometheus.HistogramVec
+	panicsTotal  *prometheus.CounterVec
+	redisOps     *prometheus.HistogramVec
+	enqueued     *prometheus.CounterVec
+	payloadBytes *prometheus.HistogramVec
+	enqueueCalls *prometheus.HistogramVec
+	enqueueFails *prometheus.CounterVec
+	conflicts    *prometheus.CounterVec
+	deprecated   *prometheus.CounterVec
+	promoted     *prometheus.CounterVec
+	staleQueues  *prometheus.CounterVec
+
+	// queueStats RegisterQueues 注册的队列统计，删除队列后丢弃其缓存
+	queueStats *queueCollector
+
+	// enqueueQueues 入队指标中出现过的队列，ForgetMissingQueues 据此删除已不存在的队列
+	queuesMu      sync.Mutex
+	enqueueQueues map[string]struct{}
+
+	labels *LabelGuard
+	// exemplar 任务指标的 exemplar 标签，记录处理任务的 worker 实例而不增加序列
+	exemplar prometheus.Labels
+}
+
+// Option 指标可选项
+type Option func(*Metrics)
+
+// WithLabelGuard 限制任务类型等用户可控标签的取值数量
+func WithLabelGuard(g *LabelGuard) Option {
+	return func(m *Metrics) {
+		m.labels = g
+	}
+}
+
+// WithWorkerInstance 导出 taskflow_worker_info{instance_id,version} 并在任务数量和耗时上附加实例 ID 的 exemplar。
+// 实例 ID 每次启动都不同，不作为任务指标的标签，避免序列数随重启增长；exemplar 需以 OpenMetrics 格式抓取
+func WithWorkerInstance(instanceID, version string) Option {
+	return func(m *Metrics) {
+		info := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "worker_info",
+			Help:        "Identity of this worker instance, always 1.",
+			ConstLabels: prometheus.Labels{"instance_id": instanceID, "version": version},
+		})
+		info.Set(1)
+		m.registry.MustRegister(info)
+		m.exemplar = prometheus.Labels{"instance_id": instanceID}
+	}
+}
+
+// New 创建独立 registry 的指标集合，包含 Go 运行时和进程指标
+func New(opts ...Option) *Metrics {
+	m := &Metrics{
+		registry:      prometheus.NewRegistry(),
+		enqueueQueues: make(map[string]struct{}),
+		tasksTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tasks_processed_total",
+			Help:      "Number of tasks processed, by task type and attempt status (completed, failed, cancelled, timed_out, or retry for failed attempts asynq will retry).",
+		}, []string{"type", "status"}),
+		taskDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "task_duration_seconds",
+			Help:      "Task processing duration in seconds, by task type and status.",
+			Buckets:   prometheus.ExponentialBuckets(0.05, 2, 14),
+		}, []string{"type", "status"}),
+		panicsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "task_panics_total",
+			Help:      "Number of task handler panics recovered, by task type and panic class.",
+		}, []string{"type", "class"}),
+		redisOps: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "progress_redis_duration_seconds",
+			Help:      "Duration of Redis stream operations in the progress layer, by operation. Blocking reads are excluded.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"op"}),
+		enqueued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tasks_enqueued_total",
+			Help:      "Number of tasks enqueued through the API, by task type and queue.",
+		}, []string{"type", "queue"}),
+		payloadBytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "task_payload_bytes",
+			Help:      "Size in bytes of enqueued task payloads, by task type.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 10),
+		}, []string{"type"}),
+		enqueueCalls: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "enqueue_duration_seconds",
+			Help:      "Duration of enqueue calls to Redis, including failed and conflicting ones, by task type and queue.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"type", "queue"}),
+		enqueueFails: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "enqueue_errors_total",
+			Help:      "Number of enqueue calls that failed, excluding unique and task ID conflicts, by task type and queue.",
+		}, []string{"type", "queue"}),
+		conflicts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "enqueue_conflicts_total",
+			Help:      "Number of enqueue calls rejected because the task ID already exists or a unique lock is held, by task type and queue.",
+		}, []string{"type", "queue"}),
+		deprecated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "deprecated_tasks_total",
+			Help:      "Number of task creation requests matching a configured deprecation, by deprecation name and outcome (accepted, migrated, rejected).",
+		}, []string{"deprecation", "outcome"}),
+		promoted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tasks_promoted_total",
+			Help:      "Number of pending tasks moved to a higher-weight queue after waiting too long, by source and target queue.",
+		}, []string{"from", "to"}),
+		staleQueues: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stale_queues_total",
+			Help:      "Number of unconfigured queues found empty for longer than queue_cleanup.retention, by result (deleted, dry_run, failed).",
+		}, []string{"result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.tasksTotal,
+		m.taskDuration,
+		m.panicsTotal,
+		m.redisOps,
+		m.enqueued,
+		m.payloadBytes,
+		m.enqueueCalls,
+		m.enqueueFails,
+		m.conflicts,
+		m.deprecated,
+		m.promoted,
+		m.staleQueues,
+	)
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// ObserveTask 记录一次正常结束（未 panic）的任务，status 为结束状态
+func (m *Metrics) ObserveTask(taskType, status string, duration time.Duration) {
+	taskType = m.labels.Value("tasks_processed_total/type", taskType)
+	if m.exemplar == nil {
+		m.tasksTotal.WithLabelValues(taskType, status).Inc()
+		m.taskDuration.WithLabelValues(taskType, status).Observe(duration.Seconds())
+		return
+	}
+	m.tasksTotal.WithLabelValues(taskType, status).(prometheus.ExemplarAdder).AddWithExemplar(1, m.exemplar)
+	m.taskDuration.WithLabelValues(taskType, status).(prometheus.ExemplarObserver).ObserveWithExemplar(duration.Seconds(), m.exemplar)
+}
+
+// RecordPanic 记录一次被恢复的 panic
+func (m *Metrics) RecordPanic(taskType, class string) {
+	taskType = m.labels.Value("task_panics_total/type", taskType)
+	m.panicsTotal.WithLabelValues(taskType, class).Inc()
+}
+
+// ObserveEnqueue 记录一次成功入队的任务及其 payload 大小
+func (m *Metrics) ObserveEnqueue(taskType, queue string, payloadBytes int) {
+	taskType = m.labels.Value("tasks_enqueued_total/type", taskType)
+	queue = m.labels.Value("tasks_enqueued_total/queue", queue)
+	m.trackQueue(queue)
+	m.enqueued.WithLabelValues(taskType, queue).Inc()
+	m.payloadBytes.WithLabelValues(taskType).Observe(float64(payloadBytes))
+}
+
+// ObserveEnqueueCall 记录一次入队调用的耗时，result 为 ok、conflict 或 failed
+func (m *Metrics) ObserveEnqueueCall(taskType, queue, result string, d time.Duration) {
+	taskType = m.labels.Value("enqueue_duration_seconds/type", taskType)
+	queue = m.labels.Value("enqueue_duration_seconds/queue", queue)
+	m.trackQueue(queue)
+	m.enqueueCalls.WithLabelValues(taskType, queue).Observe(d.Seconds())
+	switch result {
+	case "conflict":
+		m.conflicts.WithLabelValues(taskType, queue).Inc()
+	case "failed":
+		m.enqueueFails.WithLabelValues(taskType, queue).Inc()
+	}
+}
+
+// ObserveDeprecated 记录一次命中弃用项的创建请求，name 来自配置，取值有限
+func (m *Metrics) ObserveDeprecated(name, outcome string) {
+	m.deprecated.WithLabelValues(name, outcome).Inc()
+}
+
+// ObservePromotion 记录一次老化提升，队列来自配置，取值有限
+func (m *Metrics) ObservePromotion(from, to string) {
+	m.promoted.WithLabelValues(from, to).Inc()
+}
+
+// ObserveQueueCleanup 记录一次过期队列的处理结果
+func (m *Metrics) ObserveQueueCleanup(result string) {
+	m.staleQueues.WithLabelValues(result).Inc()
+}
+
+// ForgetQueue 删除已删除队列的入队指标标签组合，并从缓存的队列统计中移除该队列，
+// 之后的抓取不再报告这个队列
+func (m *Metrics) ForgetQueue(queue string) {
+	labels := prometheus.Labels{"queue": queue}
+	m.enqueued.DeletePartialMatch(labels)
+	m.enqueueCalls.DeletePartialMatch(labels)
+	m.enqueueFails.DeletePartialMatch(labels)
+	m.conflicts.DeletePartialMatch(labels)
+	if m.queueStats != nil {
+		m.queueStats.forget(queue)
+	}
+	m.queuesMu.Lock()
+	delete(m.enqueueQueues, queue)
+	m.queuesMu.Unlock()
+}
+
+func (m *Metrics) trackQueue(queue string) {
+	if queue == OtherLabelValue {
+		return
+	}
+	m.queuesMu.Lock()
+	m.enqueueQueues[queue] = struct{}{}
+	m.queuesMu.Unlock()
+}
+
+// ObserveRedisOperation 记录一次进度层 Redis 操作的耗时
+func (m *Metrics) ObserveRedisOperation(op string, d time.Duration) {
+	m.redisOps.WithLabelValues(op).Observe(d.Seconds())
+}
+
+// MetadataFailureCounter 提供进度 metadata 编码失败次数与完成事件投递情况
+type MetadataFailureCounter interface {
+	MetadataMarshalFailures() uint64
+	CompletionsDropped() uint64
+	PendingCompletions() int
+}
+
+// RegisterProgressPublisher 注册进度 metadata 编码失败次数与完成事件投递指标
+func (m *Metrics) RegisterProgressPublisher(p MetadataFailureCounter) {
+	m.registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "progress_metadata_marshal_failures_total",
+		Help:      "Number of progress messages whose metadata could not be encoded and was sanitized.",
+	}, func() float64 {
+		return float64(p.MetadataMarshalFailures())
+	}))
+	m.registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "progress_completions_dropped_total",
+		Help:      "Number of completion events that could not be written to Redis within the retry window.",
+	}, func() float64 {
+		return float64(p.CompletionsDropped())
+	}))
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "progress_completions_pending",
+		Help:      "Number of completion events buffered in memory awaiting retry.",
+	}, func() float64 {
+		return float64(p.PendingCompletions())
+	}))
+}
+
+// SubscriptionStats 提供 API 进程中进度订阅的连接使用情况
+type SubscriptionStats interface {
+	ActiveSubscriptions() int
+	ReservedSubscriptions() int
+	SubscriptionCapacity() int
+	SubscriptionConnsInUse() int
+	SubscriptionRejections() uint64
+}
+
+// RegisterProgressSubscriber 注册进度订阅连接指标
+func (m *Metrics) RegisterProgressSubscriber(s SubscriptionStats) {
+	gauges := []struct {
+		name, help string
+		value      func() int
+	}{
+		{"progress_subscriptions_active", "Number of progress subscriptions currently reading from Redis.", s.ActiveSubscriptions},
+		{"progress_subscriptions_reserved", "Number of subscription slots reserved by open SSE connections.", s.ReservedSubscriptions},
+		{"progress_subscriptions_capacity", "Maximum number of subscription slots, 0 when unlimited.", s.SubscriptionCapacity},
+		{"progress_subscription_connections_in_use", "Number of Redis connections in use by the subscription pool.", s.SubscriptionConnsInUse},
+	}
+	for _, g := range gauges {
+		value := g.value
+		m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      g.name,
+			Help:      g.help,
+		}, func() float64 {
+			return float64(value())
+		}))
+	}
+	m.registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "progress_subscription_rejections_total",
+		Help:      "Number of progress subscriptions rejected because the subscription pool was exhausted.",
+	}, func() float64 {
+		return float64(s.SubscriptionRejections())
+	}))
+}
+
+// CanaryHealth 提供进度自检的最近结果
+type CanaryHealth interface {
+	Healthy() bool
+}
+
+// RegisterProgressCanary 注册进度自检指标，1 表示最近一次自检通过
+func (m *Metrics) RegisterProgressCanary(c CanaryHealth) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "progress_canary_healthy",
+		Help:      "Whether the latest progress self-test passed (1) or failed (0).",
+	}, func() float64 {
+		if c.Healthy() {
+			return 1
+		}
+		return 0
+	}))
+}
+
+// Registry 返回底层 registry，便于注册其他指标
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler 返回 /metrics 端点的 HTTP 处理器
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry, EnableOpenMetrics: true})
+}
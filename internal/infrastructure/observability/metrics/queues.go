@@ -0,0 +1,206 @@
+package metrics
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+// QueueInspector 读取所有队列的统计信息和最早的任务
+type QueueInspector interface {
+	QueueInfos() ([]*asynq.QueueInfo, error)
+	OldestTasks(queue string) (*asynqqueue.OldestTasks, error)
+}
+
+// queueCollector 抓取时读取队列统计，同一次抓取的所有指标来自同一份快照。
+// 快照缓存 ttl，频繁抓取不会放大 Redis 负载
+type queueCollector struct {
+	inspector QueueInspector
+	ttl       time.Duration
+	clock     clock.Clock
+
+	mu        sync.Mutex
+	infos     []*asynq.QueueInfo
+	oldest    map[string]*asynqqueue.OldestTasks
+	err       error
+	fetchedAt time.Time
+
+	size      *prometheus.Desc
+	tasks     *prometheus.Desc
+	paused    *prometheus.Desc
+	latency   *prometheus.Desc
+	processed *prometheus.Desc
+	failed    *prometheus.Desc
+	oldestAge *prometheus.Desc
+}
+
+// RegisterQueues 注册队列大小、各状态任务数、暂停状态、延迟、最早任务等待时间和当日处理/失败数指标
+func (m *Metrics) RegisterQueues(inspector QueueInspector, ttl time.Duration, clk clock.Clock) {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(namespace+"_queue_"+name, help, append([]string{"queue"}, labels...), nil)
+	}
+	m.queueStats = &queueCollector{
+		inspector: inspector,
+		ttl:       ttl,
+		clock:     clock.OrReal(clk),
+
+		size:      desc("size", "Tasks in the queue, excluding completed tasks."),
+		tasks:     desc("tasks", "Tasks in the queue by state.", "state"),
+		paused:    desc("paused", "Whether the queue is paused (1) or not (0)."),
+		latency:   desc("latency_seconds", "Age of the oldest pending task in seconds."),
+		processed: desc("processed_today", "Tasks processed today (UTC), including failures."),
+		failed:    desc("failed_today", "Tasks that failed today (UTC)."),
+		oldestAge: desc("oldest_task_age_seconds", "Age of the oldest pending task, or how long the earliest scheduled task is overdue, in seconds. 0 when there is none.", "state"),
+	}
+	m.registry.MustRegister(m.queueStats)
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.size
+	ch <- c.tasks
+	ch <- c.paused
+	ch <- c.latency
+	ch <- c.processed
+	ch <- c.failed
+	ch <- c.oldestAge
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	infos, oldest, err := c.snapshot()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.size, err)
+		return
+	}
+
+	gauge := func(desc *prometheus.Desc, v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, labels...)
+	}
+	for _, info := range infos {
+		q := info.Queue
+		gauge(c.size, float64(info.Size), q)
+		for state, n := range map[string]int{
+			"pending":     info.Pending,
+			"active":      info.Active,
+			"scheduled":   info.Scheduled,
+			"retry":       info.Retry,
+			"archived":    info.Archived,
+			"completed":   info.Completed,
+			"aggregating": info.Aggregating,
+		} {
+			gauge(c.tasks, float64(n), q, state)
+		}
+		paused := 0.0
+		if info.Paused {
+			paused = 1
+		}
+		gauge(c.paused, paused, q)
+		gauge(c.latency, info.Latency.Seconds(), q)
+		gauge(c.processed, float64(info.Processed), q)
+		gauge(c.failed, float64(info.Failed), q)
+		if o, ok := oldest[q]; ok {
+			gauge(c.oldestAge, oldestAge(o.Pending).Seconds(), q, "pending")
+			gauge(c.oldestAge, oldestAge(o.Scheduled).Seconds(), q, "scheduled")
+		}
+	}
+}
+
+// snapshot 返回缓存的队列统计，过期后重新读取；并发抓取共用同一次读取。
+// 读取最早任务失败的队列不导出等待时间指标
+func (c *queueCollector) snapshot() ([]*asynq.QueueInfo, map[string]*asynqqueue.OldestTasks, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.fetchedAt.IsZero() && c.clock.Since(c.fetchedAt) < c.ttl {
+		return c.infos, c.oldest, c.err
+	}
+	c.infos, c.err = c.inspector.QueueInfos()
+	c.oldest = make(map[string]*asynqqueue.OldestTasks, len(c.infos))
+	for _, info := range c.infos {
+		if o, err := c.inspector.OldestTasks(info.Queue); err == nil {
+			c.oldest[info.Queue] = o
+		}
+	}
+	c.fetchedAt = c.clock.Now()
+	return c.infos, c.oldest, c.err
+}
+
+// forget 从缓存的快照中移除已删除的队列，不必等缓存过期
+func (c *queueCollector) forget(queue string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.infos = slices.DeleteFunc(slices.Clone(c.infos), func(info *asynq.QueueInfo) bool {
+		return info.Queue == queue
+	})
+	delete(c.oldest, queue)
+}
+
+func oldestAge(t *asynqqueue.OldestTask) time.Duration {
+	if t == nil {
+		return 0
+	}
+	return t.Age
+}
+
+// QueueLister 列出 Redis 中现有的队列
+type QueueLister interface {
+	GetQueues() ([]string, error)
+}
+
+// ForgetMissingQueues 删除已不在 Redis 中的队列的入队指标，返回被删除的队列。
+// 队列由 worker 的过期队列清理删除，只记录入队指标的 API 进程需要据此同步删除。
+// 先取已记录的队列再列出现有队列，列出之后才第一次入队的队列不会被误删
+func (m *Metrics) ForgetMissingQueues(lister QueueLister) ([]string, error) {
+	m.queuesMu.Lock()
+	tracked := make([]string, 0, len(m.enqueueQueues))
+	for queue := range m.enqueueQueues {
+		tracked = append(tracked, queue)
+	}
+	m.queuesMu.Unlock()
+	if len(tracked) == 0 {
+		return nil, nil
+	}
+
+	queues, err := lister.GetQueues()
+	if err != nil {
+		return nil, err
+	}
+	var forgotten []string
+	for _, queue := range tracked {
+		if !slices.Contains(queues, queue) {
+			m.ForgetQueue(queue)
+			forgotten = append(forgotten, queue)
+		}
+	}
+	slices.Sort(forgotten)
+	return forgotten, nil
+}
+
+// RunForgetMissingQueues 每隔 interval 调用一次 ForgetMissingQueues，直到 ctx 结束
+func (m *Metrics) RunForgetMissingQueues(ctx context.Context, lister QueueLister, interval time.Duration, clk clock.Clock, logger *zap.Logger) {
+	ticker := clock.OrReal(clk).NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			forgotten, err := m.ForgetMissingQueues(lister)
+			if err != nil {
+				logger.Warn("failed to list queues for metric cleanup", zap.Error(err))
+				continue
+			}
+			if len(forgotten) > 0 {
+				logger.Info("dropped enqueue metrics of deleted queues", zap.Strings("queues", forgotten))
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+
+	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+type fakeQueueInspector struct {
+	calls int
+}
+
+func (f *fakeQueueInspector) QueueInfos() ([]*asynq.QueueInfo, error) {
+	f.calls++
+	return []*asynq.QueueInfo{{
+		Queue:     "default",
+		Size:      7,
+		Pending:   5,
+		Active:    2,
+		Paused:    true,
+		Latency:   90 * time.Second,
+		Processed: 120,
+		Failed:    3,
+	}}, nil
+}
+
+func (f *fakeQueueInspector) OldestTasks(queue string) (*asynqqueue.OldestTasks, error) {
+	return &asynqqueue.OldestTasks{
+		Queue:   queue,
+		Pending: &asynqqueue.OldestTask{ID: "t1", Type: "demo", Age: 90 * time.Second},
+	}, nil
+}
+
+func TestQueueCollectorExportsCachedSnapshot(t *testing.T) {
+	inspector := &fakeQueueInspector{}
+	fake := clock.NewFake(time.Unix(0, 0))
+	m := New()
+	m.RegisterQueues(inspector, 10*time.Second, fake)
+
+	expected := `
+# HELP taskflow_queue_latency_seconds Age of the oldest pending task in seconds.
+# TYPE taskflow_queue_latency_seconds gauge
+taskflow_queue_latency_seconds{queue="default"} 90
+# HELP taskflow_queue_paused Whether the queue is paused (1) or not (0).
+# TYPE taskflow_queue_paused gauge
+taskflow_queue_paused{queue="default"} 1
+# HELP taskflow_queue_failed_today Tasks that failed today (UTC).
+# TYPE taskflow_queue_failed_today gauge
+taskflow_queue_failed_today{queue="default"} 3
+# HELP taskflow_queue_oldest_task_age_seconds Age of the oldest pending task, or how long the earliest scheduled task is overdue, in seconds. 0 when there is none.
+# TYPE taskflow_queue_oldest_task_age_seconds gauge
+taskflow_queue_oldest_task_age_seconds{queue="default",state="pending"} 90
+taskflow_queue_oldest_task_age_seconds{queue="default",state="scheduled"} 0
+`
+	err := testutil.GatherAndCompare(m.Registry(), strings.NewReader(expected),
+		"taskflow_queue_latency_seconds", "taskflow_queue_paused", "taskflow_queue_failed_today",
+		"taskflow_queue_oldest_task_age_seconds")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Registry().Gather(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inspector.calls != 1 {
+		t.Fatalf("expected scrapes within the ttl to share one read, got %d", inspector.calls)
+	}
+
+	fake.Advance(10 * time.Second)
+	if _, err := m.Registry().Gather(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inspector.calls != 2 {
+		t.Fatalf("expected a new read after the ttl, got %d", inspector.calls)
+	}
+}
+
+func TestForgetQueueRemovesDeletedQueueSeries(t *testing.T) {
+	inspector := &fakeQueueInspector{}
+	m := New()
+	m.RegisterQueues(inspector, time.Hour, clock.NewFake(time.Unix(0, 0)))
+	m.ObserveEnqueue("demo", "default", 10)
+	m.ObserveEnqueue("demo", "low", 10)
+	if _, err := m.Registry().Gather(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m.ForgetQueue("default")
+
+	// 缓存的快照未过期，也不再报告已删除的队列
+	if n := testutil.CollectAndCount(m.queueStats, "taskflow_queue_size"); n != 0 {
+		t.Fatalf("expected no queue size series, got %d", n)
+	}
+	if n := testutil.CollectAndCount(m.enqueued); n != 1 {
+		t.Fatalf("expected only the low queue to remain, got %d series", n)
+	}
+	if inspector.calls != 1 {
+		t.Fatalf("expected the cached snapshot to be reused, got %d reads", inspector.calls)
+	}
+}
+
+type fakeQueueLister struct {
+	queues []string
+}
+
+func (f *fakeQueueLister) GetQueues() ([]string, error) {
+	return f.queues, nil
+}
+
+func TestForgetMissingQueuesDropsDeletedQueues(t *testing.T) {
+	m := New(WithLabelGuard(NewLabelGuard(2, zap.NewNop())))
+	m.ObserveEnqueue("demo", "default", 10)
+	m.ObserveEnqueue("demo", "defualt", 10)
+	m.ObserveEnqueueCall("demo", "defualt", "failed", time.Millisecond)
+	// 超出基数上限的队列归入 other，不随某个队列删除
+	m.ObserveEnqueue("demo", "extra", 10)
+
+	forgotten, err := m.ForgetMissingQueues(&fakeQueueLister{queues: []string{"default", "low"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(forgotten) != 1 || forgotten[0] != "defualt" {
+		t.Fatalf("expected only the deleted queue to be forgotten, got %v", forgotten)
+	}
+	if n := testutil.CollectAndCount(m.enqueued); n != 2 {
+		t.Fatalf("expected the default and other series to remain, got %d", n)
+	}
+	if n := testutil.CollectAndCount(m.enqueueFails); n != 0 {
+		t.Fatalf("expected the failure series of the deleted queue to be dropped, got %d", n)
+	}
+
+	// 已删除的队列不再重复处理
+	forgotten, err = m.ForgetMissingQueues(&fakeQueueLister{queues: []string{"default"}})
+	if err != nil || len(forgotten) != 0 {
+		t.Fatalf("expected nothing more to forget, got %v (err %v)", forgotten, err)
+	}
+}
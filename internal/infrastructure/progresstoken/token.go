@@ -0,0 +1,146 @@
+// Package progresstoken 签发和校验下游执行方通过 HTTP 上报进度所用的任务级令牌
+package progresstoken
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	revokedKeyPrefix      = "taskflow:progress_token:revoked:"
+	revokedTokenKeyPrefix = "taskflow:progress_token:revoked_token:"
+)
+
+var (
+	// ErrInvalid 令牌格式错误、签名不符或不属于该任务
+	ErrInvalid = errors.New("invalid progress token")
+	// ErrExpired 令牌已过期
+	ErrExpired = errors.New("progress token expired")
+	// ErrRevoked 任务已取消或已上报完成，令牌不再有效
+	ErrRevoked = errors.New("progress token revoked")
+)
+
+// Claims 令牌携带的内容
+type Claims struct {
+	TaskID string `json:"tid"`
+	Queue  string `json:"q,omitempty"`
+	// Attempt 第几次执行（从 1 开始），每次执行的令牌不同，可单独吊销
+	Attempt   int   `json:"att,omitempty"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// Signer 使用 HMAC-SHA256 签发令牌，API 和 worker 须配置相同的 secret
+type Signer struct {
+	secret []byte
+	maxTTL time.Duration
+}
+
+// NewSigner maxTTL 为令牌的最长有效期，任务截止时间更晚时按 maxTTL 截断
+func NewSigner(secret string, maxTTL time.Duration) *Signer {
+	return &Signer{secret: []byte(secret), maxTTL: maxTTL}
+}
+
+// MaxTTL 令牌的最长有效期，也是吊销记录的保留时间
+func (s *Signer) MaxTTL() time.Duration {
+	return s.maxTTL
+}
+
+// Sign 签发 taskID 第 attempt 次执行的令牌，在 expiresAt 和 now+maxTTL 中较早的时间过期
+func (s *Signer) Sign(taskID, queue string, attempt int, now, expiresAt time.Time) string {
+	if limit := now.Add(s.maxTTL); expiresAt.IsZero() || expiresAt.After(limit) {
+		expiresAt = limit
+	}
+	claims, _ := json.Marshal(Claims{TaskID: taskID, Queue: queue, Attempt: attempt, ExpiresAt: expiresAt.Unix()})
+	body := base64.RawURLEncoding.EncodeToString(claims)
+	return body + "." + base64.RawURLEncoding.EncodeToString(s.mac(body))
+}
+
+// Verify 检查令牌的签名、所属任务和有效期
+func (s *Signer) Verify(token, taskID string, now time.Time) (Claims, error) {
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return Claims{}, ErrInvalid
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.mac(body)) {
+		return Claims{}, ErrInvalid
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return Claims{}, ErrInvalid
+	}
+	var c Claims
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return Claims{}, ErrInvalid
+	}
+	if c.TaskID != taskID {
+		return Claims{}, ErrInvalid
+	}
+	if now.Unix() >= c.ExpiresAt {
+		return Claims{}, ErrExpired
+	}
+	return c, nil
+}
+
+func (s *Signer) mac(body string) []byte {
+	m := hmac.New(sha256.New, s.secret)
+	m.Write([]byte(body))
+	return m.Sum(nil)
+}
+
+// Revocations 在 Redis 中记录已吊销的令牌：任务取消时吊销该任务的所有令牌，
+// 下游上报完成时只吊销本次执行的令牌，重试签发的新令牌不受影响
+type Revocations struct {
+	redis *redis.Client
+	ttl   time.Duration
+}
+
+// NewRevocations ttl 应不小于令牌的最长有效期，之后令牌已自然过期
+func NewRevocations(redisClient *redis.Client, ttl time.Duration) *Revocations {
+	return &Revocations{redis: redisClient, ttl: ttl}
+}
+
+// Revoke 吊销任务的所有令牌
+func (r *Revocations) Revoke(ctx context.Context, taskID string) error {
+	if err := r.redis.Set(ctx, revokedKeyPrefix+taskID, 1, r.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke progress token: %w", err)
+	}
+	return nil
+}
+
+// RevokeToken 只吊销一个令牌，记录保留到令牌过期
+func (r *Revocations) RevokeToken(ctx context.Context, token string, expiresAt, now time.Time) error {
+	ttl := expiresAt.Sub(now)
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	if err := r.redis.Set(ctx, tokenKey(token), 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke progress token: %w", err)
+	}
+	return nil
+}
+
+// Revoked 令牌本身或其任务的所有令牌是否已被吊销
+func (r *Revocations) Revoked(ctx context.Context, taskID, token string) (bool, error) {
+	n, err := r.redis.Exists(ctx, revokedKeyPrefix+taskID, tokenKey(token)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check progress token revocation: %w", err)
+	}
+	return n > 0, nil
+}
+
+// tokenKey 按令牌的哈希记录，Redis 中不保存令牌本身
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return revokedTokenKeyPrefix + hex.EncodeToString(sum[:])
+}
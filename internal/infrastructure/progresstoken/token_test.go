@@ -0,0 +1,98 @@
+package progresstoken
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSignerVerify(t *testing.T) {
+	signer := NewSigner("secret", time.Hour)
+	now := time.Unix(1_700_000_000, 0)
+
+	token := signer.Sign("task-1", "default", 1, now, now.Add(10*time.Minute))
+	claims, err := signer.Verify(token, "task-1", now)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.Queue != "default" || claims.ExpiresAt != now.Add(10*time.Minute).Unix() {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	if _, err := signer.Verify(token, "task-2", now); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for another task, got %v", err)
+	}
+	if _, err := signer.Verify(token, "task-1", now.Add(10*time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+	if _, err := NewSigner("other", time.Hour).Verify(token, "task-1", now); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for another secret, got %v", err)
+	}
+	if _, err := signer.Verify("x"+token, "task-1", now); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for tampered token, got %v", err)
+	}
+}
+
+func TestSignerCapsLifetime(t *testing.T) {
+	signer := NewSigner("secret", time.Hour)
+	now := time.Unix(1_700_000_000, 0)
+
+	// 没有截止时间或截止时间过晚时按 maxTTL 过期
+	for _, deadline := range []time.Time{{}, now.Add(48 * time.Hour)} {
+		claims, err := signer.Verify(signer.Sign("task-1", "", 1, now, deadline), "task-1", now)
+		if err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+		if claims.ExpiresAt != now.Add(time.Hour).Unix() {
+			t.Fatalf("expected expiry capped at max_ttl, got %d", claims.ExpiresAt)
+		}
+	}
+}
+
+func TestRevocations(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+	r := NewRevocations(client, time.Hour)
+
+	if revoked, err := r.Revoked(ctx, "task-1", "token-a"); err != nil || revoked {
+		t.Fatalf("expected not revoked, got %v, %v", revoked, err)
+	}
+	if err := r.Revoke(ctx, "task-1"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if revoked, err := r.Revoked(ctx, "task-1", "token-b"); err != nil || !revoked {
+		t.Fatalf("expected revoked, got %v, %v", revoked, err)
+	}
+	if ttl := mr.TTL(revokedKeyPrefix + "task-1"); ttl != time.Hour {
+		t.Fatalf("expected revocation to expire after max_ttl, got %s", ttl)
+	}
+}
+
+func TestRevokeTokenKeepsOtherAttempts(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+	r := NewRevocations(client, time.Hour)
+	now := time.Unix(1_700_000_000, 0)
+
+	if err := r.RevokeToken(ctx, "token-a", now.Add(10*time.Minute), now); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if revoked, err := r.Revoked(ctx, "task-1", "token-a"); err != nil || !revoked {
+		t.Fatalf("expected token-a revoked, got %v, %v", revoked, err)
+	}
+	// 重试签发的新令牌仍然有效
+	if revoked, err := r.Revoked(ctx, "task-1", "token-b"); err != nil || revoked {
+		t.Fatalf("expected token-b still valid, got %v, %v", revoked, err)
+	}
+	if ttl := mr.TTL(tokenKey("token-a")); ttl != 10*time.Minute {
+		t.Fatalf("expected revocation to expire with the token, got %s", ttl)
+	}
+}
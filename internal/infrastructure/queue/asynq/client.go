@@ -2,40 +2,133 @@ package asynq
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 
 	"github.com/Aixtrade/TaskFlow/internal/config"
 	"github.com/Aixtrade/TaskFlow/internal/domain/task"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
 	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
 )
 
 type Client struct {
 	client    *asynq.Client
 	inspector *asynq.Inspector
+	redis     *redis.Client // 直接读写 asynq 分组数据，inspector 未提供的能力
+
+	// opTimeout inspector 操作和直接读写 Redis 的超时，0 表示不限制
+	opTimeout time.Duration
+
+	enqueues      EnqueueObserver // 为空时不记录入队耗时
+	logger        *zap.Logger
+	slowThreshold time.Duration // 入队超过该耗时时记录警告，0 表示不记录
+
+	clock clock.Clock
+}
+
+// 入队结果，用于入队耗时指标
+const (
+	EnqueueOK = "ok"
+	// EnqueueConflict 任务 ID 已存在或 unique 锁未过期，任务没有入队
+	EnqueueConflict = "conflict"
+	EnqueueFailed   = "failed"
+)
+
+// EnqueueObserver 记录每次入队调用的耗时和结果
+type EnqueueObserver interface {
+	ObserveEnqueueCall(taskType, queue, result string, d time.Duration)
+}
+
+// ClientOption 客户端可选项
+type ClientOption func(*Client)
+
+// WithOperationTimeout 限制 inspector 操作（取消、删除、查询等）和直接读写 Redis 的耗时。
+// inspector 不接受 context，超时通过 Redis 连接的读写超时实现
+func WithOperationTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.opTimeout = d
+	}
+}
+
+// WithEnqueueObserver 按队列和任务类型记录入队耗时、失败和冲突次数
+func WithEnqueueObserver(o EnqueueObserver) ClientOption {
+	return func(c *Client) {
+		c.enqueues = o
+	}
+}
+
+// WithLogger 记录读取失败而被跳过的队列等警告
+func WithLogger(logger *zap.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithSlowEnqueueWarning 入队耗时超过 threshold 时记录警告，包含任务类型、队列和 payload 大小，便于定位慢的调用方
+func WithSlowEnqueueWarning(logger *zap.Logger, threshold time.Duration) ClientOption {
+	return func(c *Client) {
+		c.logger = logger
+		c.slowThreshold = threshold
+	}
 }
 
-func NewClient(cfg *config.RedisConfig) (*Client, error) {
+// WithClock 替换计算任务等待时长使用的时钟，用于测试
+func WithClock(clk clock.Clock) ClientOption {
+	return func(c *Client) {
+		c.clock = clk
+	}
+}
+
+func NewClient(cfg *config.RedisConfig, opts ...ClientOption) (*Client, error) {
+	c := &Client{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.logger == nil {
+		c.logger = zap.NewNop()
+	}
+	c.clock = clock.OrReal(c.clock)
+
 	redisOpt := asynq.RedisClientOpt{
 		Addr:     cfg.Addr,
 		Password: cfg.Password,
 		DB:       cfg.DB,
 	}
+	c.client = asynq.NewClient(redisOpt)
+
+	inspectorOpt := redisOpt
+	inspectorOpt.ReadTimeout = c.opTimeout
+	inspectorOpt.WriteTimeout = c.opTimeout
+	c.inspector = asynq.NewInspector(inspectorOpt)
 
-	client := asynq.NewClient(redisOpt)
-	inspector := asynq.NewInspector(redisOpt)
+	c.redis = redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	return c, nil
+}
 
-	return &Client{
-		client:    client,
-		inspector: inspector,
-	}, nil
+// opContext 直接读写 Redis 时使用的 context
+func (c *Client) opContext() (context.Context, context.CancelFunc) {
+	if c.opTimeout > 0 {
+		return context.WithTimeout(context.Background(), c.opTimeout)
+	}
+	return context.WithCancel(context.Background())
 }
 
 func (c *Client) Close() error {
-	return c.client.Close()
+	return errors.Join(c.client.Close(), c.inspector.Close(), c.redis.Close())
 }
 
 type EnqueueOptions struct {
@@ -98,7 +191,7 @@ func (c *Client) Enqueue(ctx context.Context, t *task.Task, opts ...EnqueueOptio
 
 	asynqTask := asynq.NewTask(t.Type.String(), t.Payload)
 
-	return c.client.EnqueueContext(ctx, asynqTask, asynqOpts...)
+	return c.enqueue(ctx, asynqTask, opt.Queue, asynqOpts)
 }
 
 func (c *Client) EnqueueTask(ctx context.Context, taskType tasktype.Type, payload any, opts ...EnqueueOptions) (*asynq.TaskInfo, error) {
@@ -140,7 +233,36 @@ func (c *Client) EnqueueTask(ctx context.Context, taskType tasktype.Type, payloa
 
 	asynqTask := asynq.NewTask(taskType.String(), payloadBytes)
 
-	return c.client.EnqueueContext(ctx, asynqTask, asynqOpts...)
+	return c.enqueue(ctx, asynqTask, opt.Queue, asynqOpts)
+}
+
+// enqueue 调用 asynq 入队并记录耗时和结果
+func (c *Client) enqueue(ctx context.Context, t *asynq.Task, queue string, opts []asynq.Option) (*asynq.TaskInfo, error) {
+	start := time.Now()
+	info, err := c.client.EnqueueContext(ctx, t, opts...)
+	elapsed := time.Since(start)
+
+	result := EnqueueOK
+	switch {
+	case errors.Is(err, asynq.ErrTaskIDConflict), errors.Is(err, asynq.ErrDuplicateTask):
+		result = EnqueueConflict
+	case err != nil:
+		result = EnqueueFailed
+	}
+	if c.enqueues != nil {
+		c.enqueues.ObserveEnqueueCall(t.Type(), queue, result, elapsed)
+	}
+	if c.slowThreshold > 0 && elapsed >= c.slowThreshold {
+		c.logger.Warn("slow enqueue",
+			zap.String("type", t.Type()),
+			zap.String("queue", queue),
+			zap.Int("payload_bytes", len(t.Payload())),
+			zap.String("result", result),
+			zap.Duration("elapsed", elapsed),
+			zap.Duration("threshold", c.slowThreshold),
+		)
+	}
+	return info, err
 }
 
 func (c *Client) CancelTask(taskID string) error {
@@ -151,28 +273,77 @@ func (c *Client) DeleteTask(queue, taskID string) error {
 	return c.inspector.DeleteTask(queue, taskID)
 }
 
+// ArchiveTask 将未开始的任务移入 archived，保留任务记录但不再执行
+func (c *Client) ArchiveTask(queue, taskID string) error {
+	return c.inspector.ArchiveTask(queue, taskID)
+}
+
+// RunTask 将 scheduled、retry 或 archived 状态的任务立即移入 pending
+func (c *Client) RunTask(queue, taskID string) error {
+	return c.inspector.RunTask(queue, taskID)
+}
+
 func (c *Client) GetTaskInfo(queue, taskID string) (*asynq.TaskInfo, error) {
 	return c.inspector.GetTaskInfo(queue, taskID)
 }
 
+// TaskExists 在所有队列中查找任务，用于不知道任务所在队列的场景
+func (c *Client) TaskExists(ctx context.Context, taskID string) (bool, error) {
+	queues, err := c.inspector.Queues()
+	if err != nil {
+		return false, err
+	}
+	for _, queue := range queues {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		_, err := c.inspector.GetTaskInfo(queue, taskID)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, asynq.ErrTaskNotFound) && !errors.Is(err, asynq.ErrQueueNotFound) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
 func (c *Client) ListActiveTasks(queue string, page, size int) ([]*asynq.TaskInfo, error) {
-	return c.inspector.ListActiveTasks(queue, page, size)
+	return c.ListTasks(queue, "active", page, size)
 }
 
+// 各状态列表的排序依据，均为升序。顺序由 asynq 的存储结构决定：pending 和 active 是列表，
+// 从最早进入的一端读取；其余状态是按时间打分的有序集合，分数相同时按任务 ID 排序
+var listOrders = map[string]string{
+	"pending":   "enqueued_at",
+	"active":    "started_at",
+	"scheduled": "process_at",
+	"retry":     "next_retry_at",
+	"archived":  "archived_at",
+	"completed": "expires_at",
+}
+
+// ListOrder 返回 state 列表的排序依据，state 无效时返回空字符串
+func ListOrder(state string) string {
+	return listOrders[state]
+}
+
+// ListTasks 返回 state 下的第 page 页（从 0 开始），顺序见 ListOrder
 func (c *Client) ListTasks(queue, state string, page, size int) ([]*asynq.TaskInfo, error) {
+	opts := []asynq.ListOption{asynq.Page(page + 1), asynq.PageSize(size)}
 	switch state {
 	case "active":
-		return c.inspector.ListActiveTasks(queue, page, size)
+		return c.inspector.ListActiveTasks(queue, opts...)
 	case "pending":
-		return c.inspector.ListPendingTasks(queue, page, size)
+		return c.inspector.ListPendingTasks(queue, opts...)
 	case "scheduled":
-		return c.inspector.ListScheduledTasks(queue, page, size)
+		return c.inspector.ListScheduledTasks(queue, opts...)
 	case "retry":
-		return c.inspector.ListRetryTasks(queue, page, size)
+		return c.inspector.ListRetryTasks(queue, opts...)
 	case "archived":
-		return c.inspector.ListArchivedTasks(queue, page, size)
+		return c.inspector.ListArchivedTasks(queue, opts...)
 	case "completed":
-		return c.inspector.ListCompletedTasks(queue, page, size)
+		return c.inspector.ListCompletedTasks(queue, opts...)
 	default:
 		return nil, errors.New("invalid task state")
 	}
@@ -194,6 +365,9 @@ type QueueStats struct {
 	Retry     int    `json:"retry"`
 	Archived  int    `json:"archived"`
 	Completed int    `json:"completed"`
+	// Aggregating 等待聚合的任务数，Groups 分组数
+	Aggregating int `json:"aggregating"`
+	Groups      int `json:"groups"`
 }
 
 func (c *Client) GetAllQueueStats() ([]QueueStats, error) {
@@ -217,12 +391,189 @@ func (c *Client) GetAllQueueStats() ([]QueueStats, error) {
 			Retry:     info.Retry,
 			Archived:  info.Archived,
 			Completed: info.Completed,
+
+			Aggregating: info.Aggregating,
+			Groups:      info.Groups,
 		})
 	}
 
 	return stats, nil
 }
 
+// QueueInfos 返回所有队列的原始统计信息，读取失败的队列被跳过并记录警告
+func (c *Client) QueueInfos() ([]*asynq.QueueInfo, error) {
+	queues, err := c.inspector.Queues()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]*asynq.QueueInfo, 0, len(queues))
+	for _, q := range queues {
+		info, err := c.inspector.GetQueueInfo(q)
+		if err != nil {
+			c.logger.Warn("failed to read queue stats, queue skipped", zap.String("queue", q), zap.Error(err))
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// OldestTask 队列中最早会被处理的任务
+type OldestTask struct {
+	ID   string
+	Type string
+	// Age pending 任务为进入 pending 状态后已等待的时间；
+	// scheduled 任务为已超过计划执行时间的时长，尚未到期时为 0
+	Age           time.Duration
+	NextProcessAt time.Time
+}
+
+// OldestTasks 队列中最早的 pending 和 scheduled 任务，对应状态没有任务时为 nil
+type OldestTasks struct {
+	Queue     string
+	Pending   *OldestTask
+	Scheduled *OldestTask
+}
+
+// OldestTasks 返回队列中最早的 pending 任务（下一个出队的任务）和最早到期的 scheduled 任务。
+// pending 任务的等待时间取 inspector 报告的队列延迟，即最早的 pending 任务已等待的时间
+func (c *Client) OldestTasks(queue string) (*OldestTasks, error) {
+	info, err := c.inspector.GetQueueInfo(queue)
+	if err != nil {
+		return nil, err
+	}
+	result := &OldestTasks{Queue: queue}
+
+	pending, err := c.inspector.ListPendingTasks(queue, asynq.PageSize(1))
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) > 0 {
+		result.Pending = &OldestTask{
+			ID:            pending[0].ID,
+			Type:          pending[0].Type,
+			Age:           info.Latency,
+			NextProcessAt: pending[0].NextProcessAt,
+		}
+	}
+
+	scheduled, err := c.inspector.ListScheduledTasks(queue, asynq.PageSize(1))
+	if err != nil {
+		return nil, err
+	}
+	if len(scheduled) > 0 {
+		oldest := &OldestTask{ID: scheduled[0].ID, Type: scheduled[0].Type, NextProcessAt: scheduled[0].NextProcessAt}
+		if overdue := c.clock.Since(oldest.NextProcessAt); overdue > 0 {
+			oldest.Age = overdue
+		}
+		result.Scheduled = oldest
+	}
+
+	return result, nil
+}
+
+// StalePendingTask 返回队列中下一个出队的 pending 任务，它进入 pending 状态未超过 olderThan 时返回 nil。
+// 等待时间取 inspector 报告的队列延迟。pending 列表按进入时间排列，只有队首任务的等待时间可知，
+// 调用方移走它之后再次调用即可检查下一个任务
+func (c *Client) StalePendingTask(queue string, olderThan time.Duration) (*asynq.TaskInfo, error) {
+	pending, err := c.inspector.ListPendingTasks(queue, asynq.PageSize(1))
+	if err != nil {
+		if errors.Is(err, asynq.ErrQueueNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	// 先取任务再取延迟：队首任务在两次读取之间被取走时，延迟属于更晚进入的任务，不会提前移动
+	info, err := c.inspector.GetQueueInfo(queue)
+	if err != nil {
+		return nil, err
+	}
+	if info.Latency < olderThan {
+		return nil, nil
+	}
+	return pending[0], nil
+}
+
+// ErrTaskNotPending 任务已开始执行或已被移走，不能再移动
+var ErrTaskNotPending = errors.New("task is no longer pending")
+
+// MoveTask 将 pending 任务按原 ID 和选项移到另一个队列。先从原队列删除再写入目标队列，
+// 任务已开始执行或已被其他实例移走时返回 ErrTaskNotPending，不做任何修改；写入目标队列失败时放回原队列。
+// 唯一锁按剩余时长迁到目标队列，重试次数与最后错误无法通过客户端恢复，从 0 开始计数
+func (c *Client) MoveTask(ctx context.Context, info *asynq.TaskInfo, queue string) error {
+	unique, err := c.UniqueTTL(info)
+	if err != nil {
+		return fmt.Errorf("read unique lock: %w", err)
+	}
+	if err := c.inspector.DeleteTask(info.Queue, info.ID); err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) {
+			return errors.Join(ErrTaskNotPending, err)
+		}
+		// asynq 不区分删除失败的原因，已开始执行的任务单独报告
+		if current, infoErr := c.inspector.GetTaskInfo(info.Queue, info.ID); infoErr == nil && current.State != asynq.TaskStatePending {
+			return errors.Join(ErrTaskNotPending, err)
+		}
+		return fmt.Errorf("remove from %s: %w", info.Queue, err)
+	}
+
+	opts := []asynq.Option{
+		asynq.TaskID(info.ID),
+		asynq.MaxRetry(info.MaxRetry),
+	}
+	if info.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(info.Timeout))
+	}
+	if !info.Deadline.IsZero() {
+		opts = append(opts, asynq.Deadline(info.Deadline))
+	}
+	if info.Retention > 0 {
+		opts = append(opts, asynq.Retention(info.Retention))
+	}
+	if unique > 0 {
+		opts = append(opts, asynq.Unique(unique))
+	}
+
+	task := asynq.NewTask(info.Type, info.Payload)
+	_, err = c.client.EnqueueContext(ctx, task, append(opts, asynq.Queue(queue))...)
+	if err == nil {
+		return nil
+	}
+	if _, restoreErr := c.client.EnqueueContext(context.WithoutCancel(ctx), task, append(opts, asynq.Queue(info.Queue))...); restoreErr != nil {
+		return fmt.Errorf("enqueue to %s: %w; restore to %s also failed: %w", queue, err, info.Queue, restoreErr)
+	}
+	return fmt.Errorf("enqueue to %s: %w", queue, err)
+}
+
+// EnqueueCopy 以 taskID 将任务的副本写入原队列，payload、重试次数、超时和保留时间与原任务相同，
+// 重试计数从 0 开始。已过去的截止时间和唯一锁不复制：原任务可能仍持有唯一锁
+func (c *Client) EnqueueCopy(ctx context.Context, info *asynq.TaskInfo, taskID string) (*asynq.TaskInfo, error) {
+	opts := []asynq.Option{
+		asynq.Queue(info.Queue),
+		asynq.TaskID(taskID),
+		asynq.MaxRetry(info.MaxRetry),
+	}
+	if info.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(info.Timeout))
+	}
+	if info.Deadline.After(c.clock.Now()) {
+		opts = append(opts, asynq.Deadline(info.Deadline))
+	}
+	if info.Retention > 0 {
+		opts = append(opts, asynq.Retention(info.Retention))
+	}
+	return c.enqueue(ctx, asynq.NewTask(info.Type, info.Payload), info.Queue, opts)
+}
+
+// DeleteQueue 删除空队列，队列中还有 pending、active、scheduled、retry 或 archived 任务时返回 asynq.ErrQueueNotEmpty
+func (c *Client) DeleteQueue(queue string) error {
+	return c.inspector.DeleteQueue(queue, false)
+}
+
 func (c *Client) PauseQueue(queue string) error {
 	return c.inspector.PauseQueue(queue)
 }
@@ -230,3 +581,90 @@ func (c *Client) PauseQueue(queue string) error {
 func (c *Client) UnpauseQueue(queue string) error {
 	return c.inspector.UnpauseQueue(queue)
 }
+
+// uniqueKey asynq 唯一锁的键，值为持有锁的任务 ID
+func uniqueKey(queue, taskType string, payload []byte) string {
+	key := "asynq:{" + queue + "}:unique:" + taskType + ":"
+	if payload == nil {
+		return key
+	}
+	checksum := md5.Sum(payload)
+	return key + hex.EncodeToString(checksum[:])
+}
+
+// UniqueTTL 返回任务唯一锁的剩余有效期，任务未持有锁（未设置 unique、已过期或已成功完成）时返回 0
+func (c *Client) UniqueTTL(info *asynq.TaskInfo) (time.Duration, error) {
+	ctx, cancel := c.opContext()
+	defer cancel()
+	key := uniqueKey(info.Queue, info.Type, info.Payload)
+
+	pipe := c.redis.Pipeline()
+	owner := pipe.Get(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+	// 相同 payload 的新任务可能已持有锁
+	if owner.Val() != info.ID || ttl.Val() <= 0 {
+		return 0, nil
+	}
+	return ttl.Val(), nil
+}
+
+// GroupStats 等待聚合的任务分组
+type GroupStats struct {
+	Group string `json:"group"`
+	Size  int    `json:"size"`
+}
+
+// ListGroups 列出队列中等待聚合的分组，按大小降序
+func (c *Client) ListGroups(queue string) ([]GroupStats, error) {
+	groups, err := c.inspector.Groups(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]GroupStats, 0, len(groups))
+	for _, g := range groups {
+		stats = append(stats, GroupStats{Group: g.Group, Size: g.Size})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Size != stats[j].Size {
+			return stats[i].Size > stats[j].Size
+		}
+		return stats[i].Group < stats[j].Group
+	})
+	return stats, nil
+}
+
+// groupPageSize 列出分组成员时每页读取的任务数
+const groupPageSize = 100
+
+// FlushGroup 将分组中的任务移入 pending，不再等待聚合，由 worker 逐个处理。
+// 返回分组中的任务 ID；dryRun 为 true 时只返回而不修改分组
+func (c *Client) FlushGroup(queue, group string, dryRun bool) ([]string, error) {
+	var members []string
+	for page := 1; ; page++ {
+		tasks, err := c.inspector.ListAggregatingTasks(queue, group, asynq.Page(page), asynq.PageSize(groupPageSize))
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tasks {
+			members = append(members, t.ID)
+		}
+		if len(tasks) < groupPageSize {
+			break
+		}
+	}
+	if len(members) == 0 {
+		return nil, apperrors.ErrGroupNotFound
+	}
+	if dryRun {
+		return members, nil
+	}
+
+	if _, err := c.inspector.RunAllAggregatingTasks(queue, group); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
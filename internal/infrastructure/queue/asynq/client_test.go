@@ -0,0 +1,366 @@
+package asynq
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/Aixtrade/TaskFlow/internal/config"
+	"github.com/Aixtrade/TaskFlow/internal/domain/task"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
+)
+
+func TestUniqueTTL(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := NewClient(&config.RedisConfig{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+
+	unique, err := client.client.Enqueue(asynq.NewTask("demo", []byte(`{}`)), asynq.Unique(time.Hour))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	plain, err := client.client.Enqueue(asynq.NewTask("demo", []byte(`{"n":1}`)))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	ttl, err := client.UniqueTTL(unique)
+	if err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Fatalf("expected about an hour of unique ttl, got %v, %v", ttl, err)
+	}
+	if ttl, err := client.UniqueTTL(plain); err != nil || ttl != 0 {
+		t.Fatalf("expected no unique ttl, got %v, %v", ttl, err)
+	}
+
+	mr.FastForward(2 * time.Hour)
+	if ttl, err := client.UniqueTTL(unique); err != nil || ttl != 0 {
+		t.Fatalf("expected expired unique lock, got %v, %v", ttl, err)
+	}
+}
+
+func TestListAndFlushGroups(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := NewClient(&config.RedisConfig{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+
+	for i, group := range []string{"small", "large", "large"} {
+		task := asynq.NewTask("demo", []byte{byte(i)})
+		if _, err := client.client.Enqueue(task, asynq.Group(group)); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	groups, err := client.ListGroups("default")
+	if err != nil {
+		t.Fatalf("list groups: %v", err)
+	}
+	if len(groups) != 2 || groups[0].Group != "large" || groups[0].Size != 2 {
+		t.Fatalf("expected groups sorted by size, got %+v", groups)
+	}
+
+	preview, err := client.FlushGroup("default", "large", true)
+	if err != nil || len(preview) != 2 {
+		t.Fatalf("expected 2 tasks in dry run, got %v, %v", preview, err)
+	}
+	groups, _ = client.ListGroups("default")
+	if len(groups) != 2 || groups[0].Size != 2 {
+		t.Fatalf("expected dry run to leave the group untouched, got %+v", groups)
+	}
+
+	flushed, err := client.FlushGroup("default", "large", false)
+	if err != nil || len(flushed) != 2 {
+		t.Fatalf("expected 2 tasks flushed, got %v, %v", flushed, err)
+	}
+	groups, _ = client.ListGroups("default")
+	if len(groups) != 1 || groups[0].Group != "small" {
+		t.Fatalf("expected flushed group to be gone, got %+v", groups)
+	}
+	pending, err := client.inspector.ListPendingTasks("default")
+	if err != nil {
+		t.Fatalf("list pending: %v", err)
+	}
+	var ids []string
+	for _, info := range pending {
+		ids = append(ids, info.ID)
+	}
+	slices.Sort(ids)
+	slices.Sort(flushed)
+	if !slices.Equal(ids, flushed) {
+		t.Fatalf("expected flushed tasks %v to be pending, got %v", flushed, ids)
+	}
+
+	if _, err := client.FlushGroup("default", "missing", false); !errors.Is(err, apperrors.ErrGroupNotFound) {
+		t.Fatalf("expected ErrGroupNotFound, got %v", err)
+	}
+}
+
+func TestListTasksPaginates(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := NewClient(&config.RedisConfig{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+
+	var pending []string
+	for i := range 5 {
+		info, err := client.client.Enqueue(asynq.NewTask("demo", []byte(strconv.Itoa(i))))
+		if err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		pending = append(pending, info.ID)
+	}
+	later, err := client.client.Enqueue(asynq.NewTask("demo", nil), asynq.ProcessIn(2*time.Hour))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	sooner, err := client.client.Enqueue(asynq.NewTask("demo", nil), asynq.ProcessIn(time.Hour))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	ids := func(infos []*asynq.TaskInfo) []string {
+		var out []string
+		for _, info := range infos {
+			out = append(out, info.ID)
+		}
+		return out
+	}
+
+	// page 从 0 开始，pending 按入队先后排列
+	for page, want := range [][]string{pending[0:2], pending[2:4], pending[4:]} {
+		infos, err := client.ListTasks("default", "pending", page, 2)
+		if err != nil {
+			t.Fatalf("list page %d: %v", page, err)
+		}
+		if got := ids(infos); !slices.Equal(got, want) {
+			t.Fatalf("page %d: expected %v, got %v", page, want, got)
+		}
+	}
+
+	infos, err := client.ListTasks("default", "scheduled", 0, 10)
+	if err != nil {
+		t.Fatalf("list scheduled: %v", err)
+	}
+	if got := ids(infos); !slices.Equal(got, []string{sooner.ID, later.ID}) {
+		t.Fatalf("expected scheduled tasks by process_at, got %v", got)
+	}
+}
+
+func TestOldestTasks(t *testing.T) {
+	mr := miniredis.RunT(t)
+	clk := clock.NewFake(time.Now())
+	client, err := NewClient(&config.RedisConfig{Addr: mr.Addr()}, WithClock(clk))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.OldestTasks("high"); err == nil {
+		t.Fatal("expected an error for a queue that does not exist")
+	}
+
+	first, err := client.client.Enqueue(asynq.NewTask("first", nil), asynq.Queue("high"))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if _, err := client.client.Enqueue(asynq.NewTask("second", nil), asynq.Queue("high")); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if _, err := client.client.Enqueue(asynq.NewTask("later", nil), asynq.Queue("high"), asynq.ProcessIn(2*time.Hour)); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	due, err := client.client.Enqueue(asynq.NewTask("sooner", nil), asynq.Queue("high"), asynq.ProcessIn(time.Hour))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	oldest, err := client.OldestTasks("high")
+	if err != nil {
+		t.Fatalf("oldest tasks: %v", err)
+	}
+	if oldest.Pending == nil || oldest.Pending.ID != first.ID || oldest.Pending.Type != "first" {
+		t.Fatalf("expected the first enqueued task to be the oldest pending, got %+v", oldest.Pending)
+	}
+	if oldest.Pending.Age < 0 || oldest.Pending.Age > time.Minute {
+		t.Fatalf("expected a fresh pending task, got age %v", oldest.Pending.Age)
+	}
+	if oldest.Scheduled == nil || oldest.Scheduled.ID != due.ID || oldest.Scheduled.Age != 0 {
+		t.Fatalf("expected the earliest scheduled task not yet due, got %+v", oldest.Scheduled)
+	}
+
+	// 计划执行时间已过去半小时
+	clk.Advance(90 * time.Minute)
+	oldest, err = client.OldestTasks("high")
+	if err != nil {
+		t.Fatalf("oldest tasks: %v", err)
+	}
+	if age := oldest.Scheduled.Age; age < 29*time.Minute || age > 31*time.Minute {
+		t.Fatalf("expected the scheduled task to be about 30m overdue, got %v", age)
+	}
+
+	if _, err := client.inspector.DeleteAllPendingTasks("high"); err != nil {
+		t.Fatalf("delete pending: %v", err)
+	}
+	oldest, err = client.OldestTasks("high")
+	if err != nil || oldest.Pending != nil {
+		t.Fatalf("expected no pending task in an existing queue, got %+v, %v", oldest.Pending, err)
+	}
+}
+
+func TestStalePendingTaskAndMoveTask(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := NewClient(&config.RedisConfig{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+
+	old, err := client.client.Enqueue(asynq.NewTask("demo", []byte(`{"n":1}`)), asynq.Queue("low"), asynq.MaxRetry(7), asynq.Unique(time.Hour))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if _, err := client.client.Enqueue(asynq.NewTask("demo", []byte(`{"n":2}`)), asynq.Queue("low")); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if stale, err := client.StalePendingTask("low", 10*time.Minute); err != nil || stale != nil {
+		t.Fatalf("expected fresh tasks not to be stale, got %+v, %v", stale, err)
+	}
+	stale, err := client.StalePendingTask("low", 0)
+	if err != nil {
+		t.Fatalf("stale pending task: %v", err)
+	}
+	if stale == nil || stale.ID != old.ID {
+		t.Fatalf("expected the first task, got %+v", stale)
+	}
+	if stale, err := client.StalePendingTask("missing", time.Minute); err != nil || stale != nil {
+		t.Fatalf("expected an unknown queue to have no stale task, got %v, %v", stale, err)
+	}
+
+	if err := client.MoveTask(context.Background(), stale, "default"); err != nil {
+		t.Fatalf("move task: %v", err)
+	}
+	moved, err := client.inspector.GetTaskInfo("default", old.ID)
+	if err != nil {
+		t.Fatalf("get moved task: %v", err)
+	}
+	if moved.State != asynq.TaskStatePending || moved.MaxRetry != 7 || string(moved.Payload) != `{"n":1}` {
+		t.Fatalf("expected the task to keep its options, got %+v", moved)
+	}
+	if ttl, err := client.UniqueTTL(moved); err != nil || ttl <= 59*time.Minute {
+		t.Fatalf("expected the unique lock to move with the task, got %v, %v", ttl, err)
+	}
+	if _, err := client.inspector.GetTaskInfo("low", old.ID); !errors.Is(err, asynq.ErrTaskNotFound) {
+		t.Fatalf("expected the task to leave the low queue, got %v", err)
+	}
+
+	// 已被移走的任务不能再移动
+	if err := client.MoveTask(context.Background(), stale, "high"); !errors.Is(err, ErrTaskNotPending) {
+		t.Fatalf("expected ErrTaskNotPending, got %v", err)
+	}
+}
+
+func TestEnqueueCopy(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := NewClient(&config.RedisConfig{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+
+	orig, err := client.client.Enqueue(asynq.NewTask("demo", []byte(`{"n":1}`)), asynq.Queue("low"), asynq.MaxRetry(7),
+		asynq.Timeout(time.Minute), asynq.Retention(time.Hour), asynq.Unique(time.Hour))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	copied, err := client.EnqueueCopy(context.Background(), orig, "copy-1")
+	if err != nil {
+		t.Fatalf("enqueue copy: %v", err)
+	}
+	if copied.ID != "copy-1" || copied.Queue != "low" || copied.MaxRetry != 7 || copied.Timeout != time.Minute ||
+		copied.Retention != time.Hour || string(copied.Payload) != `{"n":1}` {
+		t.Fatalf("expected the copy to keep the options, got %+v", copied)
+	}
+	// 原任务仍持有唯一锁，副本不设置唯一锁
+	if ttl, err := client.UniqueTTL(copied); err != nil || ttl != 0 {
+		t.Fatalf("expected the copy to have no unique lock, got %v, %v", ttl, err)
+	}
+	if _, err := client.EnqueueCopy(context.Background(), orig, "copy-1"); !errors.Is(err, asynq.ErrTaskIDConflict) {
+		t.Fatalf("expected ErrTaskIDConflict, got %v", err)
+	}
+}
+
+type enqueueCall struct {
+	taskType, queue, result string
+}
+
+type fakeEnqueueObserver struct {
+	calls []enqueueCall
+}
+
+func (f *fakeEnqueueObserver) ObserveEnqueueCall(taskType, queue, result string, d time.Duration) {
+	f.calls = append(f.calls, enqueueCall{taskType, queue, result})
+}
+
+func TestEnqueueRecordsResultAndSlowCalls(t *testing.T) {
+	mr := miniredis.RunT(t)
+	obs := &fakeEnqueueObserver{}
+	core, logs := observer.New(zapcore.WarnLevel)
+	// 阈值极小，每次入队都视为慢调用
+	client, err := NewClient(&config.RedisConfig{Addr: mr.Addr()},
+		WithEnqueueObserver(obs),
+		WithSlowEnqueueWarning(zap.New(core), time.Nanosecond),
+	)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+
+	tk := &task.Task{ID: "t-1", Type: tasktype.Demo, Payload: []byte(`{"n":1}`), Queue: "high"}
+	if _, err := client.Enqueue(context.Background(), tk); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if _, err := client.Enqueue(context.Background(), tk); !errors.Is(err, asynq.ErrTaskIDConflict) {
+		t.Fatalf("expected task ID conflict, got %v", err)
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Enqueue(canceled, &task.Task{ID: "t-2", Type: tasktype.Demo, Payload: []byte(`{}`), Queue: "high"}); err == nil {
+		t.Fatal("expected enqueue to fail with a canceled context")
+	}
+
+	want := []enqueueCall{
+		{tasktype.Demo.String(), "high", EnqueueOK},
+		{tasktype.Demo.String(), "high", EnqueueConflict},
+		{tasktype.Demo.String(), "high", EnqueueFailed},
+	}
+	if !slices.Equal(obs.calls, want) {
+		t.Fatalf("calls = %+v, want %+v", obs.calls, want)
+	}
+
+	entries := logs.FilterMessage("slow enqueue").All()
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d slow enqueue warnings, got %d", len(want), len(entries))
+	}
+	if got := entries[0].ContextMap()["payload_bytes"]; got != int64(len(tk.Payload)) {
+		t.Fatalf("payload_bytes = %v, want %d", got, len(tk.Payload))
+	}
+}
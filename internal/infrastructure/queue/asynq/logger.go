@@ -0,0 +1,150 @@
+package asynq
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+// asynqLogger 将 asynq 内部日志转为结构化日志：带 component=asynq 字段，
+// warn 及以上的 "操作: 错误" 形式消息拆为 msg 与 error 字段。
+// 低于 level 的日志丢弃，相同的 warn/error 日志在 window 内只输出一次，之后汇总被抑制的条数
+type asynqLogger struct {
+	logger *zap.Logger
+	level  zap.AtomicLevel
+	window time.Duration
+	clock  clock.Clock
+
+	mu         sync.Mutex
+	suppressed map[suppressKey]*suppression
+}
+
+// suppressKey 按级别和拆分后的 msg 判断是否相同，错误详情（如连接的地址）不同也视为相同
+type suppressKey struct {
+	level zapcore.Level
+	msg   string
+}
+
+type suppression struct {
+	until time.Time
+	count int
+}
+
+func newAsynqLogger(l *zap.Logger, level zap.AtomicLevel, window time.Duration, clk clock.Clock) *asynqLogger {
+	return &asynqLogger{
+		logger:     l.Named("asynq").With(zap.String("component", "asynq")),
+		level:      level,
+		window:     window,
+		clock:      clock.OrReal(clk),
+		suppressed: make(map[suppressKey]*suppression),
+	}
+}
+
+func (l *asynqLogger) Debug(args ...interface{}) {
+	l.log(zapcore.DebugLevel, args)
+}
+
+func (l *asynqLogger) Info(args ...interface{}) {
+	l.log(zapcore.InfoLevel, args)
+}
+
+func (l *asynqLogger) Warn(args ...interface{}) {
+	l.log(zapcore.WarnLevel, args)
+}
+
+func (l *asynqLogger) Error(args ...interface{}) {
+	l.log(zapcore.ErrorLevel, args)
+}
+
+// Fatal 不受级别和抑制影响
+func (l *asynqLogger) Fatal(args ...interface{}) {
+	msg, fields := splitMessage(fmt.Sprint(args...))
+	l.logger.Fatal(msg, fields...)
+}
+
+func (l *asynqLogger) log(level zapcore.Level, args []interface{}) {
+	if !l.level.Enabled(level) {
+		return
+	}
+
+	text := fmt.Sprint(args...)
+	msg, fields := text, []zap.Field(nil)
+	if level >= zapcore.WarnLevel {
+		msg, fields = splitMessage(text)
+		if !l.admit(suppressKey{level: level, msg: msg}) {
+			return
+		}
+	}
+	if ce := l.logger.Check(level, msg); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// admit 判断该条日志是否输出。窗口结束后的第一条会先输出上一个窗口的汇总
+func (l *asynqLogger) admit(key suppressKey) bool {
+	if l.window <= 0 {
+		return true
+	}
+
+	now := l.clock.Now()
+	l.mu.Lock()
+	s, ok := l.suppressed[key]
+	if ok && now.Before(s.until) {
+		s.count++
+		l.mu.Unlock()
+		return false
+	}
+	var count int
+	if ok {
+		count = s.count
+	}
+	l.suppressed[key] = &suppression{until: now.Add(l.window)}
+	l.mu.Unlock()
+
+	if count > 0 {
+		l.writeSummary(key, count)
+	}
+	return true
+}
+
+// Flush 输出尚未汇总的抑制条数，服务停止时调用
+func (l *asynqLogger) Flush() {
+	l.mu.Lock()
+	pending := make(map[suppressKey]int)
+	for key, s := range l.suppressed {
+		if s.count > 0 {
+			pending[key] = s.count
+		}
+		delete(l.suppressed, key)
+	}
+	l.mu.Unlock()
+
+	for key, count := range pending {
+		l.writeSummary(key, count)
+	}
+}
+
+func (l *asynqLogger) writeSummary(key suppressKey, count int) {
+	if ce := l.logger.Check(key.level, fmt.Sprintf("suppressed %d similar messages", count)); ce != nil {
+		ce.Write(
+			zap.String("suppressed_message", key.msg),
+			zap.Int("suppressed", count),
+			zap.Duration("window", l.window),
+		)
+	}
+}
+
+// splitMessage 将 asynq 的 "Failed to do something: <error>" 拆为消息和 error 字段
+func splitMessage(text string) (string, []zap.Field) {
+	msg, detail, ok := strings.Cut(text, ": ")
+	if !ok || msg == "" || detail == "" {
+		return text, nil
+	}
+	return msg, []zap.Field{zap.String("error", detail)}
+}
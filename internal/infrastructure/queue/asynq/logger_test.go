@@ -0,0 +1,93 @@
+package asynq
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+func newObservedAsynqLogger(level zapcore.Level, window time.Duration) (*asynqLogger, *observer.ObservedLogs, *clock.Fake) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	fake := clock.NewFake(time.UnixMilli(1_700_000_000_000))
+	return newAsynqLogger(zap.New(core), zap.NewAtomicLevelAt(level), window, fake), logs, fake
+}
+
+func TestAsynqLoggerLevelAndFields(t *testing.T) {
+	l, logs, _ := newObservedAsynqLogger(zapcore.WarnLevel, time.Minute)
+
+	l.Debug("Sending heartbeat")
+	l.Info("Starting processing")
+	l.Warn("Failed to write server state data: UNKNOWN: redis command error: connection refused")
+	l.Error("Dequeue error", " for queue ", "low")
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("expected only warn and error entries, got %+v", entries)
+	}
+	warn := entries[0]
+	if warn.Level != zapcore.WarnLevel || warn.LoggerName != "asynq" || warn.Message != "Failed to write server state data" {
+		t.Fatalf("unexpected warn entry: %+v", warn)
+	}
+	fields := warn.ContextMap()
+	if fields["component"] != "asynq" || fields["error"] != "UNKNOWN: redis command error: connection refused" {
+		t.Fatalf("unexpected warn fields: %v", fields)
+	}
+	if entries[1].Message != "Dequeue error for queue low" || entries[1].ContextMap()["error"] != nil {
+		t.Fatalf("expected a message without an error part to stay whole, got %+v", entries[1])
+	}
+
+	// 级别可在运行中调整
+	l.level.SetLevel(zapcore.DebugLevel)
+	l.Debug("Sending heartbeat")
+	if logs.Len() != 3 {
+		t.Fatalf("expected debug entry after lowering the level, got %d entries", logs.Len())
+	}
+}
+
+func TestAsynqLoggerSuppressesRepeatedWarnings(t *testing.T) {
+	l, logs, fake := newObservedAsynqLogger(zapcore.InfoLevel, time.Minute)
+
+	for _, addr := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.1"} {
+		l.Warn("Failed to dequeue: dial tcp " + addr + ":6379: connection refused")
+	}
+	l.Error("Failed to dequeue: dial tcp 10.0.0.1:6379: connection refused")
+	// info 日志不抑制
+	l.Info("Starting processing")
+	l.Info("Starting processing")
+
+	if logs.FilterMessage("Failed to dequeue").Len() != 2 {
+		t.Fatalf("expected one warn and one error before suppression, got %+v", logs.AllUntimed())
+	}
+	if logs.FilterMessage("Starting processing").Len() != 2 {
+		t.Fatalf("expected info entries not to be suppressed")
+	}
+
+	fake.Advance(time.Minute)
+	l.Warn("Failed to dequeue: dial tcp 10.0.0.1:6379: connection refused")
+
+	summaries := logs.FilterMessage("suppressed 2 similar messages").AllUntimed()
+	if len(summaries) != 1 || summaries[0].Level != zapcore.WarnLevel {
+		t.Fatalf("expected a warn summary of 2 suppressed messages, got %+v", logs.AllUntimed())
+	}
+	if got := summaries[0].ContextMap()["suppressed_message"]; got != "Failed to dequeue" {
+		t.Fatalf("expected the summary to name the message, got %v", got)
+	}
+	if logs.FilterMessage("Failed to dequeue").Len() != 3 {
+		t.Fatalf("expected the first warning after the window to be logged")
+	}
+
+	l.Warn("Failed to dequeue: dial tcp 10.0.0.1:6379: connection refused")
+	l.Flush()
+	if logs.FilterMessage("suppressed 1 similar messages").Len() != 1 {
+		t.Fatalf("expected flush to report pending suppressions, got %+v", logs.AllUntimed())
+	}
+	l.Flush()
+	if logs.FilterMessage("suppressed 1 similar messages").Len() != 1 {
+		t.Fatal("expected a second flush to report nothing")
+	}
+}
@@ -0,0 +1,28 @@
+package asynq
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// DefaultRetryJitter 重试延迟默认的随机浮动比例
+const DefaultRetryJitter = 0.2
+
+// RetryDelayFunc 返回带随机抖动的重试延迟函数。
+// 基础延迟与 asynq 默认曲线一致（n^4 + 15 秒），每个任务在 [1-jitter, 1+jitter] 倍之间独立浮动，
+// 同时失败的大量任务不会在同一时刻一起重试；jitter 不大于 0 时延迟固定。rnd 返回 [0, 1) 的随机数，nil 时使用 math/rand
+func RetryDelayFunc(jitter float64, rnd func() float64) asynq.RetryDelayFunc {
+	if rnd == nil {
+		rnd = rand.Float64
+	}
+	jitter = min(max(jitter, 0), 1)
+
+	return func(n int, _ error, _ *asynq.Task) time.Duration {
+		base := time.Duration(math.Pow(float64(n), 4))*time.Second + 15*time.Second
+		factor := 1 + jitter*(2*rnd()-1)
+		return time.Duration(float64(base) * factor)
+	}
+}
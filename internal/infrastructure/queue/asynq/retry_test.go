@@ -0,0 +1,51 @@
+package asynq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestRetryDelayFuncSpreadsSimultaneousFailures(t *testing.T) {
+	delay := RetryDelayFunc(DefaultRetryJitter, nil)
+	err := errors.New("backend unavailable")
+
+	first := delay(1, err, asynq.NewTask("demo", []byte(`{"n":1}`)))
+	second := delay(1, err, asynq.NewTask("demo", []byte(`{"n":2}`)))
+	if first == second {
+		t.Fatalf("expected tasks failing together to get different delays, both got %v", first)
+	}
+
+	base := 16 * time.Second
+	for _, d := range []time.Duration{first, second} {
+		if d < time.Duration(float64(base)*0.8) || d > time.Duration(float64(base)*1.2) {
+			t.Fatalf("expected delay within 20%% of %v, got %v", base, d)
+		}
+	}
+}
+
+func TestRetryDelayFuncJitterBounds(t *testing.T) {
+	task := asynq.NewTask("demo", nil)
+	tests := []struct {
+		name   string
+		jitter float64
+		rnd    float64
+		want   time.Duration
+	}{
+		{name: "no jitter", jitter: 0, rnd: 0.9, want: 31 * time.Second},
+		{name: "disabled", jitter: -1, rnd: 0.9, want: 31 * time.Second},
+		{name: "lowest", jitter: 0.5, rnd: 0, want: 15500 * time.Millisecond},
+		{name: "highest", jitter: 0.5, rnd: 1, want: 46500 * time.Millisecond},
+		{name: "clamped", jitter: 3, rnd: 1, want: 62 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay := RetryDelayFunc(tt.jitter, func() float64 { return tt.rnd })
+			if got := delay(2, nil, task); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
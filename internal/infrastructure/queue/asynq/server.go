@@ -2,17 +2,31 @@ package asynq
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/hibiken/asynq"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/Aixtrade/TaskFlow/internal/config"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
 )
 
 type Server struct {
+	mu     sync.Mutex
 	server *asynq.Server
 	mux    *asynq.ServeMux
 	logger *zap.Logger
+	// asynqLog asynq 内部日志的适配器，停止时输出被抑制日志的汇总
+	asynqLog *asynqLogger
+
+	// newServer 按相同配置和给定的队列权重创建 asynq 服务，Stop 之后的服务无法再次启动，恢复时需重建
+	newServer func(queues map[string]int) *asynq.Server
+	queues    map[string]int
+	draining  bool
 }
 
 type ServerConfig struct {
@@ -20,6 +34,12 @@ type ServerConfig struct {
 	Queues      map[string]int
 	Concurrency int
 	Logger      *zap.Logger
+	// LogLevel asynq 内部日志的最低级别，可在运行中调整；未设置时为 info
+	LogLevel zap.AtomicLevel
+	// LogSuppressWindow 相同的 asynq warn/error 日志在该时间内只输出一次，0 表示不抑制
+	LogSuppressWindow time.Duration
+	// RetryJitter 重试延迟的随机浮动比例（0~1），不大于 0 时不浮动，见 RetryDelayFunc
+	RetryJitter float64
 }
 
 func NewServer(cfg ServerConfig) (*Server, error) {
@@ -29,25 +49,39 @@ func NewServer(cfg ServerConfig) (*Server, error) {
 		DB:       cfg.Redis.DB,
 	}
 
-	server := asynq.NewServer(
-		redisOpt,
-		asynq.Config{
-			Concurrency: cfg.Concurrency,
-			Queues:      cfg.Queues,
-			ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
-				cfg.Logger.Error("task error",
-					zap.String("type", task.Type()),
-					zap.Error(err),
-				)
-			}),
-			Logger: newZapLogger(cfg.Logger),
-		},
-	)
+	level := cfg.LogLevel
+	if level == (zap.AtomicLevel{}) {
+		level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	}
+	asynqLog := newAsynqLogger(cfg.Logger, level, cfg.LogSuppressWindow, clock.Real())
+
+	asynqConfig := asynq.Config{
+		Concurrency: cfg.Concurrency,
+		Queues:      cfg.Queues,
+		ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
+			cfg.Logger.Error("task error",
+				zap.String("type", task.Type()),
+				zap.Error(err),
+			)
+		}),
+		RetryDelayFunc: RetryDelayFunc(cfg.RetryJitter, nil),
+		Logger:         asynqLog,
+		// 级别由 asynqLog 过滤，以便在运行中调整
+		LogLevel: asynq.DebugLevel,
+	}
+	newServer := func(queues map[string]int) *asynq.Server {
+		c := asynqConfig
+		c.Queues = queues
+		return asynq.NewServer(redisOpt, c)
+	}
 
 	return &Server{
-		server: server,
-		mux:    asynq.NewServeMux(),
-		logger: cfg.Logger,
+		server:    newServer(cfg.Queues),
+		mux:       asynq.NewServeMux(),
+		logger:    cfg.Logger,
+		asynqLog:  asynqLog,
+		newServer: newServer,
+		queues:    cfg.Queues,
 	}, nil
 }
 
@@ -64,44 +98,99 @@ func (s *Server) Use(middlewares ...asynq.MiddlewareFunc) {
 }
 
 func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.logger.Info("starting asynq server")
 	return s.server.Start(s.mux)
 }
 
 func (s *Server) Shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.logger.Info("shutting down asynq server")
 	s.server.Shutdown()
+	s.asynqLog.Flush()
 }
 
 func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.logger.Info("stopping asynq server")
 	s.server.Stop()
+	s.asynqLog.Flush()
 }
 
-type zapLogger struct {
-	logger *zap.Logger
-}
+// Drain 停止拉取新任务，正在执行的任务继续完成。返回是否由本次调用进入排空状态
+func (s *Server) Drain() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-func newZapLogger(l *zap.Logger) *zapLogger {
-	return &zapLogger{logger: l.Named("asynq")}
+	if s.draining {
+		return false
+	}
+	s.logger.Info("draining asynq server")
+	s.server.Stop()
+	s.draining = true
+	return true
 }
 
-func (l *zapLogger) Debug(args ...interface{}) {
-	l.logger.Sugar().Debug(args...)
-}
+// Undrain 恢复拉取任务：关闭已停止的服务并按相同配置重新启动。
+// 调用方应确认排空期间的任务均已完成，否则关闭时会等待它们直到超时
+func (s *Server) Undrain() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-func (l *zapLogger) Info(args ...interface{}) {
-	l.logger.Sugar().Info(args...)
+	if !s.draining {
+		return nil
+	}
+	s.logger.Info("resuming asynq server")
+	s.server.Shutdown()
+	s.server = s.newServer(s.queues)
+	if err := s.server.Start(s.mux); err != nil {
+		return err
+	}
+	s.draining = false
+	return nil
 }
 
-func (l *zapLogger) Warn(args ...interface{}) {
-	l.logger.Sugar().Warn(args...)
+// Draining 是否处于排空状态
+func (s *Server) Draining() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.draining
 }
 
-func (l *zapLogger) Error(args ...interface{}) {
-	l.logger.Sugar().Error(args...)
-}
+// Reconfigure 按新的队列权重重建 asynq 服务：停止拉取，等待正在执行的任务完成（最多 asynq 的关闭超时，
+// 超时未完成的任务会回到队列重新执行），再以新权重启动。新服务启动失败时以原来的权重重新启动，
+// 不会让 worker 停止消费。排空状态下只记录权重，恢复消费时生效
+func (s *Server) Reconfigure(queues map[string]int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.draining {
+		s.queues = queues
+		return nil
+	}
+	s.logger.Info("restarting asynq server with new queue weights", zap.Any("queues", queues))
+	s.server.Stop()
+	s.server.Shutdown()
+	s.server = s.newServer(queues)
+	err := s.server.Start(s.mux)
+	if err == nil {
+		s.queues = queues
+		return nil
+	}
 
-func (l *zapLogger) Fatal(args ...interface{}) {
-	l.logger.Sugar().Fatal(args...)
+	s.logger.Error("failed to start asynq server with new queue weights, restoring previous weights",
+		zap.Any("queues", s.queues),
+		zap.Error(err),
+	)
+	s.server = s.newServer(s.queues)
+	if restoreErr := s.server.Start(s.mux); restoreErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to restore previous queue weights: %w", restoreErr))
+	}
+	return err
 }
@@ -0,0 +1,136 @@
+package asynq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/config"
+)
+
+func TestServerDrainAndUndrain(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisCfg := &config.RedisConfig{Addr: mr.Addr()}
+	server, err := NewServer(ServerConfig{
+		Redis:       redisCfg,
+		Queues:      map[string]int{"default": 1},
+		Concurrency: 1,
+		Logger:      zap.NewNop(),
+	})
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	processed := make(chan string, 1)
+	server.HandleFunc("demo", func(ctx context.Context, task *asynq.Task) error {
+		processed <- string(task.Payload())
+		return nil
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer server.Shutdown()
+
+	if !server.Drain() || !server.Draining() {
+		t.Fatalf("expected server to enter draining state")
+	}
+	if server.Drain() {
+		t.Fatalf("expected second drain to be a no-op")
+	}
+
+	client, err := NewClient(redisCfg)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.client.Enqueue(asynq.NewTask("demo", []byte("after-drain"))); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	select {
+	case payload := <-processed:
+		t.Fatalf("drained server processed %q", payload)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := server.Undrain(); err != nil {
+		t.Fatalf("undrain: %v", err)
+	}
+	if server.Draining() {
+		t.Fatalf("expected server to leave draining state")
+	}
+	select {
+	case payload := <-processed:
+		if payload != "after-drain" {
+			t.Fatalf("unexpected payload %q", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected task to be processed after undrain")
+	}
+}
+
+func TestServerReconfigureRestoresPreviousWeightsWhenStartFails(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisCfg := &config.RedisConfig{Addr: mr.Addr()}
+	server, err := NewServer(ServerConfig{
+		Redis:       redisCfg,
+		Queues:      map[string]int{"default": 1},
+		Concurrency: 1,
+		Logger:      zap.NewNop(),
+	})
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	processed := make(chan string, 1)
+	server.HandleFunc("demo", func(ctx context.Context, task *asynq.Task) error {
+		processed <- string(task.Payload())
+		return nil
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer server.Shutdown()
+
+	// 新权重的服务已在运行，再次 Start 会失败；它只消费 other，不会取走 default 的任务
+	newServer := server.newServer
+	var running *asynq.Server
+	server.newServer = func(queues map[string]int) *asynq.Server {
+		srv := newServer(queues)
+		if _, ok := queues["other"]; ok {
+			if err := srv.Start(asynq.HandlerFunc(func(context.Context, *asynq.Task) error { return nil })); err != nil {
+				t.Fatalf("start: %v", err)
+			}
+			running = srv
+		}
+		return srv
+	}
+	if err := server.Reconfigure(map[string]int{"other": 1}); err == nil {
+		t.Fatal("expected reconfigure to fail")
+	}
+	defer running.Shutdown()
+	if _, ok := server.queues["default"]; !ok {
+		t.Fatalf("expected previous weights to be kept, got %v", server.queues)
+	}
+
+	client, err := NewClient(redisCfg)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.client.Enqueue(asynq.NewTask("demo", []byte("after-reconfigure"))); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	select {
+	case payload := <-processed:
+		if payload != "after-reconfigure" {
+			t.Fatalf("unexpected payload %q", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the server to keep consuming with the previous weights")
+	}
+}
@@ -0,0 +1,220 @@
+// Package queuecleanup 删除不在配置中且长期为空的队列。拼写错误或已从配置移除的队列
+// 会一直留在 asynq 的队列列表中，出现在队列统计和指标里
+package queuecleanup
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/config"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+// 清理结果
+const (
+	ResultDeleted = "deleted"
+	ResultDryRun  = "dry_run"
+	ResultFailed  = "failed"
+)
+
+// Queues 读取队列统计和删除空队列
+type Queues interface {
+	QueueInfos() ([]*asynq.QueueInfo, error)
+	DeleteQueue(queue string) error
+}
+
+// Recorder 记录清理结果，并删除已删除队列的指标
+type Recorder interface {
+	ObserveQueueCleanup(result string)
+	ForgetQueue(queue string)
+}
+
+// Cleaner 定期删除过期队列。队列为空的起始时间只记录在内存中，worker 重启后重新计算；
+// 删除时 asynq 会再次检查队列为空，多个 worker 同时运行或队列刚收到任务时不会误删
+type Cleaner struct {
+	queues    Queues
+	known     map[string]bool
+	retention time.Duration
+	dryRun    bool
+
+	// emptySince 不在配置中的队列第一次被发现为空的时间
+	emptySince map[string]time.Time
+	// reportedAt dry run 时队列上次被报告的时间，每个保留时长内只报告一次
+	reportedAt map[string]time.Time
+
+	recorder Recorder
+	clock    clock.Clock
+	logger   *zap.Logger
+}
+
+// Option Cleaner 可选项
+type Option func(*Cleaner)
+
+// WithRecorder 记录清理结果并删除已删除队列的指标
+func WithRecorder(r Recorder) Option {
+	return func(c *Cleaner) {
+		c.recorder = r
+	}
+}
+
+// WithClock 替换计算为空时长和检查间隔使用的时钟，用于测试
+func WithClock(clk clock.Clock) Option {
+	return func(c *Cleaner) {
+		c.clock = clk
+	}
+}
+
+// New 创建 Cleaner，known 为配置中的队列，这些队列不会被删除
+func New(queues Queues, known []string, cfg *config.QueueCleanupConfig, logger *zap.Logger, opts ...Option) *Cleaner {
+	c := &Cleaner{
+		queues:     queues,
+		known:      make(map[string]bool, len(known)),
+		retention:  cfg.Retention,
+		dryRun:     cfg.DryRun,
+		emptySince: make(map[string]time.Time),
+		reportedAt: make(map[string]time.Time),
+		logger:     logger,
+	}
+	for _, q := range known {
+		c.known[q] = true
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.clock = clock.OrReal(c.clock)
+	return c
+}
+
+// Run 立即检查一次，之后每隔 interval 检查，直到 ctx 结束
+func (c *Cleaner) Run(ctx context.Context, interval time.Duration) {
+	c.Clean()
+
+	ticker := c.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			c.Clean()
+		}
+	}
+}
+
+// Clean 检查一次所有队列，返回本次删除（dry run 时为本次报告将被删除）的队列
+func (c *Cleaner) Clean() []string {
+	infos, err := c.queues.QueueInfos()
+	if err != nil {
+		c.logger.Warn("failed to list queues for cleanup", zap.Error(err))
+		return nil
+	}
+
+	now := c.clock.Now()
+	seen := make(map[string]bool, len(infos))
+	var removed []string
+	for _, info := range infos {
+		queue := info.Queue
+		if c.known[queue] {
+			continue
+		}
+		seen[queue] = true
+		// Size 不含已完成的任务，保留中的已完成任务过期后队列才算空
+		if info.Size > 0 || info.Completed > 0 {
+			c.untrack(queue)
+			continue
+		}
+		since, ok := c.emptySince[queue]
+		if !ok {
+			c.emptySince[queue] = now
+			c.logger.Debug("unconfigured queue is empty, waiting for retention before deleting it",
+				zap.String("queue", queue),
+				zap.Duration("retention", c.retention),
+			)
+			continue
+		}
+		if now.Sub(since) < c.retention {
+			continue
+		}
+
+		if c.dryRun {
+			if last, ok := c.reportedAt[queue]; ok && now.Sub(last) < c.retention {
+				continue
+			}
+			c.reportedAt[queue] = now
+			c.logger.Info("stale queue would be deleted (dry run)",
+				zap.String("queue", queue),
+				zap.Duration("empty_for", now.Sub(since)),
+			)
+			c.record(ResultDryRun)
+			removed = append(removed, queue)
+			continue
+		}
+		if c.delete(queue, now.Sub(since)) {
+			removed = append(removed, queue)
+		}
+	}
+
+	// 已被删除（或被其他 worker 删除）的队列不再跟踪
+	for queue := range c.emptySince {
+		if !seen[queue] {
+			c.untrack(queue)
+		}
+	}
+	return removed
+}
+
+// untrack 清除队列的为空起始时间和 dry run 报告时间
+func (c *Cleaner) untrack(queue string) {
+	delete(c.emptySince, queue)
+	delete(c.reportedAt, queue)
+}
+
+// delete 删除空队列并删除其指标，返回是否由本次调用删除
+func (c *Cleaner) delete(queue string, emptyFor time.Duration) bool {
+	err := c.queues.DeleteQueue(queue)
+	switch {
+	case err == nil:
+	case errors.Is(err, asynq.ErrQueueNotFound):
+		// 其他 worker 已删除，本实例的指标同样需要删除
+		c.untrack(queue)
+		c.forget(queue)
+		return false
+	case errors.Is(err, asynq.ErrQueueNotEmpty):
+		// 检查之后队列收到了任务，重新计算为空时长
+		c.untrack(queue)
+		c.logger.Debug("stale queue received tasks before it could be deleted", zap.String("queue", queue))
+		return false
+	default:
+		c.logger.Error("failed to delete stale queue",
+			zap.String("queue", queue),
+			zap.Error(err),
+		)
+		c.record(ResultFailed)
+		return false
+	}
+
+	c.untrack(queue)
+	c.forget(queue)
+	c.record(ResultDeleted)
+	c.logger.Info("stale queue deleted",
+		zap.String("queue", queue),
+		zap.Duration("empty_for", emptyFor),
+	)
+	return true
+}
+
+func (c *Cleaner) record(result string) {
+	if c.recorder != nil {
+		c.recorder.ObserveQueueCleanup(result)
+	}
+}
+
+func (c *Cleaner) forget(queue string) {
+	if c.recorder != nil {
+		c.recorder.ForgetQueue(queue)
+	}
+}
@@ -0,0 +1,133 @@
+package queuecleanup
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/config"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+type fakeQueues struct {
+	infos     []*asynq.QueueInfo
+	deleteErr map[string]error
+	deleted   []string
+}
+
+func (f *fakeQueues) QueueInfos() ([]*asynq.QueueInfo, error) {
+	return f.infos, nil
+}
+
+func (f *fakeQueues) DeleteQueue(queue string) error {
+	if err := f.deleteErr[queue]; err != nil {
+		return err
+	}
+	f.deleted = append(f.deleted, queue)
+	return nil
+}
+
+type fakeRecorder struct {
+	results   []string
+	forgotten []string
+}
+
+func (f *fakeRecorder) ObserveQueueCleanup(result string) {
+	f.results = append(f.results, result)
+}
+
+func (f *fakeRecorder) ForgetQueue(queue string) {
+	f.forgotten = append(f.forgotten, queue)
+}
+
+func TestCleanDeletesQueuesEmptyPastRetention(t *testing.T) {
+	queues := &fakeQueues{
+		infos: []*asynq.QueueInfo{
+			{Queue: "default"},
+			{Queue: "defualt"},
+			{Queue: "busy", Size: 3},
+			{Queue: "done", Completed: 1},
+			{Queue: "refilled"},
+			{Queue: "gone"},
+		},
+		deleteErr: map[string]error{
+			"refilled": asynq.ErrQueueNotEmpty,
+			"gone":     asynq.ErrQueueNotFound,
+		},
+	}
+	recorder := &fakeRecorder{}
+	fake := clock.NewFake(time.Unix(0, 0))
+	cleaner := New(queues, []string{"default"}, &config.QueueCleanupConfig{Retention: time.Hour}, zap.NewNop(),
+		WithRecorder(recorder), WithClock(fake))
+
+	// 第一次发现为空时只开始计时
+	if removed := cleaner.Clean(); len(removed) != 0 {
+		t.Fatalf("expected nothing to be removed on first sight, got %v", removed)
+	}
+	fake.Advance(30 * time.Minute)
+	if removed := cleaner.Clean(); len(removed) != 0 {
+		t.Fatalf("expected nothing to be removed before the retention, got %v", removed)
+	}
+
+	fake.Advance(30 * time.Minute)
+	removed := cleaner.Clean()
+	if !slices.Equal(removed, []string{"defualt"}) || !slices.Equal(queues.deleted, []string{"defualt"}) {
+		t.Fatalf("expected only the empty unconfigured queue to be deleted, got %v (deleted %v)", removed, queues.deleted)
+	}
+	if !slices.Equal(recorder.results, []string{ResultDeleted}) {
+		t.Fatalf("unexpected results %v", recorder.results)
+	}
+	// 被其他 worker 删除的队列同样清除指标
+	if !slices.Equal(recorder.forgotten, []string{"defualt", "gone"}) {
+		t.Fatalf("expected deleted queues to be forgotten, got %v", recorder.forgotten)
+	}
+
+	// 删除前收到任务的队列重新计时
+	queues.deleteErr = nil
+	queues.infos = []*asynq.QueueInfo{{Queue: "refilled"}}
+	if removed := cleaner.Clean(); len(removed) != 0 {
+		t.Fatalf("expected the refilled queue to wait for the retention again, got %v", removed)
+	}
+}
+
+func TestCleanDryRunAndFailures(t *testing.T) {
+	queues := &fakeQueues{infos: []*asynq.QueueInfo{{Queue: "stale"}}}
+	recorder := &fakeRecorder{}
+	fake := clock.NewFake(time.Unix(0, 0))
+	cfg := &config.QueueCleanupConfig{Retention: time.Minute, DryRun: true}
+	cleaner := New(queues, nil, cfg, zap.NewNop(), WithRecorder(recorder), WithClock(fake))
+
+	cleaner.Clean()
+	fake.Advance(time.Minute)
+	if removed := cleaner.Clean(); !slices.Equal(removed, []string{"stale"}) {
+		t.Fatalf("expected the stale queue to be reported, got %v", removed)
+	}
+	if len(queues.deleted) != 0 || len(recorder.forgotten) != 0 {
+		t.Fatalf("expected dry run to leave the queue, got deleted=%v forgotten=%v", queues.deleted, recorder.forgotten)
+	}
+	// 每个保留时长内只报告一次
+	fake.Advance(30 * time.Second)
+	if removed := cleaner.Clean(); len(removed) != 0 {
+		t.Fatalf("expected the stale queue not to be reported again within the retention, got %v", removed)
+	}
+	fake.Advance(30 * time.Second)
+	if removed := cleaner.Clean(); !slices.Equal(removed, []string{"stale"}) {
+		t.Fatalf("expected the stale queue to be reported again after the retention, got %v", removed)
+	}
+
+	cfg.DryRun = false
+	queues.deleteErr = map[string]error{"stale": errors.New("redis down")}
+	cleaner = New(queues, nil, cfg, zap.NewNop(), WithRecorder(recorder), WithClock(fake))
+	cleaner.Clean()
+	fake.Advance(time.Minute)
+	if removed := cleaner.Clean(); len(removed) != 0 {
+		t.Fatalf("expected a failed delete to remove nothing, got %v", removed)
+	}
+	if !slices.Equal(recorder.results, []string{ResultDryRun, ResultDryRun, ResultFailed}) {
+		t.Fatalf("unexpected results %v", recorder.results)
+	}
+}
@@ -0,0 +1,127 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Aixtrade/TaskFlow/internal/config"
+)
+
+// ErrUnroutable 标签组合没有对应的路由
+var ErrUnroutable = errors.New("no queue configured for required labels")
+
+// Table 执行环境标签到专用队列的路由表
+type Table struct {
+	mu     sync.RWMutex
+	routes map[string]string // 规范化标签组合 -> 队列后缀
+	labels map[string][]string
+}
+
+// NewTable 根据配置创建路由表
+func NewTable(cfg *config.RoutingConfig) *Table {
+	t := &Table{}
+	t.Update(cfg)
+	return t
+}
+
+// Update 用新配置替换路由表，用于配置热更新
+func (t *Table) Update(cfg *config.RoutingConfig) {
+	routes := make(map[string]string, len(cfg.Routes))
+	labels := make(map[string][]string, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		key, normalized := normalize(route.Requires)
+		routes[key] = route.Suffix
+		labels[key] = normalized
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.routes = routes
+	t.labels = labels
+}
+
+// Route 返回带有指定标签要求的任务应进入的队列
+func (t *Table) Route(queue string, requires []string) (string, error) {
+	if len(requires) == 0 {
+		return queue, nil
+	}
+
+	key, _ := normalize(requires)
+	t.mu.RLock()
+	suffix, ok := t.routes[key]
+	t.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnroutable, key)
+	}
+	return queue + "." + suffix, nil
+}
+
+// Queues 返回具备指定标签的 worker 应消费的队列及权重
+// 基础队列始终消费；标签要求是 worker 标签子集的路由，其队列沿用基础队列权重
+func (t *Table) Queues(base map[string]int, labels []string) map[string]int {
+	queues := make(map[string]int, len(base))
+	for q, w := range base {
+		queues[q] = w
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for key, suffix := range t.routes {
+		if !subset(t.labels[key], labels) {
+			continue
+		}
+		for q, w := range base {
+			queues[q+"."+suffix] = w
+		}
+	}
+	return queues
+}
+
+// Served 返回具备指定标签的 worker 可服务的标签组合
+func (t *Table) Served(labels []string) []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var served []string
+	for key := range t.routes {
+		if subset(t.labels[key], labels) {
+			served = append(served, key)
+		}
+	}
+	sort.Strings(served)
+	return served
+}
+
+// normalize 去重、排序标签，返回以 "+" 连接的组合 key
+func normalize(labels []string) (string, []string) {
+	seen := make(map[string]struct{}, len(labels))
+	out := make([]string, 0, len(labels))
+	for _, l := range labels {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if l == "" {
+			continue
+		}
+		if _, ok := seen[l]; ok {
+			continue
+		}
+		seen[l] = struct{}{}
+		out = append(out, l)
+	}
+	sort.Strings(out)
+	return strings.Join(out, "+"), out
+}
+
+func subset(required, labels []string) bool {
+	have := make(map[string]struct{}, len(labels))
+	for _, l := range labels {
+		have[strings.ToLower(strings.TrimSpace(l))] = struct{}{}
+	}
+	for _, r := range required {
+		if _, ok := have[r]; !ok {
+			return false
+		}
+	}
+	return true
+}
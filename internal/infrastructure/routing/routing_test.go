@@ -0,0 +1,74 @@
+package routing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Aixtrade/TaskFlow/internal/config"
+)
+
+func newTestTable() *Table {
+	return NewTable(&config.RoutingConfig{Routes: []config.LabelRouteConfig{
+		{Requires: []string{"gpu"}, Suffix: "gpu"},
+		{Requires: []string{"highmem", "gpu"}, Suffix: "gpu-highmem"},
+	}})
+}
+
+func TestTableRoute(t *testing.T) {
+	table := newTestTable()
+
+	cases := []struct {
+		requires []string
+		want     string
+	}{
+		{nil, "default"},
+		{[]string{"gpu"}, "default.gpu"},
+		{[]string{"GPU", "highmem", "gpu"}, "default.gpu-highmem"},
+	}
+	for _, tc := range cases {
+		got, err := table.Route("default", tc.requires)
+		if err != nil {
+			t.Fatalf("route %v: unexpected error: %v", tc.requires, err)
+		}
+		if got != tc.want {
+			t.Fatalf("route %v: expected %s, got %s", tc.requires, tc.want, got)
+		}
+	}
+
+	if _, err := table.Route("default", []string{"tpu"}); !errors.Is(err, ErrUnroutable) {
+		t.Fatalf("expected ErrUnroutable, got %v", err)
+	}
+}
+
+func TestTableQueuesForWorkerLabels(t *testing.T) {
+	table := newTestTable()
+	base := map[string]int{"default": 3, "low": 1}
+
+	got := table.Queues(base, []string{"gpu"})
+	want := map[string]int{"default": 3, "low": 1, "default.gpu": 3, "low.gpu": 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	if served := table.Served([]string{"gpu", "highmem"}); !reflect.DeepEqual(served, []string{"gpu", "gpu+highmem"}) {
+		t.Fatalf("unexpected served labels %v", served)
+	}
+	if served := table.Served(nil); len(served) != 0 {
+		t.Fatalf("expected no served labels, got %v", served)
+	}
+}
+
+func TestTableUpdateReplacesRoutes(t *testing.T) {
+	table := newTestTable()
+	table.Update(&config.RoutingConfig{Routes: []config.LabelRouteConfig{
+		{Requires: []string{"arm"}, Suffix: "arm"},
+	}})
+
+	if got, err := table.Route("default", []string{"arm"}); err != nil || got != "default.arm" {
+		t.Fatalf("expected default.arm, got %q (%v)", got, err)
+	}
+	if _, err := table.Route("default", []string{"gpu"}); !errors.Is(err, ErrUnroutable) {
+		t.Fatalf("expected removed route to be unroutable, got %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"go.uber.org/zap"
+
+	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+)
+
+const (
+	schemaKeyPrefix = "taskflow:schemas:"
+	// generationKey 任意 schema 变更时递增，用于各进程轮询失效缓存
+	generationKey = "taskflow:schemas:generation"
+)
+
+// ErrSchemaNotFound 任务类型未注册 schema
+var ErrSchemaNotFound = errors.New("schema not found")
+
+// ErrInvalidSchema schema 无法编译
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// Schema 任务类型的 payload schema
+type Schema struct {
+	TaskType  string          `json:"task_type"`
+	Version   int64           `json:"version"`
+	Document  json.RawMessage `json:"schema"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type compiledSchema struct {
+	version int64
+	schema  *jsonschema.Schema // 为空表示该类型未注册 schema
+}
+
+// Registry 基于 Redis 的 payload schema 注册表
+// 编译后的 schema 缓存在进程内，通过轮询全局 generation 感知变更
+type Registry struct {
+	redis  *redis.Client
+	logger *zap.Logger
+
+	mu         sync.RWMutex
+	cache      map[string]compiledSchema
+	generation int64
+}
+
+// NewRegistry 创建 schema 注册表
+func NewRegistry(redisClient *redis.Client, logger *zap.Logger) *Registry {
+	return &Registry{
+		redis:  redisClient,
+		logger: logger,
+		cache:  make(map[string]compiledSchema),
+	}
+}
+
+func schemaKey(taskType string) string {
+	return schemaKeyPrefix + taskType
+}
+
+// Put 编译并保存 schema，返回新版本
+func (r *Registry) Put(ctx context.Context, taskType string, document json.RawMessage) (*Schema, error) {
+	if _, err := compile(taskType, document); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+
+	key := schemaKey(taskType)
+	now := time.Now().UTC()
+
+	pipe := r.redis.TxPipeline()
+	versionCmd := pipe.HIncrBy(ctx, key, "version", 1)
+	pipe.HSet(ctx, key, "document", string(document), "updated_at", now.Format(time.RFC3339Nano))
+	pipe.Incr(ctx, generationKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to store schema: %w", err)
+	}
+
+	r.invalidate(taskType)
+
+	r.logger.Info("payload schema updated",
+		zap.String("type", taskType),
+		zap.Int64("version", versionCmd.Val()),
+	)
+
+	return &Schema{
+		TaskType:  taskType,
+		Version:   versionCmd.Val(),
+		Document:  document,
+		UpdatedAt: now,
+	}, nil
+}
+
+// Get 读取 schema
+func (r *Registry) Get(ctx context.Context, taskType string) (*Schema, error) {
+	values, err := r.redis.HGetAll(ctx, schemaKey(taskType)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if values["document"] == "" {
+		return nil, ErrSchemaNotFound
+	}
+
+	version, _ := strconv.ParseInt(values["version"], 10, 64)
+	updatedAt, _ := time.Parse(time.RFC3339Nano, values["updated_at"])
+
+	return &Schema{
+		TaskType:  taskType,
+		Version:   version,
+		Document:  json.RawMessage(values["document"]),
+		UpdatedAt: updatedAt,
+	}, nil
+}
+
+// ValidatePayload 按注册的 schema 校验 payload，未注册 schema 的类型直接通过
+func (r *Registry) ValidatePayload(ctx context.Context, taskType string, payload []byte) error {
+	compiled, err := r.load(ctx, taskType)
+	if err != nil {
+		return err
+	}
+	if reason := validate(compiled, payload); reason != "" {
+		return apperrors.NewPayloadSchemaError(taskType, compiled.version, reason)
+	}
+	return nil
+}
+
+// OutputWildcard 服务级输出 schema 的方法名，方法未单独注册时使用
+const OutputWildcard = "*"
+
+func outputKey(service, method string) string {
+	return "output:" + service + "/" + method
+}
+
+// PutOutput 保存 gRPC 服务方法的结果 schema，method 为 OutputWildcard 时对整个服务生效
+func (r *Registry) PutOutput(ctx context.Context, service, method string, document json.RawMessage) (*Schema, error) {
+	return r.Put(ctx, outputKey(service, method), document)
+}
+
+// GetOutput 读取 gRPC 服务方法的结果 schema
+func (r *Registry) GetOutput(ctx context.Context, service, method string) (*Schema, error) {
+	return r.Get(ctx, outputKey(service, method))
+}
+
+// OutputSchemaError gRPC 服务返回的结果未通过 schema 校验
+type OutputSchemaError struct {
+	Service       string
+	Method        string
+	SchemaVersion int64
+	Reason        string
+}
+
+func (e *OutputSchemaError) Error() string {
+	return fmt.Sprintf("result of %s/%s rejected by output schema v%d: %s", e.Service, e.Method, e.SchemaVersion, e.Reason)
+}
+
+// ValidateOutput 按服务方法的结果 schema 校验结果，方法未注册时回退到服务级 schema，均未注册时直接通过
+func (r *Registry) ValidateOutput(ctx context.Context, service, method string, data []byte) error {
+	compiled, err := r.load(ctx, outputKey(service, method))
+	if err != nil {
+		return err
+	}
+	if compiled.schema == nil && method != OutputWildcard {
+		if compiled, err = r.load(ctx, outputKey(service, OutputWildcard)); err != nil {
+			return err
+		}
+	}
+	if reason := validate(compiled, data); reason != "" {
+		return &OutputSchemaError{
+			Service:       service,
+			Method:        method,
+			SchemaVersion: compiled.version,
+			Reason:        reason,
+		}
+	}
+	return nil
+}
+
+// validate 返回校验失败原因，schema 未注册或校验通过时返回空字符串
+func validate(compiled compiledSchema, data []byte) string {
+	if compiled.schema == nil {
+		return ""
+	}
+
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return err.Error()
+	}
+	if err := compiled.schema.Validate(inst); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// Watch 定期检查 schema 变更并清空本地缓存，直到 ctx 取消
+func (r *Registry) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			generation, err := r.redis.Get(ctx, generationKey).Int64()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				if ctx.Err() == nil {
+					r.logger.Warn("failed to poll schema generation", zap.Error(err))
+				}
+				continue
+			}
+
+			r.mu.Lock()
+			if generation != r.generation {
+				r.generation = generation
+				r.cache = make(map[string]compiledSchema)
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+// load 从缓存或 Redis 读取编译后的 schema
+func (r *Registry) load(ctx context.Context, taskType string) (compiledSchema, error) {
+	r.mu.RLock()
+	compiled, ok := r.cache[taskType]
+	r.mu.RUnlock()
+	if ok {
+		return compiled, nil
+	}
+
+	s, err := r.Get(ctx, taskType)
+	switch {
+	case errors.Is(err, ErrSchemaNotFound):
+		compiled = compiledSchema{}
+	case err != nil:
+		return compiledSchema{}, fmt.Errorf("failed to load schema: %w", err)
+	default:
+		sch, err := compile(taskType, s.Document)
+		if err != nil {
+			return compiledSchema{}, fmt.Errorf("stored schema for %s is invalid: %w", taskType, err)
+		}
+		compiled = compiledSchema{version: s.Version, schema: sch}
+	}
+
+	r.mu.Lock()
+	r.cache[taskType] = compiled
+	r.mu.Unlock()
+
+	return compiled, nil
+}
+
+func (r *Registry) invalidate(taskType string) {
+	r.mu.Lock()
+	delete(r.cache, taskType)
+	r.mu.Unlock()
+}
+
+func compile(taskType string, document json.RawMessage) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(document))
+	if err != nil {
+		return nil, err
+	}
+
+	url := "taskflow://schemas/" + taskType + ".json"
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(url, doc); err != nil {
+		return nil, err
+	}
+	return c.Compile(url)
+}
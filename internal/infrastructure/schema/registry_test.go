@@ -0,0 +1,46 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRegistry(client, zap.NewNop())
+}
+
+func TestValidateOutputFallsBackToServiceSchema(t *testing.T) {
+	registry := newTestRegistry(t)
+	ctx := context.Background()
+
+	if _, err := registry.PutOutput(ctx, "llm", OutputWildcard, []byte(`{"type":"object","required":["text"]}`)); err != nil {
+		t.Fatalf("put service schema: %v", err)
+	}
+	if _, err := registry.PutOutput(ctx, "llm", "embed", []byte(`{"type":"object","required":["vector"]}`)); err != nil {
+		t.Fatalf("put method schema: %v", err)
+	}
+
+	if err := registry.ValidateOutput(ctx, "llm", "chat", []byte(`{"text":"hi"}`)); err != nil {
+		t.Fatalf("expected chat result to pass service schema, got %v", err)
+	}
+
+	err := registry.ValidateOutput(ctx, "llm", "embed", []byte(`{"text":"hi"}`))
+	var schemaErr *OutputSchemaError
+	if !errors.As(err, &schemaErr) || schemaErr.Method != "embed" || schemaErr.SchemaVersion != 1 {
+		t.Fatalf("expected OutputSchemaError from method schema, got %v", err)
+	}
+
+	if err := registry.ValidateOutput(ctx, "trading", "backtest", []byte(`[]`)); err != nil {
+		t.Fatalf("expected unregistered service to pass, got %v", err)
+	}
+}
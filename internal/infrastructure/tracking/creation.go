@@ -0,0 +1,46 @@
+package tracking
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const createdKeyPrefix = "taskflow:created:"
+
+// CreationTracker 在 Redis 中记录刚创建的任务，用于读己之写
+// 标记只保留很短时间，覆盖 inspector 尚未观察到任务的窗口
+type CreationTracker struct {
+	redis *redis.Client
+	ttl   time.Duration
+}
+
+// NewCreationTracker 创建任务创建标记器
+func NewCreationTracker(redisClient *redis.Client, ttl time.Duration) *CreationTracker {
+	if ttl <= 0 {
+		ttl = 10 * time.Second
+	}
+	return &CreationTracker{
+		redis: redisClient,
+		ttl:   ttl,
+	}
+}
+
+// MarkCreated 记录任务已创建及其所在队列
+func (t *CreationTracker) MarkCreated(ctx context.Context, taskID, queue string) error {
+	return t.redis.Set(ctx, createdKeyPrefix+taskID, queue, t.ttl).Err()
+}
+
+// RecentlyCreated 返回任务是否刚创建以及创建时的队列
+func (t *CreationTracker) RecentlyCreated(ctx context.Context, taskID string) (string, bool, error) {
+	queue, err := t.redis.Get(ctx, createdKeyPrefix+taskID).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return queue, true, nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
 	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
 )
 
@@ -16,6 +17,9 @@ type CreateTaskRequest struct {
 	ProcessAt  string            `json:"process_at,omitempty"`
 	Unique     string            `json:"unique,omitempty"`
 	Metadata   map[string]string `json:"metadata,omitempty"`
+	Requires   []string          `json:"requires,omitempty"`
+	// TaskID 指定任务 ID，必须符合服务配置的 ID 格式（含前缀）
+	TaskID string `json:"task_id,omitempty"`
 }
 
 func (r *CreateTaskRequest) GetTimeout() (time.Duration, error) {
@@ -43,10 +47,122 @@ func (r *CreateTaskRequest) GetTaskType() tasktype.Type {
 	return tasktype.Type(r.Type)
 }
 
+type UploadTaskRequest struct {
+	Type       string   `form:"type" binding:"required"`
+	Payload    string   `form:"payload"`
+	Queue      string   `form:"queue"`
+	MaxRetries int      `form:"max_retries"`
+	Timeout    string   `form:"timeout"`
+	ProcessAt  string   `form:"process_at"`
+	Unique     string   `form:"unique"`
+	Metadata   string   `form:"metadata"`
+	Requires   []string `form:"requires"`
+	FileField  string   `form:"file_field"`
+	TaskID     string   `form:"task_id"`
+}
+
+// CreateTaskRequest 将表单字段转换为 JSON 创建请求，复用其解析逻辑
+func (r *UploadTaskRequest) CreateTaskRequest() (*CreateTaskRequest, error) {
+	req := &CreateTaskRequest{
+		Type:       r.Type,
+		Queue:      r.Queue,
+		MaxRetries: r.MaxRetries,
+		Timeout:    r.Timeout,
+		ProcessAt:  r.ProcessAt,
+		Unique:     r.Unique,
+		Requires:   r.Requires,
+		TaskID:     r.TaskID,
+	}
+	if r.Payload != "" {
+		req.Payload = json.RawMessage(r.Payload)
+	}
+	if r.Metadata != "" {
+		if err := json.Unmarshal([]byte(r.Metadata), &req.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
 type CreateTaskResponse struct {
-	TaskID string `json:"task_id"`
-	Queue  string `json:"queue"`
-	Status string `json:"status"`
+	TaskID   string   `json:"task_id"`
+	Queue    string   `json:"queue"`
+	Status   string   `json:"status"`
+	Self     string   `json:"self"`
+	Warnings []string `json:"warnings,omitempty"`
+	// TaskURL、ProgressURL 和 ProgressStreamURL 为完整地址，客户端无需自行拼接
+	TaskURL           string `json:"task_url,omitempty"`
+	ProgressURL       string `json:"progress_url,omitempty"`
+	ProgressStreamURL string `json:"progress_stream_url,omitempty"`
+	// Deprecations 请求命中的弃用项，同时以 299 Warning 头返回
+	Deprecations []DeprecationNotice `json:"deprecations,omitempty"`
+
+	UniqueTTLSeconds int64  `json:"unique_ttl_seconds,omitempty"`
+	UniqueExpiresAt  string `json:"unique_expires_at,omitempty"`
+
+	// Effective 合并类型默认值、全局默认值和上限之后实际生效的选项
+	Effective EffectiveOptions `json:"effective"`
+}
+
+// EffectiveOptions 任务实际生效的选项，时长单位为秒
+type EffectiveOptions struct {
+	Queue          string `json:"queue"`
+	MaxRetries     int    `json:"max_retries"`
+	TimeoutSeconds int64  `json:"timeout_seconds"`
+	// RetentionSeconds 完成后任务记录的保留时长，为 0 时完成即删除
+	RetentionSeconds int64 `json:"retention_seconds"`
+	UniqueTTLSeconds int64 `json:"unique_ttl_seconds"`
+	// ProcessAt 定时任务的执行时间（RFC 3339），立即执行时为空
+	ProcessAt string `json:"process_at,omitempty"`
+}
+
+// DeprecationNotice 创建任务时命中的弃用项
+type DeprecationNotice struct {
+	Name    string `json:"name"`
+	Message string `json:"message,omitempty"`
+	// Cutoff 开始拒绝请求的时间（RFC 3339），未设置时为空
+	Cutoff string `json:"cutoff,omitempty"`
+	// Migrated payload 是否已改写为新格式后入队
+	Migrated bool `json:"migrated,omitempty"`
+}
+
+type CancelTaskResponse struct {
+	Message       string `json:"message"`
+	TaskID        string `json:"task_id"`
+	Queue         string `json:"queue"`
+	PreviousState string `json:"previous_state"`
+	Action        string `json:"action"`
+}
+
+type DeleteTaskResponse struct {
+	Message         string `json:"message"`
+	TaskID          string `json:"task_id"`
+	Queue           string `json:"queue"`
+	ProgressDeleted bool   `json:"progress_deleted"`
+}
+
+// ArchiveTaskResponse 归档任务的响应
+type ArchiveTaskResponse struct {
+	Message string `json:"message"`
+	TaskID  string `json:"task_id"`
+	Queue   string `json:"queue"`
+}
+
+// RunTaskResponse 立即执行任务的响应
+type RunTaskResponse struct {
+	Message       string `json:"message"`
+	TaskID        string `json:"task_id"`
+	Queue         string `json:"queue"`
+	PreviousState string `json:"previous_state"`
+}
+
+// RequeueTaskResponse 取消并重新入队任务的响应
+type RequeueTaskResponse struct {
+	Message      string `json:"message"`
+	TaskID       string `json:"task_id"`
+	Queue        string `json:"queue"`
+	NewTaskID    string `json:"new_task_id"`
+	StoppedState string `json:"stopped_state"`
 }
 
 type GetTaskResponse struct {
@@ -58,13 +174,50 @@ type GetTaskResponse struct {
 	Retried       int    `json:"retried"`
 	LastErr       string `json:"last_err,omitempty"`
 	NextProcessAt string `json:"next_process_at,omitempty"`
+
+	UniqueTTLSeconds int64  `json:"unique_ttl_seconds,omitempty"`
+	UniqueExpiresAt  string `json:"unique_expires_at,omitempty"`
+
+	// Worker 最近一次处理该任务的 worker 实例
+	Worker *progress.Worker `json:"worker,omitempty"`
+
+	// Payload 仅在指定 include_payload 时返回
+	Payload *PayloadResponse `json:"payload,omitempty"`
+}
+
+// TaskListPageResponse 分页的任务列表，所有状态使用同一结构
+type TaskListPageResponse struct {
+	Items []TaskListResponse `json:"items"`
+	Page  int                `json:"page"`
+	Size  int                `json:"size"`
+	// Total 该状态下的任务总数（过滤前）；队列计数不可用时改为返回 EstimatedTotal（已知的下限）
+	Total          *int `json:"total,omitempty"`
+	EstimatedTotal *int `json:"estimated_total,omitempty"`
+	HasMore        bool `json:"has_more"`
+	// Order 排序依据，均为升序
+	Order string `json:"order"`
+	// Scanned 本页过滤前的任务数
+	Scanned int `json:"scanned"`
 }
 
 type TaskListResponse struct {
-	ID    string `json:"id"`
-	Queue string `json:"queue"`
-	Type  string `json:"type"`
-	State string `json:"state"`
+	ID      string `json:"id"`
+	Queue   string `json:"queue"`
+	Type    string `json:"type"`
+	State   string `json:"state"`
+	LastErr string `json:"last_err,omitempty"`
+
+	Payload *PayloadResponse `json:"payload,omitempty"`
+}
+
+// PayloadResponse 任务 payload 的脱敏预览或完整内容
+type PayloadResponse struct {
+	Size      int    `json:"size"`
+	Preview   string `json:"preview,omitempty"`
+	Truncated bool   `json:"truncated"`
+	// Redacted 有字段被脱敏；payload 不是 JSON 时不返回预览并记为 true
+	Redacted bool            `json:"redacted"`
+	Full     json.RawMessage `json:"full,omitempty"`
 }
 
 type QueueStatsResponse struct {
@@ -75,6 +228,50 @@ type QueueStatsResponse struct {
 	Retry     int    `json:"retry"`
 	Archived  int    `json:"archived"`
 	Completed int    `json:"completed"`
+
+	Aggregating int `json:"aggregating"`
+	Groups      int `json:"groups"`
+}
+
+// QueueStatsEvent 队列统计 SSE 事件
+type QueueStatsEvent struct {
+	Seq    uint64               `json:"seq"`
+	Queues []QueueStatsResponse `json:"queues"`
+}
+
+type GroupResponse struct {
+	Group string `json:"group"`
+	Size  int    `json:"size"`
+}
+
+// OldestTasksResponse 队列中最早的任务，对应状态没有任务时为 null
+type OldestTasksResponse struct {
+	Queue     string              `json:"queue"`
+	Pending   *OldestTaskResponse `json:"pending"`
+	Scheduled *OldestTaskResponse `json:"scheduled"`
+}
+
+type OldestTaskResponse struct {
+	ID            string `json:"id"`
+	Type          string `json:"type"`
+	AgeSeconds    int64  `json:"age_seconds"`
+	NextProcessAt string `json:"next_process_at,omitempty"`
+}
+
+type FlushGroupResponse struct {
+	Message string `json:"message"`
+	Queue   string `json:"queue"`
+	Group   string `json:"group"`
+	Size    int    `json:"size"`
+	DryRun  bool   `json:"dry_run"`
+	// TaskIDs 受影响任务 ID 的样本，最多 20 个
+	TaskIDs []string `json:"task_ids"`
+}
+
+type MeResponse struct {
+	Authenticated bool `json:"authenticated"`
+	// APIKey 当前 key 的名称与限制，未启用 API key 时为空
+	APIKey any `json:"api_key,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -82,3 +279,74 @@ type ErrorResponse struct {
 	Code    string `json:"code,omitempty"`
 	Details any    `json:"details,omitempty"`
 }
+
+// SetMaintenanceRequest 切换只读维护模式
+type SetMaintenanceRequest struct {
+	Enabled *bool `json:"enabled"`
+	// Message 维护期间写请求返回的错误信息，为空时使用默认信息
+	Message string `json:"message,omitempty"`
+}
+
+// SetQueueWeightsRequest 覆盖队列权重，weights 为空对象时清除覆盖
+type SetQueueWeightsRequest struct {
+	Weights map[string]int `json:"weights"`
+}
+
+// QueueWeightsResponse 当前的队列权重覆盖及各 worker 的应用情况
+type QueueWeightsResponse struct {
+	Version int64          `json:"version"`
+	Weights map[string]int `json:"weights"`
+	// Workers 存活的 worker 及其心跳中报告的权重，未启用 discovery 时为空
+	Workers []QueueWeightsWorker `json:"workers,omitempty"`
+	// Pending 尚未应用当前版本的 worker 数
+	Pending int `json:"pending"`
+}
+
+type QueueWeightsWorker struct {
+	InstanceID     string         `json:"instance_id"`
+	Hostname       string         `json:"hostname"`
+	Queues         map[string]int `json:"queues"`
+	WeightsVersion int64          `json:"weights_version"`
+	Applied        bool           `json:"applied"`
+}
+
+// TestGRPCServiceRequest 待检查的 gRPC 服务配置，字段与 grpc_services.services 中的配置相同，时长为 Go duration 字符串
+type TestGRPCServiceRequest struct {
+	Address              string   `json:"address" binding:"required"`
+	MaxRetries           int      `json:"max_retries,omitempty"`
+	RetryDelay           string   `json:"retry_delay,omitempty"`
+	Methods              []string `json:"methods,omitempty"`
+	DefaultMethod        string   `json:"default_method,omitempty"`
+	PrewarmTimeout       string   `json:"prewarm_timeout,omitempty"`
+	MaxConcurrentStreams int      `json:"max_concurrent_streams,omitempty"`
+	StreamWaitTimeout    string   `json:"stream_wait_timeout,omitempty"`
+	ResultMode           string   `json:"result_mode,omitempty"`
+	ResponseMetadata     []string `json:"response_metadata,omitempty"`
+	HealthCheckTimeout   string   `json:"health_check_timeout,omitempty"`
+	HealthCheckRetries   *int     `json:"health_check_retries,omitempty"`
+	HealthCheckProtocol  string   `json:"health_check_protocol,omitempty"`
+	HealthCheckService   string   `json:"health_check_service,omitempty"`
+}
+
+// ReportProgressRequest 下游执行方上报的进度
+type ReportProgressRequest struct {
+	Percentage int32             `json:"percentage"`
+	Stage      string            `json:"stage,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// ReportCompletionRequest 下游执行方上报执行结束，status 为 completed 或 failed
+type ReportCompletionRequest struct {
+	Status   string            `json:"status" binding:"required"`
+	Message  string            `json:"message,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Result   json.RawMessage   `json:"result,omitempty"`
+}
+
+// ProgressReportResponse 上报已发布到任务的进度流
+type ProgressReportResponse struct {
+	TaskID string `json:"task_id"`
+	// Final 令牌已吊销，不再接受上报
+	Final bool `json:"final"`
+}
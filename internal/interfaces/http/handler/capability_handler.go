@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/discovery"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/dto"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
+)
+
+type CapabilityHandler struct {
+	directory *discovery.Directory
+}
+
+func NewCapabilityHandler(directory *discovery.Directory) *CapabilityHandler {
+	return &CapabilityHandler{
+		directory: directory,
+	}
+}
+
+func (h *CapabilityHandler) List(c *gin.Context) {
+	caps, err := h.directory.Capabilities(c.Request.Context())
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, dto.ErrorResponse{
+			Error: err.Error(),
+			Code:  "CAPABILITIES_FAILED",
+		})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, caps)
+}
@@ -7,26 +7,70 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/discovery"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
 )
 
+// defaultHealthCheckTimeout 未配置 operation_timeouts.health_check 时使用
+const defaultHealthCheckTimeout = 5 * time.Second
+
+// ProgressCanary 提供进度自检的最近结果，由 progress.Canary 实现
+type ProgressCanary interface {
+	Status() progress.CanaryStatus
+}
+
+// ConfigCheck 比较 API 与 worker 的配置指纹，由 discovery.FingerprintCheck 实现
+type ConfigCheck interface {
+	Check(ctx context.Context) discovery.FingerprintStatus
+}
+
 type HealthHandler struct {
 	redisClient *redis.Client
+	timeout     time.Duration
+	canary      ProgressCanary
+	configCheck ConfigCheck
+}
+
+// HealthHandlerOption HealthHandler 的可选配置
+type HealthHandlerOption func(*HealthHandler)
+
+// WithConfigCheck 在 /health?verbose=true 中报告 API 与 worker 的配置是否一致
+func WithConfigCheck(check ConfigCheck) HealthHandlerOption {
+	return func(h *HealthHandler) {
+		h.configCheck = check
+	}
 }
 
-func NewHealthHandler(redisClient *redis.Client) *HealthHandler {
-	return &HealthHandler{
+// NewHealthHandler 创建健康检查 handler，timeout 为 Redis 检查的超时，canary 为空时不报告进度自检
+func NewHealthHandler(redisClient *redis.Client, timeout time.Duration, canary ProgressCanary, opts ...HealthHandlerOption) *HealthHandler {
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	h := &HealthHandler{
 		redisClient: redisClient,
+		timeout:     timeout,
+		canary:      canary,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 type HealthResponse struct {
 	Status    string            `json:"status"`
 	Timestamp string            `json:"timestamp"`
 	Services  map[string]string `json:"services"`
+	// Progress 进度自检结果，未启用自检时省略
+	Progress *progress.CanaryStatus `json:"progress,omitempty"`
+	// Config API 与 worker 的配置指纹比较，仅 verbose=true 时返回
+	Config *discovery.FingerprintStatus `json:"config,omitempty"`
 }
 
 func (h *HealthHandler) Health(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
 	defer cancel()
 
 	services := make(map[string]string)
@@ -42,25 +86,49 @@ func (h *HealthHandler) Health(c *gin.Context) {
 		}
 	}
 
+	// 进度自检失败不影响任务的创建和查询，仅标记为降级
+	var canary *progress.CanaryStatus
+	if h.canary != nil {
+		s := h.canary.Status()
+		canary = &s
+		services["progress"] = s.Status
+		if s.Status == progress.CanaryUnhealthy && status == "healthy" {
+			status = "degraded"
+		}
+	}
+
+	// DB 等配置不一致时两侧都"健康"，但任务和进度互相不可见，标记为降级
+	var configStatus *discovery.FingerprintStatus
+	if h.configCheck != nil && c.Query("verbose") == "true" {
+		s := h.configCheck.Check(ctx)
+		configStatus = &s
+		services["config"] = s.Status
+		if s.Status == discovery.FingerprintMismatch && status == "healthy" {
+			status = "degraded"
+		}
+	}
+
 	statusCode := http.StatusOK
 	if status == "unhealthy" {
 		statusCode = http.StatusServiceUnavailable
 	}
 
-	c.JSON(statusCode, HealthResponse{
+	render.JSON(c, statusCode, HealthResponse{
 		Status:    status,
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 		Services:  services,
+		Progress:  canary,
+		Config:    configStatus,
 	})
 }
 
 func (h *HealthHandler) Ready(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
 	defer cancel()
 
 	if h.redisClient != nil {
 		if err := h.redisClient.Ping(ctx).Err(); err != nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{
+			render.JSON(c, http.StatusServiceUnavailable, gin.H{
 				"status": "not ready",
 				"reason": "redis unavailable",
 			})
@@ -68,9 +136,9 @@ func (h *HealthHandler) Ready(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+	render.JSON(c, http.StatusOK, gin.H{"status": "ready"})
 }
 
 func (h *HealthHandler) Live(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+	render.JSON(c, http.StatusOK, gin.H{"status": "alive"})
 }
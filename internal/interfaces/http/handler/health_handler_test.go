@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/discovery"
+)
+
+type fakeConfigCheck struct {
+	calls int
+}
+
+func (f *fakeConfigCheck) Check(ctx context.Context) discovery.FingerprintStatus {
+	f.calls++
+	api := discovery.Fingerprint{RedisDB: 0, AsynqDB: 0, ProgressPrefix: "progress:"}
+	worker := discovery.Fingerprint{RedisDB: 1, AsynqDB: 1, ProgressPrefix: "progress:", InstanceID: "w1"}
+	return discovery.FingerprintStatus{Status: discovery.FingerprintMismatch, Mismatches: api.Diff(worker), Worker: &worker}
+}
+
+func TestHealthVerboseReportsConfigMismatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	check := &fakeConfigCheck{}
+	r := gin.New()
+	r.GET("/health", NewHealthHandler(nil, 0, nil, WithConfigCheck(check)).Health)
+
+	get := func(target string) HealthResponse {
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, target, nil))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.Code)
+		}
+		var body HealthResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		return body
+	}
+
+	if body := get("/health"); body.Status != "healthy" || body.Config != nil || check.calls != 0 {
+		t.Fatalf("expected no config check without verbose, got %+v", body)
+	}
+
+	body := get("/health?verbose=true")
+	if body.Status != "degraded" || body.Services["config"] != discovery.FingerprintMismatch {
+		t.Fatalf("expected degraded health on mismatch, got %+v", body)
+	}
+	if body.Config == nil || len(body.Config.Mismatches) != 2 || body.Config.Mismatches[0] != "redis_db: api=0 worker=1" {
+		t.Fatalf("unexpected config status %+v", body.Config)
+	}
+}
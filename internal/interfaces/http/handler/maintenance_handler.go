@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/dto"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/middleware"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
+)
+
+// MaintenanceHandler 查询和切换只读维护模式
+type MaintenanceHandler struct {
+	mode   *middleware.MaintenanceMode
+	logger *zap.Logger
+}
+
+func NewMaintenanceHandler(mode *middleware.MaintenanceMode, logger *zap.Logger) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		mode:   mode,
+		logger: logger,
+	}
+}
+
+// Get 返回维护模式的当前状态
+// GET /api/v1/admin/maintenance
+func (h *MaintenanceHandler) Get(c *gin.Context) {
+	render.JSON(c, http.StatusOK, h.mode.State())
+}
+
+// Set 开启或关闭维护模式
+// PUT /api/v1/admin/maintenance
+func (h *MaintenanceHandler) Set(c *gin.Context) {
+	var req dto.SetMaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeBindError(c, err)
+		return
+	}
+	if req.Enabled == nil {
+		render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+			Error: "enabled is required",
+			Code:  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	state := h.mode.Set(*req.Enabled, req.Message)
+	h.logger.Info("maintenance mode changed",
+		zap.Bool("enabled", state.Enabled),
+		zap.String("message", state.Message),
+		zap.String("client_ip", c.ClientIP()),
+	)
+	render.JSON(c, http.StatusOK, state)
+}
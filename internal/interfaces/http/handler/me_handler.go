@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/dto"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/middleware"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
+)
+
+// Me 返回调用方 API key 的名称和限制，便于 key 持有者自查
+func Me(c *gin.Context) {
+	key := middleware.CurrentAPIKey(c)
+	if key == nil {
+		render.JSON(c, http.StatusOK, dto.MeResponse{Authenticated: false})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, dto.MeResponse{
+		Authenticated: true,
+		APIKey:        key,
+	})
+}
@@ -1,29 +1,86 @@
 package handler
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
 	"github.com/Aixtrade/TaskFlow/pkg/progress"
 )
 
+// ProgressSubscriber 读取和订阅任务进度，由 progress.Subscriber 实现
+type ProgressSubscriber interface {
+	SubscribeIter(ctx context.Context, taskID string, opts progress.SubscribeOptions) (*progress.Subscription, error)
+	GetHistory(ctx context.Context, taskID string, startID string, count int64) ([]progress.SubscribeResult, error)
+	GetLatest(ctx context.Context, taskID string) (*progress.SubscribeResult, error)
+	GetStreamInfo(ctx context.Context, taskID string) (*progress.StreamInfo, error)
+}
+
+// SubscriptionReserver 为 SSE 连接预留订阅容量，由 progress.Subscriber 实现。
+// subscriber 未实现该接口时不限制
+type SubscriptionReserver interface {
+	Reserve(n int) (release func(), err error)
+}
+
+// subscriptionRetryAfter 订阅容量用尽时建议客户端重试的间隔
+const subscriptionRetryAfter = 5 * time.Second
+
 // ProgressHandler 处理进度相关的 HTTP 请求
 type ProgressHandler struct {
-	subscriber *progress.Subscriber
-	logger     *zap.Logger
+	subscriber  ProgressSubscriber
+	logger      *zap.Logger
+	maxLifetime time.Duration
+	multiLimits MultiStreamLimits
+	reconnect   ReconnectHint
+	origins     []string
 }
 
 // NewProgressHandler 创建进度处理器
-func NewProgressHandler(subscriber *progress.Subscriber, logger *zap.Logger) *ProgressHandler {
-	return &ProgressHandler{
-		subscriber: subscriber,
-		logger:     logger,
+// maxLifetime 为 SSE 连接的最长持续时间，到期后发送 timeout 事件并断开，0 表示不限制
+func NewProgressHandler(subscriber ProgressSubscriber, logger *zap.Logger, maxLifetime time.Duration, opts ...ProgressHandlerOption) *ProgressHandler {
+	h := &ProgressHandler{
+		subscriber:  subscriber,
+		logger:      logger,
+		maxLifetime: maxLifetime,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// lifetime 返回连接到期的 channel 和释放定时器的函数，未限制时 channel 为 nil
+func (h *ProgressHandler) lifetime() (<-chan time.Time, func()) {
+	if h.maxLifetime <= 0 {
+		return nil, func() {}
+	}
+	timer := time.NewTimer(h.maxLifetime)
+	return timer.C, func() { timer.Stop() }
+}
+
+// lifetimeContext 返回连接到期时结束的 context，未限制时只随 ctx 结束
+func (h *ProgressHandler) lifetimeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if h.maxLifetime <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, h.maxLifetime)
+}
+
+// timeoutEvent 构造连接到期时的 timeout 事件
+func (h *ProgressHandler) timeoutEvent() gin.H {
+	return gin.H{
+		"reason":          "max_lifetime",
+		"max_lifetime_ms": h.maxLifetime.Milliseconds(),
 	}
 }
 
@@ -32,7 +89,7 @@ func NewProgressHandler(subscriber *progress.Subscriber, logger *zap.Logger) *Pr
 func (h *ProgressHandler) StreamProgress(c *gin.Context) {
 	taskID := c.Param("id")
 	if taskID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "task_id is required"})
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "task_id is required"})
 		return
 	}
 
@@ -48,6 +105,24 @@ func (h *ProgressHandler) StreamProgress(c *gin.Context) {
 	// 可选参数：是否包含历史进度
 	includeHistory := c.Query("history") == "true"
 
+	release, ok := h.reserve(c, 1)
+	if !ok {
+		return
+	}
+	defer release()
+
+	ctx := c.Request.Context()
+	style := render.StyleOf(c)
+
+	// 订阅进度更新，连接结束时 Close 释放后台读取
+	sub, err := h.subscriber.SubscribeIter(ctx, taskID, progress.SubscribeOptions{StartID: startID})
+	if err != nil {
+		h.logger.Error("failed to subscribe to progress", zap.String("task_id", taskID), zap.Error(err))
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "failed to subscribe to progress"})
+		return
+	}
+	defer sub.Close()
+
 	h.logger.Info("SSE connection established",
 		zap.String("task_id", taskID),
 		zap.String("start_id", startID),
@@ -59,57 +134,108 @@ func (h *ProgressHandler) StreamProgress(c *gin.Context) {
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 	c.Header("X-Accel-Buffering", "no") // 禁用 nginx 缓冲
+	h.writeRetry(c.Writer)
 
 	// 如果请求历史进度，先发送历史数据
 	if includeHistory {
 		h.sendHistory(c, taskID)
 	}
 
-	ctx := c.Request.Context()
-
-	// 订阅进度更新
-	ch := h.subscriber.Subscribe(ctx, taskID, startID)
+	readCtx, stop := h.lifetimeContext(ctx)
+	defer stop()
+	// 续订位置："$" 不是确定的位置，收到消息前不返回
+	lastID := startID
+	if lastID == "$" {
+		lastID = ""
+	}
 
 	c.Stream(func(w io.Writer) bool {
-		select {
-		case result, ok := <-ch:
-			if !ok {
-				// channel 已关闭
-				return false
+		result, ok := sub.Next(readCtx)
+		if !ok {
+			switch {
+			case ctx.Err() != nil:
+				h.logger.Debug("SSE connection closed by client",
+					zap.String("task_id", taskID),
+				)
+			case readCtx.Err() != nil:
+				// 任务迟迟没有完成事件（如 worker 已退出）时不让连接无限期占用资源，客户端可从 last_stream_id 续订
+				h.logger.Info("SSE connection reached max lifetime",
+					zap.String("task_id", taskID),
+					zap.Duration("max_lifetime", h.maxLifetime),
+				)
+				event := h.timeoutEvent()
+				event["task_id"] = taskID
+				if lastID != "" {
+					event["last_stream_id"] = lastID
+				}
+				h.writeSSEEvent(w, style, "timeout", event)
 			}
+			// 否则订阅已结束
+			return false
+		}
+		if result.StreamID != "" {
+			lastID = result.StreamID
+		}
 
-			if result.Error != nil {
-				// 发送错误事件
-				h.writeSSEEvent(w, "error", map[string]string{
-					"message": result.Error.Error(),
-				})
-				return false
-			}
+		if result.Error != nil {
+			// 发送错误事件
+			h.writeSSEEvent(w, style, "error", subscribeErrorEvent("", result))
+			return false
+		}
 
-			if result.IsFinal {
-				// 发送最终进度
-				h.writeSSEEvent(w, "progress", result.Progress)
-				// 发送完成事件
-				h.writeSSEEvent(w, "done", map[string]interface{}{
-					"task_id": taskID,
-					"status":  result.Status,
-				})
-				return false
+		if result.IsFinal {
+			// 发送最终进度
+			h.writeSSEEvent(w, style, "progress", result.Progress)
+			// 发送完成事件
+			done := gin.H{
+				"task_id": taskID,
+				"status":  result.Status,
+			}
+			if result.Result != nil {
+				done["result"] = result.Result
 			}
+			h.writeSSEEvent(w, style, "done", done)
+			return false
+		}
 
-			// 发送进度事件
-			h.writeSSEEvent(w, "progress", result.Progress)
+		// 重试开始时单独发送事件，客户端据此重置进度条
+		if result.Event == progress.EventAttemptStarted {
+			h.writeSSEEvent(w, style, progress.EventAttemptStarted, result.Progress)
 			return true
-
-		case <-ctx.Done():
-			h.logger.Debug("SSE connection closed by client",
-				zap.String("task_id", taskID),
-			)
-			return false
 		}
+
+		// 发送进度事件
+		h.writeSSEEvent(w, style, "progress", result.Progress)
+		return true
 	})
 }
 
+// reserve 为 n 个订阅预留连接。容量不足时返回 503 并带上 Retry-After，而不是让连接一直挂起
+func (h *ProgressHandler) reserve(c *gin.Context, n int) (release func(), ok bool) {
+	reserver, ok := h.subscriber.(SubscriptionReserver)
+	if !ok {
+		return func() {}, true
+	}
+
+	release, err := reserver.Reserve(n)
+	if err == nil {
+		return release, true
+	}
+	if !errors.Is(err, progress.ErrSubscriptionCapacity) {
+		h.logger.Error("failed to reserve subscription", zap.Error(err))
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "failed to subscribe to progress"})
+		return nil, false
+	}
+
+	h.logger.Warn("rejecting SSE connection, subscription capacity exhausted", zap.Int("subscriptions", n))
+	c.Header("Retry-After", strconv.Itoa(int(subscriptionRetryAfter/time.Second)))
+	render.JSON(c, http.StatusServiceUnavailable, gin.H{
+		"error": "too many progress subscriptions, retry later",
+		"code":  progress.CodeSubscriptionCapacity,
+	})
+	return nil, false
+}
+
 // sendHistory 发送历史进度
 func (h *ProgressHandler) sendHistory(c *gin.Context, taskID string) {
 	history, err := h.subscriber.GetHistory(c.Request.Context(), taskID, "-", 0)
@@ -123,16 +249,33 @@ func (h *ProgressHandler) sendHistory(c *gin.Context, taskID string) {
 
 	for _, result := range history {
 		if result.Progress != nil {
-			h.writeSSEEvent(c.Writer, "history", result.Progress)
+			h.writeSSEEvent(c.Writer, render.StyleOf(c), "history", result.Progress)
 		}
 	}
 }
 
+// subscribeErrorEvent 构造订阅错误事件，taskID 为空时不输出
+func subscribeErrorEvent(taskID string, result progress.SubscribeResult) map[string]string {
+	data := map[string]string{"message": result.Error.Error()}
+	if taskID != "" {
+		data["task_id"] = taskID
+	}
+	if result.Code != "" {
+		data["code"] = result.Code
+	}
+	return data
+}
+
 // writeSSEEvent 写入 SSE 事件
-func (h *ProgressHandler) writeSSEEvent(w io.Writer, event string, data interface{}) {
-	jsonData, err := json.Marshal(data)
+func (h *ProgressHandler) writeSSEEvent(w io.Writer, style render.Style, event string, data interface{}) {
+	writeSSE(h.logger, w, style, event, data)
+}
+
+// writeSSE 写入 SSE 事件并立即刷新，data 按 style 改写字段命名，不加信封
+func writeSSE(logger *zap.Logger, w io.Writer, style render.Style, event string, data interface{}) {
+	jsonData, err := render.Fields(style, data)
 	if err != nil {
-		h.logger.Error("failed to marshal SSE data", zap.Error(err))
+		logger.Error("failed to marshal SSE data", zap.Error(err))
 		return
 	}
 
@@ -150,13 +293,13 @@ func (h *ProgressHandler) writeSSEEvent(w io.Writer, event string, data interfac
 func (h *ProgressHandler) GetLatestProgress(c *gin.Context) {
 	taskID := c.Param("id")
 	if taskID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "task_id is required"})
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "task_id is required"})
 		return
 	}
 
 	result, err := h.subscriber.GetLatest(c.Request.Context(), taskID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		render.JSON(c, http.StatusInternalServerError, gin.H{
 			"error": "failed to get progress",
 			"code":  "PROGRESS_FETCH_ERROR",
 		})
@@ -164,19 +307,29 @@ func (h *ProgressHandler) GetLatestProgress(c *gin.Context) {
 	}
 
 	if result == nil || result.Progress == nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		render.JSON(c, http.StatusNotFound, gin.H{
 			"error": "no progress found for this task",
 			"code":  "PROGRESS_NOT_FOUND",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"progress":  result.Progress,
 		"is_final":  result.IsFinal,
 		"status":    result.Status,
 		"stream_id": result.StreamID,
-	})
+	}
+	if result.Result != nil {
+		resp["result"] = result.Result
+	}
+	if result.Event != "" {
+		resp["event"] = result.Event
+	}
+	if len(result.Warnings) > 0 {
+		resp["warnings"] = result.Warnings
+	}
+	render.JSON(c, http.StatusOK, resp)
 }
 
 // GetProgressHistory 获取进度历史
@@ -184,7 +337,7 @@ func (h *ProgressHandler) GetLatestProgress(c *gin.Context) {
 func (h *ProgressHandler) GetProgressHistory(c *gin.Context) {
 	taskID := c.Param("id")
 	if taskID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "task_id is required"})
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "task_id is required"})
 		return
 	}
 
@@ -194,7 +347,7 @@ func (h *ProgressHandler) GetProgressHistory(c *gin.Context) {
 
 	history, err := h.subscriber.GetHistory(c.Request.Context(), taskID, startID, count)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		render.JSON(c, http.StatusInternalServerError, gin.H{
 			"error": "failed to get progress history",
 			"code":  "PROGRESS_HISTORY_ERROR",
 		})
@@ -212,10 +365,19 @@ func (h *ProgressHandler) GetProgressHistory(c *gin.Context) {
 		if result.IsFinal {
 			item["status"] = result.Status
 		}
+		if result.Result != nil {
+			item["result"] = result.Result
+		}
+		if result.Event != "" {
+			item["event"] = result.Event
+		}
+		if len(result.Warnings) > 0 {
+			item["warnings"] = result.Warnings
+		}
 		items = append(items, item)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	render.JSON(c, http.StatusOK, gin.H{
 		"task_id": taskID,
 		"count":   len(items),
 		"history": items,
@@ -227,20 +389,20 @@ func (h *ProgressHandler) GetProgressHistory(c *gin.Context) {
 func (h *ProgressHandler) GetProgressInfo(c *gin.Context) {
 	taskID := c.Param("id")
 	if taskID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "task_id is required"})
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "task_id is required"})
 		return
 	}
 
 	info, err := h.subscriber.GetStreamInfo(c.Request.Context(), taskID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		render.JSON(c, http.StatusInternalServerError, gin.H{
 			"error": "failed to get stream info",
 			"code":  "STREAM_INFO_ERROR",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	render.JSON(c, http.StatusOK, gin.H{
 		"task_id":      taskID,
 		"has_progress": info.HasProgress,
 		"length":       info.Length,
@@ -254,20 +416,26 @@ func (h *ProgressHandler) GetProgressInfo(c *gin.Context) {
 func (h *ProgressHandler) StreamMultipleProgress(c *gin.Context) {
 	taskIDsParam := c.Query("task_ids")
 	if taskIDsParam == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "task_ids is required"})
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "task_ids is required"})
 		return
 	}
 
 	taskIDs := strings.Split(taskIDsParam, ",")
 	if len(taskIDs) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one task_id is required"})
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "at least one task_id is required"})
 		return
 	}
 
 	if len(taskIDs) > 10 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "maximum 10 tasks can be subscribed at once"})
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "maximum 10 tasks can be subscribed at once"})
+		return
+	}
+
+	release, ok := h.reserve(c, len(taskIDs))
+	if !ok {
 		return
 	}
+	defer release()
 
 	h.logger.Info("SSE multi-task connection established",
 		zap.Strings("task_ids", taskIDs),
@@ -278,24 +446,37 @@ func (h *ProgressHandler) StreamMultipleProgress(c *gin.Context) {
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 	c.Header("X-Accel-Buffering", "no")
+	h.writeRetry(c.Writer)
 
-	ctx := c.Request.Context()
+	// 连接结束时停止所有转发 goroutine 并等待其退出，订阅随之 Close
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+	style := render.StyleOf(c)
 
 	// 为每个任务创建订阅
-	type taggedResult struct {
-		TaskID string
-		Result progress.SubscribeResult
-	}
-
 	merged := make(chan taggedResult, len(taskIDs)*10)
 
 	// 启动订阅
 	for _, taskID := range taskIDs {
 		taskID := taskID // 捕获变量
-		ch := h.subscriber.Subscribe(ctx, taskID, "$")
+		sub, err := h.subscriber.SubscribeIter(ctx, taskID, progress.SubscribeOptions{StartID: "$"})
+		if err != nil {
+			// merged 容量足够，不会阻塞
+			merged <- taggedResult{TaskID: taskID, Result: progress.SubscribeResult{Error: err}}
+			continue
+		}
 
+		wg.Add(1)
 		go func() {
-			for result := range ch {
+			defer wg.Done()
+			defer sub.Close()
+			for {
+				result, ok := sub.Next(ctx)
+				if !ok {
+					return
+				}
 				select {
 				case merged <- taggedResult{TaskID: taskID, Result: result}:
 				case <-ctx.Done():
@@ -305,39 +486,98 @@ func (h *ProgressHandler) StreamMultipleProgress(c *gin.Context) {
 		}()
 	}
 
+	// 尚未结束的任务，连接到期时告知客户端需要重新订阅哪些任务
+	pending := make(map[string]bool, len(taskIDs))
+	for _, taskID := range taskIDs {
+		pending[taskID] = true
+	}
 	activeTasks := len(taskIDs)
 
+	expired, stop := h.lifetime()
+	defer stop()
+
+	// 限制推送速率时同一任务来不及推送的更新合并为最新一条，并定期推送 stats 事件
+	pacer := newProgressPacer(h.multiLimits.MaxEventsPerSecond)
+	defer pacer.stop()
+	var statsTick <-chan time.Time
+	if pacer.limited() && h.multiLimits.StatsInterval > 0 {
+		ticker := time.NewTicker(h.multiLimits.StatsInterval)
+		defer ticker.Stop()
+		statsTick = ticker.C
+	}
+
 	c.Stream(func(w io.Writer) bool {
 		select {
 		case tr := <-merged:
 			result := tr.Result
 
 			if result.Error != nil {
-				h.writeSSEEvent(w, "error", map[string]string{
-					"task_id": tr.TaskID,
-					"message": result.Error.Error(),
-				})
+				pacer.discard(tr.TaskID)
+				h.writeSSEEvent(w, style, "error", subscribeErrorEvent(tr.TaskID, result))
+				delete(pending, tr.TaskID)
 				activeTasks--
 				return activeTasks > 0
 			}
 
 			// 发送带有 task_id 的进度
-			eventData := map[string]interface{}{
+			eventData := gin.H{
 				"task_id":  tr.TaskID,
 				"progress": result.Progress,
 			}
 
 			if result.IsFinal {
+				pacer.discard(tr.TaskID)
 				eventData["is_final"] = true
 				eventData["status"] = result.Status
-				h.writeSSEEvent(w, "progress", eventData)
+				if result.Result != nil {
+					eventData["result"] = result.Result
+				}
+				h.writeSSEEvent(w, style, "progress", eventData)
+				delete(pending, tr.TaskID)
 				activeTasks--
 				return activeTasks > 0
 			}
+			if result.Event == progress.EventAttemptStarted {
+				pacer.discard(tr.TaskID)
+				h.writeSSEEvent(w, style, progress.EventAttemptStarted, eventData)
+				return true
+			}
 
-			h.writeSSEEvent(w, "progress", eventData)
+			if pacer.admit(tr, time.Now()) {
+				h.writeSSEEvent(w, style, "progress", eventData)
+			}
 			return true
 
+		case <-pacer.ready():
+			tr := pacer.pop(time.Now())
+			h.writeSSEEvent(w, style, "progress", gin.H{
+				"task_id":  tr.TaskID,
+				"progress": tr.Result.Progress,
+			})
+			return true
+
+		case <-statsTick:
+			if event, ok := pacer.stats(); ok {
+				h.writeSSEEvent(w, style, "stats", event)
+			}
+			return true
+
+		case <-expired:
+			h.logger.Info("SSE multi-task connection reached max lifetime",
+				zap.Strings("task_ids", taskIDs),
+				zap.Duration("max_lifetime", h.maxLifetime),
+			)
+			remaining := make([]string, 0, len(pending))
+			for _, taskID := range taskIDs {
+				if pending[taskID] {
+					remaining = append(remaining, taskID)
+				}
+			}
+			event := h.timeoutEvent()
+			event["task_ids"] = remaining
+			h.writeSSEEvent(w, style, "timeout", event)
+			return false
+
 		case <-ctx.Done():
 			return false
 		}
@@ -0,0 +1,647 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/goleak"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+// SSE 帧格式是客户端依赖的线路协议，这里用 golden 文件固定每个场景的原始响应。
+// 有意修改格式时运行 go test ./internal/interfaces/http/handler -run TestSSEGolden -update 更新
+var update = flag.Bool("update", false, "update SSE golden files")
+
+// scriptStep 向某个任务的订阅 channel 发送一条结果
+type scriptStep struct {
+	taskID string
+	result progress.SubscribeResult
+}
+
+// scriptedSubscriber 按脚本顺序输出订阅结果，不依赖 Redis
+type scriptedSubscriber struct {
+	history map[string][]progress.SubscribeResult
+
+	mu         sync.Mutex
+	streams    map[string]chan progress.SubscribeResult
+	subscribed chan string
+}
+
+func newScriptedSubscriber() *scriptedSubscriber {
+	return &scriptedSubscriber{
+		history:    make(map[string][]progress.SubscribeResult),
+		streams:    make(map[string]chan progress.SubscribeResult),
+		subscribed: make(chan string, 16),
+	}
+}
+
+func (s *scriptedSubscriber) SubscribeIter(ctx context.Context, taskID string, opts progress.SubscribeOptions) (*progress.Subscription, error) {
+	in := make(chan progress.SubscribeResult)
+	s.mu.Lock()
+	s.streams[taskID] = in
+	s.mu.Unlock()
+
+	// 与 progress.Subscriber 一样，cancel 后关闭输出 channel
+	ctx, cancel := context.WithCancel(ctx)
+	out := make(chan progress.SubscribeResult)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case result := <-in:
+				select {
+				case out <- result:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	s.subscribed <- taskID
+	return progress.NewSubscription(out, cancel), nil
+}
+
+func (s *scriptedSubscriber) GetHistory(ctx context.Context, taskID string, startID string, count int64) ([]progress.SubscribeResult, error) {
+	return s.history[taskID], nil
+}
+
+func (s *scriptedSubscriber) GetLatest(ctx context.Context, taskID string) (*progress.SubscribeResult, error) {
+	return nil, nil
+}
+
+func (s *scriptedSubscriber) GetStreamInfo(ctx context.Context, taskID string) (*progress.StreamInfo, error) {
+	return nil, nil
+}
+
+func (s *scriptedSubscriber) stream(taskID string) chan progress.SubscribeResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streams[taskID]
+}
+
+// sseRecorder 可并发读取的 ResponseRecorder，并实现 gin Stream 需要的 CloseNotify
+type sseRecorder struct {
+	*httptest.ResponseRecorder
+	mu sync.Mutex
+}
+
+func newSSERecorder() *sseRecorder {
+	return &sseRecorder{ResponseRecorder: httptest.NewRecorder()}
+}
+
+func (r *sseRecorder) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ResponseRecorder.Write(b)
+}
+
+func (r *sseRecorder) WriteString(s string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ResponseRecorder.WriteString(s)
+}
+
+func (r *sseRecorder) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ResponseRecorder.Flush()
+}
+
+func (r *sseRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func (r *sseRecorder) events() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Count(r.Body.String(), "\n\n")
+}
+
+// render 输出状态码、SSE 相关响应头和原始响应体
+func (r *sseRecorder) render() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "HTTP %d\n", r.Code)
+	for _, h := range []string{"Content-Type", "Cache-Control", "Connection", "X-Accel-Buffering"} {
+		if v := r.Header().Get(h); v != "" {
+			fmt.Fprintf(&b, "%s: %s\n", h, v)
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString(r.Body.String())
+	return b.String()
+}
+
+func progressAt(taskID string, pct int32, stage, message string) *progress.Progress {
+	return &progress.Progress{
+		TaskID:      taskID,
+		Percentage:  pct,
+		Stage:       stage,
+		Message:     message,
+		TimestampMs: 1_700_000_000_000 + int64(pct)*1000,
+	}
+}
+
+func running(taskID string, pct int32) progress.SubscribeResult {
+	return progress.SubscribeResult{Progress: progressAt(taskID, pct, "running", fmt.Sprintf("step %d", pct))}
+}
+
+func final(taskID, status string, result json.RawMessage) progress.SubscribeResult {
+	return progress.SubscribeResult{
+		Progress: progressAt(taskID, 100, status, "finished"),
+		IsFinal:  true,
+		Status:   status,
+		Result:   result,
+	}
+}
+
+// attempt 为结果标记执行次数
+func attempt(result progress.SubscribeResult, n int32) progress.SubscribeResult {
+	result.Progress.Attempt, result.Progress.MaxAttempts = n, 3
+	return result
+}
+
+func attemptStarted(taskID string, n int32) progress.SubscribeResult {
+	prog := progressAt(taskID, 0, progress.EventAttemptStarted, fmt.Sprintf("attempt %d of 3 started", n))
+	prog.Attempt, prog.MaxAttempts = n, 3
+	return progress.SubscribeResult{Progress: prog, Event: progress.EventAttemptStarted}
+}
+
+func TestSSEGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		tasks   int // 需要等待建立的订阅数
+		history map[string][]progress.SubscribeResult
+		steps   []scriptStep
+		// serial 多任务流：每步写出后再发送下一步，保证事件顺序确定
+		serial bool
+		accept string
+	}{
+		{
+			name: "stream_history_live",
+			url:  "/api/v1/tasks/t1/progress/stream?history=true",
+			history: map[string][]progress.SubscribeResult{
+				"t1": {running("t1", 10), running("t1", 40)},
+			},
+			tasks: 1,
+			steps: []scriptStep{
+				{"t1", running("t1", 60)},
+				{"t1", final("t1", "completed", json.RawMessage(`{"rows":3}`))},
+			},
+		},
+		{
+			name:  "stream_retry",
+			url:   "/api/v1/tasks/t1/progress/stream",
+			tasks: 1,
+			steps: []scriptStep{
+				{"t1", attempt(running("t1", 80), 1)},
+				{"t1", attemptStarted("t1", 2)},
+				{"t1", attempt(running("t1", 5), 2)},
+				{"t1", attempt(final("t1", "completed", nil), 2)},
+			},
+		},
+		{
+			name:   "multi_retry",
+			url:    "/api/v1/progress/stream?task_ids=a,b",
+			tasks:  2,
+			serial: true,
+			steps: []scriptStep{
+				{"a", attemptStarted("a", 2)},
+				{"a", attempt(final("a", "completed", nil), 2)},
+				{"b", final("b", "completed", nil)},
+			},
+		},
+		{
+			name:   "stream_camel",
+			url:    "/api/v1/tasks/t1/progress/stream",
+			accept: `text/event-stream; profile="camel envelope"`,
+			tasks:  1,
+			steps: []scriptStep{
+				{"t1", attempt(running("t1", 40), 1)},
+				{"t1", final("t1", "completed", json.RawMessage(`{"row_count":3}`))},
+			},
+		},
+		{
+			name:  "stream_final",
+			url:   "/api/v1/tasks/t1/progress/stream",
+			tasks: 1,
+			steps: []scriptStep{
+				{"t1", final("t1", "failed", nil)},
+			},
+		},
+		{
+			name:  "stream_error",
+			url:   "/api/v1/tasks/t1/progress/stream",
+			tasks: 1,
+			steps: []scriptStep{
+				{"t1", running("t1", 20)},
+				{"t1", progress.SubscribeResult{Error: progress.ErrTaskGone, Code: progress.CodeTaskGone}},
+			},
+		},
+		{
+			name:   "multi_tagged",
+			url:    "/api/v1/progress/stream?task_ids=a,b",
+			tasks:  2,
+			serial: true,
+			steps: []scriptStep{
+				{"a", running("a", 10)},
+				{"b", running("b", 20)},
+				{"a", final("a", "completed", json.RawMessage(`{"ok":true}`))},
+				{"b", final("b", "failed", nil)},
+			},
+		},
+		{
+			name:   "multi_error",
+			url:    "/api/v1/progress/stream?task_ids=a,b",
+			tasks:  2,
+			serial: true,
+			steps: []scriptStep{
+				{"a", progress.SubscribeResult{Error: errors.New("no progress for task a"), Code: "TASK_OR_PROGRESS_NOT_FOUND"}},
+				{"b", final("b", "completed", nil)},
+			},
+		},
+		{
+			name: "multi_too_many_tasks",
+			url:  "/api/v1/progress/stream?task_ids=1,2,3,4,5,6,7,8,9,10,11",
+		},
+		{
+			name: "multi_missing_task_ids",
+			url:  "/api/v1/progress/stream",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := newScriptedSubscriber()
+			if tt.history != nil {
+				sub.history = tt.history
+			}
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(render.Negotiate(render.Style{Casing: render.CasingSnake}))
+			h := NewProgressHandler(sub, zap.NewNop(), 0)
+			r.GET("/api/v1/tasks/:id/progress/stream", h.StreamProgress)
+			r.GET("/api/v1/progress/stream", h.StreamMultipleProgress)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := newSSERecorder()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				r.ServeHTTP(rec, req)
+			}()
+
+			for range tt.tasks {
+				select {
+				case <-sub.subscribed:
+				case <-time.After(5 * time.Second):
+					t.Fatal("timed out waiting for subscriptions")
+				}
+			}
+			base := rec.events()
+			for i, step := range tt.steps {
+				sub.stream(step.taskID) <- step.result
+				if tt.serial {
+					waitEvents(t, rec, base+i+1)
+				}
+			}
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the stream to end")
+			}
+
+			compareGolden(t, tt.name, rec.render())
+		})
+	}
+}
+
+func waitEvents(t *testing.T, rec *sseRecorder, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for rec.events() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d events, got %d", n, rec.events())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func compareGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "sse", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Fatalf("SSE output differs from %s; if the change is intentional, rerun with -update\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
+
+// fullSubscriber 订阅容量已用尽的 subscriber
+type fullSubscriber struct {
+	*scriptedSubscriber
+}
+
+func (fullSubscriber) Reserve(int) (func(), error) {
+	return nil, progress.ErrSubscriptionCapacity
+}
+
+func TestStreamRejectsWhenSubscriptionCapacityExhausted(t *testing.T) {
+	for _, url := range []string{
+		"/api/v1/tasks/t1/progress/stream?history=true",
+		"/api/v1/progress/stream?task_ids=a,b",
+	} {
+		t.Run(url, func(t *testing.T) {
+			sub := fullSubscriber{newScriptedSubscriber()}
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			h := NewProgressHandler(sub, zap.NewNop(), 0)
+			r.GET("/api/v1/tasks/:id/progress/stream", h.StreamProgress)
+			r.GET("/api/v1/progress/stream", h.StreamMultipleProgress)
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Fatalf("expected 503, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("Retry-After"); got != "5" {
+				t.Fatalf("expected Retry-After 5, got %q", got)
+			}
+			if ct := rec.Header().Get("Content-Type"); strings.HasPrefix(ct, "text/event-stream") {
+				t.Fatalf("expected a JSON error, got content type %q", ct)
+			}
+			if !strings.Contains(rec.Body.String(), progress.CodeSubscriptionCapacity) {
+				t.Fatalf("expected capacity error code, got %s", rec.Body.String())
+			}
+			if len(sub.subscribed) != 0 {
+				t.Fatalf("expected no subscriptions to be opened")
+			}
+		})
+	}
+}
+
+func TestStreamEndsWithTimeoutAfterMaxLifetime(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		tasks int
+		steps []scriptStep
+		want  []string
+	}{
+		{
+			name:  "single",
+			url:   "/api/v1/tasks/t1/progress/stream",
+			tasks: 1,
+			steps: []scriptStep{
+				{"t1", progress.SubscribeResult{Progress: progressAt("t1", 10, "running", "step"), StreamID: "1-0"}},
+			},
+			want: []string{"event: timeout", `"last_stream_id":"1-0"`, `"reason":"max_lifetime"`, `"task_id":"t1"`},
+		},
+		{
+			name:  "multi",
+			url:   "/api/v1/progress/stream?task_ids=a,b",
+			tasks: 2,
+			steps: []scriptStep{
+				{"a", final("a", "completed", nil)},
+			},
+			want: []string{"event: timeout", `"task_ids":["b"]`, `"reason":"max_lifetime"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := newScriptedSubscriber()
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			h := NewProgressHandler(sub, zap.NewNop(), 200*time.Millisecond)
+			r.GET("/api/v1/tasks/:id/progress/stream", h.StreamProgress)
+			r.GET("/api/v1/progress/stream", h.StreamMultipleProgress)
+
+			rec := newSSERecorder()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			}()
+
+			for range tt.tasks {
+				<-sub.subscribed
+			}
+			for i, step := range tt.steps {
+				sub.stream(step.taskID) <- step.result
+				waitEvents(t, rec, i+1)
+			}
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected the stream to end after its max lifetime")
+			}
+
+			body := rec.render()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Fatalf("expected %s in response:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+// loadedSubscriber 报告固定订阅容量占用的 subscriber
+type loadedSubscriber struct {
+	*scriptedSubscriber
+	reserved, capacity int
+}
+
+func (s loadedSubscriber) ReservedSubscriptions() int { return s.reserved }
+func (s loadedSubscriber) SubscriptionCapacity() int  { return s.capacity }
+
+func TestStreamSendsReconnectHint(t *testing.T) {
+	hint := ReconnectHint{Base: 2 * time.Second, Max: 10 * time.Second}
+	tests := []struct {
+		name string
+		sub  ProgressSubscriber
+		hint ReconnectHint
+		want string
+	}{
+		{"unlimited pool", newScriptedSubscriber(), hint, "retry: 2000\n\n"},
+		{"half loaded", loadedSubscriber{newScriptedSubscriber(), 2, 4}, hint, "retry: 6000\n\n"},
+		{"over capacity", loadedSubscriber{newScriptedSubscriber(), 9, 4}, hint, "retry: 10000\n\n"},
+		{"disabled", newScriptedSubscriber(), ReconnectHint{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			h := NewProgressHandler(tt.sub, zap.NewNop(), 50*time.Millisecond, WithReconnectHint(tt.hint))
+			r.GET("/api/v1/tasks/:id/progress/stream", h.StreamProgress)
+
+			rec := newSSERecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tasks/t1/progress/stream", nil))
+
+			body := rec.Body.String()
+			if tt.want == "" {
+				if strings.Contains(body, "retry:") {
+					t.Fatalf("expected no retry field, got:\n%s", body)
+				}
+				return
+			}
+			if !strings.HasPrefix(body, tt.want) {
+				t.Fatalf("expected stream to start with %q, got:\n%s", tt.want, body)
+			}
+		})
+	}
+}
+
+func TestStreamReleasesSubscriptionsOnDisconnect(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		tasks int
+	}{
+		{name: "single", url: "/api/v1/tasks/t1/progress/stream", tasks: 1},
+		{name: "multi", url: "/api/v1/progress/stream?task_ids=a,b,c", tasks: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ignore := goleak.IgnoreCurrent()
+			sub := newScriptedSubscriber()
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			h := NewProgressHandler(sub, zap.NewNop(), 0)
+			r.GET("/api/v1/tasks/:id/progress/stream", h.StreamProgress)
+			r.GET("/api/v1/progress/stream", h.StreamMultipleProgress)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			rec := newSSERecorder()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil).WithContext(ctx))
+			}()
+
+			for range tt.tasks {
+				<-sub.subscribed
+			}
+			cancel()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected the stream to end after the client disconnected")
+			}
+			goleak.VerifyNone(t, ignore)
+		})
+	}
+}
+
+func TestStreamMultipleProgressCoalescesUpdates(t *testing.T) {
+	sub := newScriptedSubscriber()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := NewProgressHandler(sub, zap.NewNop(), 0, WithMultiStreamLimits(MultiStreamLimits{
+		MaxEventsPerSecond: 2,
+		StatsInterval:      50 * time.Millisecond,
+	}))
+	r.GET("/api/v1/progress/stream", h.StreamMultipleProgress)
+
+	rec := newSSERecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/progress/stream?task_ids=a", nil))
+	}()
+	<-sub.subscribed
+
+	// 连接进行中只读响应体，响应头由处理器写入
+	body := func() string {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return rec.Body.String()
+	}
+	waitBody := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(body(), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s in:\n%s", want, body())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// 第一条立即推送，间隔内的后续更新只推送最新一条
+	sub.stream("a") <- running("a", 10)
+	waitBody(`"percentage":10`)
+	for _, pct := range []int32{20, 30, 40} {
+		sub.stream("a") <- running("a", pct)
+	}
+	waitBody(`"percentage":40`)
+	waitBody(`"coalesced_total":2`)
+
+	// 完成事件立即推送并取代尚未推送的进度
+	sub.stream("a") <- running("a", 50)
+	sub.stream("a") <- final("a", "completed", nil)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the stream to end")
+	}
+
+	got := rec.render()
+	for _, unwanted := range []string{`"percentage":20`, `"percentage":30`, `"percentage":50`} {
+		if strings.Contains(got, unwanted) {
+			t.Fatalf("expected %s to be coalesced away:\n%s", unwanted, got)
+		}
+	}
+	if !strings.Contains(got, "event: stats") || !strings.Contains(got, `"is_final":true`) {
+		t.Fatalf("expected stats and final events:\n%s", got)
+	}
+}
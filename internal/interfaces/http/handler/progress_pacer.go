@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+// MultiStreamLimits 多任务进度 SSE 每个连接的推送限制
+type MultiStreamLimits struct {
+	// MaxEventsPerSecond 每秒最多推送的进度事件数，超出时同一任务只保留最新一条等待推送，0 表示不限制。
+	// 完成、错误和重试开始事件不受限制，立即推送
+	MaxEventsPerSecond float64
+	// StatsInterval 推送 stats 事件（合并和推送数量）的间隔，0 表示不推送；不限制速率时不推送
+	StatsInterval time.Duration
+}
+
+// ProgressHandlerOption ProgressHandler 的可选配置
+type ProgressHandlerOption func(*ProgressHandler)
+
+// WithMultiStreamLimits 设置多任务进度 SSE 的推送限制
+func WithMultiStreamLimits(limits MultiStreamLimits) ProgressHandlerOption {
+	return func(h *ProgressHandler) {
+		h.multiLimits = limits
+	}
+}
+
+// taggedResult 带任务 ID 的订阅结果
+type taggedResult struct {
+	TaskID string
+	Result progress.SubscribeResult
+}
+
+// progressPacer 按最小间隔推送进度事件，来不及推送的同一任务更新合并为最新一条
+type progressPacer struct {
+	interval time.Duration
+	next     time.Time
+
+	pending map[string]taggedResult
+	order   []string
+	timer   *time.Timer
+
+	// 自上次 stats 事件以来的数量
+	coalesced int
+	delivered int
+	// 连接建立以来合并的总数
+	coalescedTotal int
+}
+
+func newProgressPacer(maxPerSecond float64) *progressPacer {
+	p := &progressPacer{pending: make(map[string]taggedResult)}
+	if maxPerSecond > 0 {
+		p.interval = time.Duration(float64(time.Second) / maxPerSecond)
+	}
+	return p
+}
+
+// limited 是否限制推送速率
+func (p *progressPacer) limited() bool {
+	return p.interval > 0
+}
+
+// admit 返回 tr 是否可以立即推送，否则将其放入待推送队列，替换同一任务尚未推送的更新
+func (p *progressPacer) admit(tr taggedResult, now time.Time) bool {
+	if !p.limited() {
+		p.delivered++
+		return true
+	}
+	if _, ok := p.pending[tr.TaskID]; ok {
+		p.pending[tr.TaskID] = tr
+		p.coalesced++
+		p.coalescedTotal++
+		return false
+	}
+	if len(p.pending) == 0 && !now.Before(p.next) {
+		p.next = now.Add(p.interval)
+		p.delivered++
+		return true
+	}
+
+	p.pending[tr.TaskID] = tr
+	p.order = append(p.order, tr.TaskID)
+	if len(p.pending) == 1 {
+		p.arm(p.next.Sub(now))
+	}
+	return false
+}
+
+// discard 丢弃任务尚未推送的更新，用于完成事件等立即推送的事件取代之前的进度
+func (p *progressPacer) discard(taskID string) {
+	if _, ok := p.pending[taskID]; !ok {
+		return
+	}
+	delete(p.pending, taskID)
+	for i, id := range p.order {
+		if id == taskID {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+	p.coalesced++
+	p.coalescedTotal++
+	if len(p.pending) == 0 && p.timer != nil {
+		p.timer.Stop()
+	}
+}
+
+// ready 有待推送的更新时返回到期的 channel，否则返回 nil
+func (p *progressPacer) ready() <-chan time.Time {
+	if len(p.pending) == 0 || p.timer == nil {
+		return nil
+	}
+	return p.timer.C
+}
+
+// pop 取出等待最久的任务的最新更新
+func (p *progressPacer) pop(now time.Time) taggedResult {
+	taskID := p.order[0]
+	p.order = p.order[1:]
+	tr := p.pending[taskID]
+	delete(p.pending, taskID)
+
+	p.next = now.Add(p.interval)
+	p.delivered++
+	if len(p.pending) > 0 {
+		p.arm(p.interval)
+	}
+	return tr
+}
+
+func (p *progressPacer) arm(d time.Duration) {
+	if p.timer == nil {
+		p.timer = time.NewTimer(d)
+		return
+	}
+	p.timer.Reset(d)
+}
+
+// stats 返回自上次调用以来的数量并清零，没有任何推送和合并时返回 false
+func (p *progressPacer) stats() (gin.H, bool) {
+	if p.coalesced == 0 && p.delivered == 0 {
+		return nil, false
+	}
+	event := gin.H{
+		"coalesced":       p.coalesced,
+		"delivered":       p.delivered,
+		"pending":         len(p.pending),
+		"coalesced_total": p.coalescedTotal,
+	}
+	p.coalesced = 0
+	p.delivered = 0
+	return event, true
+}
+
+func (p *progressPacer) stop() {
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+}
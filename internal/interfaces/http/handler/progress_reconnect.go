@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SubscriptionUsage 报告订阅容量的使用情况，由 progress.Subscriber 实现
+type SubscriptionUsage interface {
+	ReservedSubscriptions() int
+	SubscriptionCapacity() int
+}
+
+// ReconnectHint SSE 连接开始时通过 retry: 字段建议的重连间隔。
+// 订阅容量有上限时，间隔随容量占用从 Base 线性增加到 Max，让客户端在高负载时放慢重连
+type ReconnectHint struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// WithReconnectHint 设置进度 SSE 的重连间隔建议，Base 为 0 时不发送 retry: 字段
+func WithReconnectHint(hint ReconnectHint) ProgressHandlerOption {
+	return func(h *ProgressHandler) {
+		h.reconnect = hint
+	}
+}
+
+// retryInterval 按当前订阅容量占用计算建议的重连间隔
+func (h *ProgressHandler) retryInterval() time.Duration {
+	hint := h.reconnect
+	usage, ok := h.subscriber.(SubscriptionUsage)
+	if !ok || hint.Max <= hint.Base {
+		return hint.Base
+	}
+	capacity := usage.SubscriptionCapacity()
+	if capacity <= 0 {
+		return hint.Base
+	}
+	load := min(float64(usage.ReservedSubscriptions())/float64(capacity), 1)
+	return hint.Base + time.Duration(load*float64(hint.Max-hint.Base))
+}
+
+// writeRetry 在连接开始时写入 retry: 字段。只有该字段的消息不会触发客户端事件
+func (h *ProgressHandler) writeRetry(w io.Writer) {
+	if h.reconnect.Base <= 0 {
+		return
+	}
+	fmt.Fprintf(w, "retry: %d\n\n", h.retryInterval().Milliseconds())
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
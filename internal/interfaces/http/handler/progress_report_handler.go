@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/progresstoken"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/dto"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+// ProgressReportPublisher 发布上报的进度，由 progress.Publisher 实现
+type ProgressReportPublisher interface {
+	Publish(ctx context.Context, prog *progress.Progress) error
+}
+
+// TokenRevocations 记录已吊销的令牌，由 progresstoken.Revocations 实现
+type TokenRevocations interface {
+	RevokeToken(ctx context.Context, token string, expiresAt, now time.Time) error
+	Revoked(ctx context.Context, taskID, token string) (bool, error)
+}
+
+// ProgressReportHandler 接收无法提供 gRPC 服务的下游执行方通过 HTTP 上报的进度，
+// 请求凭 worker 签发的任务级令牌认证，发布路径与 worker 相同，SSE 和历史记录不受影响
+type ProgressReportHandler struct {
+	signer      *progresstoken.Signer
+	revocations TokenRevocations
+	publisher   ProgressReportPublisher
+	logger      *zap.Logger
+	clock       clock.Clock
+}
+
+// NewProgressReportHandler clk 用于校验令牌有效期，为 nil 时使用真实时钟
+func NewProgressReportHandler(signer *progresstoken.Signer, revocations TokenRevocations, publisher ProgressReportPublisher, logger *zap.Logger, clk clock.Clock) *ProgressReportHandler {
+	return &ProgressReportHandler{
+		signer:      signer,
+		revocations: revocations,
+		publisher:   publisher,
+		logger:      logger,
+		clock:       clock.OrReal(clk),
+	}
+}
+
+// Report 发布一条进度
+func (h *ProgressReportHandler) Report(c *gin.Context) {
+	taskID := c.Param("id")
+	if _, ok := h.authorize(c, taskID); !ok {
+		return
+	}
+
+	var req dto.ReportProgressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeBindError(c, err)
+		return
+	}
+	if req.Percentage < 0 || req.Percentage > 100 {
+		render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+			Error: "percentage must be between 0 and 100",
+			Code:  "INVALID_PROGRESS",
+		})
+		return
+	}
+
+	prog := progress.NewProgress(taskID, req.Percentage, req.Stage, req.Message)
+	prog.Metadata = req.Metadata
+	if err := h.publisher.Publish(c.Request.Context(), prog); err != nil {
+		h.writeFailure(c, taskID, err)
+		return
+	}
+	render.JSON(c, http.StatusAccepted, dto.ProgressReportResponse{TaskID: taskID})
+}
+
+// Complete 发布一条 100% 的进度并吊销本次执行的令牌，之后用该令牌上报返回 PROGRESS_TOKEN_REVOKED。
+// 进度流的完成事件仍由 worker 根据 gRPC 调用结果发布，这里不发布第二个完成事件；
+// 重试时 worker 签发的新令牌不受影响
+func (h *ProgressReportHandler) Complete(c *gin.Context) {
+	taskID := c.Param("id")
+	claims, ok := h.authorize(c, taskID)
+	if !ok {
+		return
+	}
+
+	var req dto.ReportCompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeBindError(c, err)
+		return
+	}
+	if req.Status != "completed" && req.Status != "failed" {
+		render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+			Error: "status must be completed or failed",
+			Code:  "INVALID_PROGRESS",
+		})
+		return
+	}
+	message := req.Message
+	if message == "" {
+		message = "task " + req.Status
+	}
+
+	prog := progress.NewProgress(taskID, 100, req.Status, message)
+	prog.Metadata = req.Metadata
+	// 结果放在 metadata_json 中，超出大小限制时由 Publish 丢弃
+	prog.MetadataJSON = req.Result
+	ctx := c.Request.Context()
+	if err := h.publisher.Publish(ctx, prog); err != nil {
+		h.writeFailure(c, taskID, err)
+		return
+	}
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+	if err := h.revocations.RevokeToken(ctx, bearerToken(c), expiresAt, h.clock.Now()); err != nil {
+		h.logger.Warn("failed to revoke progress token after completion",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+	}
+	render.JSON(c, http.StatusAccepted, dto.ProgressReportResponse{TaskID: taskID, Final: true})
+}
+
+// authorize 校验 Authorization: Bearer <令牌>，失败时写入响应
+func (h *ProgressReportHandler) authorize(c *gin.Context, taskID string) (progresstoken.Claims, bool) {
+	token := bearerToken(c)
+	if token == "" {
+		render.JSON(c, http.StatusUnauthorized, dto.ErrorResponse{
+			Error: "progress token required in Authorization header",
+			Code:  "INVALID_PROGRESS_TOKEN",
+		})
+		return progresstoken.Claims{}, false
+	}
+
+	claims, err := h.signer.Verify(token, taskID, h.clock.Now())
+	if err != nil {
+		code := "INVALID_PROGRESS_TOKEN"
+		if errors.Is(err, progresstoken.ErrExpired) {
+			code = "PROGRESS_TOKEN_EXPIRED"
+		}
+		render.JSON(c, http.StatusUnauthorized, dto.ErrorResponse{Error: err.Error(), Code: code})
+		return progresstoken.Claims{}, false
+	}
+
+	revoked, err := h.revocations.Revoked(c.Request.Context(), taskID, token)
+	if err != nil {
+		h.writeFailure(c, taskID, err)
+		return progresstoken.Claims{}, false
+	}
+	if revoked {
+		render.JSON(c, http.StatusUnauthorized, dto.ErrorResponse{
+			Error: progresstoken.ErrRevoked.Error(),
+			Code:  "PROGRESS_TOKEN_REVOKED",
+		})
+		return progresstoken.Claims{}, false
+	}
+	return claims, true
+}
+
+func bearerToken(c *gin.Context) string {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+func (h *ProgressReportHandler) writeFailure(c *gin.Context, taskID string, err error) {
+	h.logger.Error("failed to handle progress report",
+		zap.String("task_id", taskID),
+		zap.Error(err),
+	)
+	render.JSON(c, http.StatusInternalServerError, dto.ErrorResponse{
+		Error: err.Error(),
+		Code:  "PROGRESS_REPORT_FAILED",
+	})
+}
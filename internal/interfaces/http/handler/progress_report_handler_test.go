@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/progresstoken"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+type fakeReportPublisher struct {
+	published []*progress.Progress
+}
+
+func (f *fakeReportPublisher) Publish(ctx context.Context, prog *progress.Progress) error {
+	f.published = append(f.published, prog)
+	return nil
+}
+
+type fakeRevocations struct {
+	revoked map[string]bool
+}
+
+func (f *fakeRevocations) RevokeToken(ctx context.Context, token string, expiresAt, now time.Time) error {
+	f.revoked[token] = true
+	return nil
+}
+
+func (f *fakeRevocations) Revoked(ctx context.Context, taskID, token string) (bool, error) {
+	return f.revoked[taskID] || f.revoked[token], nil
+}
+
+func TestProgressReportHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	signer := progresstoken.NewSigner("secret", time.Hour)
+	publisher := &fakeReportPublisher{}
+	revocations := &fakeRevocations{revoked: map[string]bool{}}
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	h := NewProgressReportHandler(signer, revocations, publisher, zap.NewNop(), clk)
+
+	r := gin.New()
+	r.POST("/api/v1/tasks/:id/progress", h.Report)
+	r.POST("/api/v1/tasks/:id/complete", h.Complete)
+
+	post := func(path, token, body string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		var errBody struct {
+			Code string `json:"code"`
+		}
+		_ = json.Unmarshal(resp.Body.Bytes(), &errBody)
+		return resp.Code, errBody.Code
+	}
+
+	now := clk.Now()
+	token := signer.Sign("task-1", "default", 1, now, now.Add(time.Minute))
+
+	if status, _ := post("/api/v1/tasks/task-1/progress", token, `{"percentage":40,"stage":"render"}`); status != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", status)
+	}
+	if len(publisher.published) != 1 || publisher.published[0].TaskID != "task-1" || publisher.published[0].Percentage != 40 {
+		t.Fatalf("unexpected published progress %+v", publisher.published)
+	}
+
+	if status, code := post("/api/v1/tasks/task-2/progress", token, `{"percentage":40}`); status != http.StatusUnauthorized || code != "INVALID_PROGRESS_TOKEN" {
+		t.Fatalf("expected token for another task to be rejected, got %d %s", status, code)
+	}
+	if status, code := post("/api/v1/tasks/task-1/progress", "", `{"percentage":40}`); status != http.StatusUnauthorized || code != "INVALID_PROGRESS_TOKEN" {
+		t.Fatalf("expected missing token to be rejected, got %d %s", status, code)
+	}
+	if status, code := post("/api/v1/tasks/task-1/progress", token, `{"percentage":140}`); status != http.StatusBadRequest || code != "INVALID_PROGRESS" {
+		t.Fatalf("expected invalid percentage to be rejected, got %d %s", status, code)
+	}
+	// 有效期按注入的时钟判断
+	short := signer.Sign("task-1", "default", 1, now, now.Add(10*time.Second))
+	clk.Advance(10 * time.Second)
+	if status, code := post("/api/v1/tasks/task-1/progress", short, `{"percentage":40}`); status != http.StatusUnauthorized || code != "PROGRESS_TOKEN_EXPIRED" {
+		t.Fatalf("expected expired token to be rejected, got %d %s", status, code)
+	}
+
+	if status, _ := post("/api/v1/tasks/task-1/complete", token, `{"status":"completed","result":{"rows":3}}`); status != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", status)
+	}
+	// 只发布普通进度，完成事件由 worker 发布
+	last := publisher.published[len(publisher.published)-1]
+	if len(publisher.published) != 2 || last.Percentage != 100 || last.Stage != "completed" || string(last.MetadataJSON) != `{"rows":3}` {
+		t.Fatalf("unexpected completion progress %+v", last)
+	}
+
+	// 完成后本次执行的令牌被吊销，重试签发的新令牌仍可上报
+	if status, code := post("/api/v1/tasks/task-1/progress", token, `{"percentage":50}`); status != http.StatusUnauthorized || code != "PROGRESS_TOKEN_REVOKED" {
+		t.Fatalf("expected revoked token to be rejected, got %d %s", status, code)
+	}
+	retry := signer.Sign("task-1", "default", 2, now, now.Add(time.Minute))
+	if status, _ := post("/api/v1/tasks/task-1/progress", retry, `{"percentage":10}`); status != http.StatusAccepted {
+		t.Fatalf("expected retry token to be accepted, got %d", status)
+	}
+}
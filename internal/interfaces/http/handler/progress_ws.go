@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+// WebSocket 关闭码，4000 起为应用自定义
+const (
+	// WSCloseCompleted 任务成功完成
+	WSCloseCompleted = 1000
+	// WSCloseSubscribeError 订阅出错，原因见之前的 error 帧
+	WSCloseSubscribeError = 1011
+	// WSCloseTaskFailed 任务以 completed 以外的最终状态结束（失败、取消、超时），状态见之前的 done 帧
+	WSCloseTaskFailed = 4000
+	// WSCloseMaxLifetime 连接达到最长持续时间，客户端可从 timeout 帧的 last_stream_id 续订
+	WSCloseMaxLifetime = 4001
+)
+
+// wsWriteTimeout 单个帧的写入超时，客户端不再读取时不让连接一直阻塞
+const wsWriteTimeout = 10 * time.Second
+
+// StreamProgressWS 通过 WebSocket 推送任务进度，start_id 和 history 参数与 StreamProgress 相同。
+// 每个事件是一个 JSON 文本帧，event 字段与 SSE 的事件名一致；任务结束后按最终状态发送关闭帧
+// GET /api/v1/tasks/:id/progress/ws
+func (h *ProgressHandler) StreamProgressWS(c *gin.Context) {
+	taskID := c.Param("id")
+	if taskID == "" {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "task_id is required"})
+		return
+	}
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		render.JSON(c, http.StatusUpgradeRequired, gin.H{
+			"error": "websocket upgrade required",
+			"code":  "WEBSOCKET_UPGRADE_REQUIRED",
+		})
+		return
+	}
+
+	// 浏览器会为跨站页面自动携带 cookie 和代理注入的凭据，只接受同源或配置允许的来源
+	if !h.originAllowed(c.Request) {
+		render.JSON(c, http.StatusForbidden, gin.H{
+			"error": "websocket origin not allowed",
+			"code":  "WEBSOCKET_ORIGIN_NOT_ALLOWED",
+		})
+		return
+	}
+
+	startID := c.Query("start_id")
+	if startID == "" {
+		startID = "$"
+	}
+	includeHistory := c.Query("history") == "true"
+
+	release, ok := h.reserve(c, 1)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// 升级后 net/http 不再感知连接断开，由读取协程在客户端断开时取消
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	sub, err := h.subscriber.SubscribeIter(ctx, taskID, progress.SubscribeOptions{StartID: startID})
+	if err != nil {
+		h.logger.Error("failed to subscribe to progress", zap.String("task_id", taskID), zap.Error(err))
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "failed to subscribe to progress"})
+		return
+	}
+	defer sub.Close()
+
+	style := render.StyleOf(c)
+	server := websocket.Server{
+		// Origin 已由 originAllowed 校验，websocket 库默认的校验会拒绝不发送 Origin 的非浏览器客户端
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			h.logger.Info("WebSocket connection established",
+				zap.String("task_id", taskID),
+				zap.String("start_id", startID),
+				zap.Bool("include_history", includeHistory),
+			)
+			go h.discardWS(ws, cancel)
+			if includeHistory {
+				h.sendHistoryWS(ctx, ws, style, taskID)
+			}
+			h.streamWS(ctx, ws, style, sub, taskID, startID)
+		},
+	}
+	// 处理函数返回后关闭底层连接，读取协程随之退出
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// WithAllowedOrigins 设置允许建立 WebSocket 连接的浏览器页面来源（scheme://host[:port]），"*" 允许任意来源
+func WithAllowedOrigins(origins []string) ProgressHandlerOption {
+	return func(h *ProgressHandler) {
+		h.origins = origins
+	}
+}
+
+// originAllowed 没有 Origin（非浏览器客户端）、与请求同源或在允许列表中的来源可以建立连接
+func (h *ProgressHandler) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return slices.ContainsFunc(h.origins, func(allowed string) bool {
+		return allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin)
+	})
+}
+
+// discardWS 读取并丢弃客户端发来的帧（ping 由 websocket 库应答），读取失败或收到关闭帧时取消订阅
+func (h *ProgressHandler) discardWS(ws *websocket.Conn, cancel context.CancelFunc) {
+	defer cancel()
+	var msg []byte
+	for {
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			return
+		}
+	}
+}
+
+// sendHistoryWS 发送历史进度
+func (h *ProgressHandler) sendHistoryWS(ctx context.Context, ws *websocket.Conn, style render.Style, taskID string) {
+	history, err := h.subscriber.GetHistory(ctx, taskID, "-", 0)
+	if err != nil {
+		h.logger.Warn("failed to get history",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+		return
+	}
+	for _, result := range history {
+		if result.Progress == nil {
+			continue
+		}
+		if err := h.writeWS(ws, style, resultFrame("history", result)); err != nil {
+			return
+		}
+	}
+}
+
+// streamWS 转发订阅结果，直到任务结束、订阅出错、连接到期或客户端断开
+func (h *ProgressHandler) streamWS(ctx context.Context, ws *websocket.Conn, style render.Style, sub *progress.Subscription, taskID, startID string) {
+	readCtx, stop := h.lifetimeContext(ctx)
+	defer stop()
+	lastID := startID
+	if lastID == "$" {
+		lastID = ""
+	}
+
+	for {
+		result, ok := sub.Next(readCtx)
+		if !ok {
+			switch {
+			case ctx.Err() != nil:
+				h.logger.Debug("WebSocket connection closed by client",
+					zap.String("task_id", taskID),
+				)
+			case readCtx.Err() != nil:
+				h.logger.Info("WebSocket connection reached max lifetime",
+					zap.String("task_id", taskID),
+					zap.Duration("max_lifetime", h.maxLifetime),
+				)
+				frame := gin.H{"event": "timeout", "task_id": taskID}
+				for k, v := range h.timeoutEvent() {
+					frame[k] = v
+				}
+				if lastID != "" {
+					frame["last_stream_id"] = lastID
+				}
+				if h.writeWS(ws, style, frame) == nil {
+					h.closeWS(ws, taskID, WSCloseMaxLifetime)
+				}
+			default:
+				h.closeWS(ws, taskID, WSCloseSubscribeError)
+			}
+			return
+		}
+		if result.StreamID != "" {
+			lastID = result.StreamID
+		}
+
+		if result.Error != nil {
+			frame := gin.H{"event": "error"}
+			for k, v := range subscribeErrorEvent(taskID, result) {
+				frame[k] = v
+			}
+			if h.writeWS(ws, style, frame) == nil {
+				h.closeWS(ws, taskID, WSCloseSubscribeError)
+			}
+			return
+		}
+
+		if result.IsFinal {
+			if h.writeWS(ws, style, resultFrame("done", result)) == nil {
+				code := WSCloseTaskFailed
+				if result.Status == "completed" {
+					code = WSCloseCompleted
+				}
+				h.closeWS(ws, taskID, code)
+			}
+			return
+		}
+
+		event := "progress"
+		if result.Event == progress.EventAttemptStarted {
+			event = progress.EventAttemptStarted
+		}
+		if err := h.writeWS(ws, style, resultFrame(event, result)); err != nil {
+			return
+		}
+	}
+}
+
+// resultFrame 将订阅结果转换为 WebSocket 帧，字段与 GET /api/v1/tasks/:id/progress 的响应一致
+func resultFrame(event string, result progress.SubscribeResult) gin.H {
+	frame := gin.H{
+		"event":     event,
+		"progress":  result.Progress,
+		"is_final":  result.IsFinal,
+		"stream_id": result.StreamID,
+	}
+	if result.IsFinal {
+		frame["status"] = result.Status
+	}
+	if result.Result != nil {
+		frame["result"] = result.Result
+	}
+	if len(result.Warnings) > 0 {
+		frame["warnings"] = result.Warnings
+	}
+	return frame
+}
+
+// writeWS 按 style 改写字段命名后写入一个文本帧，写入失败说明客户端已断开
+func (h *ProgressHandler) writeWS(ws *websocket.Conn, style render.Style, frame gin.H) error {
+	data, err := render.Fields(style, frame)
+	if err != nil {
+		h.logger.Error("failed to marshal WebSocket frame", zap.Error(err))
+		return nil
+	}
+	if err := ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+	if err := websocket.Message.Send(ws, string(data)); err != nil {
+		h.logger.Debug("failed to write WebSocket frame", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// closeWS 发送带关闭码的关闭帧，底层连接由 websocket.Server 在处理函数返回后关闭
+func (h *ProgressHandler) closeWS(ws *websocket.Conn, taskID string, code int) {
+	if err := ws.WriteClose(code); err != nil {
+		h.logger.Debug("failed to write WebSocket close frame",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+	}
+}
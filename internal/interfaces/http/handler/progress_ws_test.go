@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/goleak"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+// WebSocket 帧的操作码
+const (
+	websocketTextFrame  = 1
+	websocketCloseFrame = 8
+)
+
+// wsClient 最小的 WebSocket 客户端，只读取服务端（不加掩码）的帧，便于检查关闭码
+type wsClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialWS(t *testing.T, srv *httptest.Server, path string) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", path, srv.Listener.Addr())
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+	return &wsClient{conn: conn, r: r}
+}
+
+// next 读取下一个帧，返回操作码和负载
+func (c *wsClient) next(t *testing.T) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		t.Fatalf("read frame header: %v", err)
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		_, _ = io.ReadFull(c.r, ext[:])
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, _ = io.ReadFull(c.r, ext[:])
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		t.Fatalf("read frame payload: %v", err)
+	}
+	return head[0] & 0x0f, payload
+}
+
+// frame 读取一个文本帧并解析 JSON
+func (c *wsClient) frame(t *testing.T) map[string]any {
+	t.Helper()
+	op, payload := c.next(t)
+	if op != websocketTextFrame {
+		t.Fatalf("expected text frame, got opcode %d (%q)", op, payload)
+	}
+	var frame map[string]any
+	if err := json.Unmarshal(payload, &frame); err != nil {
+		t.Fatalf("decode frame %q: %v", payload, err)
+	}
+	return frame
+}
+
+// closeCode 读取关闭帧并返回关闭码
+func (c *wsClient) closeCode(t *testing.T) int {
+	t.Helper()
+	op, payload := c.next(t)
+	if op != websocketCloseFrame || len(payload) < 2 {
+		t.Fatalf("expected close frame, got opcode %d (%q)", op, payload)
+	}
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+func newWSServer(t *testing.T, sub ProgressSubscriber, done chan struct{}, opts ...ProgressHandlerOption) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if done != nil {
+		r.Use(func(c *gin.Context) {
+			c.Next()
+			close(done)
+		})
+	}
+	h := NewProgressHandler(sub, zap.NewNop(), 0, opts...)
+	r.GET("/api/v1/tasks/:id/progress/ws", h.StreamProgressWS)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStreamProgressWSClosesWithTaskStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		code   int
+	}{
+		{status: "completed", code: WSCloseCompleted},
+		{status: "failed", code: WSCloseTaskFailed},
+		{status: "cancelled", code: WSCloseTaskFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			sub := newScriptedSubscriber()
+			sub.history["t1"] = []progress.SubscribeResult{running("t1", 10)}
+			srv := newWSServer(t, sub, nil)
+
+			client := dialWS(t, srv, "/api/v1/tasks/t1/progress/ws?history=true")
+			<-sub.subscribed
+
+			if f := client.frame(t); f["event"] != "history" {
+				t.Fatalf("expected history frame first, got %v", f)
+			}
+			sub.stream("t1") <- running("t1", 50)
+			f := client.frame(t)
+			if f["event"] != "progress" || f["progress"].(map[string]any)["percentage"] != float64(50) {
+				t.Fatalf("unexpected progress frame %v", f)
+			}
+			sub.stream("t1") <- final("t1", tt.status, json.RawMessage(`{"ok":true}`))
+			f = client.frame(t)
+			if f["event"] != "done" || f["status"] != tt.status || f["is_final"] != true {
+				t.Fatalf("unexpected done frame %v", f)
+			}
+			if code := client.closeCode(t); code != tt.code {
+				t.Fatalf("close code = %d, want %d", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestStreamProgressWSReleasesSubscriptionOnDisconnect(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+	sub := newScriptedSubscriber()
+	done := make(chan struct{})
+	srv := newWSServer(t, sub, done)
+
+	client := dialWS(t, srv, "/api/v1/tasks/t1/progress/ws")
+	<-sub.subscribed
+	client.conn.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the handler to return after the client disconnected")
+	}
+	srv.Close()
+	goleak.VerifyNone(t, ignore)
+}
+
+func TestStreamProgressWSRequiresUpgrade(t *testing.T) {
+	srv := newWSServer(t, newScriptedSubscriber(), nil)
+
+	resp, err := http.Get(srv.URL + "/api/v1/tasks/t1/progress/ws")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Fatalf("expected 426, got %d", resp.StatusCode)
+	}
+}
+
+func TestStreamProgressWSChecksOrigin(t *testing.T) {
+	srv := newWSServer(t, newScriptedSubscriber(), nil, WithAllowedOrigins([]string{"https://app.example.com/"}))
+
+	handshake := func(origin string) int {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/tasks/t1/progress/ws", nil)
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("handshake: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		origin string
+		want   int
+	}{
+		{origin: "https://evil.example.com", want: http.StatusForbidden},
+		{origin: "null", want: http.StatusForbidden},
+		{origin: "https://app.example.com", want: http.StatusSwitchingProtocols},
+		// 同源页面和非浏览器客户端
+		{origin: "http://" + srv.Listener.Addr().String(), want: http.StatusSwitchingProtocols},
+		{origin: "", want: http.StatusSwitchingProtocols},
+	}
+	for _, tt := range tests {
+		if got := handshake(tt.origin); got != tt.want {
+			t.Fatalf("origin %q: expected %d, got %d", tt.origin, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	taskapp "github.com/Aixtrade/TaskFlow/internal/application/task"
+	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/dto"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+// QueueStatsHandler 推送队列统计
+type QueueStatsHandler struct {
+	feed      *taskapp.QueueStatsFeed
+	logger    *zap.Logger
+	heartbeat time.Duration
+	clock     clock.Clock
+}
+
+// QueueStatsHandlerOption 队列统计推送处理器可选项
+type QueueStatsHandlerOption func(*QueueStatsHandler)
+
+// WithStatsHeartbeat 连接上超过 d 没有推送时发送一条 SSE 注释，不大于 0 时不发送
+func WithStatsHeartbeat(d time.Duration) QueueStatsHandlerOption {
+	return func(h *QueueStatsHandler) {
+		h.heartbeat = d
+	}
+}
+
+// WithStatsClock 替换心跳使用的时钟，用于测试
+func WithStatsClock(clk clock.Clock) QueueStatsHandlerOption {
+	return func(h *QueueStatsHandler) {
+		h.clock = clk
+	}
+}
+
+// NewQueueStatsHandler 创建队列统计推送处理器
+func NewQueueStatsHandler(feed *taskapp.QueueStatsFeed, logger *zap.Logger, opts ...QueueStatsHandlerOption) *QueueStatsHandler {
+	h := &QueueStatsHandler{
+		feed:   feed,
+		logger: logger,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.clock = clock.OrReal(h.clock)
+	return h
+}
+
+// Stream 通过 SSE 推送队列统计，可用 queue 参数只推送单个队列
+// GET /api/v1/queues/stats/stream
+func (h *QueueStatsHandler) Stream(c *gin.Context) {
+	queue := c.Query("queue")
+	ctx := c.Request.Context()
+
+	ch, err := h.feed.Subscribe(ctx)
+	if errors.Is(err, taskapp.ErrTooManyStatsSubscribers) {
+		h.logger.Warn("rejecting queue stats stream, subscriber limit reached")
+		c.Header("Retry-After", strconv.Itoa(int(subscriptionRetryAfter/time.Second)))
+		render.JSON(c, http.StatusServiceUnavailable, gin.H{
+			"error": "too many queue stats streams, retry later",
+			"code":  "STATS_SUBSCRIBER_LIMIT",
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 禁用 nginx 缓冲
+
+	style := render.StyleOf(c)
+
+	// 心跳在每次推送后重新计时，只在连接空闲时发送
+	var heartbeat <-chan time.Time
+	resetHeartbeat := func() {}
+	if h.heartbeat > 0 {
+		resetHeartbeat = func() { heartbeat = h.clock.After(h.heartbeat) }
+		resetHeartbeat()
+	}
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-heartbeat:
+			_, _ = io.WriteString(w, ": keepalive\n\n")
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+			resetHeartbeat()
+			return true
+
+		case snapshot, ok := <-ch:
+			if !ok {
+				return false
+			}
+			stats := snapshot.Stats
+			if queue != "" {
+				stats = filterQueueStats(stats, queue)
+			}
+			writeSSE(h.logger, w, style, "stats", dto.QueueStatsEvent{
+				Seq:    snapshot.Seq,
+				Queues: queueStatsResponse(stats),
+			})
+			resetHeartbeat()
+			return true
+
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+func filterQueueStats(stats []asynqqueue.QueueStats, queue string) []asynqqueue.QueueStats {
+	for _, s := range stats {
+		if s.Queue == queue {
+			return []asynqqueue.QueueStats{s}
+		}
+	}
+	return []asynqqueue.QueueStats{}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	taskapp "github.com/Aixtrade/TaskFlow/internal/application/task"
+	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+func TestQueueStatsStreamSendsHeartbeatsAndLimitsSubscribers(t *testing.T) {
+	fake := &fakeClient{allStats: []asynqqueue.QueueStats{
+		{Queue: "default", Pending: 3},
+		{Queue: "high", Pending: 1},
+	}}
+	// 统计读取和心跳使用不同的时钟，推进心跳时钟不会触发新的读取
+	feed := taskapp.NewQueueStatsFeed(taskapp.NewService(fake, zap.NewNop()), time.Second, 0, 1, clock.NewFake(time.Unix(0, 0)))
+	heartbeat := clock.NewFake(time.Unix(0, 0))
+	h := NewQueueStatsHandler(feed, zap.NewNop(), WithStatsHeartbeat(15*time.Second), WithStatsClock(heartbeat))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/v1/queues/stats/stream", h.Stream)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rec := newSSERecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/queues/stats/stream?queue=default", nil).WithContext(ctx)
+		r.ServeHTTP(rec, req)
+	}()
+
+	waitEvents(t, rec, 1)
+	body := rec.render()
+	if !strings.Contains(body, "event: stats") || !strings.Contains(body, `"queue":"default"`) || strings.Contains(body, `"queue":"high"`) {
+		t.Fatalf("expected a stats event for the default queue only:\n%s", body)
+	}
+
+	// 订阅者已达上限，新连接在开始推送前被拒绝
+	limited := httptest.NewRecorder()
+	r.ServeHTTP(limited, httptest.NewRequest(http.MethodGet, "/api/v1/queues/stats/stream", nil))
+	if limited.Code != http.StatusServiceUnavailable || limited.Header().Get("Retry-After") != "5" {
+		t.Fatalf("expected 503 with Retry-After, got %d %v", limited.Code, limited.Header())
+	}
+	if !strings.Contains(limited.Body.String(), "STATS_SUBSCRIBER_LIMIT") {
+		t.Fatalf("expected the subscriber limit code, got %s", limited.Body.String())
+	}
+
+	// 推送后心跳重新计时；空闲超过心跳间隔时发送 SSE 注释
+	heartbeat.BlockUntil(2)
+	heartbeat.Advance(15 * time.Second)
+	waitEvents(t, rec, 2)
+	if body := rec.render(); !strings.HasSuffix(body, ": keepalive\n\n") {
+		t.Fatalf("expected a keepalive comment:\n%s", body)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream to end when the client disconnects")
+	}
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	taskapp "github.com/Aixtrade/TaskFlow/internal/application/task"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/discovery"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/dto"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
+	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+)
+
+// QueueWeightStore 读写队列权重覆盖，由 discovery.WeightStore 实现
+type QueueWeightStore interface {
+	Get(ctx context.Context) (discovery.QueueWeights, error)
+	Set(ctx context.Context, weights map[string]int) (discovery.QueueWeights, error)
+}
+
+// WorkerLister 列出存活的 worker，由 discovery.Directory 实现
+type WorkerLister interface {
+	Workers(ctx context.Context) ([]discovery.WorkerInfo, error)
+}
+
+// QueueWeightsHandler 在运行中调整队列权重，worker 轮询到变化后依次重启消费
+type QueueWeightsHandler struct {
+	store   QueueWeightStore
+	workers WorkerLister
+	queues  func() []string
+	logger  *zap.Logger
+}
+
+// NewQueueWeightsHandler workers 为 nil（未启用 discovery）时不报告各 worker 的应用情况。
+// queues 返回 worker 可能消费的队列，覆盖其他队列的请求会被拒绝；为 nil 或返回 nil 时只检查队列名格式
+func NewQueueWeightsHandler(store QueueWeightStore, workers WorkerLister, queues func() []string, logger *zap.Logger) *QueueWeightsHandler {
+	return &QueueWeightsHandler{
+		store:   store,
+		workers: workers,
+		queues:  queues,
+		logger:  logger,
+	}
+}
+
+// Get 返回当前的权重覆盖及各 worker 是否已应用
+// GET /api/v1/queues/weights
+func (h *QueueWeightsHandler) Get(c *gin.Context) {
+	weights, err := h.store.Get(c.Request.Context())
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, dto.ErrorResponse{
+			Error: err.Error(),
+			Code:  "QUEUE_WEIGHTS_FAILED",
+		})
+		return
+	}
+	h.respond(c, weights)
+}
+
+// Set 替换权重覆盖，只影响各 worker 已消费的队列
+// PUT /api/v1/queues/weights
+func (h *QueueWeightsHandler) Set(c *gin.Context) {
+	var req dto.SetQueueWeightsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeBindError(c, err)
+		return
+	}
+	if req.Weights == nil {
+		render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+			Error: "weights is required",
+			Code:  "INVALID_REQUEST",
+		})
+		return
+	}
+	var known []string
+	if h.queues != nil {
+		known = h.queues()
+	}
+	weightsByQueue := make(map[string]int, len(req.Weights))
+	for name, weight := range req.Weights {
+		if name == "" || weight <= 0 {
+			render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+				Error: fmt.Sprintf("weight for queue %q must be greater than 0", name),
+				Code:  "INVALID_QUEUE_WEIGHT",
+			})
+			return
+		}
+		// worker 只调整已消费的队列，其他队列的覆盖不会生效
+		queue, err := taskapp.NormalizeQueue(name)
+		if err == nil && known != nil && !slices.Contains(known, queue) {
+			err = apperrors.NewQueueError(queue, "no worker consumes this queue", known)
+		}
+		if err != nil {
+			render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+				Error:   err.Error(),
+				Code:    "INVALID_QUEUE",
+				Details: queueErrorDetails(err),
+			})
+			return
+		}
+		weightsByQueue[queue] = weight
+	}
+
+	weights, err := h.store.Set(c.Request.Context(), weightsByQueue)
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, dto.ErrorResponse{
+			Error: err.Error(),
+			Code:  "QUEUE_WEIGHTS_FAILED",
+		})
+		return
+	}
+	h.logger.Info("queue weight override changed",
+		zap.Int64("version", weights.Version),
+		zap.Any("weights", weights.Weights),
+		zap.String("client_ip", c.ClientIP()),
+	)
+	h.respond(c, weights)
+}
+
+func (h *QueueWeightsHandler) respond(c *gin.Context, weights discovery.QueueWeights) {
+	resp := dto.QueueWeightsResponse{
+		Version: weights.Version,
+		Weights: weights.Weights,
+	}
+	if resp.Weights == nil {
+		resp.Weights = map[string]int{}
+	}
+
+	if h.workers != nil {
+		workers, err := h.workers.Workers(c.Request.Context())
+		if err != nil {
+			// 覆盖已读取或写入，应用情况只是辅助信息
+			h.logger.Warn("failed to list workers", zap.Error(err))
+		}
+		for _, w := range workers {
+			applied := w.WeightsVersion == weights.Version
+			if !applied {
+				resp.Pending++
+			}
+			resp.Workers = append(resp.Workers, dto.QueueWeightsWorker{
+				InstanceID:     w.InstanceID,
+				Hostname:       w.Hostname,
+				Queues:         w.Queues,
+				WeightsVersion: w.WeightsVersion,
+				Applied:        applied,
+			})
+		}
+	}
+
+	render.JSON(c, http.StatusOK, resp)
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/discovery"
+)
+
+type fakeWeightStore struct {
+	weights discovery.QueueWeights
+	sets    int
+}
+
+func (f *fakeWeightStore) Get(context.Context) (discovery.QueueWeights, error) {
+	return f.weights, nil
+}
+
+func (f *fakeWeightStore) Set(_ context.Context, weights map[string]int) (discovery.QueueWeights, error) {
+	f.sets++
+	f.weights = discovery.QueueWeights{Version: f.weights.Version + 1, Weights: weights}
+	return f.weights, nil
+}
+
+type fakeWorkerLister []discovery.WorkerInfo
+
+func (f fakeWorkerLister) Workers(context.Context) ([]discovery.WorkerInfo, error) {
+	return f, nil
+}
+
+func setupWeightsRouter(store QueueWeightStore, workers WorkerLister) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := NewQueueWeightsHandler(store, workers, func() []string { return []string{"critical", "default", "low"} }, zap.NewNop())
+	r := gin.New()
+	r.GET("/api/v1/queues/weights", h.Get)
+	r.PUT("/api/v1/queues/weights", h.Set)
+	return r
+}
+
+func putWeights(r *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/queues/weights", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestQueueWeightsHandlerSetReportsPendingWorkers(t *testing.T) {
+	store := &fakeWeightStore{weights: discovery.QueueWeights{Version: 2}}
+	workers := fakeWorkerLister{
+		{InstanceID: "w1", Queues: map[string]int{"critical": 6}, WeightsVersion: 2},
+		{InstanceID: "w2", Queues: map[string]int{"critical": 6}, WeightsVersion: 3},
+	}
+	r := setupWeightsRouter(store, workers)
+
+	resp := putWeights(r, `{"weights":{" Critical ":10,"default":2}}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var body struct {
+		Version int64          `json:"version"`
+		Weights map[string]int `json:"weights"`
+		Pending int            `json:"pending"`
+		Workers []struct {
+			InstanceID string `json:"instance_id"`
+			Applied    bool   `json:"applied"`
+		} `json:"workers"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if body.Version != 3 || !maps.Equal(body.Weights, map[string]int{"critical": 10, "default": 2}) {
+		t.Fatalf("expected normalized weights at version 3, got %s", resp.Body.String())
+	}
+	if body.Pending != 1 || len(body.Workers) != 2 || body.Workers[0].Applied || !body.Workers[1].Applied {
+		t.Fatalf("expected w1 to be pending and w2 applied, got %s", resp.Body.String())
+	}
+}
+
+func TestQueueWeightsHandlerSetRejectsInvalidWeights(t *testing.T) {
+	tests := map[string]struct {
+		body string
+		code string
+	}{
+		"missing weights": {body: `{}`, code: "INVALID_REQUEST"},
+		"zero weight":     {body: `{"weights":{"default":0}}`, code: "INVALID_QUEUE_WEIGHT"},
+		"malformed queue": {body: `{"weights":{"critical/eu":1}}`, code: "INVALID_QUEUE"},
+		"unknown queue":   {body: `{"weights":{"default":1,"lowest":1}}`, code: "INVALID_QUEUE"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := &fakeWeightStore{}
+			resp := putWeights(setupWeightsRouter(store, nil), tt.body)
+			if resp.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d: %s", resp.Code, resp.Body.String())
+			}
+			var body struct {
+				Code string `json:"code"`
+			}
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if body.Code != tt.code {
+				t.Fatalf("expected %s, got %s", tt.code, resp.Body.String())
+			}
+			if store.sets != 0 {
+				t.Fatal("expected the override not to be written")
+			}
+		})
+	}
+}
+
+func TestQueueWeightsHandlerGetWithoutOverride(t *testing.T) {
+	r := setupWeightsRouter(&fakeWeightStore{}, nil)
+
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/queues/weights", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.Code)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if weights, ok := body["weights"].(map[string]any); !ok || len(weights) != 0 {
+		t.Fatalf("expected an empty weights object, got %s", resp.Body.String())
+	}
+	if _, ok := body["workers"]; ok {
+		t.Fatalf("expected no workers without discovery, got %s", resp.Body.String())
+	}
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/schema"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/dto"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
+	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
+)
+
+type SchemaHandler struct {
+	registry *schema.Registry
+}
+
+func NewSchemaHandler(registry *schema.Registry) *SchemaHandler {
+	return &SchemaHandler{
+		registry: registry,
+	}
+}
+
+func (h *SchemaHandler) Put(c *gin.Context) {
+	taskType := tasktype.Type(c.Param("type"))
+	if !taskType.IsValid() {
+		render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+			Error: "unknown task type",
+			Code:  "INVALID_TASK_TYPE",
+		})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil || !json.Valid(body) {
+		writeBindError(c, errors.Join(errors.New("schema must be a JSON document"), err))
+		return
+	}
+
+	s, err := h.registry.Put(c.Request.Context(), taskType.String(), body)
+	if err != nil {
+		if errors.Is(err, schema.ErrInvalidSchema) {
+			render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+				Error: err.Error(),
+				Code:  "INVALID_SCHEMA",
+			})
+			return
+		}
+		render.JSON(c, http.StatusInternalServerError, dto.ErrorResponse{
+			Error: err.Error(),
+			Code:  "SCHEMA_UPDATE_FAILED",
+		})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, s)
+}
+
+func (h *SchemaHandler) Get(c *gin.Context) {
+	s, err := h.registry.Get(c.Request.Context(), c.Param("type"))
+	h.writeSchema(c, s, err)
+}
+
+// PutOutput 注册 gRPC 服务方法的结果 schema，method 为 * 时对整个服务生效
+func (h *SchemaHandler) PutOutput(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil || !json.Valid(body) {
+		writeBindError(c, errors.Join(errors.New("schema must be a JSON document"), err))
+		return
+	}
+
+	s, err := h.registry.PutOutput(c.Request.Context(), c.Param("service"), c.Param("method"), body)
+	if err != nil {
+		if errors.Is(err, schema.ErrInvalidSchema) {
+			render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+				Error: err.Error(),
+				Code:  "INVALID_SCHEMA",
+			})
+			return
+		}
+		render.JSON(c, http.StatusInternalServerError, dto.ErrorResponse{
+			Error: err.Error(),
+			Code:  "SCHEMA_UPDATE_FAILED",
+		})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, s)
+}
+
+func (h *SchemaHandler) GetOutput(c *gin.Context) {
+	s, err := h.registry.GetOutput(c.Request.Context(), c.Param("service"), c.Param("method"))
+	h.writeSchema(c, s, err)
+}
+
+func (h *SchemaHandler) writeSchema(c *gin.Context, s *schema.Schema, err error) {
+	if err != nil {
+		if errors.Is(err, schema.ErrSchemaNotFound) {
+			render.JSON(c, http.StatusNotFound, dto.ErrorResponse{
+				Error: err.Error(),
+				Code:  "SCHEMA_NOT_FOUND",
+			})
+			return
+		}
+		render.JSON(c, http.StatusInternalServerError, dto.ErrorResponse{
+			Error: err.Error(),
+			Code:  "SCHEMA_FETCH_FAILED",
+		})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, s)
+}
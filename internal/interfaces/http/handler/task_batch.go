@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	taskapp "github.com/Aixtrade/TaskFlow/internal/application/task"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/dto"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
+)
+
+// DefaultMaxBatchSize 批量创建一次最多包含的任务数的默认值
+const DefaultMaxBatchSize = 100
+
+// CreateBatch 批量创建任务，请求体为 CreateTaskRequest 数组。每个任务单独校验和入队，
+// 结果数组与请求一一对应，元素为 CreateTaskResponse 或 ErrorResponse。
+// 全部成功时返回 201，任一任务失败时返回 207
+// POST /api/v1/tasks/batch
+func (h *TaskHandler) CreateBatch(c *gin.Context) {
+	// 不使用 ShouldBindJSON：它会整体校验数组，单个任务缺少字段时整个请求都被拒绝
+	var reqs []dto.CreateTaskRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&reqs); err != nil {
+		writeBindError(c, err)
+		return
+	}
+	if len(reqs) == 0 {
+		render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+			Error: "batch must contain at least one task",
+			Code:  "INVALID_REQUEST",
+		})
+		return
+	}
+	if len(reqs) > h.maxBatchSize {
+		render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+			Error: fmt.Sprintf("batch contains %d tasks, at most %d allowed", len(reqs), h.maxBatchSize),
+			Code:  "BATCH_TOO_LARGE",
+			Details: gin.H{
+				"max":       h.maxBatchSize,
+				"requested": len(reqs),
+			},
+		})
+		return
+	}
+
+	items := make([]any, len(reqs))
+	failed := false
+	// cmds 只包含通过请求层校验的任务，indexes 记录它们在请求中的位置
+	cmds := make([]*taskapp.CreateTaskCommand, 0, len(reqs))
+	indexes := make([]int, 0, len(reqs))
+	for i := range reqs {
+		if err := binding.Validator.ValidateStruct(&reqs[i]); err != nil {
+			_, items[i] = bindErrorResponse(err)
+			failed = true
+			continue
+		}
+		cmd, failure := newCreateCommand(c, &reqs[i])
+		if failure != nil {
+			items[i] = failure.body
+			failed = true
+			continue
+		}
+		cmds = append(cmds, cmd)
+		indexes = append(indexes, i)
+	}
+
+	for j, result := range h.service.CreateTaskBatch(c.Request.Context(), cmds) {
+		if result.Err != nil {
+			_, body := createErrorResponse(result.Err)
+			items[indexes[j]] = body
+			failed = true
+			continue
+		}
+		items[indexes[j]] = h.createResponse(c, result.Result)
+	}
+
+	status := http.StatusCreated
+	if failed {
+		status = http.StatusMultiStatus
+	}
+	render.JSON(c, status, items)
+}
@@ -1,62 +1,206 @@
 package handler
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hibiken/asynq"
 
 	taskapp "github.com/Aixtrade/TaskFlow/internal/application/task"
+	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
 	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/dto"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/middleware"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
 	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
 )
 
 type TaskHandler struct {
 	service *taskapp.Service
+	// publicURL 创建响应中链接的基础地址，为空时由请求推断
+	publicURL string
+	// trustedProxies 可信反向代理，只有来自这些地址的请求才采用 X-Forwarded-* 头部
+	trustedProxies []netip.Prefix
+	// withoutProgress 进度功能关闭，进度端点未注册，响应中不返回进度链接
+	withoutProgress bool
+	// maxBatchSize 批量创建一次最多包含的任务数
+	maxBatchSize int
 }
 
-func NewTaskHandler(service *taskapp.Service) *TaskHandler {
-	return &TaskHandler{
-		service: service,
+// TaskHandlerOption TaskHandler 的可选配置
+type TaskHandlerOption func(*TaskHandler)
+
+// WithPublicURL 设置对外地址（如 https://taskflow.example.com），创建响应中的链接以它为前缀
+func WithPublicURL(publicURL string) TaskHandlerOption {
+	return func(h *TaskHandler) {
+		h.publicURL = strings.TrimRight(publicURL, "/")
+	}
+}
+
+// WithTrustedProxies 设置可信反向代理，未配置公开地址时按来自这些代理的 X-Forwarded-Proto/X-Forwarded-Host 生成链接
+func WithTrustedProxies(prefixes []netip.Prefix) TaskHandlerOption {
+	return func(h *TaskHandler) {
+		h.trustedProxies = prefixes
+	}
+}
+
+// WithProgressLinks 设置创建响应是否包含 progress_url 和 progress_stream_url，进度功能关闭时传入 false
+func WithProgressLinks(enabled bool) TaskHandlerOption {
+	return func(h *TaskHandler) {
+		h.withoutProgress = !enabled
 	}
 }
 
+// WithMaxBatchSize 设置批量创建一次最多包含的任务数，不大于 0 时使用默认值
+func WithMaxBatchSize(n int) TaskHandlerOption {
+	return func(h *TaskHandler) {
+		if n > 0 {
+			h.maxBatchSize = n
+		}
+	}
+}
+
+func NewTaskHandler(service *taskapp.Service, opts ...TaskHandlerOption) *TaskHandler {
+	h := &TaskHandler{
+		service:      service,
+		maxBatchSize: DefaultMaxBatchSize,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
 func (h *TaskHandler) Create(c *gin.Context) {
 	var req dto.CreateTaskRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+		writeBindError(c, err)
+		return
+	}
+
+	cmd, ok := buildCreateCommand(c, &req)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.CreateTask(c.Request.Context(), cmd)
+	if err != nil {
+		writeCreateError(c, err)
+		return
+	}
+
+	h.writeCreateResult(c, result)
+}
+
+func (h *TaskHandler) Upload(c *gin.Context) {
+	var form dto.UploadTaskRequest
+	if err := c.ShouldBind(&form); err != nil {
+		writeBindError(c, err)
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		writeBindError(c, err)
+		return
+	}
+
+	req, err := form.CreateTaskRequest()
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+			Error: "invalid metadata format",
+			Code:  "INVALID_METADATA",
+		})
+		return
+	}
+
+	cmd, ok := buildCreateCommand(c, req)
+	if !ok {
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
 			Error: err.Error(),
-			Code:  "INVALID_REQUEST",
+			Code:  "INVALID_FILE",
 		})
 		return
 	}
+	defer file.Close()
+
+	contentType := fileHeader.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	result, err := h.service.CreateTaskFromUpload(c.Request.Context(), &taskapp.UploadTaskCommand{
+		CreateTaskCommand: *cmd,
+		FileField:         form.FileField,
+		Filename:          fileHeader.Filename,
+		ContentType:       contentType,
+		File:              file,
+	})
+	if err != nil {
+		writeCreateError(c, err)
+		return
+	}
+
+	h.writeCreateResult(c, result)
+}
+
+func buildCreateCommand(c *gin.Context, req *dto.CreateTaskRequest) (*taskapp.CreateTaskCommand, bool) {
+	cmd, failure := newCreateCommand(c, req)
+	if failure != nil {
+		render.JSON(c, failure.status, failure.body)
+		return nil, false
+	}
+	return cmd, true
+}
+
+// createFailure 创建请求在进入 service 之前被拒绝时的响应，批量创建时作为单个任务的结果
+type createFailure struct {
+	status int
+	body   dto.ErrorResponse
+}
+
+func newCreateCommand(c *gin.Context, req *dto.CreateTaskRequest) (*taskapp.CreateTaskCommand, *createFailure) {
+	if failure := authorizeTask(c, req); failure != nil {
+		return nil, failure
+	}
 
 	timeout, err := req.GetTimeout()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+		return nil, &createFailure{status: http.StatusBadRequest, body: dto.ErrorResponse{
 			Error: "invalid timeout format",
 			Code:  "INVALID_TIMEOUT",
-		})
-		return
+		}}
 	}
 
 	processAt, err := req.GetProcessAt()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+		return nil, &createFailure{status: http.StatusBadRequest, body: dto.ErrorResponse{
 			Error: "invalid process_at format",
 			Code:  "INVALID_PROCESS_AT",
-		})
-		return
+		}}
 	}
 
 	unique, err := req.GetUnique()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+		return nil, &createFailure{status: http.StatusBadRequest, body: dto.ErrorResponse{
 			Error: "invalid unique format",
 			Code:  "INVALID_UNIQUE",
-		})
-		return
+		}}
 	}
 
 	cmd := &taskapp.CreateTaskCommand{
@@ -68,36 +212,390 @@ func (h *TaskHandler) Create(c *gin.Context) {
 		ProcessAt:  processAt,
 		Unique:     unique,
 		Metadata:   req.Metadata,
+		Requires:   req.Requires,
+		TaskID:     req.TaskID,
 	}
+	if key := middleware.CurrentAPIKey(c); key != nil {
+		cmd.IDPrefix = key.IDPrefix
+	}
+	return cmd, nil
+}
 
-	result, err := h.service.CreateTask(c.Request.Context(), cmd)
+// authorizeTask 应用 API key 的默认队列并检查任务是否在其允许范围内
+func authorizeTask(c *gin.Context, req *dto.CreateTaskRequest) *createFailure {
+	key := middleware.CurrentAPIKey(c)
+	if key == nil {
+		return nil
+	}
+
+	// 先规范化再与 API key 允许的队列比较，格式错误的名称留给 service 报告
+	if queue, err := taskapp.NormalizeQueue(req.Queue); err == nil {
+		req.Queue = queue
+	}
+	req.Queue = key.ResolveQueue(req.Queue)
+	queue := req.Queue
+	if queue == "" {
+		queue = req.GetTaskType().Queue()
+	}
+
+	if body, ok := restrictionResponse(key.Authorize(req.GetTaskType(), queue, req.Payload)); ok {
+		return &createFailure{status: http.StatusForbidden, body: body}
+	}
+	return nil
+}
+
+// restrictionResponse 将 API key 的限制错误转换为 403 响应体，err 不是限制错误时返回 false
+func restrictionResponse(err error) (dto.ErrorResponse, bool) {
+	var restricted *middleware.RestrictionError
+	if !errors.As(err, &restricted) {
+		return dto.ErrorResponse{}, false
+	}
+	return dto.ErrorResponse{
+		Error: err.Error(),
+		Code:  "API_KEY_RESTRICTED",
+		Details: gin.H{
+			"restriction": restricted.Restriction,
+			"value":       restricted.Value,
+			"allowed":     restricted.Allowed,
+		},
+	}, true
+}
+
+func writeBindError(c *gin.Context, err error) {
+	status, body := bindErrorResponse(err)
+	render.JSON(c, status, body)
+}
+
+// bindErrorResponse 将请求解析和校验的错误映射为 HTTP 状态码和错误响应
+func bindErrorResponse(err error) (int, dto.ErrorResponse) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return http.StatusRequestEntityTooLarge, dto.ErrorResponse{
+			Error: fmt.Sprintf("request body exceeds %d bytes", maxErr.Limit),
+			Code:  "REQUEST_TOO_LARGE",
+		}
+	}
+	return http.StatusBadRequest, dto.ErrorResponse{
+		Error: err.Error(),
+		Code:  "INVALID_REQUEST",
+	}
+}
+
+func writeCreateError(c *gin.Context, err error) {
+	status, body := createErrorResponse(err)
+	render.JSON(c, status, body)
+}
+
+// queueErrorDetails 返回队列错误的详情，列出可用的队列；不是 QueueError 时返回 nil
+func queueErrorDetails(err error) any {
+	var queueErr *apperrors.QueueError
+	if !errors.As(err, &queueErr) {
+		return nil
+	}
+	details := gin.H{"queue": queueErr.Queue, "reason": queueErr.Reason}
+	if len(queueErr.Valid) > 0 {
+		details["valid_queues"] = queueErr.Valid
+	}
+	return details
+}
+
+// createErrorResponse 将创建任务的错误映射为 HTTP 状态码和错误响应
+func createErrorResponse(err error) (int, dto.ErrorResponse) {
+	status := http.StatusInternalServerError
+	code := "INTERNAL_ERROR"
+
+	var details any
+	var maxErr *http.MaxBytesError
+	var schemaErr *apperrors.PayloadSchemaError
+	var fieldErr *apperrors.PayloadFieldError
+	var limitErr *apperrors.LimitError
+	var deprecatedErr *apperrors.DeprecatedError
+	switch {
+	case errors.As(err, &maxErr):
+		status = http.StatusRequestEntityTooLarge
+		code = "REQUEST_TOO_LARGE"
+	case errors.Is(err, apperrors.ErrInvalidTaskType):
+		status = http.StatusBadRequest
+		code = "INVALID_TASK_TYPE"
+	case errors.Is(err, apperrors.ErrInvalidTaskID):
+		status = http.StatusBadRequest
+		code = "INVALID_TASK_ID"
+	case errors.As(err, &schemaErr):
+		status = http.StatusBadRequest
+		code = "INVALID_PAYLOAD"
+		details = gin.H{
+			"task_type":      schemaErr.TaskType,
+			"schema_version": schemaErr.SchemaVersion,
+			"reason":         schemaErr.Reason,
+		}
+	case errors.As(err, &fieldErr):
+		status = http.StatusBadRequest
+		code = "INVALID_PAYLOAD"
+		details = gin.H{
+			"task_type": fieldErr.TaskType,
+			"field":     fieldErr.Field,
+			"reason":    fieldErr.Reason,
+		}
+	case errors.Is(err, apperrors.ErrInvalidPayload):
+		status = http.StatusBadRequest
+		code = "INVALID_PAYLOAD"
+	case errors.As(err, &limitErr):
+		status = http.StatusBadRequest
+		code = "LIMIT_EXCEEDED"
+		details = gin.H{
+			"field":     limitErr.Field,
+			"limit":     limitErr.Limit,
+			"cap":       limitErr.Cap,
+			"requested": limitErr.Requested,
+		}
+	case errors.As(err, &deprecatedErr):
+		status = http.StatusGone
+		code = "TASK_DEPRECATED"
+		details = gin.H{
+			"deprecation": deprecatedErr.Name,
+			"task_type":   deprecatedErr.TaskType,
+			"cutoff":      deprecatedErr.Cutoff.UTC().Format(time.RFC3339),
+			"message":     deprecatedErr.Message,
+		}
+	case errors.Is(err, apperrors.ErrInvalidQueue):
+		status = http.StatusBadRequest
+		code = "INVALID_QUEUE"
+		details = queueErrorDetails(err)
+	case errors.Is(err, apperrors.ErrUnroutableLabels):
+		status = http.StatusBadRequest
+		code = "UNROUTABLE_LABELS"
+	case errors.Is(err, apperrors.ErrTaskAlreadyExists):
+		status = http.StatusConflict
+		code = "TASK_ALREADY_EXISTS"
+	case errors.Is(err, apperrors.ErrNoCapableWorker):
+		status = http.StatusServiceUnavailable
+		code = "NO_CAPABLE_WORKER"
+	case errors.Is(err, apperrors.ErrBlobStoreDisabled):
+		status = http.StatusNotImplemented
+		code = "BLOB_STORE_DISABLED"
+	}
+
+	return status, dto.ErrorResponse{
+		Error:   err.Error(),
+		Code:    code,
+		Details: details,
+	}
+}
+
+func (h *TaskHandler) writeCreateResult(c *gin.Context, result *taskapp.CreateTaskResult) {
+	for _, warning := range result.Warnings {
+		c.Writer.Header().Add("Warning", fmt.Sprintf("199 taskflow %q", warning))
+	}
+	// 弃用是持续性的告警，使用 299；Sunset 头给出最早的截止时间
+	var sunset time.Time
+	for _, d := range result.Deprecations {
+		c.Writer.Header().Add("Warning", fmt.Sprintf("299 taskflow %q", d.Warning()))
+		if !d.Cutoff.IsZero() && (sunset.IsZero() || d.Cutoff.Before(sunset)) {
+			sunset = d.Cutoff
+		}
+	}
+	if !sunset.IsZero() {
+		c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+	}
+
+	render.JSON(c, http.StatusCreated, h.createResponse(c, result))
+}
+
+// createResponse 构造创建任务的响应体，告警和弃用项同时放在响应体中
+func (h *TaskHandler) createResponse(c *gin.Context, result *taskapp.CreateTaskResult) dto.CreateTaskResponse {
+	resp := dto.CreateTaskResponse{
+		TaskID:   result.TaskID,
+		Queue:    result.Queue,
+		Status:   result.Status,
+		Self:     taskURL(result.TaskID, result.Queue),
+		Warnings: result.Warnings,
+	}
+	base := h.baseURL(c)
+	resp.TaskURL = base + resp.Self
+	if !h.withoutProgress {
+		resp.ProgressURL = base + progressURL(result.TaskID)
+		resp.ProgressStreamURL = resp.ProgressURL + "/stream"
+	}
+	for _, d := range result.Deprecations {
+		notice := dto.DeprecationNotice{Name: d.Name, Message: d.Message, Migrated: d.Migrated}
+		if !d.Cutoff.IsZero() {
+			notice.Cutoff = d.Cutoff.UTC().Format(time.RFC3339)
+		}
+		resp.Deprecations = append(resp.Deprecations, notice)
+	}
+	if result.UniqueTTL > 0 {
+		resp.UniqueTTLSeconds = int64(result.UniqueTTL / time.Second)
+		resp.UniqueExpiresAt = result.UniqueExpiresAt.Format(time.RFC3339)
+	}
+	eff := result.Effective
+	resp.Effective = dto.EffectiveOptions{
+		Queue:            eff.Queue,
+		MaxRetries:       eff.MaxRetries,
+		TimeoutSeconds:   int64(eff.Timeout / time.Second),
+		RetentionSeconds: int64(eff.Retention / time.Second),
+		UniqueTTLSeconds: int64(eff.UniqueTTL / time.Second),
+	}
+	if !eff.ProcessAt.IsZero() {
+		resp.Effective.ProcessAt = eff.ProcessAt.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
+
+// taskURL 返回任务详情地址，携带队列以免查询时猜错队列
+func taskURL(taskID, queue string) string {
+	return fmt.Sprintf("/api/v1/tasks/%s?queue=%s", url.PathEscape(taskID), url.QueryEscape(queue))
+}
+
+// progressURL 返回任务最新进度的地址，SSE 地址为其后加 /stream
+func progressURL(taskID string) string {
+	return fmt.Sprintf("/api/v1/tasks/%s/progress", url.PathEscape(taskID))
+}
+
+// baseURL 返回链接的 scheme 和 host：优先使用配置的对外地址，
+// 否则取可信反向代理设置的 X-Forwarded-Proto 和 X-Forwarded-Host，最后使用请求本身。
+// 其他来源的转发头部可由客户端任意伪造，不予采用
+func (h *TaskHandler) baseURL(c *gin.Context) string {
+	if h.publicURL != "" {
+		return h.publicURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	host := c.Request.Host
+	if !h.fromTrustedProxy(c) {
+		return scheme + "://" + host
+	}
+	if proto := firstHeaderValue(c.GetHeader("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	if forwarded := firstHeaderValue(c.GetHeader("X-Forwarded-Host")); forwarded != "" {
+		host = forwarded
+	}
+	return scheme + "://" + host
+}
+
+// fromTrustedProxy 请求的直接来源是否为可信反向代理
+func (h *TaskHandler) fromTrustedProxy(c *gin.Context) bool {
+	addr, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	return slices.ContainsFunc(h.trustedProxies, func(p netip.Prefix) bool {
+		return p.Contains(addr)
+	})
+}
+
+// firstHeaderValue 返回逗号分隔的头部值中的第一个，多级代理时为最外层代理收到的值
+func firstHeaderValue(value string) string {
+	first, _, _ := strings.Cut(value, ",")
+	return strings.TrimSpace(first)
+}
+
+func (h *TaskHandler) Result(c *gin.Context) {
+	queue := c.Query("queue")
+	if queue == "" {
+		queue = "default"
+	}
+
+	query := &taskapp.GetTaskQuery{
+		TaskID: c.Param("id"),
+		Queue:  queue,
+	}
+
+	result, err := h.service.GetTaskResult(c.Request.Context(), query)
+	if err != nil {
+		status := http.StatusInternalServerError
+		code := "INTERNAL_ERROR"
+
+		switch {
+		case errors.Is(err, apperrors.ErrInvalidTaskID):
+			status = http.StatusBadRequest
+			code = "INVALID_TASK_ID"
+		case errors.Is(err, apperrors.ErrInvalidQueue):
+			status = http.StatusBadRequest
+			code = "INVALID_QUEUE"
+		case errors.Is(err, apperrors.ErrTaskNotFound):
+			status = http.StatusNotFound
+			code = "TASK_NOT_FOUND"
+		case errors.Is(err, apperrors.ErrResultNotFound):
+			status = http.StatusNotFound
+			code = "RESULT_NOT_FOUND"
+		}
+
+		render.JSON(c, status, dto.ErrorResponse{
+			Error:   err.Error(),
+			Code:    code,
+			Details: queueErrorDetails(err),
+		})
+		return
+	}
+	defer result.Body.Close()
+
+	contentType := result.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, result.Size, contentType, result.Body, nil)
+}
+
+// Artifact 下载任务产出的文件：可重定向时返回 302，否则直接返回文件内容
+func (h *TaskHandler) Artifact(c *gin.Context) {
+	queue := c.Query("queue")
+	if queue == "" {
+		queue = "default"
+	}
+
+	query := &taskapp.GetTaskArtifactQuery{
+		TaskID: c.Param("id"),
+		Queue:  queue,
+		Name:   c.Param("name"),
+	}
+
+	artifact, err := h.service.GetTaskArtifact(c.Request.Context(), query)
 	if err != nil {
 		status := http.StatusInternalServerError
 		code := "INTERNAL_ERROR"
 
 		switch {
-		case errors.Is(err, apperrors.ErrInvalidTaskType):
+		case errors.Is(err, apperrors.ErrInvalidTaskID):
 			status = http.StatusBadRequest
-			code = "INVALID_TASK_TYPE"
-		case errors.Is(err, apperrors.ErrInvalidPayload):
+			code = "INVALID_TASK_ID"
+		case errors.Is(err, apperrors.ErrInvalidQueue):
 			status = http.StatusBadRequest
-			code = "INVALID_PAYLOAD"
-		case errors.Is(err, apperrors.ErrTaskAlreadyExists):
+			code = "INVALID_QUEUE"
+		case errors.Is(err, apperrors.ErrTaskNotFound):
+			status = http.StatusNotFound
+			code = "TASK_NOT_FOUND"
+		case errors.Is(err, apperrors.ErrArtifactNotFound):
+			status = http.StatusNotFound
+			code = "ARTIFACT_NOT_FOUND"
+		case errors.Is(err, apperrors.ErrArtifactUnavailable):
 			status = http.StatusConflict
-			code = "TASK_ALREADY_EXISTS"
+			code = "ARTIFACT_UNAVAILABLE"
 		}
 
-		c.JSON(status, dto.ErrorResponse{
-			Error: err.Error(),
-			Code:  code,
+		render.JSON(c, status, dto.ErrorResponse{
+			Error:   err.Error(),
+			Code:    code,
+			Details: queueErrorDetails(err),
 		})
 		return
 	}
 
-	c.JSON(http.StatusCreated, dto.CreateTaskResponse{
-		TaskID: result.TaskID,
-		Queue:  result.Queue,
-		Status: result.Status,
+	if artifact.RedirectURL != "" {
+		c.Redirect(http.StatusFound, artifact.RedirectURL)
+		return
+	}
+	defer artifact.Body.Close()
+
+	contentType := artifact.Artifact.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, artifact.Artifact.Size, contentType, artifact.Body, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": artifact.Artifact.Name}),
 	})
 }
 
@@ -110,8 +608,9 @@ func (h *TaskHandler) Get(c *gin.Context) {
 	}
 
 	query := &taskapp.GetTaskQuery{
-		TaskID: taskID,
-		Queue:  queue,
+		TaskID:  taskID,
+		Queue:   queue,
+		Payload: c.Query("include_payload"),
 	}
 
 	result, err := h.service.GetTask(c.Request.Context(), query)
@@ -129,16 +628,20 @@ func (h *TaskHandler) Get(c *gin.Context) {
 		case errors.Is(err, apperrors.ErrTaskNotFound):
 			status = http.StatusNotFound
 			code = "TASK_NOT_FOUND"
+		case apperrors.IsValidationError(err):
+			status = http.StatusBadRequest
+			code = "INVALID_INCLUDE_PAYLOAD"
 		}
 
-		c.JSON(status, dto.ErrorResponse{
-			Error: err.Error(),
-			Code:  code,
+		render.JSON(c, status, dto.ErrorResponse{
+			Error:   err.Error(),
+			Code:    code,
+			Details: queueErrorDetails(err),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, dto.GetTaskResponse{
+	render.JSON(c, http.StatusOK, dto.GetTaskResponse{
 		ID:            result.ID,
 		Queue:         result.Queue,
 		Type:          result.Type,
@@ -147,17 +650,30 @@ func (h *TaskHandler) Get(c *gin.Context) {
 		Retried:       result.Retried,
 		LastErr:       result.LastErr,
 		NextProcessAt: result.NextProcessAt,
+
+		UniqueTTLSeconds: int64(result.UniqueTTL / time.Second),
+		UniqueExpiresAt:  result.UniqueExpiresAt,
+
+		Worker: result.Worker,
+
+		Payload: payloadResponse(result.Payload),
 	})
 }
 
 func (h *TaskHandler) Cancel(c *gin.Context) {
 	taskID := c.Param("id")
+	queue := c.Query("queue")
+
+	if queue == "" {
+		queue = "default"
+	}
 
 	cmd := &taskapp.CancelTaskCommand{
 		TaskID: taskID,
+		Queue:  queue,
 	}
 
-	err := h.service.CancelTask(c.Request.Context(), cmd)
+	result, err := h.service.CancelTask(auditContext(c), cmd)
 	if err != nil {
 		status := http.StatusInternalServerError
 		code := "CANCEL_FAILED"
@@ -165,18 +681,33 @@ func (h *TaskHandler) Cancel(c *gin.Context) {
 			status = http.StatusBadRequest
 			code = "INVALID_TASK_ID"
 		}
+		if errors.Is(err, apperrors.ErrInvalidQueue) {
+			status = http.StatusBadRequest
+			code = "INVALID_QUEUE"
+		}
 		if errors.Is(err, apperrors.ErrTaskNotFound) {
 			status = http.StatusNotFound
 			code = "TASK_NOT_FOUND"
 		}
-		c.JSON(status, dto.ErrorResponse{
-			Error: err.Error(),
-			Code:  code,
+		if errors.Is(err, apperrors.ErrTaskNotCancelable) {
+			status = http.StatusConflict
+			code = "TASK_NOT_CANCELABLE"
+		}
+		render.JSON(c, status, dto.ErrorResponse{
+			Error:   err.Error(),
+			Code:    code,
+			Details: queueErrorDetails(err),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "task cancelled"})
+	render.JSON(c, http.StatusOK, dto.CancelTaskResponse{
+		Message:       "task cancelled",
+		TaskID:        result.TaskID,
+		Queue:         result.Queue,
+		PreviousState: result.PreviousState,
+		Action:        result.Action,
+	})
 }
 
 func (h *TaskHandler) Delete(c *gin.Context) {
@@ -192,7 +723,7 @@ func (h *TaskHandler) Delete(c *gin.Context) {
 		Queue:  queue,
 	}
 
-	err := h.service.DeleteTask(c.Request.Context(), cmd)
+	result, err := h.service.DeleteTask(auditContext(c), cmd)
 	if err != nil {
 		status := http.StatusInternalServerError
 		code := "DELETE_FAILED"
@@ -206,33 +737,236 @@ func (h *TaskHandler) Delete(c *gin.Context) {
 		case errors.Is(err, apperrors.ErrTaskNotFound):
 			status = http.StatusNotFound
 			code = "TASK_NOT_FOUND"
+		case errors.Is(err, apperrors.ErrTaskActive):
+			status = http.StatusConflict
+			code = "TASK_ACTIVE"
 		}
-		c.JSON(status, dto.ErrorResponse{
-			Error: err.Error(),
-			Code:  code,
+		render.JSON(c, status, dto.ErrorResponse{
+			Error:   err.Error(),
+			Code:    code,
+			Details: queueErrorDetails(err),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "task deleted"})
+	render.JSON(c, http.StatusOK, dto.DeleteTaskResponse{
+		Message:         "task deleted",
+		TaskID:          result.TaskID,
+		Queue:           result.Queue,
+		ProgressDeleted: result.ProgressDeleted,
+	})
 }
 
-func (h *TaskHandler) GetQueueStats(c *gin.Context) {
+// Archive 将未开始执行的任务移入 archived
+// POST /api/v1/tasks/:id/archive
+func (h *TaskHandler) Archive(c *gin.Context) {
 	queue := c.Query("queue")
+	if queue == "" {
+		queue = "default"
+	}
 
+	cmd := &taskapp.ArchiveTaskCommand{
+		TaskID: c.Param("id"),
+		Queue:  queue,
+	}
+
+	result, err := h.service.ArchiveTask(auditContext(c), cmd)
+	if err != nil {
+		status := http.StatusInternalServerError
+		code := "ARCHIVE_FAILED"
+		switch {
+		case errors.Is(err, apperrors.ErrInvalidTaskID):
+			status = http.StatusBadRequest
+			code = "INVALID_TASK_ID"
+		case errors.Is(err, apperrors.ErrInvalidQueue):
+			status = http.StatusBadRequest
+			code = "INVALID_QUEUE"
+		case errors.Is(err, apperrors.ErrTaskNotFound):
+			status = http.StatusNotFound
+			code = "TASK_NOT_FOUND"
+		case errors.Is(err, apperrors.ErrTaskActive):
+			status = http.StatusConflict
+			code = "TASK_ACTIVE"
+		case errors.Is(err, apperrors.ErrInvalidTaskState):
+			status = http.StatusConflict
+			code = "TASK_NOT_ARCHIVABLE"
+		}
+		render.JSON(c, status, dto.ErrorResponse{
+			Error:   err.Error(),
+			Code:    code,
+			Details: queueErrorDetails(err),
+		})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, dto.ArchiveTaskResponse{
+		Message: "task archived",
+		TaskID:  result.TaskID,
+		Queue:   result.Queue,
+	})
+}
+
+// Run 让 scheduled 或 retry 状态的任务立即执行
+// POST /api/v1/tasks/:id/run
+func (h *TaskHandler) Run(c *gin.Context) {
+	queue := c.Query("queue")
+	if queue == "" {
+		queue = "default"
+	}
+
+	cmd := &taskapp.RunTaskCommand{
+		TaskID: c.Param("id"),
+		Queue:  queue,
+	}
+
+	result, err := h.service.RunTask(auditContext(c), cmd)
+	if err != nil {
+		status := http.StatusInternalServerError
+		code := "RUN_FAILED"
+		switch {
+		case errors.Is(err, apperrors.ErrInvalidTaskID):
+			status = http.StatusBadRequest
+			code = "INVALID_TASK_ID"
+		case errors.Is(err, apperrors.ErrInvalidQueue):
+			status = http.StatusBadRequest
+			code = "INVALID_QUEUE"
+		case errors.Is(err, apperrors.ErrTaskNotFound):
+			status = http.StatusNotFound
+			code = "TASK_NOT_FOUND"
+		case errors.Is(err, apperrors.ErrTaskActive):
+			status = http.StatusConflict
+			code = "TASK_ACTIVE"
+		case errors.Is(err, apperrors.ErrTaskNotRunnable):
+			status = http.StatusConflict
+			code = "TASK_NOT_RUNNABLE"
+		}
+		render.JSON(c, status, dto.ErrorResponse{
+			Error:   err.Error(),
+			Code:    code,
+			Details: queueErrorDetails(err),
+		})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, dto.RunTaskResponse{
+		Message:       "task queued to run now",
+		TaskID:        result.TaskID,
+		Queue:         result.Queue,
+		PreviousState: result.PreviousState,
+	})
+}
+
+// Requeue 取消正在执行的任务并以新 ID 重新入队其副本
+// POST /api/v1/tasks/:id/requeue
+func (h *TaskHandler) Requeue(c *gin.Context) {
+	queue := c.Query("queue")
+	if queue == "" {
+		queue = "default"
+	}
+
+	cmd := &taskapp.RequeueTaskCommand{
+		TaskID: c.Param("id"),
+		Queue:  queue,
+	}
+	if key := middleware.CurrentAPIKey(c); key != nil {
+		cmd.IDPrefix = key.IDPrefix
+		// 副本按原任务的类型、队列和 payload 检查，与创建任务的限制一致
+		cmd.Authorize = func(info *asynq.TaskInfo) error {
+			return key.Authorize(tasktype.Type(info.Type), info.Queue, info.Payload)
+		}
+	}
+
+	result, err := h.service.RequeueTask(auditContext(c), cmd)
+	if body, ok := restrictionResponse(err); ok {
+		render.JSON(c, http.StatusForbidden, body)
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		code := "REQUEUE_FAILED"
+		switch {
+		case errors.Is(err, apperrors.ErrInvalidTaskID):
+			status = http.StatusBadRequest
+			code = "INVALID_TASK_ID"
+		case errors.Is(err, apperrors.ErrInvalidQueue):
+			status = http.StatusBadRequest
+			code = "INVALID_QUEUE"
+		case errors.Is(err, apperrors.ErrTaskNotFound):
+			status = http.StatusNotFound
+			code = "TASK_NOT_FOUND"
+		case errors.Is(err, apperrors.ErrTaskNotActive):
+			status = http.StatusConflict
+			code = "TASK_NOT_ACTIVE"
+		case errors.Is(err, apperrors.ErrTaskCompleted):
+			status = http.StatusConflict
+			code = "TASK_COMPLETED"
+		case errors.Is(err, apperrors.ErrTaskActive):
+			status = http.StatusConflict
+			code = "TASK_STILL_ACTIVE"
+		}
+		render.JSON(c, status, dto.ErrorResponse{
+			Error:   err.Error(),
+			Code:    code,
+			Details: queueErrorDetails(err),
+		})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, dto.RequeueTaskResponse{
+		Message:      "task cancelled and requeued",
+		TaskID:       result.TaskID,
+		Queue:        result.Queue,
+		NewTaskID:    result.NewTaskID,
+		StoppedState: result.StoppedState,
+	})
+}
+
+func (h *TaskHandler) GetQueueStats(c *gin.Context) {
 	query := &taskapp.GetQueueStatsQuery{
-		Queue: queue,
+		Queue: c.Query("queue"),
+	}
+	if raw := c.Query("queues"); raw != "" {
+		query.Queues = strings.Split(raw, ",")
+	}
+	switch unknown := c.DefaultQuery("unknown", "error"); unknown {
+	case "error":
+	case "skip":
+		query.SkipUnknown = true
+	default:
+		render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+			Error: fmt.Sprintf("unknown must be error or skip, got %q", unknown),
+			Code:  "INVALID_QUEUES",
+		})
+		return
 	}
 
 	stats, err := h.service.GetQueueStats(c.Request.Context(), query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
-			Error: err.Error(),
-			Code:  "STATS_FAILED",
+		status := http.StatusInternalServerError
+		code := "STATS_FAILED"
+		switch {
+		case apperrors.IsValidationError(err):
+			status = http.StatusBadRequest
+			code = "INVALID_QUEUES"
+		case errors.Is(err, apperrors.ErrInvalidQueue):
+			status = http.StatusBadRequest
+			code = "INVALID_QUEUE"
+		case errors.Is(err, apperrors.ErrQueueNotFound):
+			status = http.StatusNotFound
+			code = "QUEUE_NOT_FOUND"
+		}
+		render.JSON(c, status, dto.ErrorResponse{
+			Error:   err.Error(),
+			Code:    code,
+			Details: queueErrorDetails(err),
 		})
 		return
 	}
 
+	render.JSON(c, http.StatusOK, queueStatsResponse(stats))
+}
+
+func queueStatsResponse(stats []asynqqueue.QueueStats) []dto.QueueStatsResponse {
 	response := make([]dto.QueueStatsResponse, len(stats))
 	for i, s := range stats {
 		response[i] = dto.QueueStatsResponse{
@@ -243,10 +977,169 @@ func (h *TaskHandler) GetQueueStats(c *gin.Context) {
 			Retry:     s.Retry,
 			Archived:  s.Archived,
 			Completed: s.Completed,
+
+			Aggregating: s.Aggregating,
+			Groups:      s.Groups,
+		}
+	}
+	return response
+}
+
+func (h *TaskHandler) ListGroups(c *gin.Context) {
+	query := &taskapp.ListGroupsQuery{Queue: c.Param("name")}
+
+	groups, err := h.service.ListGroups(c.Request.Context(), query)
+	if err != nil {
+		status := http.StatusInternalServerError
+		code := "LIST_GROUPS_FAILED"
+		switch {
+		case errors.Is(err, apperrors.ErrInvalidQueue):
+			status = http.StatusBadRequest
+			code = "INVALID_QUEUE"
+		case errors.Is(err, apperrors.ErrQueueNotFound):
+			status = http.StatusNotFound
+			code = "QUEUE_NOT_FOUND"
+		}
+		render.JSON(c, status, dto.ErrorResponse{
+			Error:   err.Error(),
+			Code:    code,
+			Details: queueErrorDetails(err),
+		})
+		return
+	}
+
+	response := make([]dto.GroupResponse, len(groups))
+	for i, g := range groups {
+		response[i] = dto.GroupResponse{
+			Group: g.Group,
+			Size:  g.Size,
+		}
+	}
+
+	render.JSON(c, http.StatusOK, response)
+}
+
+func (h *TaskHandler) GetOldestTasks(c *gin.Context) {
+	query := &taskapp.OldestTasksQuery{Queue: c.Param("name")}
+
+	oldest, err := h.service.OldestTasks(c.Request.Context(), query)
+	if err != nil {
+		status := http.StatusInternalServerError
+		code := "GET_OLDEST_TASKS_FAILED"
+		switch {
+		case errors.Is(err, apperrors.ErrInvalidQueue):
+			status = http.StatusBadRequest
+			code = "INVALID_QUEUE"
+		case errors.Is(err, apperrors.ErrQueueNotFound):
+			status = http.StatusNotFound
+			code = "QUEUE_NOT_FOUND"
+		}
+		render.JSON(c, status, dto.ErrorResponse{
+			Error:   err.Error(),
+			Code:    code,
+			Details: queueErrorDetails(err),
+		})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, dto.OldestTasksResponse{
+		Queue:     oldest.Queue,
+		Pending:   oldestTaskResponse(oldest.Pending),
+		Scheduled: oldestTaskResponse(oldest.Scheduled),
+	})
+}
+
+func oldestTaskResponse(t *asynqqueue.OldestTask) *dto.OldestTaskResponse {
+	if t == nil {
+		return nil
+	}
+	resp := &dto.OldestTaskResponse{
+		ID:         t.ID,
+		Type:       t.Type,
+		AgeSeconds: int64(t.Age.Seconds()),
+	}
+	if !t.NextProcessAt.IsZero() {
+		resp.NextProcessAt = t.NextProcessAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+func (h *TaskHandler) FlushGroup(c *gin.Context) {
+	dryRun, err := parseDryRun(c)
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+			Code:  "INVALID_DRY_RUN",
+		})
+		return
+	}
+
+	cmd := &taskapp.FlushGroupCommand{
+		Queue:  c.Param("name"),
+		Group:  c.Param("group"),
+		DryRun: dryRun,
+	}
+
+	result, err := h.service.FlushGroup(auditContext(c), cmd)
+	if err != nil {
+		status := http.StatusInternalServerError
+		code := "FLUSH_GROUP_FAILED"
+		switch {
+		case errors.Is(err, apperrors.ErrInvalidQueue):
+			status = http.StatusBadRequest
+			code = "INVALID_QUEUE"
+		case errors.Is(err, apperrors.ErrQueueNotFound):
+			status = http.StatusNotFound
+			code = "QUEUE_NOT_FOUND"
+		case errors.Is(err, apperrors.ErrGroupNotFound):
+			status = http.StatusNotFound
+			code = "GROUP_NOT_FOUND"
 		}
+		render.JSON(c, status, dto.ErrorResponse{
+			Error:   err.Error(),
+			Code:    code,
+			Details: queueErrorDetails(err),
+		})
+		return
+	}
+
+	message := "group tasks moved to pending"
+	if result.DryRun {
+		message = "dry run: group tasks would be moved to pending"
 	}
+	render.JSON(c, http.StatusOK, dto.FlushGroupResponse{
+		Message: message,
+		Queue:   result.Queue,
+		Group:   result.Group,
+		Size:    result.Affected,
+		DryRun:  result.DryRun,
+		TaskIDs: result.TaskIDs,
+	})
+}
 
-	c.JSON(http.StatusOK, response)
+// parseDryRun 解析批量写操作的 dry_run 参数，省略时为 false
+func parseDryRun(c *gin.Context) (bool, error) {
+	raw := c.Query("dry_run")
+	if raw == "" {
+		return false, nil
+	}
+	dryRun, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("dry_run must be true or false, got %q", raw)
+	}
+	return dryRun, nil
+}
+
+// auditContext 将调用方信息放入请求 context，供写操作的审计日志使用
+func auditContext(c *gin.Context) context.Context {
+	actor := taskapp.AuditActor{
+		RequestID:  c.GetString("request_id"),
+		RemoteAddr: c.ClientIP(),
+	}
+	if key := middleware.CurrentAPIKey(c); key != nil {
+		actor.APIKey = key.Name
+	}
+	return taskapp.WithAuditActor(c.Request.Context(), actor)
 }
 
 func (h *TaskHandler) ListTasks(c *gin.Context) {
@@ -255,7 +1148,18 @@ func (h *TaskHandler) ListTasks(c *gin.Context) {
 		queue = "default"
 	}
 
+	// state 与列表项中的字段同名，作为 status 的别名
 	status := c.Query("status")
+	if state := c.Query("state"); state != "" {
+		if status != "" && status != state {
+			render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+				Error: fmt.Sprintf("status %q and state %q disagree", status, state),
+				Code:  "INVALID_TASK_STATE",
+			})
+			return
+		}
+		status = state
+	}
 
 	page := 0
 	if value := c.Query("page"); value != "" {
@@ -271,17 +1175,51 @@ func (h *TaskHandler) ListTasks(c *gin.Context) {
 		}
 	}
 
+	// match=key:value 可重复，所有条件同时满足才返回
+	var match map[string]string
+	for _, value := range c.QueryArray("match") {
+		key, want, ok := strings.Cut(value, ":")
+		if !ok {
+			render.JSON(c, http.StatusBadRequest, dto.ErrorResponse{
+				Error: fmt.Sprintf("match %q must be in key:value form", value),
+				Code:  "INVALID_MATCH",
+			})
+			return
+		}
+		if match == nil {
+			match = make(map[string]string)
+		}
+		match[key] = want
+	}
+
 	query := &taskapp.ListTasksQuery{
-		Queue:  queue,
-		Status: status,
-		Page:   page,
-		Size:   size,
+		Queue:   queue,
+		Status:  status,
+		Page:    page,
+		Size:    size,
+		Match:   match,
+		Payload: c.Query("include_payload"),
+
+		ResultStatus: c.Query("result_status"),
 	}
 
 	result, err := h.service.ListTasks(c.Request.Context(), query)
 	if err != nil {
 		status := http.StatusInternalServerError
 		code := "LIST_TASKS_FAILED"
+		if apperrors.IsValidationError(err) {
+			status = http.StatusBadRequest
+			code = "INVALID_MATCH"
+			var verr *apperrors.ValidationError
+			if errors.As(err, &verr) {
+				switch verr.Field {
+				case "include_payload":
+					code = "INVALID_INCLUDE_PAYLOAD"
+				case "result_status":
+					code = "INVALID_RESULT_STATUS"
+				}
+			}
+		}
 		if errors.Is(err, apperrors.ErrInvalidQueue) {
 			status = http.StatusBadRequest
 			code = "INVALID_QUEUE"
@@ -290,22 +1228,52 @@ func (h *TaskHandler) ListTasks(c *gin.Context) {
 			status = http.StatusBadRequest
 			code = "INVALID_TASK_STATE"
 		}
-		c.JSON(status, dto.ErrorResponse{
-			Error: err.Error(),
-			Code:  code,
+		render.JSON(c, status, dto.ErrorResponse{
+			Error:   err.Error(),
+			Code:    code,
+			Details: queueErrorDetails(err),
 		})
 		return
 	}
 
-	response := make([]dto.TaskListResponse, len(result))
-	for i, item := range result {
-		response[i] = dto.TaskListResponse{
-			ID:    item.ID,
-			Queue: item.Queue,
-			Type:  item.Type,
-			State: item.State,
+	// 过滤后的结果可能少于 size，是否还有下一页以 has_more 为准
+	c.Header("X-Tasks-Scanned", strconv.Itoa(result.Scanned))
+	response := dto.TaskListPageResponse{
+		Items:   make([]dto.TaskListResponse, len(result.Items)),
+		Page:    result.Page,
+		Size:    result.Size,
+		HasMore: result.HasMore,
+		Order:   result.Order,
+		Scanned: result.Scanned,
+	}
+	if result.TotalExact {
+		response.Total = &result.Total
+	} else {
+		response.EstimatedTotal = &result.Total
+	}
+	for i, item := range result.Items {
+		response.Items[i] = dto.TaskListResponse{
+			ID:      item.ID,
+			Queue:   item.Queue,
+			Type:    item.Type,
+			State:   item.State,
+			LastErr: item.LastErr,
+			Payload: payloadResponse(item.Payload),
 		}
 	}
 
-	c.JSON(http.StatusOK, response)
+	render.JSON(c, http.StatusOK, response)
+}
+
+func payloadResponse(view *taskapp.PayloadView) *dto.PayloadResponse {
+	if view == nil {
+		return nil
+	}
+	return &dto.PayloadResponse{
+		Size:      view.Size,
+		Preview:   view.Preview,
+		Truncated: view.Truncated,
+		Redacted:  view.Redacted,
+		Full:      view.Full,
+	}
 }
@@ -4,36 +4,75 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hibiken/asynq"
 	"go.uber.org/zap"
 
 	taskapp "github.com/Aixtrade/TaskFlow/internal/application/task"
+	"github.com/Aixtrade/TaskFlow/internal/config"
 	"github.com/Aixtrade/TaskFlow/internal/domain/task"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/blobstore"
 	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/dto"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/middleware"
+	apperrors "github.com/Aixtrade/TaskFlow/pkg/errors"
+	"github.com/Aixtrade/TaskFlow/pkg/payload"
+	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
 )
 
 type fakeClient struct {
+	getInfo    *asynq.TaskInfo
 	getInfoErr error
+
+	enqueued     *task.Task
+	enqueueCount int
+	ran          []string
+	archived     []string
+	archiveErr   error
+	cancelled    []string
+	oldest       *asynqqueue.OldestTasks
+
+	listed    []*asynq.TaskInfo
+	listQueue string
+	listState string
+	queueInfo *asynq.QueueInfo
+	queues    []string
+	allStats  []asynqqueue.QueueStats
 }
 
 func (f *fakeClient) Enqueue(ctx context.Context, t *task.Task, opts ...asynqqueue.EnqueueOptions) (*asynq.TaskInfo, error) {
-	return nil, nil
+	f.enqueued = t
+	f.enqueueCount++
+	return &asynq.TaskInfo{ID: t.ID, Queue: t.Queue, State: asynq.TaskStatePending, MaxRetry: t.MaxRetries, Timeout: t.Timeout}, nil
 }
 
 func (f *fakeClient) GetTaskInfo(queue, taskID string) (*asynq.TaskInfo, error) {
-	return nil, f.getInfoErr
+	return f.getInfo, f.getInfoErr
 }
 
 func (f *fakeClient) ListTasks(queue, state string, page, size int) ([]*asynq.TaskInfo, error) {
-	return nil, nil
+	f.listQueue, f.listState = queue, state
+	return f.listed, nil
 }
 
+// CancelTask 模拟 worker 立即停止：正在执行的任务进入 retry
 func (f *fakeClient) CancelTask(taskID string) error {
+	f.cancelled = append(f.cancelled, taskID)
+	if f.getInfo != nil && f.getInfo.State == asynq.TaskStateActive {
+		stopped := *f.getInfo
+		stopped.State = asynq.TaskStateRetry
+		f.getInfo = &stopped
+	}
 	return nil
 }
 
@@ -41,20 +80,70 @@ func (f *fakeClient) DeleteTask(queue, taskID string) error {
 	return nil
 }
 
+func (f *fakeClient) ArchiveTask(queue, taskID string) error {
+	if f.archiveErr != nil {
+		return f.archiveErr
+	}
+	f.archived = append(f.archived, queue+"/"+taskID)
+	return nil
+}
+
+func (f *fakeClient) RunTask(queue, taskID string) error {
+	f.ran = append(f.ran, queue+"/"+taskID)
+	return nil
+}
+
+func (f *fakeClient) EnqueueCopy(ctx context.Context, info *asynq.TaskInfo, taskID string) (*asynq.TaskInfo, error) {
+	return &asynq.TaskInfo{ID: taskID, Queue: info.Queue, State: asynq.TaskStatePending}, nil
+}
+
 func (f *fakeClient) GetQueueInfo(queue string) (*asynq.QueueInfo, error) {
-	return nil, nil
+	return f.queueInfo, nil
+}
+
+func (f *fakeClient) GetQueues() ([]string, error) {
+	return f.queues, nil
 }
 
 func (f *fakeClient) GetAllQueueStats() ([]asynqqueue.QueueStats, error) {
+	return f.allStats, nil
+}
+
+func (f *fakeClient) ListGroups(queue string) ([]asynqqueue.GroupStats, error) {
 	return nil, nil
 }
 
+func (f *fakeClient) FlushGroup(queue, group string, dryRun bool) ([]string, error) {
+	return nil, apperrors.ErrGroupNotFound
+}
+
+func (f *fakeClient) OldestTasks(queue string) (*asynqqueue.OldestTasks, error) {
+	if f.oldest != nil {
+		return f.oldest, nil
+	}
+	return &asynqqueue.OldestTasks{Queue: queue}, nil
+}
+
+func (f *fakeClient) UniqueTTL(info *asynq.TaskInfo) (time.Duration, error) {
+	return 0, nil
+}
+
 func setupTaskRouter(service *taskapp.Service) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 	h := NewTaskHandler(service)
 	r.POST("/api/v1/tasks", h.Create)
+	r.POST("/api/v1/tasks/batch", h.CreateBatch)
+	r.GET("/api/v1/tasks", h.ListTasks)
 	r.GET("/api/v1/tasks/:id", h.Get)
+	r.GET("/api/v1/tasks/:id/result", h.Result)
+	r.POST("/api/v1/tasks/:id/archive", h.Archive)
+	r.POST("/api/v1/tasks/:id/run", h.Run)
+	r.GET("/api/v1/tasks/:id/artifacts/:name", h.Artifact)
+	r.POST("/api/v1/queues/:name/groups/:group/flush", h.FlushGroup)
+	r.GET("/api/v1/queues/:name/oldest", h.GetOldestTasks)
+	r.GET("/api/v1/queues/stats", h.GetQueueStats)
+	r.POST("/api/v1/tasks/upload", middleware.BodyLimit(1024), h.Upload)
 	return r
 }
 
@@ -79,6 +168,61 @@ func TestTaskHandlerGetNotFound(t *testing.T) {
 	}
 }
 
+func TestTaskHandlerListTasks(t *testing.T) {
+	fake := &fakeClient{
+		listed: []*asynq.TaskInfo{
+			{ID: "a", Queue: "default", Type: "demo", State: asynq.TaskStatePending},
+			{ID: "b", Queue: "default", Type: "demo", State: asynq.TaskStatePending},
+		},
+		queueInfo: &asynq.QueueInfo{Queue: "default", Pending: 5},
+	}
+	service := taskapp.NewService(fake, zap.NewNop())
+	r := setupTaskRouter(service)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tasks?state=pending&page=0&size=2", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if fake.listQueue != "default" || fake.listState != "pending" {
+		t.Fatalf("expected the default queue and pending state, got %q/%q", fake.listQueue, fake.listState)
+	}
+	var body dto.TaskListPageResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(body.Items) != 2 || body.Items[0].ID != "a" || body.Items[0].State != "pending" {
+		t.Fatalf("unexpected items: %+v", body.Items)
+	}
+	if body.Page != 0 || body.Size != 2 || body.Total == nil || *body.Total != 5 || !body.HasMore {
+		t.Fatalf("unexpected pagination: %+v", body)
+	}
+}
+
+func TestTaskHandlerListTasksRejectsInvalidState(t *testing.T) {
+	service := taskapp.NewService(&fakeClient{}, zap.NewNop())
+	r := setupTaskRouter(service)
+
+	for _, query := range []string{"state=running", "status=pending&state=active"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tasks?"+query, nil)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", query, resp.Code)
+		}
+		var body dto.ErrorResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if body.Code != "INVALID_TASK_STATE" {
+			t.Fatalf("%s: expected INVALID_TASK_STATE, got %s", query, body.Code)
+		}
+	}
+}
+
 func TestTaskHandlerCreateInvalidRequest(t *testing.T) {
 	service := taskapp.NewService(&fakeClient{}, zap.NewNop())
 	r := setupTaskRouter(service)
@@ -101,3 +245,854 @@ func TestTaskHandlerCreateInvalidRequest(t *testing.T) {
 		t.Fatalf("expected INVALID_REQUEST, got %s", body["code"])
 	}
 }
+
+func TestTaskHandlerCreateRejectsInvalidDemoPayload(t *testing.T) {
+	fake := &fakeClient{}
+	service := taskapp.NewService(fake, zap.NewNop())
+	r := setupTaskRouter(service)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks",
+		bytes.NewBufferString(`{"type":"demo","payload":{"message":"hi","count":0}}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var body struct {
+		Code    string            `json:"code"`
+		Details map[string]string `json:"details"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if body.Code != "INVALID_PAYLOAD" || body.Details["field"] != "count" || body.Details["task_type"] != "demo" {
+		t.Fatalf("expected INVALID_PAYLOAD on demo count, got %s", resp.Body.String())
+	}
+	if fake.enqueued != nil {
+		t.Fatal("expected task not to be enqueued")
+	}
+}
+
+func TestTaskHandlerCreateReportsDeprecation(t *testing.T) {
+	cutoff := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	deprecation := taskapp.Deprecation{Name: "demo-retired", Type: tasktype.Demo, Message: "use grpc_task", Cutoff: cutoff}
+	fake := &fakeClient{}
+	r := setupTaskRouter(taskapp.NewService(fake, zap.NewNop(), taskapp.WithDeprecations([]taskapp.Deprecation{deprecation}, nil)))
+
+	create := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks",
+			bytes.NewBufferString(`{"type":"demo","payload":{"message":"hi","count":1}}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := create()
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if got := resp.Header().Get("Warning"); !strings.HasPrefix(got, "299 taskflow ") || !strings.Contains(got, "demo-retired") {
+		t.Fatalf("expected deprecation warning header, got %q", got)
+	}
+	if got := resp.Header().Get("Sunset"); got != "Tue, 01 Jan 2030 00:00:00 GMT" {
+		t.Fatalf("expected sunset header, got %q", got)
+	}
+	var created struct {
+		Deprecations []map[string]any `json:"deprecations"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(created.Deprecations) != 1 || created.Deprecations[0]["name"] != "demo-retired" || created.Deprecations[0]["cutoff"] != "2030-01-01T00:00:00Z" {
+		t.Fatalf("unexpected deprecations field: %s", resp.Body.String())
+	}
+
+	// 过了截止时间后拒绝
+	deprecation.Cutoff = time.Now().Add(-time.Minute)
+	fake.enqueued = nil
+	r = setupTaskRouter(taskapp.NewService(fake, zap.NewNop(), taskapp.WithDeprecations([]taskapp.Deprecation{deprecation}, nil)))
+	resp = create()
+	if resp.Code != http.StatusGone {
+		t.Fatalf("expected status 410, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var body struct {
+		Code    string            `json:"code"`
+		Details map[string]string `json:"details"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if body.Code != "TASK_DEPRECATED" || body.Details["deprecation"] != "demo-retired" || body.Details["message"] != "use grpc_task" {
+		t.Fatalf("unexpected error response: %s", resp.Body.String())
+	}
+	if fake.enqueued != nil {
+		t.Fatal("expected task not to be enqueued")
+	}
+}
+
+func TestTaskHandlerCreateRejectsUnknownQueue(t *testing.T) {
+	fake := &fakeClient{}
+	r := setupTaskRouter(taskapp.NewService(fake, zap.NewNop(), taskapp.WithQueues([]string{"default", "low"})))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks",
+		bytes.NewBufferString(`{"type":"demo","payload":{"message":"hi","count":1},"queue":"Lowest"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var body struct {
+		Code    string `json:"code"`
+		Details struct {
+			Queue       string   `json:"queue"`
+			ValidQueues []string `json:"valid_queues"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if body.Code != "INVALID_QUEUE" || body.Details.Queue != "lowest" || strings.Join(body.Details.ValidQueues, ",") != "default,low" {
+		t.Fatalf("expected INVALID_QUEUE listing valid queues, got %s", resp.Body.String())
+	}
+	if fake.enqueued != nil {
+		t.Fatal("expected task not to be enqueued")
+	}
+}
+
+func postBatch(r *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks/batch", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestTaskHandlerCreateBatchReportsPerItemFailures(t *testing.T) {
+	fake := &fakeClient{}
+	r := setupTaskRouter(taskapp.NewService(fake, zap.NewNop()))
+
+	const id = "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+	resp := postBatch(r, `[
+		{"type":"demo","payload":{"message":"hi","count":1}},
+		{"type":"demo","payload":{"message":"hi","count":0}},
+		{"payload":{"message":"hi","count":1}},
+		{"type":"demo","payload":{"message":"hi","count":1},"timeout":"soon"},
+		{"type":"demo","payload":{"message":"hi","count":1},"task_id":"`+id+`"},
+		{"type":"demo","payload":{"message":"again","count":1},"task_id":"`+id+`"}
+	]`)
+	if resp.Code != http.StatusMultiStatus {
+		t.Fatalf("expected status 207, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	var items []struct {
+		TaskID  string            `json:"task_id"`
+		Code    string            `json:"code"`
+		Details map[string]string `json:"details"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &items); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(items) != 6 {
+		t.Fatalf("expected one result per item, got %s", resp.Body.String())
+	}
+	if items[0].TaskID == "" || items[0].Code != "" {
+		t.Fatalf("expected first item to be created, got %+v", items[0])
+	}
+	if items[1].Code != "INVALID_PAYLOAD" || items[1].Details["field"] != "count" {
+		t.Fatalf("expected INVALID_PAYLOAD on count, got %+v", items[1])
+	}
+	if items[2].Code != "INVALID_REQUEST" {
+		t.Fatalf("expected INVALID_REQUEST for missing type, got %+v", items[2])
+	}
+	if items[3].Code != "INVALID_TIMEOUT" {
+		t.Fatalf("expected INVALID_TIMEOUT, got %+v", items[3])
+	}
+	if items[4].TaskID != id {
+		t.Fatalf("expected first occurrence of the task id to be created, got %+v", items[4])
+	}
+	if items[5].Code != "TASK_ALREADY_EXISTS" {
+		t.Fatalf("expected TASK_ALREADY_EXISTS for the duplicate, got %+v", items[5])
+	}
+	if fake.enqueueCount != 2 {
+		t.Fatalf("expected only the valid items to be enqueued, got %d", fake.enqueueCount)
+	}
+}
+
+func TestTaskHandlerCreateBatchAllCreated(t *testing.T) {
+	r := setupTaskRouter(taskapp.NewService(&fakeClient{}, zap.NewNop()))
+
+	resp := postBatch(r, `[{"type":"demo","payload":{"message":"a","count":1}},{"type":"demo","payload":{"message":"b","count":1}}]`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var items []dto.CreateTaskResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &items); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(items) != 2 || items[0].TaskID == "" || items[0].TaskID == items[1].TaskID || items[1].ProgressURL == "" {
+		t.Fatalf("unexpected batch response %s", resp.Body.String())
+	}
+}
+
+func TestTaskHandlerCreateBatchRejectsWholeRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fake := &fakeClient{}
+	r := gin.New()
+	r.POST("/api/v1/tasks/batch", NewTaskHandler(taskapp.NewService(fake, zap.NewNop()), WithMaxBatchSize(2)).CreateBatch)
+
+	item := `{"type":"demo","payload":{"message":"hi","count":1}}`
+	tests := []struct {
+		body string
+		code string
+	}{
+		{body: `[` + item + `,` + item + `,` + item + `]`, code: "BATCH_TOO_LARGE"},
+		{body: `[]`, code: "INVALID_REQUEST"},
+		{body: item, code: "INVALID_REQUEST"},
+	}
+	for _, tt := range tests {
+		resp := postBatch(r, tt.body)
+		if resp.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", tt.body, resp.Code)
+		}
+		var body dto.ErrorResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if body.Code != tt.code {
+			t.Fatalf("%s: expected %s, got %s", tt.body, tt.code, body.Code)
+		}
+	}
+	if fake.enqueueCount != 0 {
+		t.Fatalf("expected nothing to be enqueued, got %d", fake.enqueueCount)
+	}
+}
+
+func TestTaskHandlerCreateReturnsLinks(t *testing.T) {
+	service := taskapp.NewService(&fakeClient{}, zap.NewNop())
+
+	create := func(r *gin.Engine) dto.CreateTaskResponse {
+		req := httptest.NewRequest(http.MethodPost, "http://10.0.0.5:8080/api/v1/tasks",
+			bytes.NewBufferString(`{"type":"demo","payload":{"message":"hi","count":1}}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "api.example.com, 10.0.0.5")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d: %s", resp.Code, resp.Body.String())
+		}
+		var created dto.CreateTaskResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &created); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		return created
+	}
+
+	// 不是来自可信代理时忽略转发头部，使用请求本身的 Host
+	created := create(setupTaskRouter(service))
+	if !strings.HasPrefix(created.TaskURL, "http://10.0.0.5:8080/api/v1/tasks/") {
+		t.Fatalf("expected forwarded headers from an untrusted peer to be ignored, got %+v", created)
+	}
+
+	// httptest 请求来自 192.0.2.1
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/v1/tasks", NewTaskHandler(service, WithTrustedProxies([]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")})).Create)
+	created = create(r)
+	base := "https://api.example.com/api/v1/tasks/" + created.TaskID
+	if created.TaskURL != base+"?queue=default" || created.ProgressURL != base+"/progress" || created.ProgressStreamURL != base+"/progress/stream" {
+		t.Fatalf("unexpected links from forwarded headers: %+v", created)
+	}
+
+	r = gin.New()
+	r.POST("/api/v1/tasks", NewTaskHandler(service, WithPublicURL("https://taskflow.example.com/")).Create)
+	created = create(r)
+	if !strings.HasPrefix(created.TaskURL, "https://taskflow.example.com/api/v1/tasks/") || !strings.HasPrefix(created.ProgressStreamURL, "https://taskflow.example.com/") {
+		t.Fatalf("expected links under the public URL, got %+v", created)
+	}
+}
+
+func TestTaskHandlerOmitsProgressLinksWhenProgressDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := NewTaskHandler(taskapp.NewService(&fakeClient{}, zap.NewNop()), WithProgressLinks(false))
+	r.POST("/api/v1/tasks", h.Create)
+	r.POST("/api/v1/tasks/batch", h.CreateBatch)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewBufferString(`{"type":"demo","payload":{"message":"hi","count":1}}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	body := resp.Body.String()
+	if strings.Contains(body, "progress_url") || strings.Contains(body, "progress_stream_url") || !strings.Contains(body, "task_url") {
+		t.Fatalf("expected task link only, got %s", body)
+	}
+
+	resp = postBatch(r, `[{"type":"demo","payload":{"message":"hi","count":1}}]`)
+	if strings.Contains(resp.Body.String(), "progress_url") {
+		t.Fatalf("expected no progress links in batch items, got %s", resp.Body.String())
+	}
+}
+
+func TestTaskHandlerCreateReturnsEffectiveOptions(t *testing.T) {
+	service := taskapp.NewService(&fakeClient{}, zap.NewNop(), taskapp.WithLimits(taskapp.Limits{
+		DefaultMaxRetries: 5,
+		DefaultTimeout:    10 * time.Minute,
+		TimeoutCap:        time.Hour,
+		TimeoutMode:       taskapp.LimitClamp,
+	}))
+	r := setupTaskRouter(service)
+
+	create := func(body string) dto.EffectiveOptions {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d: %s", resp.Code, resp.Body.String())
+		}
+		var created dto.CreateTaskResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &created); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		return created.Effective
+	}
+
+	// 省略的选项取默认值
+	eff := create(`{"type":"demo","payload":{"message":"hi","count":1}}`)
+	if eff.Queue != "default" || eff.MaxRetries != 5 || eff.TimeoutSeconds != 600 || eff.UniqueTTLSeconds != 0 {
+		t.Fatalf("expected defaults to be reported, got %+v", eff)
+	}
+
+	// 超过上限的选项报告截断后的值
+	eff = create(`{"type":"demo","payload":{"message":"hi","count":1},"max_retries":2,"timeout":"2h","unique":"90s"}`)
+	if eff.MaxRetries != 2 || eff.TimeoutSeconds != 3600 || eff.UniqueTTLSeconds != 90 {
+		t.Fatalf("expected clamped options to be reported, got %+v", eff)
+	}
+}
+
+func TestTaskHandlerCreateEnforcesAPIKeyRestrictions(t *testing.T) {
+	fake := &fakeClient{}
+	service := taskapp.NewService(fake, zap.NewNop())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.APIKeyAuth(middleware.NewAPIKeys([]config.APIKeyConfig{{
+		Name:            "partner",
+		Key:             "secret",
+		DefaultQueue:    "low",
+		AllowedTypes:    []string{"grpc_task"},
+		AllowedQueues:   []string{"low"},
+		AllowedServices: []string{"data"},
+	}})))
+	r.POST("/api/v1/tasks", NewTaskHandler(service).Create)
+	r.GET("/api/v1/me", Me)
+
+	create := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := create("wrong", `{"type":"grpc_task","payload":{"service":"data"}}`); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for unknown key, got %d", resp.Code)
+	}
+
+	resp := create("secret", `{"type":"grpc_task","payload":{"service":"llm"}}`)
+	if resp.Code != http.StatusForbidden || !strings.Contains(resp.Body.String(), "allowed_services") {
+		t.Fatalf("expected 403 naming allowed_services, got %d: %s", resp.Code, resp.Body.String())
+	}
+	resp = create("secret", `{"type":"demo","payload":{"message":"hi","count":1}}`)
+	if resp.Code != http.StatusForbidden || !strings.Contains(resp.Body.String(), "allowed_types") {
+		t.Fatalf("expected 403 naming allowed_types, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := create("secret", `{"type":"grpc_task","queue":"high","payload":{"service":"data"}}`); resp.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for disallowed queue, got %d", resp.Code)
+	}
+
+	if resp := create("secret", `{"type":"grpc_task","payload":{"service":"data"}}`); resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if fake.enqueued.Queue != "low" {
+		t.Fatalf("expected key default queue, got %q", fake.enqueued.Queue)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+	req.Header.Set("X-API-Key", "secret")
+	meResp := httptest.NewRecorder()
+	r.ServeHTTP(meResp, req)
+	if !strings.Contains(meResp.Body.String(), `"name":"partner"`) || strings.Contains(meResp.Body.String(), "secret") {
+		t.Fatalf("expected key restrictions without the key itself, got %s", meResp.Body.String())
+	}
+}
+
+type memoryStore struct {
+	objects map[string][]byte
+}
+
+func (m *memoryStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (*blobstore.Object, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	m.objects[key] = data
+	return &blobstore.Object{Key: key, ContentType: contentType, Size: int64(len(data)), URI: "mem://" + key}, nil
+}
+
+func (m *memoryStore) Open(ctx context.Context, key string) (io.ReadCloser, *blobstore.Object, error) {
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, nil, blobstore.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), &blobstore.Object{Key: key, Size: int64(len(data))}, nil
+}
+
+func (m *memoryStore) Delete(ctx context.Context, key string) error {
+	delete(m.objects, key)
+	return nil
+}
+
+func newUploadRequest(t *testing.T, content string) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	_ = w.WriteField("type", "demo")
+	_ = w.WriteField("payload", `{"message":"hi"}`)
+	part, err := w.CreateFormFile("file", "input.txt")
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	_, _ = part.Write([]byte(content))
+	_ = w.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks/upload", body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestTaskHandlerUploadStoresFileAndReferencesBlob(t *testing.T) {
+	fake := &fakeClient{}
+	store := &memoryStore{objects: map[string][]byte{}}
+	service := taskapp.NewService(fake, zap.NewNop(), taskapp.WithBlobStore(store))
+	r := setupTaskRouter(service)
+
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, newUploadRequest(t, "hello"))
+
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if len(store.objects) != 1 {
+		t.Fatalf("expected 1 stored object, got %d", len(store.objects))
+	}
+
+	var p struct {
+		Message string          `json:"message"`
+		File    payload.BlobRef `json:"file"`
+	}
+	if err := json.Unmarshal(fake.enqueued.Payload, &p); err != nil {
+		t.Fatalf("failed to parse payload: %v", err)
+	}
+	if p.Message != "hi" || p.File.Filename != "input.txt" || p.File.Size != 5 {
+		t.Fatalf("unexpected payload: %+v", p)
+	}
+}
+
+func TestTaskHandlerUploadTooLarge(t *testing.T) {
+	store := &memoryStore{objects: map[string][]byte{}}
+	service := taskapp.NewService(&fakeClient{}, zap.NewNop(), taskapp.WithBlobStore(store))
+	r := setupTaskRouter(service)
+
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, newUploadRequest(t, strings.Repeat("x", 2048)))
+
+	if resp.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413, got %d", resp.Code)
+	}
+	if len(store.objects) != 0 {
+		t.Fatalf("expected no stored objects, got %d", len(store.objects))
+	}
+}
+
+func TestTaskHandlerResultStreamsBlob(t *testing.T) {
+	fake := &fakeClient{getInfoErr: asynq.ErrTaskNotFound}
+	store := &memoryStore{objects: map[string][]byte{
+		blobstore.ResultKey("123"): []byte(`{"rows":42}`),
+	}}
+	service := taskapp.NewService(fake, zap.NewNop(), taskapp.WithBlobStore(store))
+	r := setupTaskRouter(service)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tasks/123/result", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp.Body.String() != `{"rows":42}` {
+		t.Fatalf("unexpected body %s", resp.Body.String())
+	}
+}
+
+// presigningStore 模拟 S3 兼容存储
+type presigningStore struct {
+	memoryStore
+}
+
+func (p *presigningStore) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "https://bucket.example.com/" + key + "?expires=" + ttl.String(), nil
+}
+
+func TestTaskHandlerArtifact(t *testing.T) {
+	result := `{"result":{"rows":42},"artifacts":[` +
+		`{"name":"report.csv","content_type":"text/csv","key":"artifacts/123/report.csv"},` +
+		`{"name":"dataset","uri":"https://cdn.example.com/dataset.parquet"},` +
+		`{"name":"raw","uri":"s3://bucket/raw.bin"},` +
+		`{"name":"foreign","key":"results/456.json"}]}`
+	fake := &fakeClient{getInfo: &asynq.TaskInfo{ID: "123", Queue: "default", Result: []byte(result)}}
+
+	get := func(store blobstore.Store, name string) *httptest.ResponseRecorder {
+		var opts []taskapp.Option
+		if store != nil {
+			opts = append(opts, taskapp.WithBlobStore(store))
+		}
+		r := setupTaskRouter(taskapp.NewService(fake, zap.NewNop(), opts...))
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/tasks/123/artifacts/"+name, nil))
+		return resp
+	}
+	objects := func() map[string][]byte {
+		return map[string][]byte{"artifacts/123/report.csv": []byte("a,b\n1,2\n")}
+	}
+
+	resp := get(&memoryStore{objects: objects()}, "report.csv")
+	if resp.Code != http.StatusOK || resp.Body.String() != "a,b\n1,2\n" {
+		t.Fatalf("expected streamed artifact, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if got := resp.Header().Get("Content-Type"); got != "text/csv" {
+		t.Fatalf("expected text/csv, got %q", got)
+	}
+	if got := resp.Header().Get("Content-Disposition"); got != "attachment; filename=report.csv" {
+		t.Fatalf("unexpected Content-Disposition %q", got)
+	}
+
+	resp = get(&presigningStore{memoryStore{objects: objects()}}, "report.csv")
+	if resp.Code != http.StatusFound || resp.Header().Get("Location") != "https://bucket.example.com/artifacts/123/report.csv?expires=15m0s" {
+		t.Fatalf("expected presigned redirect, got %d %q", resp.Code, resp.Header().Get("Location"))
+	}
+
+	resp = get(nil, "dataset")
+	if resp.Code != http.StatusFound || resp.Header().Get("Location") != "https://cdn.example.com/dataset.parquet" {
+		t.Fatalf("expected redirect to uri, got %d %q", resp.Code, resp.Header().Get("Location"))
+	}
+
+	// 任务前缀之外的 key 即使存在也不读取
+	resp = get(&presigningStore{memoryStore{objects: map[string][]byte{"results/456.json": []byte("{}")}}}, "foreign")
+	if resp.Code == http.StatusFound || resp.Code == http.StatusOK {
+		t.Fatalf("expected a key outside the task prefix to be refused, got %d %q", resp.Code, resp.Header().Get("Location"))
+	}
+
+	for name, want := range map[string]string{"raw": "ARTIFACT_UNAVAILABLE", "foreign": "ARTIFACT_UNAVAILABLE", "missing": "ARTIFACT_NOT_FOUND"} {
+		resp = get(nil, name)
+		var body map[string]string
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if body["code"] != want {
+			t.Fatalf("%s: expected %s, got %d %s", name, want, resp.Code, body["code"])
+		}
+	}
+}
+
+func TestTaskHandlerResultNotFound(t *testing.T) {
+	fake := &fakeClient{getInfo: &asynq.TaskInfo{ID: "123", Queue: "default", State: asynq.TaskStateActive}}
+	service := taskapp.NewService(fake, zap.NewNop())
+	r := setupTaskRouter(service)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tasks/123/result", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", resp.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if body["code"] != "RESULT_NOT_FOUND" {
+		t.Fatalf("expected RESULT_NOT_FOUND, got %s", body["code"])
+	}
+}
+
+func TestTaskHandlerRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		info   *asynq.TaskInfo
+		err    error
+		status int
+		code   string
+	}{
+		{name: "scheduled", info: &asynq.TaskInfo{ID: "t1", Queue: "low", State: asynq.TaskStateScheduled}, status: http.StatusOK},
+		{name: "not found", err: asynq.ErrTaskNotFound, status: http.StatusNotFound, code: "TASK_NOT_FOUND"},
+		{name: "active", info: &asynq.TaskInfo{ID: "t1", Queue: "low", State: asynq.TaskStateActive}, status: http.StatusConflict, code: "TASK_ACTIVE"},
+		{name: "completed", info: &asynq.TaskInfo{ID: "t1", Queue: "low", State: asynq.TaskStateCompleted}, status: http.StatusConflict, code: "TASK_NOT_RUNNABLE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeClient{getInfo: tt.info, getInfoErr: tt.err}
+			r := setupTaskRouter(taskapp.NewService(fake, zap.NewNop()))
+
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/api/v1/tasks/t1/run?queue=low", nil))
+			if resp.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, resp.Code, resp.Body.String())
+			}
+
+			if tt.code == "" {
+				var body dto.RunTaskResponse
+				if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+					t.Fatalf("failed to parse response: %v", err)
+				}
+				if body.TaskID != "t1" || body.Queue != "low" || body.PreviousState != "scheduled" {
+					t.Fatalf("unexpected response %+v", body)
+				}
+				if len(fake.ran) != 1 || fake.ran[0] != "low/t1" {
+					t.Fatalf("expected low/t1 to run, got %v", fake.ran)
+				}
+				return
+			}
+			var body dto.ErrorResponse
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if body.Code != tt.code {
+				t.Fatalf("expected %s, got %s", tt.code, body.Code)
+			}
+			if len(fake.ran) != 0 {
+				t.Fatalf("expected nothing to run, got %v", fake.ran)
+			}
+		})
+	}
+}
+
+func TestTaskHandlerArchive(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		info   *asynq.TaskInfo
+		err    error
+		status int
+		code   string
+	}{
+		{name: "pending", path: "/api/v1/tasks/t1/archive?queue=Low", status: http.StatusOK},
+		{name: "invalid queue", path: "/api/v1/tasks/t1/archive?queue=bad%20queue", status: http.StatusBadRequest, code: "INVALID_QUEUE"},
+		{name: "not found", path: "/api/v1/tasks/t1/archive?queue=low", err: asynq.ErrTaskNotFound, status: http.StatusNotFound, code: "TASK_NOT_FOUND"},
+		{name: "active", path: "/api/v1/tasks/t1/archive?queue=low", err: errors.New("asynq: cannot archive task in active state"),
+			info: &asynq.TaskInfo{ID: "t1", Queue: "low", State: asynq.TaskStateActive}, status: http.StatusConflict, code: "TASK_ACTIVE"},
+		{name: "already archived", path: "/api/v1/tasks/t1/archive?queue=low", err: errors.New("asynq: task is already archived"),
+			info: &asynq.TaskInfo{ID: "t1", Queue: "low", State: asynq.TaskStateArchived}, status: http.StatusConflict, code: "TASK_NOT_ARCHIVABLE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeClient{getInfo: tt.info, archiveErr: tt.err}
+			r := setupTaskRouter(taskapp.NewService(fake, zap.NewNop()))
+
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, tt.path, nil))
+			if resp.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, resp.Code, resp.Body.String())
+			}
+
+			if tt.code == "" {
+				var body dto.ArchiveTaskResponse
+				if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+					t.Fatalf("failed to parse response: %v", err)
+				}
+				if body.TaskID != "t1" || body.Queue != "low" {
+					t.Fatalf("unexpected response %+v", body)
+				}
+				if len(fake.archived) != 1 || fake.archived[0] != "low/t1" {
+					t.Fatalf("expected low/t1 to be archived, got %v", fake.archived)
+				}
+				return
+			}
+			var body dto.ErrorResponse
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if body.Code != tt.code {
+				t.Fatalf("expected %s, got %s", tt.code, body.Code)
+			}
+		})
+	}
+}
+
+func TestTaskHandlerRequeueAppliesAPIKeyRestrictions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setup := func(info *asynq.TaskInfo) (*fakeClient, *gin.Engine) {
+		fake := &fakeClient{getInfo: info}
+		r := gin.New()
+		r.Use(middleware.APIKeyAuth(middleware.NewAPIKeys([]config.APIKeyConfig{{
+			Name:            "partner",
+			Key:             "secret",
+			AllowedTypes:    []string{"grpc_task"},
+			AllowedQueues:   []string{"low"},
+			AllowedServices: []string{"data"},
+			IDPrefix:        "partner-",
+		}})))
+		r.POST("/api/v1/tasks/:id/requeue", NewTaskHandler(taskapp.NewService(fake, zap.NewNop())).Requeue)
+		return fake, r
+	}
+	requeue := func(r *gin.Engine, queue string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks/t1/requeue?queue="+queue, nil)
+		req.Header.Set("X-API-Key", "secret")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	tests := []struct {
+		name        string
+		info        *asynq.TaskInfo
+		restriction string
+	}{
+		{name: "type", info: &asynq.TaskInfo{ID: "t1", Queue: "low", Type: "demo", Payload: []byte(`{}`)}, restriction: "allowed_types"},
+		{name: "queue", info: &asynq.TaskInfo{ID: "t1", Queue: "high", Type: "grpc_task", Payload: []byte(`{"service":"data"}`)}, restriction: "allowed_queues"},
+		{name: "service", info: &asynq.TaskInfo{ID: "t1", Queue: "low", Type: "grpc_task", Payload: []byte(`{"service":"llm"}`)}, restriction: "allowed_services"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.info.State = asynq.TaskStateActive
+			fake, r := setup(tt.info)
+
+			resp := requeue(r, tt.info.Queue)
+			var body dto.ErrorResponse
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if resp.Code != http.StatusForbidden || body.Code != "API_KEY_RESTRICTED" || !strings.Contains(resp.Body.String(), tt.restriction) {
+				t.Fatalf("expected 403 naming %s, got %d: %s", tt.restriction, resp.Code, resp.Body.String())
+			}
+			// 没有权限时不取消原任务
+			if len(fake.cancelled) != 0 || len(fake.archived) != 0 {
+				t.Fatalf("expected the task to be left alone, got cancelled=%v archived=%v", fake.cancelled, fake.archived)
+			}
+		})
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		fake, r := setup(&asynq.TaskInfo{ID: "t1", Queue: "low", Type: "grpc_task", Payload: []byte(`{"service":"data"}`), State: asynq.TaskStateActive})
+
+		resp := requeue(r, "low")
+		if resp.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+		}
+		var body dto.RequeueTaskResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if body.TaskID != "t1" || body.StoppedState != "retry" || !strings.HasPrefix(body.NewTaskID, "partner-") {
+			t.Fatalf("unexpected response %+v", body)
+		}
+		if len(fake.cancelled) != 1 || len(fake.archived) != 1 || fake.archived[0] != "low/t1" {
+			t.Fatalf("expected the original to be cancelled and archived, got cancelled=%v archived=%v", fake.cancelled, fake.archived)
+		}
+	})
+}
+
+func TestTaskHandlerFlushGroupNotFound(t *testing.T) {
+	service := taskapp.NewService(&fakeClient{queues: []string{"default"}}, zap.NewNop())
+	r := setupTaskRouter(service)
+
+	for url, want := range map[string]string{
+		"/api/v1/queues/default/groups/missing/flush": "GROUP_NOT_FOUND",
+		"/api/v1/queues/low/groups/g/flush":           "QUEUE_NOT_FOUND",
+	} {
+		req := httptest.NewRequest(http.MethodPost, url, nil)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected status 404, got %d", url, resp.Code)
+		}
+		if !strings.Contains(resp.Body.String(), want) {
+			t.Fatalf("%s: expected %s, got %s", url, want, resp.Body.String())
+		}
+	}
+}
+
+func TestTaskHandlerGetOldestTasks(t *testing.T) {
+	fake := &fakeClient{queues: []string{"high"}}
+	service := taskapp.NewService(fake, zap.NewNop())
+	r := setupTaskRouter(service)
+
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/queues/low/oldest", nil))
+	if resp.Code != http.StatusNotFound || !strings.Contains(resp.Body.String(), "QUEUE_NOT_FOUND") {
+		t.Fatalf("expected 404 QUEUE_NOT_FOUND for a missing queue, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/queues/high/oldest", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for an empty queue, got %d", resp.Code)
+	}
+	if body := resp.Body.String(); body != `{"queue":"high","pending":null,"scheduled":null}` {
+		t.Fatalf("expected null entries for an empty queue, got %s", body)
+	}
+
+	fake.oldest = &asynqqueue.OldestTasks{
+		Queue:   "high",
+		Pending: &asynqqueue.OldestTask{ID: "t1", Type: "demo", Age: 95 * time.Second},
+	}
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if !strings.Contains(resp.Body.String(), `"pending":{"id":"t1","type":"demo","age_seconds":95}`) {
+		t.Fatalf("expected oldest pending task, got %s", resp.Body.String())
+	}
+}
+
+func TestTaskHandlerFlushGroupRejectsInvalidDryRun(t *testing.T) {
+	service := taskapp.NewService(&fakeClient{}, zap.NewNop())
+	r := setupTaskRouter(service)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/queues/default/groups/g/flush?dry_run=maybe", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), "INVALID_DRY_RUN") {
+		t.Fatalf("expected 400 INVALID_DRY_RUN, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestTaskHandlerGetQueueStatsRejectsInvalidQueues(t *testing.T) {
+	service := taskapp.NewService(&fakeClient{}, zap.NewNop())
+	r := setupTaskRouter(service)
+
+	for url, want := range map[string]string{
+		"/api/v1/queues/stats?queues=default,,low":                "INVALID_QUEUES",
+		"/api/v1/queues/stats?queues=default&unknown=ignore":      "INVALID_QUEUES",
+		"/api/v1/queues/stats?queues=default&queue=low":           "INVALID_QUEUES",
+		"/api/v1/queues/stats?queues=default,ghost&unknown=error": "QUEUE_NOT_FOUND",
+	} {
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, url, nil))
+		if !strings.Contains(resp.Body.String(), want) {
+			t.Fatalf("%s: expected %s, got %d: %s", url, want, resp.Code, resp.Body.String())
+		}
+	}
+}
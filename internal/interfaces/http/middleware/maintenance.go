@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
+)
+
+// maintenanceRetryAfter 维护模式下建议客户端重试写操作的间隔
+const maintenanceRetryAfter = 60 * time.Second
+
+// MaintenanceMode 只读维护模式的开关，可在运行中切换；只作用于当前 API 实例
+type MaintenanceMode struct {
+	mu      sync.RWMutex
+	enabled bool
+	message string
+	since   time.Time
+}
+
+// MaintenanceState 维护模式的当前状态
+type MaintenanceState struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+	// Since 最近一次进入维护模式的时间（RFC 3339），未开启时为空
+	Since string `json:"since,omitempty"`
+}
+
+// NewMaintenanceMode 创建维护模式开关，enabled 为启动时的状态
+func NewMaintenanceMode(enabled bool, message string) *MaintenanceMode {
+	m := &MaintenanceMode{}
+	m.Set(enabled, message)
+	return m
+}
+
+// Set 切换维护模式，返回切换后的状态
+func (m *MaintenanceMode) Set(enabled bool, message string) MaintenanceState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if enabled && !m.enabled {
+		m.since = time.Now()
+	}
+	if !enabled {
+		m.since = time.Time{}
+		message = ""
+	}
+	m.enabled = enabled
+	m.message = message
+	return m.state()
+}
+
+// State 返回当前状态
+func (m *MaintenanceMode) State() MaintenanceState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state()
+}
+
+func (m *MaintenanceMode) state() MaintenanceState {
+	state := MaintenanceState{Enabled: m.enabled, Message: m.message}
+	if m.enabled {
+		state.Since = m.since.Format(time.RFC3339)
+	}
+	return state
+}
+
+// Maintenance 维护模式下拒绝写请求（GET、HEAD、OPTIONS 以外的方法），返回 503 和 MAINTENANCE 错误码，读请求照常处理
+func Maintenance(mode *MaintenanceMode) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		state := mode.State()
+		if !state.Enabled {
+			c.Next()
+			return
+		}
+
+		message := state.Message
+		if message == "" {
+			message = "the API is in read-only maintenance mode"
+		}
+		c.Header("Retry-After", strconv.Itoa(int(maintenanceRetryAfter/time.Second)))
+		render.Abort(c, http.StatusServiceUnavailable, gin.H{
+			"error": message,
+			"code":  "MAINTENANCE",
+		})
+	}
+}
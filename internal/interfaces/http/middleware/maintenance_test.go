@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaintenanceRejectsWritesOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mode := NewMaintenanceMode(false, "")
+	r := gin.New()
+	r.Use(Maintenance(mode))
+	r.GET("/tasks/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/tasks", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	r.DELETE("/tasks/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	if rec := serve(http.MethodPost, "/tasks"); rec.Code != http.StatusCreated {
+		t.Fatalf("expected writes to pass outside maintenance, got %d", rec.Code)
+	}
+
+	state := mode.Set(true, "migrating redis")
+	if !state.Enabled || state.Since == "" {
+		t.Fatalf("expected an enabled state with a start time, got %+v", state)
+	}
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		path := "/tasks"
+		if method == http.MethodDelete {
+			path = "/tasks/t1"
+		}
+		rec := serve(method, path)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("%s: expected 503, got %d", method, rec.Code)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, `"MAINTENANCE"`) || !strings.Contains(body, "migrating redis") {
+			t.Fatalf("%s: expected the maintenance code and message, got %s", method, body)
+		}
+		if rec.Header().Get("Retry-After") == "" {
+			t.Fatalf("%s: expected a Retry-After header", method)
+		}
+	}
+	if rec := serve(http.MethodGet, "/tasks/t1"); rec.Code != http.StatusOK {
+		t.Fatalf("expected reads to pass during maintenance, got %d", rec.Code)
+	}
+
+	if state := mode.Set(false, "ignored"); state.Enabled || state.Message != "" || state.Since != "" {
+		t.Fatalf("expected a cleared state, got %+v", state)
+	}
+	if rec := serve(http.MethodPost, "/tasks"); rec.Code != http.StatusCreated {
+		t.Fatalf("expected writes to pass after maintenance, got %d", rec.Code)
+	}
+}
@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
 )
 
 func Logger(logger *zap.Logger) gin.HandlerFunc {
@@ -53,7 +58,7 @@ func Recovery(logger *zap.Logger) gin.HandlerFunc {
 			zap.String("path", c.Request.URL.Path),
 			zap.String("method", c.Request.Method),
 		)
-		c.AbortWithStatusJSON(500, gin.H{
+		render.Abort(c, 500, gin.H{
 			"error": "internal server error",
 			"code":  "INTERNAL_ERROR",
 		})
@@ -91,3 +96,72 @@ func RequestID() gin.HandlerFunc {
 func generateRequestID() string {
 	return fmt.Sprintf("%d", time.Now().UnixNano())
 }
+
+// BodyLimit 限制请求体大小，limit <= 0 表示不限制
+func BodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			render.Abort(c, http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("request body exceeds %d bytes", limit),
+				"code":  "REQUEST_TOO_LARGE",
+			})
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// MetricsAuth 校验 /metrics 的抓取令牌（Authorization: Bearer <token>），未配置令牌时拒绝所有请求
+func MetricsAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			render.Abort(c, http.StatusUnauthorized, gin.H{
+				"error": "metrics credentials required",
+				"code":  "UNAUTHORIZED",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// AdminAuth 校验管理接口令牌（Authorization: Bearer <token> 或 X-Admin-Token）
+// 未配置令牌时拒绝所有管理请求
+func AdminAuth(token string) gin.HandlerFunc {
+	return AdminAuthWhen(token, nil)
+}
+
+// AdminAuthWhen 仅在 cond 返回 true 时要求管理令牌，cond 为 nil 时总是要求。
+// 用于普通接口中需要管理权限的参数（如 include_payload=full）
+func AdminAuthWhen(token string, cond func(*gin.Context) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cond != nil && !cond(c) {
+			c.Next()
+			return
+		}
+
+		provided := c.GetHeader("X-Admin-Token")
+		if provided == "" {
+			provided = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			render.Abort(c, http.StatusUnauthorized, gin.H{
+				"error": "admin credentials required",
+				"code":  "UNAUTHORIZED",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMetricsAuthRequiresToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(token, header string) int {
+		r := gin.New()
+		r.GET("/metrics", MetricsAuth(token), func(c *gin.Context) { c.Status(http.StatusOK) })
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve("scrape", "Bearer scrape"); code != http.StatusOK {
+		t.Fatalf("expected the scrape token to be accepted, got %d", code)
+	}
+	for _, header := range []string{"", "Bearer other", "scrape-admin"} {
+		if code := serve("scrape", header); code != http.StatusUnauthorized {
+			t.Fatalf("expected %q to be rejected, got %d", header, code)
+		}
+	}
+	if code := serve("", ""); code != http.StatusUnauthorized {
+		t.Fatalf("expected scrapes to be rejected without a configured token, got %d", code)
+	}
+}
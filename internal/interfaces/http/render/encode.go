@@ -0,0 +1,218 @@
+package render
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// opaqueKeys 这些字段的值是调用方或任务自己的数据（payload、结果、元数据、JSON Schema），
+// 按原样编码，只改写字段本身的名字
+var opaqueKeys = map[string]bool{
+	"payload":       true,
+	"result":        true,
+	"metadata":      true,
+	"metadata_json": true,
+	"schema":        true,
+}
+
+var (
+	marshalerType     = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	ginHType          = reflect.TypeFor[gin.H]()
+)
+
+// encodeRenamed 按 encoding/json 的规则编码 v，并用 rename 改写字段名。只改写来自结构体 JSON tag 的字段名
+// 和处理器用 gin.H 拼出的响应字段；其他 map 的键是数据（队列名、能力类型、用户的 payload 和结果），原样保留。
+// depth 为字段所在对象的嵌套层级（顶层为 1）
+func encodeRenamed(v any, rename func(key string, depth int) string) ([]byte, error) {
+	e := &renameEncoder{rename: rename}
+	if err := e.encode(reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type renameEncoder struct {
+	buf    bytes.Buffer
+	rename func(key string, depth int) string
+}
+
+// encode 编码 v，depth 为 v 所在对象的嵌套层级
+func (e *renameEncoder) encode(v reflect.Value, depth int) error {
+	if !v.IsValid() {
+		e.buf.WriteString("null")
+		return nil
+	}
+	// 自定义编码的类型（time.Time、json.RawMessage 等）交给 encoding/json
+	if v.Type().Implements(marshalerType) || v.Type().Implements(textMarshalerType) {
+		return e.plain(v)
+	}
+	if v.CanAddr() {
+		if pt := reflect.PointerTo(v.Type()); pt.Implements(marshalerType) || pt.Implements(textMarshalerType) {
+			return e.plain(v.Addr())
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			e.buf.WriteString("null")
+			return nil
+		}
+		return e.encode(v.Elem(), depth)
+	case reflect.Struct:
+		return e.encodeStruct(v, depth+1)
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return e.plain(v)
+		}
+		return e.encodeMap(v, depth+1, v.Type() == ginHType)
+	case reflect.Slice:
+		if v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8 {
+			return e.plain(v)
+		}
+		fallthrough
+	case reflect.Array:
+		e.buf.WriteByte('[')
+		for i := range v.Len() {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			if err := e.encode(v.Index(i), depth); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte(']')
+		return nil
+	default:
+		return e.plain(v)
+	}
+}
+
+func (e *renameEncoder) plain(v reflect.Value) error {
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	e.buf.Write(data)
+	return nil
+}
+
+// member 写出一个字段，rename 为 false 时保留原字段名
+func (e *renameEncoder) member(first bool, key string, value reflect.Value, depth int, rename bool) error {
+	if !first {
+		e.buf.WriteByte(',')
+	}
+	name := key
+	if rename {
+		name = e.rename(key, depth)
+	}
+	data, err := json.Marshal(name)
+	if err != nil {
+		return err
+	}
+	e.buf.Write(data)
+	e.buf.WriteByte(':')
+	if opaqueKeys[key] {
+		return e.plain(value)
+	}
+	return e.encode(value, depth)
+}
+
+func (e *renameEncoder) encodeStruct(v reflect.Value, depth int) error {
+	e.buf.WriteByte('{')
+	first := true
+	for _, f := range structFields(v) {
+		if err := e.member(first, f.name, f.value, depth, true); err != nil {
+			return err
+		}
+		first = false
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
+
+func (e *renameEncoder) encodeMap(v reflect.Value, depth int, rename bool) error {
+	keys := v.MapKeys()
+	slices.SortFunc(keys, func(a, b reflect.Value) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	e.buf.WriteByte('{')
+	for i, k := range keys {
+		if err := e.member(i == 0, k.String(), v.MapIndex(k), depth, rename); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
+
+type field struct {
+	name  string
+	value reflect.Value
+}
+
+// structFields 按 encoding/json 的规则列出要输出的字段：跳过未导出和 tag 为 "-" 的字段，
+// 处理 omitempty，展开没有 tag 的匿名结构体字段
+func structFields(v reflect.Value) []field {
+	var fields []field
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := v.Field(i)
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if fv.Kind() == reflect.Pointer {
+					if fv.IsNil() {
+						continue
+					}
+					fv = fv.Elem()
+				}
+				fields = append(fields, structFields(fv)...)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if strings.Contains(","+opts+",", ",omitempty,") && isEmptyValue(fv) {
+			continue
+		}
+		fields = append(fields, field{name: name, value: fv})
+	}
+	return fields
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+// Package render 统一输出 HTTP JSON 响应，支持按配置或 Accept 的 profile 参数切换字段命名和响应信封
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Casing JSON 字段命名风格
+type Casing string
+
+const (
+	// CasingSnake 下划线命名（task_id），默认
+	CasingSnake Casing = "snake"
+	// CasingCamel 驼峰命名（taskId）
+	CasingCamel Casing = "camel"
+)
+
+// profile 参数中的信封开关
+const (
+	profileEnvelope = "envelope"
+	profilePlain    = "plain"
+)
+
+// Style 一次响应使用的格式
+type Style struct {
+	Casing Casing
+	// Envelope 为 true 时响应包装为 {"data": ..., "error": ...}
+	Envelope bool
+}
+
+// ParseCasing 解析配置中的命名风格，空字符串视为 snake
+func ParseCasing(s string) (Casing, error) {
+	switch Casing(s) {
+	case "", CasingSnake:
+		return CasingSnake, nil
+	case CasingCamel:
+		return CasingCamel, nil
+	default:
+		return "", fmt.Errorf("unknown casing %q", s)
+	}
+}
+
+// Default 返回不做任何改写的格式，与未接入 render 之前的响应一致
+func (s Style) Default() bool {
+	return s.Casing != CasingCamel && !s.Envelope
+}
+
+const styleKey = "render_style"
+
+// Negotiate 确定每个请求的响应格式并放入 gin context：默认使用 def，
+// Accept 中带 profile 参数时（如 application/json; profile="camel envelope"）按其中的标记覆盖。
+// 可用标记为 snake、camel、envelope、plain，未出现的项沿用默认值，未知标记忽略
+func Negotiate(def Style) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "Accept")
+		c.Set(styleKey, negotiate(def, c.GetHeader("Accept")))
+		c.Next()
+	}
+}
+
+func negotiate(style Style, accept string) Style {
+	for _, part := range strings.Split(accept, ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		profile, ok := params["profile"]
+		if !ok {
+			continue
+		}
+		for _, token := range strings.Fields(profile) {
+			switch strings.ToLower(token) {
+			case string(CasingSnake):
+				style.Casing = CasingSnake
+			case string(CasingCamel):
+				style.Casing = CasingCamel
+			case profileEnvelope:
+				style.Envelope = true
+			case profilePlain:
+				style.Envelope = false
+			}
+		}
+		return style
+	}
+	return style
+}
+
+// StyleOf 返回请求协商出的响应格式，未挂载 Negotiate 时返回默认格式
+func StyleOf(c *gin.Context) Style {
+	if v, ok := c.Get(styleKey); ok {
+		if style, ok := v.(Style); ok {
+			return style
+		}
+	}
+	return Style{Casing: CasingSnake}
+}
+
+// JSON 按请求的响应格式写出 body。status >= 400 时 body 视为错误内容，信封模式下放入 error 字段
+func JSON(c *gin.Context, status int, body any) {
+	style := StyleOf(c)
+	if style.Default() {
+		c.JSON(status, body)
+		return
+	}
+
+	data, err := Marshal(style, status, body)
+	if err != nil {
+		_ = c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render response", "code": "INTERNAL_ERROR"})
+		return
+	}
+	c.Data(status, "application/json; charset=utf-8", data)
+}
+
+// Abort 写出响应并终止后续处理，供中间件使用
+func Abort(c *gin.Context, status int, body any) {
+	c.Abort()
+	JSON(c, status, body)
+}
+
+// Marshal 按 style 编码 body：先改写字段命名，再按需包装信封
+func Marshal(style Style, status int, body any) ([]byte, error) {
+	if !style.Envelope {
+		return Fields(style, body)
+	}
+
+	envelope := struct {
+		Data  json.RawMessage `json:"data"`
+		Error json.RawMessage `json:"error"`
+	}{Data: json.RawMessage("null"), Error: json.RawMessage("null")}
+	var err error
+	if status >= http.StatusBadRequest {
+		// 错误响应的 error 字段即错误描述，放进信封后改名为 message，避免 error.error
+		envelope.Error, err = encodeRenamed(body, func(key string, depth int) string {
+			if depth == 1 && key == "error" {
+				return "message"
+			}
+			return style.fieldName(key)
+		})
+	} else {
+		envelope.Data, err = Fields(style, body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope)
+}
+
+// Fields 按 style 编码 body，只改写字段命名、不加信封，SSE 的 data 使用它。
+// 只改写结构体和 gin.H 的字段名，其他 map 的键原样保留
+func Fields(style Style, body any) ([]byte, error) {
+	if style.Casing != CasingCamel {
+		return json.Marshal(body)
+	}
+	return encodeRenamed(body, func(key string, _ int) string {
+		return camel(key)
+	})
+}
+
+func (s Style) fieldName(key string) string {
+	if s.Casing == CasingCamel {
+		return camel(key)
+	}
+	return key
+}
+
+// camel 将 snake_case 转为 camelCase，不含下划线的字段原样返回
+func camel(key string) string {
+	if !strings.Contains(key, "_") {
+		return key
+	}
+	var b strings.Builder
+	b.Grow(len(key))
+	upper := false
+	for i, r := range key {
+		switch {
+		case r == '_' && i > 0:
+			upper = true
+		case upper:
+			b.WriteString(strings.ToUpper(string(r)))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,220 @@
+package render
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/dto"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+// 两种字段命名和信封的组合都是客户端依赖的格式，这里用 golden 文件固定。
+// 有意修改格式时运行 go test ./internal/interfaces/http/render -run TestRenderGolden -update 更新
+var update = flag.Bool("update", false, "update render golden files")
+
+// goldenResponses 覆盖任务、进度、队列和错误响应
+var goldenResponses = []struct {
+	name   string
+	status int
+	body   any
+}{
+	{
+		name:   "task_created",
+		status: http.StatusCreated,
+		body: dto.CreateTaskResponse{
+			TaskID:           "t1",
+			Queue:            "default",
+			Status:           "pending",
+			Self:             "/api/v1/tasks/t1",
+			UniqueTTLSeconds: 60,
+			Effective: dto.EffectiveOptions{
+				Queue:            "default",
+				MaxRetries:       3,
+				TimeoutSeconds:   1800,
+				UniqueTTLSeconds: 60,
+			},
+		},
+	},
+	{
+		name:   "progress_latest",
+		status: http.StatusOK,
+		body: gin.H{
+			"progress": &progress.Progress{
+				TaskID:       "t1",
+				Percentage:   100,
+				Stage:        "completed",
+				TimestampMs:  1_700_000_000_000,
+				Metadata:     map[string]string{"trace_id": "abc"},
+				MetadataJSON: json.RawMessage(`{"row_count":3,"nested_map":{"inner_key":1}}`),
+			},
+			"is_final": true,
+			"result":   json.RawMessage(`{"output_path":"/tmp/out"}`),
+		},
+	},
+	{
+		name:   "queue_stats",
+		status: http.StatusOK,
+		body: gin.H{"queues": []dto.QueueStatsResponse{
+			{Queue: "default", Pending: 2, Active: 1, Aggregating: 3, Groups: 1},
+		}},
+	},
+	{
+		name:   "validation_error",
+		status: http.StatusBadRequest,
+		body: dto.ErrorResponse{
+			Error:   "count must be between 1 and 100",
+			Code:    "VALIDATION_ERROR",
+			Details: map[string]string{"task_type": "demo", "field": "count"},
+		},
+	},
+}
+
+func TestRenderGolden(t *testing.T) {
+	styles := []struct {
+		name   string
+		accept string
+	}{
+		{name: "snake"},
+		{name: "camel", accept: `application/json; profile="camel"`},
+		{name: "snake_envelope", accept: `application/json; profile="envelope"`},
+		{name: "camel_envelope", accept: `application/json; profile="camel envelope"`},
+	}
+
+	for _, style := range styles {
+		t.Run(style.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(Negotiate(Style{Casing: CasingSnake}))
+			for _, resp := range goldenResponses {
+				r.GET("/"+resp.name, func(c *gin.Context) {
+					JSON(c, resp.status, resp.body)
+				})
+			}
+
+			var b strings.Builder
+			for _, resp := range goldenResponses {
+				req := httptest.NewRequest(http.MethodGet, "/"+resp.name, nil)
+				if style.accept != "" {
+					req.Header.Set("Accept", style.accept)
+				}
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, req)
+
+				fmt.Fprintf(&b, "## %s\nHTTP %d\nContent-Type: %s\n%s\n\n",
+					resp.name, rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+			}
+			compareGolden(t, style.name, b.String())
+		})
+	}
+}
+
+func compareGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Fatalf("output differs from %s; if the change is intentional, rerun with -update\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	camelEnvelope := Style{Casing: CasingCamel, Envelope: true}
+	tests := []struct {
+		name   string
+		def    Style
+		accept string
+		want   Style
+	}{
+		{name: "no accept uses default", def: camelEnvelope, want: camelEnvelope},
+		{name: "accept without profile", def: camelEnvelope, accept: "application/json", want: camelEnvelope},
+		{name: "profile overrides casing only", def: Style{Casing: CasingSnake, Envelope: true}, accept: `application/json; profile="camel"`, want: camelEnvelope},
+		{name: "plain and snake opt out", def: camelEnvelope, accept: `application/json; profile="snake plain"`, want: Style{Casing: CasingSnake}},
+		{name: "first profile wins", def: Style{Casing: CasingSnake}, accept: `text/html, application/json; profile=camel, application/json; profile=envelope`, want: Style{Casing: CasingCamel}},
+		{name: "unknown tokens ignored", def: Style{Casing: CasingSnake}, accept: `application/json; profile="kebab ENVELOPE"`, want: Style{Casing: CasingSnake, Envelope: true}},
+		{name: "malformed accept ignored", def: Style{Casing: CasingSnake}, accept: `application/json; profile="camel`, want: Style{Casing: CasingSnake}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := negotiate(tt.def, tt.accept); got != tt.want {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCamel(t *testing.T) {
+	for in, want := range map[string]string{
+		"task_id":         "taskId",
+		"max_lifetime_ms": "maxLifetimeMs",
+		"queue":           "queue",
+		"_private":        "_private",
+	} {
+		if got := camel(in); got != want {
+			t.Errorf("camel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFieldsKeepsOpaqueValues(t *testing.T) {
+	got, err := Fields(Style{Casing: CasingCamel}, gin.H{
+		"task_id": "t1",
+		"payload": json.RawMessage(`{"user_id":1,"items":[{"item_id":2}]}`),
+		"items":   []gin.H{{"next_process_at": "soon"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"items":[{"nextProcessAt":"soon"}],"payload":{"user_id":1,"items":[{"item_id":2}]},"taskId":"t1"}`
+	if string(got) != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestFieldsKeepsMapKeys(t *testing.T) {
+	type capability struct {
+		TaskType  string         `json:"task_type"`
+		MaxItems  int            `json:"max_items,omitempty"`
+		QueueInfo map[string]int `json:"queue_info"`
+	}
+	got, err := Fields(Style{Casing: CasingCamel}, gin.H{
+		// 以队列名为键的 map，键是数据
+		"queue_sizes": map[string]dto.QueueStatsResponse{"low_priority": {Queue: "low_priority", Pending: 1}},
+		"capabilities": map[string]capability{
+			"grpc_task": {TaskType: "grpc_task", QueueInfo: map[string]int{"batch_jobs": 2}},
+		},
+		// 任务结果解码后的 map，内部字段名是用户自己的
+		"task_result": map[string]any{"output_path": "/tmp/out", "row_stats": map[string]any{"row_count": 3}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"capabilities":{"grpc_task":{"taskType":"grpc_task","queueInfo":{"batch_jobs":2}}},` +
+		`"queueSizes":{"low_priority":{"queue":"low_priority","pending":1,"active":0,"scheduled":0,"retry":0,"archived":0,"completed":0,"aggregating":0,"groups":0}},` +
+		`"taskResult":{"output_path":"/tmp/out","row_stats":{"row_count":3}}}`
+	if string(got) != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
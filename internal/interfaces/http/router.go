@@ -1,14 +1,21 @@
 package http
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
 	taskapp "github.com/Aixtrade/TaskFlow/internal/application/task"
 	"github.com/Aixtrade/TaskFlow/internal/config"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/discovery"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/progresstoken"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/schema"
 	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/handler"
 	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/middleware"
+	"github.com/Aixtrade/TaskFlow/internal/interfaces/http/render"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
 	"github.com/Aixtrade/TaskFlow/pkg/progress"
 )
 
@@ -16,17 +23,36 @@ type Router struct {
 	engine             *gin.Engine
 	cfg                *config.Config
 	logger             *zap.Logger
+	accessLogger       *zap.Logger
 	taskService        *taskapp.Service
 	redisClient        *redis.Client
 	progressSubscriber *progress.Subscriber
+	canary             *progress.Canary
+	directory          *discovery.Directory
+	schemas            *schema.Registry
+	metrics            http.Handler
+	maintenance        *middleware.MaintenanceMode
+	configCheck        handler.ConfigCheck
+	publisher          handler.ProgressReportPublisher
+	revocations        handler.TokenRevocations
 }
 
 type RouterConfig struct {
-	Config      *config.Config
-	Logger      *zap.Logger
-	TaskService *taskapp.Service
-	RedisClient *redis.Client
-	Progress    progress.StreamOptions
+	Config       *config.Config
+	Logger       *zap.Logger
+	AccessLogger *zap.Logger // 为空时使用 Logger
+	TaskService  *taskapp.Service
+	RedisClient  *redis.Client
+	Progress     progress.StreamOptions
+	Subscriber   *progress.Subscriber // 为空时使用 RedisClient 和 Progress 创建
+	Canary       *progress.Canary     // 进度自检，为空时 /health 不报告
+	Directory    *discovery.Directory
+	Schemas      *schema.Registry
+	Metrics      http.Handler        // Prometheus /metrics 处理器，为空时不暴露
+	ConfigCheck  handler.ConfigCheck // 与 worker 配置指纹的比较，为空时 /health 不报告
+	// Publisher 和 Revocations 用于下游通过 HTTP 上报进度，progress.report_tokens 未启用时可为空
+	Publisher   handler.ProgressReportPublisher
+	Revocations handler.TokenRevocations
 }
 
 func NewRouter(cfg RouterConfig) *Router {
@@ -36,23 +62,42 @@ func NewRouter(cfg RouterConfig) *Router {
 
 	engine := gin.New()
 
-	// 创建进度订阅器
-	progressSubscriber := progress.NewSubscriber(cfg.RedisClient, cfg.Logger, cfg.Progress)
+	// 创建进度订阅器，进度功能关闭时不创建
+	progressSubscriber := cfg.Subscriber
+	if progressSubscriber == nil && cfg.Config.Progress.IsEnabled() {
+		progressSubscriber = progress.NewSubscriber(cfg.RedisClient, cfg.Logger, cfg.Progress)
+	}
+
+	accessLogger := cfg.AccessLogger
+	if accessLogger == nil {
+		accessLogger = cfg.Logger
+	}
 
 	return &Router{
 		engine:             engine,
 		cfg:                cfg.Config,
 		logger:             cfg.Logger,
+		accessLogger:       accessLogger,
 		taskService:        cfg.TaskService,
 		redisClient:        cfg.RedisClient,
 		progressSubscriber: progressSubscriber,
+		canary:             cfg.Canary,
+		directory:          cfg.Directory,
+		schemas:            cfg.Schemas,
+		metrics:            cfg.Metrics,
+		configCheck:        cfg.ConfigCheck,
+		publisher:          cfg.Publisher,
+		revocations:        cfg.Revocations,
+		maintenance:        middleware.NewMaintenanceMode(cfg.Config.Maintenance.Enabled, cfg.Config.Maintenance.Message),
 	}
 }
 
 func (r *Router) Setup() *gin.Engine {
+	// 最先确定响应格式，recovery 等中间件输出的错误也使用同一格式
+	r.engine.Use(render.Negotiate(r.responseStyle()))
 	r.engine.Use(middleware.Recovery(r.logger))
 	r.engine.Use(middleware.RequestID())
-	r.engine.Use(middleware.Logger(r.logger))
+	r.engine.Use(middleware.Logger(r.accessLogger))
 	r.engine.Use(middleware.CORS())
 
 	r.setupHealthRoutes()
@@ -61,44 +106,173 @@ func (r *Router) Setup() *gin.Engine {
 	return r.engine
 }
 
+// responseStyle 配置的默认响应格式，配置已校验过 casing
+func (r *Router) responseStyle() render.Style {
+	casing, _ := render.ParseCasing(r.cfg.Server.HTTP.Response.Casing)
+	return render.Style{Casing: casing, Envelope: r.cfg.Server.HTTP.Response.Envelope}
+}
+
 func (r *Router) setupHealthRoutes() {
-	healthHandler := handler.NewHealthHandler(r.redisClient)
+	var canary handler.ProgressCanary
+	if r.canary != nil {
+		canary = r.canary
+	}
+	var healthOpts []handler.HealthHandlerOption
+	if r.configCheck != nil {
+		healthOpts = append(healthOpts, handler.WithConfigCheck(r.configCheck))
+	}
+	healthHandler := handler.NewHealthHandler(r.redisClient, r.cfg.OperationTimeouts.HealthCheck, canary, healthOpts...)
 
 	r.engine.GET("/health", healthHandler.Health)
 	r.engine.GET("/ready", healthHandler.Ready)
 	r.engine.GET("/live", healthHandler.Live)
+
+	if r.metrics != nil {
+		r.engine.GET("/metrics", middleware.MetricsAuth(r.cfg.Metrics.Token), gin.WrapH(r.metrics))
+	}
 }
 
 func (r *Router) setupAPIRoutes() {
-	taskHandler := handler.NewTaskHandler(r.taskService)
-	progressHandler := handler.NewProgressHandler(r.progressSubscriber, r.logger)
+	// 格式已在配置校验中检查
+	trustedProxies, _ := r.cfg.Server.HTTP.TrustedProxyPrefixes()
+	taskHandler := handler.NewTaskHandler(r.taskService,
+		handler.WithPublicURL(r.cfg.Server.HTTP.PublicURL),
+		handler.WithTrustedProxies(trustedProxies),
+		handler.WithProgressLinks(r.progressSubscriber != nil),
+		handler.WithMaxBatchSize(r.cfg.Server.HTTP.MaxBatchSize),
+	)
+	streamCfg := r.cfg.Server.HTTP.QueueStatsStream
+	queueStatsHandler := handler.NewQueueStatsHandler(
+		taskapp.NewQueueStatsFeed(r.taskService, streamCfg.Interval, streamCfg.MinDelta, streamCfg.MaxSubscribers, clock.Real()),
+		r.logger,
+		handler.WithStatsHeartbeat(streamCfg.Heartbeat),
+	)
+	// 进度功能关闭时不注册进度相关端点
+	var progressHandler *handler.ProgressHandler
+	if r.progressSubscriber != nil {
+		multiStreamCfg := r.cfg.Server.HTTP.MultiProgressStream
+		progressHandler = handler.NewProgressHandler(r.progressSubscriber, r.logger, r.cfg.Server.HTTP.SSEMaxLifetime,
+			handler.WithMultiStreamLimits(handler.MultiStreamLimits{
+				MaxEventsPerSecond: multiStreamCfg.MaxEventsPerSecond,
+				StatsInterval:      multiStreamCfg.StatsInterval,
+			}),
+			handler.WithReconnectHint(handler.ReconnectHint{
+				Base: r.cfg.Server.HTTP.SSEReconnect.Retry,
+				Max:  r.cfg.Server.HTTP.SSEReconnect.MaxRetry,
+			}),
+			handler.WithAllowedOrigins(r.cfg.Server.HTTP.WebSocketOrigins),
+		)
+	}
+	apiKeyAuth := middleware.APIKeyAuth(middleware.NewAPIKeys(r.cfg.Auth.APIKeys))
+	maintenance := middleware.Maintenance(r.maintenance)
+	// 完整 payload 可能包含敏感信息，仅管理员可读取
+	fullPayloadAuth := middleware.AdminAuthWhen(r.cfg.Admin.Token, func(c *gin.Context) bool {
+		return c.Query("include_payload") == taskapp.PayloadFull
+	})
+
+	// 维护模式开关不受维护模式限制，否则开启后无法关闭
+	maintenanceHandler := handler.NewMaintenanceHandler(r.maintenance, r.logger)
+	adminAuth := middleware.AdminAuth(r.cfg.Admin.Token)
+	r.engine.GET("/api/v1/admin/maintenance", adminAuth, maintenanceHandler.Get)
+	r.engine.PUT("/api/v1/admin/maintenance", adminAuth, middleware.BodyLimit(r.cfg.Server.HTTP.MaxBodyBytes), maintenanceHandler.Set)
+
+	// 注册前检查 gRPC 服务的连通性，使用与 worker 相同的健康检查超时
+	grpcServiceHandler := handler.NewGRPCServiceHandler(nil, r.cfg.OperationTimeouts.HealthCheck, r.logger)
+	r.engine.POST("/api/v1/admin/grpc-services/test", adminAuth, middleware.BodyLimit(r.cfg.Server.HTTP.MaxBodyBytes), grpcServiceHandler.Test)
+
+	// 文件上传使用独立的大小限制，因此不挂在 v1 分组的请求体限制之下
+	if r.cfg.BlobStore.Enabled() {
+		r.engine.POST("/api/v1/tasks/upload",
+			apiKeyAuth,
+			maintenance,
+			middleware.BodyLimit(r.cfg.Server.HTTP.MaxUploadBytes),
+			taskHandler.Upload,
+		)
+	}
+
+	// 下游执行方凭任务级令牌上报进度，不使用 API key，也不受维护模式限制（只影响已在执行的任务）
+	if tokens := r.cfg.Progress.ReportTokens; r.cfg.Progress.IsEnabled() && tokens.Enabled() && r.publisher != nil && r.revocations != nil {
+		reportHandler := handler.NewProgressReportHandler(
+			progresstoken.NewSigner(tokens.Secret, tokens.MaxTTL), r.revocations, r.publisher, r.logger, clock.Real(),
+		)
+		bodyLimit := middleware.BodyLimit(r.cfg.Server.HTTP.MaxBodyBytes)
+		r.engine.POST("/api/v1/tasks/:id/progress", bodyLimit, reportHandler.Report)
+		r.engine.POST("/api/v1/tasks/:id/complete", bodyLimit, reportHandler.Complete)
+	}
 
 	v1 := r.engine.Group("/api/v1")
+	v1.Use(apiKeyAuth, maintenance, middleware.BodyLimit(r.cfg.Server.HTTP.MaxBodyBytes))
 	{
+		v1.GET("/me", handler.Me)
+
 		tasks := v1.Group("/tasks")
 		{
 			tasks.POST("", taskHandler.Create)
-			tasks.GET("", taskHandler.ListTasks)
-			tasks.GET("/:id", taskHandler.Get)
+			tasks.POST("/batch", taskHandler.CreateBatch)
+			tasks.GET("", fullPayloadAuth, taskHandler.ListTasks)
+			tasks.GET("/:id", fullPayloadAuth, taskHandler.Get)
 			tasks.DELETE("/:id", taskHandler.Delete)
 			tasks.POST("/:id/cancel", taskHandler.Cancel)
+			tasks.POST("/:id/archive", taskHandler.Archive)
+			tasks.POST("/:id/run", taskHandler.Run)
+			tasks.POST("/:id/requeue", taskHandler.Requeue)
+			tasks.GET("/:id/result", taskHandler.Result)
+			tasks.GET("/:id/artifacts/:name", taskHandler.Artifact)
 
 			// 进度相关端点
-			tasks.GET("/:id/progress", progressHandler.GetLatestProgress)
-			tasks.GET("/:id/progress/stream", progressHandler.StreamProgress)
-			tasks.GET("/:id/progress/history", progressHandler.GetProgressHistory)
-			tasks.GET("/:id/progress/info", progressHandler.GetProgressInfo)
+			if progressHandler != nil {
+				tasks.GET("/:id/progress", progressHandler.GetLatestProgress)
+				tasks.GET("/:id/progress/stream", progressHandler.StreamProgress)
+				tasks.GET("/:id/progress/ws", progressHandler.StreamProgressWS)
+				tasks.GET("/:id/progress/history", progressHandler.GetProgressHistory)
+				tasks.GET("/:id/progress/info", progressHandler.GetProgressInfo)
+			}
 		}
 
 		queues := v1.Group("/queues")
 		{
 			queues.GET("/stats", taskHandler.GetQueueStats)
+			queues.GET("/stats/stream", queueStatsHandler.Stream)
+			queues.GET("/:name/groups", taskHandler.ListGroups)
+			queues.GET("/:name/oldest", taskHandler.GetOldestTasks)
+			queues.POST("/:name/groups/:group/flush", middleware.AdminAuth(r.cfg.Admin.Token), taskHandler.FlushGroup)
+
+			// 运行中调整队列权重，worker 依次重启消费后在心跳中报告已应用的版本
+			var workers handler.WorkerLister
+			if r.directory != nil {
+				workers = r.directory
+			}
+			weightsHandler := handler.NewQueueWeightsHandler(discovery.NewWeightStore(r.redisClient), workers, r.taskService.KnownQueues, r.logger)
+			queues.GET("/weights", adminAuth, weightsHandler.Get)
+			queues.PUT("/weights", adminAuth, weightsHandler.Set)
+		}
+
+		if r.directory != nil {
+			capabilityHandler := handler.NewCapabilityHandler(r.directory)
+			v1.GET("/capabilities", capabilityHandler.List)
+		}
+
+		if r.schemas != nil {
+			schemaHandler := handler.NewSchemaHandler(r.schemas)
+			taskTypes := v1.Group("/task-types")
+			{
+				taskTypes.GET("/:type/schema", schemaHandler.Get)
+				taskTypes.PUT("/:type/schema", middleware.AdminAuth(r.cfg.Admin.Token), schemaHandler.Put)
+			}
+
+			grpcServices := v1.Group("/grpc-services")
+			{
+				grpcServices.GET("/:service/methods/:method/output-schema", schemaHandler.GetOutput)
+				grpcServices.PUT("/:service/methods/:method/output-schema", middleware.AdminAuth(r.cfg.Admin.Token), schemaHandler.PutOutput)
+			}
 		}
 
 		// 批量进度订阅
-		progress := v1.Group("/progress")
-		{
-			progress.GET("/stream", progressHandler.StreamMultipleProgress)
+		if progressHandler != nil {
+			progress := v1.Group("/progress")
+			{
+				progress.GET("/stream", progressHandler.StreamMultipleProgress)
+			}
 		}
 	}
 }
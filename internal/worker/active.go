@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+// ActiveTask 本实例正在处理的任务
+type ActiveTask struct {
+	ID        string
+	Type      string
+	Queue     string
+	StartedAt time.Time
+	Elapsed   time.Duration
+}
+
+// ActiveTasks 进程内正在处理的任务表，用于排查单个 worker 当前在做什么
+type ActiveTasks struct {
+	mu    sync.Mutex
+	tasks map[string]ActiveTask
+	clock clock.Clock
+}
+
+// NewActiveTasks 创建正在处理的任务表
+func NewActiveTasks(clk clock.Clock) *ActiveTasks {
+	return &ActiveTasks{
+		tasks: make(map[string]ActiveTask),
+		clock: clock.OrReal(clk),
+	}
+}
+
+// Middleware 在任务开始时登记，结束（包括 panic）时注销
+func (a *ActiveTasks) Middleware() asynq.MiddlewareFunc {
+	return func(h asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			id := GetTaskID(ctx)
+
+			a.mu.Lock()
+			a.tasks[id] = ActiveTask{
+				ID:        id,
+				Type:      t.Type(),
+				Queue:     GetQueueName(ctx),
+				StartedAt: a.clock.Now(),
+			}
+			a.mu.Unlock()
+
+			defer func() {
+				a.mu.Lock()
+				delete(a.tasks, id)
+				a.mu.Unlock()
+			}()
+
+			return h.ProcessTask(ctx, t)
+		})
+	}
+}
+
+// List 返回正在处理的任务，按开始时间升序（运行最久的在前）
+func (a *ActiveTasks) List() []ActiveTask {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.clock.Now()
+	list := make([]ActiveTask, 0, len(a.tasks))
+	for _, t := range a.tasks {
+		t.Elapsed = now.Sub(t.StartedAt)
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartedAt.Before(list[j].StartedAt)
+	})
+	return list
+}
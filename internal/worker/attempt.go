@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+// AttemptPublisher 发布重试开始事件
+type AttemptPublisher interface {
+	PublishAttemptStarted(ctx context.Context, taskID string) error
+}
+
+// AttemptMiddleware 在 context 中记录本次是第几次执行，之后发布的进度和完成事件都带上执行次数；
+// 重试（第 2 次及以后）开始时先发布 attempt_started 事件，让订阅者知道进度将从头开始
+func AttemptMiddleware(publisher AttemptPublisher, logger *zap.Logger) asynq.MiddlewareFunc {
+	return func(h asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			retry := GetRetryCount(ctx)
+			ctx = progress.WithAttempt(ctx, retry+1, GetMaxRetry(ctx)+1)
+
+			if retry > 0 {
+				taskID := GetTaskID(ctx)
+				if err := publisher.PublishAttemptStarted(ctx, taskID); err != nil {
+					logger.Warn("failed to publish attempt start",
+						zap.String("task_id", taskID),
+						zap.Int("attempt", retry+1),
+						zap.Error(err),
+					)
+				}
+			}
+			return h.ProcessTask(ctx, t)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/hibiken/asynq"
 	"go.uber.org/zap"
@@ -88,3 +89,17 @@ func GetQueueName(ctx context.Context) string {
 	}
 	return queue
 }
+
+// GetDeadline 返回任务必须结束的时间，asynq 按任务的 timeout 或 deadline 设置在 context 上
+func GetDeadline(ctx context.Context) (time.Time, bool) {
+	return ctx.Deadline()
+}
+
+// GetRemainingBudget 返回距离截止时间还剩多少时间，没有截止时间时返回 false
+func GetRemainingBudget(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := GetDeadline(ctx)
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
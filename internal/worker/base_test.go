@@ -0,0 +1,23 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetRemainingBudget(t *testing.T) {
+	if _, ok := GetRemainingBudget(context.Background()); ok {
+		t.Fatal("expected no budget without a deadline")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	budget, ok := GetRemainingBudget(ctx)
+	if !ok || budget <= 0 || budget > 2*time.Second {
+		t.Fatalf("expected budget within the 2s deadline, got %v (ok=%v)", budget, ok)
+	}
+	if deadline, ok := GetDeadline(ctx); !ok || time.Until(deadline) > 2*time.Second {
+		t.Fatalf("unexpected deadline %v (ok=%v)", deadline, ok)
+	}
+}
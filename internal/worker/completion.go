@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+// completionPublishTimeout 任务 context 已取消时补发完成事件的超时
+const completionPublishTimeout = 5 * time.Second
+
+// CompletionPublisher 在处理器没有发布完成事件时补发
+type CompletionPublisher interface {
+	PublishCompletionIfMissing(ctx context.Context, taskID string, since time.Time, status, message string, metadata map[string]string) (bool, error)
+}
+
+// CompletionMiddleware 任务最后一次执行结束后，若处理器没有发布完成事件，则发布包含状态、错误和耗时的通用完成事件，
+// 使所有任务类型都有统一的结束信号。处理器 panic 时同样发布失败事件，与注册顺序无关。
+// 失败后还会被 asynq 重试的执行不发布：完成事件是最终事件，订阅方收到后即结束订阅，看不到之后的重试
+func CompletionMiddleware(publisher CompletionPublisher, logger *zap.Logger, clk clock.Clock) asynq.MiddlewareFunc {
+	clk = clock.OrReal(clk)
+	return func(h asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			start := clk.Now()
+			taskID := GetTaskID(ctx)
+
+			finished := false
+			defer func() {
+				// panic 穿过此处时仍发布失败事件
+				if !finished {
+					publishCompletion(ctx, publisher, logger, taskID, start, clk.Since(start), errors.New("task panicked"))
+				}
+			}()
+
+			err := h.ProcessTask(ctx, t)
+			finished = true
+			if WillRetry(ctx, err) {
+				return err
+			}
+			publishCompletion(ctx, publisher, logger, taskID, start, clk.Since(start), err)
+			return err
+		})
+	}
+}
+
+func publishCompletion(ctx context.Context, publisher CompletionPublisher, logger *zap.Logger, taskID string, start time.Time, duration time.Duration, err error) {
+	status, message := "completed", "task completed"
+	metadata := map[string]string{"duration_ms": strconv.FormatInt(duration.Milliseconds(), 10)}
+	switch {
+	case err != nil && errors.Is(ctx.Err(), context.Canceled):
+		status, message = "cancelled", "task cancelled"
+		metadata["error"] = err.Error()
+	case err != nil:
+		status, message = "failed", err.Error()
+		metadata["error"] = err.Error()
+	}
+
+	pubCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), completionPublishTimeout)
+	defer cancel()
+	published, pubErr := publisher.PublishCompletionIfMissing(pubCtx, taskID, start, status, message, metadata)
+	if pubErr != nil {
+		logger.Warn("failed to publish completion",
+			zap.String("task_id", taskID),
+			zap.Error(pubErr),
+		)
+		return
+	}
+	if published {
+		logger.Debug("generic completion published",
+			zap.String("task_id", taskID),
+			zap.String("status", status),
+		)
+	}
+}
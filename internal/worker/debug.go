@@ -0,0 +1,309 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/debugcapture"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+// debugAppendTimeout 写入一条采集记录的超时，不影响任务本身的结果
+const debugAppendTimeout = 2 * time.Second
+
+// DebugCaptureStore 保存调试采集，由 debugcapture.Store 实现
+type DebugCaptureStore interface {
+	Create(ctx context.Context, c debugcapture.Capture) error
+	Active(ctx context.Context, now time.Time) ([]debugcapture.Capture, error)
+	Append(ctx context.Context, c debugcapture.Capture, e debugcapture.Entry) error
+	Get(ctx context.Context, id string) (debugcapture.Capture, []debugcapture.Entry, error)
+}
+
+// DebugCaptureLimits 调试采集的限制
+type DebugCaptureLimits struct {
+	// MaxDuration 单次采集的最长时间
+	MaxDuration time.Duration
+	// Retention 采集结束后保留数据的时间
+	Retention time.Duration
+	// MaxFieldBytes payload 和结果各自最多保留的字节数，不大于 0 表示不截断
+	MaxFieldBytes int
+}
+
+// DebugCaptures 按任务类型临时开启的调试采集。进行中的采集缓存在内存中，
+// 由 Run 定期从 Redis 刷新，在任一 worker 上开启的采集几秒内对所有 worker 生效
+type DebugCaptures struct {
+	store       DebugCaptureStore
+	limits      DebugCaptureLimits
+	logger      *zap.Logger
+	debugLogger *zap.Logger
+	clock       clock.Clock
+
+	mu     sync.RWMutex
+	active []debugcapture.Capture
+}
+
+// NewDebugCaptures debugLogger 为 debug 级别的 logger，用于开启了 verbose_logging 的任务
+func NewDebugCaptures(store DebugCaptureStore, limits DebugCaptureLimits, logger, debugLogger *zap.Logger, clk clock.Clock) *DebugCaptures {
+	return &DebugCaptures{
+		store:       store,
+		limits:      limits,
+		logger:      logger,
+		debugLogger: debugLogger,
+		clock:       clock.OrReal(clk),
+	}
+}
+
+// Start 开启一次采集并立即在本 worker 生效
+func (d *DebugCaptures) Start(ctx context.Context, taskType string, duration time.Duration, opts debugcapture.Options) (debugcapture.Capture, error) {
+	if taskType == "" {
+		return debugcapture.Capture{}, errors.New("task_type is required")
+	}
+	if duration <= 0 {
+		return debugcapture.Capture{}, errors.New("duration must be greater than 0")
+	}
+	if d.limits.MaxDuration > 0 && duration > d.limits.MaxDuration {
+		return debugcapture.Capture{}, fmt.Errorf("duration must not exceed %s", d.limits.MaxDuration)
+	}
+
+	now := d.clock.Now()
+	c := debugcapture.Capture{
+		ID:        debugcapture.NewID(),
+		TaskType:  taskType,
+		Options:   opts,
+		CreatedAt: now.UTC(),
+		ExpiresAt: now.Add(duration).UTC(),
+		DeleteAt:  now.Add(duration + d.limits.Retention).UTC(),
+	}
+	if err := d.store.Create(ctx, c); err != nil {
+		return debugcapture.Capture{}, err
+	}
+
+	d.mu.Lock()
+	d.active = append(d.active, c)
+	d.mu.Unlock()
+	return c, nil
+}
+
+// DebugCaptureReport 采集及其记录
+type DebugCaptureReport struct {
+	Capture debugcapture.Capture `json:"capture"`
+	Active  bool                 `json:"active"`
+	Count   int                  `json:"count"`
+	Entries []debugcapture.Entry `json:"entries"`
+}
+
+// Get 返回采集及其记录，采集不存在或已删除时返回 debugcapture.ErrNotFound
+func (d *DebugCaptures) Get(ctx context.Context, id string) (*DebugCaptureReport, error) {
+	c, entries, err := d.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &DebugCaptureReport{
+		Capture: c,
+		Active:  c.Active(d.clock.Now()),
+		Count:   len(entries),
+		Entries: entries,
+	}, nil
+}
+
+// Refresh 从 Redis 读取进行中的采集
+func (d *DebugCaptures) Refresh(ctx context.Context) error {
+	active, err := d.store.Active(ctx, d.clock.Now())
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.active = active
+	d.mu.Unlock()
+	return nil
+}
+
+// Run 每隔 interval 刷新一次，直到 ctx 结束
+func (d *DebugCaptures) Run(ctx context.Context, interval time.Duration) {
+	if err := d.Refresh(ctx); err != nil {
+		d.logger.Warn("failed to refresh debug captures", zap.Error(err))
+	}
+	ticker := d.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if err := d.Refresh(ctx); err != nil {
+				d.logger.Warn("failed to refresh debug captures", zap.Error(err))
+			}
+		}
+	}
+}
+
+// match 返回任务类型进行中的采集，同一类型有多个时使用最早开启的
+func (d *DebugCaptures) match(taskType string, now time.Time) (debugcapture.Capture, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	i := slices.IndexFunc(d.active, func(c debugcapture.Capture) bool {
+		return c.TaskType == taskType && c.Active(now)
+	})
+	if i < 0 {
+		return debugcapture.Capture{}, false
+	}
+	return d.active[i], true
+}
+
+// Middleware 对匹配进行中采集的任务记录执行情况，其他任务直接放行
+func (d *DebugCaptures) Middleware() asynq.MiddlewareFunc {
+	return func(h asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			start := d.clock.Now()
+			capture, ok := d.match(t.Type(), start)
+			if !ok {
+				return h.ProcessTask(ctx, t)
+			}
+
+			session := &DebugSession{capture: capture, maxBytes: d.limits.MaxFieldBytes}
+			taskID := GetTaskID(ctx)
+			if capture.Options.VerboseLogging {
+				session.logger = d.debugLogger.With(
+					zap.String("debug_capture", capture.ID),
+					zap.String("type", t.Type()),
+					zap.String("task_id", taskID),
+				)
+				session.logger.Debug("debug capture: task started",
+					zap.Int("payload_size", len(t.Payload())),
+					zap.Int("retry", GetRetryCount(ctx)),
+				)
+			}
+
+			err := h.ProcessTask(withDebugSession(ctx, session), t)
+
+			entry := session.entry()
+			entry.TaskID = taskID
+			entry.Queue, _ = asynq.GetQueueName(ctx)
+			if w, ok := progress.WorkerFromContext(ctx); ok {
+				entry.Worker = w.ID
+			}
+			entry.Retry = GetRetryCount(ctx)
+			entry.StartedAt = start.UTC()
+			entry.DurationMs = d.clock.Since(start).Milliseconds()
+			entry.PayloadSize = len(t.Payload())
+			if capture.Options.CapturePayloads {
+				entry.Payload, entry.PayloadTruncated = truncateField(t.Payload(), d.limits.MaxFieldBytes)
+			}
+			if IsPanic(err) {
+				entry.Status = "panic"
+			} else {
+				entry.Status = AttemptStatus(ctx, err)
+			}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			if session.logger != nil {
+				session.logger.Debug("debug capture: task finished",
+					zap.String("status", entry.Status),
+					zap.Int64("duration_ms", entry.DurationMs),
+					zap.Error(err),
+				)
+			}
+
+			appendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), debugAppendTimeout)
+			defer cancel()
+			if appendErr := d.store.Append(appendCtx, capture, entry); appendErr != nil {
+				d.logger.Warn("failed to record debug capture entry",
+					zap.String("debug_capture", capture.ID),
+					zap.String("task_id", taskID),
+					zap.Error(appendErr),
+				)
+			}
+			return err
+		})
+	}
+}
+
+type debugSessionKey struct{}
+
+// DebugSession 一次被采集的任务执行，处理器通过 DebugSessionFromContext 补充采集内容。
+// 方法可在 nil 上调用，未被采集的任务无需判断
+type DebugSession struct {
+	capture  debugcapture.Capture
+	maxBytes int
+	// logger 开启 verbose_logging 时为 debug 级别的 logger，否则为 nil
+	logger *zap.Logger
+
+	mu               sync.Mutex
+	result           string
+	resultTruncated  bool
+	grpcRequestBytes *int
+	grpcResultBytes  *int
+}
+
+func withDebugSession(ctx context.Context, s *DebugSession) context.Context {
+	return context.WithValue(ctx, debugSessionKey{}, s)
+}
+
+// DebugSessionFromContext 返回任务的采集，任务未被采集时返回 nil
+func DebugSessionFromContext(ctx context.Context) *DebugSession {
+	s, _ := ctx.Value(debugSessionKey{}).(*DebugSession)
+	return s
+}
+
+// TaskLogger 开启 verbose_logging 的采集中返回 debug 级别的 logger，否则返回 fallback
+func TaskLogger(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if s := DebugSessionFromContext(ctx); s != nil && s.logger != nil {
+		return s.logger
+	}
+	return fallback
+}
+
+// RecordResult 记录任务结果，未开启 capture_payloads 时忽略
+func (s *DebugSession) RecordResult(data []byte) {
+	if s == nil || !s.capture.Options.CapturePayloads {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.result, s.resultTruncated = truncateField(data, s.maxBytes)
+}
+
+// RecordGRPCSizes 记录 gRPC 请求和结果的字节数，未开启 grpc_sizes 时忽略
+func (s *DebugSession) RecordGRPCSizes(requestBytes, resultBytes int) {
+	if s == nil || !s.capture.Options.GRPCSizes {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.grpcRequestBytes = &requestBytes
+	s.grpcResultBytes = &resultBytes
+}
+
+func (s *DebugSession) entry() debugcapture.Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return debugcapture.Entry{
+		Result:           s.result,
+		ResultTruncated:  s.resultTruncated,
+		GRPCRequestBytes: s.grpcRequestBytes,
+		GRPCResultBytes:  s.grpcResultBytes,
+	}
+}
+
+// truncateField 截取前 maxBytes 字节，不截断 UTF-8 字符；maxBytes 不大于 0 时不截断
+func truncateField(data []byte, maxBytes int) (string, bool) {
+	if maxBytes <= 0 || len(data) <= maxBytes {
+		return string(data), false
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(data[cut]) {
+		cut--
+	}
+	return string(data[:cut]), true
+}
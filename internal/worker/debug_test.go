@@ -0,0 +1,132 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/debugcapture"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+type fakeDebugStore struct {
+	mu       sync.Mutex
+	captures []debugcapture.Capture
+	entries  map[string][]debugcapture.Entry
+}
+
+func (f *fakeDebugStore) Create(_ context.Context, c debugcapture.Capture) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.captures = append(f.captures, c)
+	return nil
+}
+
+func (f *fakeDebugStore) Active(_ context.Context, now time.Time) ([]debugcapture.Capture, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var active []debugcapture.Capture
+	for _, c := range f.captures {
+		if c.Active(now) {
+			active = append(active, c)
+		}
+	}
+	return active, nil
+}
+
+func (f *fakeDebugStore) Append(_ context.Context, c debugcapture.Capture, e debugcapture.Entry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.entries == nil {
+		f.entries = make(map[string][]debugcapture.Entry)
+	}
+	f.entries[c.ID] = append(f.entries[c.ID], e)
+	return nil
+}
+
+func (f *fakeDebugStore) Get(_ context.Context, id string) (debugcapture.Capture, []debugcapture.Entry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.captures {
+		if c.ID == id {
+			return c, f.entries[id], nil
+		}
+	}
+	return debugcapture.Capture{}, nil, debugcapture.ErrNotFound
+}
+
+func TestDebugCaptureRecordsMatchingTasksUntilExpiry(t *testing.T) {
+	store := &fakeDebugStore{}
+	fake := clock.NewFake(time.Unix(0, 0))
+	core, logs := observer.New(zap.DebugLevel)
+	captures := NewDebugCaptures(store, DebugCaptureLimits{MaxDuration: time.Hour, MaxFieldBytes: 8}, zap.NewNop(), zap.New(core), fake)
+
+	if _, err := captures.Start(context.Background(), "demo", 2*time.Hour, debugcapture.Options{}); err == nil {
+		t.Fatal("expected durations above the limit to be rejected")
+	}
+	capture, err := captures.Start(context.Background(), "demo", 10*time.Minute, debugcapture.Options{
+		VerboseLogging:  true,
+		CapturePayloads: true,
+		GRPCSizes:       true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handler := captures.Middleware()(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		TaskLogger(ctx, zap.NewNop()).Debug("handler detail")
+		session := DebugSessionFromContext(ctx)
+		session.RecordResult([]byte(`{"ok":true}`))
+		session.RecordGRPCSizes(12, 34)
+		return errors.New("boom")
+	}))
+
+	if err := handler.ProcessTask(context.Background(), asynq.NewTask("demo", []byte(`{"count":5}`))); err == nil {
+		t.Fatal("expected the handler error to be returned")
+	}
+	if err := handler.ProcessTask(context.Background(), asynq.NewTask("other", nil)); err == nil {
+		t.Fatal("expected the handler error to be returned")
+	}
+
+	entries := store.entries[capture.ID]
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry for the matching type, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Status != StatusFailed || e.Error != "boom" || e.PayloadSize != 11 {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+	if e.Payload != `{"count"` || !e.PayloadTruncated || e.Result != `{"ok":tr` || !e.ResultTruncated {
+		t.Fatalf("expected truncated payload and result, got %+v", e)
+	}
+	if e.GRPCRequestBytes == nil || *e.GRPCRequestBytes != 12 || *e.GRPCResultBytes != 34 {
+		t.Fatalf("expected grpc sizes, got %+v", e)
+	}
+	if logs.FilterMessage("handler detail").Len() != 1 {
+		t.Fatal("expected handler debug logs through the capture logger")
+	}
+
+	report, err := captures.Get(context.Background(), capture.ID)
+	if err != nil || !report.Active || report.Count != 1 {
+		t.Fatalf("expected an active report with one entry, got %+v, %v", report, err)
+	}
+
+	// 采集结束后不再记录
+	fake.Advance(11 * time.Minute)
+	if err := captures.Refresh(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = handler.ProcessTask(context.Background(), asynq.NewTask("demo", nil))
+	if len(store.entries[capture.ID]) != 1 {
+		t.Fatal("expected no entries after the capture expired")
+	}
+	if report, err := captures.Get(context.Background(), capture.ID); err != nil || report.Active {
+		t.Fatalf("expected the report to show the capture as ended, got %+v, %v", report, err)
+	}
+}
@@ -37,6 +37,15 @@ func (h *Handler) ProcessTask(ctx context.Context, task *asynq.Task) error {
 		return err
 	}
 
+	// 校验上线前入队的任务可能带有越界的 count
+	if original, clamped := p.Normalize(); clamped {
+		h.Logger().Warn("demo count out of range, clamped",
+			zap.String("task_id", taskID),
+			zap.Int("count", original),
+			zap.Int("clamped_to", p.Count),
+		)
+	}
+
 	h.Logger().Info("========== Demo Task Started ==========")
 	h.Logger().Info(fmt.Sprintf("Task ID: %s", taskID))
 	h.Logger().Info(fmt.Sprintf("Message: %s", p.Message))
@@ -53,6 +62,11 @@ func (h *Handler) ProcessTask(ctx context.Context, task *asynq.Task) error {
 		case <-time.After(500 * time.Millisecond):
 			h.Logger().Info(fmt.Sprintf("Processing step %d/%d...", i, p.Count))
 		}
+		if i == p.FailAtStep {
+			err := fmt.Errorf("demo task failed at step %d/%d as requested", i, p.Count)
+			h.LogTaskError(h.Type(), taskID, err)
+			return err
+		}
 	}
 
 	h.Logger().Info("========== Demo Task Completed ==========")
@@ -2,13 +2,21 @@ package grpctask
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/hibiken/asynq"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 
 	grpcclient "github.com/Aixtrade/TaskFlow/internal/infrastructure/grpc"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/progresstoken"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/schema"
 	"github.com/Aixtrade/TaskFlow/internal/worker"
 	"github.com/Aixtrade/TaskFlow/pkg/payload"
 	"github.com/Aixtrade/TaskFlow/pkg/progress"
@@ -21,24 +29,71 @@ import (
 type Config struct {
 	Services map[string]grpcclient.ClientConfig `mapstructure:"services"`
 	Defaults grpcclient.ClientConfig            `mapstructure:"defaults"`
+	// BudgetMargin 下游超时与任务截止时间之间保留的余量，用于发布结果和完成事件
+	BudgetMargin time.Duration `mapstructure:"budget_margin"`
+	// MinBudget 扣除余量后剩余时间低于该值时不调用下游，交给 asynq 重试
+	MinBudget time.Duration `mapstructure:"min_budget"`
 }
 
+// BackendMetadataPrefix 完成事件 metadata 中后端响应头和 trailer 的键前缀，
+// 如 x-model-version 记为 backend.x-model-version
+const BackendMetadataPrefix = "backend."
+
+// ErrInsufficientBudget 任务剩余时间不足以调用下游服务
+var ErrInsufficientBudget = errors.New("insufficient time budget for grpc call")
+
 // Handler 处理所有 gRPC 任务
 type Handler struct {
 	*worker.BaseHandler
 	clientManager     *grpcclient.ClientManager
 	config            Config
-	progressPublisher *progress.Publisher
+	progressPublisher progress.EventPublisher
+	results           *worker.ResultSink // 为空时不保存任务结果
+	outputs           OutputValidator    // 为空时不校验结果
+	tokens            *progresstoken.Signer
+	publicURL         string
+}
+
+// OutputValidator 按服务方法注册的 schema 校验 gRPC 返回的结果
+type OutputValidator interface {
+	ValidateOutput(ctx context.Context, service, method string, data []byte) error
+}
+
+// Option handler 可选项
+type Option func(*Handler)
+
+// WithOutputValidator 在保存结果前校验结果结构，不符合时任务失败且不重试
+func WithOutputValidator(v OutputValidator) Option {
+	return func(h *Handler) {
+		h.outputs = v
+	}
+}
+
+// WithProgressTokens 在请求 metadata 中传入进度上报令牌（progress_token），下游可凭令牌通过 HTTP 上报进度。
+// publicURL 为 API 的对外地址，不为空时同时传入上报地址（progress_url）
+func WithProgressTokens(signer *progresstoken.Signer, publicURL string) Option {
+	return func(h *Handler) {
+		h.tokens = signer
+		h.publicURL = strings.TrimRight(publicURL, "/")
+	}
 }
 
-// NewHandler 创建新的 gRPC handler
-func NewHandler(logger *zap.Logger, clientManager *grpcclient.ClientManager, cfg Config, progressPublisher *progress.Publisher) *Handler {
-	return &Handler{
+// NewHandler 创建新的 gRPC handler，progressPublisher 为空时不发布进度和完成事件
+func NewHandler(logger *zap.Logger, clientManager *grpcclient.ClientManager, cfg Config, progressPublisher progress.EventPublisher, results *worker.ResultSink, opts ...Option) *Handler {
+	if progressPublisher == nil {
+		progressPublisher = progress.NopPublisher{}
+	}
+	h := &Handler{
 		BaseHandler:       worker.NewBaseHandler(logger),
 		clientManager:     clientManager,
 		config:            cfg,
 		progressPublisher: progressPublisher,
+		results:           results,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // Type 返回任务类型标识
@@ -50,11 +105,13 @@ func (h *Handler) Type() string {
 func (h *Handler) ProcessTask(ctx context.Context, task *asynq.Task) error {
 	taskID := worker.GetTaskID(ctx)
 	h.LogTaskStart(h.Type(), taskID)
+	// 任务类型处于调试采集中时为 debug 级别的 logger
+	logger := worker.TaskLogger(ctx, h.Logger())
 
 	// 1. 解析 payload
 	p, err := worker.UnmarshalPayload[payload.GRPCTaskPayload](task)
 	if err != nil {
-		h.Logger().Error("failed to unmarshal payload",
+		logger.Error("failed to unmarshal payload",
 			zap.String("task_id", taskID),
 			zap.Error(err),
 		)
@@ -63,7 +120,7 @@ func (h *Handler) ProcessTask(ctx context.Context, task *asynq.Task) error {
 
 	// 2. 验证 payload
 	if err := p.Validate(); err != nil {
-		h.Logger().Error("invalid payload",
+		logger.Error("invalid payload",
 			zap.String("task_id", taskID),
 			zap.Error(err),
 		)
@@ -72,17 +129,57 @@ func (h *Handler) ProcessTask(ctx context.Context, task *asynq.Task) error {
 
 	// 3. 验证服务是否存在
 	if !h.clientManager.HasService(p.Service) {
-		h.Logger().Error("unknown service",
+		logger.Error("unknown service",
 			zap.String("task_id", taskID),
 			zap.String("service", p.Service),
 		)
 		return asynq.SkipRetry // 未知服务，不重试
 	}
 
+	// 在 worker 侧拦截方法名拼写错误，避免交给后端后才失败
+	serviceCfg, _ := h.clientManager.GetServiceConfig(p.Service)
+	method, err := serviceCfg.ResolveMethod(p.Method)
+	if err != nil {
+		logger.Error("invalid method",
+			zap.String("task_id", taskID),
+			zap.String("service", p.Service),
+			zap.String("method", p.Method),
+			zap.Error(err),
+		)
+		return fmt.Errorf("service %s: %v: %w", p.Service, err, asynq.SkipRetry)
+	}
+	p.Method = method
+
+	// 总尝试次数按 asynq 的执行次数计算：ExecuteTask 流不在传输层重试，每次执行只调用一次下游
+	maxAttempts := serviceCfg.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = h.config.Defaults.MaxAttempts
+	}
+	err = h.execute(ctx, task, taskID, p, logger)
+	return h.limitAttempts(logger, taskID, p.Service, worker.GetRetryCount(ctx)+1, maxAttempts, err)
+}
+
+// limitAttempts 第 attempt 次执行失败且已用尽 maxAttempts 时改为不重试，maxAttempts 为 0 表示不限制
+func (h *Handler) limitAttempts(logger *zap.Logger, taskID, service string, attempt, maxAttempts int, err error) error {
+	if err == nil || maxAttempts <= 0 || attempt < maxAttempts || errors.Is(err, asynq.SkipRetry) {
+		return err
+	}
+	logger.Error("grpc task attempts exhausted, giving up",
+		zap.String("task_id", taskID),
+		zap.String("service", service),
+		zap.Int("attempt", attempt),
+		zap.Int("max_attempts", maxAttempts),
+		zap.Error(err),
+	)
+	return fmt.Errorf("%w (attempt %d of %d): %w", err, attempt, maxAttempts, asynq.SkipRetry)
+}
+
+// execute 调用下游服务并处理结果，p 已通过校验且方法已补全
+func (h *Handler) execute(ctx context.Context, task *asynq.Task, taskID string, p *payload.GRPCTaskPayload, logger *zap.Logger) error {
 	// 4. 获取客户端
 	client, err := h.clientManager.GetClient(p.Service)
 	if err != nil {
-		h.Logger().Error("failed to get client",
+		logger.Error("failed to get client",
 			zap.String("task_id", taskID),
 			zap.String("service", p.Service),
 			zap.Error(err),
@@ -92,26 +189,50 @@ func (h *Handler) ProcessTask(ctx context.Context, task *asynq.Task) error {
 
 	// 5. 检查健康状态
 	if !client.IsHealthy() {
-		h.Logger().Warn("service unhealthy, will retry",
+		logger.Warn("service unhealthy, will retry",
 			zap.String("task_id", taskID),
 			zap.String("service", p.Service),
 		)
 		return fmt.Errorf("service %s unavailable", p.Service) // 触发重试
 	}
 
+	// 剩余时间不足时不启动下游调用，任务尚未开始执行，不发布失败事件
+	if budget, ok := worker.GetRemainingBudget(ctx); ok && budget-h.config.BudgetMargin < h.config.MinBudget {
+		logger.Warn("remaining budget too small, will retry",
+			zap.String("task_id", taskID),
+			zap.String("service", p.Service),
+			zap.Duration("remaining", budget),
+			zap.Duration("margin", h.config.BudgetMargin),
+			zap.Duration("min_budget", h.config.MinBudget),
+		)
+		return fmt.Errorf("%w: %s remaining", ErrInsufficientBudget, budget.Round(time.Millisecond))
+	}
+
 	// 6. 构建请求
 	req, err := h.buildRequest(ctx, taskID, p)
 	if err != nil {
-		h.Logger().Error("failed to build request",
+		logger.Error("failed to build request",
 			zap.String("task_id", taskID),
 			zap.Error(err),
 		)
 		return asynq.SkipRetry
 	}
 
-	// 7. 执行任务
-	result, err := client.ExecuteTask(ctx, req, func(prog *pb.Progress) {
-		h.Logger().Info("task progress",
+	// 7. 执行任务，记录每个阶段首次出现的时间
+	milestones := progress.NewMilestoneTracker()
+	result, backendMD, err := client.ExecuteTask(ctx, req, func(prog *pb.Progress) {
+		stageAt := prog.TimestampMs
+		if stageAt == 0 {
+			stageAt = time.Now().UnixMilli()
+		}
+		if milestones.Observe(prog.Stage, stageAt) {
+			logger.Debug("task stage started",
+				zap.String("task_id", taskID),
+				zap.String("stage", prog.Stage),
+			)
+		}
+
+		logger.Info("task progress",
 			zap.String("task_id", taskID),
 			zap.String("service", p.Service),
 			zap.Int32("percentage", prog.Percentage),
@@ -120,34 +241,42 @@ func (h *Handler) ProcessTask(ctx context.Context, task *asynq.Task) error {
 		)
 
 		// 发布进度到 Redis Stream
-		if h.progressPublisher != nil {
-			progressData := &progress.Progress{
-				TaskID:      taskID,
-				Percentage:  prog.Percentage,
-				Stage:       prog.Stage,
-				Message:     prog.Message,
-				TimestampMs: prog.TimestampMs,
-				Metadata:    prog.Metadata,
-			}
-			if pubErr := h.progressPublisher.Publish(ctx, progressData); pubErr != nil {
-				h.Logger().Warn("failed to publish progress",
-					zap.String("task_id", taskID),
-					zap.Error(pubErr),
-				)
-			}
+		progressData := &progress.Progress{
+			TaskID:      taskID,
+			Percentage:  prog.Percentage,
+			Stage:       prog.Stage,
+			Message:     prog.Message,
+			TimestampMs: prog.TimestampMs,
+			Metadata:    prog.Metadata,
+		}
+		if pubErr := h.progressPublisher.Publish(ctx, progressData); pubErr != nil {
+			logger.Warn("failed to publish progress",
+				zap.String("task_id", taskID),
+				zap.Error(pubErr),
+			)
 		}
 	})
 
+	if errors.Is(err, grpcclient.ErrStreamsSaturated) {
+		// 任务尚未开始执行，不发布失败事件，交给 asynq 稍后重试
+		logger.Warn("grpc service saturated, will retry",
+			zap.String("task_id", taskID),
+			zap.String("service", p.Service),
+			zap.Error(err),
+		)
+		return err
+	}
+	// 后端在响应头和 trailer 中返回的上下文（版本、计费单位等）随完成事件发布，调用出错、失败和取消时也保留
+	backend := backendMetadata(backendMD)
 	if err != nil {
 		// 发布失败事件
-		if h.progressPublisher != nil {
-			h.progressPublisher.PublishCompletion(ctx, taskID, "failed", err.Error())
-		}
+		h.publishCompletion(ctx, taskID, "failed", err.Error(), milestones, backend)
 		return h.handleError(taskID, p.Service, err)
 	}
 
 	// 8. 处理结果
-	h.Logger().Info("task result received",
+	worker.DebugSessionFromContext(ctx).RecordGRPCSizes(proto.Size(req), proto.Size(result))
+	logger.Info("task result received",
 		zap.String("task_id", taskID),
 		zap.String("service", p.Service),
 		zap.String("status", result.Status.String()),
@@ -156,29 +285,169 @@ func (h *Handler) ProcessTask(ctx context.Context, task *asynq.Task) error {
 
 	if result.Status == pb.TaskStatus_TASK_STATUS_FAILED {
 		// 发布失败事件
-		if h.progressPublisher != nil {
-			h.progressPublisher.PublishCompletion(ctx, taskID, "failed", "task failed on grpc service")
-		}
+		h.publishCompletion(ctx, taskID, "failed", "task failed on grpc service", milestones, backend)
 		return fmt.Errorf("task failed on grpc service")
 	}
 
 	if result.Status == pb.TaskStatus_TASK_STATUS_CANCELLED {
+		// 返回的是普通错误，需单独报告取消状态
+		worker.ReportStatus(ctx, worker.StatusCancelled)
 		// 发布取消事件
-		if h.progressPublisher != nil {
-			h.progressPublisher.PublishCompletion(ctx, taskID, "cancelled", "task cancelled on grpc service")
-		}
+		h.publishCompletion(ctx, taskID, "cancelled", "task cancelled on grpc service", milestones, backend)
 		return fmt.Errorf("task cancelled on grpc service")
 	}
 
+	// 校验结果是否符合服务方法的输出约定，违反约定属于后端缺陷，重试无意义
+	if err := h.validateOutput(ctx, p, result); err != nil {
+		logger.Error("result rejected by output schema",
+			zap.String("task_id", taskID),
+			zap.String("service", p.Service),
+			zap.String("method", p.Method),
+			zap.Error(err),
+		)
+		h.publishCompletion(ctx, taskID, "failed", err.Error(), milestones, backend)
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+
+	// 保存结果，大结果写入对象存储，完成事件只携带引用
+	resultMeta, err := h.saveResult(ctx, task, taskID, result)
+	if err != nil {
+		logger.Error("failed to save result",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+		h.publishCompletion(ctx, taskID, "failed", err.Error(), milestones, backend)
+		// 下游已执行成功，重试会再次调用下游，结果保存失败不重试；
+		// 文件列表不合规与输出 schema 不符一样属于后端缺陷，同样不重试
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+
 	// 发布完成事件
-	if h.progressPublisher != nil {
-		h.progressPublisher.PublishCompletion(ctx, taskID, "completed", "task completed successfully")
+	for k, v := range backend {
+		if resultMeta == nil {
+			resultMeta = make(map[string]string, len(backend))
+		}
+		resultMeta[k] = v
 	}
+	h.publishCompletionResult(ctx, taskID, "completed", "task completed successfully", milestones, resultMeta, result)
 
 	h.LogTaskComplete(h.Type(), taskID)
 	return nil
 }
 
+// backendMetadata 为后端响应元数据的键加上 BackendMetadataPrefix
+func backendMetadata(md map[string]string) map[string]string {
+	if len(md) == 0 {
+		return nil
+	}
+	prefixed := make(map[string]string, len(md))
+	for k, v := range md {
+		prefixed[BackendMetadataPrefix+k] = v
+	}
+	return prefixed
+}
+
+// validateOutput 校验结果数据，schema 读取失败时放行，避免 Redis 抖动导致任务失败
+func (h *Handler) validateOutput(ctx context.Context, p *payload.GRPCTaskPayload, result *pb.TaskResult) error {
+	if h.outputs == nil {
+		return nil
+	}
+
+	data := []byte("{}")
+	if result.Data != nil {
+		var err error
+		if data, err = protojson.Marshal(result.Data); err != nil {
+			return fmt.Errorf("failed to encode result: %w", err)
+		}
+	}
+
+	err := h.outputs.ValidateOutput(ctx, p.Service, p.Method, data)
+	var schemaErr *schema.OutputSchemaError
+	if err != nil && !errors.As(err, &schemaErr) {
+		h.Logger().Warn("output schema unavailable, skipping validation",
+			zap.String("service", p.Service),
+			zap.String("method", p.Method),
+			zap.Error(err),
+		)
+		return nil
+	}
+	return err
+}
+
+// saveResult 保存 gRPC 返回的结果数据和文件列表，返回需附加到完成事件的 metadata
+func (h *Handler) saveResult(ctx context.Context, task *asynq.Task, taskID string, result *pb.TaskResult) (map[string]string, error) {
+	if h.results == nil {
+		// 未配置结果存储时文件列表无处保存，记录警告而不是静默丢弃
+		if len(result.Artifacts) > 0 {
+			h.Logger().Warn("task artifacts discarded because results are not stored, set results.blob_threshold to keep them",
+				zap.String("task_id", taskID),
+				zap.Int("artifacts", len(result.Artifacts)),
+			)
+		}
+		return nil, nil
+	}
+	if result.Data == nil && len(result.Artifacts) == 0 {
+		return nil, nil
+	}
+
+	var data []byte
+	if result.Data != nil {
+		var err error
+		if data, err = protojson.Marshal(result.Data); err != nil {
+			return nil, fmt.Errorf("failed to encode result: %w", err)
+		}
+	}
+	return h.results.SaveWithArtifacts(ctx, task, taskID, data, artifactsFromProto(result.Artifacts))
+}
+
+func artifactsFromProto(in []*pb.Artifact) []payload.Artifact {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]payload.Artifact, len(in))
+	for i, a := range in {
+		out[i] = payload.Artifact{
+			Name:        a.GetName(),
+			ContentType: a.GetContentType(),
+			Size:        a.GetSize(),
+			URI:         a.GetUri(),
+			Key:         a.GetKey(),
+		}
+	}
+	return out
+}
+
+// publishCompletion 发布完成事件，并在 metadata 中附带阶段时间线和结果
+func (h *Handler) publishCompletion(ctx context.Context, taskID, status, message string, milestones *progress.MilestoneTracker, extra map[string]string) {
+	h.publishCompletionResult(ctx, taskID, status, message, milestones, extra, nil)
+}
+
+// publishCompletionResult 同 publishCompletion，并在完成事件中携带 gRPC 返回的结果数据
+func (h *Handler) publishCompletionResult(ctx context.Context, taskID, status, message string, milestones *progress.MilestoneTracker, extra map[string]string, result *pb.TaskResult) {
+	var data any
+	if result != nil && result.Data != nil {
+		if raw, err := protojson.Marshal(result.Data); err == nil {
+			data = json.RawMessage(raw)
+		}
+	}
+
+	metadata := milestones.Metadata(time.Now().UnixMilli())
+	if len(extra) > 0 {
+		if metadata == nil {
+			metadata = make(map[string]string, len(extra))
+		}
+		for k, v := range extra {
+			metadata[k] = v
+		}
+	}
+	if err := h.progressPublisher.PublishCompletionWithResult(ctx, taskID, status, message, metadata, data); err != nil {
+		h.Logger().Warn("failed to publish completion",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+	}
+}
+
 // buildRequest 构建 gRPC 请求
 func (h *Handler) buildRequest(ctx context.Context, taskID string, p *payload.GRPCTaskPayload) (*pb.ExecuteTaskRequest, error) {
 	// 获取服务配置
@@ -195,6 +464,15 @@ func (h *Handler) buildRequest(ctx context.Context, taskID string, p *payload.GR
 	if p.Options != nil && p.Options.TimeoutMs != nil {
 		timeout = time.Duration(*p.Options.TimeoutMs) * time.Millisecond
 	}
+	// 下游超时不能超过任务剩余时间，否则 asynq 会先于下游结束任务
+	if budget, ok := worker.GetRemainingBudget(ctx); ok && timeout > budget-h.config.BudgetMargin {
+		h.Logger().Debug("shrinking downstream timeout to fit task deadline",
+			zap.String("task_id", taskID),
+			zap.Duration("timeout", timeout),
+			zap.Duration("remaining", budget),
+		)
+		timeout = budget - h.config.BudgetMargin
+	}
 
 	// 构建 payload struct
 	dataStruct, err := grpcclient.BuildPayloadStruct(p.Data)
@@ -231,6 +509,15 @@ func (h *Handler) buildRequest(ctx context.Context, taskID string, p *payload.GR
 		},
 	}
 
+	if h.tokens != nil {
+		// 令牌随任务截止时间过期
+		deadline, _ := ctx.Deadline()
+		req.Metadata["progress_token"] = h.tokens.Sign(taskID, worker.GetQueueName(ctx), worker.GetRetryCount(ctx)+1, time.Now(), deadline)
+		if h.publicURL != "" {
+			req.Metadata["progress_url"] = h.publicURL + "/api/v1/tasks/" + url.PathEscape(taskID) + "/progress"
+		}
+	}
+
 	return req, nil
 }
 
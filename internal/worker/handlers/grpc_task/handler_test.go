@@ -0,0 +1,225 @@
+package grpctask
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	pb "github.com/Aixtrade/TaskFlow/api/proto/grpc_task/v1"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/blobstore"
+	grpcclient "github.com/Aixtrade/TaskFlow/internal/infrastructure/grpc"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/progresstoken"
+	"github.com/Aixtrade/TaskFlow/internal/worker"
+	"github.com/Aixtrade/TaskFlow/pkg/payload"
+	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
+)
+
+func newTestHandler(t *testing.T, cfg Config) *Handler {
+	t.Helper()
+	// 客户端惰性连接，测试不会真正拨号
+	manager, err := grpcclient.NewClientManager(map[string]grpcclient.ClientConfig{
+		"llm": {Address: "passthrough:///unused", Timeout: 5 * time.Minute},
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(manager.Close)
+	return NewHandler(zap.NewNop(), manager, cfg, nil, nil)
+}
+
+func TestBuildRequestShrinksTimeoutToRemainingBudget(t *testing.T) {
+	h := newTestHandler(t, Config{BudgetMargin: time.Second})
+
+	tests := []struct {
+		name     string
+		deadline time.Duration // 0 表示没有截止时间
+		wantMax  time.Duration
+		wantMin  time.Duration
+	}{
+		{name: "no deadline", wantMax: 5 * time.Minute, wantMin: 5 * time.Minute},
+		{name: "ample budget", deadline: time.Hour, wantMax: 5 * time.Minute, wantMin: 5 * time.Minute},
+		{name: "short budget", deadline: 10 * time.Second, wantMax: 9 * time.Second, wantMin: 8 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+
+			req, err := h.buildRequest(ctx, "task-1", &payload.GRPCTaskPayload{Service: "llm", Method: "chat"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := time.Duration(req.Options.TimeoutMs) * time.Millisecond
+			if got > tt.wantMax || got < tt.wantMin {
+				t.Fatalf("expected timeout in [%v, %v], got %v", tt.wantMin, tt.wantMax, got)
+			}
+		})
+	}
+}
+
+func TestBuildRequestIncludesProgressToken(t *testing.T) {
+	h := newTestHandler(t, Config{})
+	signer := progresstoken.NewSigner("secret", time.Hour)
+	WithProgressTokens(signer, "https://taskflow.example.com/")(h)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	req, err := h.buildRequest(ctx, "task-1", &payload.GRPCTaskPayload{Service: "llm", Method: "chat"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	claims, err := signer.Verify(req.Metadata["progress_token"], "task-1", time.Now())
+	if err != nil {
+		t.Fatalf("expected a valid token for the task: %v", err)
+	}
+	// 令牌随任务截止时间过期
+	deadline, _ := ctx.Deadline()
+	if claims.ExpiresAt != deadline.Unix() {
+		t.Fatalf("expected token to expire at the task deadline, got %d", claims.ExpiresAt)
+	}
+	if claims.Attempt != 1 {
+		t.Fatalf("expected token for the first attempt, got %d", claims.Attempt)
+	}
+	if got := req.Metadata["progress_url"]; got != "https://taskflow.example.com/api/v1/tasks/task-1/progress" {
+		t.Fatalf("unexpected progress_url %q", got)
+	}
+}
+
+func TestProcessTaskRefusesWhenBudgetBelowFloor(t *testing.T) {
+	h := newTestHandler(t, Config{BudgetMargin: 500 * time.Millisecond, MinBudget: time.Second})
+
+	data, _ := json.Marshal(payload.GRPCTaskPayload{Service: "llm", Method: "chat"})
+	ctx, cancel := context.WithTimeout(context.Background(), 1200*time.Millisecond)
+	defer cancel()
+
+	err := h.ProcessTask(ctx, asynq.NewTask(tasktype.GRPCTask.String(), data))
+	if !errors.Is(err, ErrInsufficientBudget) {
+		t.Fatalf("expected ErrInsufficientBudget, got %v", err)
+	}
+	if errors.Is(err, asynq.SkipRetry) {
+		t.Fatal("expected the task to stay retryable")
+	}
+}
+
+func TestLimitAttemptsSkipsRetryOnceBudgetIsUsed(t *testing.T) {
+	h := newTestHandler(t, Config{})
+	failure := errors.New("backend unavailable")
+
+	tests := []struct {
+		name        string
+		attempt     int
+		maxAttempts int
+		wantSkip    bool
+	}{
+		{"unlimited", 10, 0, false},
+		{"budget left", 1, 2, false},
+		{"last attempt", 2, 2, true},
+		{"budget lowered after earlier attempts", 3, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := h.limitAttempts(zap.NewNop(), "task-1", "llm", tt.attempt, tt.maxAttempts, failure)
+			if !errors.Is(err, failure) {
+				t.Fatalf("expected the original error to be kept, got %v", err)
+			}
+			if got := errors.Is(err, asynq.SkipRetry); got != tt.wantSkip {
+				t.Fatalf("SkipRetry = %v, want %v (err: %v)", got, tt.wantSkip, err)
+			}
+		})
+	}
+
+	if err := h.limitAttempts(zap.NewNop(), "task-1", "llm", 5, 1, nil); err != nil {
+		t.Fatalf("expected nil for a successful attempt, got %v", err)
+	}
+}
+
+func TestProcessTaskAppliesDefaultMaxAttempts(t *testing.T) {
+	h := newTestHandler(t, Config{
+		Defaults:     grpcclient.ClientConfig{MaxAttempts: 1},
+		BudgetMargin: 500 * time.Millisecond,
+		MinBudget:    time.Second,
+	})
+
+	data, _ := json.Marshal(payload.GRPCTaskPayload{Service: "llm", Method: "chat"})
+	ctx, cancel := context.WithTimeout(context.Background(), 1200*time.Millisecond)
+	defer cancel()
+
+	// 首次执行即用尽预算，可重试的错误也不再重试
+	err := h.ProcessTask(ctx, asynq.NewTask(tasktype.GRPCTask.String(), data))
+	if !errors.Is(err, ErrInsufficientBudget) || !errors.Is(err, asynq.SkipRetry) {
+		t.Fatalf("expected ErrInsufficientBudget with SkipRetry, got %v", err)
+	}
+}
+
+// completingExecutor 每次调用都成功完成并返回结果数据
+type completingExecutor struct {
+	pb.UnimplementedTaskExecutorServiceServer
+	calls atomic.Int32
+}
+
+func (e *completingExecutor) ExecuteTask(req *pb.ExecuteTaskRequest, stream pb.TaskExecutorService_ExecuteTaskServer) error {
+	e.calls.Add(1)
+	data, _ := structpb.NewStruct(map[string]any{"answer": "42"})
+	return stream.Send(&pb.ExecuteTaskResponse{Response: &pb.ExecuteTaskResponse_Result{
+		Result: &pb.TaskResult{TaskId: req.TaskId, Status: pb.TaskStatus_TASK_STATUS_COMPLETED, Data: data},
+	}})
+}
+
+type failingStore struct {
+	blobstore.Store
+}
+
+func (failingStore) Put(context.Context, string, io.Reader, string) (*blobstore.Object, error) {
+	return nil, errors.New("bucket unavailable")
+}
+
+func TestProcessTaskDoesNotRetryWhenResultCannotBeSaved(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	executor := &completingExecutor{}
+	srv := grpc.NewServer()
+	pb.RegisterTaskExecutorServiceServer(srv, executor)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	manager, err := grpcclient.NewClientManager(map[string]grpcclient.ClientConfig{
+		"llm": {Address: lis.Addr().String(), Timeout: time.Minute},
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(manager.Close)
+	// 阈值为 0，结果全部写入对象存储
+	h := NewHandler(zap.NewNop(), manager, Config{}, nil, worker.NewResultSink(failingStore{}, 0))
+
+	data, _ := json.Marshal(payload.GRPCTaskPayload{Service: "llm", Method: "chat"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 下游已成功执行，重试会再次调用下游
+	err = h.ProcessTask(ctx, asynq.NewTask(tasktype.GRPCTask.String(), data))
+	if err == nil || !errors.Is(err, asynq.SkipRetry) {
+		t.Fatalf("expected the save failure to skip retries, got %v", err)
+	}
+	if n := executor.calls.Load(); n != 1 {
+		t.Fatalf("expected the downstream to be called once, got %d", n)
+	}
+}
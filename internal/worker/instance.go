@@ -0,0 +1,28 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+// InstanceMiddleware 在 context 中记录处理任务的 worker 实例，
+// 之后发布的进度、重试开始和完成事件都带上实例 ID 和版本，便于排查不同环境结果不一致的问题
+func InstanceMiddleware(instance progress.Worker) asynq.MiddlewareFunc {
+	return func(h asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			return h.ProcessTask(progress.WithWorker(ctx, instance), t)
+		})
+	}
+}
+
+// TaskTypeMiddleware 在 context 中记录任务类型，进度发布器据此选择该类型的进度流保留策略
+func TaskTypeMiddleware() asynq.MiddlewareFunc {
+	return func(h asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			return h.ProcessTask(progress.WithTaskType(ctx, t.Type()), t)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+// TaskEvent 生命周期回调收到的任务信息
+type TaskEvent struct {
+	ID        string
+	Type      string
+	Queue     string
+	Retry     int
+	MaxRetry  int
+	StartedAt time.Time
+
+	// 以下字段仅在执行结束时设置，Status 取值同 AttemptStatus：还会重试的失败执行为 retry，
+	// 最后一次执行为 completed、failed（含 panic）、cancelled 或 timed_out
+	Duration time.Duration
+	Status   string
+	Err      error
+}
+
+// TaskHook 任务开始或结束时调用，在处理任务的 goroutine 中同步执行，耗时会计入任务执行时间
+type TaskHook func(ctx context.Context, event TaskEvent)
+
+// LifecycleHooks 嵌入 TaskFlow 的服务在任务开始和结束时执行的回调，按注册顺序调用
+type LifecycleHooks struct {
+	OnStart    []TaskHook
+	OnComplete []TaskHook
+}
+
+// Empty 是否没有任何回调
+func (h LifecycleHooks) Empty() bool {
+	return len(h.OnStart) == 0 && len(h.OnComplete) == 0
+}
+
+// LifecycleMiddleware 在每次执行前后调用回调。应注册在 RecoveryMiddleware 之外，
+// 处理器 panic 时 OnComplete 才能收到转换后的错误
+func LifecycleMiddleware(hooks LifecycleHooks, clk clock.Clock) asynq.MiddlewareFunc {
+	clk = clock.OrReal(clk)
+	return func(h asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			event := TaskEvent{
+				ID:        GetTaskID(ctx),
+				Type:      t.Type(),
+				Queue:     GetQueueName(ctx),
+				Retry:     GetRetryCount(ctx),
+				MaxRetry:  GetMaxRetry(ctx),
+				StartedAt: clk.Now(),
+			}
+			for _, hook := range hooks.OnStart {
+				hook(ctx, event)
+			}
+
+			err := h.ProcessTask(ctx, t)
+
+			event.Duration = clk.Since(event.StartedAt)
+			event.Status = AttemptStatus(ctx, err)
+			event.Err = err
+			for _, hook := range hooks.OnComplete {
+				hook(ctx, event)
+			}
+			return err
+		})
+	}
+}
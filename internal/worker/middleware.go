@@ -6,12 +6,15 @@ import (
 
 	"github.com/hibiken/asynq"
 	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
 )
 
-func LoggingMiddleware(logger *zap.Logger) asynq.MiddlewareFunc {
+func LoggingMiddleware(logger *zap.Logger, clk clock.Clock) asynq.MiddlewareFunc {
+	clk = clock.OrReal(clk)
 	return func(h asynq.Handler) asynq.Handler {
 		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
-			start := time.Now()
+			start := clk.Now()
 			taskID := GetTaskID(ctx)
 
 			logger.Info("processing task",
@@ -22,7 +25,7 @@ func LoggingMiddleware(logger *zap.Logger) asynq.MiddlewareFunc {
 
 			err := h.ProcessTask(ctx, t)
 
-			duration := time.Since(start)
+			duration := clk.Since(start)
 
 			if err != nil {
 				logger.Error("task failed",
@@ -44,17 +47,28 @@ func LoggingMiddleware(logger *zap.Logger) asynq.MiddlewareFunc {
 	}
 }
 
-func RecoveryMiddleware(logger *zap.Logger) asynq.MiddlewareFunc {
+// RecoveryMiddleware 恢复处理器 panic，返回 *PanicError（不重试）
+// recorder 不为空时按 classify 的结果记录 panic 指标，classify 为空时使用内置分类
+func RecoveryMiddleware(logger *zap.Logger, recorder PanicRecorder, classify PanicClassifier) asynq.MiddlewareFunc {
+	if classify == nil {
+		classify = NewPanicClassifier(nil)
+	}
 	return func(h asynq.Handler) asynq.Handler {
 		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) (err error) {
 			defer func() {
 				if r := recover(); r != nil {
+					class := classify(r)
 					logger.Error("task panic recovered",
 						zap.String("type", t.Type()),
 						zap.String("task_id", GetTaskID(ctx)),
+						zap.String("class", class),
 						zap.Any("panic", r),
+						zap.Stack("stack"),
 					)
-					err = asynq.SkipRetry
+					if recorder != nil {
+						recorder.RecordPanic(t.Type(), class)
+					}
+					err = &PanicError{Value: r, Class: class}
 				}
 			}()
 
@@ -63,6 +77,37 @@ func RecoveryMiddleware(logger *zap.Logger) asynq.MiddlewareFunc {
 	}
 }
 
+// TaskObserver 记录正常结束的任务，由指标实现
+type TaskObserver interface {
+	ObserveTask(taskType, status string, duration time.Duration)
+}
+
+// MetricsMiddleware 记录每次执行的状态与耗时，状态优先使用处理器通过 ReportStatus 报告的值，否则由 AttemptStatus 推断：
+// asynq 还会重试的失败执行（包括 gRPC 服务并发流已满）记为 retry，不计入 failed。
+// panic 由 RecoveryMiddleware 单独计数，这里不会再记为失败，与两者的注册顺序无关
+func MetricsMiddleware(observer TaskObserver, clk clock.Clock) asynq.MiddlewareFunc {
+	clk = clock.OrReal(clk)
+	return func(h asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			start := clk.Now()
+			ctx, reported := withStatusHolder(ctx)
+
+			// panic 穿过此处时不会执行到记录逻辑
+			err := h.ProcessTask(ctx, t)
+			if IsPanic(err) {
+				return err
+			}
+
+			status := reported.get()
+			if status == "" {
+				status = AttemptStatus(ctx, err)
+			}
+			observer.ObserveTask(t.Type(), status, clk.Since(start))
+			return err
+		})
+	}
+}
+
 func TimeoutMiddleware(timeout time.Duration) asynq.MiddlewareFunc {
 	return func(h asynq.Handler) asynq.Handler {
 		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
@@ -0,0 +1,590 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+func TestLoggingMiddlewareMeasuresWithClock(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	fake := clock.NewFake(time.Unix(0, 0))
+
+	handler := LoggingMiddleware(zap.New(core), fake)(asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		fake.Advance(90 * time.Second)
+		return nil
+	}))
+
+	if err := handler.ProcessTask(context.Background(), asynq.NewTask("demo", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries := logs.FilterMessage("task completed").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 completion log, got %d", len(entries))
+	}
+	if got := entries[0].ContextMap()["duration"]; got != 90*time.Second {
+		t.Fatalf("expected 90s duration, got %v", got)
+	}
+}
+
+type fakeTaskMetrics struct {
+	observed map[string]int
+	panics   map[string]int
+}
+
+func newFakeTaskMetrics() *fakeTaskMetrics {
+	return &fakeTaskMetrics{observed: map[string]int{}, panics: map[string]int{}}
+}
+
+func (m *fakeTaskMetrics) ObserveTask(taskType, status string, _ time.Duration) {
+	m.observed[taskType+"/"+status]++
+}
+
+func (m *fakeTaskMetrics) RecordPanic(taskType, class string) {
+	m.panics[taskType+"/"+class]++
+}
+
+func TestPanicCountedOnceRegardlessOfOrder(t *testing.T) {
+	panicking := asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		var m map[string]int
+		m["boom"]++
+		return nil
+	})
+
+	orders := map[string]func(*fakeTaskMetrics) asynq.Handler{
+		"recovery outside": func(m *fakeTaskMetrics) asynq.Handler {
+			return RecoveryMiddleware(zap.NewNop(), m, nil)(MetricsMiddleware(m, nil)(panicking))
+		},
+		"metrics outside": func(m *fakeTaskMetrics) asynq.Handler {
+			return MetricsMiddleware(m, nil)(RecoveryMiddleware(zap.NewNop(), m, nil)(panicking))
+		},
+	}
+
+	for name, build := range orders {
+		t.Run(name, func(t *testing.T) {
+			m := newFakeTaskMetrics()
+			err := build(m).ProcessTask(context.Background(), asynq.NewTask("demo", nil))
+
+			if !IsPanic(err) || !errors.Is(err, asynq.SkipRetry) {
+				t.Fatalf("expected panic error wrapping SkipRetry, got %v", err)
+			}
+			if m.panics["demo/"+PanicClassRuntimeError] != 1 {
+				t.Fatalf("expected one runtime_error panic, got %v", m.panics)
+			}
+			if len(m.observed) != 0 {
+				t.Fatalf("expected panic not to be observed as a task result, got %v", m.observed)
+			}
+		})
+	}
+}
+
+func TestMetricsMiddlewareRecordsTerminalStatus(t *testing.T) {
+	cases := map[string]struct {
+		handler func(ctx context.Context) error
+		timeout bool
+		want    string
+	}{
+		"completed": {handler: func(context.Context) error { return nil }, want: StatusCompleted},
+		"failed":    {handler: func(context.Context) error { return errors.New("backend unavailable") }, want: StatusFailed},
+		"timed out": {
+			handler: func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() },
+			timeout: true,
+			want:    StatusTimedOut,
+		},
+		"grpc deadline": {
+			handler: func(context.Context) error { return status.Error(codes.DeadlineExceeded, "slow backend") },
+			want:    StatusTimedOut,
+		},
+		"reported": {
+			handler: func(ctx context.Context) error {
+				ReportStatus(ctx, StatusCancelled)
+				return errors.New("task cancelled on grpc service")
+			},
+			want: StatusCancelled,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := newFakeTaskMetrics()
+			handler := MetricsMiddleware(m, nil)(asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+				return tc.handler(ctx)
+			}))
+
+			ctx := context.Background()
+			if tc.timeout {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, time.Millisecond)
+				defer cancel()
+			}
+			_ = handler.ProcessTask(ctx, asynq.NewTask("demo", nil))
+			if m.observed["demo/"+tc.want] != 1 {
+				t.Fatalf("expected one %s, got %v", tc.want, m.observed)
+			}
+		})
+	}
+}
+
+type statusObserver chan string
+
+func (o statusObserver) ObserveTask(_, status string, _ time.Duration) {
+	o <- status
+}
+
+func TestMetricsMiddlewareRecordsRetriedAttempts(t *testing.T) {
+	mr := miniredis.RunT(t)
+	statuses := make(statusObserver, 2)
+
+	srv := asynq.NewServer(asynq.RedisClientOpt{Addr: mr.Addr()}, asynq.Config{
+		Concurrency:              1,
+		LogLevel:                 asynq.FatalLevel,
+		DelayedTaskCheckInterval: 10 * time.Millisecond,
+		RetryDelayFunc:           func(int, error, *asynq.Task) time.Duration { return 0 },
+	})
+	mux := asynq.NewServeMux()
+	mux.Use(MetricsMiddleware(statuses, nil))
+	mux.HandleFunc("flaky", func(ctx context.Context, t *asynq.Task) error {
+		return errors.New("stream limit reached")
+	})
+	if err := srv.Start(mux); err != nil {
+		t.Fatalf("start server: %v", err)
+	}
+	defer srv.Shutdown()
+
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: mr.Addr()})
+	defer client.Close()
+	if _, err := client.Enqueue(asynq.NewTask("flaky", nil), asynq.MaxRetry(1)); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	// 第一次失败还会重试，最后一次失败才记为 failed
+	for _, want := range []string{StatusRetry, StatusFailed} {
+		select {
+		case got := <-statuses:
+			if got != want {
+				t.Fatalf("status = %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no observation for %s attempt", want)
+		}
+	}
+}
+
+func TestPanicClassifierRules(t *testing.T) {
+	classify := NewPanicClassifier([]PanicRule{{Class: "nil_client", Match: "client is nil"}})
+
+	cases := map[string]struct {
+		value any
+		want  string
+	}{
+		"rule":   {value: "grpc client is nil", want: "nil_client"},
+		"string": {value: "unexpected", want: PanicClassString},
+		"error":  {value: errors.New("bad"), want: PanicClassError},
+		"other":  {value: 42, want: PanicClassUnknown},
+	}
+	for name, tc := range cases {
+		if got := classify(tc.value); got != tc.want {
+			t.Fatalf("%s: expected %q, got %q", name, tc.want, got)
+		}
+	}
+}
+
+func TestActiveTasksTracksRunningTasks(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	active := NewActiveTasks(fake)
+
+	var during []ActiveTask
+	handler := active.Middleware()(asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		fake.Advance(5 * time.Second)
+		during = active.List()
+		panic("boom")
+	}))
+
+	func() {
+		defer func() { _ = recover() }()
+		_ = handler.ProcessTask(context.Background(), asynq.NewTask("demo", nil))
+	}()
+
+	if len(during) != 1 || during[0].Type != "demo" || during[0].Elapsed != 5*time.Second {
+		t.Fatalf("expected running task with 5s elapsed, got %+v", during)
+	}
+	if got := active.List(); len(got) != 0 {
+		t.Fatalf("expected task to be removed after it finished, got %+v", got)
+	}
+}
+
+type fakeCompletionPublisher struct {
+	status   string
+	metadata map[string]string
+}
+
+func (p *fakeCompletionPublisher) PublishCompletionIfMissing(_ context.Context, _ string, _ time.Time, status, _ string, metadata map[string]string) (bool, error) {
+	p.status = status
+	p.metadata = metadata
+	return true, nil
+}
+
+func TestCompletionMiddlewarePublishesOutcome(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	publisher := &fakeCompletionPublisher{}
+	failing := asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		fake.Advance(2 * time.Second)
+		return errors.New("backend down")
+	})
+
+	err := CompletionMiddleware(publisher, zap.NewNop(), fake)(failing).ProcessTask(context.Background(), asynq.NewTask("demo", nil))
+	if err == nil {
+		t.Fatal("expected handler error to be returned")
+	}
+	if publisher.status != "failed" || publisher.metadata["error"] != "backend down" || publisher.metadata["duration_ms"] != "2000" {
+		t.Fatalf("unexpected completion: %s %v", publisher.status, publisher.metadata)
+	}
+
+	panicking := asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		panic("boom")
+	})
+	handler := RecoveryMiddleware(zap.NewNop(), nil, nil)(CompletionMiddleware(publisher, zap.NewNop(), fake)(panicking))
+	publisher.status = ""
+	if err := handler.ProcessTask(context.Background(), asynq.NewTask("demo", nil)); !IsPanic(err) {
+		t.Fatalf("expected panic error, got %v", err)
+	}
+	if publisher.status != "failed" {
+		t.Fatalf("expected failed completion for panic, got %q", publisher.status)
+	}
+}
+
+// fakeProgressPublisher 记录开始进度和完成事件，Publish 按 publishErr 失败
+type fakeProgressPublisher struct {
+	publishErr error
+	started    chan *progress.Progress
+	completed  chan string
+}
+
+func (p *fakeProgressPublisher) Publish(_ context.Context, prog *progress.Progress) error {
+	p.started <- prog
+	return p.publishErr
+}
+
+func (p *fakeProgressPublisher) PublishCompletionIfMissing(_ context.Context, taskID string, _ time.Time, status, _ string, _ map[string]string) (bool, error) {
+	p.completed <- taskID + ":" + status
+	return true, nil
+}
+
+func TestProgressMiddlewarePublishesLifecycle(t *testing.T) {
+	mr := miniredis.RunT(t)
+	publisher := &fakeProgressPublisher{
+		publishErr: errors.New("redis down"),
+		started:    make(chan *progress.Progress, 2),
+		completed:  make(chan string, 2),
+	}
+	core, logs := observer.New(zap.WarnLevel)
+
+	srv := asynq.NewServer(asynq.RedisClientOpt{Addr: mr.Addr()}, asynq.Config{Concurrency: 1, LogLevel: asynq.FatalLevel})
+	mux := asynq.NewServeMux()
+	mux.Use(ProgressMiddleware(publisher, zap.New(core), nil))
+	mux.HandleFunc("ok", func(ctx context.Context, t *asynq.Task) error { return nil })
+	mux.HandleFunc("fail", func(ctx context.Context, t *asynq.Task) error { return errors.New("backend down") })
+	if err := srv.Start(mux); err != nil {
+		t.Fatalf("start server: %v", err)
+	}
+	defer srv.Shutdown()
+
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: mr.Addr()})
+	defer client.Close()
+	for _, typ := range []string{"ok", "fail"} {
+		info, err := client.Enqueue(asynq.NewTask(typ, nil), asynq.MaxRetry(0))
+		if err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+
+		select {
+		case prog := <-publisher.started:
+			if prog.TaskID != info.ID || prog.Percentage != 0 || prog.Stage != StageStarted {
+				t.Fatalf("unexpected start progress %+v", prog)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: start progress not published", typ)
+		}
+		want := info.ID + ":completed"
+		if typ == "fail" {
+			want = info.ID + ":failed"
+		}
+		select {
+		case got := <-publisher.completed:
+			if got != want {
+				t.Fatalf("completion = %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: completion not published", typ)
+		}
+	}
+	// 开始进度发布失败只记录警告，任务照常执行并发布完成事件
+	if logs.FilterMessage("failed to publish task start").Len() != 2 {
+		t.Fatalf("expected a warning per failed start publish, got %v", logs.All())
+	}
+}
+
+func TestProgressMiddlewareSkipsTasksWithoutID(t *testing.T) {
+	publisher := &fakeProgressPublisher{started: make(chan *progress.Progress, 1), completed: make(chan string, 1)}
+	called := false
+	handler := asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		called = true
+		return nil
+	})
+
+	if err := ProgressMiddleware(publisher, zap.NewNop(), nil)(handler).ProcessTask(context.Background(), asynq.NewTask("demo", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !called {
+		t.Fatal("expected handler to run")
+	}
+	if len(publisher.started) != 0 || len(publisher.completed) != 0 {
+		t.Fatal("expected nothing to be published without a task id")
+	}
+}
+
+type fakeAttemptPublisher struct {
+	started []string
+}
+
+func (p *fakeAttemptPublisher) PublishAttemptStarted(_ context.Context, taskID string) error {
+	p.started = append(p.started, taskID)
+	return nil
+}
+
+func TestAttemptMiddlewareRecordsFirstAttempt(t *testing.T) {
+	publisher := &fakeAttemptPublisher{}
+	var attempt, maxAttempts int32
+	handler := asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		attempt, maxAttempts, _ = progress.AttemptFromContext(ctx)
+		return nil
+	})
+
+	if err := AttemptMiddleware(publisher, zap.NewNop())(handler).ProcessTask(context.Background(), asynq.NewTask("demo", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempt != 1 || maxAttempts != 1 {
+		t.Fatalf("expected attempt 1 of 1, got %d of %d", attempt, maxAttempts)
+	}
+	// 首次执行不是重试，不发布 attempt_started
+	if len(publisher.started) != 0 {
+		t.Fatalf("expected no attempt_started event, got %v", publisher.started)
+	}
+}
+
+func TestInstanceMiddlewareRecordsWorker(t *testing.T) {
+	var got progress.Worker
+	handler := asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		got, _ = progress.WorkerFromContext(ctx)
+		return nil
+	})
+
+	instance := progress.Worker{ID: "worker-1-abcd1234", Version: "v1.2.3"}
+	if err := InstanceMiddleware(instance)(handler).ProcessTask(context.Background(), asynq.NewTask("demo", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != instance {
+		t.Fatalf("expected %+v in context, got %+v", instance, got)
+	}
+}
+
+func TestLifecycleMiddlewareCallsHooks(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	var events []string
+	var completed TaskEvent
+	hooks := LifecycleHooks{
+		OnStart: []TaskHook{func(ctx context.Context, e TaskEvent) {
+			events = append(events, "start:"+e.Type)
+		}},
+		OnComplete: []TaskHook{func(ctx context.Context, e TaskEvent) {
+			events = append(events, "complete:"+e.Status)
+			completed = e
+		}},
+	}
+
+	handler := LifecycleMiddleware(hooks, fake)(RecoveryMiddleware(zap.NewNop(), nil, nil)(asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		events = append(events, "process")
+		fake.Advance(3 * time.Second)
+		panic("boom")
+	})))
+
+	err := handler.ProcessTask(context.Background(), asynq.NewTask("demo", nil))
+	if !IsPanic(err) {
+		t.Fatalf("expected panic error, got %v", err)
+	}
+	if got := strings.Join(events, ","); got != "start:demo,process,complete:failed" {
+		t.Fatalf("unexpected hook order %q", got)
+	}
+	if completed.Duration != 3*time.Second || !IsPanic(completed.Err) {
+		t.Fatalf("unexpected completion event %+v", completed)
+	}
+}
+
+func TestLifecycleMiddlewareReportsRetriedAttempts(t *testing.T) {
+	mr := miniredis.RunT(t)
+	statuses := make(chan string, 2)
+	hooks := LifecycleHooks{OnComplete: []TaskHook{func(ctx context.Context, e TaskEvent) {
+		statuses <- e.Status
+	}}}
+
+	srv := asynq.NewServer(asynq.RedisClientOpt{Addr: mr.Addr()}, asynq.Config{
+		Concurrency:              1,
+		LogLevel:                 asynq.FatalLevel,
+		DelayedTaskCheckInterval: 10 * time.Millisecond,
+		RetryDelayFunc:           func(int, error, *asynq.Task) time.Duration { return 0 },
+	})
+	mux := asynq.NewServeMux()
+	mux.Use(LifecycleMiddleware(hooks, nil))
+	mux.HandleFunc("flaky", func(ctx context.Context, t *asynq.Task) error {
+		if GetRetryCount(ctx) == 0 {
+			return errors.New("backend busy")
+		}
+		return nil
+	})
+	if err := srv.Start(mux); err != nil {
+		t.Fatalf("start server: %v", err)
+	}
+	defer srv.Shutdown()
+
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: mr.Addr()})
+	defer client.Close()
+	if _, err := client.Enqueue(asynq.NewTask("flaky", nil), asynq.MaxRetry(1)); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	for _, want := range []string{StatusRetry, StatusCompleted} {
+		select {
+		case got := <-statuses:
+			if got != want {
+				t.Fatalf("status = %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("hook not called for %s attempt", want)
+		}
+	}
+}
+
+func TestCompletionMiddlewareSkipsRetriedAttempts(t *testing.T) {
+	mr := miniredis.RunT(t)
+	publisher := &fakeProgressPublisher{completed: make(chan string, 2)}
+
+	srv := asynq.NewServer(asynq.RedisClientOpt{Addr: mr.Addr()}, asynq.Config{
+		Concurrency:              1,
+		LogLevel:                 asynq.FatalLevel,
+		DelayedTaskCheckInterval: 10 * time.Millisecond,
+		RetryDelayFunc:           func(int, error, *asynq.Task) time.Duration { return 0 },
+	})
+	mux := asynq.NewServeMux()
+	mux.Use(CompletionMiddleware(publisher, zap.NewNop(), nil))
+	attempts := make(chan int, 2)
+	mux.HandleFunc("flaky", func(ctx context.Context, t *asynq.Task) error {
+		retry := GetRetryCount(ctx)
+		attempts <- retry
+		if retry == 0 {
+			return errors.New("backend busy")
+		}
+		return nil
+	})
+	if err := srv.Start(mux); err != nil {
+		t.Fatalf("start server: %v", err)
+	}
+	defer srv.Shutdown()
+
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: mr.Addr()})
+	defer client.Close()
+	info, err := client.Enqueue(asynq.NewTask("flaky", nil), asynq.MaxRetry(1))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	// 第一次执行失败后会重试，不发布完成事件；重试成功后只发布一次 completed
+	for want := range 2 {
+		select {
+		case got := <-attempts:
+			if got != want {
+				t.Fatalf("attempt retry count = %d, want %d", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("attempt %d not executed", want)
+		}
+	}
+	select {
+	case got := <-publisher.completed:
+		if got != info.ID+":completed" {
+			t.Fatalf("completion = %q, want only the final completed event", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("completion not published")
+	}
+	select {
+	case got := <-publisher.completed:
+		t.Fatalf("unexpected extra completion %q", got)
+	default:
+	}
+}
+
+func TestProgressMiddlewareKeepsStreamOpenAcrossRetries(t *testing.T) {
+	mr := miniredis.RunT(t)
+	publisher := &fakeProgressPublisher{
+		started:   make(chan *progress.Progress, 2),
+		completed: make(chan string, 2),
+	}
+
+	srv := asynq.NewServer(asynq.RedisClientOpt{Addr: mr.Addr()}, asynq.Config{
+		Concurrency:              1,
+		LogLevel:                 asynq.FatalLevel,
+		DelayedTaskCheckInterval: 10 * time.Millisecond,
+		RetryDelayFunc:           func(int, error, *asynq.Task) time.Duration { return 0 },
+	})
+	mux := asynq.NewServeMux()
+	mux.Use(ProgressMiddleware(publisher, zap.NewNop(), nil))
+	mux.HandleFunc("flaky", func(ctx context.Context, t *asynq.Task) error {
+		if GetRetryCount(ctx) == 0 {
+			return errors.New("backend busy")
+		}
+		return nil
+	})
+	if err := srv.Start(mux); err != nil {
+		t.Fatalf("start server: %v", err)
+	}
+	defer srv.Shutdown()
+
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: mr.Addr()})
+	defer client.Close()
+	info, err := client.Enqueue(asynq.NewTask("flaky", nil), asynq.MaxRetry(1))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	// 每次执行都发布 started，失败后会重试的执行不发布完成事件
+	for range 2 {
+		select {
+		case <-publisher.started:
+		case got := <-publisher.completed:
+			t.Fatalf("unexpected completion %q before the retry started", got)
+		case <-time.After(5 * time.Second):
+			t.Fatal("start progress not published")
+		}
+	}
+	select {
+	case got := <-publisher.completed:
+		if got != info.ID+":completed" {
+			t.Fatalf("completion = %q, want %q", got, info.ID+":completed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("completion not published")
+	}
+}
@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/hibiken/asynq"
+)
+
+// 内置的 panic 分类
+const (
+	PanicClassRuntimeError = "runtime_error"
+	PanicClassError        = "error"
+	PanicClassString       = "string"
+	PanicClassUnknown      = "unknown"
+)
+
+// PanicError 处理器 panic 被恢复后返回的错误
+// 包装 asynq.SkipRetry，panic 属于代码缺陷，重试没有意义
+type PanicError struct {
+	Value any
+	Class string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panic (%s): %v", e.Class, e.Value)
+}
+
+func (e *PanicError) Unwrap() error {
+	return asynq.SkipRetry
+}
+
+// IsPanic 判断错误是否来自被恢复的 panic
+func IsPanic(err error) bool {
+	var pe *PanicError
+	return errors.As(err, &pe)
+}
+
+// PanicRecorder 记录被恢复的 panic，由指标实现
+type PanicRecorder interface {
+	RecordPanic(taskType, class string)
+}
+
+// PanicRule 按 panic 信息子串归类的规则
+type PanicRule struct {
+	Class string
+	Match string
+}
+
+// PanicClassifier 将 panic 值归类为指标标签
+type PanicClassifier func(r any) string
+
+// NewPanicClassifier 创建分类器：按顺序匹配规则，均不匹配时按 panic 值的类型归类
+func NewPanicClassifier(rules []PanicRule) PanicClassifier {
+	return func(r any) string {
+		if len(rules) > 0 {
+			msg := fmt.Sprint(r)
+			for _, rule := range rules {
+				if strings.Contains(msg, rule.Match) {
+					return rule.Class
+				}
+			}
+		}
+		return classifyPanicValue(r)
+	}
+}
+
+func classifyPanicValue(r any) string {
+	switch r.(type) {
+	case runtime.Error:
+		return PanicClassRuntimeError
+	case error:
+		return PanicClassError
+	case string:
+		return PanicClassString
+	default:
+		return PanicClassUnknown
+	}
+}
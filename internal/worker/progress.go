@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+// StageStarted 任务开始执行时发布的进度阶段
+const StageStarted = "started"
+
+// ProgressPublisher 发布任务开始进度和完成事件
+type ProgressPublisher interface {
+	Publish(ctx context.Context, prog *progress.Progress) error
+	CompletionPublisher
+}
+
+// ProgressMiddleware 每次执行开始时发布 0% 的 started 进度，结束后按 CompletionMiddleware 的方式补发完成事件，
+// 不自行发布进度的处理器（如 demo）也能在 SSE 中看到开始和结束。没有任务 ID 时不发布；
+// 发布失败只记录警告，不影响任务执行结果
+func ProgressMiddleware(publisher ProgressPublisher, logger *zap.Logger, clk clock.Clock) asynq.MiddlewareFunc {
+	completion := CompletionMiddleware(publisher, logger, clk)
+	return func(h asynq.Handler) asynq.Handler {
+		next := completion(h)
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			taskID := GetTaskID(ctx)
+			if taskID == "" {
+				return h.ProcessTask(ctx, t)
+			}
+
+			if err := publisher.Publish(ctx, progress.NewProgress(taskID, 0, StageStarted, "task started")); err != nil {
+				logger.Warn("failed to publish task start",
+					zap.String("task_id", taskID),
+					zap.Error(err),
+				)
+			}
+			return next.ProcessTask(ctx, t)
+		})
+	}
+}
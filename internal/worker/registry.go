@@ -1,33 +1,95 @@
 package worker
 
 import (
+	"errors"
+	"fmt"
+
 	"go.uber.org/zap"
 
 	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
 	"github.com/Aixtrade/TaskFlow/pkg/tasktype"
 )
 
+// ErrDuplicateHandler 同一任务类型已注册了 handler
+var ErrDuplicateHandler = errors.New("duplicate handler")
+
+// DuplicatePolicy 同一任务类型重复注册时的处理方式
+type DuplicatePolicy int
+
+const (
+	// DuplicateReject 保留已注册的 handler，Register 返回 ErrDuplicateHandler（默认）
+	DuplicateReject DuplicatePolicy = iota
+	// DuplicateReplace 记录警告后用新的 handler 替换
+	DuplicateReplace
+)
+
 type Registry struct {
-	handlers map[string]Handler
-	logger   *zap.Logger
+	handlers   map[string]Handler
+	logger     *zap.Logger
+	duplicates DuplicatePolicy
+}
+
+// RegistryOption Registry 的可选配置
+type RegistryOption func(*Registry)
+
+// WithDuplicatePolicy 设置重复注册的处理方式
+func WithDuplicatePolicy(policy DuplicatePolicy) RegistryOption {
+	return func(r *Registry) {
+		r.duplicates = policy
+	}
 }
 
-func NewRegistry(logger *zap.Logger) *Registry {
-	return &Registry{
+func NewRegistry(logger *zap.Logger, opts ...RegistryOption) *Registry {
+	r := &Registry{
 		handlers: make(map[string]Handler),
 		logger:   logger,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
-func (r *Registry) Register(handler Handler) {
-	r.handlers[handler.Type()] = handler
-	r.logger.Info("registered handler", zap.String("type", handler.Type()))
+// Register 注册 handler。任务类型已注册时按 DuplicatePolicy 处理，
+// 避免两个 handler 声明同一类型时任务被悄悄交给其中一个
+func (r *Registry) Register(handler Handler) error {
+	taskType := handler.Type()
+	if existing, ok := r.handlers[taskType]; ok {
+		if r.duplicates != DuplicateReplace {
+			r.logger.Error("duplicate handler registration rejected",
+				zap.String("type", taskType),
+				zap.String("existing", fmt.Sprintf("%T", existing)),
+				zap.String("rejected", fmt.Sprintf("%T", handler)),
+			)
+			return fmt.Errorf("%w for task type %s: %T already registered", ErrDuplicateHandler, taskType, existing)
+		}
+		r.logger.Warn("DUPLICATE HANDLER REGISTRATION: replacing existing handler",
+			zap.String("type", taskType),
+			zap.String("existing", fmt.Sprintf("%T", existing)),
+			zap.String("replacement", fmt.Sprintf("%T", handler)),
+		)
+	}
+	r.handlers[taskType] = handler
+	r.logger.Info("registered handler", zap.String("type", taskType))
+	return nil
+}
+
+// MustRegister 与 Register 相同，但注册失败时 panic，用于启动时注册
+func (r *Registry) MustRegister(handler Handler) {
+	if err := r.Register(handler); err != nil {
+		panic(err)
+	}
 }
 
-func (r *Registry) RegisterAll(handlers ...Handler) {
+// RegisterAll 依次注册，返回遇到的第一个错误，之后的 handler 仍会注册
+func (r *Registry) RegisterAll(handlers ...Handler) error {
+	var first error
 	for _, h := range handlers {
-		r.Register(h)
+		if err := r.Register(h); err != nil && first == nil {
+			first = err
+		}
 	}
+	return first
 }
 
 func (r *Registry) Get(taskType string) (Handler, bool) {
@@ -2,6 +2,7 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/hibiken/asynq"
@@ -41,3 +42,36 @@ func TestRegistryTypes(t *testing.T) {
 		t.Fatalf("expected 2 types, got %d", len(types))
 	}
 }
+
+type otherHandler struct {
+	dummyHandler
+}
+
+func TestRegistryDuplicateRegistration(t *testing.T) {
+	registry := NewRegistry(zap.NewNop())
+	if err := registry.Register(dummyHandler{name: "a"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := registry.Register(otherHandler{dummyHandler{name: "a"}}); !errors.Is(err, ErrDuplicateHandler) {
+		t.Fatalf("expected ErrDuplicateHandler, got %v", err)
+	}
+	if h, _ := registry.Get("a"); h != (dummyHandler{name: "a"}) {
+		t.Fatalf("expected the first handler to be kept, got %T", h)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected MustRegister to panic on a duplicate")
+			}
+		}()
+		registry.MustRegister(dummyHandler{name: "a"})
+	}()
+
+	replacing := NewRegistry(zap.NewNop(), WithDuplicatePolicy(DuplicateReplace))
+	replacing.MustRegister(dummyHandler{name: "a"})
+	replacing.MustRegister(otherHandler{dummyHandler{name: "a"}})
+	if h, _ := replacing.Get("a"); h != (otherHandler{dummyHandler{name: "a"}}) {
+		t.Fatalf("expected the handler to be replaced, got %T", h)
+	}
+}
@@ -0,0 +1,150 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/blobstore"
+	"github.com/Aixtrade/TaskFlow/pkg/payload"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+// ResultSink 保存任务最终结果：超过阈值的结果写入对象存储，其余内联保存
+type ResultSink struct {
+	store     blobstore.Store
+	threshold int64
+	artifacts payload.ArtifactPolicy
+}
+
+// ResultSinkOption ResultSink 可选项
+type ResultSinkOption func(*ResultSink)
+
+// WithArtifactPolicy 替换默认的文件列表校验规则
+func WithArtifactPolicy(policy payload.ArtifactPolicy) ResultSinkOption {
+	return func(s *ResultSink) {
+		s.artifacts = policy
+	}
+}
+
+func NewResultSink(store blobstore.Store, threshold int64, opts ...ResultSinkOption) *ResultSink {
+	s := &ResultSink{
+		store:     store,
+		threshold: threshold,
+		artifacts: payload.DefaultArtifactPolicy(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Save 保存 JSON 结果，返回应附加到完成事件的 metadata
+func (s *ResultSink) Save(ctx context.Context, task *asynq.Task, taskID string, data []byte) (map[string]string, error) {
+	return s.SaveWithArtifacts(ctx, task, taskID, data, nil)
+}
+
+// SaveWithArtifacts 同 Save，并保存任务产出的文件列表。有文件时保存的结果为
+// payload.ArtifactResult，完成事件的 metadata 中另附文件列表；
+// 文件列表未通过校验时返回包装 payload.ErrInvalidArtifacts 的错误，不保存任何内容
+func (s *ResultSink) SaveWithArtifacts(ctx context.Context, task *asynq.Task, taskID string, data []byte, artifacts []payload.Artifact) (map[string]string, error) {
+	var artifactsJSON []byte
+	if len(artifacts) > 0 {
+		if err := s.artifacts.Validate(taskID, artifacts); err != nil {
+			return nil, err
+		}
+		var err error
+		if artifactsJSON, err = json.Marshal(artifacts); err != nil {
+			return nil, err
+		}
+		if data, err = json.Marshal(payload.ArtifactResult{Result: data, Artifacts: artifacts}); err != nil {
+			return nil, err
+		}
+	}
+
+	meta, err := s.save(ctx, task, taskID, data)
+	if err != nil || artifactsJSON == nil {
+		return meta, err
+	}
+	meta[progress.ArtifactsMetadataKey] = string(artifactsJSON)
+	return meta, nil
+}
+
+func (s *ResultSink) save(ctx context.Context, task *asynq.Task, taskID string, data []byte) (map[string]string, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	DebugSessionFromContext(ctx).RecordResult(data)
+
+	worker := workerMetadata(ctx)
+	if int64(len(data)) <= s.threshold {
+		if err := writeResult(task, data); err != nil {
+			return nil, err
+		}
+		return withWorker(map[string]string{progress.ResultMetadataKey: string(data)}, worker), nil
+	}
+
+	obj, err := s.store.Put(ctx, blobstore.ResultKey(taskID), bytes.NewReader(data), "application/json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to store result: %w", err)
+	}
+
+	ref, err := json.Marshal(payload.BlobRef{
+		Key:         obj.Key,
+		ContentType: obj.ContentType,
+		Size:        obj.Size,
+		URI:         obj.URI,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{progress.ResultRefMetadataKey: ref}
+	if worker != nil {
+		fields[progress.WorkerMetadataKey] = worker
+	}
+	envelope, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeResult(task, envelope); err != nil {
+		return nil, err
+	}
+
+	return withWorker(map[string]string{progress.ResultRefMetadataKey: string(ref)}, worker), nil
+}
+
+// workerMetadata 返回 context 中记录的 worker 实例的 JSON，未记录时返回 nil
+func workerMetadata(ctx context.Context) json.RawMessage {
+	w, ok := progress.WorkerFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(w)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// withWorker 在结果 metadata 中记录保存结果的 worker 实例，便于排查不同环境结果不一致的问题
+func withWorker(meta map[string]string, worker json.RawMessage) map[string]string {
+	if worker != nil {
+		meta[progress.WorkerMetadataKey] = string(worker)
+	}
+	return meta
+}
+
+func writeResult(task *asynq.Task, data []byte) error {
+	w := task.ResultWriter()
+	if w == nil {
+		return nil
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	return nil
+}
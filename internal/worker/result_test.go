@@ -0,0 +1,158 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/blobstore"
+	"github.com/Aixtrade/TaskFlow/pkg/payload"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+func TestResultSinkKeepsSmallResultsInline(t *testing.T) {
+	store, err := blobstore.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sink := NewResultSink(store, 64)
+
+	meta, err := sink.Save(context.Background(), asynq.NewTask("grpc_task", nil), "task-1", []byte(`{"ok":true}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if meta[progress.ResultMetadataKey] != `{"ok":true}` {
+		t.Fatalf("expected inline result, got %v", meta)
+	}
+	if _, _, err := store.Open(context.Background(), blobstore.ResultKey("task-1")); err != blobstore.ErrNotFound {
+		t.Fatalf("expected no blob, got %v", err)
+	}
+}
+
+func TestResultSinkWritesLargeResultsToBlobStore(t *testing.T) {
+	store, err := blobstore.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sink := NewResultSink(store, 8)
+	data := `{"rows":"` + strings.Repeat("x", 32) + `"}`
+
+	meta, err := sink.Save(context.Background(), asynq.NewTask("grpc_task", nil), "task-1", []byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := meta[progress.ResultMetadataKey]; ok {
+		t.Fatal("expected large result not to be inlined")
+	}
+
+	var ref payload.BlobRef
+	if err := json.Unmarshal([]byte(meta[progress.ResultRefMetadataKey]), &ref); err != nil {
+		t.Fatalf("invalid result_ref: %v", err)
+	}
+	if ref.Key != blobstore.ResultKey("task-1") || ref.Size != int64(len(data)) {
+		t.Fatalf("unexpected ref %+v", ref)
+	}
+
+	body, _, err := store.Open(context.Background(), ref.Key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer body.Close()
+	stored, _ := io.ReadAll(body)
+	if string(stored) != data {
+		t.Fatalf("unexpected stored result %s", stored)
+	}
+}
+
+func TestResultSinkRecordsWorker(t *testing.T) {
+	store, err := blobstore.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sink := NewResultSink(store, 16)
+	ctx := progress.WithWorker(context.Background(), progress.Worker{ID: "w1", Version: "v1"})
+
+	for _, data := range []string{`{"ok":true}`, `{"rows":"` + strings.Repeat("x", 32) + `"}`} {
+		meta, err := sink.Save(ctx, asynq.NewTask("grpc_task", nil), "task-1", []byte(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if meta[progress.WorkerMetadataKey] != `{"id":"w1","version":"v1"}` {
+			t.Fatalf("expected worker in result metadata, got %v", meta)
+		}
+	}
+
+	meta, err := sink.Save(context.Background(), asynq.NewTask("grpc_task", nil), "task-2", []byte(`{}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := meta[progress.WorkerMetadataKey]; ok {
+		t.Fatalf("expected no worker without one in context, got %v", meta)
+	}
+}
+
+func TestResultSinkSavesArtifacts(t *testing.T) {
+	store, err := blobstore.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sink := NewResultSink(store, 1024)
+	artifacts := []payload.Artifact{
+		{Name: "report.csv", ContentType: "text/csv", Size: 12, URI: "s3://bucket/report.csv"},
+		{Name: "chart.png", Key: "artifacts/task-1/chart.png"},
+	}
+
+	meta, err := sink.SaveWithArtifacts(context.Background(), asynq.NewTask("grpc_task", nil), "task-1", []byte(`{"ok":true}`), artifacts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var saved payload.ArtifactResult
+	if err := json.Unmarshal([]byte(meta[progress.ResultMetadataKey]), &saved); err != nil {
+		t.Fatalf("invalid result: %v", err)
+	}
+	if string(saved.Result) != `{"ok":true}` || len(saved.Artifacts) != 2 {
+		t.Fatalf("unexpected result %+v", saved)
+	}
+	var listed []payload.Artifact
+	if err := json.Unmarshal([]byte(meta[progress.ArtifactsMetadataKey]), &listed); err != nil {
+		t.Fatalf("invalid artifacts metadata: %v", err)
+	}
+	if len(listed) != 2 || listed[1].Key != "artifacts/task-1/chart.png" {
+		t.Fatalf("unexpected artifacts metadata %+v", listed)
+	}
+}
+
+func TestResultSinkRejectsInvalidArtifacts(t *testing.T) {
+	store, err := blobstore.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sink := NewResultSink(store, 1024, WithArtifactPolicy(payload.ArtifactPolicy{MaxCount: 2, AllowedSchemes: []string{"https"}}))
+
+	tests := map[string][]payload.Artifact{
+		"too many":         {{Name: "a", Key: "artifacts/task-1/a"}, {Name: "b", Key: "artifacts/task-1/b"}, {Name: "c", Key: "artifacts/task-1/c"}},
+		"scheme":           {{Name: "a", URI: "file:///etc/passwd"}},
+		"duplicate name":   {{Name: "a", Key: "artifacts/task-1/a"}, {Name: "a", Key: "artifacts/task-1/b"}},
+		"path in name":     {{Name: "../a", Key: "artifacts/task-1/a"}},
+		"uri and key":      {{Name: "a", URI: "https://example.com/a", Key: "artifacts/task-1/a"}},
+		"neither uri, key": {{Name: "a"}},
+		"other task key":   {{Name: "a", Key: "artifacts/task-2/a"}},
+		"key outside":      {{Name: "a", Key: "results/task-2.json"}},
+		"key escapes":      {{Name: "a", Key: "artifacts/task-1/../task-2/a"}},
+		"prefix only":      {{Name: "a", Key: "artifacts/task-1/"}},
+	}
+	for name, artifacts := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := sink.SaveWithArtifacts(context.Background(), asynq.NewTask("grpc_task", nil), "task-1", nil, artifacts)
+			if !errors.Is(err, payload.ErrInvalidArtifacts) {
+				t.Fatalf("expected ErrInvalidArtifacts, got %v", err)
+			}
+		})
+	}
+}
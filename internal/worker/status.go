@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/hibiken/asynq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 任务指标 status 标签的取值，即一次执行的结束状态
+const (
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+	StatusTimedOut  = "timed_out"
+	// StatusRetry 执行失败但 asynq 还会重试，任务尚未结束
+	StatusRetry = "retry"
+)
+
+type statusKey struct{}
+
+// statusHolder 处理器报告的结束状态
+type statusHolder struct {
+	mu     sync.Mutex
+	status string
+}
+
+// ReportStatus 处理器报告本次执行的结束状态，用于无法从返回的 error 判断的情况
+// （如后端返回了取消状态）。ctx 未经过 MetricsMiddleware 时忽略
+func ReportStatus(ctx context.Context, status string) {
+	holder, ok := ctx.Value(statusKey{}).(*statusHolder)
+	if !ok {
+		return
+	}
+	holder.mu.Lock()
+	defer holder.mu.Unlock()
+	holder.status = status
+}
+
+func withStatusHolder(ctx context.Context) (context.Context, *statusHolder) {
+	holder := &statusHolder{}
+	return context.WithValue(ctx, statusKey{}, holder), holder
+}
+
+func (h *statusHolder) get() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
+
+// TerminalStatus 根据处理器的返回值和任务 context 推断结束状态：
+// 超过截止时间为 timed_out，被取消为 cancelled，其余错误为 failed
+func TerminalStatus(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return StatusCompleted
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded:
+		return StatusTimedOut
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled) || status.Code(err) == codes.Canceled:
+		return StatusCancelled
+	default:
+		return StatusFailed
+	}
+}
+
+// WillRetry 判断本次执行返回 err 后 asynq 是否会再次执行任务：错误未包装 SkipRetry 或 RevokeTask，
+// 且重试次数未用尽。context 中没有重试信息时视为不重试
+func WillRetry(ctx context.Context, err error) bool {
+	if err == nil || errors.Is(err, asynq.SkipRetry) || errors.Is(err, asynq.RevokeTask) {
+		return false
+	}
+	return GetRetryCount(ctx) < GetMaxRetry(ctx)
+}
+
+// AttemptStatus 一次执行的状态：asynq 还会重试的失败执行为 retry，其余同 TerminalStatus
+func AttemptStatus(ctx context.Context, err error) string {
+	if WillRetry(ctx, err) {
+		return StatusRetry
+	}
+	return TerminalStatus(ctx, err)
+}
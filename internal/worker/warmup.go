@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+// ServiceWarmer 预检下游服务，返回未就绪的服务
+type ServiceWarmer interface {
+	WarmUp(ctx context.Context, interval time.Duration) []string
+}
+
+// WarmUpResult 预热结果
+type WarmUpResult struct {
+	// RedisErr Redis 在超时前仍不可用时的最后一次错误
+	RedisErr error
+	// FailedServices 超时前仍未健康的服务
+	FailedServices []string
+}
+
+// OK 所有检查均已通过
+func (r WarmUpResult) OK() bool {
+	return r.RedisErr == nil && len(r.FailedServices) == 0
+}
+
+// WarmUp 在开始消费任务前等待 Redis 可用和下游服务健康，最多等待 timeout。
+// services 为空时只检查 Redis
+func WarmUp(ctx context.Context, timeout, interval time.Duration, ping func(context.Context) error, services ServiceWarmer, clk clock.Clock) WarmUpResult {
+	clk = clock.OrReal(clk)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var (
+		result WarmUpResult
+		wg     sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			err := ping(ctx)
+			if err == nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				result.RedisErr = fmt.Errorf("redis not available: %w", err)
+				return
+			case <-clk.After(interval):
+			}
+		}
+	}()
+
+	if services != nil {
+		result.FailedServices = services.WarmUp(ctx, interval)
+	}
+	wg.Wait()
+
+	return result
+}
@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeWarmer struct {
+	failed []string
+}
+
+func (f *fakeWarmer) WarmUp(ctx context.Context, interval time.Duration) []string {
+	return f.failed
+}
+
+func TestWarmUpRetriesRedisUntilAvailable(t *testing.T) {
+	var pings int
+	ping := func(ctx context.Context) error {
+		pings++
+		if pings < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	result := WarmUp(context.Background(), time.Second, time.Millisecond, ping, &fakeWarmer{failed: []string{"llm"}}, nil)
+	if result.RedisErr != nil || pings != 3 {
+		t.Fatalf("expected redis to become available after 3 pings, got %v after %d", result.RedisErr, pings)
+	}
+	if result.OK() || len(result.FailedServices) != 1 {
+		t.Fatalf("expected failed services to be reported, got %+v", result)
+	}
+}
+
+func TestWarmUpGivesUpAfterTimeout(t *testing.T) {
+	ping := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	result := WarmUp(context.Background(), 20*time.Millisecond, time.Millisecond, ping, nil, nil)
+	if result.RedisErr == nil {
+		t.Fatalf("expected redis warm-up to time out")
+	}
+}
@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/discovery"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+// weightsRestartLockTTL 重启许可的有效期，应大于一次重启（含等待任务完成）的耗时
+const weightsRestartLockTTL = 2 * time.Minute
+
+// WeightSource 读取队列权重覆盖并协调重启，由 discovery.WeightStore 实现
+type WeightSource interface {
+	Get(ctx context.Context) (discovery.QueueWeights, error)
+	AcquireRestart(ctx context.Context, owner string, ttl time.Duration) (bool, error)
+	ReleaseRestart(ctx context.Context, owner string) error
+}
+
+// QueueReconfigurer 以新的队列权重重启消费
+type QueueReconfigurer interface {
+	Reconfigure(queues map[string]int) error
+}
+
+// WeightsApplied 新权重生效后调用，通常用于在心跳中报告已应用的版本
+type WeightsApplied func(ctx context.Context, queues map[string]int, version int64)
+
+// WeightWatcher 轮询 Redis 中的队列权重覆盖，变化时重启本 worker 的消费。
+// 重启前先取得全局许可，同一时间只有一个 worker 在重启，取不到时等下一轮再试
+type WeightWatcher struct {
+	source  WeightSource
+	server  QueueReconfigurer
+	base    map[string]int
+	owner   string
+	applied WeightsApplied
+	logger  *zap.Logger
+	clock   clock.Clock
+
+	current map[string]int
+	version int64
+}
+
+// NewWeightWatcher 创建权重监听器。base 为配置中的队列权重，current 和 version 为启动时已应用的权重及其覆盖版本
+func NewWeightWatcher(source WeightSource, server QueueReconfigurer, base, current map[string]int, version int64, owner string, applied WeightsApplied, logger *zap.Logger, clk clock.Clock) *WeightWatcher {
+	return &WeightWatcher{
+		source:  source,
+		server:  server,
+		base:    base,
+		owner:   owner,
+		applied: applied,
+		logger:  logger,
+		clock:   clock.OrReal(clk),
+		current: current,
+		version: version,
+	}
+}
+
+// ApplyWeights 用覆盖中的权重替换 base 中同名队列的权重。只调整本 worker 已消费的队列，
+// 不会因此开始消费新队列；权重不大于 0 的覆盖项忽略
+func ApplyWeights(base, override map[string]int) map[string]int {
+	queues := maps.Clone(base)
+	for queue, weight := range override {
+		if _, ok := queues[queue]; ok && weight > 0 {
+			queues[queue] = weight
+		}
+	}
+	return queues
+}
+
+// Run 每隔 interval 检查一次，直到 ctx 结束
+func (w *WeightWatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := w.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			w.Check(ctx)
+		}
+	}
+}
+
+// Check 读取权重覆盖，版本变化时应用。返回本次是否重启了消费
+func (w *WeightWatcher) Check(ctx context.Context) bool {
+	override, err := w.source.Get(ctx)
+	if err != nil {
+		w.logger.Warn("failed to read queue weight override", zap.Error(err))
+		return false
+	}
+	if override.Version == w.version {
+		return false
+	}
+
+	queues := ApplyWeights(w.base, override.Weights)
+	if maps.Equal(queues, w.current) {
+		// 覆盖不影响本 worker 消费的队列，无需重启
+		w.markApplied(ctx, queues, override.Version)
+		return false
+	}
+
+	ok, err := w.source.AcquireRestart(ctx, w.owner, weightsRestartLockTTL)
+	if err != nil {
+		w.logger.Warn("failed to acquire queue weight restart lock", zap.Error(err))
+		return false
+	}
+	if !ok {
+		w.logger.Debug("another worker is restarting, will retry",
+			zap.Int64("weights_version", override.Version),
+		)
+		return false
+	}
+	defer func() {
+		if err := w.source.ReleaseRestart(context.WithoutCancel(ctx), w.owner); err != nil {
+			w.logger.Warn("failed to release queue weight restart lock", zap.Error(err))
+		}
+	}()
+
+	if err := w.server.Reconfigure(queues); err != nil {
+		w.logger.Error("failed to restart with new queue weights",
+			zap.Int64("weights_version", override.Version),
+			zap.Error(err),
+		)
+		return false
+	}
+	w.logger.Info("applied queue weight override",
+		zap.Int64("weights_version", override.Version),
+		zap.Any("queues", queues),
+	)
+	w.markApplied(ctx, queues, override.Version)
+	return true
+}
+
+func (w *WeightWatcher) markApplied(ctx context.Context, queues map[string]int, version int64) {
+	w.current = queues
+	w.version = version
+	if w.applied != nil {
+		w.applied(ctx, queues, version)
+	}
+}
@@ -0,0 +1,163 @@
+package worker
+
+import (
+	"context"
+	"maps"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/discovery"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+type fakeWeightSource struct {
+	weights  discovery.QueueWeights
+	locked   bool
+	released int
+}
+
+func (f *fakeWeightSource) Get(context.Context) (discovery.QueueWeights, error) {
+	return f.weights, nil
+}
+
+func (f *fakeWeightSource) AcquireRestart(context.Context, string, time.Duration) (bool, error) {
+	if f.locked {
+		return false, nil
+	}
+	f.locked = true
+	return true, nil
+}
+
+func (f *fakeWeightSource) ReleaseRestart(context.Context, string) error {
+	f.locked = false
+	f.released++
+	return nil
+}
+
+type fakeReconfigurer struct {
+	calls []map[string]int
+}
+
+func (f *fakeReconfigurer) Reconfigure(queues map[string]int) error {
+	f.calls = append(f.calls, queues)
+	return nil
+}
+
+func TestApplyWeightsOnlyAdjustsConsumedQueues(t *testing.T) {
+	base := map[string]int{"critical": 6, "default": 3}
+	got := ApplyWeights(base, map[string]int{"critical": 10, "low": 5, "default": 0})
+
+	want := map[string]int{"critical": 10, "default": 3}
+	if !maps.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if base["critical"] != 6 {
+		t.Fatal("expected base queues to be left unchanged")
+	}
+}
+
+func TestWeightWatcherRestartsOneWorkerAtATime(t *testing.T) {
+	base := map[string]int{"critical": 6, "default": 3}
+	source := &fakeWeightSource{
+		weights: discovery.QueueWeights{Version: 2, Weights: map[string]int{"critical": 10}},
+		locked:  true,
+	}
+	server := &fakeReconfigurer{}
+
+	var appliedVersion int64
+	watcher := NewWeightWatcher(source, server, base, base, 1, "w1", func(_ context.Context, _ map[string]int, version int64) {
+		appliedVersion = version
+	}, zap.NewNop(), nil)
+
+	// 其他 worker 正在重启，本轮不重启
+	if watcher.Check(context.Background()) {
+		t.Fatal("expected no restart while another worker holds the lock")
+	}
+	if len(server.calls) != 0 || appliedVersion != 0 {
+		t.Fatalf("unexpected reconfigure %v (applied %d)", server.calls, appliedVersion)
+	}
+
+	source.locked = false
+	if !watcher.Check(context.Background()) {
+		t.Fatal("expected a restart once the lock is free")
+	}
+	if len(server.calls) != 1 || server.calls[0]["critical"] != 10 || server.calls[0]["default"] != 3 {
+		t.Fatalf("unexpected reconfigure calls %v", server.calls)
+	}
+	if appliedVersion != 2 {
+		t.Fatalf("expected version 2 to be reported, got %d", appliedVersion)
+	}
+	if source.locked || source.released != 1 {
+		t.Fatal("expected the restart lock to be released")
+	}
+
+	// 版本未变，不再重启
+	if watcher.Check(context.Background()) {
+		t.Fatal("expected no restart for an applied version")
+	}
+}
+
+func TestWeightWatcherSkipsRestartForUnaffectedQueues(t *testing.T) {
+	base := map[string]int{"default": 3}
+	source := &fakeWeightSource{
+		weights: discovery.QueueWeights{Version: 5, Weights: map[string]int{"critical": 10}},
+	}
+	server := &fakeReconfigurer{}
+
+	var appliedVersion int64
+	watcher := NewWeightWatcher(source, server, base, base, 4, "w1", func(_ context.Context, _ map[string]int, version int64) {
+		appliedVersion = version
+	}, zap.NewNop(), nil)
+
+	if watcher.Check(context.Background()) {
+		t.Fatal("expected no restart when the override does not touch consumed queues")
+	}
+	if len(server.calls) != 0 || source.released != 0 {
+		t.Fatalf("expected no reconfigure and no lock, got %v", server.calls)
+	}
+	if appliedVersion != 5 {
+		t.Fatalf("expected version 5 to be reported, got %d", appliedVersion)
+	}
+}
+
+func TestWeightWatcherRunChecksOnEachTick(t *testing.T) {
+	base := map[string]int{"critical": 6, "default": 3}
+	source := &fakeWeightSource{
+		weights: discovery.QueueWeights{Version: 2, Weights: map[string]int{"critical": 10}},
+	}
+	server := &fakeReconfigurer{}
+	fake := clock.NewFake(time.Unix(0, 0))
+
+	applied := make(chan int64, 1)
+	watcher := NewWeightWatcher(source, server, base, base, 1, "w1", func(_ context.Context, _ map[string]int, version int64) {
+		applied <- version
+	}, zap.NewNop(), fake)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watcher.Run(ctx, time.Minute)
+	}()
+
+	// 到达间隔前不检查
+	fake.BlockUntil(1)
+	fake.Advance(30 * time.Second)
+	select {
+	case v := <-applied:
+		t.Fatalf("expected no check before the interval, got version %d", v)
+	default:
+	}
+
+	fake.Advance(30 * time.Second)
+	if v := <-applied; v != 2 {
+		t.Fatalf("expected version 2 to be applied, got %d", v)
+	}
+	cancel()
+	<-done
+	if len(server.calls) != 1 {
+		t.Fatalf("expected one restart, got %v", server.calls)
+	}
+}
@@ -0,0 +1,47 @@
+package clock
+
+import "time"
+
+// Clock 时间源抽象，便于在测试中替换为可控时钟
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker 对 time.Ticker 的抽象
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real 返回基于系统时间的时钟
+func Real() Clock {
+	return realClock{}
+}
+
+// OrReal c 为空时返回系统时钟
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real()
+	}
+	return c
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{ticker: time.NewTicker(d)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t *realTicker) C() <-chan time.Time { return t.ticker.C }
+func (t *realTicker) Stop()               { t.ticker.Stop() }
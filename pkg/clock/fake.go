@@ -0,0 +1,146 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake 手动推进的时钟，只有调用 Advance 时定时器和 ticker 才会触发
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+	changed chan struct{}
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	period   time.Duration // 大于 0 表示 ticker
+	ch       chan time.Time
+	stopped  bool
+}
+
+// NewFake 创建从 start 开始的可控时钟
+func NewFake(start time.Time) *Fake {
+	return &Fake{
+		now:     start,
+		changed: make(chan struct{}),
+	}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &fakeWaiter{deadline: f.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.ch <- f.now
+		return w.ch
+	}
+	f.addWaiter(w)
+	return w.ch
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &fakeWaiter{deadline: f.now.Add(d), period: d, ch: make(chan time.Time, 1)}
+	f.addWaiter(w)
+	return &fakeTicker{clock: f, waiter: w}
+}
+
+// Advance 推进时间并触发所有到期的定时器
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+
+	active := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.stopped {
+			continue
+		}
+		if w.deadline.After(f.now) {
+			active = append(active, w)
+			continue
+		}
+
+		// 与 time.Ticker 一致，消费方来不及接收时丢弃多余的 tick
+		select {
+		case w.ch <- f.now:
+		default:
+		}
+		if w.period > 0 {
+			for !w.deadline.After(f.now) {
+				w.deadline = w.deadline.Add(w.period)
+			}
+			active = append(active, w)
+		}
+	}
+	f.waiters = active
+}
+
+// Waiters 返回尚未触发的定时器和 ticker 数量
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil 阻塞直到至少有 n 个定时器或 ticker 在等待
+// 用于确认被测 goroutine 已进入等待状态后再调用 Advance
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mu.Lock()
+		if len(f.waiters) >= n {
+			f.mu.Unlock()
+			return
+		}
+		changed := f.changed
+		f.mu.Unlock()
+		<-changed
+	}
+}
+
+// addWaiter 调用方需持有锁
+func (f *Fake) addWaiter(w *fakeWaiter) {
+	f.waiters = append(f.waiters, w)
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+type fakeTicker struct {
+	clock  *Fake
+	waiter *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.waiter.ch }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.waiter.stopped = true
+	for i, w := range t.clock.waiters {
+		if w == t.waiter {
+			t.clock.waiters = append(t.clock.waiters[:i], t.clock.waiters[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeAfterFiresOnAdvance(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+
+	ch := c.After(time.Second)
+	c.Advance(999 * time.Millisecond)
+	select {
+	case <-ch:
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	c.Advance(time.Millisecond)
+	select {
+	case got := <-ch:
+		if !got.Equal(start.Add(time.Second)) {
+			t.Fatalf("unexpected fire time %v", got)
+		}
+	default:
+		t.Fatal("timer did not fire")
+	}
+	if c.Waiters() != 0 {
+		t.Fatalf("expected no waiters, got %d", c.Waiters())
+	}
+}
+
+func TestFakeTickerRearmsAndStops(t *testing.T) {
+	c := NewFake(time.Unix(0, 0))
+	ticker := c.NewTicker(10 * time.Second)
+
+	for i := 0; i < 3; i++ {
+		c.Advance(10 * time.Second)
+		select {
+		case <-ticker.C():
+		default:
+			t.Fatalf("tick %d missing", i)
+		}
+	}
+
+	ticker.Stop()
+	c.Advance(10 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	c := NewFake(time.Unix(0, 0))
+	done := make(chan struct{})
+
+	go func() {
+		<-c.After(time.Minute)
+		close(done)
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	<-done
+}
@@ -3,22 +3,38 @@ package errors
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 var (
-	ErrTaskNotFound      = errors.New("task not found")
-	ErrTaskAlreadyExists = errors.New("task already exists")
-	ErrTaskCancelled     = errors.New("task cancelled")
-	ErrTaskFailed        = errors.New("task failed")
-	ErrInvalidPayload    = errors.New("invalid payload")
-	ErrInvalidTaskType   = errors.New("invalid task type")
-	ErrInvalidTaskID     = errors.New("invalid task id")
-	ErrInvalidTaskState  = errors.New("invalid task state")
-	ErrInvalidQueue      = errors.New("invalid queue")
-	ErrQueueFull         = errors.New("queue is full")
-	ErrTimeout           = errors.New("operation timeout")
-	ErrUnauthorized      = errors.New("unauthorized")
-	ErrRateLimited       = errors.New("rate limited")
+	ErrTaskNotFound        = errors.New("task not found")
+	ErrTaskAlreadyExists   = errors.New("task already exists")
+	ErrTaskCancelled       = errors.New("task cancelled")
+	ErrTaskFailed          = errors.New("task failed")
+	ErrInvalidPayload      = errors.New("invalid payload")
+	ErrInvalidTaskType     = errors.New("invalid task type")
+	ErrInvalidTaskID       = errors.New("invalid task id")
+	ErrInvalidTaskState    = errors.New("invalid task state")
+	ErrInvalidQueue        = errors.New("invalid queue")
+	ErrQueueFull           = errors.New("queue is full")
+	ErrTimeout             = errors.New("operation timeout")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrRateLimited         = errors.New("rate limited")
+	ErrNoCapableWorker     = errors.New("no live worker handles task type")
+	ErrBlobStoreDisabled   = errors.New("blob store is not configured")
+	ErrResultNotFound      = errors.New("task result not found")
+	ErrUnroutableLabels    = errors.New("no queue configured for required labels")
+	ErrTaskNotCancelable   = errors.New("task already finished")
+	ErrTaskActive          = errors.New("task is running")
+	ErrTaskNotRunnable     = errors.New("task is not scheduled or waiting for retry")
+	ErrTaskNotActive       = errors.New("task is not running")
+	ErrTaskCompleted       = errors.New("task already completed")
+	ErrGroupNotFound       = errors.New("group not found")
+	ErrQueueNotFound       = errors.New("queue not found")
+	ErrLimitExceeded       = errors.New("limit exceeded")
+	ErrDeprecated          = errors.New("deprecated and no longer accepted")
+	ErrArtifactNotFound    = errors.New("artifact not found")
+	ErrArtifactUnavailable = errors.New("artifact cannot be downloaded through taskflow")
 )
 
 type TaskError struct {
@@ -64,6 +80,140 @@ func NewValidationError(field, message string) *ValidationError {
 	}
 }
 
+// PayloadFieldError payload 的某个字段未通过任务类型的内置校验
+type PayloadFieldError struct {
+	TaskType string
+	Field    string
+	Reason   string
+}
+
+func (e *PayloadFieldError) Error() string {
+	return fmt.Sprintf("invalid %s payload: %s: %s", e.TaskType, e.Field, e.Reason)
+}
+
+func (e *PayloadFieldError) Unwrap() error {
+	return ErrInvalidPayload
+}
+
+func NewPayloadFieldError(taskType, field, reason string) *PayloadFieldError {
+	return &PayloadFieldError{
+		TaskType: taskType,
+		Field:    field,
+		Reason:   reason,
+	}
+}
+
+// PayloadSchemaError payload 未通过任务类型 schema 校验
+type PayloadSchemaError struct {
+	TaskType      string
+	SchemaVersion int64
+	Reason        string
+}
+
+func (e *PayloadSchemaError) Error() string {
+	return fmt.Sprintf("payload rejected by %s schema v%d: %s", e.TaskType, e.SchemaVersion, e.Reason)
+}
+
+func (e *PayloadSchemaError) Unwrap() error {
+	return ErrInvalidPayload
+}
+
+func NewPayloadSchemaError(taskType string, version int64, reason string) *PayloadSchemaError {
+	return &PayloadSchemaError{
+		TaskType:      taskType,
+		SchemaVersion: version,
+		Reason:        reason,
+	}
+}
+
+// LimitError 创建任务的参数超出配置的上限或低于下限
+type LimitError struct {
+	// Field 请求中的字段
+	Field string
+	// Limit 对应的配置项
+	Limit     string
+	Cap       string
+	Requested string
+	// Below 为 true 时 Cap 是下限
+	Below bool
+}
+
+func (e *LimitError) Error() string {
+	if e.Below {
+		return fmt.Sprintf("%s %s is below %s (%s)", e.Field, e.Requested, e.Limit, e.Cap)
+	}
+	return fmt.Sprintf("%s %s exceeds %s (%s)", e.Field, e.Requested, e.Limit, e.Cap)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+func NewLimitError(field, limit, capValue, requested string) *LimitError {
+	return &LimitError{
+		Field:     field,
+		Limit:     limit,
+		Cap:       capValue,
+		Requested: requested,
+	}
+}
+
+// NewMinimumError 创建参数低于下限的 LimitError
+func NewMinimumError(field, limit, minValue, requested string) *LimitError {
+	return &LimitError{
+		Field:     field,
+		Limit:     limit,
+		Cap:       minValue,
+		Requested: requested,
+		Below:     true,
+	}
+}
+
+// QueueError 队列名格式不合法或不在配置的队列中
+type QueueError struct {
+	Queue  string
+	Reason string
+	// Valid 可用的队列，未配置队列集合时为空
+	Valid []string
+}
+
+func (e *QueueError) Error() string {
+	return fmt.Sprintf("invalid queue %q: %s", e.Queue, e.Reason)
+}
+
+func (e *QueueError) Unwrap() error {
+	return ErrInvalidQueue
+}
+
+func NewQueueError(queue, reason string, valid []string) *QueueError {
+	return &QueueError{
+		Queue:  queue,
+		Reason: reason,
+		Valid:  valid,
+	}
+}
+
+// DeprecatedError 任务类型或 payload 格式已过弃用截止时间
+type DeprecatedError struct {
+	// Name 配置中的弃用项名称
+	Name     string
+	TaskType string
+	Cutoff   time.Time
+	Message  string
+}
+
+func (e *DeprecatedError) Error() string {
+	msg := fmt.Sprintf("task type %s (%s) is deprecated and no longer accepted since %s", e.TaskType, e.Name, e.Cutoff.Format(time.RFC3339))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+func (e *DeprecatedError) Unwrap() error {
+	return ErrDeprecated
+}
+
 type RetryableError struct {
 	Cause      error
 	RetryAfter int
@@ -0,0 +1,123 @@
+package payload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidArtifacts 任务产出的文件列表未通过校验
+var ErrInvalidArtifacts = errors.New("invalid artifacts")
+
+// Artifact 任务产出的文件（报表、数据集等），URI 与 Key 二选一
+type Artifact struct {
+	// Name 文件名，同一任务内唯一，用于 GET /tasks/:id/artifacts/:name
+	Name string `json:"name"`
+
+	// ContentType 文件类型
+	ContentType string `json:"content_type,omitempty"`
+
+	// Size 文件大小（字节），未知时为 0
+	Size int64 `json:"size,omitempty"`
+
+	// URI 文件地址，scheme 须在 ArtifactPolicy.AllowedSchemes 中
+	URI string `json:"uri,omitempty"`
+
+	// Key TaskFlow 对象存储中的 key，须位于 ArtifactKeyPrefix(taskID) 之下
+	Key string `json:"key,omitempty"`
+}
+
+// ArtifactResult 带文件的任务结果，result 为任务原本的结果数据
+type ArtifactResult struct {
+	Result    json.RawMessage `json:"result,omitempty"`
+	Artifacts []Artifact      `json:"artifacts"`
+}
+
+// ArtifactPolicy 文件列表的校验规则
+type ArtifactPolicy struct {
+	// MaxCount 单个任务最多的文件数
+	MaxCount int
+	// AllowedSchemes 允许的 URI scheme（小写）
+	AllowedSchemes []string
+}
+
+// DefaultArtifactPolicy 未配置时使用的校验规则
+func DefaultArtifactPolicy() ArtifactPolicy {
+	return ArtifactPolicy{
+		MaxCount:       32,
+		AllowedSchemes: []string{"https", "s3"},
+	}
+}
+
+// ArtifactKeyPrefix 任务产出的文件在对象存储中的 key 前缀，后端只能引用本任务前缀下的对象
+func ArtifactKeyPrefix(taskID string) string {
+	return "artifacts/" + taskID + "/"
+}
+
+// ValidArtifactKey 检查 key 是否位于任务的文件前缀之下，不允许 ".." 等跳出前缀的路径
+func ValidArtifactKey(taskID, key string) bool {
+	prefix := ArtifactKeyPrefix(taskID)
+	return taskID != "" && len(key) > len(prefix) && strings.HasPrefix(key, prefix) && path.Clean(key) == key
+}
+
+// Validate 检查文件数量、文件名和地址，对象存储中的文件须位于 taskID 的文件前缀之下，错误包装 ErrInvalidArtifacts
+func (p ArtifactPolicy) Validate(taskID string, artifacts []Artifact) error {
+	if p.MaxCount > 0 && len(artifacts) > p.MaxCount {
+		return fmt.Errorf("%w: %d artifacts exceeds the limit of %d", ErrInvalidArtifacts, len(artifacts), p.MaxCount)
+	}
+
+	seen := make(map[string]bool, len(artifacts))
+	for i, a := range artifacts {
+		if err := validArtifactName(a.Name); err != nil {
+			return fmt.Errorf("%w: artifacts[%d]: %v", ErrInvalidArtifacts, i, err)
+		}
+		if seen[a.Name] {
+			return fmt.Errorf("%w: duplicate artifact name %q", ErrInvalidArtifacts, a.Name)
+		}
+		seen[a.Name] = true
+
+		if a.Size < 0 {
+			return fmt.Errorf("%w: artifact %q has negative size", ErrInvalidArtifacts, a.Name)
+		}
+		if (a.URI == "") == (a.Key == "") {
+			return fmt.Errorf("%w: artifact %q must have exactly one of uri and key", ErrInvalidArtifacts, a.Name)
+		}
+		if a.URI == "" {
+			if !ValidArtifactKey(taskID, a.Key) {
+				return fmt.Errorf("%w: artifact %q key must be under %q", ErrInvalidArtifacts, a.Name, ArtifactKeyPrefix(taskID))
+			}
+			continue
+		}
+		u, err := url.Parse(a.URI)
+		if err != nil || u.Scheme == "" {
+			return fmt.Errorf("%w: artifact %q has invalid uri", ErrInvalidArtifacts, a.Name)
+		}
+		if !slices.Contains(p.AllowedSchemes, strings.ToLower(u.Scheme)) {
+			return fmt.Errorf("%w: artifact %q uri scheme %q is not allowed", ErrInvalidArtifacts, a.Name, u.Scheme)
+		}
+	}
+	return nil
+}
+
+// validArtifactName 文件名出现在 URL 路径中，不允许路径分隔符和控制字符
+func validArtifactName(name string) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(name) > 255 {
+		return fmt.Errorf("name must be at most 255 bytes")
+	}
+	if name == "." || name == ".." {
+		return fmt.Errorf("name %q is reserved", name)
+	}
+	for _, r := range name {
+		if r == '/' || r == '\\' || r < 0x20 || r == 0x7f {
+			return fmt.Errorf("name %q contains a path separator or control character", name)
+		}
+	}
+	return nil
+}
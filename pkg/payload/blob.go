@@ -0,0 +1,19 @@
+package payload
+
+// BlobRef 引用对象存储中的文件，用于文件类任务的 payload
+type BlobRef struct {
+	// Key 对象存储中的 key
+	Key string `json:"key"`
+
+	// Filename 原始文件名
+	Filename string `json:"filename,omitempty"`
+
+	// ContentType 文件类型
+	ContentType string `json:"content_type,omitempty"`
+
+	// Size 文件大小（字节）
+	Size int64 `json:"size"`
+
+	// URI 对象地址
+	URI string `json:"uri"`
+}
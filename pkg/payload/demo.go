@@ -1,8 +1,25 @@
 package payload
 
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultDemoMaxCount demo 任务 count 的默认上限
+	DefaultDemoMaxCount = 100
+	// DefaultDemoMaxMessageLength demo 任务 message 的默认最大字符数
+	DefaultDemoMaxMessageLength = 1024
+	// DemoCountCeiling count 的硬上限，配置的上限不能超过它，handler 也会截断到它
+	DemoCountCeiling = 10000
+)
+
 type DemoPayload struct {
 	Message string `json:"message"`
 	Count   int    `json:"count,omitempty"`
+	// FailAtStep 执行到第几步时返回错误（可选），用于演示重试与失败流程
+	FailAtStep int `json:"fail_at_step,omitempty"`
 }
 
 type DemoResult struct {
@@ -10,3 +27,62 @@ type DemoResult struct {
 	Message string `json:"message"`
 	Count   int    `json:"count"`
 }
+
+// DemoLimits demo payload 的校验上限
+type DemoLimits struct {
+	MaxCount         int
+	MaxMessageLength int
+}
+
+// ValidateDemoPayload 校验 demo payload：count 省略时按 1 处理，显式给出时必须在 [1, MaxCount] 内；
+// message 不能超过 MaxMessageLength 个字符；fail_at_step 必须在 [0, count] 内。
+// 上限为 0 时使用默认值
+func ValidateDemoPayload(data []byte, limits DemoLimits) error {
+	var p struct {
+		Message    string `json:"message"`
+		Count      *int   `json:"count"`
+		FailAtStep int    `json:"fail_at_step"`
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return &ValidationError{Field: "payload", Message: err.Error()}
+	}
+
+	maxCount := limits.MaxCount
+	if maxCount <= 0 {
+		maxCount = DefaultDemoMaxCount
+	}
+	maxMessage := limits.MaxMessageLength
+	if maxMessage <= 0 {
+		maxMessage = DefaultDemoMaxMessageLength
+	}
+
+	count := 1
+	if p.Count != nil {
+		count = *p.Count
+	}
+	if count < 1 || count > maxCount {
+		return &ValidationError{Field: "count", Message: fmt.Sprintf("count must be between 1 and %d", maxCount)}
+	}
+	if n := utf8.RuneCountInString(p.Message); n > maxMessage {
+		return &ValidationError{Field: "message", Message: fmt.Sprintf("message must be at most %d characters, got %d", maxMessage, n)}
+	}
+	if p.FailAtStep < 0 || p.FailAtStep > count {
+		return &ValidationError{Field: "fail_at_step", Message: fmt.Sprintf("fail_at_step must be between 0 and count (%d)", count)}
+	}
+	return nil
+}
+
+// Normalize 将越界的 count 截断到 [1, DemoCountCeiling]，用于处理校验上线前入队的任务。
+// 返回截断前的 count 以及是否发生截断
+func (p *DemoPayload) Normalize() (original int, clamped bool) {
+	original = p.Count
+	switch {
+	case p.Count < 1:
+		p.Count = 1
+	case p.Count > DemoCountCeiling:
+		p.Count = DemoCountCeiling
+	default:
+		return original, false
+	}
+	return original, true
+}
@@ -0,0 +1,106 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// EventAttemptStarted 任务重试开始时发布的事件类型
+const EventAttemptStarted = "attempt_started"
+
+type attemptKey struct{}
+
+type attemptInfo struct {
+	attempt     int32
+	maxAttempts int32
+}
+
+// WithAttempt 在 context 中记录当前是第几次执行（从 1 开始）以及最多执行几次，
+// 通过该 context 发布的进度和完成事件都会带上这两个值
+func WithAttempt(ctx context.Context, attempt, maxAttempts int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attemptInfo{attempt: int32(attempt), maxAttempts: int32(maxAttempts)})
+}
+
+// AttemptFromContext 返回 WithAttempt 记录的执行次数，未记录时返回 false
+func AttemptFromContext(ctx context.Context) (attempt, maxAttempts int32, ok bool) {
+	info, ok := ctx.Value(attemptKey{}).(attemptInfo)
+	return info.attempt, info.maxAttempts, ok
+}
+
+// setAttempt 将执行次数写入 Stream 字段，prog 未指定时使用 context 中的值
+func setAttempt(ctx context.Context, values map[string]interface{}, attempt, maxAttempts int32) {
+	if attempt == 0 {
+		attempt, maxAttempts, _ = AttemptFromContext(ctx)
+	}
+	if attempt > 0 {
+		values["attempt"] = attempt
+	}
+	if maxAttempts > 0 {
+		values["max_attempts"] = maxAttempts
+	}
+}
+
+// PublishAttemptStarted 发布重试开始事件，执行次数取自 context（见 WithAttempt）。
+// 同时清除单调性检查记录的百分比，新一轮执行的进度可以从头开始
+func (p *Publisher) PublishAttemptStarted(ctx context.Context, taskID string) error {
+	attempt, maxAttempts, _ := AttemptFromContext(ctx)
+	p.forgetPercentage(taskID)
+
+	message := fmt.Sprintf("attempt %d started", attempt)
+	if maxAttempts > 0 {
+		message = fmt.Sprintf("attempt %d of %d started", attempt, maxAttempts)
+	}
+	values := map[string]interface{}{
+		"task_id":      taskID,
+		"percentage":   0,
+		"stage":        EventAttemptStarted,
+		"message":      message,
+		"event":        EventAttemptStarted,
+		"timestamp_ms": p.clock.Now().UnixMilli(),
+	}
+	setAttempt(ctx, values, attempt, maxAttempts)
+	setWorker(ctx, values)
+
+	args := &redis.XAddArgs{
+		Stream: StreamKey(taskID),
+		Values: values,
+	}
+	r := p.retention(ctx, nil)
+	if r.maxLen > 0 {
+		args.MaxLen = r.maxLen
+		args.Approx = true
+	}
+
+	start := p.clock.Now()
+	_, err := p.redis.XAdd(ctx, args).Result()
+	p.timer.done(OpXAdd, taskID, start)
+	p.recordResult(err)
+	if err != nil {
+		return fmt.Errorf("failed to publish attempt start: %w", err)
+	}
+	p.ensureTTL(ctx, StreamKey(taskID), r)
+
+	p.logger.Debug("attempt start published",
+		zap.String("task_id", taskID),
+		zap.Int32("attempt", attempt),
+	)
+	return nil
+}
+
+// parseAttempt 解析 Stream 字段中的执行次数
+func parseAttempt(values map[string]interface{}, prog *Progress) {
+	for field, dst := range map[string]*int32{"attempt": &prog.Attempt, "max_attempts": &prog.MaxAttempts} {
+		switch v := values[field].(type) {
+		case string:
+			if n, err := strconv.ParseInt(v, 10, 32); err == nil {
+				*dst = int32(n)
+			}
+		case int64:
+			*dst = int32(v)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+// 进度自检的状态
+const (
+	CanaryHealthy   = "healthy"
+	CanaryUnhealthy = "unhealthy"
+	CanaryPending   = "pending" // 尚未完成第一次检查
+)
+
+// 进度自检失败的原因，指明出问题的一侧
+const (
+	CanaryPublishFailed = "publish_failed" // worker 写入 canary 进度流失败
+	CanaryReadFailed    = "read_failed"    // API 读取 canary 进度流失败
+	CanaryStale         = "stale"          // API 读到的最新 canary 事件过旧或不存在，worker 写入的不是同一个流
+)
+
+// canaryStage canary 进度事件的 stage
+const canaryStage = "canary"
+
+// canaryCheckTimeout 单次自检的超时
+const canaryCheckTimeout = 5 * time.Second
+
+// CanaryStatus 最近一次进度自检的结果
+type CanaryStatus struct {
+	Status string `json:"status"`
+	// Error 失败原因，取值见 CanaryPublishFailed 等
+	Error string `json:"error,omitempty"`
+	// Detail 具体的错误信息
+	Detail        string `json:"detail,omitempty"`
+	CheckedAt     string `json:"checked_at,omitempty"`
+	LastSuccessAt string `json:"last_success_at,omitempty"`
+}
+
+// Canary 周期性地检查进度链路：worker 一侧向 canary 进度流发布事件，API 一侧读取同一个流并检查是否及时，
+// 任一侧的进度配置错误（Redis DB、key、TTL 等）都会在结果中体现，而不会因任务照常完成被掩盖
+type Canary struct {
+	check  func(ctx context.Context) (reason string, err error)
+	clock  clock.Clock
+	logger *zap.Logger
+
+	mu          sync.Mutex
+	status      CanaryStatus
+	lastSuccess time.Time
+}
+
+// NewPublishCanary 创建 worker 一侧的自检：每次检查向 taskID 的进度流发布一条 canary 事件
+func NewPublishCanary(publisher *Publisher, taskID string, logger *zap.Logger) *Canary {
+	clk := publisher.clock
+	return newCanary(clk, logger, func(ctx context.Context) (string, error) {
+		err := publisher.Publish(ctx, &Progress{
+			TaskID:      taskID,
+			Stage:       canaryStage,
+			Message:     "progress self-test",
+			TimestampMs: clk.Now().UnixMilli(),
+		})
+		if err != nil {
+			return CanaryPublishFailed, err
+		}
+		return "", nil
+	})
+}
+
+// NewReadCanary 创建 API 一侧的自检：每次检查读取 taskID 进度流的最新事件，
+// 读取失败、没有事件或事件早于 staleAfter 时判定为失败
+func NewReadCanary(subscriber *Subscriber, taskID string, staleAfter time.Duration, logger *zap.Logger) *Canary {
+	clk := subscriber.clock
+	return newCanary(clk, logger, func(ctx context.Context) (string, error) {
+		latest, err := subscriber.GetLatest(ctx, taskID)
+		if err != nil {
+			return CanaryReadFailed, err
+		}
+		if latest == nil || latest.Progress == nil {
+			return CanaryStale, fmt.Errorf("no canary event in stream %s", StreamKey(taskID))
+		}
+		age := clk.Since(time.UnixMilli(latest.Progress.TimestampMs))
+		if age > staleAfter {
+			return CanaryStale, fmt.Errorf("latest canary event in stream %s is %s old", StreamKey(taskID), age.Truncate(time.Second))
+		}
+		return "", nil
+	})
+}
+
+func newCanary(clk clock.Clock, logger *zap.Logger, check func(ctx context.Context) (string, error)) *Canary {
+	return &Canary{
+		check:  check,
+		clock:  clock.OrReal(clk),
+		logger: logger,
+		status: CanaryStatus{Status: CanaryPending},
+	}
+}
+
+// Run 立即检查一次，之后每隔 interval 检查，直到 ctx 结束
+func (c *Canary) Run(ctx context.Context, interval time.Duration) {
+	c.Check(ctx)
+
+	ticker := c.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			c.Check(ctx)
+		}
+	}
+}
+
+// Check 执行一次自检并返回结果
+func (c *Canary) Check(ctx context.Context) CanaryStatus {
+	ctx, cancel := context.WithTimeout(ctx, canaryCheckTimeout)
+	reason, err := c.check(ctx)
+	cancel()
+
+	now := c.clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.status.Status
+	c.status = CanaryStatus{Status: CanaryHealthy, CheckedAt: now.UTC().Format(time.RFC3339)}
+	if err != nil {
+		c.status.Status = CanaryUnhealthy
+		c.status.Error = reason
+		c.status.Detail = err.Error()
+	} else {
+		c.lastSuccess = now
+	}
+	if !c.lastSuccess.IsZero() {
+		c.status.LastSuccessAt = c.lastSuccess.UTC().Format(time.RFC3339)
+	}
+
+	// 只在状态变化时记录日志，避免持续失败时刷屏
+	switch {
+	case err != nil && prev != CanaryUnhealthy:
+		c.logger.Error("progress self-test failed",
+			zap.String("reason", reason),
+			zap.Error(err),
+		)
+	case err == nil && prev == CanaryUnhealthy:
+		c.logger.Info("progress self-test recovered")
+	}
+	return c.status
+}
+
+// Status 返回最近一次自检的结果
+func (c *Canary) Status() CanaryStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// Healthy 最近一次自检是否通过，尚未检查时视为通过
+func (c *Canary) Healthy() bool {
+	return c.Status().Status != CanaryUnhealthy
+}
@@ -0,0 +1,89 @@
+package progress
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+func TestCanaryPublishAndRead(t *testing.T) {
+	mr, client := newTestRedis(t)
+	fake := clock.NewFake(time.UnixMilli(1_700_000_000_000))
+	opts := StreamOptions{MaxLen: 10, TTL: time.Hour, Clock: fake}
+	writer := NewPublishCanary(NewPublisher(client, zap.NewNop(), opts), "_canary", zap.NewNop())
+	reader := NewReadCanary(NewSubscriber(client, zap.NewNop(), opts), "_canary", 2*time.Minute, zap.NewNop())
+	ctx := context.Background()
+
+	if s := reader.Status(); s.Status != CanaryPending || !reader.Healthy() {
+		t.Fatalf("expected pending status before the first check, got %+v", s)
+	}
+
+	// worker 尚未发布
+	if s := reader.Check(ctx); s.Status != CanaryUnhealthy || s.Error != CanaryStale {
+		t.Fatalf("expected stale without canary events, got %+v", s)
+	}
+
+	if s := writer.Check(ctx); s.Status != CanaryHealthy {
+		t.Fatalf("expected publish to succeed, got %+v", s)
+	}
+	if s := reader.Check(ctx); s.Status != CanaryHealthy || s.LastSuccessAt == "" {
+		t.Fatalf("expected read to succeed, got %+v", s)
+	}
+
+	// worker 停止发布后读到的事件逐渐过旧
+	fake.Advance(3 * time.Minute)
+	s := reader.Check(ctx)
+	if s.Status != CanaryUnhealthy || s.Error != CanaryStale || !strings.Contains(s.Detail, "3m0s old") {
+		t.Fatalf("expected stale canary, got %+v", s)
+	}
+	if reader.Healthy() {
+		t.Fatal("expected reader to report unhealthy")
+	}
+
+	mr.SetError("LOADING")
+	if s := writer.Check(ctx); s.Error != CanaryPublishFailed || s.Detail == "" {
+		t.Fatalf("expected publish failure, got %+v", s)
+	}
+	if s := reader.Check(ctx); s.Error != CanaryReadFailed || s.LastSuccessAt == "" {
+		t.Fatalf("expected read failure keeping the last success, got %+v", s)
+	}
+}
+
+func TestCanaryRunChecksEveryInterval(t *testing.T) {
+	_, client := newTestRedis(t)
+	fake := clock.NewFake(time.UnixMilli(1_700_000_000_000))
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{MaxLen: 10, Clock: fake})
+	canary := NewPublishCanary(publisher, "_canary", zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		canary.Run(ctx, 30*time.Second)
+	}()
+
+	fake.BlockUntil(1)
+	fake.Advance(30 * time.Second)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		n, err := client.XLen(context.Background(), StreamKey("_canary")).Result()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 2 canary events, got %d", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
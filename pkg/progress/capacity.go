@@ -0,0 +1,68 @@
+package progress
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrSubscriptionCapacity 订阅连接已用尽，调用方应稍后重试
+var ErrSubscriptionCapacity = errors.New("progress subscription capacity exhausted")
+
+// CodeSubscriptionCapacity 订阅连接用尽的错误码，与 SubscribeResult.Error 对应
+const CodeSubscriptionCapacity = "SUBSCRIPTION_CAPACITY"
+
+// Reserve 为 n 个订阅预留阻塞读取连接，全部预留成功或全部失败。
+// 容量不足时返回 ErrSubscriptionCapacity；成功时返回的 release 在订阅结束后调用，可重复调用。
+// 未设置 MaxSubscriptions 时不限制
+func (s *Subscriber) Reserve(n int) (release func(), err error) {
+	if s.slots == nil || n <= 0 {
+		return func() {}, nil
+	}
+
+	for i := 0; i < n; i++ {
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			for ; i > 0; i-- {
+				<-s.slots
+			}
+			s.rejected.Add(1)
+			return nil, ErrSubscriptionCapacity
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			for i := 0; i < n; i++ {
+				<-s.slots
+			}
+		})
+	}, nil
+}
+
+// ActiveSubscriptions 返回正在进行的订阅数量
+func (s *Subscriber) ActiveSubscriptions() int {
+	return int(s.active.Load())
+}
+
+// ReservedSubscriptions 返回已预留的订阅连接数量，未限制时为 0
+func (s *Subscriber) ReservedSubscriptions() int {
+	return len(s.slots)
+}
+
+// SubscriptionCapacity 返回订阅连接上限，0 表示不限制
+func (s *Subscriber) SubscriptionCapacity() int {
+	return cap(s.slots)
+}
+
+// SubscriptionRejections 返回因容量不足被拒绝的预留次数
+func (s *Subscriber) SubscriptionRejections() uint64 {
+	return s.rejected.Load()
+}
+
+// SubscriptionConnsInUse 返回订阅专用连接池中正在使用的连接数
+func (s *Subscriber) SubscriptionConnsInUse() int {
+	stats := s.blocking.PoolStats()
+	return int(stats.TotalConns) - int(stats.IdleConns)
+}
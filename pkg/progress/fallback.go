@@ -0,0 +1,213 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	// degradedFailureThreshold 连续失败达到该次数时认为进度发布降级
+	degradedFailureThreshold = 3
+
+	completionRetryMinBackoff = 200 * time.Millisecond
+	completionRetryMaxBackoff = 10 * time.Second
+	completionRetryTimeout    = 5 * time.Second
+)
+
+// pendingCompletion XADD 失败后缓存在内存中的完成事件
+type pendingCompletion struct {
+	taskID   string
+	args     *redis.XAddArgs
+	deadline time.Time
+	// retention 发布时解析的保留策略，送达后据此缩短过期时间
+	retention retention
+}
+
+// fallback 完成事件的内存缓冲与重试状态
+type fallback struct {
+	mu       sync.Mutex
+	failures int // 连续失败次数
+	pending  []*pendingCompletion
+	retrying bool
+}
+
+// recordResult 记录一次 XADD 结果，用于判断是否降级
+func (p *Publisher) recordResult(err error) {
+	p.fallback.mu.Lock()
+	defer p.fallback.mu.Unlock()
+
+	if err != nil {
+		p.fallback.failures++
+		return
+	}
+	p.fallback.failures = 0
+}
+
+// Degraded 返回进度发布是否处于降级状态：连续多次写入失败，或仍有未送达的完成事件
+func (p *Publisher) Degraded() bool {
+	p.fallback.mu.Lock()
+	defer p.fallback.mu.Unlock()
+	return p.fallback.failures >= degradedFailureThreshold || len(p.fallback.pending) > 0
+}
+
+// hasPendingCompletion 任务是否有等待重试的完成事件
+func (p *Publisher) hasPendingCompletion(taskID string) bool {
+	p.fallback.mu.Lock()
+	defer p.fallback.mu.Unlock()
+	for _, pc := range p.fallback.pending {
+		if pc.taskID == taskID {
+			return true
+		}
+	}
+	return false
+}
+
+// PendingCompletions 返回等待重试的完成事件数量
+func (p *Publisher) PendingCompletions() int {
+	p.fallback.mu.Lock()
+	defer p.fallback.mu.Unlock()
+	return len(p.fallback.pending)
+}
+
+// bufferCompletion 缓存发送失败的完成事件，并在重试窗口内后台重试
+func (p *Publisher) bufferCompletion(taskID string, args *redis.XAddArgs, r retention) {
+	p.fallback.mu.Lock()
+	defer p.fallback.mu.Unlock()
+
+	p.fallback.pending = append(p.fallback.pending, &pendingCompletion{
+		taskID:   taskID,
+		args:     args,
+		deadline: p.clock.Now().Add(p.options.CompletionRetryWindow),
+
+		retention: r,
+	})
+
+	if !p.fallback.retrying {
+		p.fallback.retrying = true
+		go p.retryCompletions()
+	}
+}
+
+// retryCompletions 以指数退避重试缓存的完成事件，直到全部送达或过期
+func (p *Publisher) retryCompletions() {
+	backoff := completionRetryMinBackoff
+	for {
+		<-p.clock.After(backoff)
+
+		ctx, cancel := context.WithTimeout(context.Background(), completionRetryTimeout)
+		delivered, remaining := p.flushPending(ctx)
+		cancel()
+
+		if remaining == 0 {
+			return
+		}
+		if delivered > 0 {
+			backoff = completionRetryMinBackoff
+		} else if backoff *= 2; backoff > completionRetryMaxBackoff {
+			backoff = completionRetryMaxBackoff
+		}
+	}
+}
+
+// confirmCompletion 在调用方内以指数退避重试完成事件，直到送达或超出重试窗口。
+// 任务 context 已取消时仍继续重试，保证完成事件不因任务超时而丢失
+func (p *Publisher) confirmCompletion(ctx context.Context, taskID string, args *redis.XAddArgs, r retention) error {
+	ctx = context.WithoutCancel(ctx)
+	deadline := p.clock.Now().Add(p.options.CompletionRetryWindow)
+	backoff := completionRetryMinBackoff
+
+	var err error
+	for {
+		if wait := deadline.Sub(p.clock.Now()); wait <= 0 {
+			break
+		} else if backoff > wait {
+			backoff = wait
+		}
+		<-p.clock.After(backoff)
+
+		attemptCtx, cancel := context.WithTimeout(ctx, completionRetryTimeout)
+		start := p.clock.Now()
+		_, err = p.redis.XAdd(attemptCtx, args).Result()
+		cancel()
+		p.timer.done(OpXAdd, taskID, start)
+		p.recordResult(err)
+		if err == nil {
+			p.expireCompleted(ctx, taskID, r)
+			p.logger.Info("completion delivered after retry", zap.String("task_id", taskID))
+			return nil
+		}
+
+		if backoff *= 2; backoff > completionRetryMaxBackoff {
+			backoff = completionRetryMaxBackoff
+		}
+	}
+
+	p.completionsDropped.Add(1)
+	p.logger.Error("dropping completion event after retry window",
+		zap.String("task_id", taskID),
+		zap.Duration("window", p.options.CompletionRetryWindow),
+		zap.Error(err),
+	)
+	return fmt.Errorf("failed to publish completion within %s: %w", p.options.CompletionRetryWindow, err)
+}
+
+// CompletionsDropped 返回最终未能送达（超出重试窗口或未开启重试）的完成事件累计数量
+func (p *Publisher) CompletionsDropped() uint64 {
+	return p.completionsDropped.Load()
+}
+
+// Flush 立即尝试发送所有缓存的完成事件，返回仍未送达的数量
+// 通常在进程退出前调用
+func (p *Publisher) Flush(ctx context.Context) int {
+	_, remaining := p.flushPending(ctx)
+	return remaining
+}
+
+// flushPending 发送一轮缓存的完成事件，丢弃超出重试窗口的事件
+// 没有剩余事件时结束后台重试
+func (p *Publisher) flushPending(ctx context.Context) (delivered, remaining int) {
+	p.fallback.mu.Lock()
+	batch := p.fallback.pending
+	p.fallback.pending = nil
+	p.fallback.mu.Unlock()
+
+	var kept []*pendingCompletion
+	for _, pc := range batch {
+		if p.clock.Now().After(pc.deadline) {
+			p.completionsDropped.Add(1)
+			p.logger.Error("dropping completion event after retry window",
+				zap.String("task_id", pc.taskID),
+				zap.Duration("window", p.options.CompletionRetryWindow),
+			)
+			continue
+		}
+
+		start := p.clock.Now()
+		_, err := p.redis.XAdd(ctx, pc.args).Result()
+		p.timer.done(OpXAdd, pc.taskID, start)
+		p.recordResult(err)
+		if err != nil {
+			kept = append(kept, pc)
+			continue
+		}
+
+		delivered++
+		p.expireCompleted(ctx, pc.taskID, pc.retention)
+		p.logger.Info("buffered completion delivered", zap.String("task_id", pc.taskID))
+	}
+
+	p.fallback.mu.Lock()
+	defer p.fallback.mu.Unlock()
+
+	p.fallback.pending = append(kept, p.fallback.pending...)
+	remaining = len(p.fallback.pending)
+	if remaining == 0 {
+		p.fallback.retrying = false
+	}
+	return delivered, remaining
+}
@@ -0,0 +1,174 @@
+package progress
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+func waitForPending(t *testing.T, publisher *Publisher, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for publisher.PendingCompletions() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d pending completions, got %d", want, publisher.PendingCompletions())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPublishCompletionRetriesAfterRedisRecovers(t *testing.T) {
+	mr, client := newTestRedis(t)
+	fake := clock.NewFake(time.UnixMilli(1_700_000_000_000))
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{
+		MaxLen:                10,
+		TTL:                   time.Hour,
+		CompletedTTL:          5 * time.Minute,
+		Clock:                 fake,
+		CompletionRetryWindow: time.Minute,
+	})
+
+	ctx := context.Background()
+	mr.SetError("LOADING")
+	for i := 0; i < degradedFailureThreshold; i++ {
+		_ = publisher.Publish(ctx, &Progress{TaskID: "task-1", Percentage: int32(i)})
+	}
+	if err := publisher.PublishCompletion(ctx, "task-1", "completed", "done"); err != nil {
+		t.Fatalf("expected completion to be buffered, got %v", err)
+	}
+	if !publisher.Degraded() || publisher.PendingCompletions() != 1 {
+		t.Fatalf("expected degraded publisher with one pending completion")
+	}
+
+	fake.BlockUntil(1)
+	mr.SetError("")
+	fake.Advance(completionRetryMinBackoff)
+	waitForPending(t, publisher, 0)
+
+	if publisher.Degraded() {
+		t.Fatalf("expected publisher to recover after delivery")
+	}
+	latest, err := NewSubscriber(client, zap.NewNop()).GetLatest(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !latest.IsFinal || latest.Status != "completed" {
+		t.Fatalf("expected delivered completion, got %+v", latest)
+	}
+	if ttl := mr.TTL(StreamKey("task-1")); ttl != 5*time.Minute {
+		t.Fatalf("expected completed ttl after delayed delivery, got %v", ttl)
+	}
+}
+
+func TestPublishCompletionDropsAfterRetryWindow(t *testing.T) {
+	mr, client := newTestRedis(t)
+	fake := clock.NewFake(time.UnixMilli(1_700_000_000_000))
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{
+		MaxLen:                10,
+		Clock:                 fake,
+		CompletionRetryWindow: time.Second,
+	})
+
+	mr.SetError("LOADING")
+	if err := publisher.PublishCompletion(context.Background(), "task-1", "failed", "boom"); err != nil {
+		t.Fatalf("expected completion to be buffered, got %v", err)
+	}
+
+	fake.BlockUntil(1)
+	fake.Advance(2 * time.Second)
+	waitForPending(t, publisher, 0)
+	if got := publisher.CompletionsDropped(); got != 1 {
+		t.Fatalf("expected one dropped completion, got %d", got)
+	}
+}
+
+func TestPublishCompletionWithoutRetryWindowReturnsError(t *testing.T) {
+	mr, client := newTestRedis(t)
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{MaxLen: 10})
+
+	mr.SetError("LOADING")
+	if err := publisher.PublishCompletion(context.Background(), "task-1", "completed", "done"); err == nil {
+		t.Fatalf("expected error when retry window is disabled")
+	}
+	if publisher.PendingCompletions() != 0 {
+		t.Fatalf("expected nothing buffered")
+	}
+	if got := publisher.CompletionsDropped(); got != 1 {
+		t.Fatalf("expected one dropped completion, got %d", got)
+	}
+}
+
+func TestConfirmCompletionBlocksUntilDelivered(t *testing.T) {
+	mr, client := newTestRedis(t)
+	fake := clock.NewFake(time.UnixMilli(1_700_000_000_000))
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{
+		MaxLen:                10,
+		Clock:                 fake,
+		CompletionRetryWindow: time.Minute,
+		ConfirmCompletion:     true,
+	})
+
+	// 任务 context 已取消，确认模式下仍需继续重试
+	ctx, cancel := context.WithCancel(context.Background())
+	mr.SetError("LOADING")
+	done := make(chan error, 1)
+	go func() {
+		done <- publisher.PublishCompletion(ctx, "task-1", "completed", "done")
+	}()
+
+	fake.BlockUntil(1)
+	cancel()
+	select {
+	case err := <-done:
+		t.Fatalf("expected publish to block until delivered, returned %v", err)
+	default:
+	}
+
+	mr.SetError("")
+	fake.Advance(completionRetryMinBackoff)
+	if err := <-done; err != nil {
+		t.Fatalf("expected confirmed delivery, got %v", err)
+	}
+	if publisher.PendingCompletions() != 0 || publisher.CompletionsDropped() != 0 {
+		t.Fatalf("expected nothing buffered or dropped")
+	}
+
+	latest, err := NewSubscriber(client, zap.NewNop()).GetLatest(context.Background(), "task-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !latest.IsFinal || latest.Status != "completed" {
+		t.Fatalf("expected delivered completion, got %+v", latest)
+	}
+}
+
+func TestConfirmCompletionFailsAfterRetryWindow(t *testing.T) {
+	mr, client := newTestRedis(t)
+	fake := clock.NewFake(time.UnixMilli(1_700_000_000_000))
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{
+		MaxLen:                10,
+		Clock:                 fake,
+		CompletionRetryWindow: time.Second,
+		ConfirmCompletion:     true,
+	})
+
+	mr.SetError("LOADING")
+	done := make(chan error, 1)
+	go func() {
+		done <- publisher.PublishCompletion(context.Background(), "task-1", "failed", "boom")
+	}()
+
+	fake.BlockUntil(1)
+	fake.Advance(time.Second)
+	if err := <-done; err == nil {
+		t.Fatalf("expected error after retry window")
+	}
+	if got := publisher.CompletionsDropped(); got != 1 {
+		t.Fatalf("expected one dropped completion, got %d", got)
+	}
+}
@@ -0,0 +1,414 @@
+package progress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// 消费组默认配置
+const (
+	defaultGroupScanInterval = 5 * time.Second
+	defaultGroupClaimIdle    = time.Minute
+	defaultGroupCount        = 10
+	// groupRetryDelay Redis 出错后重试前的等待时间
+	groupRetryDelay = time.Second
+)
+
+// GroupOptions 消费组订阅的可选配置，零值使用默认值
+type GroupOptions struct {
+	// ScanInterval 重新扫描匹配的进度流并接管超时事件的间隔，新任务的事件在下一次扫描后开始投递，默认 5s
+	ScanInterval time.Duration
+	// ClaimIdle 已投递给其他消费者但超过该时间未确认的事件由本消费者接管，默认 1m。
+	// 应大于处理单条事件的最长时间，否则仍在处理的事件会被重复投递
+	ClaimIdle time.Duration
+	// Count 每次读取的最多条数，默认 10
+	Count int64
+}
+
+func (o GroupOptions) withDefaults() GroupOptions {
+	if o.ScanInterval <= 0 {
+		o.ScanInterval = defaultGroupScanInterval
+	}
+	if o.ClaimIdle <= 0 {
+		o.ClaimIdle = defaultGroupClaimIdle
+	}
+	if o.Count <= 0 {
+		o.Count = defaultGroupCount
+	}
+	return o
+}
+
+// GroupMessage 消费组投递的一条进度事件，处理完成后须调用 Ack，否则会在 ClaimIdle 后重新投递
+type GroupMessage struct {
+	SubscribeResult
+	// TaskID 事件所属的任务
+	TaskID string
+	// Redelivered 事件之前已投递过（本消费者重启前未确认，或从其他消费者接管），调用方应按 StreamID 去重
+	Redelivered bool
+
+	ack func(ctx context.Context) error
+}
+
+// Ack 确认事件已处理，之后不会再投递给组内任何消费者
+func (m GroupMessage) Ack(ctx context.Context) error {
+	if m.ack == nil {
+		return nil
+	}
+	return m.ack(ctx)
+}
+
+// GroupSubscription 一次消费组订阅，由 SubscribeGroup 创建。不再读取时必须调用 Close
+type GroupSubscription struct {
+	ch     <-chan GroupMessage
+	cancel context.CancelFunc
+	closed sync.Once
+}
+
+// Next 阻塞等待下一条事件，订阅已关闭或 ctx 结束时返回 false
+func (g *GroupSubscription) Next(ctx context.Context) (GroupMessage, bool) {
+	select {
+	case msg, ok := <-g.ch:
+		return msg, ok
+	case <-ctx.Done():
+		return GroupMessage{}, false
+	}
+}
+
+// Close 停止订阅并等待后台读取退出，可重复调用。未确认的事件留在组内，之后由同名消费者或接管重新投递
+func (g *GroupSubscription) Close() {
+	g.closed.Do(func() {
+		g.cancel()
+		for range g.ch {
+		}
+	})
+}
+
+// SubscribeGroup 以消费组方式读取 taskPattern（Redis glob，如 "*"）匹配的所有任务的进度流。
+// 每条事件只投递给组内一个消费者，确认前一直保留：consumer 重启后先收到自己未确认的事件，
+// 崩溃的消费者持有的事件超过 ClaimIdle 后由其他消费者接管。组在每个进度流上从头创建，
+// 因此订阅前已写入的事件也会投递。与 Subscribe 互不影响，进度流过期后其中未确认的事件随之丢弃。
+// 订阅期间占用一个阻塞读取连接，设置了 MaxSubscriptions 且容量不足时返回 ErrSubscriptionCapacity
+func (s *Subscriber) SubscribeGroup(ctx context.Context, taskPattern, group, consumer string, opts ...GroupOptions) (*GroupSubscription, error) {
+	if taskPattern == "" || group == "" || consumer == "" {
+		return nil, errors.New("task pattern, group and consumer are required")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var opt GroupOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	release, err := s.Reserve(1)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	ch := make(chan GroupMessage, 10)
+	r := &groupReader{
+		s:        s,
+		pattern:  StreamKey(taskPattern),
+		group:    group,
+		consumer: consumer,
+		opts:     opt.withDefaults(),
+		streams:  make(map[string]bool),
+		ch:       ch,
+		release:  release,
+	}
+	s.active.Add(1)
+	go r.run(ctx)
+	return &GroupSubscription{ch: ch, cancel: cancel}, nil
+}
+
+// groupReader SubscribeGroup 的后台读取
+type groupReader struct {
+	s        *Subscriber
+	pattern  string
+	group    string
+	consumer string
+	opts     GroupOptions
+	// streams 已创建消费组的进度流
+	streams map[string]bool
+	ch      chan<- GroupMessage
+	// release 归还 SubscribeGroup 预留的连接
+	release func()
+}
+
+func (r *groupReader) run(ctx context.Context) {
+	defer close(r.ch)
+	defer r.s.active.Add(-1)
+	defer r.release()
+	logger := r.s.logger.With(zap.String("group", r.group), zap.String("consumer", r.consumer))
+
+	var nextScan time.Time
+	for ctx.Err() == nil {
+		if now := r.s.clock.Now(); !now.Before(nextScan) {
+			if err := r.scan(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				logger.Warn("failed to scan progress streams for consumer group", zap.Error(err))
+				r.wait(ctx, groupRetryDelay)
+				continue
+			}
+			nextScan = now.Add(r.opts.ScanInterval)
+		}
+		if len(r.streams) == 0 {
+			r.wait(ctx, r.opts.ScanInterval)
+			continue
+		}
+
+		block := max(min(nextScan.Sub(r.s.clock.Now()), r.opts.ScanInterval), time.Millisecond)
+		err := r.read(ctx, block)
+		switch {
+		case err == nil || errors.Is(err, redis.Nil):
+		case ctx.Err() != nil:
+			return
+		case isNoGroup(err):
+			// 进度流过期或被删除：重新扫描，仍存在的流上组会重建
+			clear(r.streams)
+			nextScan = time.Time{}
+		default:
+			logger.Warn("failed to read progress streams with consumer group", zap.Error(err))
+			r.wait(ctx, groupRetryDelay)
+		}
+	}
+}
+
+// scan 为新出现的进度流创建消费组并投递本消费者未确认的事件，再接管其他消费者超时未确认的事件
+func (r *groupReader) scan(ctx context.Context) error {
+	var added []string
+	iter := r.s.redis.ScanType(ctx, 0, r.pattern, 100, "stream").Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		if r.streams[key] {
+			continue
+		}
+		if err := createGroup(ctx, r.s.redis, key, r.group, "0"); err != nil {
+			return err
+		}
+		r.streams[key] = true
+		added = append(added, key)
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	// 重启前已投递给本消费者但未确认的事件
+	if err := r.readPending(ctx, added); err != nil {
+		return err
+	}
+
+	for key := range r.streams {
+		if err := r.claim(ctx, key); err != nil {
+			if isNoGroup(err) {
+				delete(r.streams, key)
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// readPending 从头读取本消费者在 keys 上未确认的事件
+func (r *groupReader) readPending(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		start := "0"
+		for {
+			streams, err := r.s.redis.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    r.group,
+				Consumer: r.consumer,
+				Streams:  []string{key, start},
+				Count:    r.opts.Count,
+				Block:    -1,
+			}).Result()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				return err
+			}
+			if len(streams) == 0 || len(streams[0].Messages) == 0 {
+				break
+			}
+			messages := streams[0].Messages
+			if !r.deliver(ctx, key, messages, true) {
+				return ctx.Err()
+			}
+			start = messages[len(messages)-1].ID
+		}
+	}
+	return nil
+}
+
+// claim 接管 key 上其他消费者超过 ClaimIdle 未确认的事件
+func (r *groupReader) claim(ctx context.Context, key string) error {
+	start := "0-0"
+	for {
+		messages, next, err := r.s.redis.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   key,
+			Group:    r.group,
+			Consumer: r.consumer,
+			MinIdle:  r.opts.ClaimIdle,
+			Start:    start,
+			Count:    r.opts.Count,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		if len(messages) > 0 {
+			r.s.logger.Info("claimed idle progress events",
+				zap.String("group", r.group),
+				zap.String("consumer", r.consumer),
+				zap.String("stream", key),
+				zap.Int("count", len(messages)),
+			)
+			if !r.deliver(ctx, key, messages, true) {
+				return ctx.Err()
+			}
+		}
+		if next == "0-0" || next == "" {
+			return nil
+		}
+		start = next
+	}
+}
+
+// read 阻塞读取尚未投递给组内任何消费者的新事件
+func (r *groupReader) read(ctx context.Context, block time.Duration) error {
+	keys := make([]string, 0, len(r.streams))
+	for key := range r.streams {
+		keys = append(keys, key)
+	}
+	args := make([]string, 0, 2*len(keys))
+	args = append(args, keys...)
+	for range keys {
+		args = append(args, ">")
+	}
+
+	start := r.s.clock.Now()
+	streams, err := r.s.blocking.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    r.group,
+		Consumer: r.consumer,
+		Streams:  args,
+		Count:    r.opts.Count,
+		Block:    block,
+	}).Result()
+	r.s.timer.doneBlocking(OpXReadGroup, r.pattern, start, block)
+	if err != nil {
+		return err
+	}
+	for _, stream := range streams {
+		if !r.deliver(ctx, stream.Stream, stream.Messages, false) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// deliver 把消息发送给调用方，ctx 结束时返回 false
+func (r *groupReader) deliver(ctx context.Context, key string, messages []redis.XMessage, redelivered bool) bool {
+	taskID := strings.TrimPrefix(key, StreamKey(""))
+	for _, msg := range messages {
+		// 已被裁剪的事件在 XAUTOCLAIM 结果中没有内容，确认后跳过
+		if msg.Values == nil {
+			_ = r.s.redis.XAck(ctx, key, r.group, msg.ID).Err()
+			continue
+		}
+		id := msg.ID
+		gm := GroupMessage{
+			SubscribeResult: r.s.parseMessage(taskID, msg),
+			TaskID:          taskID,
+			Redelivered:     redelivered,
+			ack: func(ctx context.Context) error {
+				return r.s.redis.XAck(ctx, key, r.group, id).Err()
+			},
+		}
+		select {
+		case r.ch <- gm:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// wait 等待 d 或 ctx 结束
+func (r *groupReader) wait(ctx context.Context, d time.Duration) {
+	select {
+	case <-r.s.clock.After(d):
+	case <-ctx.Done():
+	}
+}
+
+// CreateGroup 在 taskID 的进度流上创建消费组，startID 为 "0" 时包含已有事件，"$" 只投递之后的事件。
+// 组已存在时不做修改；进度流不存在时返回错误
+func (s *Subscriber) CreateGroup(ctx context.Context, taskID, group, startID string) error {
+	return createGroup(ctx, s.redis, StreamKey(taskID), group, startID)
+}
+
+func createGroup(ctx context.Context, client *redis.Client, key, group, startID string) error {
+	err := client.XGroupCreate(ctx, key, group, startID).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group on %s: %w", key, err)
+	}
+	return nil
+}
+
+// GroupLag 消费组在一个进度流上的积压
+type GroupLag struct {
+	TaskID string
+	// Pending 已投递但未确认的事件数
+	Pending int64
+	// Lag 尚未投递给组内任何消费者的事件数
+	Lag int64
+	// Consumers 组内的消费者数
+	Consumers int64
+}
+
+// GroupLags 返回 taskPattern 匹配的进度流上 group 的积压，只包含已创建该组的流。
+// Lag 通过读取最后投递位置之后的事件计算，成本与进度流长度（MaxLen）成正比
+func (s *Subscriber) GroupLags(ctx context.Context, taskPattern, group string) ([]GroupLag, error) {
+	var lags []GroupLag
+	iter := s.redis.ScanType(ctx, 0, StreamKey(taskPattern), 100, "stream").Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		groups, err := s.redis.XInfoGroups(ctx, key).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to inspect consumer groups on %s: %w", key, err)
+		}
+		for _, g := range groups {
+			if g.Name != group {
+				continue
+			}
+			undelivered, err := s.redis.XRange(ctx, key, exclusiveStart(g.LastDeliveredID), "+").Result()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", key, err)
+			}
+			lags = append(lags, GroupLag{
+				TaskID:    strings.TrimPrefix(key, StreamKey("")),
+				Pending:   g.Pending,
+				Lag:       int64(len(undelivered)),
+				Consumers: g.Consumers,
+			})
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return lags, nil
+}
+
+// isNoGroup 进度流或其上的消费组不存在
+func isNoGroup(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOGROUP")
+}
@@ -0,0 +1,215 @@
+package progress
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+	"go.uber.org/goleak"
+	"go.uber.org/zap"
+)
+
+// readGroup 读取 n 条事件，ack 为 true 时逐条确认
+func readGroup(t *testing.T, sub *GroupSubscription, n int, ack bool) []GroupMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var messages []GroupMessage
+	for len(messages) < n {
+		msg, ok := sub.Next(ctx)
+		if !ok {
+			t.Fatalf("expected %d group messages, got %d", n, len(messages))
+		}
+		if ack {
+			if err := msg.Ack(ctx); err != nil {
+				t.Fatalf("ack: %v", err)
+			}
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func streamIDs(messages []GroupMessage) []string {
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.TaskID + "/" + msg.StreamID
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestSubscribeGroupSharesEventsBetweenConsumers(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+	t.Cleanup(func() { goleak.VerifyNone(t, ignore) })
+
+	_, client := newTestRedis(t)
+	fake := clock.NewFake(time.UnixMilli(1_700_000_000_000))
+	streamOpts := DefaultOptions()
+	streamOpts.Clock = fake
+	publisher := NewPublisher(client, zap.NewNop(), streamOpts)
+	subscriber := NewSubscriber(client, zap.NewNop(), streamOpts)
+	ctx := context.Background()
+
+	for _, taskID := range []string{"task-a", "task-b"} {
+		for pct := int32(10); pct <= 30; pct += 10 {
+			if err := publisher.Publish(ctx, NewProgress(taskID, pct, "running", "")); err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+		}
+	}
+
+	opts := GroupOptions{ScanInterval: 20 * time.Millisecond}
+	first, err := subscriber.SubscribeGroup(ctx, "task-*", "analytics", "first", opts)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer first.Close()
+	got := readGroup(t, first, 6, true)
+	if streams := streamIDs(got); len(slices.Compact(streams)) != 6 {
+		t.Fatalf("expected six distinct events, got %v", streams)
+	}
+
+	// 同组的第二个消费者只收到之后的新事件，且不会与第一个重复
+	second, err := subscriber.SubscribeGroup(ctx, "task-*", "analytics", "second", opts)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer second.Close()
+	if err := publisher.PublishCompletion(ctx, "task-c", "completed", "done"); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	// 新任务的进度流在下一次扫描后才开始读取
+	fake.Advance(opts.ScanInterval)
+
+	readCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var final GroupMessage
+	select {
+	case final = <-first.ch:
+	case final = <-second.ch:
+	case <-readCtx.Done():
+		t.Fatal("expected the completion event to be delivered to one consumer")
+	}
+	if final.TaskID != "task-c" || !final.IsFinal || final.Redelivered {
+		t.Fatalf("unexpected completion event %+v", final)
+	}
+	if err := final.Ack(ctx); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+
+	lags, err := subscriber.GroupLags(ctx, "task-*", "analytics")
+	if err != nil {
+		t.Fatalf("lags: %v", err)
+	}
+	for _, lag := range lags {
+		if lag.Pending != 0 || lag.Lag != 0 {
+			t.Fatalf("expected every event acknowledged, got %+v", lag)
+		}
+	}
+}
+
+func TestSubscribeGroupRedeliversAfterCrash(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+	t.Cleanup(func() { goleak.VerifyNone(t, ignore) })
+
+	mr, client := newTestRedis(t)
+	now := time.UnixMilli(1_700_000_000_000)
+	mr.SetTime(now)
+	fake := clock.NewFake(now)
+	streamOpts := DefaultOptions()
+	streamOpts.Clock = fake
+	publisher := NewPublisher(client, zap.NewNop(), streamOpts)
+	subscriber := NewSubscriber(client, zap.NewNop(), streamOpts)
+	ctx := context.Background()
+
+	for pct := int32(10); pct <= 30; pct += 10 {
+		if err := publisher.Publish(ctx, NewProgress("task-1", pct, "running", "")); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	// crashed 读到事件后没有确认就退出
+	opts := GroupOptions{ScanInterval: 20 * time.Millisecond, ClaimIdle: time.Minute}
+	crashed, err := subscriber.SubscribeGroup(ctx, "*", "ingest", "crashed", opts)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	delivered := readGroup(t, crashed, 3, false)
+	crashed.Close()
+
+	lags, err := subscriber.GroupLags(ctx, "*", "ingest")
+	if err != nil {
+		t.Fatalf("lags: %v", err)
+	}
+	if len(lags) != 1 || lags[0].TaskID != "task-1" || lags[0].Pending != 3 || lags[0].Lag != 0 {
+		t.Fatalf("expected three pending events, got %+v", lags)
+	}
+
+	// 同名消费者重启后先收到自己未确认的事件
+	restarted, err := subscriber.SubscribeGroup(ctx, "*", "ingest", "crashed", opts)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	redelivered := readGroup(t, restarted, 3, false)
+	restarted.Close()
+	if !slices.Equal(streamIDs(redelivered), streamIDs(delivered)) || !redelivered[0].Redelivered {
+		t.Fatalf("expected the unacknowledged events again, got %+v", redelivered)
+	}
+
+	// 另一个消费者在事件空闲超过 ClaimIdle 后接管，空闲时间由 Redis 计算
+	mr.SetTime(now.Add(2 * opts.ClaimIdle))
+	fake.Advance(2 * opts.ClaimIdle)
+	survivor, err := subscriber.SubscribeGroup(ctx, "*", "ingest", "survivor", opts)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer survivor.Close()
+	claimed := readGroup(t, survivor, 3, true)
+	if !slices.Equal(streamIDs(claimed), streamIDs(delivered)) || !claimed[0].Redelivered {
+		t.Fatalf("expected the crashed consumer's events to be claimed, got %+v", claimed)
+	}
+
+	lags, err = subscriber.GroupLags(ctx, "*", "ingest")
+	if err != nil {
+		t.Fatalf("lags: %v", err)
+	}
+	if lags[0].Pending != 0 || lags[0].Consumers != 2 {
+		t.Fatalf("expected no pending events after claim, got %+v", lags[0])
+	}
+}
+
+func TestSubscribeGroupReservesSubscription(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+	t.Cleanup(func() { goleak.VerifyNone(t, ignore) })
+
+	_, client := newTestRedis(t)
+	streamOpts := DefaultOptions()
+	streamOpts.MaxSubscriptions = 1
+	streamOpts.Clock = clock.NewFake(time.UnixMilli(1_700_000_000_000))
+	subscriber := NewSubscriber(client, zap.NewNop(), streamOpts)
+	ctx := context.Background()
+
+	sub, err := subscriber.SubscribeGroup(ctx, "*", "ingest", "first")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if got := subscriber.ReservedSubscriptions(); got != 1 {
+		t.Fatalf("expected one reserved subscription, got %d", got)
+	}
+	if _, err := subscriber.SubscribeGroup(ctx, "*", "ingest", "second"); !errors.Is(err, ErrSubscriptionCapacity) {
+		t.Fatalf("expected ErrSubscriptionCapacity, got %v", err)
+	}
+
+	sub.Close()
+	if got := subscriber.ReservedSubscriptions(); got != 0 {
+		t.Fatalf("expected the reservation released on close, got %d", got)
+	}
+	if got := subscriber.ActiveSubscriptions(); got != 0 {
+		t.Fatalf("expected no active subscriptions, got %d", got)
+	}
+}
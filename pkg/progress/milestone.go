@@ -0,0 +1,97 @@
+package progress
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// MilestonesMetadataKey 完成事件 metadata 中存放阶段时间线的 key
+const MilestonesMetadataKey = "milestones"
+
+// Milestone 任务的一个执行阶段
+type Milestone struct {
+	Stage       string `json:"stage"`
+	StartedAtMs int64  `json:"started_at_ms"`
+	DurationMs  int64  `json:"duration_ms"`
+}
+
+// MilestoneTracker 记录进度中每个阶段首次出现的时间，生成阶段时间线
+type MilestoneTracker struct {
+	mu         sync.Mutex
+	milestones []Milestone
+	seen       map[string]struct{}
+}
+
+// NewMilestoneTracker 创建阶段记录器
+func NewMilestoneTracker() *MilestoneTracker {
+	return &MilestoneTracker{
+		seen: make(map[string]struct{}),
+	}
+}
+
+// Observe 记录阶段，仅在首次出现时生效，返回是否为新阶段
+func (t *MilestoneTracker) Observe(stage string, timestampMs int64) bool {
+	if stage == "" {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.seen[stage]; ok {
+		return false
+	}
+	t.seen[stage] = struct{}{}
+	t.milestones = append(t.milestones, Milestone{
+		Stage:       stage,
+		StartedAtMs: timestampMs,
+	})
+	return true
+}
+
+// Finish 计算各阶段耗时：每个阶段持续到下一个阶段开始，最后一个阶段持续到 endMs
+func (t *MilestoneTracker) Finish(endMs int64) []Milestone {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]Milestone, len(t.milestones))
+	copy(result, t.milestones)
+	for i := range result {
+		end := endMs
+		if i+1 < len(result) {
+			end = result[i+1].StartedAtMs
+		}
+		if end > result[i].StartedAtMs {
+			result[i].DurationMs = end - result[i].StartedAtMs
+		}
+	}
+	return result
+}
+
+// Metadata 将阶段时间线编码为完成事件的 metadata，没有阶段时返回 nil
+func (t *MilestoneTracker) Metadata(endMs int64) map[string]string {
+	milestones := t.Finish(endMs)
+	if len(milestones) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(milestones)
+	if err != nil {
+		return nil
+	}
+	return map[string]string{MilestonesMetadataKey: string(data)}
+}
+
+// DecodeMilestones 从进度 metadata 中解析阶段时间线
+func DecodeMilestones(metadata map[string]string) ([]Milestone, error) {
+	raw, ok := metadata[MilestonesMetadataKey]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+
+	var milestones []Milestone
+	if err := json.Unmarshal([]byte(raw), &milestones); err != nil {
+		return nil, err
+	}
+	return milestones, nil
+}
@@ -0,0 +1,44 @@
+package progress
+
+import "testing"
+
+func TestMilestoneTrackerRecordsFirstSeenStages(t *testing.T) {
+	tracker := NewMilestoneTracker()
+
+	tracker.Observe("download", 1000)
+	tracker.Observe("download", 2000)
+	tracker.Observe("", 2500)
+	tracker.Observe("process", 3000)
+	tracker.Observe("upload", 33000)
+
+	got := tracker.Finish(38000)
+	want := []Milestone{
+		{Stage: "download", StartedAtMs: 1000, DurationMs: 2000},
+		{Stage: "process", StartedAtMs: 3000, DurationMs: 30000},
+		{Stage: "upload", StartedAtMs: 33000, DurationMs: 5000},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d milestones, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("milestone %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestMilestoneMetadataRoundTrip(t *testing.T) {
+	tracker := NewMilestoneTracker()
+	if tracker.Metadata(0) != nil {
+		t.Fatal("expected nil metadata without stages")
+	}
+
+	tracker.Observe("process", 100)
+	decoded, err := DecodeMilestones(tracker.Metadata(400))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0].Stage != "process" || decoded[0].DurationMs != 300 {
+		t.Fatalf("unexpected milestones: %+v", decoded)
+	}
+}
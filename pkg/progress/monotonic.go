@@ -0,0 +1,76 @@
+package progress
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// MonotonicMode 进度百分比回退时的处理方式
+type MonotonicMode string
+
+const (
+	// MonotonicOff 不检查，按原样发布
+	MonotonicOff MonotonicMode = ""
+	// MonotonicDrop 丢弃百分比低于已发布值的进度
+	MonotonicDrop MonotonicMode = "drop"
+	// MonotonicClamp 将回退的百分比提升为已发布的最大值
+	MonotonicClamp MonotonicMode = "clamp"
+)
+
+// monotonic 每个任务已发布的最大百分比，完成事件发布后清除
+type monotonic struct {
+	mu   sync.Mutex
+	last map[string]int32
+}
+
+// enforceMonotonic 按配置处理回退的百分比，返回 nil 表示丢弃该进度
+func (p *Publisher) enforceMonotonic(prog *Progress) *Progress {
+	if p.options.Monotonic == MonotonicOff {
+		return prog
+	}
+
+	p.monotonic.mu.Lock()
+	last, ok := p.monotonic.last[prog.TaskID]
+	p.monotonic.mu.Unlock()
+	if !ok || prog.Percentage >= last {
+		return prog
+	}
+
+	p.logger.Warn("progress percentage went backwards",
+		zap.String("task_id", prog.TaskID),
+		zap.Int32("percentage", prog.Percentage),
+		zap.Int32("last_percentage", last),
+		zap.String("mode", string(p.options.Monotonic)),
+	)
+	if p.options.Monotonic == MonotonicDrop {
+		return nil
+	}
+
+	clamped := *prog
+	clamped.Percentage = last
+	return &clamped
+}
+
+// recordPercentage 记录已成功发布的百分比
+func (p *Publisher) recordPercentage(taskID string, percentage int32) {
+	if p.options.Monotonic == MonotonicOff {
+		return
+	}
+
+	p.monotonic.mu.Lock()
+	defer p.monotonic.mu.Unlock()
+	if p.monotonic.last == nil {
+		p.monotonic.last = make(map[string]int32)
+	}
+	if last, ok := p.monotonic.last[taskID]; !ok || percentage > last {
+		p.monotonic.last[taskID] = percentage
+	}
+}
+
+// forgetPercentage 任务结束后清除记录，重试时进度可从头开始
+func (p *Publisher) forgetPercentage(taskID string) {
+	p.monotonic.mu.Lock()
+	defer p.monotonic.mu.Unlock()
+	delete(p.monotonic.last, taskID)
+}
@@ -0,0 +1,49 @@
+package progress
+
+import (
+	"context"
+	"time"
+)
+
+// EventPublisher 处理器和中间件发布进度所需的方法，*Publisher 和 NopPublisher 都实现了该接口。
+// 进度功能关闭时 worker 使用 NopPublisher，处理器无需判断发布器是否为空
+type EventPublisher interface {
+	Publish(ctx context.Context, prog *Progress) error
+	PublishCompletion(ctx context.Context, taskID, status, message string) error
+	PublishCompletionWithResult(ctx context.Context, taskID, status, message string, metadata map[string]string, result any) error
+	PublishCompletionIfMissing(ctx context.Context, taskID string, since time.Time, status, message string, metadata map[string]string) (bool, error)
+	PublishAttemptStarted(ctx context.Context, taskID string) error
+}
+
+var (
+	_ EventPublisher = (*Publisher)(nil)
+	_ EventPublisher = NopPublisher{}
+)
+
+// NopPublisher 丢弃所有事件的发布器，用于关闭进度功能（progress.enabled: false）
+type NopPublisher struct{}
+
+// Publish 丢弃进度
+func (NopPublisher) Publish(context.Context, *Progress) error {
+	return nil
+}
+
+// PublishCompletion 丢弃完成事件
+func (NopPublisher) PublishCompletion(context.Context, string, string, string) error {
+	return nil
+}
+
+// PublishCompletionWithResult 丢弃完成事件
+func (NopPublisher) PublishCompletionWithResult(context.Context, string, string, string, map[string]string, any) error {
+	return nil
+}
+
+// PublishCompletionIfMissing 不发布，返回 false 表示没有补发完成事件
+func (NopPublisher) PublishCompletionIfMissing(context.Context, string, time.Time, string, string, map[string]string) (bool, error) {
+	return false, nil
+}
+
+// PublishAttemptStarted 丢弃 attempt_started 事件
+func (NopPublisher) PublishAttemptStarted(context.Context, string) error {
+	return nil
+}
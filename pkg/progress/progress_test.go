@@ -0,0 +1,719 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestPublishCompletionUsesClock(t *testing.T) {
+	_, client := newTestRedis(t)
+	fake := clock.NewFake(time.UnixMilli(1_700_000_000_000))
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{MaxLen: 10, TTL: time.Hour, Clock: fake})
+	subscriber := NewSubscriber(client, zap.NewNop())
+
+	ctx := context.Background()
+	if err := publisher.PublishCompletion(ctx, "task-1", "completed", "done"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	latest, err := subscriber.GetLatest(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !latest.IsFinal || latest.Status != "completed" {
+		t.Fatalf("expected final completed event, got %+v", latest)
+	}
+	if latest.Progress.TimestampMs != 1_700_000_000_000 {
+		t.Fatalf("expected fake clock timestamp, got %d", latest.Progress.TimestampMs)
+	}
+}
+
+func TestPublishSetsStreamTTL(t *testing.T) {
+	mr, client := newTestRedis(t)
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{MaxLen: 10, TTL: time.Minute})
+
+	if err := publisher.Publish(context.Background(), NewProgress("task-1", 10, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ttl := mr.TTL(StreamKey("task-1")); ttl != time.Minute {
+		t.Fatalf("expected 1m ttl, got %v", ttl)
+	}
+
+	mr.FastForward(time.Minute)
+	if mr.Exists(StreamKey("task-1")) {
+		t.Fatal("expected stream to expire")
+	}
+}
+
+func TestPublishCompletionShortensStreamTTL(t *testing.T) {
+	mr, client := newTestRedis(t)
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{MaxLen: 10, TTL: time.Hour, CompletedTTL: 5 * time.Minute})
+	ctx := context.Background()
+	key := StreamKey("task-1")
+
+	if err := publisher.Publish(ctx, NewProgress("task-1", 10, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := publisher.PublishCompletion(ctx, "task-1", "failed", "boom"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ttl := mr.TTL(key); ttl != 5*time.Minute {
+		t.Fatalf("expected completed ttl of 5m, got %v", ttl)
+	}
+
+	// 任务重试后恢复进行中的 TTL
+	if err := publisher.PublishAttemptStarted(WithAttempt(ctx, 2, 3), "task-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ttl := mr.TTL(key); ttl != time.Hour {
+		t.Fatalf("expected ttl restored to 1h, got %v", ttl)
+	}
+}
+
+func TestCompletedTTLNotLongerThanTTL(t *testing.T) {
+	mr, client := newTestRedis(t)
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{MaxLen: 10, TTL: time.Minute, CompletedTTL: time.Hour})
+	ctx := context.Background()
+
+	if err := publisher.Publish(ctx, NewProgress("task-1", 10, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := publisher.PublishCompletion(ctx, "task-1", "completed", "done"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ttl := mr.TTL(StreamKey("task-1")); ttl != time.Minute {
+		t.Fatalf("expected ttl to stay at 1m, got %v", ttl)
+	}
+}
+
+func TestPublishUsesTaskTypeOverrides(t *testing.T) {
+	mr, client := newTestRedis(t)
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{
+		MaxLen:       10,
+		TTL:          time.Hour,
+		CompletedTTL: 5 * time.Minute,
+		TypeOverrides: map[string]StreamOverride{
+			"grpc_task": {TTL: 6 * time.Hour},
+			"demo":      {CompletedTTL: time.Minute},
+		},
+	})
+	ctx := context.Background()
+
+	// 任务类型来自 context
+	grpcCtx := WithTaskType(ctx, "grpc_task")
+	if err := publisher.Publish(grpcCtx, NewProgress("task-1", 10, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ttl := mr.TTL(StreamKey("task-1")); ttl != 6*time.Hour {
+		t.Fatalf("expected grpc_task ttl of 6h, got %v", ttl)
+	}
+	if err := publisher.PublishCompletion(grpcCtx, "task-1", "completed", "done"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ttl := mr.TTL(StreamKey("task-1")); ttl != 5*time.Minute {
+		t.Fatalf("expected global completed ttl of 5m, got %v", ttl)
+	}
+
+	// 任务类型来自 metadata，未覆盖的项沿用全局值
+	if err := publisher.PublishCompletionWithMetadata(ctx, "task-2", "completed", "done", map[string]string{TaskTypeMetadataKey: "demo"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ttl := mr.TTL(StreamKey("task-2")); ttl != time.Minute {
+		t.Fatalf("expected demo completed ttl of 1m, got %v", ttl)
+	}
+
+	// 未配置的类型使用全局值
+	if err := publisher.Publish(WithTaskType(ctx, "other"), NewProgress("task-3", 10, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ttl := mr.TTL(StreamKey("task-3")); ttl != time.Hour {
+		t.Fatalf("expected global ttl of 1h, got %v", ttl)
+	}
+}
+
+// checkNotifier 记录每次存在性检查，任务始终存在
+type checkNotifier chan struct{}
+
+func (c checkNotifier) TaskExists(context.Context, string) (bool, error) {
+	c <- struct{}{}
+	return true, nil
+}
+
+// 订阅的等待由 XREAD BLOCK 在服务端完成，使用毫秒级 ReadTimeout 验证超时后继续等待
+func TestSubscribeContinuesAfterReadTimeout(t *testing.T) {
+	_, client := newTestRedis(t)
+	fake := clock.NewFake(time.UnixMilli(1_700_000_000_000))
+	checks := make(checkNotifier, 1)
+	opts := StreamOptions{
+		MaxLen:           10,
+		TTL:              time.Hour,
+		ReadTimeout:      10 * time.Millisecond,
+		Clock:            fake,
+		TaskChecker:      checks,
+		WatchdogInterval: time.Minute,
+	}
+	publisher := NewPublisher(client, zap.NewNop(), opts)
+	subscriber := NewSubscriber(client, zap.NewNop(), opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ch := subscriber.Subscribe(ctx, "task-1", "0")
+
+	// 订阅开始时检查一次；推进时钟后，只有读取超时返回才会再次检查
+	waitCheck := func() {
+		t.Helper()
+		select {
+		case <-checks:
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for the subscriber to check the task")
+		}
+	}
+	waitCheck()
+	fake.Advance(opts.WatchdogInterval)
+	waitCheck()
+
+	if err := publisher.PublishCompletion(ctx, "task-1", "completed", "done"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result, ok := <-ch
+	if !ok {
+		t.Fatal("subscription closed before final event")
+	}
+	if result.Error != nil || !result.IsFinal {
+		t.Fatalf("expected final event, got %+v", result)
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("expected subscription to close after final event")
+	}
+}
+
+type fakeTaskChecker struct {
+	exists atomic.Bool
+}
+
+func (f *fakeTaskChecker) TaskExists(context.Context, string) (bool, error) {
+	return f.exists.Load(), nil
+}
+
+func TestSubscribeEndsWhenTaskDeleted(t *testing.T) {
+	_, client := newTestRedis(t)
+	checker := &fakeTaskChecker{}
+	checker.exists.Store(true)
+	subscriber := NewSubscriber(client, zap.NewNop(), StreamOptions{
+		ReadTimeout:    10 * time.Millisecond,
+		MaxReadTimeout: 20 * time.Millisecond,
+		TaskChecker:    checker,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ch := subscriber.Subscribe(ctx, "task-1", "0")
+	checker.exists.Store(false)
+
+	result, ok := <-ch
+	if !ok || !errors.Is(result.Error, ErrTaskGone) {
+		t.Fatalf("expected ErrTaskGone, got %+v (open=%v)", result, ok)
+	}
+	if _, ok := <-ch; ok {
+		t.Fatalf("expected subscription to be closed")
+	}
+}
+
+func TestSubscribeEndsWhenNothingKnownAfterGrace(t *testing.T) {
+	_, client := newTestRedis(t)
+	subscriber := NewSubscriber(client, zap.NewNop(), StreamOptions{
+		ReadTimeout:      time.Second,
+		TaskChecker:      &fakeTaskChecker{},
+		WatchdogInterval: time.Second,
+		NotFoundGrace:    50 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	result := <-subscriber.Subscribe(ctx, "typo")
+	if !errors.Is(result.Error, ErrTaskOrProgressNotFound) || result.Code != CodeTaskOrProgressNotFound {
+		t.Fatalf("expected not found result, got %+v", result)
+	}
+
+	// 宽限期内出现的进度流让订阅继续
+	if _, err := client.XAdd(ctx, &redis.XAddArgs{Stream: StreamKey("late"), Values: map[string]any{"data": "{}"}}).Result(); err != nil {
+		t.Fatalf("xadd: %v", err)
+	}
+	ch := NewSubscriber(client, zap.NewNop(), StreamOptions{
+		ReadTimeout:   20 * time.Millisecond,
+		NotFoundGrace: 50 * time.Millisecond,
+	}).Subscribe(ctx, "late")
+	select {
+	case result := <-ch:
+		t.Fatalf("expected subscription to keep waiting, got %+v", result)
+	case <-time.After(150 * time.Millisecond):
+	}
+}
+
+func TestNextBlockTimeoutDoublesUpToMax(t *testing.T) {
+	subscriber := NewSubscriber(nil, zap.NewNop(), StreamOptions{MaxReadTimeout: 50 * time.Second})
+
+	if got := subscriber.nextBlockTimeout(10 * time.Second); got != 20*time.Second {
+		t.Fatalf("expected 20s, got %v", got)
+	}
+	if got := subscriber.nextBlockTimeout(40 * time.Second); got != 50*time.Second {
+		t.Fatalf("expected 50s, got %v", got)
+	}
+
+	fixed := NewSubscriber(nil, zap.NewNop(), StreamOptions{})
+	if got := fixed.nextBlockTimeout(30 * time.Second); got != 30*time.Second {
+		t.Fatalf("expected timeout to stay at 30s without max, got %v", got)
+	}
+}
+
+func TestMetadataJSONRoundTrip(t *testing.T) {
+	_, client := newTestRedis(t)
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{MaxLen: 10})
+	subscriber := NewSubscriber(client, zap.NewNop())
+	ctx := context.Background()
+
+	prog := NewProgress("task-1", 40, "shards", "processing")
+	prog.Metadata = map[string]string{"host": "a"}
+	prog.MetadataJSON = []byte(`{"shards":{"done":4,"total":10}}`)
+	if err := publisher.Publish(ctx, prog); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	latest, err := subscriber.GetLatest(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("get latest: %v", err)
+	}
+	if string(latest.Progress.MetadataJSON) != `{"shards":{"done":4,"total":10}}` || latest.Progress.Metadata["host"] != "a" {
+		t.Fatalf("expected both metadata forms, got %+v", latest)
+	}
+
+	prog.MetadataJSON = []byte(`[` + strings.Repeat(`1,`, MaxMetadataJSONSize) + `1]`)
+	if err := publisher.Publish(ctx, prog); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	latest, _ = subscriber.GetLatest(ctx, "task-1")
+	if latest.Progress.MetadataJSON != nil {
+		t.Fatalf("expected oversized metadata_json to be dropped")
+	}
+}
+
+func TestPublishMonotonicModes(t *testing.T) {
+	for _, tc := range []struct {
+		mode MonotonicMode
+		want []int32
+	}{
+		{MonotonicOff, []int32{10, 50, 30, 60}},
+		{MonotonicDrop, []int32{10, 50, 60}},
+		{MonotonicClamp, []int32{10, 50, 50, 60}},
+	} {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			_, client := newTestRedis(t)
+			publisher := NewPublisher(client, zap.NewNop(), StreamOptions{MaxLen: 10, Monotonic: tc.mode})
+			subscriber := NewSubscriber(client, zap.NewNop())
+			ctx := context.Background()
+
+			for _, pct := range []int32{10, 50, 30, 60} {
+				if err := publisher.Publish(ctx, NewProgress("task-1", pct, "running", "")); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			history, err := subscriber.GetHistory(ctx, "task-1", "", 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []int32
+			for _, event := range history {
+				got = append(got, event.Progress.Percentage)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPublishMonotonicResetsAfterCompletion(t *testing.T) {
+	_, client := newTestRedis(t)
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{MaxLen: 10, Monotonic: MonotonicDrop})
+	subscriber := NewSubscriber(client, zap.NewNop())
+	ctx := context.Background()
+
+	if err := publisher.Publish(ctx, NewProgress("task-1", 80, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := publisher.PublishCompletion(ctx, "task-1", "failed", "retrying"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 重试从头开始上报进度
+	if err := publisher.Publish(ctx, NewProgress("task-1", 5, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	latest, err := subscriber.GetLatest(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if latest.Progress.Percentage != 5 {
+		t.Fatalf("expected retry progress to be published, got %+v", latest)
+	}
+}
+
+func TestPublishSanitizesMetadataThatFailsToEncode(t *testing.T) {
+	marshalJSON = func(v any) ([]byte, error) {
+		if m, ok := v.(map[string]string); ok && m["broken"] != "" {
+			panic("boom")
+		}
+		return json.Marshal(v)
+	}
+	t.Cleanup(func() { marshalJSON = json.Marshal })
+
+	_, client := newTestRedis(t)
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{MaxLen: 10})
+	subscriber := NewSubscriber(client, zap.NewNop())
+	ctx := context.Background()
+
+	prog := NewProgress("task-1", 10, "running", "")
+	prog.Metadata = map[string]string{"shard": "3", "broken": "x"}
+	if err := publisher.Publish(ctx, prog); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	latest, err := subscriber.GetLatest(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if latest.Progress.Metadata["shard"] != "3" || latest.Progress.Metadata["broken"] != "" {
+		t.Fatalf("expected sanitized metadata without the broken key, got %v", latest.Progress.Metadata)
+	}
+	if got := publisher.MetadataMarshalFailures(); got != 1 {
+		t.Fatalf("expected 1 marshal failure, got %d", got)
+	}
+}
+
+func TestParseMessageWarnsOnUndecodableMetadata(t *testing.T) {
+	_, client := newTestRedis(t)
+	subscriber := NewSubscriber(client, zap.NewNop())
+	ctx := context.Background()
+
+	err := client.XAdd(ctx, &redis.XAddArgs{
+		Stream: StreamKey("task-1"),
+		Values: map[string]interface{}{"task_id": "task-1", "percentage": 10, "metadata": "{not json"},
+	}).Err()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	latest, err := subscriber.GetLatest(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(latest.Warnings) != 1 || !strings.Contains(latest.Warnings[0], "metadata") {
+		t.Fatalf("expected a metadata warning, got %v", latest.Warnings)
+	}
+	if latest.Progress.Percentage != 10 {
+		t.Fatalf("expected the rest of the message to be parsed, got %+v", latest.Progress)
+	}
+}
+
+func TestPublishCompletionIfMissingSkipsHandlerCompletion(t *testing.T) {
+	_, client := newTestRedis(t)
+	fake := clock.NewFake(time.UnixMilli(1_700_000_000_000))
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{MaxLen: 10, Clock: fake})
+	ctx := context.Background()
+
+	// 上一次执行的完成事件早于本次开始，不算本次已完成
+	if err := publisher.PublishCompletion(ctx, "task-1", "failed", "attempt 1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fake.Advance(time.Second)
+	start := fake.Now()
+
+	published, err := publisher.PublishCompletionIfMissing(ctx, "task-1", start, "completed", "done", nil)
+	if err != nil || !published {
+		t.Fatalf("expected completion to be published, got %v (%v)", published, err)
+	}
+	published, err = publisher.PublishCompletionIfMissing(ctx, "task-1", start, "completed", "done", nil)
+	if err != nil || published {
+		t.Fatalf("expected existing completion to be kept, got %v (%v)", published, err)
+	}
+}
+
+func TestPublishCompletionResultRoundTrip(t *testing.T) {
+	_, client := newTestRedis(t)
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{MaxLen: 10})
+	subscriber := NewSubscriber(client, zap.NewNop())
+	ctx := context.Background()
+
+	result := map[string]any{"rows": 42, "files": []string{"a.csv"}}
+	if err := publisher.PublishCompletionResult(ctx, "task-1", "completed", result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	latest, err := subscriber.GetLatest(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !latest.IsFinal || string(latest.Result) != `{"files":["a.csv"],"rows":42}` {
+		t.Fatalf("expected final event with result, got %+v (%s)", latest, latest.Result)
+	}
+
+	// 超过上限的结果被丢弃，完成事件照常发布
+	large := strings.Repeat("x", MaxResultSize)
+	if err := publisher.PublishCompletionResult(ctx, "task-2", "completed", large); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	latest, err = subscriber.GetLatest(ctx, "task-2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !latest.IsFinal || latest.Result != nil {
+		t.Fatalf("expected final event without result, got %+v", latest)
+	}
+}
+
+type recordingObserver struct {
+	ops []string
+}
+
+func (r *recordingObserver) ObserveRedisOperation(op string, _ time.Duration) {
+	r.ops = append(r.ops, op)
+}
+
+func TestOpTimerWarnsOnSlowOperations(t *testing.T) {
+	fake := clock.NewFake(time.UnixMilli(1_700_000_000_000))
+	core, logs := observer.New(zap.WarnLevel)
+	rec := &recordingObserver{}
+	timer := newOpTimer(StreamOptions{SlowOperationThreshold: 200 * time.Millisecond, RedisObserver: rec}, zap.New(core), fake)
+
+	start := fake.Now()
+	fake.Advance(50 * time.Millisecond)
+	timer.done(OpXAdd, "task-1", start)
+	if logs.Len() != 0 {
+		t.Fatalf("expected no warning below threshold, got %d", logs.Len())
+	}
+
+	start = fake.Now()
+	fake.Advance(300 * time.Millisecond)
+	timer.done(OpXRange, "task-1", start)
+	if logs.Len() != 1 || logs.All()[0].ContextMap()["op"] != OpXRange {
+		t.Fatalf("expected one xrange warning, got %v", logs.All())
+	}
+
+	// 阻塞读取只统计超出 block 的部分
+	start = fake.Now()
+	fake.Advance(time.Second + 100*time.Millisecond)
+	timer.doneBlocking(OpXRead, "task-1", start, time.Second)
+	if logs.Len() != 1 {
+		t.Fatalf("expected blocking read within threshold not to warn, got %d", logs.Len())
+	}
+	start = fake.Now()
+	fake.Advance(time.Second + 500*time.Millisecond)
+	timer.doneBlocking(OpXRead, "task-1", start, time.Second)
+	if logs.Len() != 2 {
+		t.Fatalf("expected slow blocking read to warn, got %d", logs.Len())
+	}
+
+	if !slices.Equal(rec.ops, []string{OpXAdd, OpXRange}) {
+		t.Fatalf("expected only non-blocking ops to be observed, got %v", rec.ops)
+	}
+}
+
+func TestAttemptIsCarriedByProgressAndCompletion(t *testing.T) {
+	_, client := newTestRedis(t)
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{MaxLen: 10, Monotonic: MonotonicDrop})
+	subscriber := NewSubscriber(client, zap.NewNop())
+
+	first := WithAttempt(context.Background(), 1, 3)
+	if err := publisher.Publish(first, NewProgress("task-1", 80, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 第二次执行：先发布 attempt_started，进度从头开始也不会被单调性检查丢弃
+	second := WithAttempt(context.Background(), 2, 3)
+	if err := publisher.PublishAttemptStarted(second, "task-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := publisher.Publish(second, NewProgress("task-1", 5, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := publisher.PublishCompletion(second, "task-1", "completed", "done"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	history, err := subscriber.GetHistory(context.Background(), "task-1", "", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	type entry struct {
+		event            string
+		pct              int32
+		attempt, maxAttr int32
+		final            bool
+	}
+	var got []entry
+	for _, r := range history {
+		got = append(got, entry{r.Event, r.Progress.Percentage, r.Progress.Attempt, r.Progress.MaxAttempts, r.IsFinal})
+	}
+	want := []entry{
+		{"", 80, 1, 3, false},
+		{EventAttemptStarted, 0, 2, 3, false},
+		{"", 5, 2, 3, false},
+		{"", 100, 2, 3, true},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if history[1].Progress.Message != "attempt 2 of 3 started" {
+		t.Fatalf("unexpected attempt message %q", history[1].Progress.Message)
+	}
+}
+
+func TestProgressAttemptOverridesContext(t *testing.T) {
+	_, client := newTestRedis(t)
+	publisher := NewPublisher(client, zap.NewNop())
+	subscriber := NewSubscriber(client, zap.NewNop())
+
+	prog := NewProgress("task-1", 10, "running", "")
+	prog.Attempt, prog.MaxAttempts = 4, 5
+	if err := publisher.Publish(WithAttempt(context.Background(), 1, 5), prog); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := publisher.Publish(context.Background(), NewProgress("task-1", 20, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	history, err := subscriber.GetHistory(context.Background(), "task-1", "", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if history[0].Progress.Attempt != 4 || history[1].Progress.Attempt != 0 {
+		t.Fatalf("expected explicit attempt 4 then none, got %d and %d", history[0].Progress.Attempt, history[1].Progress.Attempt)
+	}
+}
+
+func TestReserveSubscriptions(t *testing.T) {
+	_, client := newTestRedis(t)
+	subscriber := NewSubscriber(client, zap.NewNop(), StreamOptions{MaxSubscriptions: 3})
+
+	release, err := subscriber.Reserve(2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 多任务订阅要么全部预留要么全部失败，失败时不占用容量
+	if _, err := subscriber.Reserve(2); !errors.Is(err, ErrSubscriptionCapacity) {
+		t.Fatalf("expected ErrSubscriptionCapacity, got %v", err)
+	}
+	if got := subscriber.ReservedSubscriptions(); got != 2 {
+		t.Fatalf("expected 2 reserved after rejection, got %d", got)
+	}
+	if subscriber.SubscriptionRejections() != 1 {
+		t.Fatalf("expected one rejection")
+	}
+
+	release()
+	release()
+	if got := subscriber.ReservedSubscriptions(); got != 0 {
+		t.Fatalf("expected release to be idempotent, got %d reserved", got)
+	}
+	if _, err := subscriber.Reserve(3); err != nil {
+		t.Fatalf("expected capacity after release, got %v", err)
+	}
+
+	unlimited := NewSubscriber(client, zap.NewNop())
+	if _, err := unlimited.Reserve(1000); err != nil {
+		t.Fatalf("expected no limit without MaxSubscriptions, got %v", err)
+	}
+}
+
+func TestWorkerIsCarriedByWorkerEvents(t *testing.T) {
+	_, client := newTestRedis(t)
+	publisher := NewPublisher(client, zap.NewNop())
+	subscriber := NewSubscriber(client, zap.NewNop())
+
+	// API 侧发布的事件没有 worker
+	if err := publisher.Publish(context.Background(), NewProgress("task-1", 0, "queued", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	worker := Worker{ID: "host-1-abcd1234", Version: "v1.2.3"}
+	ctx := WithAttempt(WithWorker(context.Background(), worker), 2, 3)
+	if err := publisher.PublishAttemptStarted(ctx, "task-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := publisher.Publish(ctx, NewProgress("task-1", 50, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := publisher.PublishCompletion(ctx, "task-1", "completed", "done"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	history, err := subscriber.GetHistory(context.Background(), "task-1", "", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(history) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(history))
+	}
+	if history[0].Progress.Worker != nil {
+		t.Fatalf("expected no worker on the API event, got %+v", history[0].Progress.Worker)
+	}
+	for _, r := range history[1:] {
+		if r.Progress.Worker == nil || *r.Progress.Worker != worker {
+			t.Fatalf("expected worker %+v on %q, got %+v", worker, r.Progress.Stage, r.Progress.Worker)
+		}
+	}
+}
+
+func TestLatestWorkerSkipsAPIEvents(t *testing.T) {
+	_, client := newTestRedis(t)
+	publisher := NewPublisher(client, zap.NewNop())
+	subscriber := NewSubscriber(client, zap.NewNop())
+
+	w, err := subscriber.LatestWorker(context.Background(), "task-1")
+	if err != nil || w != nil {
+		t.Fatalf("expected no worker for an empty stream, got %+v, %v", w, err)
+	}
+
+	worker := Worker{ID: "host-1-abcd1234", Version: "v1.2.3"}
+	ctx := WithWorker(context.Background(), worker)
+	if err := publisher.Publish(ctx, NewProgress("task-1", 50, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// API 侧发布的取消事件没有 worker
+	if err := publisher.PublishCompletion(context.Background(), "task-1", "cancelled", "task cancelled"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w, err = subscriber.LatestWorker(context.Background(), "task-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w == nil || *w != worker {
+		t.Fatalf("expected worker %+v, got %+v", worker, w)
+	}
+}
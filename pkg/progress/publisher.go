@@ -4,10 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
 )
 
 // Publisher 进度发布器
@@ -15,6 +19,15 @@ type Publisher struct {
 	redis   *redis.Client
 	logger  *zap.Logger
 	options StreamOptions
+	clock   clock.Clock
+
+	fallback  fallback
+	monotonic monotonic
+	watchers  watchers
+
+	metadataFailures   atomic.Uint64
+	completionsDropped atomic.Uint64
+	timer              opTimer
 }
 
 // NewPublisher 创建进度发布器
@@ -24,10 +37,13 @@ func NewPublisher(redisClient *redis.Client, logger *zap.Logger, opts ...StreamO
 		opt = opts[0]
 	}
 
+	clk := clock.OrReal(opt.Clock)
 	return &Publisher{
 		redis:   redisClient,
 		logger:  logger,
 		options: opt,
+		clock:   clk,
+		timer:   newOpTimer(opt, logger, clk),
 	}
 }
 
@@ -36,6 +52,12 @@ func (p *Publisher) Publish(ctx context.Context, prog *Progress) error {
 	if prog == nil {
 		return fmt.Errorf("progress cannot be nil")
 	}
+	if prog = p.enforceMonotonic(prog); prog == nil {
+		return nil
+	}
+	if !p.shouldPublish(ctx, prog.TaskID) {
+		return nil
+	}
 
 	key := StreamKey(prog.TaskID)
 
@@ -48,11 +70,23 @@ func (p *Publisher) Publish(ctx context.Context, prog *Progress) error {
 		"timestamp_ms": prog.TimestampMs,
 	}
 
+	setAttempt(ctx, values, prog.Attempt, prog.MaxAttempts)
+	setWorker(ctx, values)
+
 	// 添加 metadata（如果有）
 	if len(prog.Metadata) > 0 {
-		metaJSON, err := json.Marshal(prog.Metadata)
-		if err == nil {
-			values["metadata"] = string(metaJSON)
+		if metaJSON, ok := p.marshalMetadata(prog.TaskID, prog.Metadata); ok {
+			values["metadata"] = metaJSON
+		}
+	}
+	if len(prog.MetadataJSON) > 0 {
+		if validMetadataJSON(prog.MetadataJSON) {
+			values["metadata_json"] = string(prog.MetadataJSON)
+		} else {
+			p.logger.Warn("dropping invalid or oversized metadata_json",
+				zap.String("task_id", prog.TaskID),
+				zap.Int("size", len(prog.MetadataJSON)),
+			)
 		}
 	}
 
@@ -63,12 +97,16 @@ func (p *Publisher) Publish(ctx context.Context, prog *Progress) error {
 	}
 
 	// 限制 Stream 长度
-	if p.options.MaxLen > 0 {
-		args.MaxLen = p.options.MaxLen
+	r := p.retention(ctx, prog.Metadata)
+	if r.maxLen > 0 {
+		args.MaxLen = r.maxLen
 		args.Approx = true // 使用 ~ 近似限制，性能更好
 	}
 
+	start := p.clock.Now()
 	result, err := p.redis.XAdd(ctx, args).Result()
+	p.timer.done(OpXAdd, prog.TaskID, start)
+	p.recordResult(err)
 	if err != nil {
 		p.logger.Error("failed to publish progress",
 			zap.String("task_id", prog.TaskID),
@@ -77,8 +115,10 @@ func (p *Publisher) Publish(ctx context.Context, prog *Progress) error {
 		return fmt.Errorf("failed to publish progress: %w", err)
 	}
 
+	p.recordPercentage(prog.TaskID, prog.Percentage)
+
 	// 设置 TTL（如果是第一条消息）
-	p.ensureTTL(ctx, key)
+	p.ensureTTL(ctx, key, r)
 
 	p.logger.Debug("progress published",
 		zap.String("task_id", prog.TaskID),
@@ -91,7 +131,25 @@ func (p *Publisher) Publish(ctx context.Context, prog *Progress) error {
 
 // PublishCompletion 发布任务完成事件
 func (p *Publisher) PublishCompletion(ctx context.Context, taskID, status, message string) error {
+	return p.PublishCompletionWithMetadata(ctx, taskID, status, message, nil)
+}
+
+// PublishCompletionWithMetadata 发布带 metadata 的任务完成事件
+func (p *Publisher) PublishCompletionWithMetadata(ctx context.Context, taskID, status, message string, metadata map[string]string) error {
+	return p.PublishCompletionWithResult(ctx, taskID, status, message, metadata, nil)
+}
+
+// PublishCompletionResult 发布携带任务结果的完成事件，result 编码为 JSON
+func (p *Publisher) PublishCompletionResult(ctx context.Context, taskID, status string, result any) error {
+	return p.PublishCompletionWithResult(ctx, taskID, status, "task "+status, nil, result)
+}
+
+// PublishCompletionWithResult 发布带 metadata 和任务结果的完成事件。
+// result 为 nil 时不携带结果；无法编码或超过 MaxResultSize 时丢弃结果并记录警告，完成事件照常发布
+func (p *Publisher) PublishCompletionWithResult(ctx context.Context, taskID, status, message string, metadata map[string]string, result any) error {
 	key := StreamKey(taskID)
+	p.forgetPercentage(taskID)
+	p.forgetWatchers(taskID)
 
 	// 发布完成消息到同一个 Stream
 	values := map[string]interface{}{
@@ -100,22 +158,56 @@ func (p *Publisher) PublishCompletion(ctx context.Context, taskID, status, messa
 		"stage":        "completed",
 		"message":      message,
 		"status":       status, // completed, failed, cancelled
-		"timestamp_ms": time.Now().UnixMilli(),
+		"timestamp_ms": p.clock.Now().UnixMilli(),
 		"is_final":     "true", // 标记为最终消息
 	}
+	setAttempt(ctx, values, 0, 0)
+	setWorker(ctx, values)
+
+	if len(metadata) > 0 {
+		if metaJSON, ok := p.marshalMetadata(taskID, metadata); ok {
+			values["metadata"] = metaJSON
+		}
+	}
+	if result != nil {
+		if data, ok := p.marshalResult(taskID, result); ok {
+			values["result"] = data
+		}
+	}
 
 	args := &redis.XAddArgs{
 		Stream: key,
 		Values: values,
 	}
 
-	if p.options.MaxLen > 0 {
-		args.MaxLen = p.options.MaxLen
+	r := p.retention(ctx, metadata)
+	if r.maxLen > 0 {
+		args.MaxLen = r.maxLen
 		args.Approx = true
 	}
 
+	start := p.clock.Now()
 	_, err := p.redis.XAdd(ctx, args).Result()
+	p.timer.done(OpXAdd, taskID, start)
+	p.recordResult(err)
 	if err != nil {
+		// 完成事件不能丢：在重试窗口内同步重试，或缓存并后台重试
+		if p.options.CompletionRetryWindow > 0 && p.options.ConfirmCompletion {
+			p.logger.Warn("failed to publish completion, retrying until confirmed",
+				zap.String("task_id", taskID),
+				zap.Error(err),
+			)
+			return p.confirmCompletion(ctx, taskID, args, r)
+		}
+		if p.options.CompletionRetryWindow > 0 {
+			p.logger.Warn("failed to publish completion, buffering for retry",
+				zap.String("task_id", taskID),
+				zap.Error(err),
+			)
+			p.bufferCompletion(taskID, args, r)
+			return nil
+		}
+		p.completionsDropped.Add(1)
 		p.logger.Error("failed to publish completion",
 			zap.String("task_id", taskID),
 			zap.Error(err),
@@ -123,6 +215,8 @@ func (p *Publisher) PublishCompletion(ctx context.Context, taskID, status, messa
 		return fmt.Errorf("failed to publish completion: %w", err)
 	}
 
+	p.expireCompleted(ctx, taskID, r)
+
 	p.logger.Debug("completion published",
 		zap.String("task_id", taskID),
 		zap.String("status", status),
@@ -131,9 +225,130 @@ func (p *Publisher) PublishCompletion(ctx context.Context, taskID, status, messa
 	return nil
 }
 
+// PublishCompletionIfMissing 在 since 之后还没有完成事件时发布完成事件，返回是否发布。
+// 用于为不自行发布完成事件的处理器补发。无法确认是否已发布时照常发布：
+// 订阅方在第一个完成事件处结束，重复的完成事件无害，缺失则会让订阅方一直等到超时
+func (p *Publisher) PublishCompletionIfMissing(ctx context.Context, taskID string, since time.Time, status, message string, metadata map[string]string) (bool, error) {
+	published, err := p.completedSince(ctx, taskID, since)
+	if err != nil {
+		p.logger.Warn("failed to check completion, publishing anyway",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+	}
+	if published {
+		return false, nil
+	}
+	return true, p.PublishCompletionWithMetadata(ctx, taskID, status, message, metadata)
+}
+
+// completedSince 判断 since 之后是否已发布（或已缓存待重试）完成事件
+func (p *Publisher) completedSince(ctx context.Context, taskID string, since time.Time) (bool, error) {
+	if p.hasPendingCompletion(taskID) {
+		return true, nil
+	}
+
+	start := p.clock.Now()
+	messages, err := p.redis.XRevRangeN(ctx, StreamKey(taskID), "+", "-", 1).Result()
+	p.timer.done(OpXRevRange, taskID, start)
+	if err != nil {
+		return false, err
+	}
+	if len(messages) == 0 {
+		return false, nil
+	}
+
+	values := messages[0].Values
+	if final, _ := values["is_final"].(string); final != "true" {
+		return false, nil
+	}
+	ts, _ := values["timestamp_ms"].(string)
+	ms, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false, nil
+	}
+	return ms >= since.UnixMilli(), nil
+}
+
+// marshalJSON 编码 metadata，测试中可替换
+var marshalJSON = json.Marshal
+
+// safeMarshal 编码时的 panic 转为错误
+func safeMarshal(v any) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while encoding: %v", r)
+		}
+	}()
+	return marshalJSON(v)
+}
+
+// marshalMetadata 编码 metadata。整体编码失败时记录失败次数，并逐项编码：
+// 无法编码的值转为 ASCII 转义后的字符串保留，仍失败的项丢弃
+func (p *Publisher) marshalMetadata(taskID string, metadata map[string]string) (string, bool) {
+	data, err := safeMarshal(metadata)
+	if err == nil {
+		return string(data), true
+	}
+	p.metadataFailures.Add(1)
+
+	sanitized := make(map[string]string, len(metadata))
+	var dropped []string
+	for k, v := range metadata {
+		if _, err := safeMarshal(v); err != nil {
+			v = strconv.QuoteToASCII(v)
+		}
+		if _, err := safeMarshal(map[string]string{k: v}); err != nil {
+			dropped = append(dropped, k)
+			continue
+		}
+		sanitized[k] = v
+	}
+	p.logger.Warn("failed to encode progress metadata, publishing sanitized metadata",
+		zap.String("task_id", taskID),
+		zap.Strings("dropped_keys", dropped),
+		zap.Error(err),
+	)
+
+	data, err = safeMarshal(sanitized)
+	if err != nil {
+		p.logger.Warn("dropping progress metadata",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+		return "", false
+	}
+	return string(data), true
+}
+
+// marshalResult 编码完成事件携带的结果，超过 MaxResultSize 时丢弃
+func (p *Publisher) marshalResult(taskID string, result any) (string, bool) {
+	data, err := safeMarshal(result)
+	if err != nil {
+		p.logger.Warn("dropping completion result that failed to encode",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+		return "", false
+	}
+	if len(data) > MaxResultSize || !json.Valid(data) {
+		p.logger.Warn("dropping invalid or oversized completion result",
+			zap.String("task_id", taskID),
+			zap.Int("size", len(data)),
+		)
+		return "", false
+	}
+	return string(data), true
+}
+
+// MetadataMarshalFailures 返回 metadata 整体编码失败的累计次数
+func (p *Publisher) MetadataMarshalFailures() uint64 {
+	return p.metadataFailures.Load()
+}
+
 // ensureTTL 确保 Stream 设置了过期时间
-func (p *Publisher) ensureTTL(ctx context.Context, key string) {
-	if p.options.TTL <= 0 {
+func (p *Publisher) ensureTTL(ctx context.Context, key string, r retention) {
+	if r.ttl <= 0 {
 		return
 	}
 
@@ -143,14 +358,31 @@ func (p *Publisher) ensureTTL(ctx context.Context, key string) {
 		return
 	}
 
-	// 如果没有设置 TTL，则设置
-	if ttl < 0 {
-		p.redis.Expire(ctx, key, p.options.TTL)
+	// 没有设置 TTL，或剩余时间不超过 CompletedTTL 时设置为 TTL。
+	// 后者通常是完成事件缩短了 TTL 后任务重试、继续发布进度；执行很久的任务也会因此延长，不会在执行中过期
+	if ttl < 0 || (r.shortensCompleted() && ttl <= r.completedTTL) {
+		p.redis.Expire(ctx, key, r.ttl)
+	}
+}
+
+// expireCompleted 完成事件写入后将 Stream 的过期时间缩短为 CompletedTTL，
+// 已结束任务的进度流比进行中的更早清理
+func (p *Publisher) expireCompleted(ctx context.Context, taskID string, r retention) {
+	if !r.shortensCompleted() {
+		return
+	}
+	if err := p.redis.Expire(ctx, StreamKey(taskID), r.completedTTL).Err(); err != nil {
+		p.logger.Warn("failed to shorten completed progress stream ttl",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
 	}
 }
 
 // Delete 删除任务的进度 Stream
 func (p *Publisher) Delete(ctx context.Context, taskID string) error {
+	p.forgetPercentage(taskID)
+	p.forgetWatchers(taskID)
 	key := StreamKey(taskID)
 	return p.redis.Del(ctx, key).Err()
 }
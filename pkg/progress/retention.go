@@ -0,0 +1,73 @@
+package progress
+
+import (
+	"context"
+	"time"
+)
+
+// TaskTypeMetadataKey 进度 metadata 中携带任务类型的 key，context 中没有任务类型时用它选择保留策略
+const TaskTypeMetadataKey = "task_type"
+
+type taskTypeKey struct{}
+
+// WithTaskType 在 context 中记录任务类型，通过该 context 发布的事件按该类型的 StreamOverride 保留
+func WithTaskType(ctx context.Context, taskType string) context.Context {
+	return context.WithValue(ctx, taskTypeKey{}, taskType)
+}
+
+// TaskTypeFromContext 返回 WithTaskType 记录的任务类型，未记录时返回 false
+func TaskTypeFromContext(ctx context.Context) (string, bool) {
+	taskType, ok := ctx.Value(taskTypeKey{}).(string)
+	return taskType, ok && taskType != ""
+}
+
+// StreamOverride 某个任务类型的进度流保留策略，零值字段沿用 StreamOptions 中的全局值
+type StreamOverride struct {
+	MaxLen       int64
+	TTL          time.Duration
+	CompletedTTL time.Duration
+}
+
+// retention 一次发布实际使用的保留策略
+type retention struct {
+	maxLen       int64
+	ttl          time.Duration
+	completedTTL time.Duration
+}
+
+// shortensCompleted 完成事件写入后是否缩短 Stream 的过期时间
+func (r retention) shortensCompleted() bool {
+	return r.ttl > 0 && r.completedTTL > 0 && r.completedTTL < r.ttl
+}
+
+// retention 解析本次发布的保留策略：任务类型取自 context，其次取自 metadata 的 task_type，
+// 该类型配置了 TypeOverrides 时逐项覆盖全局值
+func (p *Publisher) retention(ctx context.Context, metadata map[string]string) retention {
+	r := retention{
+		maxLen:       p.options.MaxLen,
+		ttl:          p.options.TTL,
+		completedTTL: p.options.CompletedTTL,
+	}
+	if len(p.options.TypeOverrides) == 0 {
+		return r
+	}
+
+	taskType, ok := TaskTypeFromContext(ctx)
+	if !ok {
+		taskType = metadata[TaskTypeMetadataKey]
+	}
+	override, ok := p.options.TypeOverrides[taskType]
+	if !ok {
+		return r
+	}
+	if override.MaxLen > 0 {
+		r.maxLen = override.MaxLen
+	}
+	if override.TTL > 0 {
+		r.ttl = override.TTL
+	}
+	if override.CompletedTTL > 0 {
+		r.completedTTL = override.CompletedTTL
+	}
+	return r
+}
@@ -3,18 +3,43 @@ package progress
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+var (
+	// ErrTaskGone 订阅期间任务已被删除，不会再有新的进度
+	ErrTaskGone = errors.New("task no longer exists")
+	// ErrTaskOrProgressNotFound 任务和进度流都不存在，通常是任务 ID 有误
+	ErrTaskOrProgressNotFound = errors.New("task or progress not found")
+)
+
+// 订阅错误码，与 SubscribeResult.Error 对应
+const (
+	CodeTaskGone               = "TASK_GONE"
+	CodeTaskOrProgressNotFound = "TASK_OR_PROGRESS_NOT_FOUND"
 )
 
 // Subscriber 进度订阅器
 type Subscriber struct {
-	redis   *redis.Client
-	logger  *zap.Logger
-	options StreamOptions
+	redis    *redis.Client
+	blocking *redis.Client // 订阅期间的阻塞读取和存在性检查使用的客户端
+	logger   *zap.Logger
+	options  StreamOptions
+	clock    clock.Clock
+	timer    opTimer
+
+	slots    chan struct{} // 订阅连接预留，nil 表示不限制
+	active   atomic.Int64
+	rejected atomic.Uint64
 }
 
 // NewSubscriber 创建进度订阅器
@@ -24,10 +49,24 @@ func NewSubscriber(redisClient *redis.Client, logger *zap.Logger, opts ...Stream
 		opt = opts[0]
 	}
 
+	blocking := opt.SubscriptionClient
+	if blocking == nil {
+		blocking = redisClient
+	}
+	var slots chan struct{}
+	if opt.MaxSubscriptions > 0 {
+		slots = make(chan struct{}, opt.MaxSubscriptions)
+	}
+
+	clk := clock.OrReal(opt.Clock)
 	return &Subscriber{
-		redis:   redisClient,
-		logger:  logger,
-		options: opt,
+		redis:    redisClient,
+		blocking: blocking,
+		logger:   logger,
+		options:  opt,
+		clock:    clk,
+		timer:    newOpTimer(opt, logger, clk),
+		slots:    slots,
 	}
 }
 
@@ -38,90 +77,329 @@ type SubscribeResult struct {
 	Status    string    // 最终状态（仅当 IsFinal 为 true）
 	StreamID  string    // Redis Stream ID
 	Error     error     // 错误信息
+	Code      string    // 错误码（仅当 Error 不为 nil 且为已知错误）
+	Warnings  []string  // 消息中存在但无法解析的字段
+	// Result 完成事件携带的任务结果 JSON（仅当 IsFinal 为 true 且发布时携带了结果）
+	Result json.RawMessage
+	// Event 非普通进度的事件类型，如 EventAttemptStarted；执行次数见 Progress.Attempt
+	Event string
 }
 
 // Subscribe 订阅任务进度
-// 返回一个 channel，持续接收进度更新直到任务完成或 context 取消
+// 返回一个 channel，持续接收进度更新直到任务完成或 context 取消。
+// 调用方停止读取时必须取消 ctx，否则后台 goroutine 会一直阻塞；需要显式释放时使用 SubscribeIter。
+// 设置了 MaxSubscriptions 时调用方应先通过 Reserve 预留连接
 func (s *Subscriber) Subscribe(ctx context.Context, taskID string, startID ...string) <-chan SubscribeResult {
+	var opts SubscribeOptions
+	if len(startID) > 0 {
+		opts.StartID = startID[0]
+	}
+
 	ch := make(chan SubscribeResult, 10)
+	sub, err := s.SubscribeIter(ctx, taskID, opts)
+	if err != nil {
+		ch <- SubscribeResult{Error: err}
+		close(ch)
+		return ch
+	}
+
+	go func() {
+		defer close(ch)
+		defer sub.Close()
+		for {
+			result, ok := sub.Next(ctx)
+			if !ok {
+				return
+			}
+			select {
+			case ch <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// SubscribeIter 订阅任务进度，通过返回的 Subscription 逐条读取。
+// Close 后后台读取立即停止，不依赖 ctx 取消；ctx 已结束或 taskID 为空时返回错误。
+// 设置了 MaxSubscriptions 时调用方应先通过 Reserve 预留连接
+func (s *Subscriber) SubscribeIter(ctx context.Context, taskID string, opts SubscribeOptions) (*Subscription, error) {
+	if taskID == "" {
+		return nil, errors.New("task id is required")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// 默认从最新消息开始读取，使用 $ 表示只读新消息
-	// 如果指定了 startID，则从该位置开始读取
+	// 如果指定了 StartID，则从该位置开始读取
 	lastID := "$"
-	if len(startID) > 0 && startID[0] != "" {
-		lastID = startID[0]
+	if opts.StartID != "" {
+		lastID = opts.StartID
 	}
 
-	go func() {
-		defer close(ch)
+	ctx, cancel := context.WithCancel(ctx)
+	ch := make(chan SubscribeResult, 10)
+	s.active.Add(1)
+	// 订阅期间登记订阅数，发布方据此决定没有订阅方时是否跳过中间进度（见 UnwatchedMode）
+	go s.run(ctx, taskID, lastID, ch, s.watch(ctx, taskID))
+	return NewSubscription(ch, cancel), nil
+}
+
+// run 从 lastID 之后持续读取进度写入 ch，任务结束、出错或 ctx 取消时注销订阅数并关闭 ch
+func (s *Subscriber) run(ctx context.Context, taskID, lastID string, ch chan<- SubscribeResult, reg *watchRegistration) {
+	defer close(ch)
+	defer s.active.Add(-1)
+	defer reg.unwatch(ctx)
+
+	key := StreamKey(taskID)
+	minTimeout := s.options.ReadTimeout
+	if minTimeout == 0 {
+		minTimeout = 30 * time.Second
+	}
+	blockTimeout := minTimeout
+
+	// 启动时先检查一次，任务 ID 有误时不必等到第一次读取超时
+	var w watchdog
+	if s.watchEnabled() && s.finishIfMissing(ctx, ch, &w, taskID, key, lastID) {
+		return
+	}
 
-		key := StreamKey(taskID)
-		blockTimeout := s.options.ReadTimeout
-		if blockTimeout == 0 {
-			blockTimeout = 30 * time.Second
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Debug("subscription cancelled",
+				zap.String("task_id", taskID),
+				zap.Error(ctx.Err()),
+			)
+			return
+		default:
 		}
 
-		for {
-			select {
-			case <-ctx.Done():
-				s.logger.Debug("subscription cancelled",
-					zap.String("task_id", taskID),
-					zap.Error(ctx.Err()),
-				)
+		// 订阅数在订阅期间续期，阻塞读取不超过下一次续期的时间
+		reg.refresh(ctx)
+
+		// 使用 XREAD 阻塞读取
+		block := max(min(s.readTimeout(&w, blockTimeout), reg.untilRefresh()), time.Millisecond)
+		start := s.clock.Now()
+		streams, err := s.blocking.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{key, lastID},
+			Block:   block,
+			Count:   10, // 每次最多读取 10 条
+		}).Result()
+		s.timer.doneBlocking(OpXRead, taskID, start, block)
+
+		if err != nil {
+			if err == redis.Nil {
+				// 超时：空闲越久阻塞越久，减少空闲订阅对 Redis 的轮询
+				blockTimeout = s.nextBlockTimeout(blockTimeout)
+
+				if s.watchEnabled() && s.checkDue(&w) && s.finishIfMissing(ctx, ch, &w, taskID, key, lastID) {
+					return
+				}
+				continue
+			}
+			if ctx.Err() != nil {
+				// context 已取消
+				return
+			}
+			// 专用连接池耗尽：返回可识别的错误，而不是让调用方当作 Redis 故障处理
+			if errors.Is(err, redis.ErrPoolTimeout) {
+				s.rejected.Add(1)
+				s.logger.Warn("subscription pool exhausted", zap.String("task_id", taskID))
+				ch <- SubscribeResult{Error: fmt.Errorf("%w: %w", ErrSubscriptionCapacity, err), Code: CodeSubscriptionCapacity}
 				return
-			default:
 			}
+			s.logger.Error("failed to read stream",
+				zap.String("task_id", taskID),
+				zap.Error(err),
+			)
+			ch <- SubscribeResult{Error: err}
+			return
+		}
+
+		blockTimeout = minTimeout
 
-			// 使用 XREAD 阻塞读取
-			streams, err := s.redis.XRead(ctx, &redis.XReadArgs{
-				Streams: []string{key, lastID},
-				Block:   blockTimeout,
-				Count:   10, // 每次最多读取 10 条
-			}).Result()
-
-			if err != nil {
-				if err == redis.Nil {
-					// 超时，继续等待
-					continue
+		// 处理读取到的消息
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				result := s.parseMessage(taskID, msg)
+				lastID = msg.ID
+
+				select {
+				case ch <- result:
+				case <-ctx.Done():
+					return
 				}
-				if ctx.Err() != nil {
-					// context 已取消
+
+				// 如果是最终消息，结束订阅
+				if result.IsFinal {
+					s.logger.Debug("received final message, closing subscription",
+						zap.String("task_id", taskID),
+						zap.String("status", result.Status),
+					)
 					return
 				}
-				s.logger.Error("failed to read stream",
+			}
+		}
+	}
+}
+
+// nextBlockTimeout 计算下一次阻塞读取的超时
+func (s *Subscriber) nextBlockTimeout(current time.Duration) time.Duration {
+	if s.options.MaxReadTimeout <= current {
+		return current
+	}
+	return min(current*2, s.options.MaxReadTimeout)
+}
+
+// watchdog 订阅的存在性检查状态
+type watchdog struct {
+	lastCheck time.Time
+	// missingSince 首次发现任务和进度流都不存在的时间
+	missingSince time.Time
+}
+
+// watchVerdict 存在性检查结果
+type watchVerdict int
+
+const (
+	watchAlive watchVerdict = iota
+	// watchTaskGone 任务已删除
+	watchTaskGone
+	// watchNotFound 任务和进度流在宽限期内始终不存在
+	watchNotFound
+)
+
+func (s *Subscriber) watchEnabled() bool {
+	return s.options.TaskChecker != nil || s.options.NotFoundGrace > 0
+}
+
+// checkDue 到达检查间隔，或缺失状态已超过宽限期时需要检查
+func (s *Subscriber) checkDue(w *watchdog) bool {
+	if !w.missingSince.IsZero() && s.clock.Since(w.missingSince) >= s.options.NotFoundGrace {
+		return true
+	}
+	return s.clock.Since(w.lastCheck) >= s.options.WatchdogInterval
+}
+
+// readTimeout 缺失状态下阻塞读取不超过剩余宽限期，保证宽限期结束时及时检查
+func (s *Subscriber) readTimeout(w *watchdog, blockTimeout time.Duration) time.Duration {
+	if w.missingSince.IsZero() {
+		return blockTimeout
+	}
+	remaining := s.options.NotFoundGrace - s.clock.Since(w.missingSince)
+	return max(min(blockTimeout, remaining), time.Millisecond)
+}
+
+// check 检查任务和进度流是否仍存在，检查失败时视为存在
+func (s *Subscriber) check(ctx context.Context, w *watchdog, taskID, key string) watchVerdict {
+	w.lastCheck = s.clock.Now()
+
+	checked := s.options.TaskChecker != nil
+	if checked {
+		exists, err := s.options.TaskChecker.TaskExists(ctx, taskID)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.Warn("failed to check task existence",
 					zap.String("task_id", taskID),
 					zap.Error(err),
 				)
-				ch <- SubscribeResult{Error: err}
-				return
 			}
+			return watchAlive
+		}
+		if exists {
+			w.missingSince = time.Time{}
+			return watchAlive
+		}
+	}
 
-			// 处理读取到的消息
-			for _, stream := range streams {
-				for _, msg := range stream.Messages {
-					result := s.parseMessage(taskID, msg)
-					lastID = msg.ID
-
-					select {
-					case ch <- result:
-					case <-ctx.Done():
-						return
-					}
-
-					// 如果是最终消息，结束订阅
-					if result.IsFinal {
-						s.logger.Debug("received final message, closing subscription",
-							zap.String("task_id", taskID),
-							zap.String("status", result.Status),
-						)
-						return
-					}
-				}
+	n, err := s.blocking.Exists(ctx, key).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Warn("failed to check progress stream existence",
+				zap.String("task_id", taskID),
+				zap.Error(err),
+			)
+		}
+		return watchAlive
+	}
+	if n > 0 || s.options.NotFoundGrace <= 0 {
+		w.missingSince = time.Time{}
+		if checked {
+			return watchTaskGone
+		}
+		return watchAlive
+	}
+
+	// 刚创建的任务可能暂时不可见，缺失超过宽限期才判定不存在
+	if w.missingSince.IsZero() {
+		w.missingSince = w.lastCheck
+	}
+	if s.clock.Since(w.missingSince) >= s.options.NotFoundGrace {
+		return watchNotFound
+	}
+	return watchAlive
+}
+
+// finishIfMissing 任务或进度流已不存在时结束订阅，返回是否已结束
+func (s *Subscriber) finishIfMissing(ctx context.Context, ch chan<- SubscribeResult, w *watchdog, taskID, key, lastID string) bool {
+	switch s.check(ctx, w, taskID, key) {
+	case watchTaskGone:
+		s.finishGone(ctx, ch, taskID, key, lastID)
+		return true
+	case watchNotFound:
+		s.logger.Debug("task and progress not found, closing subscription", zap.String("task_id", taskID))
+		select {
+		case ch <- SubscribeResult{
+			Error: fmt.Errorf("%w: nothing known about task %s after %s, check the task ID",
+				ErrTaskOrProgressNotFound, taskID, s.options.NotFoundGrace),
+			Code: CodeTaskOrProgressNotFound,
+		}:
+		case <-ctx.Done():
+		}
+		return true
+	}
+	return false
+}
+
+// finishGone 任务已删除：先非阻塞读出剩余消息，没有最终消息时以 ErrTaskGone 结束订阅
+func (s *Subscriber) finishGone(ctx context.Context, ch chan<- SubscribeResult, taskID, key, lastID string) {
+	start := s.clock.Now()
+	messages, err := s.blocking.XRangeN(ctx, key, exclusiveStart(lastID), "+", 100).Result()
+	s.timer.done(OpXRange, taskID, start)
+	if err == nil {
+		for _, msg := range messages {
+			result := s.parseMessage(taskID, msg)
+			select {
+			case ch <- result:
+			case <-ctx.Done():
+				return
+			}
+			if result.IsFinal {
+				return
 			}
 		}
-	}()
+	}
 
-	return ch
+	s.logger.Debug("task deleted, closing subscription", zap.String("task_id", taskID))
+	select {
+	case ch <- SubscribeResult{Error: ErrTaskGone, Code: CodeTaskGone}:
+	case <-ctx.Done():
+	}
+}
+
+// exclusiveStart 将 XREAD 的起始 ID 转换为 XRANGE 的排他起点
+func exclusiveStart(lastID string) string {
+	switch lastID {
+	case "$":
+		return "+"
+	case "0", "0-0":
+		return "-"
+	default:
+		return "(" + lastID
+	}
 }
 
 // GetHistory 获取任务的历史进度
@@ -137,11 +415,13 @@ func (s *Subscriber) GetHistory(ctx context.Context, taskID string, startID stri
 	var messages []redis.XMessage
 	var err error
 
+	start := s.clock.Now()
 	if count > 0 {
 		messages, err = s.redis.XRangeN(ctx, key, startID, "+", count).Result()
 	} else {
 		messages, err = s.redis.XRange(ctx, key, startID, "+").Result()
 	}
+	s.timer.done(OpXRange, taskID, start)
 
 	if err != nil {
 		return nil, err
@@ -160,7 +440,9 @@ func (s *Subscriber) GetLatest(ctx context.Context, taskID string) (*SubscribeRe
 	key := StreamKey(taskID)
 
 	// 使用 XREVRANGE 获取最后一条消息
+	start := s.clock.Now()
 	messages, err := s.redis.XRevRangeN(ctx, key, "+", "-", 1).Result()
+	s.timer.done(OpXRevRange, taskID, start)
 	if err != nil {
 		return nil, err
 	}
@@ -221,10 +503,25 @@ func (s *Subscriber) parseMessage(taskID string, msg redis.XMessage) SubscribeRe
 	// 解析 metadata
 	if v, ok := values["metadata"].(string); ok && v != "" {
 		var meta map[string]string
-		if err := json.Unmarshal([]byte(v), &meta); err == nil {
+		if err := json.Unmarshal([]byte(v), &meta); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("metadata could not be decoded: %v", err))
+		} else {
 			result.Progress.Metadata = meta
 		}
 	}
+	if v, ok := values["metadata_json"].(string); ok && v != "" {
+		if validMetadataJSON([]byte(v)) {
+			result.Progress.MetadataJSON = json.RawMessage(v)
+		} else {
+			result.Warnings = append(result.Warnings, "metadata_json is not valid JSON or exceeds the size limit")
+		}
+	}
+
+	parseAttempt(values, result.Progress)
+	parseWorker(values, result.Progress)
+	if v, ok := values["event"].(string); ok {
+		result.Event = v
+	}
 
 	// 检查是否是最终消息
 	if v, ok := values["is_final"].(string); ok && v == "true" {
@@ -232,6 +529,13 @@ func (s *Subscriber) parseMessage(taskID string, msg redis.XMessage) SubscribeRe
 		if status, ok := values["status"].(string); ok {
 			result.Status = status
 		}
+		if v, ok := values["result"].(string); ok && v != "" {
+			if json.Valid([]byte(v)) {
+				result.Result = json.RawMessage(v)
+			} else {
+				result.Warnings = append(result.Warnings, "result is not valid JSON")
+			}
+		}
 	}
 
 	return result
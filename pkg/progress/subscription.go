@@ -0,0 +1,85 @@
+package progress
+
+import (
+	"context"
+	"sync"
+)
+
+// SubscribeOptions 订阅选项
+type SubscribeOptions struct {
+	// StartID 从该 Stream ID 之后开始读取："0" 包含全部历史，空或 "$" 只读新消息
+	StartID string
+}
+
+// Subscription 一次进度订阅，由 SubscribeIter 创建。
+// 调用方读完或不再读取时必须调用 Close，Close 会停止后台读取并等待其退出
+type Subscription struct {
+	ch     <-chan SubscribeResult
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	err    error
+	done   bool
+	closed sync.Once
+}
+
+// NewSubscription 包装一个结果 channel，产生结果的一方在 cancel 后必须关闭 ch。
+// 供 Subscriber 之外的订阅实现（如测试替身）复用同样的读取和释放语义
+func NewSubscription(ch <-chan SubscribeResult, cancel context.CancelFunc) *Subscription {
+	if cancel == nil {
+		cancel = func() {}
+	}
+	return &Subscription{ch: ch, cancel: cancel}
+}
+
+// Next 阻塞等待下一条结果，订阅已结束或 ctx 结束时返回 false。
+// 出错时最后一条结果带有 Error，之后 Err 返回同一错误；ctx 结束不会结束订阅，之后仍可继续调用
+func (s *Subscription) Next(ctx context.Context) (SubscribeResult, bool) {
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+	if done {
+		return SubscribeResult{}, false
+	}
+
+	select {
+	case result, ok := <-s.ch:
+		if !ok {
+			s.finish(nil)
+			return SubscribeResult{}, false
+		}
+		if result.Error != nil {
+			s.finish(result.Error)
+		}
+		return result, true
+	case <-ctx.Done():
+		return SubscribeResult{}, false
+	}
+}
+
+// Err 返回结束订阅的错误，正常结束（收到最终消息或 Close）时为 nil
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close 停止订阅并等待后台读取退出，可重复调用
+func (s *Subscription) Close() {
+	s.closed.Do(func() {
+		s.cancel()
+		for range s.ch {
+		}
+		s.finish(nil)
+	})
+}
+
+// finish 标记订阅已结束，只记录第一个错误
+func (s *Subscription) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.done {
+		s.err = err
+	}
+	s.done = true
+}
@@ -0,0 +1,153 @@
+package progress
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+	"go.uber.org/zap"
+)
+
+func TestSubscriptionCloseStopsReadingWithoutCancel(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+	t.Cleanup(func() { goleak.VerifyNone(t, ignore) })
+
+	_, client := newTestRedis(t)
+	opts := StreamOptions{MaxLen: 10, TTL: time.Hour, ReadTimeout: time.Second}
+	publisher := NewPublisher(client, zap.NewNop(), opts)
+	subscriber := NewSubscriber(client, zap.NewNop(), opts)
+
+	// ctx 从不取消，只靠 Close 释放
+	ctx := context.Background()
+	sub, err := subscriber.SubscribeIter(ctx, "task-1", SubscribeOptions{StartID: "0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := publisher.Publish(ctx, NewProgress("task-1", 10, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, ok := sub.Next(readCtx)
+	if !ok || result.Progress == nil || result.Progress.Percentage != 10 {
+		t.Fatalf("expected the published progress, got %+v (ok=%v)", result, ok)
+	}
+
+	sub.Close()
+	sub.Close()
+	if _, ok := sub.Next(readCtx); ok {
+		t.Fatal("expected no results after Close")
+	}
+	if err := sub.Err(); err != nil {
+		t.Fatalf("expected no error after Close, got %v", err)
+	}
+	if n := subscriber.ActiveSubscriptions(); n != 0 {
+		t.Fatalf("expected no active subscriptions after Close, got %d", n)
+	}
+}
+
+func TestSubscriptionReportsTerminalError(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+	t.Cleanup(func() { goleak.VerifyNone(t, ignore) })
+
+	_, client := newTestRedis(t)
+	checker := &fakeTaskChecker{}
+	checker.exists.Store(true)
+	subscriber := NewSubscriber(client, zap.NewNop(), StreamOptions{
+		ReadTimeout:    10 * time.Millisecond,
+		MaxReadTimeout: 20 * time.Millisecond,
+		TaskChecker:    checker,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	sub, err := subscriber.SubscribeIter(ctx, "task-1", SubscribeOptions{StartID: "0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sub.Close()
+	checker.exists.Store(false)
+
+	result, ok := sub.Next(ctx)
+	if !ok || !errors.Is(result.Error, ErrTaskGone) {
+		t.Fatalf("expected ErrTaskGone, got %+v (ok=%v)", result, ok)
+	}
+	if _, ok := sub.Next(ctx); ok {
+		t.Fatal("expected the subscription to end after an error")
+	}
+	if !errors.Is(sub.Err(), ErrTaskGone) {
+		t.Fatalf("expected Err to return ErrTaskGone, got %v", sub.Err())
+	}
+}
+
+func TestSubscriptionNextReturnsOnContextWithoutEnding(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+	t.Cleanup(func() { goleak.VerifyNone(t, ignore) })
+
+	_, client := newTestRedis(t)
+	opts := StreamOptions{MaxLen: 10, TTL: time.Hour, ReadTimeout: 10 * time.Millisecond}
+	publisher := NewPublisher(client, zap.NewNop(), opts)
+	subscriber := NewSubscriber(client, zap.NewNop(), opts)
+
+	sub, err := subscriber.SubscribeIter(context.Background(), "task-1", SubscribeOptions{StartID: "0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sub.Close()
+
+	short, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, ok := sub.Next(short); ok {
+		t.Fatal("expected Next to give up when its context ends")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := publisher.PublishCompletion(ctx, "task-1", "completed", "done"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result, ok := sub.Next(ctx); !ok || !result.IsFinal {
+		t.Fatalf("expected the final event after a timed out Next, got %+v (ok=%v)", result, ok)
+	}
+	if _, ok := sub.Next(ctx); ok || sub.Err() != nil {
+		t.Fatalf("expected a clean end after the final event, got err %v", sub.Err())
+	}
+}
+
+func TestSubscribeChannelReleasesOnCancel(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+	t.Cleanup(func() { goleak.VerifyNone(t, ignore) })
+
+	_, client := newTestRedis(t)
+	subscriber := NewSubscriber(client, zap.NewNop(), StreamOptions{ReadTimeout: time.Second})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := subscriber.Subscribe(ctx, "task-1")
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("expected no results after cancel")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the channel to close after cancel")
+	}
+}
+
+func TestSubscribeIterRejectsEndedContext(t *testing.T) {
+	subscriber := NewSubscriber(nil, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := subscriber.SubscribeIter(ctx, "task-1", SubscribeOptions{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, err := subscriber.SubscribeIter(context.Background(), "", SubscribeOptions{}); err == nil {
+		t.Fatal("expected an error for an empty task id")
+	}
+}
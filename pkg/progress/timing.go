@@ -0,0 +1,67 @@
+package progress
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+// Redis 操作名，用于慢操作日志和耗时指标
+const (
+	OpXAdd      = "xadd"
+	OpXRead     = "xread"
+	OpXRange    = "xrange"
+	OpXRevRange = "xrevrange"
+	// OpXReadGroup 消费组的阻塞读取，慢操作日志中 task_id 为进度流的匹配模式
+	OpXReadGroup = "xreadgroup"
+)
+
+// RedisObserver 记录进度层 Redis 操作的耗时
+type RedisObserver interface {
+	ObserveRedisOperation(op string, d time.Duration)
+}
+
+// opTimer 为 Redis 操作计时：记录耗时指标，超过 SlowOperationThreshold 时记录警告
+type opTimer struct {
+	threshold time.Duration
+	observer  RedisObserver
+	logger    *zap.Logger
+	clock     clock.Clock
+}
+
+func newOpTimer(opts StreamOptions, logger *zap.Logger, clk clock.Clock) opTimer {
+	return opTimer{
+		threshold: opts.SlowOperationThreshold,
+		observer:  opts.RedisObserver,
+		logger:    logger,
+		clock:     clk,
+	}
+}
+
+// done 记录从 start 开始的操作耗时
+func (t opTimer) done(op, taskID string, start time.Time) {
+	elapsed := t.clock.Since(start)
+	if t.observer != nil {
+		t.observer.ObserveRedisOperation(op, elapsed)
+	}
+	t.warnIfSlow(op, taskID, elapsed)
+}
+
+// doneBlocking 记录阻塞读取：等待新消息的时间不算慢，只检查超出 block 的部分，也不计入耗时指标
+func (t opTimer) doneBlocking(op, taskID string, start time.Time, block time.Duration) {
+	t.warnIfSlow(op, taskID, t.clock.Since(start)-block)
+}
+
+func (t opTimer) warnIfSlow(op, taskID string, elapsed time.Duration) {
+	if t.threshold <= 0 || elapsed < t.threshold {
+		return
+	}
+	t.logger.Warn("slow redis operation in progress layer",
+		zap.String("op", op),
+		zap.String("task_id", taskID),
+		zap.Duration("elapsed", elapsed),
+		zap.Duration("threshold", t.threshold),
+	)
+}
@@ -1,6 +1,14 @@
 package progress
 
-import "time"
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
 
 // Progress 表示任务执行进度
 type Progress struct {
@@ -10,6 +18,24 @@ type Progress struct {
 	Message     string            `json:"message"`
 	TimestampMs int64             `json:"timestamp_ms"`
 	Metadata    map[string]string `json:"metadata,omitempty"`
+	// MetadataJSON 任意结构的 JSON 元数据（数字、嵌套对象等），与 Metadata 并存
+	MetadataJSON json.RawMessage `json:"metadata_json,omitempty"`
+	// Attempt 第几次执行（从 1 开始），MaxAttempts 最多执行几次；0 表示未知
+	Attempt     int32 `json:"attempt,omitempty"`
+	MaxAttempts int32 `json:"max_attempts,omitempty"`
+	// Worker 发布该进度的 worker 实例，API 侧发布的事件没有
+	Worker *Worker `json:"worker,omitempty"`
+}
+
+// MaxMetadataJSONSize metadata_json 的最大字节数，超出时丢弃该字段
+const MaxMetadataJSONSize = 16 << 10
+
+// MaxResultSize 完成事件携带结果的最大字节数，超出时不携带结果
+const MaxResultSize = 64 << 10
+
+// validMetadataJSON metadata_json 必须是不超过大小限制的合法 JSON
+func validMetadataJSON(data []byte) bool {
+	return len(data) <= MaxMetadataJSONSize && json.Valid(data)
 }
 
 // Event 表示进度事件（包含 Stream 元信息）
@@ -52,6 +78,55 @@ type StreamOptions struct {
 	MaxLen      int64         // Stream 最大长度
 	TTL         time.Duration // Stream 过期时间
 	ReadTimeout time.Duration // 读取超时
+	Clock       clock.Clock   // 时间源，为空时使用系统时钟
+
+	// CompletedTTL 完成事件写入后将 Stream 的过期时间缩短为该值，留给迟到的订阅方读取最终状态。
+	// 0 或不小于 TTL 时不缩短；TTL 为 0（不过期）时不生效
+	CompletedTTL time.Duration
+
+	// CompletionRetryWindow 完成事件写入失败后在内存中重试的时间窗口，不大于 0 表示不重试
+	CompletionRetryWindow time.Duration
+	// ConfirmCompletion 完成事件写入失败时在调用方内同步重试，直到送达或重试窗口结束才返回，
+	// 而不是缓存到后台重试。处理器的返回会被阻塞，但返回 nil 即表示完成事件已写入 Redis
+	ConfirmCompletion bool
+
+	// MaxReadTimeout 空闲订阅的阻塞读取超时上限：每次读取超时后翻倍直到该值，收到消息后恢复为 ReadTimeout
+	// 不大于 ReadTimeout 时不做自适应
+	MaxReadTimeout time.Duration
+	// TaskChecker 订阅开始时和空闲期间检查任务是否仍存在，为空时不检查
+	TaskChecker TaskChecker
+	// WatchdogInterval 两次任务存在性检查的最小间隔
+	WatchdogInterval time.Duration
+	// NotFoundGrace 任务和进度流都不存在持续超过该时长时结束订阅，0 表示不检查进度流
+	NotFoundGrace time.Duration
+
+	// Monotonic 同一任务的进度百分比回退时的处理方式（完成事件不受影响），默认不检查
+	Monotonic MonotonicMode
+	// Unwatched 任务没有订阅方时中间进度的处理方式，默认照常发布。订阅数由 Subscriber 维护，
+	// 只统计 Subscribe/SubscribeIter，消费组和 GetHistory 的读取方不计入
+	Unwatched UnwatchedMode
+	// UnwatchedInterval Unwatched 为 UnwatchedSample 时每个任务发布中间进度的最小间隔
+	UnwatchedInterval time.Duration
+
+	// SlowOperationThreshold XADD/XREAD/XRANGE 超过该耗时时记录警告，0 表示不记录
+	SlowOperationThreshold time.Duration
+	// RedisObserver 记录 Redis 操作耗时，为空时不记录
+	RedisObserver RedisObserver
+
+	// TypeOverrides 按任务类型覆盖 MaxLen、TTL 和 CompletedTTL，未列出的类型使用全局值。
+	// 只影响发布方，任务类型见 WithTaskType 和 TaskTypeMetadataKey
+	TypeOverrides map[string]StreamOverride
+
+	// SubscriptionClient 订阅专用的 Redis 客户端，每个进行中的订阅占用其中一个连接做阻塞读取；
+	// 为空时与其他操作共用同一个客户端
+	SubscriptionClient *redis.Client
+	// MaxSubscriptions 可同时预留的订阅数，通常等于 SubscriptionClient 的连接池大小，0 表示不限制
+	MaxSubscriptions int
+}
+
+// TaskChecker 判断任务是否仍然存在
+type TaskChecker interface {
+	TaskExists(ctx context.Context, taskID string) (bool, error)
 }
 
 // DefaultOptions 返回默认配置
@@ -60,5 +135,17 @@ func DefaultOptions() StreamOptions {
 		MaxLen:      1000,              // 保留最近 1000 条进度
 		TTL:         1 * time.Hour,     // 1 小时后过期
 		ReadTimeout: 30 * time.Second,  // 30 秒读取超时
+
+		CompletedTTL:          5 * time.Minute,
+		CompletionRetryWindow: time.Minute,
+		WatchdogInterval:      time.Minute,
 	}
 }
+
+// 完成事件 metadata 中携带任务结果的 key
+const (
+	ResultMetadataKey    = "result"     // 内联结果 JSON
+	ResultRefMetadataKey = "result_ref" // 写入对象存储的结果引用（payload.BlobRef JSON）
+	WorkerMetadataKey    = "worker"     // 保存结果的 worker 实例（Worker JSON）
+	ArtifactsMetadataKey = "artifacts"  // 任务产出的文件列表（[]payload.Artifact JSON）
+)
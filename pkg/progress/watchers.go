@@ -0,0 +1,202 @@
+package progress
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// UnwatchedMode 没有订阅方时中间进度的处理方式，完成事件和 attempt_started 事件不受影响
+type UnwatchedMode string
+
+const (
+	// UnwatchedPublish 照常发布
+	UnwatchedPublish UnwatchedMode = ""
+	// UnwatchedDrop 不发布中间进度
+	UnwatchedDrop UnwatchedMode = "drop"
+	// UnwatchedSample 每个任务每 UnwatchedInterval 最多发布一条中间进度
+	UnwatchedSample UnwatchedMode = "sample"
+)
+
+// watcherCheckInterval 发布方缓存订阅数的时间，同一任务在此期间的进度不再查询 Redis
+const watcherCheckInterval = time.Second
+
+// watcherIdleTimeout 缓存的订阅状态超过该时间未使用即清除。没有发布完成事件就结束的任务
+// （worker 崩溃、任务被删除）不会触发 forgetWatchers，靠它回收
+const watcherIdleTimeout = 10 * time.Minute
+
+// WatcherKey 生成任务订阅数的 key，由 Subscriber 在订阅期间维护
+func WatcherKey(taskID string) string {
+	return "progress:watchers:" + taskID
+}
+
+// watchers 发布方按任务缓存的订阅状态，完成事件发布后清除
+type watchers struct {
+	mu      sync.Mutex
+	tasks   map[string]*watchState
+	sweptAt time.Time // 最近一次清理空闲状态的时间
+}
+
+type watchState struct {
+	watched     bool
+	checkedAt   time.Time
+	publishedAt time.Time // 最近一次发布中间进度的时间
+}
+
+// shouldPublish 按 Unwatched 判断是否发布任务的中间进度。查询订阅数失败时照常发布
+func (p *Publisher) shouldPublish(ctx context.Context, taskID string) bool {
+	mode := p.options.Unwatched
+	if mode == UnwatchedPublish {
+		return true
+	}
+
+	now := p.clock.Now()
+	p.watchers.mu.Lock()
+	p.sweepWatchers(now)
+	state, ok := p.watchers.tasks[taskID]
+	if !ok {
+		if p.watchers.tasks == nil {
+			p.watchers.tasks = make(map[string]*watchState)
+		}
+		state = &watchState{}
+		p.watchers.tasks[taskID] = state
+	}
+	due := !ok || now.Sub(state.checkedAt) >= watcherCheckInterval
+	p.watchers.mu.Unlock()
+
+	// 查询期间不持有锁，同一任务并发发布时最多多查询一次
+	if due {
+		count, err := p.redis.Get(ctx, WatcherKey(taskID)).Int64()
+		watched := count > 0
+		if err != nil && err != redis.Nil {
+			p.logger.Debug("failed to read progress watchers, publishing",
+				zap.String("task_id", taskID),
+				zap.Error(err),
+			)
+			watched = true
+		}
+		p.watchers.mu.Lock()
+		state.watched, state.checkedAt = watched, now
+		p.watchers.mu.Unlock()
+	}
+
+	p.watchers.mu.Lock()
+	defer p.watchers.mu.Unlock()
+	switch {
+	case state.watched:
+	case mode == UnwatchedSample && now.Sub(state.publishedAt) >= p.options.UnwatchedInterval:
+	default:
+		return false
+	}
+	state.publishedAt = now
+	return true
+}
+
+// sweepWatchers 清除超过 watcherIdleTimeout 未使用的订阅状态，最多每 watcherIdleTimeout 扫描一次。
+// 采样模式下发布时间在 UnwatchedInterval 内的状态仍然保留，调用方需持有 mu
+func (p *Publisher) sweepWatchers(now time.Time) {
+	if now.Sub(p.watchers.sweptAt) < watcherIdleTimeout {
+		return
+	}
+	p.watchers.sweptAt = now
+	idle := max(watcherIdleTimeout, p.options.UnwatchedInterval)
+	for taskID, state := range p.watchers.tasks {
+		if now.Sub(state.checkedAt) >= idle && now.Sub(state.publishedAt) >= idle {
+			delete(p.watchers.tasks, taskID)
+		}
+	}
+}
+
+// forgetWatchers 任务结束后清除缓存的订阅状态
+func (p *Publisher) forgetWatchers(taskID string) {
+	p.watchers.mu.Lock()
+	defer p.watchers.mu.Unlock()
+	delete(p.watchers.tasks, taskID)
+}
+
+// unwatchScript 注销订阅，计数归零时删除 key，避免 key 过期后 DECR 留下没有过期时间的负数
+var unwatchScript = redis.NewScript(`
+local n = redis.call("DECR", KEYS[1])
+if n <= 0 then
+	redis.call("DEL", KEYS[1])
+end
+return n
+`)
+
+// watchRegistration 一个订阅登记的订阅数，订阅期间由 run 定期续期
+type watchRegistration struct {
+	s           *Subscriber
+	taskID      string
+	key         string
+	ttl         time.Duration
+	registered  bool
+	refreshedAt time.Time
+}
+
+// watch 登记一个订阅，订阅结束时调用 unwatch 注销。订阅数的过期时间与进度流的 TTL 一致，
+// 订阅期间每半个 TTL 续期一次；订阅方异常退出时残留的计数最多保留一个 TTL，期间发布方照常发布
+func (s *Subscriber) watch(ctx context.Context, taskID string) *watchRegistration {
+	ttl := s.options.TTL
+	if ttl <= 0 {
+		ttl = DefaultOptions().TTL
+	}
+	reg := &watchRegistration{s: s, taskID: taskID, key: WatcherKey(taskID), ttl: ttl}
+
+	pipe := s.redis.TxPipeline()
+	pipe.Incr(ctx, reg.key)
+	pipe.Expire(ctx, reg.key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.Debug("failed to register progress watcher",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+		return reg
+	}
+	reg.registered, reg.refreshedAt = true, s.clock.Now()
+	return reg
+}
+
+// untilRefresh 距下一次续期的时间，未登记成功时不需要续期
+func (r *watchRegistration) untilRefresh() time.Duration {
+	if !r.registered {
+		return math.MaxInt64
+	}
+	return max(r.ttl/2-r.s.clock.Since(r.refreshedAt), 0)
+}
+
+// refresh 到期时续期订阅数，续期失败时四分之一 TTL 后重试
+func (r *watchRegistration) refresh(ctx context.Context) {
+	if r.untilRefresh() > 0 {
+		return
+	}
+	if err := r.s.redis.Expire(ctx, r.key, r.ttl).Err(); err != nil {
+		if ctx.Err() == nil {
+			r.s.logger.Debug("failed to refresh progress watcher",
+				zap.String("task_id", r.taskID),
+				zap.Error(err),
+			)
+		}
+		r.refreshedAt = r.s.clock.Now().Add(-r.ttl / 4)
+		return
+	}
+	r.refreshedAt = r.s.clock.Now()
+}
+
+// unwatch 注销订阅，ctx 已取消时仍会执行
+func (r *watchRegistration) unwatch(ctx context.Context) {
+	if !r.registered {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := unwatchScript.Run(ctx, r.s.redis, []string{r.key}).Err(); err != nil {
+		r.s.logger.Debug("failed to unregister progress watcher",
+			zap.String("task_id", r.taskID),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,147 @@
+package progress
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+)
+
+func TestPublishUnwatchedModes(t *testing.T) {
+	for _, tc := range []struct {
+		mode UnwatchedMode
+		want []int32
+	}{
+		{UnwatchedPublish, []int32{10, 20, 30, 100}},
+		{UnwatchedDrop, []int32{100}},
+		{UnwatchedSample, []int32{10, 30, 100}},
+	} {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			_, client := newTestRedis(t)
+			fake := clock.NewFake(time.Now())
+			publisher := NewPublisher(client, zap.NewNop(), StreamOptions{
+				MaxLen: 10, Clock: fake, Unwatched: tc.mode, UnwatchedInterval: 10 * time.Second,
+			})
+			subscriber := NewSubscriber(client, zap.NewNop())
+			ctx := context.Background()
+
+			for i, pct := range []int32{10, 20, 30} {
+				if i > 0 {
+					fake.Advance(5 * time.Second)
+				}
+				if err := publisher.Publish(ctx, NewProgress("task-1", pct, "running", "")); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			// 完成事件总是发布
+			if err := publisher.PublishCompletion(ctx, "task-1", "completed", "done"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			history, err := subscriber.GetHistory(ctx, "task-1", "", 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []int32
+			for _, event := range history {
+				got = append(got, event.Progress.Percentage)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPublishResumesWhenSubscribed(t *testing.T) {
+	mr, client := newTestRedis(t)
+	fake := clock.NewFake(time.Now())
+	opts := StreamOptions{MaxLen: 10, TTL: time.Hour, ReadTimeout: 10 * time.Millisecond, Clock: fake, Unwatched: UnwatchedDrop}
+	publisher := NewPublisher(client, zap.NewNop(), opts)
+	subscriber := NewSubscriber(client, zap.NewNop(), opts)
+	ctx := context.Background()
+
+	if err := publisher.Publish(ctx, NewProgress("task-1", 10, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sub, err := subscriber.SubscribeIter(ctx, "task-1", SubscribeOptions{StartID: "0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sub.Close()
+	if got, _ := mr.Get(WatcherKey("task-1")); got != "1" {
+		t.Fatalf("expected one registered watcher, got %q", got)
+	}
+	if ttl := mr.TTL(WatcherKey("task-1")); ttl != time.Hour {
+		t.Fatalf("expected watcher count to expire with the stream ttl, got %v", ttl)
+	}
+
+	// 订阅数缓存过期后恢复发布
+	fake.Advance(watcherCheckInterval)
+	if err := publisher.Publish(ctx, NewProgress("task-1", 20, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result, ok := sub.Next(ctx)
+	if !ok || result.Progress == nil || result.Progress.Percentage != 20 {
+		t.Fatalf("expected the progress published after subscribing, got %+v", result)
+	}
+
+	sub.Close()
+	if mr.Exists(WatcherKey("task-1")) {
+		t.Fatal("expected the watcher count to be removed when the last subscription closes")
+	}
+}
+
+func TestWatcherCountRefreshedDuringSubscription(t *testing.T) {
+	mr, client := newTestRedis(t)
+	fake := clock.NewFake(time.Now())
+	opts := StreamOptions{MaxLen: 10, TTL: time.Hour, ReadTimeout: 10 * time.Millisecond, Clock: fake}
+	subscriber := NewSubscriber(client, zap.NewNop(), opts)
+
+	sub, err := subscriber.SubscribeIter(context.Background(), "task-1", SubscribeOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sub.Close()
+
+	// 超过半个 TTL 后，下一次读取超时前续期
+	fake.Advance(31 * time.Minute)
+	mr.FastForward(31 * time.Minute)
+	deadline := time.Now().Add(2 * time.Second)
+	for mr.TTL(WatcherKey("task-1")) != time.Hour {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the watcher count to be refreshed, ttl is %v", mr.TTL(WatcherKey("task-1")))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPublisherEvictsIdleWatchState(t *testing.T) {
+	_, client := newTestRedis(t)
+	fake := clock.NewFake(time.Now())
+	publisher := NewPublisher(client, zap.NewNop(), StreamOptions{MaxLen: 10, Clock: fake, Unwatched: UnwatchedDrop})
+	ctx := context.Background()
+
+	// task-1 没有发布完成事件就不再上报
+	if err := publisher.Publish(ctx, NewProgress("task-1", 10, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fake.Advance(watcherIdleTimeout)
+	if err := publisher.Publish(ctx, NewProgress("task-2", 10, "running", "")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	publisher.watchers.mu.Lock()
+	defer publisher.watchers.mu.Unlock()
+	if _, ok := publisher.watchers.tasks["task-1"]; ok {
+		t.Fatal("expected the idle watch state to be evicted")
+	}
+	if _, ok := publisher.watchers.tasks["task-2"]; !ok {
+		t.Fatal("expected the active watch state to be kept")
+	}
+}
@@ -0,0 +1,73 @@
+package progress
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// workerLookupCount LatestWorker 从最新事件往前查找的事件数上限
+const workerLookupCount = 100
+
+// Worker 处理任务的 worker 实例
+type Worker struct {
+	// ID 实例 ID（主机名 + 随机后缀），每次启动都不同
+	ID string `json:"id"`
+	// Version worker 的构建版本
+	Version string `json:"version,omitempty"`
+}
+
+type workerKey struct{}
+
+// WithWorker 在 context 中记录处理任务的 worker 实例，通过该 context 发布的进度和完成事件都会带上它
+func WithWorker(ctx context.Context, w Worker) context.Context {
+	return context.WithValue(ctx, workerKey{}, w)
+}
+
+// WorkerFromContext 返回 WithWorker 记录的 worker 实例，未记录时返回 false
+func WorkerFromContext(ctx context.Context) (Worker, bool) {
+	w, ok := ctx.Value(workerKey{}).(Worker)
+	return w, ok && w.ID != ""
+}
+
+// setWorker 将 context 中的 worker 实例写入 Stream 字段
+func setWorker(ctx context.Context, values map[string]interface{}) {
+	w, ok := WorkerFromContext(ctx)
+	if !ok {
+		return
+	}
+	values["worker_id"] = w.ID
+	if w.Version != "" {
+		values["worker_version"] = w.Version
+	}
+}
+
+// parseWorker 解析 Stream 字段中的 worker 实例
+func parseWorker(values map[string]interface{}, prog *Progress) {
+	id, _ := values["worker_id"].(string)
+	if id == "" {
+		return
+	}
+	version, _ := values["worker_version"].(string)
+	prog.Worker = &Worker{ID: id, Version: version}
+}
+
+// LatestWorker 返回最近一条由 worker 发布的事件中记录的 worker 实例。API 发布的事件（取消、归档等）
+// 不带 worker，跳过它们继续往前找，最多查看 workerLookupCount 条；没有找到时返回 nil
+func (s *Subscriber) LatestWorker(ctx context.Context, taskID string) (*Worker, error) {
+	start := s.clock.Now()
+	messages, err := s.redis.XRevRangeN(ctx, StreamKey(taskID), "+", "-", workerLookupCount).Result()
+	s.timer.done(OpXRevRange, taskID, start)
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for _, msg := range messages {
+		var prog Progress
+		parseWorker(msg.Values, &prog)
+		if prog.Worker != nil {
+			return prog.Worker, nil
+		}
+	}
+	return nil, nil
+}
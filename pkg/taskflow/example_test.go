@@ -0,0 +1,78 @@
+package taskflow_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/taskflow"
+)
+
+// reportHandler 嵌入方自己的任务处理器
+type reportHandler struct{}
+
+func (reportHandler) Type() string { return "billing:report" }
+
+func (reportHandler) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	// 生成报表……
+	return nil
+}
+
+// 在自己的进程中运行 TaskFlow worker，消费 billing:report 任务并在任务结束时记录状态。
+// 示例使用内存中的 Redis，实际部署时 LoadConfig 读取自己的配置文件
+func ExampleNewWorker() {
+	redis, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer redis.Close()
+
+	dir, err := os.MkdirTemp("", "taskflow-example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	config := "server:\n  http:\n    port: 8080\n  worker:\n    concurrency: 1\n    warmup_timeout: 1s\n" +
+		"redis:\n  addr: " + redis.Addr() + "\nqueues:\n  critical: 4\n  high: 3\n  default: 2\n  low: 1\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		log.Fatal(err)
+	}
+	cfg, err := taskflow.LoadConfig(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	finished := make(chan taskflow.TaskEvent, 1)
+	w, err := taskflow.NewWorker(cfg,
+		taskflow.WithLogger(zap.NewNop()),
+		taskflow.WithHandlers(reportHandler{}),
+		taskflow.OnTaskComplete(func(ctx context.Context, e taskflow.TaskEvent) {
+			finished <- e
+		}),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := w.Start(); err != nil {
+		log.Fatal(err)
+	}
+	// 实际进程中在收到 SIGINT/SIGTERM 后调用
+	defer w.Shutdown()
+
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: redis.Addr()})
+	defer client.Close()
+	if _, err := client.Enqueue(asynq.NewTask("billing:report", nil)); err != nil {
+		log.Fatal(err)
+	}
+
+	e := <-finished
+	fmt.Println(e.Type, e.Status)
+	// Output: billing:report completed
+}
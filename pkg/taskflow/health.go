@@ -0,0 +1,270 @@
+package taskflow
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/debugcapture"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+// healthHandler worker 的健康检查、指标和维护接口
+func (w *Worker) healthHandler() http.Handler {
+	cfg, logger := w.cfg, w.logger
+
+	healthMux := http.NewServeMux()
+	healthMux.HandleFunc("/health", func(rw http.ResponseWriter, r *http.Request) {
+		status := "healthy"
+		services := map[string]string{}
+
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.OperationTimeouts.HealthCheck)
+		defer cancel()
+
+		if err := w.redis.Ping(ctx).Err(); err != nil {
+			services["redis"] = "unhealthy"
+			status = "unhealthy"
+		} else {
+			services["redis"] = "healthy"
+		}
+
+		// 进度流降级不影响任务执行，仅在健康信息中标识
+		if w.publisher == nil {
+			services["progress"] = "disabled"
+		} else if w.publisher.Degraded() {
+			services["progress"] = "degraded"
+			if status == "healthy" {
+				status = "degraded"
+			}
+		} else {
+			services["progress"] = "healthy"
+		}
+		var canaryStatus *progress.CanaryStatus
+		if w.canary != nil {
+			s := w.canary.Status()
+			canaryStatus = &s
+			if s.Status == progress.CanaryUnhealthy {
+				services["progress"] = s.Status
+				if status == "healthy" {
+					status = "degraded"
+				}
+			}
+		}
+
+		streams := make(map[string]map[string]int)
+		if w.clientManager != nil {
+			for _, svc := range w.clientManager.GetHealthStatus() {
+				name := fmt.Sprintf("grpc:%s", svc.Name)
+				if svc.Healthy {
+					services[name] = "healthy"
+				} else {
+					services[name] = "unhealthy"
+					status = "unhealthy"
+				}
+				streams[name] = map[string]int{"active": svc.ActiveStreams, "max": svc.MaxStreams}
+			}
+		}
+
+		payload := map[string]interface{}{
+			"status":    status,
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"services":  services,
+		}
+		if len(streams) > 0 {
+			payload["streams"] = streams
+		}
+		if canaryStatus != nil {
+			payload["progress"] = canaryStatus
+		}
+		if status == "unhealthy" {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(rw).Encode(payload)
+	})
+
+	healthMux.HandleFunc("/ready", func(rw http.ResponseWriter, r *http.Request) {
+		if !w.warmedUp.Load() {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(rw).Encode(map[string]string{
+				"status": "not ready",
+				"reason": "warming up",
+			})
+			return
+		}
+		if w.server.Draining() {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(rw).Encode(map[string]string{
+				"status": "not ready",
+				"reason": "draining",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.OperationTimeouts.HealthCheck)
+		defer cancel()
+
+		if err := w.redis.Ping(ctx).Err(); err != nil {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(rw).Encode(map[string]string{
+				"status": "not ready",
+				"reason": "redis unavailable",
+			})
+			return
+		}
+
+		if w.clientManager != nil && len(w.clientManager.UnhealthyServices()) > 0 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(rw).Encode(map[string]string{
+				"status": "not ready",
+				"reason": "grpc services unavailable",
+			})
+			return
+		}
+
+		_ = json.NewEncoder(rw).Encode(map[string]string{"status": "ready"})
+	})
+
+	if w.metrics != nil {
+		healthMux.Handle("/metrics", w.metrics.Handler())
+	}
+	healthMux.HandleFunc("/active", func(rw http.ResponseWriter, r *http.Request) {
+		tasks := w.activeTasks.List()
+		items := make([]map[string]interface{}, 0, len(tasks))
+		for _, t := range tasks {
+			items = append(items, map[string]interface{}{
+				"task_id":    t.ID,
+				"type":       t.Type,
+				"queue":      t.Queue,
+				"started_at": t.StartedAt.UTC().Format(time.RFC3339),
+				"elapsed_ms": t.Elapsed.Milliseconds(),
+			})
+		}
+		_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+			"count": len(items),
+			"tasks": items,
+		})
+	})
+	// 维护用：停止拉取新任务，正在执行的任务继续完成
+	healthMux.Handle("POST /drain", adminOnly(cfg.Admin.Token, func(rw http.ResponseWriter, r *http.Request) {
+		if w.server.Drain() {
+			logger.Info("worker draining")
+		}
+		_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+			"status": "draining",
+			"active": len(w.activeTasks.List()),
+		})
+	}))
+	healthMux.Handle("POST /undrain", adminOnly(cfg.Admin.Token, func(rw http.ResponseWriter, r *http.Request) {
+		if !w.server.Draining() {
+			_ = json.NewEncoder(rw).Encode(map[string]string{"status": "running"})
+			return
+		}
+		// 重启消费会关闭已停止的服务，仍有任务在执行时拒绝，避免它们被中断
+		if active := len(w.activeTasks.List()); active > 0 {
+			rw.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+				"error":  "tasks are still running",
+				"code":   "DRAIN_IN_PROGRESS",
+				"active": active,
+			})
+			return
+		}
+		if err := w.server.Undrain(); err != nil {
+			logger.Error("failed to resume worker", zap.Error(err))
+			rw.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(rw).Encode(map[string]string{
+				"error": err.Error(),
+				"code":  "UNDRAIN_FAILED",
+			})
+			return
+		}
+		logger.Info("worker resumed")
+		_ = json.NewEncoder(rw).Encode(map[string]string{"status": "running"})
+	}))
+	// 调试采集：对某个任务类型在一段时间内输出 debug 日志并记录 payload、结果和 gRPC 消息大小
+	healthMux.Handle("POST /admin/debug/capture", adminOnly(cfg.Admin.Token, func(rw http.ResponseWriter, r *http.Request) {
+		var req struct {
+			TaskType string `json:"task_type"`
+			Duration string `json:"duration"`
+			debugcapture.Options
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		var duration time.Duration
+		if err == nil {
+			duration, err = time.ParseDuration(req.Duration)
+		}
+		if err == nil && !slices.Contains(w.registry.Types(), req.TaskType) {
+			err = fmt.Errorf("task type %q is not handled by this worker", req.TaskType)
+		}
+		var capture debugcapture.Capture
+		if err == nil {
+			capture, err = w.debugCaptures.Start(r.Context(), req.TaskType, duration, req.Options)
+		}
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(rw).Encode(map[string]string{
+				"error": err.Error(),
+				"code":  "INVALID_DEBUG_CAPTURE",
+			})
+			return
+		}
+		logger.Info("debug capture started",
+			zap.String("debug_capture", capture.ID),
+			zap.String("type", capture.TaskType),
+			zap.Time("expires_at", capture.ExpiresAt),
+		)
+		rw.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(rw).Encode(capture)
+	}))
+	healthMux.Handle("GET /admin/debug/capture/{id}", adminOnly(cfg.Admin.Token, func(rw http.ResponseWriter, r *http.Request) {
+		report, err := w.debugCaptures.Get(r.Context(), r.PathValue("id"))
+		if errors.Is(err, debugcapture.ErrNotFound) {
+			rw.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(rw).Encode(map[string]string{
+				"error": err.Error(),
+				"code":  "DEBUG_CAPTURE_NOT_FOUND",
+			})
+			return
+		}
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(rw).Encode(map[string]string{
+				"error": err.Error(),
+				"code":  "DEBUG_CAPTURE_FAILED",
+			})
+			return
+		}
+		_ = json.NewEncoder(rw).Encode(report)
+	}))
+	healthMux.HandleFunc("/live", func(rw http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(rw).Encode(map[string]string{"status": "alive"})
+	})
+	return healthMux
+}
+
+// adminOnly 校验管理令牌（Authorization: Bearer <token> 或 X-Admin-Token），未配置令牌时拒绝所有请求
+func adminOnly(token string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := r.Header.Get("X-Admin-Token")
+		if provided == "" {
+			provided = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"error": "admin credentials required",
+				"code":  "UNAUTHORIZED",
+			})
+			return
+		}
+		next(w, r)
+	})
+}
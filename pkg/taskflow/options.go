@@ -0,0 +1,69 @@
+package taskflow
+
+import (
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+// Option Worker 的可选配置
+type Option func(*Worker)
+
+// WithLogger 设置日志，未设置时不输出日志
+func WithLogger(logger *zap.Logger) Option {
+	return func(w *Worker) {
+		w.logger = logger
+	}
+}
+
+// WithAsynqLogLevel 设置 asynq 内部日志的级别，调用方可在运行中修改。未设置时使用 logging.asynq.level
+func WithAsynqLogLevel(level zap.AtomicLevel) Option {
+	return func(w *Worker) {
+		w.asynqLogLevel = &level
+	}
+}
+
+// WithVersion 设置随进度事件、指标和服务发现上报的版本号，默认为 dev
+func WithVersion(version string) Option {
+	return func(w *Worker) {
+		w.version = version
+	}
+}
+
+// WithHandlers 注册任务处理器，任务类型重复时 NewWorker 返回错误。
+// 启用 grpc_services 时 gRPC 任务处理器由 Worker 自动注册
+func WithHandlers(handlers ...Handler) Option {
+	return func(w *Worker) {
+		w.handlers = append(w.handlers, handlers...)
+	}
+}
+
+// WithMiddleware 追加 asynq 中间件，位于内置中间件之内、最靠近处理器，按传入顺序由外到内执行
+func WithMiddleware(middlewares ...asynq.MiddlewareFunc) Option {
+	return func(w *Worker) {
+		w.middlewares = append(w.middlewares, middlewares...)
+	}
+}
+
+// WithProgressPublisher 使用调用方创建的进度发布器，处理器需要自行发布进度时与 Worker 共用同一个。
+// 未设置时按 progress 配置创建，progress.enabled 为 false 时忽略
+func WithProgressPublisher(publisher *progress.Publisher) Option {
+	return func(w *Worker) {
+		w.publisher = publisher
+	}
+}
+
+// OnTaskStart 每次执行任务前调用 hook，可多次注册
+func OnTaskStart(hook TaskHook) Option {
+	return func(w *Worker) {
+		w.hooks.OnStart = append(w.hooks.OnStart, hook)
+	}
+}
+
+// OnTaskComplete 每次执行结束后调用 hook，包括失败、取消和 panic，可多次注册
+func OnTaskComplete(hook TaskHook) Option {
+	return func(w *Worker) {
+		w.hooks.OnComplete = append(w.hooks.OnComplete, hook)
+	}
+}
@@ -0,0 +1,58 @@
+package taskflow
+
+import (
+	"maps"
+	"slices"
+
+	"go.uber.org/zap"
+
+	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+)
+
+// checkQueues 启动时列出 Redis 中已存在但本 worker 不消费的队列。配置中没有的队列不会被任何 worker 消费，
+// 通常来自拼错的队列名，其中的任务会一直等待，因此记录警告；属于其他标签路由的队列只记录调试日志
+func (w *Worker) checkQueues() {
+	client, err := asynqqueue.NewClient(&w.cfg.Redis, asynqqueue.WithOperationTimeout(startupTimeout))
+	if err != nil {
+		w.logger.Warn("failed to create queue client for queue check", zap.Error(err))
+		return
+	}
+	defer client.Close()
+
+	existing, err := client.GetQueues()
+	if err != nil {
+		w.logger.Warn("failed to list queues in redis", zap.Error(err))
+		return
+	}
+
+	unknown, elsewhere := unconsumedQueues(existing, w.queues, w.cfg.KnownQueues())
+	if len(unknown) > 0 {
+		w.logger.Warn("queues in redis are not consumed by this worker",
+			zap.Strings("queues", unknown),
+			zap.Strings("consumed", slices.Sorted(maps.Keys(w.queues))),
+		)
+	}
+	if len(elsewhere) > 0 {
+		w.logger.Debug("queues in redis are consumed by workers with other labels",
+			zap.Strings("queues", elsewhere),
+		)
+	}
+}
+
+// unconsumedQueues 返回 existing 中不在 consumed 里的队列：unknown 不在 known 中，
+// elsewhere 在 known 中（其他标签路由的队列，由具备相应标签的 worker 消费）
+func unconsumedQueues(existing []string, consumed map[string]int, known []string) (unknown, elsewhere []string) {
+	for _, queue := range existing {
+		if _, ok := consumed[queue]; ok {
+			continue
+		}
+		if slices.Contains(known, queue) {
+			elsewhere = append(elsewhere, queue)
+		} else {
+			unknown = append(unknown, queue)
+		}
+	}
+	slices.Sort(unknown)
+	slices.Sort(elsewhere)
+	return unknown, elsewhere
+}
@@ -0,0 +1,25 @@
+// Package taskflow 组装 TaskFlow worker：注册处理器、进度发布、asynq 服务、健康检查和服务发现。
+// cmd/server 基于它运行，其他服务也可以用它在自己的进程中消费 TaskFlow 任务
+package taskflow
+
+import (
+	"github.com/Aixtrade/TaskFlow/internal/config"
+	"github.com/Aixtrade/TaskFlow/internal/worker"
+)
+
+// 以下类型供嵌入方在不引用 internal 包的情况下使用
+type (
+	// Config TaskFlow 配置，通过 LoadConfig 读取
+	Config = config.Config
+	// Handler 任务处理器，Type 返回处理的任务类型
+	Handler = worker.Handler
+	// TaskEvent 生命周期回调收到的任务信息
+	TaskEvent = worker.TaskEvent
+	// TaskHook 任务开始或结束时的回调
+	TaskHook = worker.TaskHook
+)
+
+// LoadConfig 读取配置文件并补全默认值，path 为空时在 ./configs 和当前目录查找 config.yaml
+func LoadConfig(path string) (*Config, error) {
+	return config.Load(path)
+}
@@ -0,0 +1,598 @@
+package taskflow
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/Aixtrade/TaskFlow/internal/config"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/aging"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/blobstore"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/debugcapture"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/discovery"
+	grpcclient "github.com/Aixtrade/TaskFlow/internal/infrastructure/grpc"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/observability/logging"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/observability/metrics"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/progresstoken"
+	asynqqueue "github.com/Aixtrade/TaskFlow/internal/infrastructure/queue/asynq"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/queuecleanup"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/routing"
+	"github.com/Aixtrade/TaskFlow/internal/infrastructure/schema"
+	"github.com/Aixtrade/TaskFlow/internal/worker"
+	grpctask "github.com/Aixtrade/TaskFlow/internal/worker/handlers/grpc_task"
+	"github.com/Aixtrade/TaskFlow/pkg/clock"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+const (
+	// warmupInterval 预热期间两次检查的间隔
+	warmupInterval = 500 * time.Millisecond
+	// startupTimeout 启动和关闭时单次 Redis 操作的超时
+	startupTimeout = 5 * time.Second
+)
+
+// Worker 一个 TaskFlow worker 实例。NewWorker 创建后调用 Start 开始消费，Shutdown 停止并释放资源
+type Worker struct {
+	cfg           *config.Config
+	logger        *zap.Logger
+	version       string
+	asynqLogLevel *zap.AtomicLevel
+	handlers      []Handler
+	middlewares   []asynq.MiddlewareFunc
+	publisher     *progress.Publisher // 进度功能关闭时为空
+	events        progress.EventPublisher
+	hooks         worker.LifecycleHooks
+
+	instance      progress.Worker
+	redis         *redis.Client
+	metrics       *metrics.Metrics
+	registry      *worker.Registry
+	canary        *progress.Canary
+	clientManager *grpcclient.ClientManager
+	server        *asynqqueue.Server
+	routes        *routing.Table
+
+	baseQueues     map[string]int
+	queues         map[string]int
+	weightStore    *discovery.WeightStore
+	weightsVersion int64
+
+	debugCaptures *worker.DebugCaptures
+	activeTasks   *worker.ActiveTasks
+	healthServer  *http.Server
+	warmedUp      atomic.Bool
+	advertiser    *discovery.Advertiser
+
+	// background 在 Start 后运行、Shutdown 时停止的后台任务
+	background []func(ctx context.Context)
+	// closers 在 Shutdown 时按注册的相反顺序关闭
+	closers []func() error
+	stop    context.CancelFunc
+}
+
+// NewWorker 按配置创建 worker：连接 Redis、注册处理器和中间件、创建 asynq 服务，但不开始消费
+func NewWorker(cfg *Config, opts ...Option) (*Worker, error) {
+	w := &Worker{
+		cfg:     cfg,
+		logger:  zap.NewNop(),
+		version: "dev",
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if err := w.build(); err != nil {
+		w.close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Worker) build() error {
+	cfg, logger := w.cfg, w.logger
+
+	// 本实例的标识，随进度和完成事件发布，用于定位处理任务的 pod 和版本
+	w.instance = progress.Worker{ID: discovery.NewInstanceID(), Version: w.version}
+
+	w.redis = redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+	w.closers = append(w.closers, w.redis.Close)
+
+	if cfg.Metrics.Enabled {
+		w.metrics = metrics.New(
+			metrics.WithLabelGuard(metrics.NewLabelGuard(cfg.Metrics.MaxLabelValues, logger)),
+			metrics.WithWorkerInstance(w.instance.ID, w.instance.Version),
+		)
+	}
+
+	switch {
+	case !cfg.Progress.IsEnabled():
+		// 关闭进度功能时不写入进度流，中间件和处理器发布的事件全部丢弃
+		w.publisher = nil
+		w.events = progress.NopPublisher{}
+	case w.publisher == nil:
+		progressOpts := progress.StreamOptions{
+			MaxLen:      cfg.Progress.MaxLen,
+			TTL:         cfg.Progress.TTL,
+			ReadTimeout: cfg.Progress.ReadTimeout,
+
+			CompletedTTL:           cfg.Progress.CompletedTTL,
+			TypeOverrides:          cfg.Progress.TypeOverrides(),
+			CompletionRetryWindow:  cfg.Progress.CompletionRetryWindow,
+			ConfirmCompletion:      cfg.Progress.ConfirmCompletion,
+			Monotonic:              progress.MonotonicMode(cfg.Progress.Monotonic),
+			Unwatched:              progress.UnwatchedMode(cfg.Progress.Unwatched),
+			UnwatchedInterval:      cfg.Progress.UnwatchedInterval,
+			SlowOperationThreshold: cfg.Progress.SlowOperationThreshold,
+		}
+		if w.metrics != nil {
+			progressOpts.RedisObserver = w.metrics
+		}
+		w.publisher = progress.NewPublisher(w.redis, logger, progressOpts)
+		fallthrough
+	default:
+		w.events = w.publisher
+	}
+
+	var results *worker.ResultSink
+	if cfg.Results.BlobThreshold > 0 {
+		blobs, err := blobstore.New(&cfg.BlobStore)
+		if err != nil {
+			return fmt.Errorf("failed to create blob store: %w", err)
+		}
+		results = worker.NewResultSink(blobs, cfg.Results.BlobThreshold, worker.WithArtifactPolicy(cfg.Results.Artifacts.Policy()))
+	}
+
+	w.registry = worker.NewRegistry(logger)
+	if err := w.registry.RegisterAll(w.handlers...); err != nil {
+		return err
+	}
+
+	// 进度自检：定期向 canary 进度流发布事件，API 读取同一个流确认两侧配置一致
+	if cfg.Progress.Canary.Enabled && w.publisher != nil {
+		w.canary = progress.NewPublishCanary(w.publisher, cfg.Progress.Canary.TaskID, logger)
+		w.background = append(w.background, func(ctx context.Context) {
+			w.canary.Run(ctx, cfg.Progress.Canary.Interval)
+		})
+	}
+
+	if cfg.GRPCServices.Enabled && len(cfg.GRPCServices.Services) > 0 {
+		if err := w.buildGRPC(results); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("registered handlers", zap.Strings("types", w.registry.Types()))
+
+	// 按 worker 标签追加需要消费的标签队列
+	w.routes = routing.NewTable(&cfg.Routing)
+	w.baseQueues = w.routes.Queues(cfg.Queues.ToMap(), cfg.Server.Worker.Labels)
+	w.queues = w.baseQueues
+
+	// 启动时直接使用 Redis 中的队列权重覆盖，读取失败时先用配置，由 weightWatcher 稍后应用
+	w.weightStore = discovery.NewWeightStore(w.redis)
+	weightsCtx, weightsCancel := context.WithTimeout(context.Background(), startupTimeout)
+	if override, err := w.weightStore.Get(weightsCtx); err != nil {
+		logger.Warn("failed to read queue weight override, using configured weights", zap.Error(err))
+	} else {
+		w.queues = worker.ApplyWeights(w.baseQueues, override.Weights)
+		w.weightsVersion = override.Version
+	}
+	weightsCancel()
+
+	if w.asynqLogLevel == nil {
+		level := zap.NewAtomicLevelAt(logging.ParseLevel(cfg.Logging.Asynq.Level))
+		w.asynqLogLevel = &level
+	}
+	server, err := asynqqueue.NewServer(asynqqueue.ServerConfig{
+		Redis:       &cfg.Redis,
+		Queues:      w.queues,
+		Concurrency: cfg.Server.Worker.Concurrency,
+		Logger:      logger,
+
+		LogLevel:          *w.asynqLogLevel,
+		LogSuppressWindow: cfg.Logging.Asynq.SuppressWindow,
+		RetryJitter:       cfg.Server.Worker.RetryJitter,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create server: %w", err)
+	}
+	w.server = server
+
+	var (
+		panicRecorder worker.PanicRecorder
+		panicRules    []worker.PanicRule
+	)
+	if w.metrics != nil {
+		panicRecorder = w.metrics
+		for _, rule := range cfg.Metrics.PanicClasses {
+			panicRules = append(panicRules, worker.PanicRule{Class: rule.Class, Match: rule.Match})
+		}
+
+		// 导出本 worker 消费队列中最大的待聚合分组
+		queueClient, err := asynqqueue.NewClient(&cfg.Redis,
+			asynqqueue.WithOperationTimeout(cfg.OperationTimeouts.Inspector), asynqqueue.WithLogger(logger))
+		if err != nil {
+			return fmt.Errorf("failed to create queue client: %w", err)
+		}
+		w.closers = append(w.closers, queueClient.Close)
+		w.metrics.RegisterGroups(queueClient, slices.Sorted(maps.Keys(w.queues)), cfg.Metrics.TopGroups)
+		if w.publisher != nil {
+			w.metrics.RegisterProgressPublisher(w.publisher)
+		}
+		if w.canary != nil {
+			w.metrics.RegisterProgressCanary(w.canary)
+		}
+		if cfg.Metrics.QueueStats.Enabled {
+			w.metrics.RegisterQueues(queueClient, cfg.Metrics.QueueStats.CacheTTL, clock.Real())
+		}
+	}
+
+	// 任务老化：把等待过久的 pending 任务移到权重更高的队列。每个 worker 都会运行，
+	// 移动时先从原队列删除，同一个任务只会被一个 worker 移走
+	if cfg.Aging.Enabled {
+		agingClient, err := asynqqueue.NewClient(&cfg.Redis,
+			asynqqueue.WithOperationTimeout(cfg.OperationTimeouts.Inspector), asynqqueue.WithLogger(logger))
+		if err != nil {
+			return fmt.Errorf("failed to create queue client: %w", err)
+		}
+		w.closers = append(w.closers, agingClient.Close)
+		var agingOpts []aging.Option
+		if w.metrics != nil {
+			agingOpts = append(agingOpts, aging.WithRecorder(w.metrics))
+		}
+		mover := aging.New(agingClient, &cfg.Aging, logger, agingOpts...)
+		w.background = append(w.background, func(ctx context.Context) {
+			mover.Run(ctx, cfg.Aging.Interval)
+		})
+	}
+
+	// 清理不在配置中且持续为空的队列。每个 worker 都会运行，asynq 只删除空队列，
+	// 其他 worker 已删除的队列只清除本实例的指标
+	if cfg.QueueCleanup.Enabled {
+		cleanupClient, err := asynqqueue.NewClient(&cfg.Redis,
+			asynqqueue.WithOperationTimeout(cfg.OperationTimeouts.Inspector), asynqqueue.WithLogger(logger))
+		if err != nil {
+			return fmt.Errorf("failed to create queue client: %w", err)
+		}
+		w.closers = append(w.closers, cleanupClient.Close)
+		var cleanupOpts []queuecleanup.Option
+		if w.metrics != nil {
+			cleanupOpts = append(cleanupOpts, queuecleanup.WithRecorder(w.metrics))
+		}
+		cleaner := queuecleanup.New(cleanupClient, cfg.KnownQueues(), &cfg.QueueCleanup, logger, cleanupOpts...)
+		w.background = append(w.background, func(ctx context.Context) {
+			cleaner.Run(ctx, cfg.QueueCleanup.Interval)
+		})
+	}
+
+	// 按任务类型临时开启的调试采集，在任一 worker 上开启后由各 worker 定期从 Redis 读取
+	debugLogger, err := logging.NewDebugLogger(&cfg.Logging)
+	if err != nil {
+		return fmt.Errorf("failed to create debug logger: %w", err)
+	}
+	debugCfg := cfg.Server.Worker.DebugCapture
+	w.debugCaptures = worker.NewDebugCaptures(debugcapture.NewStore(w.redis, debugCfg.MaxEntries), worker.DebugCaptureLimits{
+		MaxDuration:   debugCfg.MaxDuration,
+		Retention:     debugCfg.Retention,
+		MaxFieldBytes: debugCfg.MaxFieldBytes,
+	}, logger, debugLogger, clock.Real())
+	w.background = append(w.background, func(ctx context.Context) {
+		w.debugCaptures.Run(ctx, 5*time.Second)
+	})
+
+	middlewares := []asynq.MiddlewareFunc{
+		// 最外层记录实例和执行次数，内层发布的进度和完成事件都能带上
+		worker.InstanceMiddleware(w.instance),
+		worker.TaskTypeMiddleware(),
+		w.debugCaptures.Middleware(),
+		worker.AttemptMiddleware(w.events, logger),
+	}
+	// 生命周期回调在 RecoveryMiddleware 之外，panic 也能收到结束回调
+	if !w.hooks.Empty() {
+		middlewares = append(middlewares, worker.LifecycleMiddleware(w.hooks, clock.Real()))
+	}
+	middlewares = append(middlewares,
+		worker.RecoveryMiddleware(logger, panicRecorder, worker.NewPanicClassifier(panicRules)),
+	)
+	switch {
+	case w.publisher == nil:
+	case cfg.Progress.PublishOnStart:
+		// 开始进度和完成事件由同一个中间件发布，publish_on_start 隐含 publish_on_finish
+		middlewares = append(middlewares, worker.ProgressMiddleware(w.events, logger, clock.Real()))
+	case cfg.Progress.PublishOnFinish:
+		middlewares = append(middlewares, worker.CompletionMiddleware(w.events, logger, clock.Real()))
+	}
+	if w.metrics != nil {
+		middlewares = append(middlewares, worker.MetricsMiddleware(w.metrics, clock.Real()))
+	}
+	// 登记本实例正在处理的任务，供 /active 查看
+	w.activeTasks = worker.NewActiveTasks(clock.Real())
+	middlewares = append(middlewares,
+		w.activeTasks.Middleware(),
+		worker.LoggingMiddleware(logger, clock.Real()),
+	)
+	w.server.Use(append(middlewares, w.middlewares...)...)
+
+	w.registry.SetupServer(w.server)
+
+	if cfg.Server.Worker.Health.Enabled {
+		w.healthServer = &http.Server{
+			Addr:              fmt.Sprintf("%s:%d", cfg.Server.Worker.Health.Host, cfg.Server.Worker.Health.Port),
+			Handler:           w.healthHandler(),
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       cfg.Server.Worker.Health.ReadTimeout,
+			WriteTimeout:      cfg.Server.Worker.Health.WriteTimeout,
+			IdleTimeout:       cfg.Server.Worker.Health.IdleTimeout,
+		}
+	}
+	return nil
+}
+
+// buildGRPC 创建 gRPC 客户端并注册 gRPC 任务处理器
+func (w *Worker) buildGRPC(results *worker.ResultSink) error {
+	cfg, logger := w.cfg, w.logger
+
+	clientConfigs := make(map[string]grpcclient.ClientConfig)
+	for name, svcCfg := range cfg.GRPCServices.Services {
+		clientConfigs[name] = grpcClientConfig(cfg, svcCfg)
+	}
+
+	clientManager, err := grpcclient.NewClientManager(clientConfigs, logger)
+	if err != nil {
+		return fmt.Errorf("failed to create grpc client manager: %w", err)
+	}
+	w.clientManager = clientManager
+	w.closers = append(w.closers, func() error {
+		clientManager.Close()
+		return nil
+	})
+
+	grpcTaskConfig := grpctask.Config{
+		Services: clientConfigs,
+		Defaults: grpcclient.ClientConfig{
+			Timeout:             cfg.GRPCServices.Defaults.Timeout,
+			HealthCheckInterval: cfg.GRPCServices.Defaults.HealthCheckInterval,
+			MaxRetries:          cfg.GRPCServices.Defaults.MaxRetries,
+			RetryDelay:          cfg.GRPCServices.Defaults.RetryDelay,
+			MaxAttempts:         cfg.GRPCServices.Defaults.MaxAttempts,
+		},
+		BudgetMargin: cfg.GRPCServices.BudgetMargin,
+		MinBudget:    cfg.GRPCServices.MinBudget,
+	}
+	var handlerOpts []grpctask.Option
+	if cfg.Schemas.Enabled {
+		// 与 API 共用 schema 注册表，按服务方法校验 gRPC 返回的结果
+		outputs := schema.NewRegistry(w.redis, logger)
+		w.background = append(w.background, func(ctx context.Context) {
+			outputs.Watch(ctx, cfg.Schemas.RefreshInterval)
+		})
+		handlerOpts = append(handlerOpts, grpctask.WithOutputValidator(outputs))
+	}
+	if tokens := cfg.Progress.ReportTokens; tokens.Enabled() {
+		handlerOpts = append(handlerOpts, grpctask.WithProgressTokens(
+			progresstoken.NewSigner(tokens.Secret, tokens.MaxTTL),
+			cfg.Server.HTTP.PublicURL,
+		))
+	}
+	if err := w.registry.Register(grpctask.NewHandler(logger, clientManager, grpcTaskConfig, w.events, results, handlerOpts...)); err != nil {
+		return err
+	}
+
+	logger.Info("grpc services initialized",
+		zap.Strings("services", clientManager.Services()),
+	)
+	return nil
+}
+
+// grpcClientConfig 生成服务的客户端配置。服务未设置 cancel_timeout、health_check_timeout 时
+// 使用 operation_timeouts 中的全局值
+func grpcClientConfig(cfg *config.Config, svcCfg config.GRPCServiceConfig) grpcclient.ClientConfig {
+	cancelTimeout := svcCfg.CancelTimeout
+	if cancelTimeout == 0 {
+		cancelTimeout = cfg.OperationTimeouts.Cancel
+	}
+	healthCheckTimeout := svcCfg.HealthCheckTimeout
+	if healthCheckTimeout == 0 {
+		healthCheckTimeout = cfg.OperationTimeouts.HealthCheck
+	}
+	return grpcclient.ClientConfig{
+		Address:             svcCfg.Address,
+		Timeout:             svcCfg.Timeout,
+		HealthCheckInterval: svcCfg.HealthCheckInterval,
+		MaxRetries:          svcCfg.MaxRetries,
+		RetryDelay:          svcCfg.RetryDelay,
+		MaxAttempts:         svcCfg.MaxAttempts,
+		Methods:             svcCfg.Methods,
+		DefaultMethod:       svcCfg.DefaultMethod,
+		Prewarm:             svcCfg.Prewarm,
+		PrewarmTimeout:      svcCfg.PrewarmTimeout,
+
+		MaxConcurrentStreams: svcCfg.MaxConcurrentStreams,
+		StreamWaitTimeout:    svcCfg.StreamWaitTimeout,
+		ResultMode:           svcCfg.ResultMode,
+		ResponseMetadata:     svcCfg.ResponseMetadata,
+		CancelTimeout:        cancelTimeout,
+		HealthCheckTimeout:   healthCheckTimeout,
+		HealthCheckRetries:   svcCfg.HealthCheckRetries,
+		HealthCheckProtocol:  svcCfg.HealthCheckProtocol,
+		HealthCheckService:   svcCfg.HealthCheckService,
+	}
+}
+
+// Start 写入配置指纹、启动健康检查服务，预热完成后开始消费并注册到服务发现，不阻塞。
+// 预热最长等待 server.worker.warmup_timeout。返回错误时不会留下运行中的 goroutine 或监听的端口
+func (w *Worker) Start() error {
+	cfg, logger := w.cfg, w.logger
+
+	// 写入配置指纹，API 启动和 /health?verbose=true 时与自身配置比较
+	fingerprint := discovery.NewFingerprint(cfg)
+	fingerprint.InstanceID = w.instance.ID
+	fingerprint.WrittenAt = time.Now().UTC()
+	fingerprintRedis := discovery.NewFingerprintClient(&cfg.Redis)
+	fingerprintCtx, fingerprintCancel := context.WithTimeout(context.Background(), startupTimeout)
+	if err := discovery.NewFingerprintStore(fingerprintRedis).Write(fingerprintCtx, fingerprint); err != nil {
+		logger.Warn("failed to write config fingerprint", zap.Error(err))
+	}
+	fingerprintCancel()
+	fingerprintRedis.Close()
+
+	// 先占用健康检查端口，失败时还没有启动任何 goroutine
+	var listener net.Listener
+	if w.healthServer != nil {
+		var err error
+		listener, err = net.Listen("tcp", w.healthServer.Addr)
+		if err != nil {
+			return fmt.Errorf("failed to start worker health server: %w", err)
+		}
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	w.stop = stop
+	for _, run := range w.background {
+		go run(ctx)
+	}
+
+	if listener != nil {
+		go func() {
+			logger.Info("starting worker health server", zap.String("addr", w.healthServer.Addr))
+			if err := w.healthServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+				logger.Error("worker health server stopped", zap.Error(err))
+			}
+		}()
+	}
+
+	// 预热：等待 Redis 和 gRPC 服务就绪后再开始消费，避免首批任务白白消耗重试
+	var warmer worker.ServiceWarmer
+	if w.clientManager != nil {
+		warmer = w.clientManager
+	}
+	warm := worker.WarmUp(ctx, cfg.Server.Worker.WarmupTimeout, warmupInterval,
+		func(ctx context.Context) error { return w.redis.Ping(ctx).Err() }, warmer, clock.Real())
+	if warm.RedisErr != nil {
+		logger.Warn("redis not available after warm-up", zap.Error(warm.RedisErr))
+	} else {
+		w.checkQueues()
+	}
+	if len(warm.FailedServices) > 0 {
+		logger.Warn("grpc services not healthy after warm-up, starting anyway",
+			zap.Strings("services", warm.FailedServices),
+		)
+	}
+	w.warmedUp.Store(true)
+
+	if err := w.server.Start(); err != nil {
+		// 停止已启动的后台任务和健康检查服务，其余资源由 Shutdown 释放
+		stop()
+		if w.healthServer != nil {
+			_ = w.healthServer.Close()
+		}
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+
+	// 注册 worker 能力，供 API 判断任务类型是否有人消费
+	if cfg.Discovery.Enabled {
+		w.advertiser = discovery.NewAdvertiser(w.redis, logger, discovery.WorkerInfo{
+			InstanceID:  w.instance.ID,
+			Version:     w.version,
+			Types:       w.registry.Types(),
+			Queues:      w.queues,
+			Labels:      w.routes.Served(cfg.Server.Worker.Labels),
+			Concurrency: cfg.Server.Worker.Concurrency,
+
+			WeightsVersion: w.weightsVersion,
+		}, discovery.Options{
+			HeartbeatInterval: cfg.Discovery.HeartbeatInterval,
+			TTL:               cfg.Discovery.TTL,
+		})
+
+		registerCtx, cancel := context.WithTimeout(ctx, startupTimeout)
+		if err := w.advertiser.Start(registerCtx); err != nil {
+			logger.Warn("initial worker registration failed, will retry on heartbeat", zap.Error(err))
+		}
+		cancel()
+	}
+
+	// 队列权重覆盖变化时重启消费，已应用的版本通过心跳报告
+	weightWatcher := worker.NewWeightWatcher(w.weightStore, w.server, w.baseQueues, w.queues, w.weightsVersion, w.instance.ID,
+		func(ctx context.Context, queues map[string]int, version int64) {
+			if w.advertiser == nil {
+				return
+			}
+			if err := w.advertiser.UpdateQueues(ctx, queues, version); err != nil {
+				logger.Warn("failed to report applied queue weights", zap.Error(err))
+			}
+		}, logger, clock.Real())
+	go weightWatcher.Run(ctx, cfg.Server.Worker.WeightsPollInterval)
+	return nil
+}
+
+// Shutdown 从服务发现注销、停止健康检查服务，等待正在执行的任务结束后投递剩余的完成事件并释放资源
+func (w *Worker) Shutdown() {
+	logger := w.logger
+
+	if w.advertiser != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+		if err := w.advertiser.Stop(ctx); err != nil {
+			logger.Error("failed to deregister worker", zap.Error(err))
+		}
+		cancel()
+	}
+	if w.healthServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+		if err := w.healthServer.Shutdown(ctx); err != nil {
+			logger.Error("failed to shutdown health server", zap.Error(err))
+		}
+		cancel()
+	}
+	w.server.Shutdown()
+
+	if w.publisher != nil {
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), startupTimeout)
+		if remaining := w.publisher.Flush(flushCtx); remaining > 0 {
+			logger.Warn("undelivered completion events on shutdown", zap.Int("count", remaining))
+		}
+		flushCancel()
+	}
+
+	if w.stop != nil {
+		w.stop()
+	}
+	w.close()
+}
+
+// close 按相反顺序释放已创建的资源
+func (w *Worker) close() {
+	for i := len(w.closers) - 1; i >= 0; i-- {
+		if err := w.closers[i](); err != nil {
+			w.logger.Warn("failed to release worker resource", zap.Error(err))
+		}
+	}
+	w.closers = nil
+}
+
+// InstanceID 本实例的标识
+func (w *Worker) InstanceID() string {
+	return w.instance.ID
+}
+
+// Types 已注册处理器的任务类型
+func (w *Worker) Types() []string {
+	return w.registry.Types()
+}
+
+// ProgressPublisher Worker 使用的进度发布器，进度功能关闭时为 progress.NopPublisher，处理器可直接调用
+func (w *Worker) ProgressPublisher() progress.EventPublisher {
+	return w.events
+}
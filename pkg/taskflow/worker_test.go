@@ -0,0 +1,247 @@
+package taskflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"go.uber.org/goleak"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/Aixtrade/TaskFlow/internal/config"
+	"github.com/Aixtrade/TaskFlow/pkg/progress"
+)
+
+type echoHandler struct {
+	processed chan string
+}
+
+func (h *echoHandler) Type() string { return "embedded:echo" }
+
+func (h *echoHandler) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	h.processed <- string(task.Payload())
+	return nil
+}
+
+func testConfig(t *testing.T, redisAddr string) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := fmt.Sprintf(`
+server:
+  http:
+    port: 8080
+  worker:
+    concurrency: 1
+    warmup_timeout: 1s
+redis:
+  addr: %s
+queues:
+  critical: 4
+  high: 3
+  default: 2
+  low: 1
+`, redisAddr)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	return cfg
+}
+
+func TestWorkerRunsEmbeddedHandlerWithHooks(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := testConfig(t, mr.Addr())
+
+	handler := &echoHandler{processed: make(chan string, 1)}
+	started := make(chan TaskEvent, 1)
+	completed := make(chan TaskEvent, 1)
+	w, err := NewWorker(cfg,
+		WithHandlers(handler),
+		OnTaskStart(func(ctx context.Context, e TaskEvent) { started <- e }),
+		OnTaskComplete(func(ctx context.Context, e TaskEvent) { completed <- e }),
+	)
+	if err != nil {
+		t.Fatalf("new worker: %v", err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer w.Shutdown()
+
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: mr.Addr()})
+	defer client.Close()
+	info, err := client.Enqueue(asynq.NewTask("embedded:echo", []byte("hello")))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	select {
+	case e := <-started:
+		if e.ID != info.ID || e.Type != "embedded:echo" || e.Queue != "default" {
+			t.Fatalf("unexpected start event %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("start hook not called")
+	}
+	if got := <-handler.processed; got != "hello" {
+		t.Fatalf("unexpected payload %q", got)
+	}
+	select {
+	case e := <-completed:
+		if e.ID != info.ID || e.Status != "completed" || e.Err != nil {
+			t.Fatalf("unexpected completion event %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("complete hook not called")
+	}
+}
+
+func TestWorkerStartFailureLeavesNothingRunning(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := testConfig(t, mr.Addr())
+
+	// 健康检查端口已被占用
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer busy.Close()
+	cfg.Server.Worker.Health.Enabled = true
+	cfg.Server.Worker.Health.Host = "127.0.0.1"
+	cfg.Server.Worker.Health.Port = busy.Addr().(*net.TCPAddr).Port
+
+	w, err := NewWorker(cfg)
+	if err != nil {
+		t.Fatalf("new worker: %v", err)
+	}
+	defer w.Shutdown()
+
+	ignore := goleak.IgnoreCurrent()
+	if err := w.Start(); err == nil {
+		t.Fatal("expected start to fail on a busy health port")
+	}
+	goleak.VerifyNone(t, ignore)
+}
+
+func TestNewWorkerRejectsDuplicateHandlers(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := testConfig(t, mr.Addr())
+
+	_, err := NewWorker(cfg, WithHandlers(&echoHandler{}, &echoHandler{}))
+	if err == nil {
+		t.Fatalf("expected duplicate handler error")
+	}
+}
+
+func TestWorkerProgressEnabledAndDisabled(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%v", enabled), func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			cfg := testConfig(t, mr.Addr())
+			cfg.Progress.Enabled = &enabled
+			cfg.Progress.PublishOnFinish = true
+
+			completed := make(chan TaskEvent, 1)
+			w, err := NewWorker(cfg,
+				WithHandlers(&echoHandler{processed: make(chan string, 1)}),
+				OnTaskComplete(func(ctx context.Context, e TaskEvent) { completed <- e }),
+			)
+			if err != nil {
+				t.Fatalf("new worker: %v", err)
+			}
+			if _, nop := w.ProgressPublisher().(progress.NopPublisher); nop == enabled {
+				t.Fatalf("unexpected progress publisher %T", w.ProgressPublisher())
+			}
+			if err := w.Start(); err != nil {
+				t.Fatalf("start: %v", err)
+			}
+			defer w.Shutdown()
+
+			client := asynq.NewClient(asynq.RedisClientOpt{Addr: mr.Addr()})
+			defer client.Close()
+			info, err := client.Enqueue(asynq.NewTask("embedded:echo", []byte("hello")))
+			if err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+			select {
+			case <-completed:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("task not completed")
+			}
+			if got := mr.Exists(progress.StreamKey(info.ID)); got != enabled {
+				t.Fatalf("progress stream exists = %v, want %v", got, enabled)
+			}
+
+			rec := httptest.NewRecorder()
+			w.healthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+			var body struct {
+				Services map[string]string `json:"services"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decode health: %v", err)
+			}
+			want := "healthy"
+			if !enabled {
+				want = "disabled"
+			}
+			if body.Services["progress"] != want {
+				t.Fatalf("progress health = %q, want %q", body.Services["progress"], want)
+			}
+		})
+	}
+}
+
+func TestWorkerWarnsAboutUnconsumedQueues(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := testConfig(t, mr.Addr())
+	cfg.Routing.Routes = []config.LabelRouteConfig{{Requires: []string{"gpu"}, Suffix: "gpu"}}
+	for _, queue := range []string{"default", "default.gpu", "Default "} {
+		mr.SAdd("asynq:queues", queue)
+	}
+
+	core, logs := observer.New(zap.DebugLevel)
+	w, err := NewWorker(cfg, WithLogger(zap.New(core)))
+	if err != nil {
+		t.Fatalf("new worker: %v", err)
+	}
+	t.Cleanup(w.close)
+	w.checkQueues()
+
+	warnings := logs.FilterMessage("queues in redis are not consumed by this worker").All()
+	if len(warnings) != 1 || warnings[0].Level != zap.WarnLevel {
+		t.Fatalf("expected one warning, got %v", logs.All())
+	}
+	if queues := warnings[0].ContextMap()["queues"]; fmt.Sprint(queues) != "[Default ]" {
+		t.Fatalf("expected only the misspelled queue in the warning, got %v", queues)
+	}
+	if logs.FilterMessage("queues in redis are consumed by workers with other labels").Len() != 1 {
+		t.Fatalf("expected the labelled queue to be logged separately, got %v", logs.All())
+	}
+}
+
+func TestGRPCClientConfigPrefersServiceTimeouts(t *testing.T) {
+	cfg := &config.Config{OperationTimeouts: config.OperationTimeoutsConfig{Cancel: 10 * time.Second, HealthCheck: 5 * time.Second}}
+
+	client := grpcClientConfig(cfg, config.GRPCServiceConfig{Address: "llm:50051"})
+	if client.CancelTimeout != 10*time.Second || client.HealthCheckTimeout != 5*time.Second {
+		t.Fatalf("expected the global timeouts, got cancel=%v health=%v", client.CancelTimeout, client.HealthCheckTimeout)
+	}
+
+	client = grpcClientConfig(cfg, config.GRPCServiceConfig{Address: "llm:50051", CancelTimeout: 30 * time.Second, HealthCheckTimeout: 2 * time.Second})
+	if client.CancelTimeout != 30*time.Second || client.HealthCheckTimeout != 2*time.Second {
+		t.Fatalf("expected the service timeouts, got cancel=%v health=%v", client.CancelTimeout, client.HealthCheckTimeout)
+	}
+}
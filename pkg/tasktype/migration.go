@@ -0,0 +1,42 @@
+package tasktype
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Migration 将任务类型已弃用的 payload 格式改写为新格式。
+// 迁移在代码中注册，配置中的弃用项（deprecations[].migration）按名称引用
+type Migration struct {
+	// Type 适用的任务类型
+	Type Type
+	// Matches 判断 payload 是否为旧格式，为空时该类型的所有 payload 都视为旧格式
+	Matches func(payload []byte) bool
+	// Migrate 返回新格式的 payload，为空时只能标记弃用，不能改写
+	Migrate func(payload []byte) ([]byte, error)
+}
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = make(map[string]Migration)
+)
+
+// RegisterMigration 以 name 注册 payload 迁移，通常在 init 中调用；重复注册同一名称会 panic
+func RegisterMigration(name string, m Migration) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+
+	if _, ok := migrations[name]; ok {
+		panic(fmt.Sprintf("tasktype: migration %q already registered", name))
+	}
+	migrations[name] = m
+}
+
+// LookupMigration 返回以 name 注册的迁移
+func LookupMigration(name string) (Migration, bool) {
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+
+	m, ok := migrations[name]
+	return m, ok
+}